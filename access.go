@@ -0,0 +1,185 @@
+// +build windows
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus-community/windows_exporter/log"
+	"gopkg.in/yaml.v2"
+)
+
+// collectorAccess restricts the collectors served to authenticated clients,
+// as configured in the collector_access section of the configuration file.
+type collectorAccess struct {
+	// collectors holds the collectors served to each lower-cased identity,
+	// nil for identities served all enabled collectors.
+	collectors map[string][]string
+}
+
+// newCollectorAccess returns the access of the entries, or nil if there are
+// none, in which case all clients are served all enabled collectors.
+func newCollectorAccess(entries []config.CollectorAccess, enabled map[string]collector.Collector) (*collectorAccess, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	a := &collectorAccess{collectors: make(map[string][]string)}
+	for _, e := range entries {
+		var collectors []string
+		if e.Collectors != "" {
+			collectors = expandEnabledCollectors(e.Collectors)
+			for _, name := range collectors {
+				if _, ok := enabled[name]; !ok {
+					return nil, fmt.Errorf("collector %s isn't enabled", name)
+				}
+			}
+		}
+		for _, id := range e.Identities {
+			a.collectors[strings.ToLower(id)] = collectors
+		}
+	}
+	return a, nil
+}
+
+// allowed returns the collectors served to the client of r, nil if it is
+// served all enabled collectors. The identity matched is returned, and is
+// empty if none of the client's identities is listed.
+func (a *collectorAccess) allowed(r *http.Request) ([]string, string) {
+	for _, id := range requestIdentities(r) {
+		if collectors, ok := a.collectors[strings.ToLower(id)]; ok {
+			return collectors, id
+		}
+	}
+	return nil, ""
+}
+
+// restrict returns the requested collectors if the client of r is allowed to
+// scrape them. If none are requested, it returns those of served, or of all
+// enabled collectors if served is nil, that the client is allowed to scrape,
+// nil if it is allowed to scrape all of them.
+func (a *collectorAccess) restrict(r *http.Request, requested []string, served []string) ([]string, error) {
+	allowed, id := a.allowed(r)
+	if id == "" {
+		return nil, fmt.Errorf("client not allowed to scrape any collector")
+	}
+	if allowed == nil {
+		return requested, nil
+	}
+
+	set := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		set[name] = true
+	}
+	if len(requested) > 0 {
+		for _, name := range requested {
+			if !set[name] {
+				return nil, fmt.Errorf("%s not allowed to scrape collector %s", id, name)
+			}
+		}
+		return requested, nil
+	}
+	if served == nil {
+		return allowed, nil
+	}
+	var result []string
+	for _, name := range served {
+		if set[name] {
+			result = append(result, name)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%s not allowed to scrape any collector served by this endpoint", id)
+	}
+	return result, nil
+}
+
+// fullAccess returns whether the client of r is served all enabled
+// collectors, which endpoints exposing data beyond the collectors, e.g. raw
+// performance counters, require.
+func (a *collectorAccess) fullAccess(r *http.Request) bool {
+	allowed, id := a.allowed(r)
+	return id != "" && allowed == nil
+}
+
+// withFullAccess rejects requests unless their client is served all enabled
+// collectors. A nil access allows all requests.
+func withFullAccess(a *collectorAccess, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.fullAccess(r) {
+			log.Warnf("Rejecting request from %s to %s: client not served all collectors", r.RemoteAddr, r.URL.Path)
+			http.Error(w, "client not allowed to access "+r.URL.Path, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type basicAuthVerifiedKey struct{}
+
+// withBasicAuthVerified marks the requests of a listener as having their
+// basic auth password verified if its web config lists basic_auth_users.
+// exporter-toolkit reads the web config on every request and only checks
+// passwords if it lists users, so it is read again on every request too.
+func withBasicAuthVerified(webConfigFile string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if webConfigHasUsers(webConfigFile) {
+			r = r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webConfigHasUsers returns whether the web config file lists
+// basic_auth_users.
+func webConfigHasUsers(path string) bool {
+	if path == "" {
+		return false
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var c struct {
+		Users map[string]string `yaml:"basic_auth_users"`
+	}
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return false
+	}
+	return len(c.Users) > 0
+}
+
+// requestIdentities returns the identities of the client of r: the subject
+// common name and SANs of its verified client certificate, and its basic
+// auth user name if the listener verified its password.
+func requestIdentities(r *http.Request) []string {
+	var ids []string
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		ids = append(ids, certificateNames(r.TLS.VerifiedChains[0][0])...)
+	}
+	if verified, _ := r.Context().Value(basicAuthVerifiedKey{}).(bool); verified {
+		if user, _, ok := r.BasicAuth(); ok && user != "" {
+			ids = append(ids, user)
+		}
+	}
+	return ids
+}
+
+// certificateNames returns the subject common name and the SANs of cert.
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
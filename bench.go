@@ -0,0 +1,41 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+)
+
+// runBench measures the cost of scraping each of the enabled collectors on
+// the local host and writes it as a table, most expensive first.
+func runBench(enabled string, iterations int, w io.Writer) error {
+	collectors, err := loadCollectors(enabled)
+	if err != nil {
+		return err
+	}
+
+	results := make([]collector.BenchResult, 0, len(collectors))
+	for name, c := range collectors {
+		r, err := collector.Bench(name, c, iterations, collector.PrepareScrapeContext)
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CPUTime > results[j].CPUTime
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "COLLECTOR\tMETRICS\tWALL TIME\tCPU TIME\tALLOCS\tALLOC BYTES\tERRORS\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%d/%d\t\n",
+			r.Collector, r.Metrics, r.WallTime, r.CPUTime, r.Allocs, r.AllocBytes, r.Errors, r.Iterations+1)
+	}
+	return tw.Flush()
+}
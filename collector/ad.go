@@ -4,16 +4,31 @@ package collector
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
 
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 func init() {
-	registerCollector("ad", NewADCollector)
+	registerCollector("ad", NewADCollector, "Database ==> Instances")
 }
 
+var adLogger = log.With("collector", "ad")
+
+var (
+	adDomainControllers = kingpin.Flag(
+		"collector.ad.domain-controllers",
+		"Comma-separated list of domain controllers to collect from instead of the local machine. Their metrics are labeled with dc=<name>.",
+	).Default("").String()
+)
+
 // A ADCollector is a Prometheus collector for WMI Win32_PerfRawData_DirectoryServices_DirectoryServices metrics
 type ADCollector struct {
 	AddressBookOperationsTotal                          *prometheus.Desc
@@ -77,388 +92,499 @@ type ADCollector struct {
 	SamPasswordChangesTotal                             *prometheus.Desc
 	TombstonedObjectsCollectedTotal                     *prometheus.Desc
 	TombstonedObjectsVisitedTotal                       *prometheus.Desc
+
+	DatabaseFileSizeBytes           *prometheus.Desc
+	DatabaseLogVolumeFreeBytes      *prometheus.Desc
+	DatabaseLogVolumeSizeBytes      *prometheus.Desc
+	DatabaseVersionBucketsAllocated *prometheus.Desc
+	DatabaseDefragmentationTasks    *prometheus.Desc
+	DatabaseDefragmentationPending  *prometheus.Desc
+	DatabaseOnlineDefragPagesTotal  *prometheus.Desc
+
+	// server is the domain controller to query, or empty for the local machine.
+	server string
+}
+
+// adMultiCollector collects the domain controllers given by --collector.ad.domain-controllers.
+type adMultiCollector struct {
+	collectors []*ADCollector
 }
 
 // NewADCollector ...
 func NewADCollector() (Collector, error) {
+	if *adDomainControllers == "" {
+		return newADCollector("", nil), nil
+	}
+
+	c := &adMultiCollector{}
+	for _, dc := range strings.Split(*adDomainControllers, ",") {
+		dc = strings.TrimSpace(dc)
+		if dc == "" {
+			continue
+		}
+		c.collectors = append(c.collectors, newADCollector(dc, prometheus.Labels{"dc": dc}))
+	}
+	return c, nil
+}
+
+func newADCollector(server string, constLabels prometheus.Labels) *ADCollector {
 	const subsystem = "ad"
 	return &ADCollector{
 		AddressBookOperationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "address_book_operations_total"),
 			"",
 			[]string{"operation"},
-			nil,
+			constLabels,
 		),
 		AddressBookClientSessions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "address_book_client_sessions"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ApproximateHighestDistinguishedNameTag: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "approximate_highest_distinguished_name_tag"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		AtqEstimatedDelaySeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "atq_estimated_delay_seconds"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		AtqOutstandingRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "atq_outstanding_requests"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		AtqAverageRequestLatency: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "atq_average_request_latency"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		AtqCurrentThreads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "atq_current_threads"),
 			"",
 			[]string{"service"},
-			nil,
+			constLabels,
 		),
 		SearchesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "searches_total"),
 			"",
 			[]string{"scope"},
-			nil,
+			constLabels,
 		),
 		DatabaseOperationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "database_operations_total"),
 			"",
 			[]string{"operation"},
-			nil,
+			constLabels,
 		),
 		BindsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "binds_total"),
 			"",
 			[]string{"bind_method"},
-			nil,
+			constLabels,
 		),
 		ReplicationHighestUsn: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_highest_usn"),
 			"",
 			[]string{"state"},
-			nil,
+			constLabels,
 		),
 		IntrasiteReplicationDataBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_data_intrasite_bytes_total"),
 			"",
 			[]string{"direction"},
-			nil,
+			constLabels,
 		),
 		IntersiteReplicationDataBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_data_intersite_bytes_total"),
 			"",
 			[]string{"direction"},
-			nil,
+			constLabels,
 		),
 		ReplicationInboundSyncObjectsRemaining: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_sync_objects_remaining"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationInboundLinkValueUpdatesRemaining: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_link_value_updates_remaining"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationInboundObjectsUpdatedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_objects_updated_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationInboundObjectsFilteredTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_objects_filtered_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationInboundPropertiesUpdatedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_properties_updated_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationInboundPropertiesFilteredTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_inbound_properties_filtered_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationPendingOperations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_pending_operations"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationPendingSynchronizations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_pending_synchronizations"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationSyncRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_sync_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationSyncRequestsSuccessTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_sync_requests_success_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ReplicationSyncRequestsSchemaMismatchFailureTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "replication_sync_requests_schema_mismatch_failure_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		NameTranslationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "name_translations_total"),
 			"",
 			[]string{"target_name"},
-			nil,
+			constLabels,
 		),
 		ChangeMonitorsRegistered: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "change_monitors_registered"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		ChangeMonitorUpdatesPending: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "change_monitor_updates_pending"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		NameCacheHitsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "name_cache_hits_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		NameCacheLookupsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "name_cache_lookups_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		DirectoryOperationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "directory_operations_total"),
 			"",
 			[]string{"operation", "origin"},
-			nil,
+			constLabels,
 		),
 		DirectorySearchSuboperationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "directory_search_suboperations_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SecurityDescriptorPropagationEventsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "security_descriptor_propagation_events_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SecurityDescriptorPropagationEventsQueued: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "security_descriptor_propagation_events_queued"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SecurityDescriptorPropagationAccessWaitTotalSeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "security_descriptor_propagation_access_wait_total_seconds"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SecurityDescriptorPropagationItemsQueuedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "security_descriptor_propagation_items_queued_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		DirectoryServiceThreads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "directory_service_threads"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapClosedConnectionsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_closed_connections_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapOpenedConnectionsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_opened_connections_total"),
 			"",
 			[]string{"type"},
-			nil,
+			constLabels,
 		),
 		LdapActiveThreads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_active_threads"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapLastBindTimeSeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_last_bind_time_seconds"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapSearchesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_searches_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapUdpOperationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_udp_operations_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LdapWritesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "ldap_writes_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		LinkValuesCleanedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "link_values_cleaned_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		PhantomObjectsCleanedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "phantom_objects_cleaned_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		PhantomObjectsVisitedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "phantom_objects_visited_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamGroupMembershipEvaluationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_group_membership_evaluations_total"),
 			"",
 			[]string{"group_type"},
-			nil,
+			constLabels,
 		),
 		SamGroupMembershipGlobalCatalogEvaluationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_group_membership_global_catalog_evaluations_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamGroupMembershipEvaluationsNontransitiveTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_group_membership_evaluations_nontransitive_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamGroupMembershipEvaluationsTransitiveTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_group_membership_evaluations_transitive_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamGroupEvaluationLatency: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_group_evaluation_latency"),
 			"The mean latency of the last 100 group evaluations performed for authentication",
 			[]string{"evaluation_type"},
-			nil,
+			constLabels,
 		),
 		SamComputerCreationRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_computer_creation_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamComputerCreationSuccessfulRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_computer_creation_successful_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamUserCreationRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_user_creation_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamUserCreationSuccessfulRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_user_creation_successful_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamQueryDisplayRequestsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_query_display_requests_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamEnumerationsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_enumerations_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamMembershipChangesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_membership_changes_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		SamPasswordChangesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sam_password_changes_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		TombstonedObjectsCollectedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "tombstoned_objects_collected_total"),
 			"",
 			nil,
-			nil,
+			constLabels,
 		),
 		TombstonedObjectsVisitedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "tombstoned_objects_visited_total"),
 			"",
 			nil,
+			constLabels,
+		),
+		DatabaseFileSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_file_size_bytes"),
+			"Size of the directory database file (NTDS.dit)",
+			nil,
+			constLabels,
+		),
+		DatabaseLogVolumeFreeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_log_volume_free_bytes"),
+			"Free space of the volume holding the directory database log files",
+			nil,
+			constLabels,
+		),
+		DatabaseLogVolumeSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_log_volume_size_bytes"),
+			"Size of the volume holding the directory database log files",
+			nil,
+			constLabels,
+		),
+		DatabaseVersionBucketsAllocated: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_version_buckets_allocated"),
+			"Version store buckets allocated by the directory database",
 			nil,
+			constLabels,
 		),
-	}, nil
+		DatabaseDefragmentationTasks: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_defragmentation_tasks"),
+			"Background defragmentation tasks running on the directory database",
+			nil,
+			constLabels,
+		),
+		DatabaseDefragmentationPending: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_defragmentation_tasks_pending"),
+			"Background defragmentation tasks of the directory database waiting to run",
+			nil,
+			constLabels,
+		),
+		DatabaseOnlineDefragPagesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_online_defrag_pages_total"),
+			"Pages of the directory database read, dirtied or freed by online defragmentation",
+			[]string{"operation"},
+			constLabels,
+		),
+
+		server: server,
+	}
 }
 
 // Collect sends the metric values for each metric
 // to the provided prometheus Metric channel.
 func (c *ADCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
-	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting ad metrics:", desc, err)
+	if desc, err := c.collect(ctx, ch); err != nil {
+		if c.server != "" {
+			adLogger.Error("failed collecting ad metrics from ", c.server, ":", desc, err)
+		} else {
+			adLogger.Error("failed collecting ad metrics:", desc, err)
+		}
 		return err
 	}
+	// The database files and counters are only available locally.
+	if c.server == "" {
+		if desc, err := c.collectDatabase(ctx, ch); err != nil {
+			adLogger.Error("failed collecting ad database metrics:", desc, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Collect collects all domain controllers concurrently. It fails if any of them failed.
+func (c *adMultiCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	wg := sync.WaitGroup{}
+	var mu sync.Mutex
+	var failed []string
+	for _, dc := range c.collectors {
+		wg.Add(1)
+		go func(dc *ADCollector) {
+			defer wg.Done()
+			if err := dc.Collect(ctx, ch); err != nil {
+				mu.Lock()
+				failed = append(failed, dc.server)
+				mu.Unlock()
+			}
+		}(dc)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed collecting ad metrics from %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
 
@@ -614,10 +740,15 @@ type Win32_PerfRawData_DirectoryServices_DirectoryServices struct {
 	TransitivesuboperationsPersec                                    uint32
 }
 
-func (c *ADCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *ADCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_DirectoryServices_DirectoryServices
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	var connectServerArgs []interface{}
+	if c.server != "" {
+		connectServerArgs = append(connectServerArgs, c.server)
+	}
+	// Remote domain controllers may not answer before the scrape times out.
+	if err := queryWMIContext(ctx.Context(), q, &dst, connectServerArgs...); err != nil {
 		return nil, err
 	}
 	if len(dst) == 0 {
@@ -1312,3 +1443,97 @@ func (c *ADCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, er
 
 	return nil, nil
 }
+
+// adDatabaseInstance is the ESE instance of the directory database, which runs
+// in lsass.exe.
+const adDatabaseInstance = "lsass/NTDSA"
+
+func (c *ADCollector) collectDatabase(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\NTDS\Parameters`, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	dbFile, _, err := k.GetStringValue("DSA Database file")
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dbFile)
+	if err != nil {
+		return c.DatabaseFileSizeBytes, err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.DatabaseFileSizeBytes,
+		prometheus.GaugeValue,
+		float64(info.Size()),
+	)
+
+	logPath, _, err := k.GetStringValue("Database log files path")
+	if err != nil {
+		return nil, err
+	}
+	logPathPtr, err := windows.UTF16PtrFromString(logPath)
+	if err != nil {
+		return nil, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(logPathPtr, &free, &total, &totalFree); err != nil {
+		return c.DatabaseLogVolumeFreeBytes, err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.DatabaseLogVolumeFreeBytes,
+		prometheus.GaugeValue,
+		float64(totalFree),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.DatabaseLogVolumeSizeBytes,
+		prometheus.GaugeValue,
+		float64(total),
+	)
+
+	var dst []esentDatabaseInstance
+	if err := unmarshalObject(ctx.perfObjects["Database ==> Instances"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+	for _, instance := range dst {
+		if !strings.EqualFold(instance.Name, adDatabaseInstance) {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseVersionBucketsAllocated,
+			prometheus.GaugeValue,
+			instance.VersionBucketsAllocated,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseDefragmentationTasks,
+			prometheus.GaugeValue,
+			instance.DefragmentationTasks,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseDefragmentationPending,
+			prometheus.GaugeValue,
+			instance.DefragmentationTasksPending,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseOnlineDefragPagesTotal,
+			prometheus.CounterValue,
+			instance.OnlineDefragPagesRead,
+			"read",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseOnlineDefragPagesTotal,
+			prometheus.CounterValue,
+			instance.OnlineDefragPagesDirtied,
+			"dirtied",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DatabaseOnlineDefragPagesTotal,
+			prometheus.CounterValue,
+			instance.OnlineDefragPagesFreed,
+			"freed",
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,349 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/netapi32"
+	"github.com/prometheus-community/windows_exporter/headers/ntdsapi"
+	"github.com/prometheus-community/windows_exporter/headers/sysinfoapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("ad_forest", newADForestCollector)
+}
+
+var adForestLogger = log.With("collector", "ad_forest")
+
+var (
+	adForestDomain = kingpin.Flag(
+		"collector.ad_forest.domain",
+		"DNS name of a domain of the forest to monitor. Defaults to the domain of the local computer.",
+	).Default("").String()
+	adForestDiscoveryInterval = kingpin.Flag(
+		"collector.ad_forest.discovery-interval",
+		"How often to rediscover the domains and domain controllers of the forest.",
+	).Default("1h").Duration()
+)
+
+// adForestRoles names the FSMO roles, indexed by ntdsapi.Role.
+var adForestRoles = []string{"schema", "domain_naming", "pdc", "rid", "infrastructure"}
+
+// adForestShares are the shares every healthy domain controller provides.
+var adForestShares = []string{"SYSVOL", "NETLOGON"}
+
+// A ADForestCollector is a Prometheus collector for the health of all domain
+// controllers of a forest, collected centrally over DRS and SMB.
+type ADForestCollector struct {
+	DomainControllers              *prometheus.Desc
+	DCInfo                         *prometheus.Desc
+	DCUp                           *prometheus.Desc
+	DCResponseSeconds              *prometheus.Desc
+	SharePresent                   *prometheus.Desc
+	ReplicationConsecutiveFailures *prometheus.Desc
+	ReplicationLastSuccess         *prometheus.Desc
+	ReplicationLastResult          *prometheus.Desc
+	RoleOwner                      *prometheus.Desc
+
+	domain string
+
+	mu                sync.Mutex
+	discovered        time.Time
+	domainControllers []adForestDC
+}
+
+type adForestDC struct {
+	ntdsapi.DomainController
+	domain string
+}
+
+func newADForestCollector() (Collector, error) {
+	const subsystem = "ad_forest"
+
+	domain := *adForestDomain
+	if domain == "" {
+		var err error
+		domain, err = sysinfoapi.GetComputerName(sysinfoapi.ComputerNameDNSDomain)
+		if err != nil {
+			return nil, err
+		}
+		if domain == "" {
+			return nil, fmt.Errorf("computer isn't joined to a domain, set --collector.ad_forest.domain")
+		}
+	}
+
+	return &ADForestCollector{
+		DomainControllers: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "domain_controllers"),
+			"Number of domain controllers of the domain",
+			[]string{"domain"},
+			nil,
+		),
+		DCInfo: newInfoDesc(
+			subsystem+"_dc",
+			"A metric with a constant '1' value labeled with the domain and site of the domain controller",
+			[]string{"dc"},
+			"domain", "site",
+		),
+		DCUp: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "dc_up"),
+			"Whether the domain controller accepted a DRS binding",
+			[]string{"dc"},
+			nil,
+		),
+		DCResponseSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "dc_response_seconds"),
+			"Time taken to bind to the domain controller",
+			[]string{"dc"},
+			nil,
+		),
+		SharePresent: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "dc_share_present"),
+			"Whether the domain controller shares the SYSVOL or NETLOGON folder",
+			[]string{"dc", "share"},
+			nil,
+		),
+		ReplicationConsecutiveFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "replication_consecutive_failures"),
+			"Number of consecutive failed inbound replications of the naming context from the source domain controller",
+			[]string{"dc", "naming_context", "source_dc"},
+			nil,
+		),
+		ReplicationLastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "replication_last_success_timestamp_seconds"),
+			"Time of the last successful inbound replication of the naming context from the source domain controller",
+			[]string{"dc", "naming_context", "source_dc"},
+			nil,
+		),
+		ReplicationLastResult: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "replication_last_result"),
+			"Win32 error code of the last inbound replication of the naming context from the source domain controller, 0 on success",
+			[]string{"dc", "naming_context", "source_dc"},
+			nil,
+		),
+		RoleOwner: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "fsmo_role_owner"),
+			"A metric with a constant '1' value for the domain controller holding the FSMO role. domain is empty for the forest-wide roles",
+			[]string{"role", "domain", "dc"},
+			nil,
+		),
+		domain: domain,
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ADForestCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		adForestLogger.Error("failed collecting ad_forest metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// discover returns the domain controllers of all domains of the forest,
+// refreshing them every --collector.ad_forest.discovery-interval.
+func (c *ADForestCollector) discover() ([]adForestDC, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.domainControllers != nil && time.Since(c.discovered) < flagDuration(adForestDiscoveryInterval) {
+		return c.domainControllers, nil
+	}
+
+	h, err := ntdsapi.Bind(c.domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to %s: %v", c.domain, err)
+	}
+	defer h.Close()
+
+	domains, err := h.ListDomains()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %v", err)
+	}
+	var dcs []adForestDC
+	for _, dn := range domains {
+		domain := dnToDomain(dn)
+		domainDCs, err := h.DomainControllers(domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list domain controllers of %s: %v", domain, err)
+		}
+		for _, dc := range domainDCs {
+			dcs = append(dcs, adForestDC{DomainController: dc, domain: domain})
+		}
+	}
+	adForestLogger.Debugf("Discovered %d domain controllers in %d domains", len(dcs), len(domains))
+
+	c.domainControllers = dcs
+	c.discovered = time.Now()
+	return dcs, nil
+}
+
+func (c *ADForestCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	dcs, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	// NTDS Settings objects identify domain controllers in replication
+	// metadata and role ownership.
+	names := make(map[string]string, len(dcs))
+	perDomain := make(map[string]int)
+	for _, dc := range dcs {
+		names[strings.ToLower("CN=NTDS Settings,"+dc.ServerObjectName)] = dc.DNSHostName
+		perDomain[dc.domain]++
+	}
+	dcName := func(ntdsSettingsDN string) string {
+		if name, ok := names[strings.ToLower(ntdsSettingsDN)]; ok {
+			return name
+		}
+		return serverNameFromNTDSSettings(ntdsSettingsDN)
+	}
+
+	for domain, count := range perDomain {
+		ch <- prometheus.MustNewConstMetric(
+			c.DomainControllers,
+			prometheus.GaugeValue,
+			float64(count),
+			domain,
+		)
+	}
+
+	wg := sync.WaitGroup{}
+	var forestRoles sync.Once
+	for _, dc := range dcs {
+		wg.Add(1)
+		go func(dc adForestDC) {
+			defer wg.Done()
+			c.collectDC(ch, dc, dcName, &forestRoles)
+		}(dc)
+	}
+	wg.Wait()
+	return nil, nil
+}
+
+func (c *ADForestCollector) collectDC(ch chan<- prometheus.Metric, dc adForestDC, dcName func(string) string, forestRoles *sync.Once) {
+	ch <- newInfoMetric(c.DCInfo, dc.DNSHostName, dc.domain, dc.SiteName)
+
+	for _, share := range adForestShares {
+		present, err := netapi32.ShareExists(dc.DNSHostName, share)
+		if err != nil {
+			adForestLogger.Warnf("Failed to query share %s of %s: %v", share, dc.DNSHostName, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.SharePresent,
+			prometheus.GaugeValue,
+			boolToFloat(present),
+			dc.DNSHostName, share,
+		)
+	}
+
+	begin := time.Now()
+	h, err := ntdsapi.Bind(dc.DNSHostName)
+	duration := time.Since(begin)
+	ch <- prometheus.MustNewConstMetric(
+		c.DCUp,
+		prometheus.GaugeValue,
+		boolToFloat(err == nil),
+		dc.DNSHostName,
+	)
+	if err != nil {
+		adForestLogger.Warnf("Failed to bind to %s: %v", dc.DNSHostName, err)
+		return
+	}
+	defer h.Close()
+	ch <- prometheus.MustNewConstMetric(
+		c.DCResponseSeconds,
+		prometheus.GaugeValue,
+		duration.Seconds(),
+		dc.DNSHostName,
+	)
+
+	neighbors, err := h.ReplicationNeighbors()
+	if err != nil {
+		adForestLogger.Warnf("Failed to query replication neighbors of %s: %v", dc.DNSHostName, err)
+	}
+	for _, n := range neighbors {
+		source := dcName(n.SourceDsaDN)
+		ch <- prometheus.MustNewConstMetric(
+			c.ReplicationConsecutiveFailures,
+			prometheus.GaugeValue,
+			float64(n.ConsecutiveSyncFailures),
+			dc.DNSHostName, n.NamingContext, source,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ReplicationLastResult,
+			prometheus.GaugeValue,
+			float64(n.LastSyncResult),
+			dc.DNSHostName, n.NamingContext, source,
+		)
+		var lastSuccess float64
+		if n.LastSyncSuccess.HighDateTime != 0 || n.LastSyncSuccess.LowDateTime != 0 {
+			lastSuccess = float64(n.LastSyncSuccess.Nanoseconds()) / 1e9
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ReplicationLastSuccess,
+			prometheus.GaugeValue,
+			lastSuccess,
+			dc.DNSHostName, n.NamingContext, source,
+		)
+	}
+
+	// The PDC emulator of each domain reports the roles of its domain, and
+	// one of them the forest-wide roles.
+	if !dc.IsPDC {
+		return
+	}
+	owners, err := h.ListRoles()
+	if err != nil {
+		adForestLogger.Warnf("Failed to list FSMO roles of %s: %v", dc.domain, err)
+		return
+	}
+	roleOwner := func(role ntdsapi.Role, domain string) {
+		if int(role) < len(owners) && owners[role] != "" {
+			ch <- prometheus.MustNewConstMetric(
+				c.RoleOwner,
+				prometheus.GaugeValue,
+				1.0,
+				adForestRoles[role], domain, dcName(owners[role]),
+			)
+		}
+	}
+	roleOwner(ntdsapi.RolePDCOwner, dc.domain)
+	roleOwner(ntdsapi.RoleRIDOwner, dc.domain)
+	roleOwner(ntdsapi.RoleInfrastructureOwner, dc.domain)
+	forestRoles.Do(func() {
+		roleOwner(ntdsapi.RoleSchemaOwner, "")
+		roleOwner(ntdsapi.RoleDomainNamingOwner, "")
+	})
+}
+
+// dnToDomain converts the DN of a domain to its DNS name, e.g.
+// DC=corp,DC=example,DC=com to corp.example.com.
+func dnToDomain(dn string) string {
+	var parts []string
+	for _, rdn := range strings.Split(dn, ",") {
+		rdn = strings.TrimSpace(rdn)
+		if len(rdn) > 3 && strings.EqualFold(rdn[:3], "DC=") {
+			parts = append(parts, rdn[3:])
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// serverNameFromNTDSSettings returns the name of the server an NTDS Settings
+// object belongs to, e.g. DC01 for CN=NTDS Settings,CN=DC01,CN=Servers,...
+func serverNameFromNTDSSettings(dn string) string {
+	rdns := strings.Split(dn, ",")
+	if len(rdns) > 1 && strings.HasPrefix(strings.ToUpper(rdns[1]), "CN=") {
+		return rdns[1][3:]
+	}
+	return dn
+}
@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestDNToDomain(t *testing.T) {
+	if got := dnToDomain("DC=corp,DC=example,DC=com"); got != "corp.example.com" {
+		t.Errorf("expected corp.example.com, got %q", got)
+	}
+	if got := serverNameFromNTDSSettings("CN=NTDS Settings,CN=DC01,CN=Servers,CN=Default-First-Site-Name,CN=Sites,CN=Configuration,DC=example,DC=com"); got != "DC01" {
+		t.Errorf("expected DC01, got %q", got)
+	}
+}
+
+func BenchmarkADForestCollector(b *testing.B) {
+	benchmarkCollector(b, "ad_forest", newADForestCollector)
+}
@@ -0,0 +1,272 @@
+// +build windows
+
+package collector
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("adcs", newADCSCollector, "Certification Authority")
+}
+
+var adcsLogger = log.With("collector", "adcs")
+
+var adcsCRLDirectory = kingpin.Flag(
+	"collector.adcs.crl-directory",
+	"Directory the CA publishes its CRLs to. Empty to not report CRLs.",
+).Default(`C:\Windows\System32\CertSrv\CertEnroll`).String()
+
+// A ADCSCollector is a Prometheus collector for the requests processed by an
+// Active Directory Certificate Services Certification Authority and the CRLs
+// it published
+type ADCSCollector struct {
+	Requests                        *prometheus.Desc
+	FailedRequests                  *prometheus.Desc
+	IssuedRequests                  *prometheus.Desc
+	PendingRequests                 *prometheus.Desc
+	Retrievals                      *prometheus.Desc
+	RequestProcessingTime           *prometheus.Desc
+	RequestCryptographicSigningTime *prometheus.Desc
+	RequestPolicyModuleTime         *prometheus.Desc
+	RetrievalProcessingTime         *prometheus.Desc
+	CRLThisUpdate                   *prometheus.Desc
+	CRLNextUpdate                   *prometheus.Desc
+}
+
+func newADCSCollector() (Collector, error) {
+	const subsystem = "adcs"
+
+	return &ADCSCollector{
+		Requests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "requests_total"),
+			"Total certificate requests processed for the template",
+			[]string{"cert_template"},
+			nil,
+		),
+		FailedRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "failed_requests_total"),
+			"Total certificate requests for the template that were denied or failed",
+			[]string{"cert_template"},
+			nil,
+		),
+		IssuedRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "issued_requests_total"),
+			"Total certificate requests for the template that were issued",
+			[]string{"cert_template"},
+			nil,
+		),
+		PendingRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pending_requests_total"),
+			"Total certificate requests for the template that were set pending, e.g. for approval by a certificate manager",
+			[]string{"cert_template"},
+			nil,
+		),
+		Retrievals: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "retrievals_total"),
+			"Total retrievals of issued certificates of the template",
+			[]string{"cert_template"},
+			nil,
+		),
+		RequestProcessingTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "request_processing_time_seconds"),
+			"Time the last certificate request for the template took to process",
+			[]string{"cert_template"},
+			nil,
+		),
+		RequestCryptographicSigningTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "request_cryptographic_signing_time_seconds"),
+			"Time signing the last certificate issued for the template took",
+			[]string{"cert_template"},
+			nil,
+		),
+		RequestPolicyModuleTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "request_policy_module_processing_time_seconds"),
+			"Time the policy module took to process the last certificate request for the template",
+			[]string{"cert_template"},
+			nil,
+		),
+		RetrievalProcessingTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "retrieval_processing_time_seconds"),
+			"Time the last retrieval of a certificate of the template took to process",
+			[]string{"cert_template"},
+			nil,
+		),
+		CRLThisUpdate: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "crl_this_update_timestamp_seconds"),
+			"Time the CRL was published, as a Unix timestamp",
+			[]string{"crl"},
+			nil,
+		),
+		CRLNextUpdate: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "crl_next_update_timestamp_seconds"),
+			"Time the CRL expires, by when the next one must be published, as a Unix timestamp",
+			[]string{"crl"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ADCSCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectRequests(ctx, ch); err != nil {
+		adcsLogger.Error("failed collecting adcs request metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectCRLs(ch); err != nil {
+		adcsLogger.Error("failed collecting adcs crl metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// adcsTemplate holds the counters of the requests for a certificate template.
+// The processing times are those of the last request, in milliseconds.
+type adcsTemplate struct {
+	Name string
+
+	RequestsPerSec                    float64 `perflib:"Requests/sec"`
+	FailedRequestsPerSec              float64 `perflib:"Failed Requests/sec"`
+	IssuedRequestsPerSec              float64 `perflib:"Issued Requests/sec"`
+	PendingRequestsPerSec             float64 `perflib:"Pending Requests/sec"`
+	RetrievalsPerSec                  float64 `perflib:"Retrievals/sec"`
+	RequestProcessingTime             float64 `perflib:"Request processing time (ms)"`
+	RequestCryptographicSigningTime   float64 `perflib:"Request cryptographic signing time (ms)"`
+	RequestPolicyModuleProcessingTime float64 `perflib:"Request policy module processing time (ms)"`
+	RetrievalProcessingTime           float64 `perflib:"Retrieval processing time (ms)"`
+}
+
+func (c *ADCSCollector) collectRequests(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The object only exists while the Certificate Services are running.
+	if ctx.perfObjects["Certification Authority"] == nil {
+		return nil, nil
+	}
+	var dst []adcsTemplate
+	if err := unmarshalObject(ctx.perfObjects["Certification Authority"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, template := range dst {
+		if template.Name == "" || template.Name == "_Total" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.Requests,
+			prometheus.CounterValue,
+			template.RequestsPerSec,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.FailedRequests,
+			prometheus.CounterValue,
+			template.FailedRequestsPerSec,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.IssuedRequests,
+			prometheus.CounterValue,
+			template.IssuedRequestsPerSec,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PendingRequests,
+			prometheus.CounterValue,
+			template.PendingRequestsPerSec,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.Retrievals,
+			prometheus.CounterValue,
+			template.RetrievalsPerSec,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.RequestProcessingTime,
+			prometheus.GaugeValue,
+			template.RequestProcessingTime/1000,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.RequestCryptographicSigningTime,
+			prometheus.GaugeValue,
+			template.RequestCryptographicSigningTime/1000,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.RequestPolicyModuleTime,
+			prometheus.GaugeValue,
+			template.RequestPolicyModuleProcessingTime/1000,
+			template.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.RetrievalProcessingTime,
+			prometheus.GaugeValue,
+			template.RetrievalProcessingTime/1000,
+			template.Name,
+		)
+	}
+	return nil, nil
+}
+
+func (c *ADCSCollector) collectCRLs(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	dir := flagString(adcsCRLDirectory)
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		adcsLogger.Debugf("Not reporting CRLs, %s doesn't exist", dir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), ".crl") {
+			continue
+		}
+		thisUpdate, nextUpdate, err := readCRLUpdateTimes(filepath.Join(dir, f.Name()))
+		if err != nil {
+			adcsLogger.Warnf("Skipping CRL %s: %v", f.Name(), err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.CRLThisUpdate,
+			prometheus.GaugeValue,
+			float64(thisUpdate.Unix()),
+			f.Name(),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CRLNextUpdate,
+			prometheus.GaugeValue,
+			float64(nextUpdate.Unix()),
+			f.Name(),
+		)
+	}
+	return nil, nil
+}
+
+// readCRLUpdateTimes returns the thisUpdate and nextUpdate times of a CRL
+// file in DER or PEM encoding.
+func readCRLUpdateTimes(path string) (time.Time, time.Time, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return crl.TBSCertList.ThisUpdate, crl.TBSCertList.NextUpdate, nil
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func BenchmarkADCSCollector(b *testing.B) {
+	benchmarkCollector(b, "adcs", newADCSCollector)
+}
+
+func TestReadCRLUpdateTimes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thisUpdate := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	nextUpdate := thisUpdate.AddDate(0, 0, 7)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test CA"},
+		NotBefore:    thisUpdate.AddDate(-1, 0, 0),
+		NotAfter:     thisUpdate.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageCRLSign | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ca.CreateCRL(rand.Reader, key, nil, thisUpdate, nextUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "adcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Test CA.crl")
+	if err := ioutil.WriteFile(path, crl, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actualThis, actualNext, err := readCRLUpdateTimes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actualThis.Equal(thisUpdate) || !actualNext.Equal(nextUpdate) {
+		t.Errorf("CRL update times do not match!\nExpected result: %v, %v\nActual result: %v, %v", thisUpdate, nextUpdate, actualThis, actualNext)
+	}
+}
@@ -114,7 +114,7 @@ type perflibADFS struct {
 
 func (c *adfsCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var adfsData []perflibADFS
-	err := unmarshalObject(ctx.perfObjects["AD FS"], &adfsData)
+	err := unmarshalObject(ctx.perfObjects["AD FS"], &adfsData, ctx.logger())
 	if err != nil {
 		return err
 	}
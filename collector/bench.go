@@ -0,0 +1,120 @@
+// +build windows
+
+package collector
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+)
+
+// BenchResult is the cost of scraping a collector, averaged over the
+// iterations of Bench.
+type BenchResult struct {
+	Collector  string
+	Iterations int
+	Errors     int
+	// Metrics is the number of metrics sent per scrape.
+	Metrics int
+	// WallTime and CPUTime include querying the perflib objects the collector
+	// depends on. CPUTime is the user and kernel time of the exporter process,
+	// so it excludes time spent in other processes, e.g. the WMI provider
+	// hosts.
+	WallTime time.Duration
+	CPUTime  time.Duration
+	// Allocs and AllocBytes are the heap allocations of the exporter process.
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// Bench measures the cost of scraping the collector. prepare returns the
+// scrape context of each iteration, e.g. PrepareScrapeContext to query the
+// local host. A first scrape warms up caches and connections and isn't
+// measured. Collectors with a cache TTL are measured without their cache.
+func Bench(name string, c Collector, iterations int, prepare func(collectors []string) (*ScrapeContext, error)) (BenchResult, error) {
+	result := BenchResult{Collector: name, Iterations: iterations}
+	if iterations <= 0 {
+		return result, nil
+	}
+	if cached, ok := c.(*cachedCollector); ok {
+		c = cached.collector
+	}
+
+	var metrics chan<- prometheus.Metric
+	scrape := func() error {
+		ctx, err := prepare([]string{name})
+		if err != nil {
+			return err
+		}
+		return c.Collect(ctx, metrics)
+	}
+
+	metrics, count := countMetrics()
+	if err := scrape(); err != nil {
+		result.Errors++
+	}
+	count()
+
+	metrics, count = countMetrics()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	cpuBefore, err := processCPUTime()
+	if err != nil {
+		return result, err
+	}
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		if err := scrape(); err != nil {
+			result.Errors++
+		}
+	}
+	wall := time.Since(start)
+	cpuAfter, err := processCPUTime()
+	if err != nil {
+		return result, err
+	}
+	runtime.ReadMemStats(&after)
+
+	n := time.Duration(iterations)
+	result.Metrics = count() / iterations
+	result.WallTime = wall / n
+	result.CPUTime = (cpuAfter - cpuBefore) / n
+	result.Allocs = (after.Mallocs - before.Mallocs) / uint64(iterations)
+	result.AllocBytes = (after.TotalAlloc - before.TotalAlloc) / uint64(iterations)
+	return result, nil
+}
+
+// countMetrics returns a channel discarding the metrics sent to it, and a
+// function closing it and returning the number of metrics sent.
+func countMetrics() (chan<- prometheus.Metric, func() int) {
+	ch := make(chan prometheus.Metric, 1000)
+	counted := make(chan int)
+	go func() {
+		n := 0
+		for range ch {
+			n++
+		}
+		counted <- n
+	}()
+	return ch, func() int {
+		close(ch)
+		return <-counted
+	}
+}
+
+// processCPUTime returns the user and kernel time used by the exporter process.
+func processCPUTime() (time.Duration, error) {
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(windows.CurrentProcess(), &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	// Filetime.Nanoseconds converts from the Windows epoch, which doesn't
+	// apply to durations.
+	ticks := func(ft windows.Filetime) int64 {
+		return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)
+	}
+	return time.Duration(ticks(kernel)+ticks(user)) * 100, nil
+}
@@ -11,6 +11,8 @@ func init() {
 	registerCollector("cache", newCacheCollector, "Cache")
 }
 
+var cacheLogger = log.With("collector", "cache")
+
 // A CacheCollector is a Prometheus collector for Perflib Cache metrics
 type CacheCollector struct {
 	AsyncCopyReadsTotal         *prometheus.Desc
@@ -50,175 +52,175 @@ func newCacheCollector() (Collector, error) {
 	return &CacheCollector{
 		AsyncCopyReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "async_copy_reads_total"),
-			"(AsyncCopyReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "AsyncCopyReadsTotal"),
 			nil,
 			nil,
 		),
 		AsyncDataMapsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "async_data_maps_total"),
-			"(AsyncDataMapsTotal)",
+			counterHelp("Cache", perflibCache{}, "AsyncDataMapsTotal"),
 			nil,
 			nil,
 		),
 		AsyncFastReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "async_fast_reads_total"),
-			"(AsyncFastReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "AsyncFastReadsTotal"),
 			nil,
 			nil,
 		),
 		AsyncMDLReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "async_mdl_reads_total"),
-			"(AsyncMDLReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "AsyncMDLReadsTotal"),
 			nil,
 			nil,
 		),
 		AsyncPinReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "async_pin_reads_total"),
-			"(AsyncPinReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "AsyncPinReadsTotal"),
 			nil,
 			nil,
 		),
 		CopyReadHitsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "copy_read_hits_total"),
-			"(CopyReadHitsTotal)",
+			counterHelp("Cache", perflibCache{}, "CopyReadHitsTotal"),
 			nil,
 			nil,
 		),
 		CopyReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "copy_reads_total"),
-			"(CopyReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "CopyReadsTotal"),
 			nil,
 			nil,
 		),
 		DataFlushesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "data_flushes_total"),
-			"(DataFlushesTotal)",
+			counterHelp("Cache", perflibCache{}, "DataFlushesTotal"),
 			nil,
 			nil,
 		),
 		DataFlushPagesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "data_flush_pages_total"),
-			"(DataFlushPagesTotal)",
+			counterHelp("Cache", perflibCache{}, "DataFlushPagesTotal"),
 			nil,
 			nil,
 		),
 		DataMapHitsPercent: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "data_map_hits_percent"),
-			"(DataMapHitsPercent)",
+			counterHelp("Cache", perflibCache{}, "DataMapHitsPercent"),
 			nil,
 			nil,
 		),
 		DataMapPinsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "data_map_pins_total"),
-			"(DataMapPinsTotal)",
+			counterHelp("Cache", perflibCache{}, "DataMapPinsTotal"),
 			nil,
 			nil,
 		),
 		DataMapsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "data_maps_total"),
-			"(DataMapsTotal)",
+			counterHelp("Cache", perflibCache{}, "DataMapsTotal"),
 			nil,
 			nil,
 		),
 		DirtyPages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dirty_pages"),
-			"(DirtyPages)",
+			counterHelp("Cache", perflibCache{}, "DirtyPages"),
 			nil,
 			nil,
 		),
 		DirtyPageThreshold: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dirty_page_threshold"),
-			"(DirtyPageThreshold)",
+			counterHelp("Cache", perflibCache{}, "DirtyPageThreshold"),
 			nil,
 			nil,
 		),
 		FastReadNotPossiblesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "fast_read_not_possibles_total"),
-			"(FastReadNotPossiblesTotal)",
+			counterHelp("Cache", perflibCache{}, "FastReadNotPossiblesTotal"),
 			nil,
 			nil,
 		),
 		FastReadResourceMissesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "fast_read_resource_misses_total"),
-			"(FastReadResourceMissesTotal)",
+			counterHelp("Cache", perflibCache{}, "FastReadResourceMissesTotal"),
 			nil,
 			nil,
 		),
 		FastReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "fast_reads_total"),
-			"(FastReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "FastReadsTotal"),
 			nil,
 			nil,
 		),
 		LazyWriteFlushesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "lazy_write_flushes_total"),
-			"(LazyWriteFlushesTotal)",
+			counterHelp("Cache", perflibCache{}, "LazyWriteFlushesTotal"),
 			nil,
 			nil,
 		),
 		LazyWritePagesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "lazy_write_pages_total"),
-			"(LazyWritePagesTotal)",
+			counterHelp("Cache", perflibCache{}, "LazyWritePagesTotal"),
 			nil,
 			nil,
 		),
 		MDLReadHitsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mdl_read_hits_total"),
-			"(MDLReadHitsTotal)",
+			counterHelp("Cache", perflibCache{}, "MDLReadHitsTotal"),
 			nil,
 			nil,
 		),
 		MDLReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mdl_reads_total"),
-			"(MDLReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "MDLReadsTotal"),
 			nil,
 			nil,
 		),
 		PinReadHitsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pin_read_hits_total"),
-			"(PinReadHitsTotal)",
+			counterHelp("Cache", perflibCache{}, "PinReadHitsTotal"),
 			nil,
 			nil,
 		),
 		PinReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pin_reads_total"),
-			"(PinReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "PinReadsTotal"),
 			nil,
 			nil,
 		),
 		ReadAheadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "read_aheads_total"),
-			"(ReadAheadsTotal)",
+			counterHelp("Cache", perflibCache{}, "ReadAheadsTotal"),
 			nil,
 			nil,
 		),
 		SyncCopyReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sync_copy_reads_total"),
-			"(SyncCopyReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "SyncCopyReadsTotal"),
 			nil,
 			nil,
 		),
 		SyncDataMapsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sync_data_maps_total"),
-			"(SyncDataMapsTotal)",
+			counterHelp("Cache", perflibCache{}, "SyncDataMapsTotal"),
 			nil,
 			nil,
 		),
 		SyncFastReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sync_fast_reads_total"),
-			"(SyncFastReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "SyncFastReadsTotal"),
 			nil,
 			nil,
 		),
 		SyncMDLReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sync_mdl_reads_total"),
-			"(SyncMDLReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "SyncMDLReadsTotal"),
 			nil,
 			nil,
 		),
 		SyncPinReadsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sync_pin_reads_total"),
-			"(SyncPinReadsTotal)",
+			counterHelp("Cache", perflibCache{}, "SyncPinReadsTotal"),
 			nil,
 			nil,
 		),
@@ -228,7 +230,7 @@ func newCacheCollector() (Collector, error) {
 // Collect implements the Collector interface
 func (c *CacheCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting cache metrics:", desc, err)
+		cacheLogger.Error("failed collecting cache metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -270,7 +272,7 @@ type perflibCache struct {
 
 func (c *CacheCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []perflibCache // Single-instance class, array is required but will have single entry.
-	if err := unmarshalObject(ctx.perfObjects["Cache"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["Cache"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,116 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var collectorCacheTTLs = kingpin.Flag(
+	"collectors.cache-ttls",
+	"Comma-separated list of collector=ttl pairs, e.g. mssql=1m, serving the metrics of a collector from a cache until they are older than the TTL, so that frequent or concurrent scrapes don't collect them again.",
+).Default("").String()
+
+// parseCacheTTLs parses a comma-separated list of collector=ttl pairs.
+func parseCacheTTLs(s string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid collector cache TTL %q, must be collector=ttl", pair)
+		}
+		ttl, err := time.ParseDuration(parts[1])
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid cache TTL %q of collector %s", parts[1], parts[0])
+		}
+		ttls[parts[0]] = ttl
+	}
+	return ttls, nil
+}
+
+// cachedMetrics holds the metrics of the last successful collection from a
+// host.
+type cachedMetrics struct {
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+	expires time.Time
+}
+
+// cachedCollector serves the metrics of a collector from a cache until they
+// are older than its TTL. Scrapes arriving while the collector refreshes the
+// cache wait for it and are served its metrics, rather than collecting again.
+// Failed collections aren't cached.
+type cachedCollector struct {
+	collector Collector
+	ttl       time.Duration
+
+	mu sync.Mutex
+	// hosts holds the cached metrics of each host the collector is used
+	// against, "" being the local host.
+	hosts map[string]*cachedMetrics
+}
+
+func newCachedCollector(c Collector, ttl time.Duration) *cachedCollector {
+	return &cachedCollector{
+		collector: c,
+		ttl:       ttl,
+		hosts:     make(map[string]*cachedMetrics),
+	}
+}
+
+// Close closes the cached collector.
+func (c *cachedCollector) Close() error {
+	return Close(c.collector)
+}
+
+func (c *cachedCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	cached, ok := c.hosts[ctx.host]
+	if !ok {
+		cached = &cachedMetrics{}
+		c.hosts[ctx.host] = cached
+	}
+	c.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if time.Now().Before(cached.expires) {
+		for _, m := range cached.metrics {
+			ch <- m
+		}
+		return nil
+	}
+
+	out := make(chan prometheus.Metric)
+	collected := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range out {
+			metrics = append(metrics, m)
+		}
+		collected <- metrics
+	}()
+	err := c.collector.Collect(ctx, out)
+	close(out)
+	metrics := <-collected
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	if err != nil {
+		return err
+	}
+	cached.metrics = metrics
+	cached.expires = time.Now().Add(c.ttl)
+	return nil
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// countingCollector sends the number of times it was collected.
+type countingCollector struct {
+	desc        *prometheus.Desc
+	collections float64
+	err         error
+}
+
+func (c *countingCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.collections++
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, c.collections)
+	return c.err
+}
+
+// closingCollector records whether it was closed.
+type closingCollector struct {
+	countingCollector
+	closed bool
+}
+
+func (c *closingCollector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCloseCachedCollector(t *testing.T) {
+	closing := &closingCollector{}
+	if err := Close(newCachedCollector(closing, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !closing.closed {
+		t.Error("Expected closing the cached collector to close the collector")
+	}
+	if err := Close(&countingCollector{}); err != nil {
+		t.Errorf("Expected closing a collector without resources to succeed, got %v", err)
+	}
+}
+
+func TestCachedCollector(t *testing.T) {
+	counting := &countingCollector{desc: prometheus.NewDesc("collections", "Collections.", nil, nil)}
+	c := newCachedCollector(counting, time.Hour)
+
+	collect := func(ctx *ScrapeContext) (float64, error) {
+		ch := make(chan prometheus.Metric, 1)
+		err := c.Collect(ctx, ch)
+		var m dto.Metric
+		if werr := (<-ch).Write(&m); werr != nil {
+			t.Fatal(werr)
+		}
+		return m.GetGauge().GetValue(), err
+	}
+
+	for _, host := range []string{"", "", "HV01", "HV01", ""} {
+		if _, err := collect(&ScrapeContext{host: host}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.collections != 2 {
+		t.Errorf("Expected 2 collections, one per host, got %v", counting.collections)
+	}
+
+	counting.err = errors.New("failed")
+	c.hosts[""].expires = time.Time{}
+	for i := 0; i < 2; i++ {
+		if _, err := collect(&ScrapeContext{}); err == nil {
+			t.Error("Expected the error of the collector")
+		}
+	}
+	if counting.collections != 4 {
+		t.Errorf("Expected failed collections not to be cached, got %v collections", counting.collections)
+	}
+
+	if ttls, err := parseCacheTTLs("mssql=1m, ad=30s,"); err != nil || len(ttls) != 2 || ttls["mssql"] != time.Minute {
+		t.Errorf("Unexpected cache TTLs %v (%v)", ttls, err)
+	}
+	for _, s := range []string{"mssql", "mssql=", "mssql=-1s"} {
+		if _, err := parseCacheTTLs(s); err == nil {
+			t.Errorf("parseCacheTTLs(%q) succeeded", s)
+		}
+	}
+}
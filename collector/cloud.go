@@ -0,0 +1,395 @@
+// +build windows
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("cloud", newCloudCollector)
+}
+
+var cloudLogger = log.With("collector", "cloud")
+
+var (
+	cloudProvider = kingpin.Flag(
+		"collector.cloud.provider",
+		"Cloud provider whose instance metadata service to query. \"auto\" tries all of them.",
+	).Default("auto").Enum("auto", "azure", "aws", "gcp")
+	cloudTimeout = kingpin.Flag(
+		"collector.cloud.timeout",
+		"Timeout of each request to the instance metadata service.",
+	).Default("1s").Duration()
+	cloudRefreshInterval = kingpin.Flag(
+		"collector.cloud.refresh-interval",
+		"How often to query the instance metadata service again.",
+	).Default("1h").Duration()
+	cloudScheduledEvents = kingpin.Flag(
+		"collector.cloud.scheduled-events",
+		"On Azure, query the scheduled maintenance events of the VM on every scrape. The first query enables scheduled events for the VM, so that maintenance waits for the not before time of its events.",
+	).Default("false").Bool()
+)
+
+// azureScheduledEventTypes are the types of Azure scheduled events, as in the
+// type label.
+var azureScheduledEventTypes = []string{
+	"freeze",
+	"reboot",
+	"redeploy",
+	"preempt",
+	"terminate",
+}
+
+// cloudMetadataAddress is the link-local address all supported instance
+// metadata services listen on.
+const cloudMetadataAddress = "http://169.254.169.254"
+
+type cloudInstance struct {
+	provider     string
+	instanceID   string
+	instanceType string
+	region       string
+	zone         string
+	// name is the name of Azure VMs, which their scheduled events refer
+	// to them by.
+	name string
+}
+
+type cloudMetadataFunc func(ctx context.Context, client *http.Client, base string) (cloudInstance, error)
+
+var cloudProviders = map[string]cloudMetadataFunc{
+	"azure": azureInstanceMetadata,
+	"aws":   awsInstanceMetadata,
+	"gcp":   gcpInstanceMetadata,
+}
+
+// A CloudCollector is a Prometheus collector for the instance metadata of
+// cloud virtual machines
+type CloudCollector struct {
+	Info                    *prometheus.Desc
+	ScheduledEvents         *prometheus.Desc
+	ScheduledEventNotBefore *prometheus.Desc
+
+	client *http.Client
+	base   string
+
+	mu        sync.Mutex
+	refreshed time.Time
+	instance  *cloudInstance
+}
+
+func newCloudCollector() (Collector, error) {
+	const subsystem = "cloud"
+
+	return &CloudCollector{
+		Info: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "instance_info"),
+			"A metric with a constant '1' value labeled with the cloud provider, instance ID, instance type, region and zone",
+			[]string{"provider", "instance_id", "instance_type", "region", "zone"},
+			nil,
+		),
+		ScheduledEvents: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "scheduled_events"),
+			"Maintenance events of the type scheduled or started for the VM",
+			[]string{"type"},
+			nil,
+		),
+		ScheduledEventNotBefore: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "scheduled_event_not_before_timestamp_seconds"),
+			"Time the scheduled event may start at, in seconds since epoch",
+			[]string{"event_id", "type", "source"},
+			nil,
+		),
+		// The metadata services must be reached directly, never via a proxy.
+		client: &http.Client{Transport: &http.Transport{Proxy: nil}},
+		base:   cloudMetadataAddress,
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *CloudCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		cloudLogger.Error("failed collecting cloud metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *CloudCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	instance, err := c.lookup()
+	if err != nil {
+		return nil, err
+	}
+	ch <- newInfoMetric(
+		c.Info,
+		instance.provider,
+		instance.instanceID,
+		instance.instanceType,
+		instance.region,
+		instance.zone,
+	)
+
+	if flagBool(cloudScheduledEvents) && instance.provider == "azure" {
+		ctx, cancel := context.WithTimeout(context.Background(), flagDuration(cloudTimeout))
+		defer cancel()
+		events, err := azureScheduledEvents(ctx, c.client, c.base, instance.name)
+		if err != nil {
+			return c.ScheduledEvents, err
+		}
+
+		counts := make(map[string]float64, len(azureScheduledEventTypes))
+		for _, t := range azureScheduledEventTypes {
+			counts[t] = 0
+		}
+		for _, e := range events {
+			counts[e.eventType]++
+			// Started events have no not before time.
+			if e.notBefore.IsZero() {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.ScheduledEventNotBefore,
+				prometheus.GaugeValue,
+				float64(e.notBefore.Unix()),
+				e.id,
+				e.eventType,
+				e.source,
+			)
+		}
+		for t, count := range counts {
+			ch <- prometheus.MustNewConstMetric(
+				c.ScheduledEvents,
+				prometheus.GaugeValue,
+				count,
+				t,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// lookup returns the cached instance metadata, querying the metadata service
+// if it is older than --collector.cloud.refresh-interval.
+func (c *CloudCollector) lookup() (cloudInstance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.instance != nil && time.Since(c.refreshed) < flagDuration(cloudRefreshInterval) {
+		return *c.instance, nil
+	}
+
+	providers := []string{flagString(cloudProvider)}
+	if providers[0] == "auto" {
+		providers = []string{"azure", "aws", "gcp"}
+	}
+	var errs []string
+	for _, name := range providers {
+		ctx, cancel := context.WithTimeout(context.Background(), flagDuration(cloudTimeout))
+		instance, err := cloudProviders[name](ctx, c.client, c.base)
+		cancel()
+		if err != nil {
+			cloudLogger.Debugf("Failed to query %s instance metadata: %v", name, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		c.instance = &instance
+		c.refreshed = time.Now()
+		return instance, nil
+	}
+	return cloudInstance{}, fmt.Errorf("failed to query instance metadata: %s", strings.Join(errs, "; "))
+}
+
+// cloudGetJSON sends req and decodes its JSON response into dst.
+func cloudGetJSON(client *http.Client, req *http.Request, dst interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// azureInstanceMetadata queries the Azure Instance Metadata Service.
+// https://docs.microsoft.com/en-us/azure/virtual-machines/windows/instance-metadata-service
+func azureInstanceMetadata(ctx context.Context, client *http.Client, base string) (cloudInstance, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/metadata/instance/compute?api-version=2021-02-01", nil)
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	var compute struct {
+		VMID     string `json:"vmId"`
+		Name     string `json:"name"`
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+	}
+	if err := cloudGetJSON(client, req, &compute); err != nil {
+		return cloudInstance{}, err
+	}
+	if compute.VMID == "" {
+		return cloudInstance{}, errors.New("response has no vmId")
+	}
+	return cloudInstance{
+		provider:     "azure",
+		instanceID:   compute.VMID,
+		instanceType: compute.VMSize,
+		region:       compute.Location,
+		zone:         compute.Zone,
+		name:         compute.Name,
+	}, nil
+}
+
+type azureScheduledEvent struct {
+	id        string
+	eventType string
+	source    string
+	// notBefore is zero once the event started.
+	notBefore time.Time
+}
+
+// azureScheduledEvents returns the scheduled events of the Azure VM of the
+// given name, leaving out those of other VMs of its availability set.
+// https://docs.microsoft.com/en-us/azure/virtual-machines/windows/scheduled-events
+func azureScheduledEvents(ctx context.Context, client *http.Client, base string, name string) ([]azureScheduledEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/metadata/scheduledevents?api-version=2020-07-01", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	var document struct {
+		Events []struct {
+			EventID     string   `json:"EventId"`
+			EventType   string   `json:"EventType"`
+			Resources   []string `json:"Resources"`
+			NotBefore   string   `json:"NotBefore"`
+			EventSource string   `json:"EventSource"`
+		} `json:"Events"`
+	}
+	if err := cloudGetJSON(client, req, &document); err != nil {
+		return nil, err
+	}
+
+	var events []azureScheduledEvent
+	for _, e := range document.Events {
+		if !find(e.Resources, name) {
+			continue
+		}
+		event := azureScheduledEvent{
+			id:        e.EventID,
+			eventType: strings.ToLower(e.EventType),
+			source:    strings.ToLower(e.EventSource),
+		}
+		if e.NotBefore != "" {
+			event.notBefore, err = http.ParseTime(e.NotBefore)
+			if err != nil {
+				return nil, fmt.Errorf("invalid NotBefore of event %s: %v", e.EventID, err)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// awsInstanceMetadata queries the EC2 instance metadata service, using an
+// IMDSv2 session token so that instances requiring it are supported.
+// https://docs.aws.amazon.com/AWSEC2/latest/WindowsGuide/configuring-instance-metadata-service.html
+func awsInstanceMetadata(ctx context.Context, client *http.Client, base string) (cloudInstance, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+"/latest/api/token", nil)
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := client.Do(req)
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cloudInstance{}, fmt.Errorf("%s returned %s", req.URL.Path, resp.Status)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, base+"/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+
+	var document struct {
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	if err := cloudGetJSON(client, req, &document); err != nil {
+		return cloudInstance{}, err
+	}
+	if document.InstanceID == "" {
+		return cloudInstance{}, errors.New("response has no instanceId")
+	}
+	return cloudInstance{
+		provider:     "aws",
+		instanceID:   document.InstanceID,
+		instanceType: document.InstanceType,
+		region:       document.Region,
+		zone:         document.AvailabilityZone,
+	}, nil
+}
+
+// gcpInstanceMetadata queries the Compute Engine metadata server.
+// https://cloud.google.com/compute/docs/metadata/overview
+func gcpInstanceMetadata(ctx context.Context, client *http.Client, base string) (cloudInstance, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/computeMetadata/v1/instance/?recursive=true", nil)
+	if err != nil {
+		return cloudInstance{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var instance struct {
+		ID          json.Number `json:"id"`
+		MachineType string      `json:"machineType"`
+		Zone        string      `json:"zone"`
+	}
+	if err := cloudGetJSON(client, req, &instance); err != nil {
+		return cloudInstance{}, err
+	}
+	if instance.ID == "" {
+		return cloudInstance{}, errors.New("response has no id")
+	}
+	// The machine type and zone are given as paths, e.g.
+	// projects/123/zones/europe-west1-b.
+	zone := instance.Zone[strings.LastIndex(instance.Zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return cloudInstance{
+		provider:     "gcp",
+		instanceID:   instance.ID.String(),
+		instanceType: instance.MachineType[strings.LastIndex(instance.MachineType, "/")+1:],
+		region:       region,
+		zone:         zone,
+	}, nil
+}
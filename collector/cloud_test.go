@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCloudInstanceMetadata(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metadata/instance/compute", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"vmId":"02aab8a4-74ef-476e-8182-f6d2ba4166a6","name":"vm01","vmSize":"Standard_D2s_v3","location":"westeurope","zone":"1"}`))
+	})
+	mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		_, _ = w.Write([]byte("token"))
+	})
+	mux.HandleFunc("/latest/dynamic/instance-identity/document", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"instanceId":"i-1234567890abcdef0","instanceType":"m5.large","region":"eu-west-1","availabilityZone":"eu-west-1a"}`))
+	})
+	mux.HandleFunc("/computeMetadata/v1/instance/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":4520031799277581759,"machineType":"projects/123/machineTypes/n2-standard-4","zone":"projects/123/zones/europe-west1-b"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	expected := map[string]cloudInstance{
+		"azure": {"azure", "02aab8a4-74ef-476e-8182-f6d2ba4166a6", "Standard_D2s_v3", "westeurope", "1", "vm01"},
+		"aws":   {"aws", "i-1234567890abcdef0", "m5.large", "eu-west-1", "eu-west-1a", ""},
+		"gcp":   {"gcp", "4520031799277581759", "n2-standard-4", "europe-west1", "europe-west1-b", ""},
+	}
+	for name, fn := range cloudProviders {
+		instance, err := fn(context.Background(), server.Client(), server.URL)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if instance != expected[name] {
+			t.Errorf("%s: expected %+v, got %+v", name, expected[name], instance)
+		}
+	}
+}
+
+func TestAzureScheduledEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/scheduledevents" || r.Header.Get("Metadata") != "true" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"DocumentIncarnation":2,"Events":[
+			{"EventId":"C7061BAC-AFDC-4513-B24B-AA5F13A16123","EventStatus":"Scheduled","EventType":"Freeze","ResourceType":"VirtualMachine","Resources":["vm01","vm02"],"NotBefore":"Mon, 11 Apr 2022 22:26:58 GMT","EventSource":"Platform","DurationInSeconds":5},
+			{"EventId":"4F34D4B2-ED83-4B3C-A4B1-0B2C5E1E1B2E","EventStatus":"Started","EventType":"Reboot","ResourceType":"VirtualMachine","Resources":["vm01"],"NotBefore":"","EventSource":"User","DurationInSeconds":-1},
+			{"EventId":"0C8A3D5E-3A2B-4D57-9E4B-5F0C7A1B2C3D","EventStatus":"Scheduled","EventType":"Redeploy","ResourceType":"VirtualMachine","Resources":["vm02"],"NotBefore":"Mon, 11 Apr 2022 23:00:00 GMT","EventSource":"Platform","DurationInSeconds":-1}
+		]}`))
+	}))
+	defer server.Close()
+
+	events, err := azureScheduledEvents(context.Background(), server.Client(), server.URL, "vm01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []azureScheduledEvent{
+		{"C7061BAC-AFDC-4513-B24B-AA5F13A16123", "freeze", "platform", time.Date(2022, 4, 11, 22, 26, 58, 0, time.UTC)},
+		{"4F34D4B2-ED83-4B3C-A4B1-0B2C5E1E1B2E", "reboot", "user", time.Time{}},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Scheduled events do not match!\nExpected result: %+v\nActual result: %+v", expected, events)
+	}
+}
+
+func BenchmarkCloudCollector(b *testing.B) {
+	benchmarkCollector(b, "cloud", newCloudCollector)
+}
@@ -0,0 +1,131 @@
+// +build windows
+
+package collector
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	clusterOwnershipAll   = "all"
+	clusterOwnershipOwner = "owner"
+	clusterOwnershipLabel = "label"
+
+	// clusterResourceRefresh bounds how long a failover goes unnoticed.
+	clusterResourceRefresh = 15 * time.Second
+)
+
+var clusterOwnership = kingpin.Flag(
+	"collectors.cluster-ownership",
+	"How collectors handle resources of failover cluster roles. \"all\" collects them on every node, \"owner\" only on the node currently owning the resource, \"label\" on every node with an owner_node label.",
+).Default(clusterOwnershipAll).Enum(clusterOwnershipAll, clusterOwnershipOwner, clusterOwnershipLabel)
+
+// MSCluster_Resource docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/cluswmi/mscluster-resource
+type MSCluster_Resource struct {
+	Name      string
+	Type      string
+	OwnerNode string
+}
+
+// clusterResources caches the owner nodes of the resources of the failover
+// cluster the local computer is a node of.
+type clusterResources struct {
+	mu        sync.Mutex
+	refreshed time.Time
+	owners    map[string]string
+}
+
+var localClusterResources = &clusterResources{}
+
+// owner returns the node currently owning the resource of the given type and
+// name, and whether such a resource exists. It never reports a resource on
+// computers that aren't cluster nodes.
+func (r *clusterResources) owner(resourceType, name string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.owners == nil || time.Since(r.refreshed) > clusterResourceRefresh {
+		var dst []MSCluster_Resource
+		owners := make(map[string]string)
+		if err := queryWMINamespace("SELECT Name, Type, OwnerNode FROM MSCluster_Resource", &dst, "root\\MSCluster"); err != nil {
+			log.Debugf("Not treating any resource as clustered, failed to query cluster resources: %v", err)
+		}
+		for _, res := range dst {
+			owners[strings.ToLower(res.Type+"\x00"+res.Name)] = res.OwnerNode
+		}
+		r.owners = owners
+		r.refreshed = time.Now()
+	}
+
+	node, ok := r.owners[strings.ToLower(resourceType+"\x00"+name)]
+	return node, ok
+}
+
+// isLocalClusterNode reports whether node names the local computer.
+func isLocalClusterNode(node string) bool {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.SplitN(hostname, ".", 2)[0], node)
+}
+
+// clusterOwnerFilter applies --collectors.cluster-ownership to a resource of
+// the given type and name. It returns false if the resource must not be
+// collected on this node. Otherwise it returns the channel to send its
+// metrics to, and a function to call once all were sent.
+func clusterOwnerFilter(ch chan<- prometheus.Metric, resourceType, name string) (chan<- prometheus.Metric, func(), bool) {
+	if *clusterOwnership == clusterOwnershipAll {
+		return ch, func() {}, true
+	}
+	node, clustered := localClusterResources.owner(resourceType, name)
+	if !clustered {
+		return ch, func() {}, true
+	}
+	if *clusterOwnership == clusterOwnershipOwner {
+		if !isLocalClusterNode(node) {
+			log.Debugf("Skipping cluster resource %q owned by %s", name, node)
+			return nil, nil, false
+		}
+		return ch, func() {}, true
+	}
+
+	labeled := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range labeled {
+			ch <- ownerNodeMetric{Metric: m, node: node}
+		}
+		close(done)
+	}()
+	return labeled, func() {
+		close(labeled)
+		<-done
+	}, true
+}
+
+// ownerNodeMetric adds the owner_node label to a metric.
+type ownerNodeMetric struct {
+	prometheus.Metric
+	node string
+}
+
+func (m ownerNodeMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	name, value := "owner_node", m.node
+	out.Label = append(out.Label, &dto.LabelPair{Name: &name, Value: &value})
+	sort.Slice(out.Label, func(i, j int) bool { return out.Label[i].GetName() < out.Label[j].GetName() })
+	return nil
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestOwnerNodeMetric(t *testing.T) {
+	desc := prometheus.NewDesc("windows_test", "test", []string{"mssql_instance", "replica"}, nil)
+	m := ownerNodeMetric{
+		Metric: prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "SQL01", "node2"),
+		node:   "NODE1",
+	}
+
+	var out dto.Metric
+	if err := m.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"mssql_instance=SQL01", "owner_node=NODE1", "replica=node2"}
+	if len(out.Label) != len(expected) {
+		t.Fatalf("expected %d labels, got %v", len(expected), out.Label)
+	}
+	for i, lp := range out.Label {
+		if got := lp.GetName() + "=" + lp.GetValue(); got != expected[i] {
+			t.Errorf("label %d: expected %s, got %s", i, expected[i], got)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package collector
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/StackExchange/wmi"
 	"github.com/leoluk/perflib_exporter/perflib"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sys/windows/registry"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 // ...
@@ -50,16 +57,53 @@ func getWindowsVersion() float64 {
 	return currentv_flt
 }
 
+// queryWMI and queryWMINamespace are replaced when replaying recorded WMI
+// responses in tests.
+var (
+	queryWMI          = wmi.Query
+	queryWMINamespace = wmi.QueryNamespace
+)
+
+// queryWMIContext runs a WMI query, giving up when ctx is done. WMI queries
+// can't be cancelled, so an abandoned query runs to completion in the
+// background, and its result is discarded.
+func queryWMIContext(ctx context.Context, query string, dst interface{}, connectServerArgs ...interface{}) error {
+	result := reflect.New(reflect.TypeOf(dst).Elem())
+	done := make(chan error, 1)
+	go func() {
+		done <- queryWMI(query, result.Interface(), connectServerArgs...)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			reflect.ValueOf(dst).Elem().Set(result.Elem())
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 type collectorBuilder func() (Collector, error)
 
 var (
 	builders                = make(map[string]collectorBuilder)
 	perfCounterDependencies = make(map[string]string)
+	perfCounterSetNames     = make(map[string][]string)
 )
 
 func registerCollector(name string, builder collectorBuilder, perfCounterNames ...string) {
 	builders[name] = builder
 	addPerfCounterDependencies(name, perfCounterNames)
+
+	// The flag applies to the messages logged with a collector field of
+	// the name, see log.With.
+	log.AddCollectorLevelFlag(
+		kingpin.CommandLine,
+		"log.level."+name,
+		fmt.Sprintf("Only log messages of the %s collector with the given severity or above, overriding --log.level.", name),
+		name,
+	)
 }
 
 func addPerfCounterDependencies(name string, perfCounterNames []string) {
@@ -68,6 +112,7 @@ func addPerfCounterDependencies(name string, perfCounterNames []string) {
 		perfIndicies = append(perfIndicies, MapCounterToIndex(cn))
 	}
 	perfCounterDependencies[name] = strings.Join(perfIndicies, " ")
+	perfCounterSetNames[name] = perfCounterNames
 }
 
 func Available() []string {
@@ -82,7 +127,65 @@ func Build(collector string) (Collector, error) {
 	if !exists {
 		return nil, fmt.Errorf("Unknown collector %q", collector)
 	}
-	return builder()
+	ttls, err := parseCacheTTLs(*collectorCacheTTLs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collectors.cache-ttls: %v", err)
+	}
+	c, err := builder()
+	if err != nil {
+		return nil, err
+	}
+	if ttl, ok := ttls[collector]; ok {
+		return newCachedCollector(c, ttl), nil
+	}
+	return c, nil
+}
+
+// Close releases the resources held by a collector, e.g. its ETW sessions and
+// background goroutines, if it implements io.Closer. The collector mustn't be
+// used afterwards.
+func Close(c Collector) error {
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// flagsMu guards the values of the collector flags, which configuration
+// reloads change while collectors run. Builders read flags directly, as
+// collectors are only built by the reload that changed them, and collectors
+// read them with flagString and the like once built.
+var flagsMu sync.RWMutex
+
+// SetFlag sets the value of a collector flag.
+func SetFlag(f *kingpin.FlagClause, value string) error {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	return f.Model().Value.Set(value)
+}
+
+func flagString(p *string) string {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return *p
+}
+
+func flagBool(p *bool) bool {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return *p
+}
+
+func flagInt(p *int) int {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return *p
+}
+
+func flagDuration(p *time.Duration) time.Duration {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return *p
 }
 func getPerfQuery(collectors []string) string {
 	parts := make([]string, 0, len(collectors))
@@ -94,6 +197,14 @@ func getPerfQuery(collectors []string) string {
 	return strings.Join(parts, " ")
 }
 
+func getPerfCounterSetNames(collectors []string) []string {
+	names := make([]string, 0, len(collectors))
+	for _, c := range collectors {
+		names = append(names, perfCounterSetNames[c]...)
+	}
+	return names
+}
+
 // Collector is the interface a collector has to implement.
 type Collector interface {
 	// Get new metrics and expose them via prometheus registry.
@@ -102,18 +213,206 @@ type Collector interface {
 
 type ScrapeContext struct {
 	perfObjects map[string]*perflib.PerfObject
+	// sampleTimes holds the time the performance counters of each collector
+	// were sampled.
+	sampleTimes map[string]time.Time
+	// ctx carries the deadline of the collector.
+	ctx context.Context
+	// host is the remote host scraped, empty for the local host.
+	host string
+	// collectorLogger is the logger of the collector, nil for the standard
+	// logger.
+	collectorLogger log.Logger
+}
+
+// Context returns the context of the scrape, which is cancelled when the
+// collector's deadline expires. Collectors running slow operations should stop
+// when it's done; the metrics they send afterwards are discarded.
+func (ctx *ScrapeContext) Context() context.Context {
+	if ctx.ctx == nil {
+		return context.Background()
+	}
+	return ctx.ctx
+}
+
+// WithContext returns a copy of the ScrapeContext carrying c.
+func (ctx *ScrapeContext) WithContext(c context.Context) *ScrapeContext {
+	scrapeContext := *ctx
+	scrapeContext.ctx = c
+	return &scrapeContext
+}
+
+// WithLogger returns a copy of the ScrapeContext carrying the logger of the
+// collector, which the helpers run on its behalf log to.
+func (ctx *ScrapeContext) WithLogger(l log.Logger) *ScrapeContext {
+	scrapeContext := *ctx
+	scrapeContext.collectorLogger = l
+	return &scrapeContext
+}
+
+// logger returns the logger of the collector.
+func (ctx *ScrapeContext) logger() log.Logger {
+	if ctx.collectorLogger == nil {
+		return log.Base()
+	}
+	return ctx.collectorLogger
+}
+
+// SampleTime returns the time the performance counters read by the collector
+// were sampled. Rates computed from the counters are only exact when divided
+// by the time between samples, rather than between scrapes. ok is false for
+// collectors not reading performance counters.
+func (ctx *ScrapeContext) SampleTime(collector string) (t time.Time, ok bool) {
+	t, ok = ctx.sampleTimes[collector]
+	return t, ok
+}
+
+// setSampleTime records the sample time of the collectors reading performance
+// counters.
+func (ctx *ScrapeContext) setSampleTime(collectors []string, t time.Time) {
+	for _, c := range collectors {
+		if len(perfCounterSetNames[c]) > 0 {
+			ctx.sampleTimes[c] = t
+		}
+	}
 }
 
 // PrepareScrapeContext creates a ScrapeContext to be used during a single scrape
 func PrepareScrapeContext(collectors []string) (*ScrapeContext, error) {
-	q := getPerfQuery(collectors) // TODO: Memoize
-	objs, err := getPerflibSnapshot(q)
+	v1Collectors := make([]string, 0, len(collectors))
+	v2Collectors := make([]string, 0)
+	for _, c := range collectors {
+		if usePerflibV2(c) {
+			v2Collectors = append(v2Collectors, c)
+		} else {
+			v1Collectors = append(v1Collectors, c)
+		}
+	}
+
+	ctx := &ScrapeContext{
+		perfObjects: make(map[string]*perflib.PerfObject),
+		sampleTimes: make(map[string]time.Time),
+	}
+	// Collectors not reading performance counters, e.g. those reading WMI,
+	// need no query.
+	if q := getPerfQuery(v1Collectors); q != "" { // TODO: Memoize
+		// The V1 backend doesn't return the sample time, so it's taken as
+		// the middle of the query.
+		start := time.Now()
+		objs, err := getPerflibSnapshot(q)
+		if err != nil {
+			return nil, err
+		}
+		ctx.perfObjects = objs
+		ctx.setSampleTime(v1Collectors, start.Add(time.Since(start)/2))
+	}
+
+	if len(v2Collectors) > 0 {
+		v2Objs, sampleTime, err := getPerflibV2Snapshot("", getPerfCounterSetNames(v2Collectors))
+		if err != nil {
+			return nil, err
+		}
+		for name, obj := range v2Objs {
+			ctx.perfObjects[name] = obj
+		}
+		ctx.setSampleTime(v2Collectors, sampleTime)
+	}
+
+	return ctx, nil
+}
+
+// MissingPerfObjects returns the performance objects read by the collectors
+// that aren't found on the local host, indexed by collector.
+func MissingPerfObjects(collectors []string) (map[string][]string, error) {
+	ctx, err := PrepareScrapeContext(collectors)
 	if err != nil {
 		return nil, err
 	}
+	missing := make(map[string][]string)
+	for _, c := range collectors {
+		for _, name := range perfCounterSetNames[c] {
+			if ctx.perfObjects[name] == nil {
+				missing[c] = append(missing[c], name)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// PrepareRemoteScrapeContext creates a ScrapeContext holding the performance counters
+// of a remote host. Remote counters are read with the PerfLib V2 backend, falling
+// back to the remote registry if it isn't reachable. It gives up once c is done,
+// the snapshot then completes in the background and is discarded.
+func PrepareRemoteScrapeContext(c context.Context, host string, collectors []string) (*ScrapeContext, error) {
+	type snapshot struct {
+		objs       map[string]*perflib.PerfObject
+		sampleTime time.Time
+		err        error
+	}
+	done := make(chan snapshot, 1)
+	go func() {
+		var s snapshot
+		s.objs, s.sampleTime, s.err = getRemoteSnapshot(host, getPerfCounterSetNames(collectors))
+		done <- s
+	}()
+
+	var s snapshot
+	select {
+	case s = <-done:
+	case <-c.Done():
+		return nil, fmt.Errorf("reading the performance counters of %s: %v", host, c.Err())
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
 
-	return &ScrapeContext{objs}, nil
+	ctx := &ScrapeContext{
+		perfObjects: s.objs,
+		sampleTimes: make(map[string]time.Time),
+		ctx:         c,
+		host:        host,
+	}
+	ctx.setSampleTime(collectors, s.sampleTime)
+	return ctx, nil
+}
+
+// localOnlyCollectors read performance counters, but combine them with data
+// from local APIs or WMI and would produce wrong results for remote hosts.
+var localOnlyCollectors = map[string]bool{
+	"ad":                true,
+	"mscluster":         true,
+	"os":                true,
+	"process":           true,
+	"s2d":               true,
+	"sysmain":           true,
+	"terminal_services": true,
+}
+
+// SupportsRemote reports whether a collector reads all of its data from
+// performance counters, and can therefore be used against remote hosts.
+func SupportsRemote(collector string) bool {
+	return len(perfCounterSetNames[collector]) > 0 && !localOnlyCollectors[collector]
 }
+
+// newInfoDesc returns the descriptor of a windows_<subsystem>_info metric.
+// Static attributes of an object belong on its info metric rather than on its
+// numeric series, which only carry the key labels. Attributes can then change
+// without creating new numeric series, and are joined in when needed, e.g.
+// windows_service_state * on(name) group_left(run_as) windows_service_info
+func newInfoDesc(subsystem string, help string, keyLabels []string, attributeLabels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, subsystem, "info"),
+		help,
+		append(append([]string{}, keyLabels...), attributeLabels...),
+		nil,
+	)
+}
+
+// newInfoMetric returns an info metric, which always has the value 1.
+func newInfoMetric(desc *prometheus.Desc, labelValues ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1.0, labelValues...)
+}
+
 func boolToFloat(b bool) float64 {
 	if b {
 		return 1.0
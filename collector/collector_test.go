@@ -54,6 +54,8 @@ func benchmarkCollector(b *testing.B, name string, collectFunc func() (Collector
 		}
 	}()
 
+	b.ReportAllocs()
+	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		c.Collect(scrapeContext, metrics)
 	}
@@ -12,6 +12,8 @@ func init() {
 	registerCollector("container", NewContainerMetricsCollector)
 }
 
+var containerLogger = log.With("collector", "container")
+
 // A ContainerMetricsCollector is a Prometheus collector for containers metrics
 type ContainerMetricsCollector struct {
 	// Presence
@@ -133,7 +135,7 @@ func NewContainerMetricsCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *ContainerMetricsCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting ContainerMetricsCollector metrics:", desc, err)
+		containerLogger.Error("failed collecting ContainerMetricsCollector metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -143,7 +145,7 @@ func (c *ContainerMetricsCollector) Collect(ctx *ScrapeContext, ch chan<- promet
 func containerClose(c hcsshim.Container) {
 	err := c.Close()
 	if err != nil {
-		log.Error(err)
+		containerLogger.Error(err)
 	}
 }
 
@@ -152,7 +154,7 @@ func (c *ContainerMetricsCollector) collect(ch chan<- prometheus.Metric) (*prome
 	// Types Container is passed to get the containers compute systems only
 	containers, err := hcsshim.GetContainers(hcsshim.ComputeSystemQuery{Types: []string{"Container"}})
 	if err != nil {
-		log.Error("Err in Getting containers:", err)
+		containerLogger.Error("Err in Getting containers:", err)
 		return nil, err
 	}
 
@@ -173,13 +175,13 @@ func (c *ContainerMetricsCollector) collect(ch chan<- prometheus.Metric) (*prome
 			defer containerClose(container)
 		}
 		if err != nil {
-			log.Error("err in opening container: ", containerDetails.ID, err)
+			containerLogger.Error("err in opening container: ", containerDetails.ID, err)
 			continue
 		}
 
 		cstats, err := container.Statistics()
 		if err != nil {
-			log.Error("err in fetching container Statistics: ", containerDetails.ID, err)
+			containerLogger.Error("err in fetching container Statistics: ", containerDetails.ID, err)
 			continue
 		}
 		containerIdWithPrefix := getContainerIdWithPrefix(containerDetails)
@@ -228,7 +230,7 @@ func (c *ContainerMetricsCollector) collect(ch chan<- prometheus.Metric) (*prome
 		)
 
 		if len(cstats.Network) == 0 {
-			log.Info("No Network Stats for container: ", containerDetails.ID)
+			containerLogger.Info("No Network Stats for container: ", containerDetails.ID)
 			continue
 		}
 
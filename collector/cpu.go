@@ -157,7 +157,7 @@ type perflibProcessor struct {
 
 func (c *cpuCollectorBasic) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	data := make([]perflibProcessor, 0)
-	err := unmarshalObject(ctx.perfObjects["Processor"], &data)
+	err := unmarshalObject(ctx.perfObjects["Processor"], &data, ctx.logger())
 	if err != nil {
 		return err
 	}
@@ -264,7 +264,7 @@ type perflibProcessorInformation struct {
 
 func (c *cpuCollectorFull) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	data := make([]perflibProcessorInformation, 0)
-	err := unmarshalObject(ctx.perfObjects["Processor Information"], &data)
+	err := unmarshalObject(ctx.perfObjects["Processor Information"], &data, ctx.logger())
 	if err != nil {
 		return err
 	}
@@ -13,6 +13,8 @@ func init() {
 	registerCollector("cs", NewCSCollector)
 }
 
+var csLogger = log.With("collector", "cs")
+
 // A CSCollector is a Prometheus collector for WMI metrics
 type CSCollector struct {
 	PhysicalMemoryBytes *prometheus.Desc
@@ -53,7 +55,7 @@ func NewCSCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *CSCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting cs metrics:", desc, err)
+		csLogger.Error("failed collecting cs metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -0,0 +1,266 @@
+// +build windows
+
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("defender", newDefenderCollector)
+}
+
+var defenderLogger = log.With("collector", "defender")
+
+const defenderNamespace = "root/Microsoft/Windows/Defender"
+
+// defenderThreatStatuses are the statuses of threat detections, failed ones
+// being grouped.
+var defenderThreatStatuses = []string{
+	"detected",
+	"cleaned",
+	"quarantined",
+	"removed",
+	"allowed",
+	"blocked",
+	"failed",
+	"unknown",
+}
+
+// A DefenderCollector is a Prometheus collector for the state of Microsoft
+// Defender Antivirus: its protections, signatures, scans and the threats it
+// detected
+type DefenderCollector struct {
+	Info                 *prometheus.Desc
+	ProtectionEnabled    *prometheus.Desc
+	TamperProtected      *prometheus.Desc
+	SignatureLastUpdated *prometheus.Desc
+	LastScan             *prometheus.Desc
+	ThreatDetections     *prometheus.Desc
+	LastThreatDetection  *prometheus.Desc
+}
+
+func newDefenderCollector() (Collector, error) {
+	const subsystem = "defender"
+
+	return &DefenderCollector{
+		Info: newInfoDesc(
+			subsystem,
+			"Versions of the Defender Antivirus client, engine and signatures",
+			nil,
+			"product_version", "engine_version", "antivirus_signature_version",
+		),
+		ProtectionEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "protection_enabled"),
+			"Whether the protection is enabled (1) or not (0)",
+			[]string{"protection"},
+			nil,
+		),
+		TamperProtected: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "tamper_protected"),
+			"Whether the settings of Defender Antivirus are protected from changes (1) or not (0)",
+			nil,
+			nil,
+		),
+		SignatureLastUpdated: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "signature_last_updated_timestamp_seconds"),
+			"Time the signatures were last updated, in seconds since epoch",
+			[]string{"signature"},
+			nil,
+		),
+		LastScan: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_scan_timestamp_seconds"),
+			"Time the last scan of the type ended, in seconds since epoch",
+			[]string{"type"},
+			nil,
+		),
+		ThreatDetections: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "threat_detections"),
+			"Threat detections of the status in the detection history of Defender Antivirus",
+			[]string{"status"},
+			nil,
+		),
+		LastThreatDetection: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_threat_detection_timestamp_seconds"),
+			"Time of the latest threat detection in the detection history, in seconds since epoch",
+			nil,
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *DefenderCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectStatus(ctx, ch); err != nil {
+		defenderLogger.Error("failed collecting defender status metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectThreatDetections(ctx, ch); err != nil {
+		defenderLogger.Error("failed collecting defender threat detection metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// MSFT_MpComputerStatus docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/defender/msft-mpcomputerstatus
+type MSFT_MpComputerStatus struct {
+	AMProductVersion          string
+	AMEngineVersion           string
+	AntivirusSignatureVersion string
+
+	AMServiceEnabled          bool
+	AntispywareEnabled        bool
+	AntivirusEnabled          bool
+	BehaviorMonitorEnabled    bool
+	IoavProtectionEnabled     bool
+	NISEnabled                bool
+	OnAccessProtectionEnabled bool
+	RealTimeProtectionEnabled bool
+	IsTamperProtected         bool
+
+	AntispywareSignatureLastUpdated *time.Time
+	AntivirusSignatureLastUpdated   *time.Time
+	NISSignatureLastUpdated         *time.Time
+	FullScanEndTime                 *time.Time
+	QuickScanEndTime                *time.Time
+}
+
+func (c *DefenderCollector) collectStatus(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []MSFT_MpComputerStatus
+	q := queryAll(&dst)
+	if err := queryWMIContext(ctx.Context(), q, &dst, nil, defenderNamespace); err != nil {
+		return nil, err
+	}
+	if len(dst) == 0 {
+		return nil, nil
+	}
+	status := dst[0]
+
+	ch <- newInfoMetric(
+		c.Info,
+		status.AMProductVersion,
+		status.AMEngineVersion,
+		status.AntivirusSignatureVersion,
+	)
+
+	for _, p := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"antimalware_service", status.AMServiceEnabled},
+		{"antispyware", status.AntispywareEnabled},
+		{"antivirus", status.AntivirusEnabled},
+		{"behavior_monitor", status.BehaviorMonitorEnabled},
+		{"ioav", status.IoavProtectionEnabled},
+		{"network_inspection", status.NISEnabled},
+		{"on_access", status.OnAccessProtectionEnabled},
+		{"real_time", status.RealTimeProtectionEnabled},
+	} {
+		ch <- prometheus.MustNewConstMetric(
+			c.ProtectionEnabled,
+			prometheus.GaugeValue,
+			boolToFloat(p.enabled),
+			p.name,
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.TamperProtected,
+		prometheus.GaugeValue,
+		boolToFloat(status.IsTamperProtected),
+	)
+
+	// The times are null if the signatures were never updated, or no scan
+	// of the type ever ran.
+	for _, s := range []struct {
+		name    string
+		updated *time.Time
+	}{
+		{"antispyware", status.AntispywareSignatureLastUpdated},
+		{"antivirus", status.AntivirusSignatureLastUpdated},
+		{"network_inspection", status.NISSignatureLastUpdated},
+	} {
+		if s.updated == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.SignatureLastUpdated,
+			prometheus.GaugeValue,
+			float64(s.updated.Unix()),
+			s.name,
+		)
+	}
+	if status.FullScanEndTime != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastScan,
+			prometheus.GaugeValue,
+			float64(status.FullScanEndTime.Unix()),
+			"full",
+		)
+	}
+	if status.QuickScanEndTime != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastScan,
+			prometheus.GaugeValue,
+			float64(status.QuickScanEndTime.Unix()),
+			"quick",
+		)
+	}
+	return nil, nil
+}
+
+// MSFT_MpThreatDetection docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/defender/msft-mpthreatdetection
+type MSFT_MpThreatDetection struct {
+	ThreatStatusID       uint8
+	InitialDetectionTime *time.Time
+}
+
+// defenderThreatStatus returns the status of a ThreatStatusID.
+func defenderThreatStatus(id uint8) string {
+	switch {
+	case id >= 1 && id <= 6:
+		return defenderThreatStatuses[id-1]
+	case id >= 102 && id <= 107:
+		return "failed"
+	}
+	return "unknown"
+}
+
+func (c *DefenderCollector) collectThreatDetections(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []MSFT_MpThreatDetection
+	q := queryAll(&dst)
+	if err := queryWMIContext(ctx.Context(), q, &dst, nil, defenderNamespace); err != nil {
+		return nil, err
+	}
+
+	detections := make(map[string]float64)
+	var last time.Time
+	for _, d := range dst {
+		detections[defenderThreatStatus(d.ThreatStatusID)]++
+		if d.InitialDetectionTime != nil && d.InitialDetectionTime.After(last) {
+			last = *d.InitialDetectionTime
+		}
+	}
+
+	for _, status := range defenderThreatStatuses {
+		ch <- prometheus.MustNewConstMetric(
+			c.ThreatDetections,
+			prometheus.GaugeValue,
+			detections[status],
+			status,
+		)
+	}
+	if !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastThreatDetection,
+			prometheus.GaugeValue,
+			float64(last.Unix()),
+		)
+	}
+	return nil, nil
+}
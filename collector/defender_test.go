@@ -0,0 +1,17 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkDefenderCollector(b *testing.B) {
+	benchmarkCollector(b, "defender", newDefenderCollector)
+}
+
+func TestDefenderThreatStatus(t *testing.T) {
+	for id, expected := range map[uint8]string{0: "unknown", 1: "detected", 3: "quarantined", 6: "blocked", 102: "failed", 107: "failed", 108: "unknown"} {
+		if got := defenderThreatStatus(id); got != expected {
+			t.Errorf("Expected status %s for threat status %d, got %s", expected, id, got)
+		}
+	}
+}
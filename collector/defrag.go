@@ -0,0 +1,448 @@
+// +build windows
+
+package collector
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/headers/winioctl"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("defrag", newDefragCollector)
+}
+
+var defragLogger = log.With("collector", "defrag")
+
+var (
+	defragEventLookback = kingpin.Flag(
+		"collector.defrag.event-lookback",
+		"How far back to look for completed optimizations in the Application event log.",
+	).Default("744h").Duration()
+	defragAnalysisInterval = kingpin.Flag(
+		"collector.defrag.analysis-interval",
+		"How often to analyze the fragmentation of the volumes in the background. Analysis reads the file system metadata of every volume. 0 to disable.",
+	).Default("0s").Duration()
+)
+
+const (
+	defragLog = "Application"
+	// Event 258 records an optimization the storage optimizer completed,
+	// e.g. "The storage optimizer successfully completed retrim on (C:)".
+	defragEventsQuery = "*[System[Provider[@Name='Microsoft-Windows-Defrag'] and EventID=258 and TimeCreated[timediff(@SystemTime) <= %d]]]"
+)
+
+var defragDriveLetterPattern = regexp.MustCompile(`\(([A-Za-z]:)\)`)
+
+// A DefragCollector is a Prometheus collector for the optimization of the
+// local volumes by the storage optimizer, and their TRIM and thin provisioning
+// support
+type DefragCollector struct {
+	LastOptimization           *prometheus.Desc
+	TrimEnabled                *prometheus.Desc
+	ThinProvisioned            *prometheus.Desc
+	MappingResourcesUsed       *prometheus.Desc
+	MappingResourcesAvailable  *prometheus.Desc
+	FragmentationRatio         *prometheus.Desc
+	FreeSpaceFragmentation     *prometheus.Desc
+	MFTFragments               *prometheus.Desc
+	DefragmentationRecommended *prometheus.Desc
+
+	mu       sync.Mutex
+	analyses map[string]defragAnalysisResult
+	// done is closed to stop analyzing the volumes.
+	done chan struct{}
+}
+
+func newDefragCollector() (Collector, error) {
+	const subsystem = "defrag"
+
+	c := &DefragCollector{
+		LastOptimization: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_optimization_timestamp_seconds"),
+			"Time the storage optimizer last completed the operation on the volume, within collector.defrag.event-lookback",
+			[]string{"volume", "operation"},
+			nil,
+		),
+		TrimEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "trim_enabled"),
+			"Whether the device of the volume supports TRIM or unmap (1) or not (0)",
+			[]string{"volume"},
+			nil,
+		),
+		ThinProvisioned: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "thin_provisioned"),
+			"Whether the device of the volume is thinly provisioned (1) or not (0)",
+			[]string{"volume"},
+			nil,
+		),
+		MappingResourcesUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "thin_provisioning_used_bytes"),
+			"Mapping resources, e.g. slabs, of the thinly provisioned device used by the volume",
+			[]string{"volume"},
+			nil,
+		),
+		MappingResourcesAvailable: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "thin_provisioning_available_bytes"),
+			"Mapping resources still available to the thinly provisioned device of the volume",
+			[]string{"volume"},
+			nil,
+		),
+		FragmentationRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "fragmentation_ratio"),
+			"Share of the volume fragmented, as of the last analysis",
+			[]string{"volume"},
+			nil,
+		),
+		FreeSpaceFragmentation: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "free_space_fragmentation_ratio"),
+			"Share of the free space of the volume fragmented, as of the last analysis",
+			[]string{"volume"},
+			nil,
+		),
+		MFTFragments: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "mft_fragments"),
+			"Number of fragments of the master file table of the volume, as of the last analysis",
+			[]string{"volume"},
+			nil,
+		),
+		DefragmentationRecommended: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "defragmentation_recommended"),
+			"Whether the last analysis recommended defragmenting the volume (1) or not (0)",
+			[]string{"volume"},
+			nil,
+		),
+		analyses: make(map[string]defragAnalysisResult),
+		done:     make(chan struct{}),
+	}
+	if *defragAnalysisInterval > 0 {
+		go c.analyzeVolumes()
+	}
+	return c, nil
+}
+
+// Close stops analyzing the fragmentation of the volumes.
+func (c *DefragCollector) Close() error {
+	close(c.done)
+	return nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *DefragCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectVolumes(ctx, ch); err != nil {
+		defragLogger.Error("failed collecting defrag volume metrics:", desc, err)
+		return err
+	}
+	c.collectOptimizations(ch)
+	return nil
+}
+
+// defragVolume is the Win32_Volume of a local disk volume.
+type defragVolume struct {
+	DeviceID    string
+	DriveLetter string
+	Name        string
+}
+
+// label returns the drive letter of the volume, e.g. C:, or its mount point
+// or volume GUID path if it has none.
+func (v defragVolume) label() string {
+	if v.DriveLetter != "" {
+		return v.DriveLetter
+	}
+	return v.Name
+}
+
+const defragVolumesQuery = "SELECT DeviceID, DriveLetter, Name FROM Win32_Volume WHERE DriveType = 3"
+
+func (c *DefragCollector) collectVolumes(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var volumes []defragVolume
+	if err := queryWMIContext(ctx.Context(), defragVolumesQuery, &volumes); err != nil {
+		return nil, err
+	}
+
+	for _, v := range volumes {
+		c.collectVolumeDevice(v, ch)
+	}
+
+	c.mu.Lock()
+	analyses := c.analyses
+	c.mu.Unlock()
+	for _, v := range volumes {
+		a, ok := analyses[v.DeviceID]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.FragmentationRatio,
+			prometheus.GaugeValue,
+			a.fragmentation,
+			v.label(),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.FreeSpaceFragmentation,
+			prometheus.GaugeValue,
+			a.freeSpaceFragmentation,
+			v.label(),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.MFTFragments,
+			prometheus.GaugeValue,
+			a.mftFragments,
+			v.label(),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.DefragmentationRecommended,
+			prometheus.GaugeValue,
+			boolToFloat(a.recommended),
+			v.label(),
+		)
+	}
+	return nil, nil
+}
+
+// collectVolumeDevice sends the TRIM and thin provisioning support of the
+// device of the volume. Volumes that can't be opened, e.g. without
+// administrative rights, are skipped.
+func (c *DefragCollector) collectVolumeDevice(v defragVolume, ch chan<- prometheus.Metric) {
+	h, err := winioctl.OpenVolume(strings.TrimSuffix(v.DeviceID, `\`))
+	if err != nil {
+		defragLogger.Debugf("Failed to open volume %s: %v", v.label(), err)
+		return
+	}
+	defer windows.CloseHandle(h)
+
+	if trim, err := winioctl.TrimEnabled(h); err == nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.TrimEnabled,
+			prometheus.GaugeValue,
+			boolToFloat(trim),
+			v.label(),
+		)
+	}
+	thin, err := winioctl.ThinProvisioningEnabled(h)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.ThinProvisioned,
+		prometheus.GaugeValue,
+		boolToFloat(thin),
+		v.label(),
+	)
+	if !thin {
+		return
+	}
+	resources, err := winioctl.GetMappingResources(h)
+	if err != nil {
+		defragLogger.Debugf("Failed to get the mapping resources of volume %s: %v", v.label(), err)
+		return
+	}
+	if resources.Used >= 0 {
+		ch <- prometheus.MustNewConstMetric(
+			c.MappingResourcesUsed,
+			prometheus.GaugeValue,
+			float64(resources.Used),
+			v.label(),
+		)
+	}
+	if resources.Available >= 0 {
+		ch <- prometheus.MustNewConstMetric(
+			c.MappingResourcesAvailable,
+			prometheus.GaugeValue,
+			float64(resources.Available),
+			v.label(),
+		)
+	}
+}
+
+// collectOptimizations sends the time of the last optimizations logged. The
+// Application log may have been cleared, which only leaves them out.
+func (c *DefragCollector) collectOptimizations(ch chan<- prometheus.Metric) {
+	events, err := wevtapi.Query(defragLog, fmt.Sprintf(defragEventsQuery, defragEventLookback.Milliseconds()))
+	if err != nil {
+		defragLogger.Debugf("Failed to query storage optimizer events: %v", err)
+		return
+	}
+	var parsed []defragEvent
+	for _, e := range events {
+		var de defragEvent
+		if err := xml.Unmarshal([]byte(e), &de); err != nil {
+			defragLogger.Debugf("Ignoring invalid storage optimizer event: %v", err)
+			continue
+		}
+		parsed = append(parsed, de)
+	}
+	for key, t := range defragLastOptimizations(parsed) {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastOptimization,
+			prometheus.GaugeValue,
+			float64(t.UnixNano())/1e9,
+			key.volume, key.operation,
+		)
+	}
+}
+
+// defragEvent is a completed optimization event, whose data are the operation,
+// e.g. retrim, and the volume, e.g. (C:).
+type defragEvent struct {
+	Time struct {
+		SystemTime time.Time `xml:"SystemTime,attr"`
+	} `xml:"System>TimeCreated"`
+	Data []string `xml:"EventData>Data"`
+}
+
+type defragOptimizationKey struct {
+	volume    string
+	operation string
+}
+
+// defragLastOptimizations returns the time of the last completion of each
+// operation on each volume. Volumes are named by their drive letter if the
+// event mentions one.
+func defragLastOptimizations(events []defragEvent) map[defragOptimizationKey]time.Time {
+	last := make(map[defragOptimizationKey]time.Time)
+	for _, e := range events {
+		if len(e.Data) < 2 {
+			continue
+		}
+		volume := strings.TrimSpace(e.Data[1])
+		if m := defragDriveLetterPattern.FindStringSubmatch(volume); m != nil {
+			volume = strings.ToUpper(m[1])
+		}
+		key := defragOptimizationKey{volume: volume, operation: strings.ToLower(strings.TrimSpace(e.Data[0]))}
+		if t := e.Time.SystemTime; t.After(last[key]) {
+			last[key] = t
+		}
+	}
+	return last
+}
+
+type defragAnalysisResult struct {
+	fragmentation          float64
+	freeSpaceFragmentation float64
+	mftFragments           float64
+	recommended            bool
+}
+
+// analyzeVolumes analyzes the volumes in the background, as an analysis can
+// take minutes on large volumes.
+func (c *DefragCollector) analyzeVolumes() {
+	for {
+		var volumes []defragVolume
+		if err := queryWMI(defragVolumesQuery, &volumes); err != nil {
+			defragLogger.Warnf("Failed to list volumes to analyze: %v", err)
+		}
+		analyses := make(map[string]defragAnalysisResult, len(volumes))
+		for _, v := range volumes {
+			a, err := defragAnalysis(v.DeviceID)
+			if err != nil {
+				defragLogger.Warnf("Failed to analyze the fragmentation of volume %s: %v", v.label(), err)
+				continue
+			}
+			analyses[v.DeviceID] = a
+		}
+		c.mu.Lock()
+		c.analyses = analyses
+		c.mu.Unlock()
+		select {
+		case <-c.done:
+			return
+		case <-time.After(flagDuration(defragAnalysisInterval)):
+		}
+	}
+}
+
+var wmiObjectPathEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// defragAnalysis runs the DefragAnalysis method of the Win32_Volume through
+// the scripting API, as the WMI client only runs queries.
+// https://docs.microsoft.com/en-us/previous-versions/windows/desktop/vdswmi/defraganalysis-method-in-class-win32-volume
+func defragAnalysis(deviceID string) (defragAnalysisResult, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if code := err.(*ole.OleError).Code(); code != ole.S_OK && code != wmi.S_FALSE {
+			return defragAnalysisResult{}, err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("WbemScripting.SWbemLocator")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer unknown.Release()
+	locator, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer locator.Release()
+	serviceRaw, err := oleutil.CallMethod(locator, "ConnectServer")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer serviceRaw.Clear()
+
+	path := fmt.Sprintf(`Win32_Volume.DeviceID="%s"`, wmiObjectPathEscaper.Replace(deviceID))
+	outRaw, err := oleutil.CallMethod(serviceRaw.ToIDispatch(), "ExecMethod", path, "DefragAnalysis")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer outRaw.Clear()
+	out := outRaw.ToIDispatch()
+
+	returnValue, err := oleutil.GetProperty(out, "ReturnValue")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer returnValue.Clear()
+	if code, _ := returnValue.Value().(int32); code != 0 {
+		return defragAnalysisResult{}, fmt.Errorf("DefragAnalysis returned %d", code)
+	}
+	recommended, err := oleutil.GetProperty(out, "DefragRecommended")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer recommended.Clear()
+	analysis, err := oleutil.GetProperty(out, "DefragAnalysis")
+	if err != nil {
+		return defragAnalysisResult{}, err
+	}
+	defer analysis.Clear()
+
+	result := defragAnalysisResult{}
+	result.recommended, _ = recommended.Value().(bool)
+	for name, dst := range map[string]*float64{
+		"TotalPercentFragmentation":     &result.fragmentation,
+		"FreeSpacePercentFragmentation": &result.freeSpaceFragmentation,
+		"TotalMFTFragments":             &result.mftFragments,
+	} {
+		v, err := oleutil.GetProperty(analysis.ToIDispatch(), name)
+		if err != nil {
+			return defragAnalysisResult{}, err
+		}
+		// The uint32 properties are returned as int32 by the scripting API.
+		n, _ := v.Value().(int32)
+		v.Clear()
+		*dst = float64(n)
+	}
+	result.fragmentation /= 100
+	result.freeSpaceFragmentation /= 100
+	return result, nil
+}
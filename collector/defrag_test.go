@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func BenchmarkDefragCollector(b *testing.B) {
+	benchmarkCollector(b, "defrag", newDefragCollector)
+}
+
+func TestDefragLastOptimizations(t *testing.T) {
+	event := func(systemTime, operation, volume string) string {
+		return `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System>` +
+			`<Provider Name="Microsoft-Windows-Defrag"/><EventID>258</EventID>` +
+			`<TimeCreated SystemTime="` + systemTime + `"/></System>` +
+			`<EventData><Data>` + operation + `</Data><Data>` + volume + `</Data></EventData></Event>`
+	}
+	guid := `\\?\Volume{3f1c2a52-0000-0000-0000-100000000000}\`
+	raw := []string{
+		event("2020-10-04T01:00:00.000Z", "retrim", "(C:)"),
+		event("2020-10-11T01:00:00.000Z", "retrim", "(C:)"),
+		event("2020-10-11T01:05:00.000Z", "defragmentation", "Data (d:)"),
+		event("2020-10-11T01:10:00.000Z", "slab consolidation", guid),
+	}
+	var events []defragEvent
+	for _, r := range raw {
+		var e defragEvent
+		if err := xml.Unmarshal([]byte(r), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+
+	expected := map[defragOptimizationKey]time.Time{
+		{volume: "C:", operation: "retrim"}:             time.Date(2020, 10, 11, 1, 0, 0, 0, time.UTC),
+		{volume: "D:", operation: "defragmentation"}:    time.Date(2020, 10, 11, 1, 5, 0, 0, time.UTC),
+		{volume: guid, operation: "slab consolidation"}: time.Date(2020, 10, 11, 1, 10, 0, 0, time.UTC),
+	}
+	if got := defragLastOptimizations(events); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Optimizations do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
@@ -20,6 +20,8 @@ func init() {
 	registerCollector("dfsr", NewDFSRCollector, perflibDependencies...)
 }
 
+var dfsrLogger = log.With("collector", "dfsr")
+
 // DFSRCollector contains the metric and state data of the DFSR collectors.
 type DFSRCollector struct {
 	// Connection source
@@ -93,7 +95,7 @@ func dfsrGetPerfObjectName(collector string) string {
 
 // NewDFSRCollector is registered
 func NewDFSRCollector() (Collector, error) {
-	log.Info("dfsr collector is in an experimental state! Metrics for this collector have not been tested.")
+	dfsrLogger.Info("dfsr collector is in an experimental state! Metrics for this collector have not been tested.")
 	const subsystem = "dfsr"
 
 	enabled := expandEnabledChildCollectors(*dfsrEnabledCollectors)
@@ -447,7 +449,7 @@ type PerflibDFSRConnection struct {
 
 func (c *DFSRCollector) collectConnection(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var dst []PerflibDFSRConnection
-	if err := unmarshalObject(ctx.perfObjects["DFS Replication Connections"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["DFS Replication Connections"], &dst, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -554,7 +556,7 @@ type PerflibDFSRFolder struct {
 
 func (c *DFSRCollector) collectFolder(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var dst []PerflibDFSRFolder
-	if err := unmarshalObject(ctx.perfObjects["DFS Replicated Folders"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["DFS Replicated Folders"], &dst, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -764,7 +766,7 @@ type PerflibDFSRVolume struct {
 
 func (c *DFSRCollector) collectVolume(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var dst []PerflibDFSRVolume
-	if err := unmarshalObject(ctx.perfObjects["DFS Replication Service Volumes"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["DFS Replication Service Volumes"], &dst, ctx.logger()); err != nil {
 		return err
 	}
 
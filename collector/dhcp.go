@@ -229,7 +229,7 @@ type dhcpPerf struct {
 
 func (c *DhcpCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var perflib []dhcpPerf
-	if err := unmarshalObject(ctx.perfObjects["DHCP Server"], &perflib); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["DHCP Server"], &perflib, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -0,0 +1,273 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("dns_analytic", newDNSAnalyticCollector)
+}
+
+var dnsAnalyticLogger = log.With("collector", "dns_analytic")
+
+var (
+	dnsAnalyticIPv4PrefixLength = kingpin.Flag(
+		"collector.dns_analytic.ipv4-prefix-length",
+		"Prefix length of the subnets IPv4 clients are aggregated by.",
+	).Default("24").Int()
+	dnsAnalyticIPv6PrefixLength = kingpin.Flag(
+		"collector.dns_analytic.ipv6-prefix-length",
+		"Prefix length of the subnets IPv6 clients are aggregated by.",
+	).Default("64").Int()
+	dnsAnalyticMaxSubnets = kingpin.Flag(
+		"collector.dns_analytic.max-subnets",
+		"Maximum number of client subnets exposed. Clients of further subnets are aggregated as subnet \"other\".",
+	).Default("1000").Int()
+)
+
+const (
+	dnsAnalyticSessionName = "windows_exporter_dns_analytic"
+	dnsAnalyticOtherSubnet = "other"
+
+	// Events of the Microsoft-Windows-DNSServer analytic channel
+	dnsEventQueryReceived   = 256
+	dnsEventResponseSuccess = 257
+	dnsEventResponseFailure = 258
+)
+
+// Microsoft-Windows-DNSServer
+var dnsServerProvider = windows.GUID{
+	Data1: 0xeb79061a,
+	Data2: 0xa566,
+	Data3: 0x4698,
+	Data4: [8]byte{0x91, 0x19, 0x3e, 0xd2, 0x80, 0x70, 0x60, 0xe7},
+}
+
+// https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml#dns-parameters-4
+var dnsQueryTypes = map[string]string{
+	"1":   "A",
+	"2":   "NS",
+	"5":   "CNAME",
+	"6":   "SOA",
+	"12":  "PTR",
+	"15":  "MX",
+	"16":  "TXT",
+	"28":  "AAAA",
+	"33":  "SRV",
+	"35":  "NAPTR",
+	"43":  "DS",
+	"46":  "RRSIG",
+	"47":  "NSEC",
+	"48":  "DNSKEY",
+	"50":  "NSEC3",
+	"64":  "SVCB",
+	"65":  "HTTPS",
+	"251": "IXFR",
+	"252": "AXFR",
+	"255": "ANY",
+	"257": "CAA",
+}
+
+// https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml#dns-parameters-6
+var dnsResponseCodes = map[string]string{
+	"0":  "NOERROR",
+	"1":  "FORMERR",
+	"2":  "SERVFAIL",
+	"3":  "NXDOMAIN",
+	"4":  "NOTIMP",
+	"5":  "REFUSED",
+	"6":  "YXDOMAIN",
+	"7":  "YXRRSET",
+	"8":  "NXRRSET",
+	"9":  "NOTAUTH",
+	"10": "NOTZONE",
+}
+
+type dnsQueryKey struct {
+	subnet string
+	qtype  string
+}
+
+type dnsResponseKey struct {
+	subnet string
+	qtype  string
+	rcode  string
+}
+
+// A DNSAnalyticCollector is a Prometheus collector for the queries and
+// responses of the DNS Server, aggregated by client subnet, from the events of
+// its analytic channel
+type DNSAnalyticCollector struct {
+	QueriesReceived *prometheus.Desc
+	Responses       *prometheus.Desc
+
+	ipv4Mask   net.IPMask
+	ipv6Mask   net.IPMask
+	maxSubnets int
+	session    *etw.Session
+
+	mu        sync.Mutex
+	queries   map[dnsQueryKey]float64
+	responses map[dnsResponseKey]float64
+	subnets   map[string]bool
+}
+
+func newDNSAnalyticCollector() (Collector, error) {
+	const subsystem = "dns_analytic"
+
+	c := &DNSAnalyticCollector{
+		QueriesReceived: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "queries_received_total"),
+			"Total queries received from clients of the subnet since the exporter started",
+			[]string{"subnet", "qtype"},
+			nil,
+		),
+		Responses: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "responses_total"),
+			"Total responses sent to clients of the subnet since the exporter started",
+			[]string{"subnet", "qtype", "rcode"},
+			nil,
+		),
+		ipv4Mask:   net.CIDRMask(*dnsAnalyticIPv4PrefixLength, 8*net.IPv4len),
+		ipv6Mask:   net.CIDRMask(*dnsAnalyticIPv6PrefixLength, 8*net.IPv6len),
+		maxSubnets: *dnsAnalyticMaxSubnets,
+		queries:    make(map[dnsQueryKey]float64),
+		responses:  make(map[dnsResponseKey]float64),
+		subnets:    make(map[string]bool),
+	}
+	if c.ipv4Mask == nil || c.ipv6Mask == nil {
+		return nil, fmt.Errorf("invalid DNS client subnet prefix lengths /%d and /%d", *dnsAnalyticIPv4PrefixLength, *dnsAnalyticIPv6PrefixLength)
+	}
+
+	// Only informational events are written to the analytic channel.
+	session, err := etw.StartSession(dnsAnalyticSessionName, []etw.Provider{{GUID: dnsServerProvider, Level: 4}}, c.handleEvent)
+	if err != nil {
+		return nil, err
+	}
+	c.session = session
+	return c, nil
+}
+
+func (c *DNSAnalyticCollector) handleEvent(e *etw.Event) {
+	if e.ID != dnsEventQueryReceived && e.ID != dnsEventResponseSuccess && e.ID != dnsEventResponseFailure {
+		return
+	}
+	props, err := e.Properties()
+	if err != nil {
+		dnsAnalyticLogger.Debugf("Failed to decode DNS Server event %d: %v", e.ID, err)
+		return
+	}
+
+	client := props["Destination"]
+	if e.ID == dnsEventQueryReceived {
+		client = props["Source"]
+	}
+	subnet, ok := dnsClientSubnet(client, c.ipv4Mask, c.ipv6Mask)
+	if !ok {
+		dnsAnalyticLogger.Debugf("Ignoring DNS Server event %d with client address %q", e.ID, client)
+		return
+	}
+	qtype := dnsQueryTypeName(props["QTYPE"])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.subnets[subnet] {
+		if len(c.subnets) >= c.maxSubnets {
+			subnet = dnsAnalyticOtherSubnet
+		} else {
+			c.subnets[subnet] = true
+		}
+	}
+	if e.ID == dnsEventQueryReceived {
+		c.queries[dnsQueryKey{subnet: subnet, qtype: qtype}]++
+		return
+	}
+	c.responses[dnsResponseKey{subnet: subnet, qtype: qtype, rcode: dnsResponseCodeName(props["RCODE"])}]++
+}
+
+// dnsClientSubnet returns the subnet of the client address in CIDR notation.
+// The address may include a port.
+func dnsClientSubnet(addr string, ipv4Mask, ipv6Mask net.IPMask) (string, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", false
+		}
+		if ip = net.ParseIP(host); ip == nil {
+			return "", false
+		}
+	}
+	subnet := net.IPNet{IP: ip.To4(), Mask: ipv4Mask}
+	if subnet.IP == nil {
+		subnet = net.IPNet{IP: ip, Mask: ipv6Mask}
+	}
+	subnet.IP = subnet.IP.Mask(subnet.Mask)
+	return subnet.String(), true
+}
+
+// dnsQueryTypeName returns the mnemonic of the numeric query type, or the
+// number if it has none.
+func dnsQueryTypeName(qtype string) string {
+	if name, ok := dnsQueryTypes[qtype]; ok {
+		return name
+	}
+	return qtype
+}
+
+// dnsResponseCodeName returns the mnemonic of the numeric response code, or
+// the number if it has none.
+func dnsResponseCodeName(rcode string) string {
+	if name, ok := dnsResponseCodes[rcode]; ok {
+		return name
+	}
+	return rcode
+}
+
+// Close stops the ETW session of the collector.
+func (c *DNSAnalyticCollector) Close() error {
+	return c.session.Close()
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *DNSAnalyticCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	queries := make(map[dnsQueryKey]float64, len(c.queries))
+	for k, v := range c.queries {
+		queries[k] = v
+	}
+	responses := make(map[dnsResponseKey]float64, len(c.responses))
+	for k, v := range c.responses {
+		responses[k] = v
+	}
+	c.mu.Unlock()
+
+	for k, v := range queries {
+		ch <- prometheus.MustNewConstMetric(
+			c.QueriesReceived,
+			prometheus.CounterValue,
+			v,
+			k.subnet, k.qtype,
+		)
+	}
+	for k, v := range responses {
+		ch <- prometheus.MustNewConstMetric(
+			c.Responses,
+			prometheus.CounterValue,
+			v,
+			k.subnet, k.qtype, k.rcode,
+		)
+	}
+	return nil
+}
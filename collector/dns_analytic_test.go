@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"net"
+	"testing"
+)
+
+func BenchmarkDNSAnalyticCollector(b *testing.B) {
+	benchmarkCollector(b, "dns_analytic", newDNSAnalyticCollector)
+}
+
+func TestDNSClientSubnet(t *testing.T) {
+	ipv4Mask := net.CIDRMask(24, 32)
+	ipv6Mask := net.CIDRMask(64, 128)
+	cases := []struct {
+		addr   string
+		subnet string
+		ok     bool
+	}{
+		{"192.0.2.17", "192.0.2.0/24", true},
+		{"192.0.2.17:53122", "192.0.2.0/24", true},
+		{"2001:db8::1", "2001:db8::/64", true},
+		{"[2001:db8:0:1::1]:53122", "2001:db8:0:1::/64", true},
+		{"::ffff:192.0.2.17", "192.0.2.0/24", true},
+		{"", "", false},
+		{"client", "", false},
+	}
+	for _, c := range cases {
+		subnet, ok := dnsClientSubnet(c.addr, ipv4Mask, ipv6Mask)
+		if subnet != c.subnet || ok != c.ok {
+			t.Errorf("dnsClientSubnet(%q) = %q, %v, want %q, %v", c.addr, subnet, ok, c.subnet, c.ok)
+		}
+	}
+}
+
+func TestDNSQueryTypeName(t *testing.T) {
+	for qtype, want := range map[string]string{"1": "A", "28": "AAAA", "65": "HTTPS", "65280": "65280"} {
+		if got := dnsQueryTypeName(qtype); got != want {
+			t.Errorf("dnsQueryTypeName(%q) = %q, want %q", qtype, got, want)
+		}
+	}
+}
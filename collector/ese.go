@@ -0,0 +1,272 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("ese", newESECollector, "Database ==> Instances")
+}
+
+var eseLogger = log.With("collector", "ese")
+
+var (
+	eseInstanceWhitelist = kingpin.Flag(
+		"collector.ese.instance-whitelist",
+		"Regexp of ESE database instances to whitelist. Instance name must both match whitelist and not match blacklist to be included.",
+	).Default(".+").String()
+	eseInstanceBlacklist = kingpin.Flag(
+		"collector.ese.instance-blacklist",
+		"Regexp of ESE database instances to blacklist. Instance name must both match whitelist and not match blacklist to be included.",
+	).Default("").String()
+)
+
+// A ESECollector is a Prometheus collector for the perflib "Database ==>
+// Instances" metrics of the Extensible Storage Engine databases, e.g. those of
+// Active Directory, DHCP, Exchange and WSUS
+type ESECollector struct {
+	LogBytesGeneratedTotal     *prometheus.Desc
+	LogBytesWrittenTotal       *prometheus.Desc
+	LogFilesGeneratedTotal     *prometheus.Desc
+	LogRecordsTotal            *prometheus.Desc
+	LogCheckpointDepth         *prometheus.Desc
+	CacheHitsTotal             *prometheus.Desc
+	CacheRequestsTotal         *prometheus.Desc
+	CacheSizeBytes             *prometheus.Desc
+	VersionBucketsAllocated    *prometheus.Desc
+	IOReadsTotal               *prometheus.Desc
+	IOReadLatencySecondsTotal  *prometheus.Desc
+	IOWritesTotal              *prometheus.Desc
+	IOWriteLatencySecondsTotal *prometheus.Desc
+
+	instanceWhitelistPattern *regexp.Regexp
+	instanceBlacklistPattern *regexp.Regexp
+}
+
+func newESECollector() (Collector, error) {
+	const subsystem = "ese"
+
+	return &ESECollector{
+		LogBytesGeneratedTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_bytes_generated_total"),
+			"Total bytes of log data generated by the instance",
+			[]string{"name"},
+			nil,
+		),
+		LogBytesWrittenTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_bytes_written_total"),
+			"Total bytes written to the log files of the instance",
+			[]string{"name"},
+			nil,
+		),
+		LogFilesGeneratedTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_files_generated_total"),
+			"Total log files generated by the instance",
+			[]string{"name"},
+			nil,
+		),
+		LogRecordsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_records_total"),
+			"Total records written to the log buffers of the instance",
+			[]string{"name"},
+			nil,
+		),
+		LogCheckpointDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_generation_checkpoint_depth"),
+			"Log files generated by the instance that have not yet been flushed to the database",
+			[]string{"name"},
+			nil,
+		),
+		CacheHitsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_cache_hits_total"),
+			"Total database page requests fulfilled by the database cache",
+			[]string{"name"},
+			nil,
+		),
+		CacheRequestsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_cache_requests_total"),
+			"Total database page requests to the database cache",
+			[]string{"name"},
+			nil,
+		),
+		CacheSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "database_cache_size_bytes"),
+			"Memory used by the database cache of the instance",
+			[]string{"name"},
+			nil,
+		),
+		VersionBucketsAllocated: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "version_buckets_allocated"),
+			"Version store buckets allocated by the instance",
+			[]string{"name"},
+			nil,
+		),
+		IOReadsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_database_reads_total"),
+			"Total read operations on the attached database files of the instance",
+			[]string{"name"},
+			nil,
+		),
+		IOReadLatencySecondsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_database_read_latency_seconds_total"),
+			"Total time spent on read operations on the attached database files of the instance",
+			[]string{"name"},
+			nil,
+		),
+		IOWritesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_database_writes_total"),
+			"Total write operations on the attached database files of the instance",
+			[]string{"name"},
+			nil,
+		),
+		IOWriteLatencySecondsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_database_write_latency_seconds_total"),
+			"Total time spent on write operations on the attached database files of the instance",
+			[]string{"name"},
+			nil,
+		),
+
+		instanceWhitelistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *eseInstanceWhitelist)),
+		instanceBlacklistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *eseInstanceBlacklist)),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ESECollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ctx, ch); err != nil {
+		eseLogger.Error("failed collecting ese metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// esentDatabaseInstance holds the counters of an ESE instance, named after the
+// process hosting it and the instance, e.g. lsass/NTDSA.
+// https://docs.microsoft.com/en-us/windows/win32/extensible-storage-engine/extensible-storage-engine
+type esentDatabaseInstance struct {
+	Name string
+
+	LogBytesGeneratedPerSec                     float64 `perflib:"Log Bytes Generated/sec"`
+	LogBytesWritePerSec                         float64 `perflib:"Log Bytes Write/sec"`
+	LogFilesGenerated                           float64 `perflib:"Log Files Generated"`
+	LogRecordsPerSec                            float64 `perflib:"Log Records/sec"`
+	LogGenerationCheckpointDepth                float64 `perflib:"Log Generation Checkpoint Depth"`
+	DatabaseCachePercentHit                     float64 `perflib:"Database Cache % Hit"`
+	DatabaseCachePercentHit_Base                float64 `perflib:"Database Cache % Hit_Base"`
+	DatabaseCacheSizeMB                         float64 `perflib:"Database Cache Size (MB)"`
+	VersionBucketsAllocated                     float64 `perflib:"Version buckets allocated"`
+	DefragmentationTasks                        float64 `perflib:"Defragmentation Tasks"`
+	DefragmentationTasksPending                 float64 `perflib:"Defragmentation Tasks Pending"`
+	OnlineDefragPagesRead                       float64 `perflib:"Online Defrag Pages Read/sec"`
+	OnlineDefragPagesDirtied                    float64 `perflib:"Online Defrag Pages Dirtied/sec"`
+	OnlineDefragPagesFreed                      float64 `perflib:"Online Defrag Pages Freed/sec"`
+	IODatabaseReadsAttachedAverageLatency       float64 `perflib:"I/O Database Reads (Attached) Average Latency"`
+	IODatabaseReadsAttachedAverageLatency_Base  float64 `perflib:"I/O Database Reads (Attached) Average Latency_Base"`
+	IODatabaseWritesAttachedAverageLatency      float64 `perflib:"I/O Database Writes (Attached) Average Latency"`
+	IODatabaseWritesAttachedAverageLatency_Base float64 `perflib:"I/O Database Writes (Attached) Average Latency_Base"`
+}
+
+func (c *ESECollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []esentDatabaseInstance
+	if err := unmarshalObject(ctx.perfObjects["Database ==> Instances"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, instance := range dst {
+		if instance.Name == "_Total" ||
+			c.instanceBlacklistPattern.MatchString(instance.Name) ||
+			!c.instanceWhitelistPattern.MatchString(instance.Name) {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.LogBytesGeneratedTotal,
+			prometheus.CounterValue,
+			instance.LogBytesGeneratedPerSec,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.LogBytesWrittenTotal,
+			prometheus.CounterValue,
+			instance.LogBytesWritePerSec,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.LogFilesGeneratedTotal,
+			prometheus.CounterValue,
+			instance.LogFilesGenerated,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.LogRecordsTotal,
+			prometheus.CounterValue,
+			instance.LogRecordsPerSec,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.LogCheckpointDepth,
+			prometheus.GaugeValue,
+			instance.LogGenerationCheckpointDepth,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheHitsTotal,
+			prometheus.CounterValue,
+			instance.DatabaseCachePercentHit,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheRequestsTotal,
+			prometheus.CounterValue,
+			instance.DatabaseCachePercentHit_Base,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheSizeBytes,
+			prometheus.GaugeValue,
+			instance.DatabaseCacheSizeMB*1024*1024,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VersionBucketsAllocated,
+			prometheus.GaugeValue,
+			instance.VersionBucketsAllocated,
+			instance.Name,
+		)
+		// The latencies are sums of milliseconds, divided by the number of
+		// operations in their base.
+		ch <- prometheus.MustNewConstMetric(
+			c.IOReadsTotal,
+			prometheus.CounterValue,
+			instance.IODatabaseReadsAttachedAverageLatency_Base,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.IOReadLatencySecondsTotal,
+			prometheus.CounterValue,
+			instance.IODatabaseReadsAttachedAverageLatency/1000,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.IOWritesTotal,
+			prometheus.CounterValue,
+			instance.IODatabaseWritesAttachedAverageLatency_Base,
+			instance.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.IOWriteLatencySecondsTotal,
+			prometheus.CounterValue,
+			instance.IODatabaseWritesAttachedAverageLatency/1000,
+			instance.Name,
+		)
+	}
+	return nil, nil
+}
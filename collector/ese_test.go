@@ -0,0 +1,9 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkESECollector(b *testing.B) {
+	benchmarkCollector(b, "ese", newESECollector)
+}
@@ -0,0 +1,344 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	registerCollector("etw", newETWCollector)
+}
+
+var etwLogger = log.With("collector", "etw")
+
+var etwConfigFile = kingpin.Flag(
+	"collector.etw.config-file",
+	"YAML file listing the ETW providers and events to expose.",
+).Default("").String()
+
+const (
+	etwSessionName = "windows_exporter_etw"
+	etwOtherLabel  = "other"
+
+	etwDefaultLevel     = 4
+	etwDefaultMaxSeries = 1000
+)
+
+// etwEventConfig is an entry of the events of a provider in the ETW
+// configuration file.
+type etwEventConfig struct {
+	ID   uint16 `yaml:"id"`
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	// Labels are the properties of the event whose values label the samples.
+	Labels []string `yaml:"labels"`
+	// Value is the numeric property observed by a histogram. Without it, the
+	// events are counted.
+	Value   string    `yaml:"value"`
+	Scale   float64   `yaml:"scale"`
+	Buckets []float64 `yaml:"buckets"`
+	// MaxSeries bounds the label value combinations, further ones being
+	// aggregated as "other".
+	MaxSeries int `yaml:"max_series"`
+}
+
+// etwProviderConfig is an entry of the providers section of the ETW
+// configuration file.
+type etwProviderConfig struct {
+	GUID            string           `yaml:"guid"`
+	Level           uint8            `yaml:"level"`
+	MatchAnyKeyword uint64           `yaml:"match_any_keyword"`
+	Events          []etwEventConfig `yaml:"events"`
+}
+
+type etwConfig struct {
+	Providers []etwProviderConfig `yaml:"providers"`
+}
+
+// etwHistogram is a histogram with cumulative bucket counts, as exposed by
+// prometheus.MustNewConstHistogram.
+type etwHistogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func newETWHistogram(bounds []float64) *etwHistogram {
+	h := &etwHistogram{buckets: make(map[float64]uint64, len(bounds))}
+	for _, bound := range bounds {
+		h.buckets[bound] = 0
+	}
+	return h
+}
+
+func (h *etwHistogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for bound := range h.buckets {
+		if v <= bound {
+			h.buckets[bound]++
+		}
+	}
+}
+
+// metric returns the histogram as a constant metric. The bucket counts are
+// copied, as they change after the caller releases its lock.
+func (h *etwHistogram) metric(desc *prometheus.Desc, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.buckets))
+	for bound, n := range h.buckets {
+		buckets[bound] = n
+	}
+	return prometheus.MustNewConstHistogram(desc, h.count, h.sum, buckets, labels...)
+}
+
+type etwSeries struct {
+	labels    []string
+	count     float64
+	histogram *etwHistogram
+}
+
+// etwEvent aggregates the events of an entry of the configuration file.
+type etwEvent struct {
+	config etwEventConfig
+	desc   *prometheus.Desc
+	series map[string]*etwSeries
+}
+
+type etwEventKey struct {
+	provider windows.GUID
+	id       uint16
+}
+
+// An ETWCollector is a Prometheus collector for the events of the ETW
+// providers listed in its configuration file, counted or observed by
+// histograms
+type ETWCollector struct {
+	session *etw.Session
+
+	mu     sync.Mutex
+	events map[etwEventKey]*etwEvent
+}
+
+func newETWCollector() (Collector, error) {
+	const subsystem = "etw"
+
+	var config etwConfig
+	if *etwConfigFile != "" {
+		b, err := ioutil.ReadFile(*etwConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, &config); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", *etwConfigFile, err)
+		}
+	}
+
+	c := &ETWCollector{events: make(map[etwEventKey]*etwEvent)}
+	var providers []etw.Provider
+	names := make(map[string]bool)
+	for _, p := range config.Providers {
+		guid, err := parseETWProviderGUID(p.GUID)
+		if err != nil {
+			return nil, err
+		}
+		if p.Level == 0 {
+			p.Level = etwDefaultLevel
+		}
+		providers = append(providers, etw.Provider{GUID: guid, Level: p.Level, MatchAnyKeyword: p.MatchAnyKeyword})
+
+		for _, e := range p.Events {
+			if err := validateETWEvent(e); err != nil {
+				return nil, err
+			}
+			if names[e.Name] {
+				return nil, fmt.Errorf("duplicate ETW event %s", e.Name)
+			}
+			names[e.Name] = true
+			key := etwEventKey{provider: guid, id: e.ID}
+			if _, ok := c.events[key]; ok {
+				return nil, fmt.Errorf("ETW event %s: event %d of provider %s is listed twice", e.Name, e.ID, p.GUID)
+			}
+			if e.Scale == 0 {
+				e.Scale = 1
+			}
+			if e.MaxSeries <= 0 {
+				e.MaxSeries = etwDefaultMaxSeries
+			}
+			sort.Float64s(e.Buckets)
+
+			name, help := e.Name+"_total", fmt.Sprintf("Total events %d of provider %s", e.ID, p.GUID)
+			if e.Value != "" {
+				name, help = e.Name, fmt.Sprintf("Histogram of property %s of the events %d of provider %s", e.Value, e.ID, p.GUID)
+			}
+			if e.Help != "" {
+				help = e.Help
+			}
+			c.events[key] = &etwEvent{
+				config: e,
+				desc: prometheus.NewDesc(
+					prometheus.BuildFQName(Namespace, subsystem, name),
+					help,
+					odbcLabelNames(e.Labels),
+					nil,
+				),
+				series: make(map[string]*etwSeries),
+			}
+		}
+	}
+
+	if len(providers) > 0 {
+		session, err := etw.StartSession(etwSessionName, providers, c.handleEvent)
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+	}
+	return c, nil
+}
+
+// parseETWProviderGUID parses the GUID of a provider, with or without braces.
+func parseETWProviderGUID(s string) (windows.GUID, error) {
+	if !strings.HasPrefix(s, "{") {
+		s = "{" + s + "}"
+	}
+	guid, err := windows.GUIDFromString(s)
+	if err != nil {
+		return windows.GUID{}, fmt.Errorf("invalid ETW provider GUID %s", s)
+	}
+	return guid, nil
+}
+
+func validateETWEvent(e etwEventConfig) error {
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("ETW event %d without name", e.ID)
+	case odbcMetricName(e.Name) != e.Name:
+		return fmt.Errorf("ETW event %s: name must only contain lower case letters, digits and underscores", e.Name)
+	case e.Value == "" && len(e.Buckets) > 0:
+		return fmt.Errorf("ETW event %s: buckets require a value", e.Name)
+	case e.Value != "" && len(e.Buckets) == 0:
+		return fmt.Errorf("ETW event %s: value requires buckets", e.Name)
+	}
+	return nil
+}
+
+// parseETWValue parses a numeric property, which is formatted in decimal or,
+// for some types, in hexadecimal.
+func parseETWValue(s string) (float64, bool) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, true
+	}
+	if v, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func (c *ETWCollector) handleEvent(e *etw.Event) {
+	event, ok := c.events[etwEventKey{provider: e.Provider, id: e.ID}]
+	if !ok {
+		return
+	}
+	var props map[string]string
+	if len(event.config.Labels) > 0 || event.config.Value != "" {
+		var err error
+		if props, err = e.Properties(); err != nil {
+			etwLogger.Debugf("Failed to decode ETW event %d of provider %s: %v", e.ID, e.Provider, err)
+			return
+		}
+	}
+
+	var value float64
+	if event.config.Value != "" {
+		v, ok := parseETWValue(props[event.config.Value])
+		if !ok {
+			etwLogger.Debugf("Ignoring ETW event %d of provider %s with non-numeric %s %q", e.ID, e.Provider, event.config.Value, props[event.config.Value])
+			return
+		}
+		value = v * event.config.Scale
+	}
+	labels := make([]string, len(event.config.Labels))
+	for i, name := range event.config.Labels {
+		labels[i] = props[name]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := event.lookup(labels)
+	s.count++
+	if s.histogram != nil {
+		s.histogram.observe(value)
+	}
+}
+
+// lookup returns the series of the label values, aggregating the values of
+// further series as "other" once there are MaxSeries.
+func (e *etwEvent) lookup(labels []string) *etwSeries {
+	key := strings.Join(labels, "\xff")
+	if s, ok := e.series[key]; ok {
+		return s
+	}
+	if len(e.series) >= e.config.MaxSeries {
+		for i := range labels {
+			labels[i] = etwOtherLabel
+		}
+		key = strings.Join(labels, "\xff")
+		if s, ok := e.series[key]; ok {
+			return s
+		}
+	}
+	s := &etwSeries{labels: labels}
+	if e.config.Value != "" {
+		s.histogram = newETWHistogram(e.config.Buckets)
+	}
+	e.series[key] = s
+	return s
+}
+
+// Close stops the ETW session of the collector.
+func (c *ETWCollector) Close() error {
+	if c.session == nil {
+		return nil
+	}
+	return c.session.Close()
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ETWCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	var metrics []prometheus.Metric
+	for _, e := range c.events {
+		for _, s := range e.series {
+			if s.histogram != nil {
+				metrics = append(metrics, s.histogram.metric(e.desc, s.labels...))
+				continue
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				e.desc,
+				prometheus.CounterValue,
+				s.count,
+				s.labels...,
+			))
+		}
+	}
+	c.mu.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkETWCollector(b *testing.B) {
+	benchmarkCollector(b, "etw", newETWCollector)
+}
+
+func TestValidateETWEvent(t *testing.T) {
+	valid := []etwEventConfig{
+		{ID: 3008, Name: "dns_client_queries", Labels: []string{"QueryType"}},
+		{ID: 3020, Name: "dns_client_query_duration_seconds", Value: "Duration", Buckets: []float64{0.01, 0.1}},
+	}
+	for _, e := range valid {
+		if err := validateETWEvent(e); err != nil {
+			t.Errorf("validateETWEvent(%+v) failed: %v", e, err)
+		}
+	}
+	invalid := []etwEventConfig{
+		{ID: 3008},
+		{ID: 3008, Name: "DNS queries"},
+		{ID: 3008, Name: "dns_client_queries", Buckets: []float64{1}},
+		{ID: 3008, Name: "dns_client_queries", Value: "Duration"},
+	}
+	for _, e := range invalid {
+		if err := validateETWEvent(e); err == nil {
+			t.Errorf("validateETWEvent(%+v) succeeded", e)
+		}
+	}
+}
+
+func TestParseETWValue(t *testing.T) {
+	cases := map[string]float64{"42": 42, "0.5": 0.5, "0x1A": 26}
+	for s, expected := range cases {
+		if got, ok := parseETWValue(s); !ok || got != expected {
+			t.Errorf("parseETWValue(%q) = %v, %v, expected %v", s, got, ok, expected)
+		}
+	}
+	for _, s := range []string{"", "fast"} {
+		if _, ok := parseETWValue(s); ok {
+			t.Errorf("parseETWValue(%q) succeeded", s)
+		}
+	}
+}
+
+func TestETWHistogram(t *testing.T) {
+	h := newETWHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.5, 2} {
+		h.observe(v)
+	}
+	if h.count != 3 || h.sum != 2.55 {
+		t.Errorf("got count %d and sum %v, expected 3 and 2.55", h.count, h.sum)
+	}
+	if expected := map[float64]uint64{0.1: 1, 1: 2}; !reflect.DeepEqual(h.buckets, expected) {
+		t.Errorf("got buckets %v, expected %v", h.buckets, expected)
+	}
+}
+
+func TestETWEventLookup(t *testing.T) {
+	e := &etwEvent{
+		config: etwEventConfig{Labels: []string{"QueryType"}, MaxSeries: 2},
+		series: make(map[string]*etwSeries),
+	}
+	for _, qtype := range []string{"1", "28", "1", "33", "12"} {
+		e.lookup([]string{qtype}).count++
+	}
+	got := make(map[string]float64)
+	for _, s := range e.series {
+		got[s.labels[0]] = s.count
+	}
+	if expected := map[string]float64{"1": 2, "28": 1, "other": 2}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got series %v, expected %v", got, expected)
+	}
+}
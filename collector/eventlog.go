@@ -0,0 +1,273 @@
+// +build windows
+
+package collector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	registerCollector("eventlog", newEventLogCollector)
+}
+
+var eventlogLogger = log.With("collector", "eventlog")
+
+var eventlogConfigFile = kingpin.Flag(
+	"collector.eventlog.config-file",
+	"YAML file listing the event log queries to count the events of.",
+).Default("").String()
+
+// Keywords of the events of the Security log auditing a failed or successful
+// access.
+const (
+	eventlogKeywordAuditFailure = 0x10000000000000
+	eventlogKeywordAuditSuccess = 0x20000000000000
+)
+
+// eventlogQuery is an entry of the queries section of the event log
+// configuration file.
+type eventlogQuery struct {
+	Name    string `yaml:"name"`
+	Channel string `yaml:"channel"`
+	// XPath selects the events of the channel to count, e.g.
+	// *[System[Level<=2]], all of them if empty.
+	XPath string `yaml:"xpath"`
+	// MessageRegex only counts the events whose message matches.
+	MessageRegex string `yaml:"message_regex"`
+}
+
+type eventlogConfig struct {
+	Queries []eventlogQuery `yaml:"queries"`
+}
+
+type eventlogKey struct {
+	provider string
+	level    string
+	eventID  string
+}
+
+// eventlogTail counts the events of a query logged since the collector
+// started, polling the events newer than the last one counted.
+type eventlogTail struct {
+	query   eventlogQuery
+	message *regexp.Regexp
+
+	started  bool
+	recordID uint64
+	counts   map[eventlogKey]float64
+}
+
+// An EventLogCollector is a Prometheus collector for the events logged to
+// event log channels, as selected by the queries of its configuration file
+type EventLogCollector struct {
+	Events *prometheus.Desc
+
+	mu    sync.Mutex
+	tails []*eventlogTail
+}
+
+func newEventLogCollector() (Collector, error) {
+	const subsystem = "eventlog"
+
+	var config eventlogConfig
+	if *eventlogConfigFile != "" {
+		b, err := ioutil.ReadFile(*eventlogConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, &config); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", *eventlogConfigFile, err)
+		}
+	}
+
+	c := &EventLogCollector{
+		Events: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "events_total"),
+			"Total events selected by the query logged since the exporter started",
+			[]string{"query", "channel", "provider", "level", "event_id"},
+			nil,
+		),
+	}
+
+	names := make(map[string]bool)
+	for _, q := range config.Queries {
+		switch {
+		case q.Name == "":
+			return nil, fmt.Errorf("event log query without name")
+		case names[q.Name]:
+			return nil, fmt.Errorf("duplicate event log query %s", q.Name)
+		case q.Channel == "":
+			return nil, fmt.Errorf("event log query %s: channel is required", q.Name)
+		}
+		names[q.Name] = true
+
+		t := &eventlogTail{query: q, counts: make(map[eventlogKey]float64)}
+		if q.MessageRegex != "" {
+			re, err := regexp.Compile(q.MessageRegex)
+			if err != nil {
+				return nil, fmt.Errorf("event log query %s: invalid message_regex: %v", q.Name, err)
+			}
+			t.message = re
+		}
+		// Start counting now rather than on the first scrape, if the
+		// channel can already be read.
+		if err := t.update(); err != nil {
+			eventlogLogger.Warnf("Failed to read event log channel %s of query %s: %v", q.Channel, q.Name, err)
+		}
+		c.tails = append(c.tails, t)
+	}
+	return c, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *EventLogCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.tails {
+		if err := t.update(); err != nil {
+			eventlogLogger.Error("failed collecting eventlog metrics:", t.query.Name, err)
+			return err
+		}
+		for key, n := range t.counts {
+			ch <- prometheus.MustNewConstMetric(
+				c.Events,
+				prometheus.CounterValue,
+				n,
+				t.query.Name,
+				t.query.Channel,
+				key.provider,
+				key.level,
+				key.eventID,
+			)
+		}
+	}
+	return nil
+}
+
+// update counts the events logged since the last update. The first update
+// only records the newest event.
+func (t *eventlogTail) update() error {
+	last, err := wevtapi.LastRecordID(t.query.Channel)
+	if err != nil {
+		return err
+	}
+	if !t.started {
+		t.started = true
+		t.recordID = last
+		return nil
+	}
+	if last < t.recordID {
+		// The log was cleared, and its record IDs started over.
+		t.recordID = 0
+	}
+	if last == t.recordID {
+		return nil
+	}
+
+	q := eventlogStructuredQuery(t.query.Channel, t.query.XPath, t.recordID)
+	if t.message == nil {
+		events, err := wevtapi.Query("", q)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			t.count(e, "")
+		}
+		return nil
+	}
+	events, err := wevtapi.QueryMessages("", q)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		t.count(e.XML, e.Message)
+	}
+	return nil
+}
+
+// eventlogEvent is the system part of an event.
+type eventlogEvent struct {
+	Provider struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"System>Provider"`
+	EventID  string `xml:"System>EventID"`
+	Level    int    `xml:"System>Level"`
+	Keywords string `xml:"System>Keywords"`
+	RecordID uint64 `xml:"System>EventRecordID"`
+}
+
+// count counts the event if its message matches, and advances the record ID
+// past it.
+func (t *eventlogTail) count(eventXML string, message string) {
+	var e eventlogEvent
+	if err := xml.Unmarshal([]byte(eventXML), &e); err != nil {
+		eventlogLogger.Debugf("Ignoring invalid %s event: %v", t.query.Channel, err)
+		return
+	}
+	if e.RecordID > t.recordID {
+		t.recordID = e.RecordID
+	}
+	if t.message != nil && !t.message.MatchString(message) {
+		return
+	}
+	t.counts[eventlogKey{
+		provider: e.Provider.Name,
+		level:    eventlogLevel(e.Level, e.Keywords),
+		eventID:  strings.TrimSpace(e.EventID),
+	}]++
+}
+
+// eventlogLevel returns the level of an event as shown by Event Viewer, e.g.
+// error, or audit_failure for the failed accesses audited in the Security log.
+func eventlogLevel(level int, keywords string) string {
+	k, _ := strconv.ParseUint(strings.TrimPrefix(keywords, "0x"), 16, 64)
+	switch {
+	case k&eventlogKeywordAuditFailure != 0:
+		return "audit_failure"
+	case k&eventlogKeywordAuditSuccess != 0:
+		return "audit_success"
+	}
+	switch level {
+	case 1:
+		return "critical"
+	case 2:
+		return "error"
+	case 3:
+		return "warning"
+	case 5:
+		return "verbose"
+	default:
+		return "information"
+	}
+}
+
+// eventlogStructuredQuery returns a structured XML query for the events of
+// the channel matching the XPath query newer than the record ID.
+// https://docs.microsoft.com/en-us/windows/win32/wes/consuming-events#xml-query-schema
+func eventlogStructuredQuery(channel string, xpath string, recordID uint64) string {
+	if xpath == "" {
+		xpath = "*"
+	}
+	var path, selected bytes.Buffer
+	xml.EscapeText(&path, []byte(channel))
+	xml.EscapeText(&selected, []byte(xpath))
+	return fmt.Sprintf(
+		`<QueryList><Query Id="0"><Select Path="%s">%s</Select><Suppress Path="%s">*[System[EventRecordID&lt;=%d]]</Suppress></Query></QueryList>`,
+		path.String(), selected.String(), path.String(), recordID,
+	)
+}
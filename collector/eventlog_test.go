@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func BenchmarkEventLogCollector(b *testing.B) {
+	benchmarkCollector(b, "eventlog", newEventLogCollector)
+}
+
+func TestEventlogStructuredQuery(t *testing.T) {
+	expected := `<QueryList><Query Id="0"><Select Path="System">*[System[Level&lt;=2]]</Select><Suppress Path="System">*[System[EventRecordID&lt;=42]]</Suppress></Query></QueryList>`
+	if got := eventlogStructuredQuery("System", "*[System[Level<=2]]", 42); got != expected {
+		t.Errorf("Query does not match!\nExpected result: %s\nActual result: %s", expected, got)
+	}
+	expected = `<QueryList><Query Id="0"><Select Path="Microsoft-Windows-PowerShell/Operational">*</Select><Suppress Path="Microsoft-Windows-PowerShell/Operational">*[System[EventRecordID&lt;=0]]</Suppress></Query></QueryList>`
+	if got := eventlogStructuredQuery("Microsoft-Windows-PowerShell/Operational", "", 0); got != expected {
+		t.Errorf("Query does not match!\nExpected result: %s\nActual result: %s", expected, got)
+	}
+}
+
+func TestEventlogTailCount(t *testing.T) {
+	event := func(provider, eventID, level, keywords, recordID string) string {
+		return `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System>` +
+			`<Provider Name="` + provider + `"/><EventID Qualifiers="49152">` + eventID + `</EventID>` +
+			`<Level>` + level + `</Level><Keywords>` + keywords + `</Keywords>` +
+			`<EventRecordID>` + recordID + `</EventRecordID></System></Event>`
+	}
+	tail := &eventlogTail{
+		query:    eventlogQuery{Name: "crashes", Channel: "System"},
+		message:  regexp.MustCompile(`terminated unexpectedly|failed to log on`),
+		recordID: 100,
+		counts:   make(map[eventlogKey]float64),
+	}
+	tail.count(event("Service Control Manager", "7034", "2", "0x8080000000000000", "101"), "The Print Spooler service terminated unexpectedly.")
+	tail.count(event("Service Control Manager", "7034", "2", "0x8080000000000000", "103"), "The Print Spooler service terminated unexpectedly.")
+	tail.count(event("Service Control Manager", "7036", "4", "0x8080000000000000", "105"), "The Print Spooler service entered the running state.")
+	tail.count(event("Microsoft-Windows-Security-Auditing", "4625", "0", "0x8010000000000000", "102"), "An account failed to log on.")
+
+	expected := map[eventlogKey]float64{
+		{provider: "Service Control Manager", level: "error", eventID: "7034"}:                     2,
+		{provider: "Microsoft-Windows-Security-Auditing", level: "audit_failure", eventID: "4625"}: 1,
+	}
+	if !reflect.DeepEqual(tail.counts, expected) {
+		t.Errorf("Counts do not match!\nExpected result: %+v\nActual result: %+v", expected, tail.counts)
+	}
+	if tail.recordID != 105 {
+		t.Errorf("Record ID does not match!\nExpected result: %d\nActual result: %d", 105, tail.recordID)
+	}
+}
@@ -23,9 +23,13 @@ func init() {
 		"MSExchangeAutodiscover",
 		"MSExchange WorkloadManagement Workloads",
 		"MSExchange RpcClientAccess",
+		"MSExchangeTransport Resource Manager",
+		"MSExchangeTransport Safety Net",
 	)
 }
 
+var exchangeLogger = log.With("collector", "exchange")
+
 type exchangeCollector struct {
 	LDAPReadTime                            *prometheus.Desc
 	LDAPSearchTime                          *prometheus.Desc
@@ -64,6 +68,8 @@ type exchangeCollector struct {
 	ConnectionCount                         *prometheus.Desc
 	RPCOperationsPerSec                     *prometheus.Desc
 	UserCount                               *prometheus.Desc
+	TransportBackPressureState              *prometheus.Desc
+	TransportSafetyNetMessages              *prometheus.Desc
 
 	enabledCollectors []string
 }
@@ -80,6 +86,8 @@ var (
 		"Autodiscover",
 		"WorkloadManagement",
 		"RpcClientAccess",
+		"TransportBackPressure",
+		"TransportSafetyNet",
 	}
 
 	argExchangeListAllCollectors = kingpin.Flag(
@@ -144,20 +152,24 @@ func newExchangeCollector() (Collector, error) {
 		MailboxServerProxyFailureRate:           desc("http_proxy_mailbox_proxy_failure_rate", "% of failures between this CAS and MBX servers over the last 200 samples", "name"),
 		PingCommandsPending:                     desc("activesync_ping_cmds_pending", "Number of ping commands currently pending in the queue"),
 		SyncCommandsPerSec:                      desc("activesync_sync_cmds_total", "Number of sync commands processed per second. Clients use this command to synchronize items within a folder"),
+		TransportBackPressureState:              desc("transport_back_pressure_state", "Back pressure state of a resource monitored by the transport service (1 for the current state, 0 for the others)", "resource", "state"),
+		TransportSafetyNetMessages:              desc("transport_safety_net_messages", "Number of delivered messages kept in Safety Net for redelivery", "name"),
 
 		enabledCollectors: make([]string, 0, len(exchangeAllCollectorNames)),
 	}
 
 	collectorDesc := map[string]string{
-		"ADAccessProcesses":   "[19108] MSExchange ADAccess Processes",
-		"TransportQueues":     "[20524] MSExchangeTransport Queues",
-		"HttpProxy":           "[36934] MSExchange HttpProxy",
-		"ActiveSync":          "[25138] MSExchange ActiveSync",
-		"AvailabilityService": "[24914] MSExchange Availability Service",
-		"OutlookWebAccess":    "[24618] MSExchange OWA",
-		"Autodiscover":        "[29240] MSExchange Autodiscover",
-		"WorkloadManagement":  "[19430] MSExchange WorkloadManagement Workloads",
-		"RpcClientAccess":     "[29336] MSExchange RpcClientAccess",
+		"ADAccessProcesses":     "[19108] MSExchange ADAccess Processes",
+		"TransportQueues":       "[20524] MSExchangeTransport Queues",
+		"HttpProxy":             "[36934] MSExchange HttpProxy",
+		"ActiveSync":            "[25138] MSExchange ActiveSync",
+		"AvailabilityService":   "[24914] MSExchange Availability Service",
+		"OutlookWebAccess":      "[24618] MSExchange OWA",
+		"Autodiscover":          "[29240] MSExchange Autodiscover",
+		"WorkloadManagement":    "[19430] MSExchange WorkloadManagement Workloads",
+		"RpcClientAccess":       "[29336] MSExchange RpcClientAccess",
+		"TransportBackPressure": "MSExchangeTransport Resource Manager",
+		"TransportSafetyNet":    "MSExchangeTransport Safety Net",
 	}
 
 	if *argExchangeListAllCollectors {
@@ -189,20 +201,22 @@ func newExchangeCollector() (Collector, error) {
 func (c *exchangeCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 
 	collectorFuncs := map[string]func(ctx *ScrapeContext, ch chan<- prometheus.Metric) error{
-		"ADAccessProcesses":   c.collectADAccessProcesses,
-		"TransportQueues":     c.collectTransportQueues,
-		"HttpProxy":           c.collectHTTPProxy,
-		"ActiveSync":          c.collectActiveSync,
-		"AvailabilityService": c.collectAvailabilityService,
-		"OutlookWebAccess":    c.collectOWA,
-		"Autodiscover":        c.collectAutoDiscover,
-		"WorkloadManagement":  c.collectWorkloadManagementWorkloads,
-		"RpcClientAccess":     c.collectRPC,
+		"ADAccessProcesses":     c.collectADAccessProcesses,
+		"TransportQueues":       c.collectTransportQueues,
+		"HttpProxy":             c.collectHTTPProxy,
+		"ActiveSync":            c.collectActiveSync,
+		"AvailabilityService":   c.collectAvailabilityService,
+		"OutlookWebAccess":      c.collectOWA,
+		"Autodiscover":          c.collectAutoDiscover,
+		"WorkloadManagement":    c.collectWorkloadManagementWorkloads,
+		"RpcClientAccess":       c.collectRPC,
+		"TransportBackPressure": c.collectTransportBackPressure,
+		"TransportSafetyNet":    c.collectTransportSafetyNet,
 	}
 
 	for _, collectorName := range c.enabledCollectors {
 		if err := collectorFuncs[collectorName](ctx, ch); err != nil {
-			log.Errorf("Error in %s: %s", collectorName, err)
+			exchangeLogger.Errorf("Error in %s: %s", collectorName, err)
 			return err
 		}
 	}
@@ -222,7 +236,7 @@ type perflibADAccessProcesses struct {
 
 func (c *exchangeCollector) collectADAccessProcesses(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibADAccessProcesses
-	if err := unmarshalObject(ctx.perfObjects["MSExchange ADAccess Processes"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange ADAccess Processes"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -280,7 +294,7 @@ type perflibAvailabilityService struct {
 
 func (c *exchangeCollector) collectAvailabilityService(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibAvailabilityService
-	if err := unmarshalObject(ctx.perfObjects["MSExchange Availability Service"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange Availability Service"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -308,7 +322,7 @@ type perflibHTTPProxy struct {
 
 func (c *exchangeCollector) collectHTTPProxy(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibHTTPProxy
-	if err := unmarshalObject(ctx.perfObjects["MSExchange HttpProxy"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange HttpProxy"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -362,7 +376,7 @@ type perflibOWA struct {
 
 func (c *exchangeCollector) collectOWA(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibOWA
-	if err := unmarshalObject(ctx.perfObjects["MSExchange OWA"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange OWA"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -390,7 +404,7 @@ type perflibActiveSync struct {
 
 func (c *exchangeCollector) collectActiveSync(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibActiveSync
-	if err := unmarshalObject(ctx.perfObjects["MSExchange ActiveSync"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange ActiveSync"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -426,7 +440,7 @@ type perflibRPCClientAccess struct {
 
 func (c *exchangeCollector) collectRPC(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibRPCClientAccess
-	if err := unmarshalObject(ctx.perfObjects["MSExchange RpcClientAccess"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange RpcClientAccess"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -482,7 +496,7 @@ type perflibTransportQueues struct {
 
 func (c *exchangeCollector) collectTransportQueues(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibTransportQueues
-	if err := unmarshalObject(ctx.perfObjects["MSExchangeTransport Queues"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchangeTransport Queues"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -543,6 +557,83 @@ func (c *exchangeCollector) collectTransportQueues(ctx *ScrapeContext, ch chan<-
 	return nil
 }
 
+// exchangeBackPressureStates are the pressure levels of a resource monitored
+// by the transport service, ordered by their value in perflib. At medium
+// pressure, connections from other servers are rejected; at high pressure,
+// all connections are.
+var exchangeBackPressureStates = []string{"normal", "medium", "high"}
+
+// Perflib: MSExchangeTransport Resource Manager
+type perflibTransportResourceManager struct {
+	Name string
+
+	ResourcePressure float64 `perflib:"Resource Pressure"`
+}
+
+func (c *exchangeCollector) collectTransportBackPressure(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	// The object only exists on servers running the transport service.
+	if ctx.perfObjects["MSExchangeTransport Resource Manager"] == nil {
+		return nil
+	}
+	var data []perflibTransportResourceManager
+	if err := unmarshalObject(ctx.perfObjects["MSExchangeTransport Resource Manager"], &data, ctx.logger()); err != nil {
+		return err
+	}
+
+	for _, resource := range data {
+		labelName := c.toLabelName(resource.Name)
+		if strings.HasSuffix(labelName, "_total") {
+			continue
+		}
+		for i, state := range exchangeBackPressureStates {
+			isCurrentState := 0.0
+			if int(resource.ResourcePressure) == i {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.TransportBackPressureState,
+				prometheus.GaugeValue,
+				isCurrentState,
+				labelName,
+				state,
+			)
+		}
+	}
+	return nil
+}
+
+// Perflib: MSExchangeTransport Safety Net
+type perflibTransportSafetyNet struct {
+	Name string
+
+	MessageCount float64 `perflib:"Message Count"`
+}
+
+func (c *exchangeCollector) collectTransportSafetyNet(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	// The object only exists on servers running the transport service.
+	if ctx.perfObjects["MSExchangeTransport Safety Net"] == nil {
+		return nil
+	}
+	var data []perflibTransportSafetyNet
+	if err := unmarshalObject(ctx.perfObjects["MSExchangeTransport Safety Net"], &data, ctx.logger()); err != nil {
+		return err
+	}
+
+	for _, safetyNet := range data {
+		labelName := c.toLabelName(safetyNet.Name)
+		if strings.HasSuffix(labelName, "_total") {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.TransportSafetyNetMessages,
+			prometheus.GaugeValue,
+			safetyNet.MessageCount,
+			labelName,
+		)
+	}
+	return nil
+}
+
 // Perflib: [19430] MSExchange WorkloadManagement Workloads
 type perflibWorkloadManagementWorkloads struct {
 	Name string
@@ -556,7 +647,7 @@ type perflibWorkloadManagementWorkloads struct {
 
 func (c *exchangeCollector) collectWorkloadManagementWorkloads(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibWorkloadManagementWorkloads
-	if err := unmarshalObject(ctx.perfObjects["MSExchange WorkloadManagement Workloads"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchange WorkloadManagement Workloads"], &data, ctx.logger()); err != nil {
 		return err
 	}
 
@@ -607,7 +698,7 @@ type perflibAutodiscover struct {
 
 func (c *exchangeCollector) collectAutoDiscover(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	var data []perflibAutodiscover
-	if err := unmarshalObject(ctx.perfObjects["MSExchangeAutodiscover"], &data); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["MSExchangeAutodiscover"], &data, ctx.logger()); err != nil {
 		return err
 	}
 	for _, autodisc := range data {
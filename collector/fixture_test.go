@@ -0,0 +1,329 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/StackExchange/wmi"
+	"github.com/leoluk/perflib_exporter/perflib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	recordFixtures = flag.Bool(
+		"fixtures.record",
+		false,
+		"Record the fixtures of the collectors in -fixtures.collectors on this machine, overwriting the recorded ones.",
+	)
+	fixtureCollectors = flag.String(
+		"fixtures.collectors",
+		"",
+		"Comma-separated list of collectors to record the fixtures of.",
+	)
+)
+
+// fixtureDir holds the fixture of each collector as <collector>.json and the
+// metrics it produced when recorded as <collector>.prom.
+const fixtureDir = "testdata/fixtures"
+
+// A fixture holds the raw performance counters and WMI responses a collector
+// read during a scrape.
+type fixture struct {
+	PerfObjects map[string]fixturePerfObject `json:"perf_objects,omitempty"`
+	// WMI holds the rows returned by each query, keyed by wmiFixtureKey.
+	WMI map[string]json.RawMessage `json:"wmi,omitempty"`
+}
+
+type fixturePerfObject struct {
+	Frequency int64                   `json:"frequency"`
+	Counters  []fixturePerfCounterDef `json:"counters"`
+	Instances []fixturePerfInstance   `json:"instances"`
+}
+
+type fixturePerfCounterDef struct {
+	Name                string `json:"name"`
+	CounterType         uint32 `json:"type"`
+	IsCounter           bool   `json:"is_counter,omitempty"`
+	IsBaseValue         bool   `json:"is_base_value,omitempty"`
+	IsNanosecondCounter bool   `json:"is_nanosecond_counter,omitempty"`
+}
+
+type fixturePerfInstance struct {
+	Name string `json:"name,omitempty"`
+	// Values holds the raw value of each of the object's counters, in order.
+	Values []int64 `json:"values"`
+}
+
+func wmiFixtureKey(query string, connectServerArgs ...interface{}) string {
+	if len(connectServerArgs) == 0 {
+		return query
+	}
+	return fmt.Sprintf("%s %v", query, connectServerArgs)
+}
+
+func newFixturePerfObject(obj *perflib.PerfObject) (fixturePerfObject, error) {
+	f := fixturePerfObject{Frequency: obj.Frequency}
+	index := make(map[*perflib.PerfCounterDef]int, len(obj.CounterDefs))
+	for i, def := range obj.CounterDefs {
+		index[def] = i
+		f.Counters = append(f.Counters, fixturePerfCounterDef{
+			Name:                def.Name,
+			CounterType:         def.CounterType,
+			IsCounter:           def.IsCounter,
+			IsBaseValue:         def.IsBaseValue,
+			IsNanosecondCounter: def.IsNanosecondCounter,
+		})
+	}
+	for _, instance := range obj.Instances {
+		values := make([]int64, len(obj.CounterDefs))
+		for _, ctr := range instance.Counters {
+			i, ok := index[ctr.Def]
+			if !ok {
+				return f, fmt.Errorf("counter %q of %q isn't defined by the object", ctr.Def.Name, obj.Name)
+			}
+			values[i] = ctr.Value
+		}
+		f.Instances = append(f.Instances, fixturePerfInstance{Name: instance.Name, Values: values})
+	}
+	return f, nil
+}
+
+func (f fixturePerfObject) object(name string) *perflib.PerfObject {
+	obj := &perflib.PerfObject{Name: name, Frequency: f.Frequency}
+	for _, def := range f.Counters {
+		obj.CounterDefs = append(obj.CounterDefs, &perflib.PerfCounterDef{
+			Name:                def.Name,
+			CounterType:         def.CounterType,
+			IsCounter:           def.IsCounter,
+			IsBaseValue:         def.IsBaseValue,
+			IsNanosecondCounter: def.IsNanosecondCounter,
+		})
+	}
+	for _, instance := range f.Instances {
+		pi := &perflib.PerfInstance{Name: instance.Name}
+		for i, def := range obj.CounterDefs {
+			pi.Counters = append(pi.Counters, &perflib.PerfCounter{Value: instance.Values[i], Def: def})
+		}
+		obj.Instances = append(obj.Instances, pi)
+	}
+	return obj
+}
+
+// scrapeContext returns a scrape context holding the recorded performance
+// counters.
+func (f *fixture) scrapeContext() *ScrapeContext {
+	ctx := &ScrapeContext{
+		perfObjects: make(map[string]*perflib.PerfObject, len(f.PerfObjects)),
+	}
+	for name, obj := range f.PerfObjects {
+		ctx.perfObjects[name] = obj.object(name)
+	}
+	return ctx
+}
+
+// replayWMI replaces the WMI queries with the recorded responses until the
+// returned function is called. Queries that weren't recorded fail.
+func (f *fixture) replayWMI() func() {
+	replay := func(query string, dst interface{}, connectServerArgs ...interface{}) error {
+		rows, ok := f.WMI[wmiFixtureKey(query, connectServerArgs...)]
+		if !ok {
+			return fmt.Errorf("no recorded response for WMI query %q", query)
+		}
+		return json.Unmarshal(rows, dst)
+	}
+	return swapWMI(
+		replay,
+		func(query string, dst interface{}, namespace string) error {
+			return replay(query, dst, nil, namespace)
+		},
+	)
+}
+
+// recordWMI records the responses of the WMI queries until the returned
+// function is called.
+func (f *fixture) recordWMI() func() {
+	record := func(query string, dst interface{}, connectServerArgs ...interface{}) error {
+		if err := wmi.Query(query, dst, connectServerArgs...); err != nil {
+			return err
+		}
+		rows, err := json.Marshal(dst)
+		if err != nil {
+			return err
+		}
+		f.WMI[wmiFixtureKey(query, connectServerArgs...)] = rows
+		return nil
+	}
+	return swapWMI(
+		record,
+		func(query string, dst interface{}, namespace string) error {
+			return record(query, dst, nil, namespace)
+		},
+	)
+}
+
+func swapWMI(query func(string, interface{}, ...interface{}) error, queryNamespace func(string, interface{}, string) error) func() {
+	origQuery, origQueryNamespace := queryWMI, queryWMINamespace
+	queryWMI, queryWMINamespace = query, queryNamespace
+	return func() {
+		queryWMI, queryWMINamespace = origQuery, origQueryNamespace
+	}
+}
+
+// fixtureMetrics returns the metrics of a scrape of the collector in the text
+// format, without help texts as those of performance counters are localized.
+func fixtureMetrics(c Collector, ctx *ScrapeContext) ([]byte, error) {
+	reg := prometheus.NewRegistry()
+	adapter := &fixtureCollector{c: c, ctx: ctx}
+	if err := reg.Register(adapter); err != nil {
+		return nil, err
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	if adapter.err != nil {
+		return nil, adapter.err
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		mf.Help = nil
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// fixtureCollector adapts a Collector to prometheus.Collector for a single
+// scrape.
+type fixtureCollector struct {
+	c   Collector
+	ctx *ScrapeContext
+	err error
+}
+
+// Describe sends no descriptors, so the collector is unchecked.
+func (f *fixtureCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f *fixtureCollector) Collect(ch chan<- prometheus.Metric) {
+	f.err = f.c.Collect(f.ctx, ch)
+}
+
+func recordFixture(name string) error {
+	c, err := Build(name)
+	if err != nil {
+		return err
+	}
+
+	f := &fixture{
+		PerfObjects: make(map[string]fixturePerfObject),
+		WMI:         make(map[string]json.RawMessage),
+	}
+	ctx, err := PrepareScrapeContext([]string{name})
+	if err != nil {
+		return err
+	}
+	for _, objName := range perfCounterSetNames[name] {
+		obj, ok := ctx.perfObjects[objName]
+		if !ok {
+			continue
+		}
+		if f.PerfObjects[objName], err = newFixturePerfObject(obj); err != nil {
+			return err
+		}
+	}
+
+	restore := f.recordWMI()
+	metrics, err := fixtureMetrics(c, ctx)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fixtureDir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(fixtureDir, name+".json"), data, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(fixtureDir, name+".prom"), metrics, 0644)
+}
+
+// TestCollectorFixtures replays the recorded fixture of every collector, and
+// compares the metrics to those produced when it was recorded. Collectors
+// without a fixture are skipped. Only the performance counters and WMI
+// responses are replayed; collectors also reading other local APIs, or metrics
+// depending on the current time, need a fixture recorded on a machine where
+// those are stable.
+//
+// To record fixtures on a live machine:
+//
+//	go test ./collector/ -run TestCollectorFixtures -fixtures.record -fixtures.collectors cpu,memory
+func TestCollectorFixtures(t *testing.T) {
+	// Whitelists are not set in testing context (kingpin flags not parsed),
+	// causing the collectors to skip all instances, so set their defaults.
+	defaultVolumeWhitelist, defaultNicWhitelist, defaultProcessWhitelist := ".+", ".+", ".*"
+	origVolumeWhitelist, origNicWhitelist, origProcessWhitelist := volumeWhitelist, nicWhitelist, processWhitelist
+	volumeWhitelist, nicWhitelist, processWhitelist = &defaultVolumeWhitelist, &defaultNicWhitelist, &defaultProcessWhitelist
+	defer func() {
+		volumeWhitelist, nicWhitelist, processWhitelist = origVolumeWhitelist, origNicWhitelist, origProcessWhitelist
+	}()
+
+	if *recordFixtures {
+		for _, name := range expandEnabledChildCollectors(*fixtureCollectors) {
+			if err := recordFixture(name); err != nil {
+				t.Fatalf("failed to record fixture of %s: %v", name, err)
+			}
+		}
+	}
+
+	names := Available()
+	sort.Strings(names)
+	for _, name := range names {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join(fixtureDir, name+".json"))
+			if os.IsNotExist(err) {
+				t.Skip("no fixture recorded")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f fixture
+			if err := json.Unmarshal(data, &f); err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(filepath.Join(fixtureDir, name+".prom"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := Build(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restore := f.replayWMI()
+			defer restore()
+			got, err := fixtureMetrics(c, f.scrapeContext())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("metrics differ from the recorded ones\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -10,18 +9,18 @@ func init() {
 	registerCollector("fsrmquota", newFSRMQuotaCollector)
 }
 
+var fsrmquotaLogger = log.With("collector", "fsrmquota")
+
 type FSRMQuotaCollector struct {
 	QuotasCount *prometheus.Desc
-	Path        *prometheus.Desc
+	Information *prometheus.Desc
 	PeakUsage   *prometheus.Desc
 	Size        *prometheus.Desc
 	Usage       *prometheus.Desc
 
-	Description     *prometheus.Desc
 	Disabled        *prometheus.Desc
 	MatchesTemplate *prometheus.Desc
 	SoftLimit       *prometheus.Desc
-	Template        *prometheus.Desc
 }
 
 func newFSRMQuotaCollector() (Collector, error) {
@@ -33,52 +32,46 @@ func newFSRMQuotaCollector() (Collector, error) {
 			nil,
 			nil,
 		),
+		Information: newInfoDesc(
+			subsystem,
+			"A metric with a constant '1' value labeled with the template and description of the quota (Template, Description)",
+			[]string{"path"},
+			"template", "description",
+		),
 		PeakUsage: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "peak_usage_bytes"),
 			"The highest amount of disk space usage charged to this quota. (PeakUsage)",
-			[]string{"path", "template"},
+			[]string{"path"},
 			nil,
 		),
 		Size: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "size_bytes"),
 			"The size of the quota. (Size)",
-			[]string{"path", "template"},
+			[]string{"path"},
 			nil,
 		),
 		Usage: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "usage_bytes"),
 			"The current amount of disk space usage charged to this quota. (Usage)",
-			[]string{"path", "template"},
-			nil,
-		),
-		Description: prometheus.NewDesc(
-			prometheus.BuildFQName(Namespace, subsystem, "description"),
-			"Description of the quota (Description)",
-			[]string{"path", "template", "description"},
+			[]string{"path"},
 			nil,
 		),
 		Disabled: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "disabled"),
 			"If 1, the quota is disabled. The default value is 0. (Disabled)",
-			[]string{"path", "template"},
+			[]string{"path"},
 			nil,
 		),
 		SoftLimit: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "softlimit"),
 			"If 1, the quota is a soft limit. If 0, the quota is a hard limit. The default value is 0. Optional (SoftLimit)",
-			[]string{"path", "template"},
-			nil,
-		),
-		Template: prometheus.NewDesc(
-			prometheus.BuildFQName(Namespace, subsystem, "template"),
-			"Quota template name. (Template)",
-			[]string{"path", "template"},
+			[]string{"path"},
 			nil,
 		),
 		MatchesTemplate: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "matchestemplate"),
 			"If 1, the property values of this quota match those values of the template from which it was derived. (MatchesTemplate)",
-			[]string{"path", "template"},
+			[]string{"path"},
 			nil,
 		),
 	}, nil
@@ -88,7 +81,7 @@ func newFSRMQuotaCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *FSRMQuotaCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting fsrmquota metrics:", desc, err)
+		fsrmquotaLogger.Error("failed collecting fsrmquota metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -117,7 +110,7 @@ func (c *FSRMQuotaCollector) collect(ch chan<- prometheus.Metric) (*prometheus.D
 
 	var count int
 
-	if err := wmi.QueryNamespace(q, &dst, "root/microsoft/windows/fsrm"); err != nil {
+	if err := queryWMINamespace(q, &dst, "root/microsoft/windows/fsrm"); err != nil {
 		return nil, err
 	}
 
@@ -125,56 +118,49 @@ func (c *FSRMQuotaCollector) collect(ch chan<- prometheus.Metric) (*prometheus.D
 
 		count++
 		path := quota.Path
-		template := quota.Template
-		Description := quota.Description
+
+		ch <- newInfoMetric(
+			c.Information,
+			path,
+			quota.Template,
+			quota.Description,
+		)
 
 		ch <- prometheus.MustNewConstMetric(
 			c.PeakUsage,
 			prometheus.GaugeValue,
 			float64(quota.PeakUsage),
 			path,
-			template,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			c.Size,
 			prometheus.GaugeValue,
 			float64(quota.Size),
 			path,
-			template,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			c.Usage,
 			prometheus.GaugeValue,
 			float64(quota.Usage),
 			path,
-			template,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			c.Description,
-			prometheus.GaugeValue,
-			1.0,
-			path, template, Description,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			c.Disabled,
 			prometheus.GaugeValue,
 			boolToFloat(quota.Disabled),
 			path,
-			template,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			c.MatchesTemplate,
 			prometheus.GaugeValue,
 			boolToFloat(quota.MatchesTemplate),
 			path,
-			template,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			c.SoftLimit,
 			prometheus.GaugeValue,
 			boolToFloat(quota.SoftLimit),
 			path,
-			template,
 		)
 	}
 
@@ -0,0 +1,269 @@
+// +build windows
+
+package collector
+
+import (
+	"regexp"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("gpu", newGPUCollector, "GPU Engine", "GPU Adapter Memory", "GPU Process Memory")
+}
+
+var gpuLogger = log.With("collector", "gpu")
+
+// A GPUCollector is a Prometheus collector for the usage of the engines and
+// memory of the GPUs, as scheduled by WDDM on Windows 10 1709, Windows Server
+// 2019 and later.
+type GPUCollector struct {
+	EngineTime             *prometheus.Desc
+	AdapterDedicatedMemory *prometheus.Desc
+	AdapterSharedMemory    *prometheus.Desc
+	AdapterCommittedMemory *prometheus.Desc
+	ProcessDedicatedMemory *prometheus.Desc
+	ProcessSharedMemory    *prometheus.Desc
+	ProcessCommittedMemory *prometheus.Desc
+}
+
+func newGPUCollector() (Collector, error) {
+	const subsystem = "gpu"
+
+	return &GPUCollector{
+		EngineTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "engine_time_seconds_total"),
+			"Time the GPU engine was busy, summed over the processes currently using it",
+			[]string{"luid", "phys_index", "eng_index", "engine_type"},
+			nil,
+		),
+		AdapterDedicatedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "adapter_memory_dedicated_bytes"),
+			"Dedicated memory of the GPU adapter in use",
+			[]string{"luid", "phys_index"},
+			nil,
+		),
+		AdapterSharedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "adapter_memory_shared_bytes"),
+			"System memory shared with the GPU adapter in use",
+			[]string{"luid", "phys_index"},
+			nil,
+		),
+		AdapterCommittedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "adapter_memory_committed_bytes"),
+			"Memory committed to the GPU adapter",
+			[]string{"luid", "phys_index"},
+			nil,
+		),
+		ProcessDedicatedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "process_memory_dedicated_bytes"),
+			"Dedicated memory of the GPU adapter used by the process",
+			[]string{"luid", "phys_index", "process_id"},
+			nil,
+		),
+		ProcessSharedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "process_memory_shared_bytes"),
+			"System memory shared with the GPU adapter used by the process",
+			[]string{"luid", "phys_index", "process_id"},
+			nil,
+		),
+		ProcessCommittedMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "process_memory_committed_bytes"),
+			"Memory of the GPU adapter committed by the process",
+			[]string{"luid", "phys_index", "process_id"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *GPUCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectEngines(ctx, ch); err != nil {
+		gpuLogger.Error("failed collecting gpu engine metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectAdapterMemory(ctx, ch); err != nil {
+		gpuLogger.Error("failed collecting gpu adapter memory metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectProcessMemory(ctx, ch); err != nil {
+		gpuLogger.Error("failed collecting gpu process memory metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// gpuInstancePattern matches the instance names of the GPU objects, e.g.
+// pid_1234_luid_0x00000000_0x0000C2A8_phys_0_eng_3_engtype_VideoDecode for an
+// engine used by a process, or luid_0x00000000_0x0000C2A8_phys_0 for an adapter.
+var gpuInstancePattern = regexp.MustCompile(`^(?:pid_(\d+)_)?luid_(0x[0-9A-Fa-f]+_0x[0-9A-Fa-f]+)_phys_(\d+)(?:_eng_(\d+)_engtype_(.*))?$`)
+
+// gpuInstance holds the parts of the name of an instance of the GPU objects.
+// Parts not in the name are empty.
+type gpuInstance struct {
+	pid        string
+	luid       string
+	phys       string
+	eng        string
+	engineType string
+}
+
+func parseGPUInstance(name string) (gpuInstance, bool) {
+	m := gpuInstancePattern.FindStringSubmatch(name)
+	if m == nil {
+		return gpuInstance{}, false
+	}
+	return gpuInstance{pid: m[1], luid: m[2], phys: m[3], eng: m[4], engineType: m[5]}, true
+}
+
+type gpuEngine struct {
+	Name string
+
+	RunningTime float64 `perflib:"Running Time"`
+}
+
+func (c *GPUCollector) collectEngines(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The objects don't exist before Windows 10 1709, nor without a WDDM 2.x driver.
+	if ctx.perfObjects["GPU Engine"] == nil {
+		return nil, nil
+	}
+	var dst []gpuEngine
+	if err := unmarshalObject(ctx.perfObjects["GPU Engine"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	// The engines have an instance per process using them, which are summed
+	// so the series of an engine don't come and go with processes.
+	engines := make(map[gpuInstance]float64)
+	var order []gpuInstance
+	for _, engine := range dst {
+		inst, ok := parseGPUInstance(engine.Name)
+		if !ok || inst.eng == "" {
+			gpuLogger.Debugf("Skipping GPU engine instance %q", engine.Name)
+			continue
+		}
+		inst.pid = ""
+		if _, exists := engines[inst]; !exists {
+			order = append(order, inst)
+		}
+		engines[inst] += engine.RunningTime
+	}
+
+	for _, inst := range order {
+		ch <- prometheus.MustNewConstMetric(
+			c.EngineTime,
+			prometheus.CounterValue,
+			engines[inst]*ticksToSecondsScaleFactor,
+			inst.luid,
+			inst.phys,
+			inst.eng,
+			inst.engineType,
+		)
+	}
+	return nil, nil
+}
+
+type gpuAdapterMemory struct {
+	Name string
+
+	DedicatedUsage float64 `perflib:"Dedicated Usage"`
+	SharedUsage    float64 `perflib:"Shared Usage"`
+	TotalCommitted float64 `perflib:"Total Committed"`
+}
+
+func (c *GPUCollector) collectAdapterMemory(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	if ctx.perfObjects["GPU Adapter Memory"] == nil {
+		return nil, nil
+	}
+	var dst []gpuAdapterMemory
+	if err := unmarshalObject(ctx.perfObjects["GPU Adapter Memory"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, adapter := range dst {
+		inst, ok := parseGPUInstance(adapter.Name)
+		if !ok {
+			gpuLogger.Debugf("Skipping GPU adapter memory instance %q", adapter.Name)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.AdapterDedicatedMemory,
+			prometheus.GaugeValue,
+			adapter.DedicatedUsage,
+			inst.luid,
+			inst.phys,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.AdapterSharedMemory,
+			prometheus.GaugeValue,
+			adapter.SharedUsage,
+			inst.luid,
+			inst.phys,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.AdapterCommittedMemory,
+			prometheus.GaugeValue,
+			adapter.TotalCommitted,
+			inst.luid,
+			inst.phys,
+		)
+	}
+	return nil, nil
+}
+
+type gpuProcessMemory struct {
+	Name string
+
+	DedicatedUsage float64 `perflib:"Dedicated Usage"`
+	SharedUsage    float64 `perflib:"Shared Usage"`
+	TotalCommitted float64 `perflib:"Total Committed"`
+}
+
+func (c *GPUCollector) collectProcessMemory(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	if ctx.perfObjects["GPU Process Memory"] == nil {
+		return nil, nil
+	}
+	var dst []gpuProcessMemory
+	if err := unmarshalObject(ctx.perfObjects["GPU Process Memory"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, process := range dst {
+		inst, ok := parseGPUInstance(process.Name)
+		if !ok || inst.pid == "" {
+			gpuLogger.Debugf("Skipping GPU process memory instance %q", process.Name)
+			continue
+		}
+		// Processes that only opened the adapter have no memory on it.
+		if process.TotalCommitted == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ProcessDedicatedMemory,
+			prometheus.GaugeValue,
+			process.DedicatedUsage,
+			inst.luid,
+			inst.phys,
+			inst.pid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ProcessSharedMemory,
+			prometheus.GaugeValue,
+			process.SharedUsage,
+			inst.luid,
+			inst.phys,
+			inst.pid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ProcessCommittedMemory,
+			prometheus.GaugeValue,
+			process.TotalCommitted,
+			inst.luid,
+			inst.phys,
+			inst.pid,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkGPUCollector(b *testing.B) {
+	benchmarkCollector(b, "gpu", newGPUCollector)
+}
+
+func TestParseGPUInstance(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected gpuInstance
+		ok       bool
+	}{
+		{
+			"pid_1234_luid_0x00000000_0x0000C2A8_phys_0_eng_3_engtype_VideoDecode",
+			gpuInstance{pid: "1234", luid: "0x00000000_0x0000C2A8", phys: "0", eng: "3", engineType: "VideoDecode"},
+			true,
+		},
+		{
+			"pid_88_luid_0x00000000_0x0000C2A8_phys_1_eng_12_engtype_Compute_0",
+			gpuInstance{pid: "88", luid: "0x00000000_0x0000C2A8", phys: "1", eng: "12", engineType: "Compute_0"},
+			true,
+		},
+		{
+			"pid_1234_luid_0x00000000_0x0000C2A8_phys_0",
+			gpuInstance{pid: "1234", luid: "0x00000000_0x0000C2A8", phys: "0"},
+			true,
+		},
+		{
+			"luid_0x00000000_0x0000C2A8_phys_0",
+			gpuInstance{luid: "0x00000000_0x0000C2A8", phys: "0"},
+			true,
+		},
+		{"_Total", gpuInstance{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseGPUInstance(c.name)
+		if ok != c.ok || got != c.expected {
+			t.Errorf("Instance %s does not match!\nExpected result: %+v (%t)\nActual result: %+v (%t)", c.name, c.expected, c.ok, got, ok)
+		}
+	}
+}
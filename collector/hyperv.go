@@ -3,17 +3,29 @@
 package collector
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 func init() {
 	registerCollector("hyperv", NewHyperVCollector)
 }
 
+var hypervLogger = log.With("collector", "hyperv")
+
+var hypervResourceMetering = kingpin.Flag(
+	"collector.hyperv.resource-metering",
+	"Collect the resource metering data of VMs with resource metering enabled, as reported by Measure-VM.",
+).Default("false").Bool()
+
 // HyperVCollector is a Prometheus collector for hyper-v
 type HyperVCollector struct {
 	// Win32_PerfRawData_VmmsVirtualMachineStats_HyperVVirtualMachineHealthSummary
@@ -110,6 +122,41 @@ type HyperVCollector struct {
 	VMNetworkDroppedPacketsOutgoing *prometheus.Desc
 	VMNetworkPacketsReceived        *prometheus.Desc
 	VMNetworkPacketsSent            *prometheus.Desc
+
+	// Win32_PerfRawData_BalancerStats_HyperVDynamicMemoryVM
+	VMMemoryPhysical             *prometheus.Desc
+	VMMemoryGuestVisiblePhysical *prometheus.Desc
+	VMMemoryDemand               *prometheus.Desc
+	VMMemoryPressureCurrent      *prometheus.Desc
+	VMMemoryPressureAverage      *prometheus.Desc
+	VMMemoryPressureMinimum      *prometheus.Desc
+	VMMemoryPressureMaximum      *prometheus.Desc
+	VMMemoryAdded                *prometheus.Desc
+	VMMemoryRemoved              *prometheus.Desc
+	VMMemoryAddOperations        *prometheus.Desc
+	VMMemoryRemoveOperations     *prometheus.Desc
+
+	// Msvm_VirtualSystemSettingData, Msvm_MemorySettingData
+	VMMemoryMaximum         *prometheus.Desc
+	VMMemorySmartPagingFile *prometheus.Desc
+
+	// Msvm_VirtualSystemSettingData, Msvm_StorageAllocationSettingData
+	VMCheckpoints          *prometheus.Desc
+	VMCheckpointDisks      *prometheus.Desc
+	VMOldestCheckpointTime *prometheus.Desc
+
+	// Msvm_AggregationMetricValue, Msvm_BaseMetricValue
+	VMMeteringDuration         *prometheus.Desc
+	VMMeteringCPUAverage       *prometheus.Desc
+	VMMeteringMemoryAverage    *prometheus.Desc
+	VMMeteringMemoryMinimum    *prometheus.Desc
+	VMMeteringMemoryMaximum    *prometheus.Desc
+	VMMeteringDiskAllocated    *prometheus.Desc
+	VMMeteringDiskRead         *prometheus.Desc
+	VMMeteringDiskWritten      *prometheus.Desc
+	VMMeteringDiskNormalizedIO *prometheus.Desc
+	VMMeteringNetworkIncoming  *prometheus.Desc
+	VMMeteringNetworkOutgoing  *prometheus.Desc
 }
 
 // NewHyperVCollector ...
@@ -592,62 +639,255 @@ func NewHyperVCollector() (Collector, error) {
 			[]string{"vm_interface"},
 			nil,
 		),
+
+		//
+
+		VMMemoryPhysical: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "physical_bytes"),
+			"This counter represents the memory currently assigned to the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryGuestVisiblePhysical: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "guest_visible_physical_bytes"),
+			"This counter represents the memory visible to the guest operating system of the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryDemand: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "demand_bytes"),
+			"This counter represents the memory the VM needs, derived from its current pressure and assigned memory",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryPressureCurrent: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "pressure_current"),
+			"This counter represents the current memory pressure of the VM, the percentage of its demand to its assigned memory",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryPressureAverage: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "pressure_average"),
+			"This counter represents the average memory pressure of the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryPressureMinimum: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "pressure_minimum"),
+			"This counter represents the minimum memory pressure of the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryPressureMaximum: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "pressure_maximum"),
+			"This counter represents the maximum memory pressure of the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryAdded: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "added_bytes_total"),
+			"This counter represents the cumulative amount of memory added to the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryRemoved: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "removed_bytes_total"),
+			"This counter represents the cumulative amount of memory removed from the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryAddOperations: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "add_operations_total"),
+			"This counter represents the total number of add operations for the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryRemoveOperations: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "remove_operations_total"),
+			"This counter represents the total number of remove operations for the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemoryMaximum: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "maximum_bytes"),
+			"This counter represents the maximum memory dynamic memory may assign to the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMMemorySmartPagingFile: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_memory"), "smart_paging_file_bytes"),
+			"This counter represents the size of the Smart Paging file of the VM, which backs part of its memory while it restarts with less than its startup memory",
+			[]string{"vm"},
+			nil,
+		),
+
+		//
+
+		VMCheckpoints: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm"), "checkpoints"),
+			"This counter represents the number of checkpoints of the VM",
+			[]string{"vm"},
+			nil,
+		),
+		VMCheckpointDisks: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm"), "checkpoint_disk_bytes"),
+			"This counter represents the size of the differencing disks of the checkpoints of the VM, including those the VM currently writes to",
+			[]string{"vm"},
+			nil,
+		),
+		VMOldestCheckpointTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm"), "oldest_checkpoint_timestamp_seconds"),
+			"This counter represents the time the oldest checkpoint of the VM was created, in seconds since epoch",
+			[]string{"vm"},
+			nil,
+		),
+
+		//
+
+		VMMeteringDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "duration_seconds"),
+			"Time covered by the resource metering data of the VM, since metering was enabled or last reset",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringCPUAverage: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "cpu_average_megahertz"),
+			"Average processor usage of the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringMemoryAverage: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "memory_average_bytes"),
+			"Average memory assigned to the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringMemoryMinimum: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "memory_minimum_bytes"),
+			"Minimum memory assigned to the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringMemoryMaximum: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "memory_maximum_bytes"),
+			"Maximum memory assigned to the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringDiskAllocated: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "disk_allocated_bytes"),
+			"Maximum disk space allocated to the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringDiskRead: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "disk_read_bytes_total"),
+			"Data read from the disks of the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringDiskWritten: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "disk_written_bytes_total"),
+			"Data written to the disks of the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringDiskNormalizedIO: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "disk_normalized_operations_total"),
+			"Disk operations of the VM over the metering duration, counted in 8 KB units",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringNetworkIncoming: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "network_received_bytes_total"),
+			"Network traffic received by the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
+		VMMeteringNetworkOutgoing: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, buildSubsystemName("vm_metering"), "network_sent_bytes_total"),
+			"Network traffic sent by the VM over the metering duration",
+			[]string{"vm"},
+			nil,
+		),
 	}, nil
 }
 
 // Collect sends the metric values for each metric
 // to the provided prometheus Metric channel.
 func (c *HyperVCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
-	if desc, err := c.collectVmHealth(ch); err != nil {
-		log.Error("failed collecting hyperV health status metrics:", desc, err)
+	if desc, err := c.collectVmHealth(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV health status metrics:", desc, err)
+		return err
+	}
+
+	if desc, err := c.collectVmVid(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV pages metrics:", desc, err)
+		return err
+	}
+
+	if desc, err := c.collectVmHv(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV hv status metrics:", desc, err)
+		return err
+	}
+
+	if desc, err := c.collectVmProcessor(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV processor metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmVid(ch); err != nil {
-		log.Error("failed collecting hyperV pages metrics:", desc, err)
+	if desc, err := c.collectHostCpuUsage(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV host CPU metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmHv(ch); err != nil {
-		log.Error("failed collecting hyperV hv status metrics:", desc, err)
+	if desc, err := c.collectVmCpuUsage(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV VM CPU metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmProcessor(ch); err != nil {
-		log.Error("failed collecting hyperV processor metrics:", desc, err)
+	if desc, err := c.collectVmSwitch(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV switch metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectHostCpuUsage(ch); err != nil {
-		log.Error("failed collecting hyperV host CPU metrics:", desc, err)
+	if desc, err := c.collectVmEthernet(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV ethernet metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmCpuUsage(ch); err != nil {
-		log.Error("failed collecting hyperV VM CPU metrics:", desc, err)
+	if desc, err := c.collectVmStorage(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV virtual storage metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmSwitch(ch); err != nil {
-		log.Error("failed collecting hyperV switch metrics:", desc, err)
+	if desc, err := c.collectVmNetwork(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV virtual network metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmEthernet(ch); err != nil {
-		log.Error("failed collecting hyperV ethernet metrics:", desc, err)
+	if desc, err := c.collectVmMemory(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV VM memory metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmStorage(ch); err != nil {
-		log.Error("failed collecting hyperV virtual storage metrics:", desc, err)
+	if desc, err := c.collectVmMemorySettings(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV VM memory settings metrics:", desc, err)
 		return err
 	}
 
-	if desc, err := c.collectVmNetwork(ch); err != nil {
-		log.Error("failed collecting hyperV virtual network metrics:", desc, err)
+	if desc, err := c.collectVmCheckpoints(ctx, ch); err != nil {
+		hypervLogger.Error("failed collecting hyperV VM checkpoint metrics:", desc, err)
 		return err
 	}
 
+	if flagBool(hypervResourceMetering) {
+		if desc, err := c.collectVmMetering(ctx, ch); err != nil {
+			hypervLogger.Error("failed collecting hyperV VM resource metering metrics:", desc, err)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -657,10 +897,10 @@ type Win32_PerfRawData_VmmsVirtualMachineStats_HyperVVirtualMachineHealthSummary
 	HealthOk       uint32
 }
 
-func (c *HyperVCollector) collectVmHealth(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmHealth(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_VmmsVirtualMachineStats_HyperVVirtualMachineHealthSummary
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -690,10 +930,10 @@ type Win32_PerfRawData_VidPerfProvider_HyperVVMVidPartition struct {
 	RemotePhysicalPages    uint64
 }
 
-func (c *HyperVCollector) collectVmVid(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmVid(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_VidPerfProvider_HyperVVMVidPartition
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -754,10 +994,10 @@ type Win32_PerfRawData_HvStats_HyperVHypervisorRootPartition struct {
 	VirtualTLBPages               uint64
 }
 
-func (c *HyperVCollector) collectVmHv(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmHv(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_HvStats_HyperVHypervisorRootPartition
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -892,10 +1132,10 @@ type Win32_PerfRawData_HvStats_HyperVHypervisor struct {
 	VirtualProcessors uint64
 }
 
-func (c *HyperVCollector) collectVmProcessor(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmProcessor(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_HvStats_HyperVHypervisor
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -927,10 +1167,10 @@ type Win32_PerfRawData_HvStats_HyperVHypervisorRootVirtualProcessor struct {
 	PercentTotalRunTime      uint64
 }
 
-func (c *HyperVCollector) collectHostCpuUsage(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectHostCpuUsage(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_HvStats_HyperVHypervisorRootVirtualProcessor
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -941,7 +1181,7 @@ func (c *HyperVCollector) collectHostCpuUsage(ch chan<- prometheus.Metric) (*pro
 		// The name format is Root VP <core id>
 		parts := strings.Split(obj.Name, " ")
 		if len(parts) != 3 {
-			log.Warnf("Unexpected format of Name in collectHostCpuUsage: %q", obj.Name)
+			hypervLogger.Warnf("Unexpected format of Name in collectHostCpuUsage: %q", obj.Name)
 			continue
 		}
 		coreId := parts[2]
@@ -988,10 +1228,10 @@ type Win32_PerfRawData_HvStats_HyperVHypervisorVirtualProcessor struct {
 	PercentTotalRunTime      uint64
 }
 
-func (c *HyperVCollector) collectVmCpuUsage(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmCpuUsage(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_HvStats_HyperVHypervisorVirtualProcessor
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -1002,12 +1242,12 @@ func (c *HyperVCollector) collectVmCpuUsage(ch chan<- prometheus.Metric) (*prome
 		// The name format is <VM Name>:Hv VP <vcore id>
 		parts := strings.Split(obj.Name, ":")
 		if len(parts) != 2 {
-			log.Warnf("Unexpected format of Name in collectVmCpuUsage: %q, expected %q. Skipping.", obj.Name, "<VM Name>:Hv VP <vcore id>")
+			hypervLogger.Warnf("Unexpected format of Name in collectVmCpuUsage: %q, expected %q. Skipping.", obj.Name, "<VM Name>:Hv VP <vcore id>")
 			continue
 		}
 		coreParts := strings.Split(parts[1], " ")
 		if len(coreParts) != 3 {
-			log.Warnf("Unexpected format of core identifier in collectVmCpuUsage: %q, expected %q. Skipping.", parts[1], "Hv VP <vcore id>")
+			hypervLogger.Warnf("Unexpected format of core identifier in collectVmCpuUsage: %q, expected %q. Skipping.", parts[1], "Hv VP <vcore id>")
 			continue
 		}
 		vmName := parts[0]
@@ -1075,10 +1315,10 @@ type Win32_PerfRawData_NvspSwitchStats_HyperVVirtualSwitch struct {
 	PurgedMacAddressesPersec               uint64
 }
 
-func (c *HyperVCollector) collectVmSwitch(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmSwitch(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NvspSwitchStats_HyperVVirtualSwitch
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -1234,10 +1474,10 @@ type Win32_PerfRawData_EthernetPerfProvider_HyperVLegacyNetworkAdapter struct {
 	FramesSentPersec     uint64
 }
 
-func (c *HyperVCollector) collectVmEthernet(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmEthernet(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_EthernetPerfProvider_HyperVLegacyNetworkAdapter
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -1304,10 +1544,10 @@ type Win32_PerfRawData_Counters_HyperVVirtualStorageDevice struct {
 	WriteOperationsPerSec uint64
 }
 
-func (c *HyperVCollector) collectVmStorage(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmStorage(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_Counters_HyperVVirtualStorageDevice
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -1373,10 +1613,10 @@ type Win32_PerfRawData_NvspNicStats_HyperVVirtualNetworkAdapter struct {
 	PacketsSentPersec            uint64
 }
 
-func (c *HyperVCollector) collectVmNetwork(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *HyperVCollector) collectVmNetwork(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NvspNicStats_HyperVVirtualNetworkAdapter
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -1430,3 +1670,621 @@ func (c *HyperVCollector) collectVmNetwork(ch chan<- prometheus.Metric) (*promet
 
 	return nil, nil
 }
+
+// Win32_PerfRawData_BalancerStats_HyperVDynamicMemoryVM ...
+type Win32_PerfRawData_BalancerStats_HyperVDynamicMemoryVM struct {
+	Name                       string
+	AddedMemory                uint64
+	AveragePressure            uint64
+	CurrentPressure            uint64
+	GuestVisiblePhysicalMemory uint64
+	MaximumPressure            uint64
+	MemoryAddOperations        uint64
+	MemoryRemoveOperations     uint64
+	MinimumPressure            uint64
+	PhysicalMemory             uint64
+	RemovedMemory              uint64
+}
+
+func (c *HyperVCollector) collectVmMemory(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []Win32_PerfRawData_BalancerStats_HyperVDynamicMemoryVM
+	q := queryAll(&dst)
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range dst {
+		if strings.Contains(obj.Name, "_Total") {
+			continue
+		}
+
+		// The memory counters are in megabytes.
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryPhysical,
+			prometheus.GaugeValue,
+			float64(obj.PhysicalMemory*1024*1024),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryGuestVisiblePhysical,
+			prometheus.GaugeValue,
+			float64(obj.GuestVisiblePhysicalMemory*1024*1024),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryDemand,
+			prometheus.GaugeValue,
+			float64(obj.PhysicalMemory*1024*1024)*float64(obj.CurrentPressure)/100,
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryPressureCurrent,
+			prometheus.GaugeValue,
+			float64(obj.CurrentPressure),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryPressureAverage,
+			prometheus.GaugeValue,
+			float64(obj.AveragePressure),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryPressureMinimum,
+			prometheus.GaugeValue,
+			float64(obj.MinimumPressure),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryPressureMaximum,
+			prometheus.GaugeValue,
+			float64(obj.MaximumPressure),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryAdded,
+			prometheus.CounterValue,
+			float64(obj.AddedMemory*1024*1024),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryRemoved,
+			prometheus.CounterValue,
+			float64(obj.RemovedMemory*1024*1024),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryAddOperations,
+			prometheus.CounterValue,
+			float64(obj.MemoryAddOperations),
+			obj.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemoryRemoveOperations,
+			prometheus.CounterValue,
+			float64(obj.MemoryRemoveOperations),
+			obj.Name,
+		)
+	}
+
+	return nil, nil
+}
+
+// Msvm_VirtualSystemSettingData ...
+type Msvm_VirtualSystemSettingData struct {
+	InstanceID       string
+	ElementName      string
+	SwapFileDataRoot string
+}
+
+// Msvm_MemorySettingData ...
+type Msvm_MemorySettingData struct {
+	InstanceID string
+	Limit      uint64
+}
+
+func (c *HyperVCollector) collectVmMemorySettings(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var vms []Msvm_VirtualSystemSettingData
+	q := queryAllWhere(&vms, "VirtualSystemType = 'Microsoft:Hyper-V:System:Realized'")
+	if err := queryWMIContext(ctx.Context(), q, &vms, nil, "root/virtualization/v2"); err != nil {
+		return nil, err
+	}
+
+	var settings []Msvm_MemorySettingData
+	q = queryAll(&settings)
+	if err := queryWMIContext(ctx.Context(), q, &settings, nil, "root/virtualization/v2"); err != nil {
+		return nil, err
+	}
+
+	// The settings of a VM are identified by the ID of the VM, followed by
+	// the ID of the setting, e.g. Microsoft:<VM ID>\<setting ID>. Snapshots
+	// have IDs of their own.
+	limits := make(map[string]uint64, len(settings))
+	for _, s := range settings {
+		if i := strings.Index(s.InstanceID, `\`); i >= 0 {
+			limits[s.InstanceID[:i]] = s.Limit
+		}
+	}
+
+	for _, vm := range vms {
+		if limit, ok := limits[vm.InstanceID]; ok {
+			ch <- prometheus.MustNewConstMetric(
+				c.VMMemoryMaximum,
+				prometheus.GaugeValue,
+				float64(limit*1024*1024),
+				vm.ElementName,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMemorySmartPagingFile,
+			prometheus.GaugeValue,
+			float64(smartPagingFileSize(vm)),
+			vm.ElementName,
+		)
+	}
+
+	return nil, nil
+}
+
+// smartPagingFileSize returns the size of the Smart Paging file of the VM, or 0
+// when the VM is not using Smart Paging. The file is named after the ID of the
+// VM and kept in its Smart Paging file location.
+func smartPagingFileSize(vm Msvm_VirtualSystemSettingData) int64 {
+	if vm.SwapFileDataRoot == "" {
+		return 0
+	}
+	id := strings.TrimPrefix(vm.InstanceID, "Microsoft:")
+	matches, err := filepath.Glob(filepath.Join(vm.SwapFileDataRoot, "*"+id+"*.slp"))
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
+// Msvm_SnapshotSettingData holds the properties of Msvm_VirtualSystemSettingData
+// identifying the VM of settings, which are those of the VM itself or of one of
+// its checkpoints.
+type Msvm_SnapshotSettingData struct {
+	InstanceID              string
+	ElementName             string
+	VirtualSystemType       string
+	VirtualSystemIdentifier string
+	CreationTime            *time.Time
+}
+
+// Msvm_StorageAllocationSettingData ...
+type Msvm_StorageAllocationSettingData struct {
+	InstanceID   string
+	HostResource []string
+}
+
+// hypervCheckpoints holds the checkpoints of a VM.
+type hypervCheckpoints struct {
+	count  int
+	oldest time.Time
+	// disks holds the paths of the differencing disks of the checkpoints.
+	disks map[string]bool
+}
+
+// hypervVMCheckpoints returns the checkpoints of each VM, by VM ID, from the
+// settings of the VMs and of their checkpoints and the virtual disks of these
+// settings. Differencing disks are those of checkpoints, whether the VM or a
+// checkpoint uses them.
+func hypervVMCheckpoints(settings []Msvm_SnapshotSettingData, storage []Msvm_StorageAllocationSettingData) map[string]*hypervCheckpoints {
+	checkpoints := make(map[string]*hypervCheckpoints)
+	vmOfSettings := make(map[string]string, len(settings))
+	for _, s := range settings {
+		vmOfSettings[s.InstanceID] = s.VirtualSystemIdentifier
+		cp, ok := checkpoints[s.VirtualSystemIdentifier]
+		if !ok {
+			cp = &hypervCheckpoints{disks: make(map[string]bool)}
+			checkpoints[s.VirtualSystemIdentifier] = cp
+		}
+		if !strings.HasPrefix(s.VirtualSystemType, "Microsoft:Hyper-V:Snapshot:") {
+			continue
+		}
+		cp.count++
+		if s.CreationTime != nil && (cp.oldest.IsZero() || s.CreationTime.Before(cp.oldest)) {
+			cp.oldest = *s.CreationTime
+		}
+	}
+
+	// The virtual disks of settings are identified by the ID of the
+	// settings, followed by the IDs of the disk, e.g.
+	// Microsoft:<settings ID>\<controller ID>\0\0\L.
+	for _, disk := range storage {
+		i := strings.Index(disk.InstanceID, `\`)
+		if i < 0 {
+			continue
+		}
+		cp, ok := checkpoints[vmOfSettings[disk.InstanceID[:i]]]
+		if !ok {
+			continue
+		}
+		for _, path := range disk.HostResource {
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".avhdx", ".avhd":
+				cp.disks[path] = true
+			}
+		}
+	}
+	return checkpoints
+}
+
+func (c *HyperVCollector) collectVmCheckpoints(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var settings []Msvm_SnapshotSettingData
+	q := queryAllForClassWhere(&settings, "Msvm_VirtualSystemSettingData", "VirtualSystemType = 'Microsoft:Hyper-V:System:Realized' OR VirtualSystemType LIKE 'Microsoft:Hyper-V:Snapshot:%'")
+	if err := queryWMIContext(ctx.Context(), q, &settings, nil, "root/virtualization/v2"); err != nil {
+		return nil, err
+	}
+
+	var storage []Msvm_StorageAllocationSettingData
+	q = queryAllWhere(&storage, "ResourceType = 31")
+	if err := queryWMIContext(ctx.Context(), q, &storage, nil, "root/virtualization/v2"); err != nil {
+		return nil, err
+	}
+
+	checkpoints := hypervVMCheckpoints(settings, storage)
+	for _, vm := range settings {
+		if vm.VirtualSystemType != "Microsoft:Hyper-V:System:Realized" {
+			continue
+		}
+		cp := checkpoints[vm.VirtualSystemIdentifier]
+
+		var size int64
+		for path := range cp.disks {
+			if fi, err := os.Stat(path); err == nil {
+				size += fi.Size()
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.VMCheckpoints,
+			prometheus.GaugeValue,
+			float64(cp.count),
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMCheckpointDisks,
+			prometheus.GaugeValue,
+			float64(size),
+			vm.ElementName,
+		)
+		if !cp.oldest.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				c.VMOldestCheckpointTime,
+				prometheus.GaugeValue,
+				float64(cp.oldest.Unix()),
+				vm.ElementName,
+			)
+		}
+	}
+
+	return nil, nil
+}
+
+// Msvm_ComputerSystem ...
+type Msvm_ComputerSystem struct {
+	Name        string
+	ElementName string
+}
+
+// Msvm_MetricForME associates a metric value with the element it measures.
+// Both properties are WMI object paths.
+type Msvm_MetricForME struct {
+	Antecedent string
+	Dependent  string
+}
+
+// Msvm_MetricValue holds the properties shared by Msvm_AggregationMetricValue
+// and Msvm_BaseMetricValue.
+type Msvm_MetricValue struct {
+	InstanceID         string
+	MetricDefinitionId string
+	MetricValue        string
+	Duration           string
+}
+
+// Msvm_MetricDefinition holds the properties shared by
+// Msvm_AggregationMetricDefinition and Msvm_BaseMetricDefinition.
+type Msvm_MetricDefinition struct {
+	Id          string
+	ElementName string
+}
+
+// hypervMetering holds the resource metering data of a VM, in the units
+// reported by Hyper-V: MHz for processor usage and MB for memory, disk space
+// and data.
+type hypervMetering struct {
+	duration     float64
+	cpuAverage   float64
+	memAverage   float64
+	memMinimum   float64
+	memMaximum   float64
+	diskAlloc    float64
+	diskRead     float64
+	diskWritten  float64
+	normalizedIO float64
+	netIncoming  float64
+	netOutgoing  float64
+}
+
+// add adds a metric value of the VM, identified by the name of its definition.
+// Network traffic is metered per port ACL, so it is summed.
+func (m *hypervMetering) add(definition string, value float64) bool {
+	switch d := strings.ToLower(definition); {
+	case strings.Contains(d, "cpu utilization"):
+		m.cpuAverage = value
+	case strings.Contains(d, "average memory"):
+		m.memAverage = value
+	case strings.Contains(d, "minimum memory"):
+		m.memMinimum = value
+	case strings.Contains(d, "maximum memory"):
+		m.memMaximum = value
+	case strings.Contains(d, "disk allocation"):
+		m.diskAlloc = value
+	case strings.Contains(d, "data read"):
+		m.diskRead = value
+	case strings.Contains(d, "data written"):
+		m.diskWritten = value
+	case strings.Contains(d, "normalized i/o count"):
+		m.normalizedIO = value
+	case strings.Contains(d, "incoming network traffic"):
+		m.netIncoming += value
+	case strings.Contains(d, "outgoing network traffic"):
+		m.netOutgoing += value
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *HyperVCollector) collectVmMetering(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	const namespace = "root/virtualization/v2"
+
+	var vms []Msvm_ComputerSystem
+	q := queryAllWhere(&vms, "Caption = 'Virtual Machine'")
+	if err := queryWMIContext(ctx.Context(), q, &vms, nil, namespace); err != nil {
+		return nil, err
+	}
+
+	var associations []Msvm_MetricForME
+	q = queryAll(&associations)
+	if err := queryWMIContext(ctx.Context(), q, &associations, nil, namespace); err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]string)
+	values := make(map[string]Msvm_MetricValue)
+	for _, class := range []string{"Aggregation", "Base"} {
+		var defs []Msvm_MetricDefinition
+		q = queryAllForClass(&defs, "Msvm_"+class+"MetricDefinition")
+		if err := queryWMIContext(ctx.Context(), q, &defs, nil, namespace); err != nil {
+			return nil, err
+		}
+		for _, d := range defs {
+			definitions[d.Id] = d.ElementName
+		}
+
+		var vals []Msvm_MetricValue
+		q = queryAllForClass(&vals, "Msvm_"+class+"MetricValue")
+		if err := queryWMIContext(ctx.Context(), q, &vals, nil, namespace); err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			values[v.InstanceID] = v
+		}
+	}
+
+	metering := make(map[string]*hypervMetering)
+	for _, a := range associations {
+		vmID, ok := meteredVM(a.Antecedent)
+		if !ok {
+			continue
+		}
+		_, keys := parseWMIPath(a.Dependent)
+		v, ok := values[keys["InstanceID"]]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(v.MetricValue, 64)
+		if err != nil {
+			continue
+		}
+		m, ok := metering[vmID]
+		if !ok {
+			m = &hypervMetering{}
+			metering[vmID] = m
+		}
+		if !m.add(definitions[v.MetricDefinitionId], value) {
+			continue
+		}
+		if d, err := parseCIMInterval(v.Duration); err == nil && d > m.duration {
+			m.duration = d
+		}
+	}
+
+	const mb = 1024 * 1024
+	for _, vm := range vms {
+		m, ok := metering[strings.ToUpper(vm.Name)]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringDuration,
+			prometheus.GaugeValue,
+			m.duration,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringCPUAverage,
+			prometheus.GaugeValue,
+			m.cpuAverage,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringMemoryAverage,
+			prometheus.GaugeValue,
+			m.memAverage*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringMemoryMinimum,
+			prometheus.GaugeValue,
+			m.memMinimum*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringMemoryMaximum,
+			prometheus.GaugeValue,
+			m.memMaximum*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringDiskAllocated,
+			prometheus.GaugeValue,
+			m.diskAlloc*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringDiskRead,
+			prometheus.CounterValue,
+			m.diskRead*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringDiskWritten,
+			prometheus.CounterValue,
+			m.diskWritten*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringDiskNormalizedIO,
+			prometheus.CounterValue,
+			m.normalizedIO,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringNetworkIncoming,
+			prometheus.CounterValue,
+			m.netIncoming*mb,
+			vm.ElementName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VMMeteringNetworkOutgoing,
+			prometheus.CounterValue,
+			m.netOutgoing*mb,
+			vm.ElementName,
+		)
+	}
+
+	return nil, nil
+}
+
+// meteredVM returns the upper-cased ID of the VM an element with metrics
+// belongs to: the VM itself, or one of the port ACLs metering its network
+// traffic, whose IDs start with Microsoft:<VM ID>. Metrics of other elements,
+// e.g. of the disks, are already aggregated on the VM.
+func meteredVM(path string) (string, bool) {
+	class, keys := parseWMIPath(path)
+	switch class {
+	case "Msvm_ComputerSystem":
+		return strings.ToUpper(keys["Name"]), keys["Name"] != ""
+	case "Msvm_EthernetSwitchPortAclSettingData":
+		id := strings.TrimPrefix(keys["InstanceID"], "Microsoft:")
+		if i := strings.Index(id, `\`); i > 0 {
+			return strings.ToUpper(id[:i]), true
+		}
+	}
+	return "", false
+}
+
+// parseWMIPath returns the class and keys of a WMI object path, e.g.
+// \\HOST\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="<ID>"
+func parseWMIPath(path string) (string, map[string]string) {
+	if strings.HasPrefix(path, `\\`) {
+		if i := strings.Index(path, ":"); i >= 0 {
+			path = path[i+1:]
+		}
+	}
+	keys := make(map[string]string)
+	i := strings.Index(path, ".")
+	if i < 0 {
+		return path, keys
+	}
+	class, rest := path[:i], path[i+1:]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		name := rest[:eq]
+		rest = rest[eq+1:]
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			// Quoted values escape quotes and backslashes with a backslash.
+			rest = rest[1:]
+			for len(rest) > 0 && rest[0] != '"' {
+				if rest[0] == '\\' && len(rest) > 1 {
+					rest = rest[1:]
+				}
+				value.WriteByte(rest[0])
+				rest = rest[1:]
+			}
+			rest = strings.TrimPrefix(rest, `"`)
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(rest[:end])
+			rest = rest[end:]
+		}
+		keys[name] = value.String()
+		rest = strings.TrimPrefix(rest, ",")
+	}
+	return class, keys
+}
+
+// parseCIMInterval parses a CIM interval, e.g. 00000001021530.000000:000 for
+// 1 day, 2 hours, 15 minutes and 30 seconds, in seconds.
+func parseCIMInterval(s string) (float64, error) {
+	if len(s) < 22 || s[14] != '.' || s[21] != ':' {
+		return 0, fmt.Errorf("invalid CIM interval %q", s)
+	}
+	var days, hours, minutes, seconds, micros int64
+	for _, part := range []struct {
+		dst   *int64
+		value string
+	}{
+		{&days, s[0:8]},
+		{&hours, s[8:10]},
+		{&minutes, s[10:12]},
+		{&seconds, s[12:14]},
+		{&micros, s[15:21]},
+	} {
+		n, err := strconv.ParseInt(part.value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CIM interval %q", s)
+		}
+		*part.dst = n
+	}
+	return float64(((days*24+hours)*60+minutes)*60+seconds) + float64(micros)/1e6, nil
+}
@@ -1,9 +1,85 @@
 package collector
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 func BenchmarkHypervCollector(b *testing.B) {
 	benchmarkCollector(b, "hyperv", NewHyperVCollector)
 }
+
+func TestParseWMIPath(t *testing.T) {
+	class, keys := parseWMIPath(`\\HV01\root\virtualization\v2:Msvm_EthernetSwitchPortAclSettingData.InstanceID="Microsoft:4E3A1C2B-0000-0000-0000-000000000001\\6B9F\\A1\\\"x\""`)
+	expected := map[string]string{"InstanceID": `Microsoft:4E3A1C2B-0000-0000-0000-000000000001\6B9F\A1\"x"`}
+	if class != "Msvm_EthernetSwitchPortAclSettingData" || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Path does not match!\nExpected result: Msvm_EthernetSwitchPortAclSettingData %v\nActual result: %s %v", expected, class, keys)
+	}
+
+	vm, ok := meteredVM(`\\HV01\root\virtualization\v2:Msvm_ComputerSystem.CreationClassName="Msvm_ComputerSystem",Name="4e3a1c2b-0000-0000-0000-000000000001"`)
+	if !ok || vm != "4E3A1C2B-0000-0000-0000-000000000001" {
+		t.Errorf("Expected the VM 4E3A1C2B-0000-0000-0000-000000000001, got %q (%t)", vm, ok)
+	}
+	vm, ok = meteredVM(`\\HV01\root\virtualization\v2:Msvm_EthernetSwitchPortAclSettingData.InstanceID="Microsoft:4E3A1C2B-0000-0000-0000-000000000001\\6B9F\\A1"`)
+	if !ok || vm != "4E3A1C2B-0000-0000-0000-000000000001" {
+		t.Errorf("Expected the VM 4E3A1C2B-0000-0000-0000-000000000001 of the ACL, got %q (%t)", vm, ok)
+	}
+	if _, ok := meteredVM(`\\HV01\root\virtualization\v2:Msvm_StorageAllocationSettingData.InstanceID="Microsoft:4E3A1C2B\\0"`); ok {
+		t.Error("Expected metrics of disks not to be attributed to the VM")
+	}
+}
+
+func TestParseCIMInterval(t *testing.T) {
+	d, err := parseCIMInterval("00000001021530.500000:000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 24*3600 + 2*3600 + 15*60 + 30.5; d != expected {
+		t.Errorf("Expected %f seconds, got %f", expected, d)
+	}
+	if _, err := parseCIMInterval("20201015000000.000000+000"); err == nil {
+		t.Error("Expected an error for a datetime")
+	}
+}
+
+func TestHypervMeteringAdd(t *testing.T) {
+	var m hypervMetering
+	m.add("Aggregated Average CPU Utilization", 1200)
+	m.add("Aggregated Disk Data Read", 10)
+	m.add("Filtered Incoming Network Traffic", 5)
+	m.add("Filtered Incoming Network Traffic", 7)
+	if m.add("Something Else", 1) {
+		t.Error("Expected an unknown definition not to be added")
+	}
+	expected := hypervMetering{cpuAverage: 1200, diskRead: 10, netIncoming: 12}
+	if m != expected {
+		t.Errorf("Metering does not match!\nExpected result: %+v\nActual result: %+v", expected, m)
+	}
+}
+
+func TestHypervVMCheckpoints(t *testing.T) {
+	older := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+	settings := []Msvm_SnapshotSettingData{
+		{InstanceID: "Microsoft:VM1", VirtualSystemType: "Microsoft:Hyper-V:System:Realized", VirtualSystemIdentifier: "VM1"},
+		{InstanceID: "Microsoft:CP1", VirtualSystemType: "Microsoft:Hyper-V:Snapshot:Realized", VirtualSystemIdentifier: "VM1", CreationTime: &newer},
+		{InstanceID: "Microsoft:CP2", VirtualSystemType: "Microsoft:Hyper-V:Snapshot:Recovery", VirtualSystemIdentifier: "VM1", CreationTime: &older},
+		{InstanceID: "Microsoft:VM2", VirtualSystemType: "Microsoft:Hyper-V:System:Realized", VirtualSystemIdentifier: "VM2"},
+	}
+	storage := []Msvm_StorageAllocationSettingData{
+		{InstanceID: `Microsoft:VM1\83F8638B\0\0\L`, HostResource: []string{`C:\VMs\vm1_6A1B.avhdx`}},
+		{InstanceID: `Microsoft:CP1\83F8638B\0\0\L`, HostResource: []string{`C:\VMs\vm1_31C2.AVHDX`}},
+		{InstanceID: `Microsoft:CP2\83F8638B\0\0\L`, HostResource: []string{`C:\VMs\vm1.vhdx`}},
+		{InstanceID: `Microsoft:VM2\83F8638B\0\0\L`, HostResource: []string{`C:\VMs\vm2.vhdx`}},
+	}
+
+	checkpoints := hypervVMCheckpoints(settings, storage)
+	expected := map[string]*hypervCheckpoints{
+		"VM1": {count: 2, oldest: older, disks: map[string]bool{`C:\VMs\vm1_6A1B.avhdx`: true, `C:\VMs\vm1_31C2.AVHDX`: true}},
+		"VM2": {disks: map[string]bool{}},
+	}
+	if !reflect.DeepEqual(checkpoints, expected) {
+		t.Errorf("Checkpoints do not match!\nExpected result: %+v %+v\nActual result: %+v %+v", expected["VM1"], expected["VM2"], checkpoints["VM1"], checkpoints["VM2"])
+	}
+}
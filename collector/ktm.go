@@ -0,0 +1,212 @@
+// +build windows
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/headers/ktmw32"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("ktm", newKTMCollector)
+}
+
+var ktmLogger = log.With("collector", "ktm")
+
+var ktmLogDirectories = kingpin.Flag(
+	"collector.ktm.log-directories",
+	"Comma-separated list of directories holding CLFS logs. Defaults to the logs of the registry transactions, in %SystemRoot%\\System32\\config and its TxR directory.",
+).Default("").String()
+
+// ktmTransactionStates maps the state of a transaction to the state label.
+var ktmTransactionStates = map[uint32]string{
+	ktmw32.TransactionStateNormal:          "normal",
+	ktmw32.TransactionStateIndoubt:         "in_doubt",
+	ktmw32.TransactionStateCommittedNotify: "committed_notify",
+}
+
+// A KTMCollector is a Prometheus collector for the transactions of the Kernel
+// Transaction Manager and the Common Log File System logs backing them
+type KTMCollector struct {
+	TransactionManagers *prometheus.Desc
+	Transactions        *prometheus.Desc
+	LogContainers       *prometheus.Desc
+	LogSizeBytes        *prometheus.Desc
+
+	logDirectories []string
+}
+
+func newKTMCollector() (Collector, error) {
+	const subsystem = "ktm"
+
+	var directories []string
+	for _, d := range strings.Split(*ktmLogDirectories, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			directories = append(directories, d)
+		}
+	}
+	if len(directories) == 0 {
+		config := filepath.Join(os.Getenv("SystemRoot"), "System32", "config")
+		directories = []string{config, filepath.Join(config, "TxR")}
+	}
+
+	return &KTMCollector{
+		TransactionManagers: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "transaction_managers"),
+			"Number of transaction managers, e.g. of the registry hives and NTFS volumes",
+			nil,
+			nil,
+		),
+		Transactions: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "transactions"),
+			"Number of transactions not yet completed, by state",
+			[]string{"state"},
+			nil,
+		),
+		LogContainers: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_containers"),
+			"Number of containers of the CLFS log",
+			[]string{"log"},
+			nil,
+		),
+		LogSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_size_bytes"),
+			"Size of the base log file and containers of the CLFS log",
+			[]string{"log"},
+			nil,
+		),
+		logDirectories: directories,
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *KTMCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectTransactions(ch); err != nil {
+		ktmLogger.Error("failed collecting ktm transaction metrics:", desc, err)
+		return err
+	}
+	c.collectLogs(ch)
+	return nil
+}
+
+func (c *KTMCollector) collectTransactions(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	managers, err := ktmw32.EnumerateTransactionManagers()
+	if err != nil {
+		return nil, err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.TransactionManagers,
+		prometheus.GaugeValue,
+		float64(len(managers)),
+	)
+
+	ids, err := ktmw32.EnumerateTransactions()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]float64{"unknown": 0}
+	for _, state := range ktmTransactionStates {
+		counts[state] = 0
+	}
+	for _, id := range ids {
+		// Transactions completing while enumerated can't be opened anymore.
+		info, err := ktmw32.QueryTransaction(id)
+		if err != nil {
+			ktmLogger.Debugf("Failed to query transaction %s: %v", id, err)
+			continue
+		}
+		state, ok := ktmTransactionStates[info.State]
+		if !ok {
+			state = "unknown"
+		}
+		counts[state]++
+	}
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			c.Transactions,
+			prometheus.GaugeValue,
+			count,
+			state,
+		)
+	}
+	return nil, nil
+}
+
+// ktmFile is a file in a directory holding CLFS logs.
+type ktmFile struct {
+	name string
+	size int64
+}
+
+type ktmLog struct {
+	name       string
+	containers int
+	size       int64
+}
+
+// ktmLogs returns the CLFS logs whose base log files, e.g. {guid}.TxR.blf,
+// are among the files. Containers are named after their base log file, e.g.
+// {guid}.TxR.0.regtrans-ms, or SYSTEM{guid}.TMContainer00000000000000000001.regtrans-ms
+// for SYSTEM{guid}.TM.blf.
+func ktmLogs(files []ktmFile) []ktmLog {
+	var logs []ktmLog
+	for _, f := range files {
+		if !strings.EqualFold(filepath.Ext(f.name), ".blf") {
+			continue
+		}
+		base := strings.TrimSuffix(f.name, filepath.Ext(f.name))
+		l := ktmLog{name: base, size: f.size}
+		for _, container := range files {
+			if container.name != f.name && !strings.EqualFold(filepath.Ext(container.name), ".blf") &&
+				strings.HasPrefix(strings.ToLower(container.name), strings.ToLower(base)) {
+				l.containers++
+				l.size += container.size
+			}
+		}
+		logs = append(logs, l)
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].name < logs[j].name })
+	return logs
+}
+
+// collectLogs sends the containers and size of the CLFS logs. Directories
+// that can't be read are skipped.
+func (c *KTMCollector) collectLogs(ch chan<- prometheus.Metric) {
+	for _, dir := range c.logDirectories {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			ktmLogger.Debugf("Failed to read CLFS log directory %s: %v", dir, err)
+			continue
+		}
+		files := make([]ktmFile, 0, len(infos))
+		for _, info := range infos {
+			if !info.IsDir() {
+				files = append(files, ktmFile{name: info.Name(), size: info.Size()})
+			}
+		}
+		for _, l := range ktmLogs(files) {
+			name := filepath.Join(dir, l.name)
+			ch <- prometheus.MustNewConstMetric(
+				c.LogContainers,
+				prometheus.GaugeValue,
+				float64(l.containers),
+				name,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.LogSizeBytes,
+				prometheus.GaugeValue,
+				float64(l.size),
+				name,
+			)
+		}
+	}
+}
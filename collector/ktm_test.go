@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkKTMCollector(b *testing.B) {
+	benchmarkCollector(b, "ktm", newKTMCollector)
+}
+
+func TestKTMLogs(t *testing.T) {
+	files := []ktmFile{
+		{name: "{53b39e70-18c4-11ea-a811-000d3aa4692b}.TxR.0.regtrans-ms", size: 1048576},
+		{name: "{53b39e70-18c4-11ea-a811-000d3aa4692b}.TxR.1.regtrans-ms", size: 1048576},
+		{name: "{53b39e70-18c4-11ea-a811-000d3aa4692b}.TxR.blf", size: 65536},
+		{name: "SYSTEM", size: 16777216},
+		{name: "SYSTEM{016888cd-6c6f-11de-8d1d-001e0bcde3ec}.TM.blf", size: 65536},
+		{name: "SYSTEM{016888cd-6c6f-11de-8d1d-001e0bcde3ec}.TMContainer00000000000000000001.regtrans-ms", size: 524288},
+		{name: "SOFTWARE{016888cd-6c6f-11de-8d1d-001e0bcde3ec}.TM.blf", size: 65536},
+	}
+	expected := []ktmLog{
+		{name: "SOFTWARE{016888cd-6c6f-11de-8d1d-001e0bcde3ec}.TM", containers: 0, size: 65536},
+		{name: "SYSTEM{016888cd-6c6f-11de-8d1d-001e0bcde3ec}.TM", containers: 1, size: 589824},
+		{name: "{53b39e70-18c4-11ea-a811-000d3aa4692b}.TxR", containers: 2, size: 2162688},
+	}
+	if got := ktmLogs(files); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Logs do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
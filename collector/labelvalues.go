@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// labelValueCollectors are the collectors whose label values can be
+// restricted in the label_values section of the configuration file, with the
+// label that can be restricted. The restriction narrows the query of the
+// collector, rather than dropping metrics after it.
+var labelValueCollectors = map[string]string{
+	"msmq":    "name",
+	"process": "process",
+	"service": "name",
+}
+
+var labelValues struct {
+	sync.RWMutex
+	// values holds the allowed values of the restricted label of each
+	// collector.
+	values map[string][]string
+}
+
+// SetLabelValues restricts the values of labels of collectors, indexed by
+// collector and label name, to those listed. It replaces the restrictions set
+// before, and can be called while collecting.
+func SetLabelValues(values map[string]map[string][]string) error {
+	restricted := make(map[string][]string, len(values))
+	for collector, labels := range values {
+		label, ok := labelValueCollectors[collector]
+		if !ok {
+			return fmt.Errorf("collector %s doesn't support restricting label values", collector)
+		}
+		for name, v := range labels {
+			if name != label {
+				return fmt.Errorf("collector %s only supports restricting the values of label %s", collector, label)
+			}
+			restricted[collector] = v
+		}
+	}
+
+	labelValues.Lock()
+	labelValues.values = restricted
+	labelValues.Unlock()
+	return nil
+}
+
+// allowedLabelValues returns the allowed values of the restricted label of
+// the collector, or nil if they aren't restricted.
+func allowedLabelValues(collector string) []string {
+	labelValues.RLock()
+	defer labelValues.RUnlock()
+	return labelValues.values[collector]
+}
+
+// wqlLabelValuesWhere returns the WQL where clause where, further restricted
+// to objects whose property is one of values. It returns where unchanged if
+// values is nil. String comparisons of WQL ignore case.
+func wqlLabelValuesWhere(where string, property string, values []string) string {
+	if values == nil {
+		return where
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	conditions := make([]string, 0, len(sorted))
+	for _, v := range sorted {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", property, escaper.Replace(v)))
+	}
+	restriction := "(" + strings.Join(conditions, " OR ") + ")"
+	if where == "" {
+		return restriction
+	}
+	return "(" + where + ") AND " + restriction
+}
+
+// labelValueSet returns the lower-cased allowed values of the restricted label
+// of the collector, or nil if they aren't restricted.
+func labelValueSet(collector string) map[string]bool {
+	values := allowedLabelValues(collector)
+	if values == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(v)] = true
+	}
+	return set
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestWQLLabelValuesWhere(t *testing.T) {
+	cases := []struct {
+		where    string
+		values   []string
+		expected string
+	}{
+		{"StartMode = 'Auto'", nil, "StartMode = 'Auto'"},
+		{"", []string{"wuauserv", "MSSQLSERVER"}, "(Name = 'MSSQLSERVER' OR Name = 'wuauserv')"},
+		{"StartMode = 'Auto'", []string{"wuauserv"}, "(StartMode = 'Auto') AND (Name = 'wuauserv')"},
+		{"", []string{`host\private$\o'brien`}, `(Name = 'host\\private$\\o\'brien')`},
+	}
+	for _, c := range cases {
+		if actual := wqlLabelValuesWhere(c.where, "Name", c.values); actual != c.expected {
+			t.Errorf("Where clauses do not match!\nExpected result: %s\nActual result: %s", c.expected, actual)
+		}
+	}
+}
+
+func TestSetLabelValues(t *testing.T) {
+	defer SetLabelValues(nil)
+
+	if err := SetLabelValues(map[string]map[string][]string{"cpu": {"core": {"0,0"}}}); err == nil {
+		t.Error("Expected an error for a collector not supporting label values")
+	}
+	if err := SetLabelValues(map[string]map[string][]string{"process": {"process_id": {"4"}}}); err == nil {
+		t.Error("Expected an error for a label that can't be restricted")
+	}
+	if err := SetLabelValues(map[string]map[string][]string{"process": {"process": {"SQLServr", "w3wp"}}}); err != nil {
+		t.Fatal(err)
+	}
+	allowed := labelValueSet("process")
+	if len(allowed) != 2 || !allowed["sqlservr"] || !allowed["w3wp"] {
+		t.Errorf("Expected sqlservr and w3wp to be allowed, got %v", allowed)
+	}
+	if labelValueSet("service") != nil {
+		t.Error("Expected service label values not to be restricted")
+	}
+}
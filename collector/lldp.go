@@ -0,0 +1,340 @@
+// +build windows
+
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/wpcap"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("lldp", newLLDPCollector)
+}
+
+var lldpLogger = log.With("collector", "lldp")
+
+var lldpCDP = kingpin.Flag(
+	"collector.lldp.cdp",
+	"If true, also capture Cisco Discovery Protocol announcements.",
+).Bool()
+
+const (
+	lldpEtherType = 0x88cc
+	lldpFilter    = "ether proto 0x88cc"
+	cdpFilter     = "ether dst 01:00:0c:cc:cc:cc"
+)
+
+// lldpNeighbor is a device announcing itself on a network interface.
+type lldpNeighbor struct {
+	protocol        string
+	chassisID       string
+	systemName      string
+	portID          string
+	portDescription string
+	vlan            string
+	ttl             time.Duration
+}
+
+type lldpEntry struct {
+	lldpNeighbor
+	iface    string
+	lastSeen time.Time
+}
+
+// A LLDPCollector is a Prometheus collector for the neighbors announced with
+// LLDP or CDP on the network interfaces, captured with Npcap
+type LLDPCollector struct {
+	InterfaceInfo    *prometheus.Desc
+	NeighborInfo     *prometheus.Desc
+	NeighborLastSeen *prometheus.Desc
+
+	// interfaces maps the GUIDs of the captured interfaces to the
+	// descriptions of their network adapters.
+	interfaces map[string]string
+
+	mu        sync.Mutex
+	neighbors map[string]lldpEntry
+
+	// done is closed to stop the captures, captures waits for them.
+	done     chan struct{}
+	captures sync.WaitGroup
+}
+
+func newLLDPCollector() (Collector, error) {
+	const subsystem = "lldp"
+
+	if err := wpcap.Load(); err != nil {
+		return nil, err
+	}
+	devices, err := wpcap.FindAllDevs()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &LLDPCollector{
+		InterfaceInfo: newInfoDesc(
+			subsystem+"_interface",
+			"A metric with a constant '1' value labeled with the description of the network adapter of the interface",
+			[]string{"interface"},
+			"description",
+		),
+		NeighborInfo: newInfoDesc(
+			subsystem+"_neighbor",
+			"A metric with a constant '1' value labeled with the identity of a neighbor announced on the interface",
+			[]string{"interface", "protocol", "chassis_id", "port_id"},
+			"system_name", "port_description", "vlan",
+		),
+		NeighborLastSeen: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "neighbor_last_seen_timestamp_seconds"),
+			"Time the neighbor was last announced on the interface",
+			[]string{"interface", "protocol", "chassis_id", "port_id"},
+			nil,
+		),
+		interfaces: make(map[string]string),
+		neighbors:  make(map[string]lldpEntry),
+		done:       make(chan struct{}),
+	}
+
+	filter := lldpFilter
+	if *lldpCDP {
+		filter += " or " + cdpFilter
+	}
+	for _, dev := range devices {
+		if strings.Contains(dev.Name, "Loopback") {
+			continue
+		}
+		// LLDP and CDP are sent to link-local multicast addresses, which
+		// many adapters only pass up in promiscuous mode.
+		h, err := wpcap.OpenLive(dev.Name, 1518, true, 1000)
+		if err != nil {
+			lldpLogger.Warnf("Failed to capture on %s: %v", dev.Description, err)
+			continue
+		}
+		if err := h.SetFilter(filter); err != nil {
+			h.Close()
+			// Stop the captures already started on other interfaces.
+			c.Close()
+			return nil, err
+		}
+		// Like the wifi collector, series are keyed by the GUID of the
+		// interface, as the description of the adapter changes with driver
+		// updates.
+		iface := lldpInterfaceGUID(dev.Name)
+		c.interfaces[iface] = dev.Description
+		lldpLogger.Debugf("Capturing LLDP on %s (%s)", iface, dev.Description)
+		c.captures.Add(1)
+		go c.capture(h, iface)
+	}
+	return c, nil
+}
+
+// lldpInterfaceGUID returns the GUID of the interface of an Npcap device, named
+// \Device\NPF_{GUID}, or the name of the device if it has none.
+func lldpInterfaceGUID(name string) string {
+	i := strings.Index(name, "NPF_{")
+	if i < 0 || !strings.HasSuffix(name, "}") {
+		return name
+	}
+	return name[i+len("NPF_"):]
+}
+
+// capture records the neighbors announced on the interface until the
+// capture fails or the collector is closed.
+func (c *LLDPCollector) capture(h *wpcap.Handle, iface string) {
+	defer c.captures.Done()
+	defer h.Close()
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		frame, err := h.Next()
+		if err == wpcap.ErrTimeout {
+			continue
+		}
+		if err != nil {
+			lldpLogger.Errorf("Stopped capturing LLDP on %s: %v", iface, err)
+			return
+		}
+		n, err := parseNeighborFrame(frame)
+		if err != nil {
+			lldpLogger.Debugf("Ignoring invalid frame on %s: %v", iface, err)
+			continue
+		}
+		key := strings.Join([]string{iface, n.protocol, n.chassisID, n.portID}, "\x00")
+		c.mu.Lock()
+		c.neighbors[key] = lldpEntry{lldpNeighbor: n, iface: iface, lastSeen: time.Now()}
+		c.mu.Unlock()
+	}
+}
+
+// Close stops the captures, which notice within the read timeout of a second.
+func (c *LLDPCollector) Close() error {
+	close(c.done)
+	c.captures.Wait()
+	return nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *LLDPCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for iface, description := range c.interfaces {
+		ch <- newInfoMetric(c.InterfaceInfo, iface, description)
+	}
+
+	now := time.Now()
+	for key, n := range c.neighbors {
+		// Neighbors that stopped announcing themselves are forgotten once
+		// their announced time to live expired.
+		if now.Sub(n.lastSeen) > n.ttl {
+			delete(c.neighbors, key)
+			continue
+		}
+		ch <- newInfoMetric(
+			c.NeighborInfo,
+			n.iface, n.protocol, n.chassisID, n.portID,
+			n.systemName, n.portDescription, n.vlan,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.NeighborLastSeen,
+			prometheus.GaugeValue,
+			float64(n.lastSeen.UnixNano())/1e9,
+			n.iface, n.protocol, n.chassisID, n.portID,
+		)
+	}
+	return nil
+}
+
+// parseNeighborFrame decodes an Ethernet frame holding an LLDPDU or a CDP
+// announcement.
+func parseNeighborFrame(frame []byte) (lldpNeighbor, error) {
+	if len(frame) < 14 {
+		return lldpNeighbor{}, errors.New("truncated frame")
+	}
+	offset := 12
+	typeOrLength := binary.BigEndian.Uint16(frame[offset:])
+	if typeOrLength == 0x8100 && len(frame) >= 18 {
+		offset += 4
+		typeOrLength = binary.BigEndian.Uint16(frame[offset:])
+	}
+	payload := frame[offset+2:]
+
+	switch {
+	case typeOrLength == lldpEtherType:
+		return parseLLDP(payload)
+	case typeOrLength <= 1500 && len(payload) >= 8 &&
+		string(payload[:8]) == "\xaa\xaa\x03\x00\x00\x0c\x20\x00":
+		// 802.3 frame with a SNAP header for Cisco's OUI and the CDP
+		// protocol ID.
+		return parseCDP(payload[8:])
+	}
+	return lldpNeighbor{}, fmt.Errorf("unexpected EtherType 0x%04x", typeOrLength)
+}
+
+// parseLLDP decodes an LLDPDU, a sequence of TLVs with a 7 bit type and 9 bit
+// length.
+// https://standards.ieee.org/standard/802_1AB-2016.html
+func parseLLDP(b []byte) (lldpNeighbor, error) {
+	n := lldpNeighbor{protocol: "lldp"}
+	for len(b) >= 2 {
+		header := binary.BigEndian.Uint16(b)
+		typ, length := header>>9, int(header&0x1ff)
+		if len(b) < 2+length {
+			return n, errors.New("truncated TLV")
+		}
+		value := b[2 : 2+length]
+		b = b[2+length:]
+
+		switch typ {
+		case 0: // End of LLDPDU
+			b = nil
+		case 1: // Chassis ID
+			if length > 1 {
+				n.chassisID = lldpID(value[0], 4, value[1:])
+			}
+		case 2: // Port ID
+			if length > 1 {
+				n.portID = lldpID(value[0], 3, value[1:])
+			}
+		case 3: // Time To Live
+			if length >= 2 {
+				n.ttl = time.Duration(binary.BigEndian.Uint16(value)) * time.Second
+			}
+		case 4: // Port Description
+			n.portDescription = string(value)
+		case 5: // System Name
+			n.systemName = string(value)
+		case 127: // Organizationally Specific
+			// IEEE 802.1 Port VLAN ID
+			if length >= 6 && string(value[:4]) == "\x00\x80\xc2\x01" {
+				n.vlan = strconv.Itoa(int(binary.BigEndian.Uint16(value[4:])))
+			}
+		}
+	}
+	if n.chassisID == "" || n.portID == "" {
+		return n, errors.New("LLDPDU lacks chassis or port ID")
+	}
+	return n, nil
+}
+
+// lldpID formats a chassis or port ID, which is a MAC address if subtype is
+// macSubtype, and text for the other commonly used subtypes.
+func lldpID(subtype byte, macSubtype byte, value []byte) string {
+	if subtype == macSubtype && len(value) == 6 {
+		return net.HardwareAddr(value).String()
+	}
+	return string(value)
+}
+
+// parseCDP decodes a CDP announcement, a header followed by TLVs with a 16 bit
+// type and a 16 bit length including the TLV header.
+func parseCDP(b []byte) (lldpNeighbor, error) {
+	if len(b) < 4 {
+		return lldpNeighbor{}, errors.New("truncated CDP header")
+	}
+	n := lldpNeighbor{
+		protocol: "cdp",
+		ttl:      time.Duration(b[1]) * time.Second,
+	}
+	b = b[4:]
+	for len(b) >= 4 {
+		typ := binary.BigEndian.Uint16(b)
+		length := int(binary.BigEndian.Uint16(b[2:]))
+		if length < 4 || len(b) < length {
+			return n, errors.New("truncated TLV")
+		}
+		value := b[4:length]
+		b = b[length:]
+
+		switch typ {
+		case 0x0001: // Device ID
+			n.chassisID = string(value)
+			n.systemName = string(value)
+		case 0x0003: // Port ID
+			n.portID = string(value)
+		case 0x000a: // Native VLAN
+			if len(value) >= 2 {
+				n.vlan = strconv.Itoa(int(binary.BigEndian.Uint16(value)))
+			}
+		}
+	}
+	if n.chassisID == "" || n.portID == "" {
+		return n, errors.New("CDP announcement lacks device or port ID")
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func lldpTLV(typ int, value string) string {
+	header := typ<<9 | len(value)
+	return string([]byte{byte(header >> 8), byte(header)}) + value
+}
+
+func TestParseNeighborFrame(t *testing.T) {
+	ethernet := "\x01\x80\xc2\x00\x00\x0e" + "\x00\x11\x22\x33\x44\x55"
+	lldp := ethernet + "\x88\xcc" +
+		lldpTLV(1, "\x04\x00\x1b\x54\xaa\xbb\xcc") +
+		lldpTLV(2, "\x05Gi1/0/12") +
+		lldpTLV(3, "\x00\x78") +
+		lldpTLV(4, "uplink to srv01") +
+		lldpTLV(5, "sw-core-01") +
+		lldpTLV(127, "\x00\x80\xc2\x01\x00\x64") +
+		lldpTLV(0, "")
+
+	cdpTLV := func(typ int, value string) string {
+		length := 4 + len(value)
+		return string([]byte{0, byte(typ), byte(length >> 8), byte(length)}) + value
+	}
+	cdpPayload := "\x02\xb4\x00\x00" +
+		cdpTLV(1, "sw-access-02") +
+		cdpTLV(3, "GigabitEthernet0/3") +
+		cdpTLV(10, "\x00\x0a")
+	snap := "\xaa\xaa\x03\x00\x00\x0c\x20\x00"
+	cdp := "\x01\x00\x0c\xcc\xcc\xcc" + "\x00\x11\x22\x33\x44\x55" +
+		string([]byte{0, byte(len(snap) + len(cdpPayload))}) + snap + cdpPayload
+
+	cases := []struct {
+		frame    string
+		expected lldpNeighbor
+	}{
+		{lldp, lldpNeighbor{"lldp", "00:1b:54:aa:bb:cc", "sw-core-01", "Gi1/0/12", "uplink to srv01", "100", 120 * time.Second}},
+		{cdp, lldpNeighbor{"cdp", "sw-access-02", "sw-access-02", "GigabitEthernet0/3", "", "10", 180 * time.Second}},
+	}
+	for _, c := range cases {
+		n, err := parseNeighborFrame([]byte(c.frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != c.expected {
+			t.Errorf("expected %+v, got %+v", c.expected, n)
+		}
+	}
+
+	if _, err := parseNeighborFrame([]byte(ethernet + "\x08\x00" + "\x45\x00")); err == nil {
+		t.Error("expected an error for an IPv4 frame")
+	}
+}
+
+func TestLLDPInterfaceGUID(t *testing.T) {
+	cases := map[string]string{
+		`\Device\NPF_{6B1B8C4A-2D3E-4F50-9A61-7C8D9E0F1A2B}`: "{6B1B8C4A-2D3E-4F50-9A61-7C8D9E0F1A2B}",
+		`\Device\NPF_Loopback`:                               `\Device\NPF_Loopback`,
+	}
+	for name, expected := range cases {
+		if got := lldpInterfaceGUID(name); got != expected {
+			t.Errorf("Interface GUID does not match!\nExpected result: %s\nActual result: %s", expected, got)
+		}
+	}
+}
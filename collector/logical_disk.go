@@ -15,6 +15,8 @@ func init() {
 	registerCollector("logical_disk", NewLogicalDiskCollector, "LogicalDisk")
 }
 
+var logicalDiskLogger = log.With("collector", "logical_disk")
+
 var (
 	volumeWhitelist = kingpin.Flag(
 		"collector.logical_disk.volume-whitelist",
@@ -159,7 +161,7 @@ func NewLogicalDiskCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *LogicalDiskCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting logical_disk metrics:", desc, err)
+		logicalDiskLogger.Error("failed collecting logical_disk metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -188,7 +190,7 @@ type logicalDisk struct {
 
 func (c *LogicalDiskCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []logicalDisk
-	if err := unmarshalObject(ctx.perfObjects["LogicalDisk"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["LogicalDisk"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -279,21 +281,21 @@ func (c *LogicalDiskCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.
 		ch <- prometheus.MustNewConstMetric(
 			c.ReadLatency,
 			prometheus.CounterValue,
-			volume.AvgDiskSecPerRead*ticksToSecondsScaleFactor,
+			volume.AvgDiskSecPerRead,
 			volume.Name,
 		)
 
 		ch <- prometheus.MustNewConstMetric(
 			c.WriteLatency,
 			prometheus.CounterValue,
-			volume.AvgDiskSecPerWrite*ticksToSecondsScaleFactor,
+			volume.AvgDiskSecPerWrite,
 			volume.Name,
 		)
 
 		ch <- prometheus.MustNewConstMetric(
 			c.ReadWriteLatency,
 			prometheus.CounterValue,
-			volume.AvgDiskSecPerTransfer*ticksToSecondsScaleFactor,
+			volume.AvgDiskSecPerTransfer,
 			volume.Name,
 		)
 	}
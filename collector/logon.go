@@ -3,43 +3,135 @@
 package collector
 
 import (
+	"encoding/xml"
 	"errors"
+	"strings"
+	"sync"
 
-	"github.com/StackExchange/wmi"
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/headers/wtsapi32"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 func init() {
 	registerCollector("logon", NewLogonCollector)
 }
 
+var logonLogger = log.With("collector", "logon")
+
+var logonCountEvents = kingpin.Flag(
+	"collector.logon.count-events",
+	"Count the logons, failed logons and logoffs audited in the Security event log.",
+).Default("false").Bool()
+
+// Events of the Security log auditing logons and logoffs, when the Audit
+// Logon and Audit Logoff policies are enabled.
+const (
+	logonEventLogon       = "4624"
+	logonEventFailedLogon = "4625"
+	logonEventLogoff      = "4634"
+
+	logonEventsXPath = "*[System[(EventID=4624 or EventID=4625 or EventID=4634)]]"
+)
+
+// logonTypeNames are the names of the LogonType values, as in the status
+// label of windows_logon_logon_type.
+var logonTypeNames = map[string]string{
+	"0":  "system",
+	"2":  "interactive",
+	"3":  "network",
+	"4":  "batch",
+	"5":  "service",
+	"6":  "proxy",
+	"7":  "unlock",
+	"8":  "network_clear_text",
+	"9":  "new_credentials",
+	"10": "remote_interactive",
+	"11": "cached_interactive",
+	"12": "cached_remote_interactive",
+	"13": "cached_unlock",
+}
+
 // A LogonCollector is a Prometheus collector for WMI metrics
 type LogonCollector struct {
-	LogonType *prometheus.Desc
+	LogonType    *prometheus.Desc
+	Logons       *prometheus.Desc
+	FailedLogons *prometheus.Desc
+	Logoffs      *prometheus.Desc
+	ConsoleUser  *prometheus.Desc
+
+	mu sync.Mutex
+	// events is nil unless --collector.logon.count-events is set.
+	events *logonEventTail
 }
 
 // NewLogonCollector ...
 func NewLogonCollector() (Collector, error) {
 	const subsystem = "logon"
 
-	return &LogonCollector{
+	c := &LogonCollector{
 		LogonType: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "logon_type"),
 			"Number of active logon sessions (LogonSession.LogonType)",
 			[]string{"status"},
 			nil,
 		),
-	}, nil
+		Logons: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "logons_total"),
+			"Successful logons of the type audited in the Security log since the exporter started",
+			[]string{"logon_type"},
+			nil,
+		),
+		FailedLogons: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "failed_logons_total"),
+			"Failed logons of the type audited in the Security log since the exporter started",
+			[]string{"logon_type"},
+			nil,
+		),
+		Logoffs: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "logoffs_total"),
+			"Logoffs of sessions of the type audited in the Security log since the exporter started",
+			[]string{"logon_type"},
+			nil,
+		),
+		ConsoleUser: newInfoDesc(
+			"logon_console_user",
+			"The user logged on to the console session",
+			nil,
+			"user",
+		),
+	}
+
+	if *logonCountEvents {
+		c.events = newLogonEventTail()
+		// Start counting now rather than on the first scrape, if the
+		// Security log can already be read.
+		if err := c.events.update(); err != nil {
+			logonLogger.Warnf("Failed to read the Security event log: %v", err)
+		}
+	}
+	return c, nil
 }
 
 // Collect sends the metric values for each metric
 // to the provided prometheus Metric channel.
 func (c *LogonCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting user metrics:", desc, err)
+		logonLogger.Error("failed collecting user metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectConsoleUser(ch); err != nil {
+		logonLogger.Error("failed collecting logon console user metrics:", desc, err)
 		return err
 	}
+	if c.events != nil {
+		if desc, err := c.collectEvents(ch); err != nil {
+			logonLogger.Error("failed collecting logon event metrics:", desc, err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -52,7 +144,7 @@ type Win32_LogonSession struct {
 func (c *LogonCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_LogonSession
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 	if len(dst) == 0 {
@@ -197,3 +289,134 @@ func (c *LogonCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc,
 	)
 	return nil, nil
 }
+
+func (c *LogonCollector) collectConsoleUser(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	sessions, err := wtsapi32.ListSessions()
+	if err != nil {
+		return c.ConsoleUser, err
+	}
+	for _, s := range sessions {
+		if s.StationName == "Console" && s.User != "" {
+			ch <- newInfoMetric(c.ConsoleUser, s.User)
+		}
+	}
+	return nil, nil
+}
+
+func (c *LogonCollector) collectEvents(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.events.update(); err != nil {
+		return nil, err
+	}
+	for _, e := range []struct {
+		desc   *prometheus.Desc
+		counts map[string]float64
+	}{
+		{c.Logons, c.events.logons},
+		{c.FailedLogons, c.events.failedLogons},
+		{c.Logoffs, c.events.logoffs},
+	} {
+		for logonType, n := range e.counts {
+			ch <- prometheus.MustNewConstMetric(
+				e.desc,
+				prometheus.CounterValue,
+				n,
+				logonType,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// logonEventTail counts the logon and logoff events of the Security log
+// logged since the collector started, like the tails of the eventlog
+// collector.
+type logonEventTail struct {
+	started  bool
+	recordID uint64
+
+	logons       map[string]float64
+	failedLogons map[string]float64
+	logoffs      map[string]float64
+}
+
+func newLogonEventTail() *logonEventTail {
+	return &logonEventTail{
+		logons:       make(map[string]float64),
+		failedLogons: make(map[string]float64),
+		logoffs:      make(map[string]float64),
+	}
+}
+
+// update counts the events logged since the last update. The first update
+// only records the newest event.
+func (t *logonEventTail) update() error {
+	last, err := wevtapi.LastRecordID("Security")
+	if err != nil {
+		return err
+	}
+	if !t.started {
+		t.started = true
+		t.recordID = last
+		return nil
+	}
+	if last < t.recordID {
+		// The log was cleared, and its record IDs started over.
+		t.recordID = 0
+	}
+	if last == t.recordID {
+		return nil
+	}
+
+	events, err := wevtapi.Query("", eventlogStructuredQuery("Security", logonEventsXPath, t.recordID))
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		t.count(e)
+	}
+	return nil
+}
+
+// logonEvent is the part of a logon or logoff event read by the collector.
+type logonEvent struct {
+	EventID  string `xml:"System>EventID"`
+	RecordID uint64 `xml:"System>EventRecordID"`
+	Data     []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+}
+
+// count counts the event by the type of the logon, and advances the record ID
+// past it.
+func (t *logonEventTail) count(eventXML string) {
+	var e logonEvent
+	if err := xml.Unmarshal([]byte(eventXML), &e); err != nil {
+		logonLogger.Debugf("Ignoring invalid Security event: %v", err)
+		return
+	}
+	if e.RecordID > t.recordID {
+		t.recordID = e.RecordID
+	}
+
+	logonType := "unknown"
+	for _, d := range e.Data {
+		if d.Name == "LogonType" {
+			if name, ok := logonTypeNames[strings.TrimSpace(d.Value)]; ok {
+				logonType = name
+			}
+			break
+		}
+	}
+	switch strings.TrimSpace(e.EventID) {
+	case logonEventLogon:
+		t.logons[logonType]++
+	case logonEventFailedLogon:
+		t.failedLogons[logonType]++
+	case logonEventLogoff:
+		t.logoffs[logonType]++
+	}
+}
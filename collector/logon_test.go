@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -8,3 +9,30 @@ func BenchmarkLogonCollector(b *testing.B) {
 	// No context name required as collector source is WMI
 	benchmarkCollector(b, "", NewLogonCollector)
 }
+
+func TestLogonEventTailCount(t *testing.T) {
+	tail := newLogonEventTail()
+	for _, e := range []string{
+		`<Event><System><EventID>4624</EventID><EventRecordID>10</EventRecordID></System><EventData><Data Name="TargetUserName">alice</Data><Data Name="LogonType">10</Data></EventData></Event>`,
+		`<Event><System><EventID>4624</EventID><EventRecordID>11</EventRecordID></System><EventData><Data Name="LogonType">3</Data></EventData></Event>`,
+		`<Event><System><EventID>4625</EventID><EventRecordID>13</EventRecordID></System><EventData><Data Name="LogonType">10</Data></EventData></Event>`,
+		`<Event><System><EventID>4634</EventID><EventRecordID>12</EventRecordID></System><EventData><Data Name="LogonType">42</Data></EventData></Event>`,
+	} {
+		tail.count(e)
+	}
+
+	if tail.recordID != 13 {
+		t.Errorf("Expected record ID 13, got %d", tail.recordID)
+	}
+	for name, c := range map[string]struct {
+		expected, actual map[string]float64
+	}{
+		"logons":        {map[string]float64{"remote_interactive": 1, "network": 1}, tail.logons},
+		"failed logons": {map[string]float64{"remote_interactive": 1}, tail.failedLogons},
+		"logoffs":       {map[string]float64{"unknown": 1}, tail.logoffs},
+	} {
+		if !reflect.DeepEqual(c.actual, c.expected) {
+			t.Errorf("%s do not match!\nExpected result: %v\nActual result: %v", name, c.expected, c.actual)
+		}
+	}
+}
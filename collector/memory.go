@@ -14,6 +14,8 @@ func init() {
 	registerCollector("memory", NewMemoryCollector, "Memory")
 }
 
+var memoryLogger = log.With("collector", "memory")
+
 // A MemoryCollector is a Prometheus collector for perflib Memory metrics
 type MemoryCollector struct {
 	AvailableBytes                  *prometheus.Desc
@@ -64,31 +66,31 @@ func NewMemoryCollector() (Collector, error) {
 		),
 		CacheBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cache_bytes"),
-			"(CacheBytes)",
+			counterHelp("Memory", memory{}, "CacheBytes"),
 			nil,
 			nil,
 		),
 		CacheBytesPeak: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cache_bytes_peak"),
-			"(CacheBytesPeak)",
+			counterHelp("Memory", memory{}, "CacheBytesPeak"),
 			nil,
 			nil,
 		),
 		CacheFaultsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cache_faults_total"),
-			"(CacheFaultsPersec)",
+			counterHelp("Memory", memory{}, "CacheFaultsPersec"),
 			nil,
 			nil,
 		),
 		CommitLimit: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "commit_limit"),
-			"(CommitLimit)",
+			counterHelp("Memory", memory{}, "CommitLimit"),
 			nil,
 			nil,
 		),
 		CommittedBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "committed_bytes"),
-			"(CommittedBytes)",
+			counterHelp("Memory", memory{}, "CommittedBytes"),
 			nil,
 			nil,
 		),
@@ -101,25 +103,25 @@ func NewMemoryCollector() (Collector, error) {
 		),
 		FreeAndZeroPageListBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "free_and_zero_page_list_bytes"),
-			"(FreeAndZeroPageListBytes)",
+			counterHelp("Memory", memory{}, "FreeAndZeroPageListBytes"),
 			nil,
 			nil,
 		),
 		FreeSystemPageTableEntries: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "free_system_page_table_entries"),
-			"(FreeSystemPageTableEntries)",
+			counterHelp("Memory", memory{}, "FreeSystemPageTableEntries"),
 			nil,
 			nil,
 		),
 		ModifiedPageListBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "modified_page_list_bytes"),
-			"(ModifiedPageListBytes)",
+			counterHelp("Memory", memory{}, "ModifiedPageListBytes"),
 			nil,
 			nil,
 		),
 		PageFaultsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "page_faults_total"),
-			"(PageFaultsPersec)",
+			counterHelp("Memory", memory{}, "PageFaultsPersec"),
 			nil,
 			nil,
 		),
@@ -162,85 +164,85 @@ func NewMemoryCollector() (Collector, error) {
 		),
 		PoolNonpagedBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pool_nonpaged_bytes_total"),
-			"(PoolNonpagedBytes)",
+			counterHelp("Memory", memory{}, "PoolNonpagedBytes"),
 			nil,
 			nil,
 		),
 		PoolPagedAllocsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pool_paged_allocs_total"),
-			"(PoolPagedAllocs)",
+			counterHelp("Memory", memory{}, "PoolPagedAllocs"),
 			nil,
 			nil,
 		),
 		PoolPagedBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pool_paged_bytes"),
-			"(PoolPagedBytes)",
+			counterHelp("Memory", memory{}, "PoolPagedBytes"),
 			nil,
 			nil,
 		),
 		PoolPagedResidentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "pool_paged_resident_bytes"),
-			"(PoolPagedResidentBytes)",
+			counterHelp("Memory", memory{}, "PoolPagedResidentBytes"),
 			nil,
 			nil,
 		),
 		StandbyCacheCoreBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "standby_cache_core_bytes"),
-			"(StandbyCacheCoreBytes)",
+			counterHelp("Memory", memory{}, "StandbyCacheCoreBytes"),
 			nil,
 			nil,
 		),
 		StandbyCacheNormalPriorityBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "standby_cache_normal_priority_bytes"),
-			"(StandbyCacheNormalPriorityBytes)",
+			counterHelp("Memory", memory{}, "StandbyCacheNormalPriorityBytes"),
 			nil,
 			nil,
 		),
 		StandbyCacheReserveBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "standby_cache_reserve_bytes"),
-			"(StandbyCacheReserveBytes)",
+			counterHelp("Memory", memory{}, "StandbyCacheReserveBytes"),
 			nil,
 			nil,
 		),
 		SystemCacheResidentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "system_cache_resident_bytes"),
-			"(SystemCacheResidentBytes)",
+			counterHelp("Memory", memory{}, "SystemCacheResidentBytes"),
 			nil,
 			nil,
 		),
 		SystemCodeResidentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "system_code_resident_bytes"),
-			"(SystemCodeResidentBytes)",
+			counterHelp("Memory", memory{}, "SystemCodeResidentBytes"),
 			nil,
 			nil,
 		),
 		SystemCodeTotalBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "system_code_total_bytes"),
-			"(SystemCodeTotalBytes)",
+			counterHelp("Memory", memory{}, "SystemCodeTotalBytes"),
 			nil,
 			nil,
 		),
 		SystemDriverResidentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "system_driver_resident_bytes"),
-			"(SystemDriverResidentBytes)",
+			counterHelp("Memory", memory{}, "SystemDriverResidentBytes"),
 			nil,
 			nil,
 		),
 		SystemDriverTotalBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "system_driver_total_bytes"),
-			"(SystemDriverTotalBytes)",
+			counterHelp("Memory", memory{}, "SystemDriverTotalBytes"),
 			nil,
 			nil,
 		),
 		TransitionFaultsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transition_faults_total"),
-			"(TransitionFaultsPersec)",
+			counterHelp("Memory", memory{}, "TransitionFaultsPersec"),
 			nil,
 			nil,
 		),
 		TransitionPagesRepurposedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transition_pages_repurposed_total"),
-			"(TransitionPagesRePurposedPersec)",
+			counterHelp("Memory", memory{}, "TransitionPagesRePurposedPersec"),
 			nil,
 			nil,
 		),
@@ -257,7 +259,7 @@ func NewMemoryCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *MemoryCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting memory metrics:", desc, err)
+		memoryLogger.Error("failed collecting memory metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -302,7 +304,7 @@ type memory struct {
 
 func (c *MemoryCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []memory
-	if err := unmarshalObject(ctx.perfObjects["Memory"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["Memory"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
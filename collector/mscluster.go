@@ -0,0 +1,415 @@
+// +build windows
+
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("mscluster", newMSClusterCollector, "Cluster NetFt Heartbeats")
+}
+
+var msclusterLogger = log.With("collector", "mscluster")
+
+const msclusterNamespace = "root\\MSCluster"
+
+// msclusterNetworkStates maps the State of MSCluster_Network to the state
+// label, in the order of their value starting at -1.
+var msclusterNetworkStates = []string{"unknown", "unavailable", "down", "partitioned", "up"}
+
+// msclusterNodeStates maps the State of MSCluster_Node to the state label, in
+// the order of their value starting at -1.
+var msclusterNodeStates = []string{"unknown", "up", "down", "paused", "joining"}
+
+// msclusterGroupStates maps the State of MSCluster_ResourceGroup to the state
+// label, in the order of their value starting at -1.
+var msclusterGroupStates = []string{"unknown", "online", "offline", "failed", "partial_online", "pending"}
+
+// msclusterResourceStates maps the State of MSCluster_Resource to the state
+// label.
+var msclusterResourceStates = map[int32]string{
+	-1:  "unknown",
+	0:   "inherited",
+	1:   "initializing",
+	2:   "online",
+	3:   "offline",
+	4:   "failed",
+	128: "pending",
+	129: "online_pending",
+	130: "offline_pending",
+}
+
+// A MSClusterCollector is a Prometheus collector for the nodes, roles,
+// networks, heartbeats and quorum witness of the failover cluster the local
+// computer is a node of
+type MSClusterCollector struct {
+	NodeState                 *prometheus.Desc
+	NodeVote                  *prometheus.Desc
+	GroupState                *prometheus.Desc
+	GroupOwner                *prometheus.Desc
+	GroupOwnerChangesTotal    *prometheus.Desc
+	NetworkState              *prometheus.Desc
+	HeartbeatRoundTripSeconds *prometheus.Desc
+	HeartbeatsMissing         *prometheus.Desc
+	HeartbeatsLostTotal       *prometheus.Desc
+	WitnessState              *prometheus.Desc
+	WitnessVote               *prometheus.Desc
+
+	mu sync.Mutex
+	// groupOwners holds the owner node of each group as of the last scrape,
+	// and groupOwnerChanges the owner changes seen since the exporter started.
+	groupOwners       map[string]string
+	groupOwnerChanges map[string]float64
+}
+
+func newMSClusterCollector() (Collector, error) {
+	const subsystem = "mscluster"
+
+	return &MSClusterCollector{
+		NodeState: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "node_state"),
+			"The state of the cluster node (1 for the current state, 0 for the others)",
+			[]string{"node", "state"},
+			nil,
+		),
+		NodeVote: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "node_vote"),
+			"Whether the cluster node currently has a quorum vote, as adjusted by dynamic quorum",
+			[]string{"node"},
+			nil,
+		),
+		GroupState: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "group_state"),
+			"The state of the resource group, i.e. cluster role (1 for the current state, 0 for the others)",
+			[]string{"group", "state"},
+			nil,
+		),
+		GroupOwner: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "group_owner"),
+			"The node currently owning the resource group, always 1",
+			[]string{"group", "owner_node"},
+			nil,
+		),
+		GroupOwnerChangesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "group_owner_changes_total"),
+			"Number of times the resource group was seen moving to another node, by failover or manually, since the exporter started",
+			[]string{"group"},
+			nil,
+		),
+		NetworkState: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "network_state"),
+			"The state of the cluster network (1 for the current state, 0 for the others)",
+			[]string{"network", "state"},
+			nil,
+		),
+		HeartbeatRoundTripSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "heartbeat_round_trip_seconds"),
+			"Round-trip time of the heartbeats on the route to another node",
+			[]string{"route"},
+			nil,
+		),
+		HeartbeatsMissing: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "heartbeats_missing"),
+			"Consecutive heartbeats currently missing on the route to another node",
+			[]string{"route"},
+			nil,
+		),
+		HeartbeatsLostTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "heartbeats_lost_total"),
+			"Total heartbeats lost on the route to another node",
+			[]string{"route"},
+			nil,
+		),
+		WitnessState: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "witness_state"),
+			"The state of the quorum witness resource (1 for the current state, 0 for the others)",
+			[]string{"name", "type", "state"},
+			nil,
+		),
+		WitnessVote: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "witness_vote"),
+			"Whether the quorum witness currently has a vote, as adjusted by dynamic quorum",
+			[]string{"cluster"},
+			nil,
+		),
+		groupOwners:       make(map[string]string),
+		groupOwnerChanges: make(map[string]float64),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *MSClusterCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectNodes(ctx, ch); err != nil {
+		msclusterLogger.Error("failed collecting mscluster node metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectGroups(ctx, ch); err != nil {
+		msclusterLogger.Error("failed collecting mscluster group metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectNetworks(ctx, ch); err != nil {
+		msclusterLogger.Error("failed collecting mscluster network metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectHeartbeats(ctx, ch); err != nil {
+		msclusterLogger.Error("failed collecting mscluster heartbeat metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectWitness(ctx, ch); err != nil {
+		msclusterLogger.Error("failed collecting mscluster witness metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// MSCluster_Node docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/cluswmi/mscluster-node
+type MSCluster_Node struct {
+	Name          string
+	State         int32
+	DynamicWeight uint32
+}
+
+func (c *MSClusterCollector) collectNodes(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []MSCluster_Node
+	if err := queryWMIContext(ctx.Context(), queryAll(&dst), &dst, nil, msclusterNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, node := range dst {
+		for i, state := range msclusterNodeStates {
+			isCurrentState := 0.0
+			if int(node.State) == i-1 {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.NodeState,
+				prometheus.GaugeValue,
+				isCurrentState,
+				node.Name,
+				state,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.NodeVote,
+			prometheus.GaugeValue,
+			float64(node.DynamicWeight),
+			node.Name,
+		)
+	}
+	return nil, nil
+}
+
+// MSCluster_ResourceGroup docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/cluswmi/mscluster-resourcegroup
+type MSCluster_ResourceGroup struct {
+	Name      string
+	State     int32
+	OwnerNode string
+}
+
+func (c *MSClusterCollector) collectGroups(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []MSCluster_ResourceGroup
+	if err := queryWMIContext(ctx.Context(), queryAll(&dst), &dst, nil, msclusterNamespace); err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]string, len(dst))
+	for _, group := range dst {
+		owners[group.Name] = group.OwnerNode
+	}
+	c.mu.Lock()
+	changes := countOwnerChanges(c.groupOwners, c.groupOwnerChanges, owners)
+	c.mu.Unlock()
+
+	for _, group := range dst {
+		for i, state := range msclusterGroupStates {
+			isCurrentState := 0.0
+			if int(group.State) == i-1 {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.GroupState,
+				prometheus.GaugeValue,
+				isCurrentState,
+				group.Name,
+				state,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.GroupOwner,
+			prometheus.GaugeValue,
+			1.0,
+			group.Name,
+			group.OwnerNode,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.GroupOwnerChangesTotal,
+			prometheus.CounterValue,
+			changes[group.Name],
+			group.Name,
+		)
+	}
+	return nil, nil
+}
+
+// countOwnerChanges updates the last seen owner of each group to the current
+// one, counting the groups whose owner changed, and returns a copy of the
+// counts. Groups no longer present are forgotten.
+func countOwnerChanges(lastOwners map[string]string, changes map[string]float64, owners map[string]string) map[string]float64 {
+	for group := range lastOwners {
+		if _, ok := owners[group]; !ok {
+			delete(lastOwners, group)
+			delete(changes, group)
+		}
+	}
+	result := make(map[string]float64, len(owners))
+	for group, owner := range owners {
+		if last, ok := lastOwners[group]; ok && last != owner {
+			changes[group]++
+		}
+		lastOwners[group] = owner
+		result[group] = changes[group]
+	}
+	return result
+}
+
+// MSCluster_Network docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/cluswmi/mscluster-network
+type MSCluster_Network struct {
+	Name  string
+	State int32
+}
+
+func (c *MSClusterCollector) collectNetworks(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []MSCluster_Network
+	if err := queryWMIContext(ctx.Context(), queryAll(&dst), &dst, nil, msclusterNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, network := range dst {
+		for i, state := range msclusterNetworkStates {
+			isCurrentState := 0.0
+			if int(network.State) == i-1 {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.NetworkState,
+				prometheus.GaugeValue,
+				isCurrentState,
+				network.Name,
+				state,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// clusterNetFtHeartbeats holds the counters of a route of the cluster network
+// driver to another node.
+type clusterNetFtHeartbeats struct {
+	Name string
+
+	RoundTripLatency  float64 `perflib:"Round-trip Latency (ms)"`
+	MissingHeartbeats float64 `perflib:"Missing heartbeats"`
+	LostHeartbeats    float64 `perflib:"Lost heartbeats"`
+}
+
+func (c *MSClusterCollector) collectHeartbeats(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The object only exists while the cluster service is running.
+	if ctx.perfObjects["Cluster NetFt Heartbeats"] == nil {
+		return nil, nil
+	}
+	var dst []clusterNetFtHeartbeats
+	if err := unmarshalObject(ctx.perfObjects["Cluster NetFt Heartbeats"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, route := range dst {
+		if route.Name == "_Total" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.HeartbeatRoundTripSeconds,
+			prometheus.GaugeValue,
+			route.RoundTripLatency/1000,
+			route.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.HeartbeatsMissing,
+			prometheus.GaugeValue,
+			route.MissingHeartbeats,
+			route.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.HeartbeatsLostTotal,
+			prometheus.CounterValue,
+			route.LostHeartbeats,
+			route.Name,
+		)
+	}
+	return nil, nil
+}
+
+// msclusterWitness is the MSCluster_Resource of a quorum witness.
+type msclusterWitness struct {
+	Name  string
+	Type  string
+	State int32
+}
+
+// MSCluster_Cluster docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/cluswmi/mscluster-cluster
+type MSCluster_Cluster struct {
+	Name                 string
+	WitnessDynamicWeight uint32
+}
+
+func (c *MSClusterCollector) collectWitness(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// A disk witness is the only physical disk among the core resources.
+	var witnesses []msclusterWitness
+	q := "SELECT Name, Type, State FROM MSCluster_Resource WHERE Type = 'File Share Witness' OR Type = 'Cloud Witness' OR (Type = 'Physical Disk' AND CoreResource = TRUE)"
+	if err := queryWMIContext(ctx.Context(), q, &witnesses, nil, msclusterNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, witness := range witnesses {
+		current, ok := msclusterResourceStates[witness.State]
+		if !ok {
+			current = "unknown"
+		}
+		for _, state := range msclusterResourceStates {
+			isCurrentState := 0.0
+			if state == current {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.WitnessState,
+				prometheus.GaugeValue,
+				isCurrentState,
+				witness.Name,
+				witness.Type,
+				state,
+			)
+		}
+	}
+
+	var clusters []MSCluster_Cluster
+	if err := queryWMIContext(ctx.Context(), "SELECT Name, WitnessDynamicWeight FROM MSCluster_Cluster", &clusters, nil, msclusterNamespace); err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		ch <- prometheus.MustNewConstMetric(
+			c.WitnessVote,
+			prometheus.GaugeValue,
+			float64(cluster.WitnessDynamicWeight),
+			cluster.Name,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkMSClusterCollector(b *testing.B) {
+	benchmarkCollector(b, "mscluster", newMSClusterCollector)
+}
+
+func TestCountOwnerChanges(t *testing.T) {
+	lastOwners := make(map[string]string)
+	changes := make(map[string]float64)
+	scrapes := []struct {
+		owners   map[string]string
+		expected map[string]float64
+	}{
+		{
+			owners:   map[string]string{"SQL": "NODE1", "FS": "NODE2"},
+			expected: map[string]float64{"SQL": 0, "FS": 0},
+		},
+		{
+			owners:   map[string]string{"SQL": "NODE2", "FS": "NODE2"},
+			expected: map[string]float64{"SQL": 1, "FS": 0},
+		},
+		{
+			owners:   map[string]string{"SQL": "NODE1"},
+			expected: map[string]float64{"SQL": 2},
+		},
+		{
+			owners:   map[string]string{"SQL": "NODE1", "FS": "NODE1"},
+			expected: map[string]float64{"SQL": 2, "FS": 0},
+		},
+	}
+	for i, s := range scrapes {
+		actual := countOwnerChanges(lastOwners, changes, s.owners)
+		if !reflect.DeepEqual(actual, s.expected) {
+			t.Errorf("scrape %d: owner changes do not match!\nExpected result: %v\nActual result: %v", i, s.expected, actual)
+		}
+	}
+}
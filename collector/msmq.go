@@ -5,7 +5,6 @@ package collector
 import (
 	"strings"
 
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -15,6 +14,8 @@ func init() {
 	registerCollector("msmq", NewMSMQCollector)
 }
 
+var msmqLogger = log.With("collector", "msmq")
+
 var (
 	msmqWhereClause = kingpin.Flag("collector.msmq.msmq-where", "WQL 'where' clause to use in WMI metrics query. Limits the response to the msmqs you specify and reduces the size of the response.").String()
 )
@@ -34,7 +35,7 @@ func NewMSMQCollector() (Collector, error) {
 	const subsystem = "msmq"
 
 	if *msmqWhereClause == "" {
-		log.Warn("No where-clause specified for msmq collector. This will generate a very large number of metrics!")
+		msmqLogger.Warn("No where-clause specified for msmq collector. This will generate a very large number of metrics!")
 	}
 
 	return &Win32_PerfRawData_MSMQ_MSMQQueueCollector{
@@ -70,7 +71,7 @@ func NewMSMQCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *Win32_PerfRawData_MSMQ_MSMQQueueCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting msmq metrics:", desc, err)
+		msmqLogger.Error("failed collecting msmq metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -87,8 +88,8 @@ type Win32_PerfRawData_MSMQ_MSMQQueue struct {
 
 func (c *Win32_PerfRawData_MSMQ_MSMQQueueCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_MSMQ_MSMQQueue
-	q := queryAllWhere(&dst, c.queryWhereClause)
-	if err := wmi.Query(q, &dst); err != nil {
+	q := queryAllWhere(&dst, wqlLabelValuesWhere(c.queryWhereClause, "Name", allowedLabelValues("msmq")))
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
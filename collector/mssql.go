@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus-community/windows_exporter/headers/odbc32"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sys/windows/registry"
@@ -20,12 +23,27 @@ var (
 	mssqlEnabledCollectors = kingpin.Flag(
 		"collectors.mssql.classes-enabled",
 		"Comma-separated list of mssql WMI classes to use.").
-		Default(mssqlAvailableClassCollectors()).String()
+		Default(mssqlDefaultClassCollectors()).String()
 
 	mssqlPrintCollectors = kingpin.Flag(
 		"collectors.mssql.class-print",
 		"If true, print available mssql WMI classes and exit.  Only displays if the mssql collector is enabled.",
 	).Bool()
+
+	mssqlMaxConcurrency = kingpin.Flag(
+		"collectors.mssql.max-concurrency",
+		"Maximum number of mssql class collectors to run concurrently, across all instances. 0 for no limit.",
+	).Default("0").Int()
+
+	mssqlInstanceTimeout = kingpin.Flag(
+		"collectors.mssql.instance-timeout",
+		"Maximum time to wait for the class collectors of a single instance. Classes that didn't finish in time are reported as failed. 0 for no limit.",
+	).Default("0s").Duration()
+
+	mssqlODBCDriver = kingpin.Flag(
+		"collectors.mssql.odbc-driver",
+		"ODBC driver used by the backup class to query msdb, connecting with the exporter's Windows account.",
+	).Default("SQL Server").String()
 )
 
 type mssqlInstancesType map[string]string
@@ -40,19 +58,19 @@ func getMSSQLInstances() mssqlInstancesType {
 	regkey := `Software\Microsoft\Microsoft SQL Server\Instance Names\SQL`
 	k, err := registry.OpenKey(registry.LOCAL_MACHINE, regkey, registry.QUERY_VALUE)
 	if err != nil {
-		log.Warn("Couldn't open registry to determine SQL instances:", err)
+		mssqlLogger.Warn("Couldn't open registry to determine SQL instances:", err)
 		return sqlDefaultInstance
 	}
 	defer func() {
 		err = k.Close()
 		if err != nil {
-			log.Warnf("Failed to close registry key: %v", err)
+			mssqlLogger.Warnf("Failed to close registry key: %v", err)
 		}
 	}()
 
 	instanceNames, err := k.ReadValueNames(0)
 	if err != nil {
-		log.Warnf("Can't ReadSubKeyNames %#v", err)
+		mssqlLogger.Warnf("Can't ReadSubKeyNames %#v", err)
 		return sqlDefaultInstance
 	}
 
@@ -62,7 +80,7 @@ func getMSSQLInstances() mssqlInstancesType {
 		}
 	}
 
-	log.Debugf("Detected MSSQL Instances: %#v\n", sqlInstances)
+	mssqlLogger.Debugf("Detected MSSQL Instances: %#v\n", sqlInstances)
 
 	return sqlInstances
 }
@@ -70,6 +88,18 @@ func getMSSQLInstances() mssqlInstancesType {
 type mssqlCollectorsMap map[string]mssqlCollectorFunc
 
 func mssqlAvailableClassCollectors() string {
+	return "accessmethods,availreplica,backup,bufman,databases,dbreplica,genstats,locks,memmgr,sqlstats,sqlerrors,tempdb,transactions"
+}
+
+// mssqlQueryClasses are the classes that query the instances through ODBC
+// rather than reading their performance counters.
+var mssqlQueryClasses = map[string]bool{
+	"backup": true,
+	"tempdb": true,
+}
+
+// mssqlDefaultClassCollectors leaves out the mssqlQueryClasses.
+func mssqlDefaultClassCollectors() string {
 	return "accessmethods,availreplica,bufman,databases,dbreplica,genstats,locks,memmgr,sqlstats,sqlerrors,transactions"
 }
 
@@ -77,6 +107,7 @@ func (c *MSSQLCollector) getMSSQLCollectors() mssqlCollectorsMap {
 	mssqlCollectors := make(mssqlCollectorsMap)
 	mssqlCollectors["accessmethods"] = c.collectAccessMethods
 	mssqlCollectors["availreplica"] = c.collectAvailabilityReplica
+	mssqlCollectors["backup"] = c.collectBackups
 	mssqlCollectors["bufman"] = c.collectBufferManager
 	mssqlCollectors["databases"] = c.collectDatabases
 	mssqlCollectors["dbreplica"] = c.collectDatabaseReplica
@@ -85,6 +116,7 @@ func (c *MSSQLCollector) getMSSQLCollectors() mssqlCollectorsMap {
 	mssqlCollectors["memmgr"] = c.collectMemoryManager
 	mssqlCollectors["sqlstats"] = c.collectSQLStats
 	mssqlCollectors["sqlerrors"] = c.collectSQLErrors
+	mssqlCollectors["tempdb"] = c.collectTempDB
 	mssqlCollectors["transactions"] = c.collectTransactions
 
 	return mssqlCollectors
@@ -125,10 +157,21 @@ func mssqlGetPerfObjectName(sqlInstance string, collector string) string {
 	return (prefix + suffix)
 }
 
+// mssqlClusterResourceName returns the name SQL Server setup gives the
+// cluster resource of a failover cluster instance.
+func mssqlClusterResourceName(sqlInstance string) string {
+	if sqlInstance == "MSSQLSERVER" {
+		return "SQL Server"
+	}
+	return "SQL Server (" + sqlInstance + ")"
+}
+
 func init() {
 	registerCollector("mssql", NewMSSQLCollector)
 }
 
+var mssqlLogger = log.With("collector", "mssql")
+
 // A MSSQLCollector is a Prometheus collector for various WMI Win32_PerfRawData_MSSQLSERVER_* metrics
 type MSSQLCollector struct {
 	// meta
@@ -192,6 +235,10 @@ type MSSQLCollector struct {
 	AvailReplicaSendstoReplica           *prometheus.Desc
 	AvailReplicaSendstoTransport         *prometheus.Desc
 
+	// msdb.dbo.backupset
+	BackupLastFinishTimestamp *prometheus.Desc
+	BackupLastSizeBytes       *prometheus.Desc
+
 	// Win32_PerfRawData_{instance}_SQLServerBufferManager
 	BufManBackgroundwriterpages         *prometheus.Desc
 	BufManBuffercachehits               *prometheus.Desc
@@ -367,6 +414,12 @@ type MSSQLCollector struct {
 	// Win32_PerfRawData_{instance}_SQLServerSQLErrors
 	SQLErrorsTotal *prometheus.Desc
 
+	// tempdb DMVs
+	TempDBPageLatchWaitingTasks *prometheus.Desc
+	TempDBFileSizeBytes         *prometheus.Desc
+	TempDBFileFreeBytes         *prometheus.Desc
+	TempDBFileUsedBytes         *prometheus.Desc
+
 	// Win32_PerfRawData_{instance}_SQLServerTransactions
 	TransactionsTempDbFreeSpaceBytes             *prometheus.Desc
 	TransactionsLongestTransactionRunningSeconds *prometheus.Desc
@@ -382,9 +435,8 @@ type MSSQLCollector struct {
 	TransactionsVersionStoreCreationUnits        *prometheus.Desc
 	TransactionsVersionStoreTruncationUnits      *prometheus.Desc
 
-	mssqlInstances             mssqlInstancesType
-	mssqlCollectors            mssqlCollectorsMap
-	mssqlChildCollectorFailure int
+	mssqlInstances  mssqlInstancesType
+	mssqlCollectors mssqlCollectorsMap
 }
 
 // NewMSSQLCollector ...
@@ -397,11 +449,21 @@ func NewMSSQLCollector() (Collector, error) {
 	perfCounters := make([]string, 0, len(mssqlInstances)*len(enabled))
 	for instance := range mssqlInstances {
 		for _, c := range enabled {
+			if mssqlQueryClasses[c] {
+				continue
+			}
 			perfCounters = append(perfCounters, mssqlGetPerfObjectName(instance, c))
 		}
 	}
 	addPerfCounterDependencies(subsystem, perfCounters)
 
+	// The counters of all instances have the same explain texts.
+	helpInstance := "MSSQLSERVER"
+	for instance := range mssqlInstances {
+		helpInstance = instance
+		break
+	}
+
 	mssqlCollector := MSSQLCollector{
 		// meta
 		mssqlScrapeDurationDesc: prometheus.NewDesc(
@@ -420,265 +482,265 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerAccessMethods
 		AccessMethodsAUcleanupbatches: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_au_batch_cleanups"),
-			"(AccessMethods.AUcleanupbatches)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "AUcleanupbatchesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsAUcleanups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_au_cleanups"),
-			"(AccessMethods.AUcleanups)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "AUcleanupsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsByreferenceLobCreateCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_by_reference_lob_creates"),
-			"(AccessMethods.ByreferenceLobCreateCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ByreferenceLobCreateCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsByreferenceLobUseCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_by_reference_lob_uses"),
-			"(AccessMethods.ByreferenceLobUseCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ByreferenceLobUseCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsCountLobReadahead: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_read_aheads"),
-			"(AccessMethods.CountLobReadahead)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "CountLobReadahead"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsCountPullInRow: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_column_value_pulls"),
-			"(AccessMethods.CountPullInRow)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "CountPullInRow"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsCountPushOffRow: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_column_value_pushes"),
-			"(AccessMethods.CountPushOffRow)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "CountPushOffRow"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsDeferreddroppedAUs: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_deferred_dropped_aus"),
-			"(AccessMethods.DeferreddroppedAUs)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "DeferreddroppedAUs"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsDeferredDroppedrowsets: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_deferred_dropped_rowsets"),
-			"(AccessMethods.DeferredDroppedrowsets)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "DeferredDroppedrowsets"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsDroppedrowsetcleanups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_dropped_rowset_cleanups"),
-			"(AccessMethods.Droppedrowsetcleanups)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "DroppedrowsetcleanupsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsDroppedrowsetsskipped: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_dropped_rowset_skips"),
-			"(AccessMethods.Droppedrowsetsskipped)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "DroppedrowsetsskippedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsExtentDeallocations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_extent_deallocations"),
-			"(AccessMethods.ExtentDeallocations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ExtentDeallocationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsExtentsAllocated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_extent_allocations"),
-			"(AccessMethods.ExtentsAllocated)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ExtentsAllocatedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFailedAUcleanupbatches: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_au_batch_cleanup_failures"),
-			"(AccessMethods.FailedAUcleanupbatches)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "FailedAUcleanupbatchesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFailedleafpagecookie: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_leaf_page_cookie_failures"),
-			"(AccessMethods.Failedleafpagecookie)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "Failedleafpagecookie"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFailedtreepagecookie: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_tree_page_cookie_failures"),
-			"(AccessMethods.Failedtreepagecookie)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "Failedtreepagecookie"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsForwardedRecords: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_forwarded_records"),
-			"(AccessMethods.ForwardedRecords)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ForwardedRecordsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFreeSpacePageFetches: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_free_space_page_fetches"),
-			"(AccessMethods.FreeSpacePageFetches)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "FreeSpacePageFetchesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFreeSpaceScans: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_free_space_scans"),
-			"(AccessMethods.FreeSpaceScans)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "FreeSpaceScansPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsFullScans: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_full_scans"),
-			"(AccessMethods.FullScans)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "FullScansPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsIndexSearches: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_index_searches"),
-			"(AccessMethods.IndexSearches)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "IndexSearchesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsInSysXactwaits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_insysxact_waits"),
-			"(AccessMethods.InSysXactwaits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "InSysXactwaitsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsLobHandleCreateCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_handle_creates"),
-			"(AccessMethods.LobHandleCreateCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "LobHandleCreateCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsLobHandleDestroyCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_handle_destroys"),
-			"(AccessMethods.LobHandleDestroyCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "LobHandleDestroyCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsLobSSProviderCreateCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_ss_provider_creates"),
-			"(AccessMethods.LobSSProviderCreateCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "LobSSProviderCreateCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsLobSSProviderDestroyCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_ss_provider_destroys"),
-			"(AccessMethods.LobSSProviderDestroyCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "LobSSProviderDestroyCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsLobSSProviderTruncationCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_lob_ss_provider_truncations"),
-			"(AccessMethods.LobSSProviderTruncationCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "LobSSProviderTruncationCount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsMixedpageallocations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_mixed_page_allocations"),
-			"(AccessMethods.MixedpageallocationsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "MixedpageallocationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsPagecompressionattempts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_page_compression_attempts"),
-			"(AccessMethods.PagecompressionattemptsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "PagecompressionattemptsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsPageDeallocations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_page_deallocations"),
-			"(AccessMethods.PageDeallocationsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "PageDeallocationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsPagesAllocated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_page_allocations"),
-			"(AccessMethods.PagesAllocatedPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "PagesAllocatedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsPagescompressed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_page_compressions"),
-			"(AccessMethods.PagescompressedPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "PagescompressedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsPageSplits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_page_splits"),
-			"(AccessMethods.PageSplitsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "PageSplitsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsProbeScans: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_probe_scans"),
-			"(AccessMethods.ProbeScansPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ProbeScansPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsRangeScans: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_range_scans"),
-			"(AccessMethods.RangeScansPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "RangeScansPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsScanPointRevalidations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_scan_point_revalidations"),
-			"(AccessMethods.ScanPointRevalidationsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "ScanPointRevalidationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsSkippedGhostedRecords: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_ghost_record_skips"),
-			"(AccessMethods.SkippedGhostedRecordsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "SkippedGhostedRecordsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsTableLockEscalations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_table_lock_escalations"),
-			"(AccessMethods.TableLockEscalationsPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "TableLockEscalationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsUsedleafpagecookie: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_leaf_page_cookie_uses"),
-			"(AccessMethods.Usedleafpagecookie)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "Usedleafpagecookie"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsUsedtreepagecookie: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_tree_page_cookie_uses"),
-			"(AccessMethods.Usedtreepagecookie)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "Usedtreepagecookie"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsWorkfilesCreated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_workfile_creates"),
-			"(AccessMethods.WorkfilesCreatedPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "WorkfilesCreatedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsWorktablesCreated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_worktables_creates"),
-			"(AccessMethods.WorktablesCreatedPersec)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "WorktablesCreatedPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsWorktablesFromCacheHits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_worktables_from_cache_hits"),
-			"(AccessMethods.WorktablesFromCacheRatio)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "WorktablesFromCacheRatio"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		AccessMethodsWorktablesFromCacheLookups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "accessmethods_worktables_from_cache_lookups"),
-			"(AccessMethods.WorktablesFromCacheRatio_Base)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "accessmethods"), mssqlAccessMethods{}, "WorktablesFromCacheRatio_Base"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -686,25 +748,25 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerAvailabilityReplica
 		AvailReplicaBytesReceivedfromReplica: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_received_from_replica_bytes"),
-			"(AvailabilityReplica.BytesReceivedfromReplica)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "BytesReceivedfromReplicaPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaBytesSenttoReplica: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_sent_to_replica_bytes"),
-			"(AvailabilityReplica.BytesSenttoReplica)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "BytesSenttoReplicaPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaBytesSenttoTransport: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_sent_to_transport_bytes"),
-			"(AvailabilityReplica.BytesSenttoTransport)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "BytesSenttoTransportPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaFlowControl: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_initiated_flow_controls"),
-			"(AvailabilityReplica.FlowControl)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "FlowControlPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
@@ -716,165 +778,179 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		AvailReplicaReceivesfromReplica: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_receives_from_replica"),
-			"(AvailabilityReplica.ReceivesfromReplica)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "ReceivesfromReplicaPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaResentMessages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_resent_messages"),
-			"(AvailabilityReplica.ResentMessages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "ResentMessagesPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaSendstoReplica: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_sends_to_replica"),
-			"(AvailabilityReplica.SendstoReplica)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "SendstoReplicaPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		AvailReplicaSendstoTransport: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "availreplica_sends_to_transport"),
-			"(AvailabilityReplica.SendstoTransport)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "availreplica"), mssqlAvailabilityReplica{}, "SendstoTransportPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 
+		// msdb.dbo.backupset
+		BackupLastFinishTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "backup_last_finish_timestamp_seconds"),
+			"Time the last backup of the type of the database finished, 0 if the database has no backup of the type",
+			[]string{"mssql_instance", "database", "type"},
+			nil,
+		),
+		BackupLastSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "backup_last_size_bytes"),
+			"Size of the last backup of the type of the database",
+			[]string{"mssql_instance", "database", "type"},
+			nil,
+		),
+
 		// Win32_PerfRawData_{instance}_SQLServerBufferManager
 		BufManBackgroundwriterpages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_background_writer_pages"),
-			"(BufferManager.Backgroundwriterpages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "BackgroundwriterpagesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManBuffercachehits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_buffer_cache_hits"),
-			"(BufferManager.Buffercachehitratio)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Buffercachehitratio"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManBuffercachelookups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_buffer_cache_lookups"),
-			"(BufferManager.Buffercachehitratio_Base)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Buffercachehitratio_Base"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManCheckpointpages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_checkpoint_pages"),
-			"(BufferManager.Checkpointpages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "CheckpointpagesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManDatabasepages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_database_pages"),
-			"(BufferManager.Databasepages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Databasepages"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionallocatedpages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_allocated_pages"),
-			"(BufferManager.Extensionallocatedpages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Extensionallocatedpages"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionfreepages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_free_pages"),
-			"(BufferManager.Extensionfreepages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Extensionfreepages"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensioninuseaspercentage: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_in_use_as_percentage"),
-			"(BufferManager.Extensioninuseaspercentage)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Extensioninuseaspercentage"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionoutstandingIOcounter: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_outstanding_io"),
-			"(BufferManager.ExtensionoutstandingIOcounter)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ExtensionoutstandingIOcounter"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionpageevictions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_page_evictions"),
-			"(BufferManager.Extensionpageevictions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ExtensionpageevictionsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionpagereads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_page_reads"),
-			"(BufferManager.Extensionpagereads)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ExtensionpagereadsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionpageunreferencedtime: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_page_unreferenced_seconds"),
-			"(BufferManager.Extensionpageunreferencedtime)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Extensionpageunreferencedtime"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManExtensionpagewrites: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_extension_page_writes"),
-			"(BufferManager.Extensionpagewrites)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ExtensionpagewritesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManFreeliststalls: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_free_list_stalls"),
-			"(BufferManager.Freeliststalls)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "FreeliststallsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManIntegralControllerSlope: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_integral_controller_slope"),
-			"(BufferManager.IntegralControllerSlope)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "IntegralControllerSlope"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManLazywrites: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_lazywrites"),
-			"(BufferManager.Lazywrites)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "LazywritesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManPagelifeexpectancy: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_page_life_expectancy_seconds"),
-			"(BufferManager.Pagelifeexpectancy)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Pagelifeexpectancy"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManPagelookups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_page_lookups"),
-			"(BufferManager.Pagelookups)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "PagelookupsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManPagereads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_page_reads"),
-			"(BufferManager.Pagereads)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "PagereadsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManPagewrites: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_page_writes"),
-			"(BufferManager.Pagewrites)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "PagewritesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManReadaheadpages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_read_ahead_pages"),
-			"(BufferManager.Readaheadpages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ReadaheadpagesPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManReadaheadtime: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_read_ahead_issuing_seconds"),
-			"(BufferManager.Readaheadtime)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "ReadaheadtimePersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		BufManTargetpages: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bufman_target_pages"),
-			"(BufferManager.Targetpages)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "bufman"), mssqlBufferManager{}, "Targetpages"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -882,139 +958,139 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerDatabaseReplica
 		DBReplicaDatabaseFlowControlDelay: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_database_flow_control_wait_seconds"),
-			"(DatabaseReplica.DatabaseFlowControlDelay)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "DatabaseFlowControlDelay"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaDatabaseFlowControls: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_database_initiated_flow_controls"),
-			"(DatabaseReplica.DatabaseFlowControls)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "DatabaseFlowControlsPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaFileBytesReceived: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_received_file_bytes"),
-			"(DatabaseReplica.FileBytesReceived)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "FileBytesReceivedPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaGroupCommits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_group_commits"),
-			"(DatabaseReplica.GroupCommits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "GroupCommitsPerSec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaGroupCommitTime: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_group_commit_stall_seconds"),
-			"(DatabaseReplica.GroupCommitTime)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "GroupCommitTime"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogApplyPendingQueue: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_apply_pending_queue"),
-			"(DatabaseReplica.LogApplyPendingQueue)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogApplyPendingQueue"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogApplyReadyQueue: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_apply_ready_queue"),
-			"(DatabaseReplica.LogApplyReadyQueue)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogApplyReadyQueue"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogBytesCompressed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_compressed_bytes"),
-			"(DatabaseReplica.LogBytesCompressed)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogBytesCompressedPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogBytesDecompressed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_decompressed_bytes"),
-			"(DatabaseReplica.LogBytesDecompressed)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogBytesDecompressedPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogBytesReceived: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_received_bytes"),
-			"(DatabaseReplica.LogBytesReceived)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogBytesReceivedPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogCompressionCachehits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_compression_cachehits"),
-			"(DatabaseReplica.LogCompressionCachehits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogCompressionCachehitsPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogCompressionCachemisses: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_compression_cachemisses"),
-			"(DatabaseReplica.LogCompressionCachemisses)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogCompressionCachemissesPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogCompressions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_compressions"),
-			"(DatabaseReplica.LogCompressions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogCompressionsPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogDecompressions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_decompressions"),
-			"(DatabaseReplica.LogDecompressions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogDecompressionsPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogremainingforundo: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_remaining_for_undo"),
-			"(DatabaseReplica.Logremainingforundo)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "Logremainingforundo"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaLogSendQueue: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_log_send_queue"),
-			"(DatabaseReplica.LogSendQueue)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "LogSendQueue"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaMirroredWriteTransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_mirrored_write_transactions"),
-			"(DatabaseReplica.MirroredWriteTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "MirroredWriteTransactionsPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaRecoveryQueue: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_recovery_queue_records"),
-			"(DatabaseReplica.RecoveryQueue)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "RecoveryQueue"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaRedoblocked: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_redo_blocks"),
-			"(DatabaseReplica.Redoblocked)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "RedoblockedPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaRedoBytesRemaining: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_redo_remaining_bytes"),
-			"(DatabaseReplica.RedoBytesRemaining)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "RedoBytesRemaining"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaRedoneBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_redone_bytes"),
-			"(DatabaseReplica.RedoneBytes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "RedoneBytesPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaRedones: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_redones"),
-			"(DatabaseReplica.Redones)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "RedonesPersec"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
 		DBReplicaTotalLogrequiringundo: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "dbreplica_total_log_requiring_undo"),
-			"(DatabaseReplica.TotalLogrequiringundo)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "dbreplica"), mssqlDatabaseReplica{}, "TotalLogrequiringundo"),
 			[]string{"mssql_instance", "replica"},
 			nil,
 		),
@@ -1028,25 +1104,25 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerDatabases
 		DatabasesActiveParallelredothreads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_active_parallel_redo_threads"),
-			"(Databases.ActiveParallelredothreads)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "Activeparallelredothreads"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesActiveTransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_active_transactions"),
-			"(Databases.ActiveTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "ActiveTransactions"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesBackupPerRestoreThroughput: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_backup_restore_operations"),
-			"(Databases.BackupPerRestoreThroughput)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "BackupPerRestoreThroughputPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesBulkCopyRows: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_bulk_copy_rows"),
-			"(Databases.BulkCopyRows)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "BulkCopyRowsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1058,7 +1134,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesCommittableentries: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_commit_table_entries"),
-			"(Databases.Committableentries)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "Committableentries"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1070,7 +1146,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesDBCCLogicalScanBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_dbcc_logical_scan_bytes"),
-			"(Databases.DBCCLogicalScanBytes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "DBCCLogicalScanBytesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1082,25 +1158,25 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesLogBytesFlushed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_flushed_bytes"),
-			"(Databases.LogBytesFlushed)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogBytesFlushedPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogCacheHits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_cache_hits"),
-			"(Databases.LogCacheHitRatio)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogCacheHitRatio"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogCacheLookups: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_cache_lookups"),
-			"(Databases.LogCacheHitRatio_Base)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogCacheHitRatio_Base"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogCacheReads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_cache_reads"),
-			"(Databases.LogCacheReads)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogCacheReadsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1118,13 +1194,13 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesLogFlushes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_flushes"),
-			"(Databases.LogFlushes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogFlushesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogFlushWaits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_flush_waits"),
-			"(Databases.LogFlushWaits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogFlushWaitsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1142,157 +1218,157 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesLogGrowths: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_growths"),
-			"(Databases.LogGrowths)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogGrowths"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolCacheMisses: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_cache_misses"),
-			"(Databases.LogPoolCacheMisses)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolCacheMissesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolDiskReads: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_disk_reads"),
-			"(Databases.LogPoolDiskReads)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolDiskReadsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolHashDeletes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_hash_deletes"),
-			"(Databases.LogPoolHashDeletes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolHashDeletesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolHashInserts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_hash_inserts"),
-			"(Databases.LogPoolHashInserts)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolHashInsertsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolInvalidHashEntry: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_invalid_hash_entries"),
-			"(Databases.LogPoolInvalidHashEntry)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolInvalidHashEntryPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolLogScanPushes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_log_scan_pushes"),
-			"(Databases.LogPoolLogScanPushes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolLogScanPushesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolLogWriterPushes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_log_writer_pushes"),
-			"(Databases.LogPoolLogWriterPushes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolLogWriterPushesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolPushEmptyFreePool: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_empty_free_pool_pushes"),
-			"(Databases.LogPoolPushEmptyFreePool)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolPushEmptyFreePoolPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolPushLowMemory: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_low_memory_pushes"),
-			"(Databases.LogPoolPushLowMemory)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolPushLowMemoryPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolPushNoFreeBuffer: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_no_free_buffer_pushes"),
-			"(Databases.LogPoolPushNoFreeBuffer)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolPushNoFreeBufferPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolReqBehindTrunc: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_req_behind_trunc"),
-			"(Databases.LogPoolReqBehindTrunc)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolReqBehindTruncPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolRequestsOldVLF: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_requests_old_vlf"),
-			"(Databases.LogPoolRequestsOldVLF)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolRequestsOldVLFPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_requests"),
-			"(Databases.LogPoolRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolRequestsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolTotalActiveLogSize: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_total_active_log_bytes"),
-			"(Databases.LogPoolTotalActiveLogSize)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolTotalActiveLogSize"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogPoolTotalSharedPoolSize: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_pool_total_shared_pool_bytes"),
-			"(Databases.LogPoolTotalSharedPoolSize)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogPoolTotalSharedPoolSize"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogShrinks: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_shrinks"),
-			"(Databases.LogShrinks)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogShrinks"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesLogTruncations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_truncations"),
-			"(Databases.LogTruncations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "LogTruncations"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesPercentLogUsed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_log_used_percent"),
-			"(Databases.PercentLogUsed)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "PercentLogUsed"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesReplPendingXacts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_pending_repl_transactions"),
-			"(Databases.ReplPendingTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "ReplPendingXacts"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesReplTransRate: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_repl_transactions"),
-			"(Databases.ReplTranactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "ReplTransRate"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesShrinkDataMovementBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_shrink_data_movement_bytes"),
-			"(Databases.ShrinkDataMovementBytes)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "ShrinkDataMovementBytesPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesTrackedtransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_tracked_transactions"),
-			"(Databases.Trackedtransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "TrackedtransactionsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesTransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_transactions"),
-			"(Databases.Transactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "TransactionsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesWriteTransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_write_transactions"),
-			"(Databases.WriteTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "WriteTransactionsPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
 		DatabasesXTPControllerDLCLatencyPerFetch: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_xtp_controller_dlc_fetch_latency_seconds"),
-			"(Databases.XTPControllerDLCLatencyPerFetch)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "XTPControllerDLCLatencyPerFetch"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1304,7 +1380,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		DatabasesXTPControllerLogProcessed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "databases_xtp_controller_log_processed_bytes"),
-			"(Databases.XTPControllerLogProcessed)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "databases"), mssqlDatabases{}, "XTPControllerLogProcessedPersec"),
 			[]string{"mssql_instance", "database"},
 			nil,
 		),
@@ -1318,145 +1394,145 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerGeneralStatistics
 		GenStatsActiveTempTables: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_active_temp_tables"),
-			"(GeneralStatistics.ActiveTempTables)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "ActiveTempTables"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsConnectionReset: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_connection_resets"),
-			"(GeneralStatistics.ConnectionReset)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "ConnectionResetPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsEventNotificationsDelayedDrop: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_event_notifications_delayed_drop"),
-			"(GeneralStatistics.EventNotificationsDelayedDrop)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "EventNotificationsDelayedDrop"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsHTTPAuthenticatedRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_http_authenticated_requests"),
-			"(GeneralStatistics.HTTPAuthenticatedRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "HTTPAuthenticatedRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsLogicalConnections: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_logical_connections"),
-			"(GeneralStatistics.LogicalConnections)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "LogicalConnections"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsLogins: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_logins"),
-			"(GeneralStatistics.Logins)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "LoginsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsLogouts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_logouts"),
-			"(GeneralStatistics.Logouts)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "LogoutsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsMarsDeadlocks: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_mars_deadlocks"),
-			"(GeneralStatistics.MarsDeadlocks)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "MarsDeadlocks"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsNonatomicyieldrate: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_non_atomic_yields"),
-			"(GeneralStatistics.Nonatomicyields)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "Nonatomicyieldrate"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsProcessesblocked: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_blocked_processes"),
-			"(GeneralStatistics.Processesblocked)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "Processesblocked"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPEmptyRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soap_empty_requests"),
-			"(GeneralStatistics.SOAPEmptyRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPEmptyRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPMethodInvocations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soap_method_invocations"),
-			"(GeneralStatistics.SOAPMethodInvocations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPMethodInvocations"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPSessionInitiateRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soap_session_initiate_requests"),
-			"(GeneralStatistics.SOAPSessionInitiateRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPSessionInitiateRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPSessionTerminateRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soap_session_terminate_requests"),
-			"(GeneralStatistics.SOAPSessionTerminateRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPSessionTerminateRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPSQLRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soapsql_requests"),
-			"(GeneralStatistics.SOAPSQLRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPSQLRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSOAPWSDLRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_soapwsdl_requests"),
-			"(GeneralStatistics.SOAPWSDLRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SOAPWSDLRequests"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsSQLTraceIOProviderLockWaits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_sql_trace_io_provider_lock_waits"),
-			"(GeneralStatistics.SQLTraceIOProviderLockWaits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "SQLTraceIOProviderLockWaits"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTempdbrecoveryunitid: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_tempdb_recovery_unit_ids_generated"),
-			"(GeneralStatistics.Tempdbrecoveryunitid)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "Tempdbrecoveryunitid"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTempdbrowsetid: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_tempdb_rowset_ids_generated"),
-			"(GeneralStatistics.Tempdbrowsetid)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "Tempdbrowsetid"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTempTablesCreationRate: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_temp_tables_creations"),
-			"(GeneralStatistics.TempTablesCreations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "TempTablesCreationRate"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTempTablesForDestruction: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_temp_tables_awaiting_destruction"),
-			"(GeneralStatistics.TempTablesForDestruction)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "TempTablesForDestruction"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTraceEventNotificationQueue: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_trace_event_notification_queue_size"),
-			"(GeneralStatistics.TraceEventNotificationQueue)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "TraceEventNotificationQueue"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsTransactions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_transactions"),
-			"(GeneralStatistics.Transactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "Transactions"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		GenStatsUserConnections: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "genstats_user_connections"),
-			"(GeneralStatistics.UserConnections)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "genstats"), mssqlGeneralStatistics{}, "UserConnections"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1476,13 +1552,13 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		LocksLockRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "locks_lock_requests"),
-			"(Locks.LockRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "locks"), mssqlLocks{}, "LockRequestsPersec"),
 			[]string{"mssql_instance", "resource"},
 			nil,
 		),
 		LocksLockTimeouts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "locks_lock_timeouts"),
-			"(Locks.LockTimeouts)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "locks"), mssqlLocks{}, "LockTimeoutsPersec"),
 			[]string{"mssql_instance", "resource"},
 			nil,
 		),
@@ -1494,7 +1570,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		LocksLockWaits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "locks_lock_waits"),
-			"(Locks.LockWaits)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "locks"), mssqlLocks{}, "LockWaitsPersec"),
 			[]string{"mssql_instance", "resource"},
 			nil,
 		),
@@ -1506,7 +1582,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		LocksNumberofDeadlocks: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "locks_deadlocks"),
-			"(Locks.NumberofDeadlocks)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "locks"), mssqlLocks{}, "NumberofDeadlocksPersec"),
 			[]string{"mssql_instance", "resource"},
 			nil,
 		),
@@ -1526,7 +1602,7 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		MemMgrExternalbenefitofmemory: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_external_benefit_of_memory"),
-			"(MemoryManager.Externalbenefitofmemory)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "Externalbenefitofmemory"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1544,13 +1620,13 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		MemMgrLockBlocks: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_lock_blocks"),
-			"(MemoryManager.LockBlocks)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "LockBlocks"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		MemMgrLockBlocksAllocated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_allocated_lock_blocks"),
-			"(MemoryManager.LockBlocksAllocated)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "LockBlocksAllocated"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1562,13 +1638,13 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		MemMgrLockOwnerBlocks: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_lock_owner_blocks"),
-			"(MemoryManager.LockOwnerBlocks)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "LockOwnerBlocks"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		MemMgrLockOwnerBlocksAllocated: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_allocated_lock_owner_blocks"),
-			"(MemoryManager.LockOwnerBlocksAllocated)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "LockOwnerBlocksAllocated"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1586,13 +1662,13 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		MemMgrMemoryGrantsOutstanding: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_outstanding_memory_grants"),
-			"(MemoryManager.MemoryGrantsOutstanding)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "MemoryGrantsOutstanding"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		MemMgrMemoryGrantsPending: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "memmgr_pending_memory_grants"),
-			"(MemoryManager.MemoryGrantsPending)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "memmgr"), mssqlMemoryManager{}, "MemoryGrantsPending"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1636,67 +1712,67 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerSQLStatistics
 		SQLStatsAutoParamAttempts: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_auto_parameterization_attempts"),
-			"(SQLStatistics.AutoParamAttempts)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "AutoParamAttemptsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsBatchRequests: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_batch_requests"),
-			"(SQLStatistics.BatchRequests)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "BatchRequestsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsFailedAutoParams: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_failed_auto_parameterization_attempts"),
-			"(SQLStatistics.FailedAutoParams)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "FailedAutoParamsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsForcedParameterizations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_forced_parameterizations"),
-			"(SQLStatistics.ForcedParameterizations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "ForcedParameterizationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsGuidedplanexecutions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_guided_plan_executions"),
-			"(SQLStatistics.Guidedplanexecutions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "GuidedplanexecutionsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsMisguidedplanexecutions: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_misguided_plan_executions"),
-			"(SQLStatistics.Misguidedplanexecutions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "MisguidedplanexecutionsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsSafeAutoParams: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_safe_auto_parameterization_attempts"),
-			"(SQLStatistics.SafeAutoParams)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "SafeAutoParamsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsSQLAttentionrate: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_sql_attentions"),
-			"(SQLStatistics.SQLAttentions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "SQLAttentionrate"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsSQLCompilations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_sql_compilations"),
-			"(SQLStatistics.SQLCompilations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "SQLCompilationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsSQLReCompilations: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_sql_recompilations"),
-			"(SQLStatistics.SQLReCompilations)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "SQLReCompilationsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		SQLStatsUnsafeAutoParams: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sqlstats_unsafe_auto_parameterization_attempts"),
-			"(SQLStatistics.UnsafeAutoParams)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlstats"), mssqlSQLStatistics{}, "UnsafeAutoParamsPersec"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1704,11 +1780,37 @@ func NewMSSQLCollector() (Collector, error) {
 		// Win32_PerfRawData_{instance}_SQLServerSQLErrors
 		SQLErrorsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "sql_errors_total"),
-			"(SQLErrors.Total)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "sqlerrors"), mssqlSQLErrors{}, "ErrorsPersec"),
 			[]string{"mssql_instance", "resource"},
 			nil,
 		),
 
+		// tempdb DMVs
+		TempDBPageLatchWaitingTasks: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "tempdb_page_latch_waiting_tasks"),
+			"Number of tasks waiting for a latch on an allocation page of tempdb, by type of page",
+			[]string{"mssql_instance", "page_type"},
+			nil,
+		),
+		TempDBFileSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "tempdb_file_size_bytes"),
+			"Size of the tempdb file",
+			[]string{"mssql_instance", "file"},
+			nil,
+		),
+		TempDBFileFreeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "tempdb_file_free_bytes"),
+			"Space in unallocated extents of the tempdb file",
+			[]string{"mssql_instance", "file"},
+			nil,
+		),
+		TempDBFileUsedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "tempdb_file_used_bytes"),
+			"Space of the tempdb file reserved for the usage",
+			[]string{"mssql_instance", "file", "usage"},
+			nil,
+		),
+
 		// Win32_PerfRawData_{instance}_SQLServerTransactions
 		TransactionsTempDbFreeSpaceBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_tempdb_free_space_bytes"),
@@ -1718,37 +1820,37 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		TransactionsLongestTransactionRunningSeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_longest_transaction_running_seconds"),
-			"(Transactions.LongestTransactionRunningTime)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "LongestTransactionRunningTime"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsNonSnapshotVersionActiveTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_nonsnapshot_version_active_total"),
-			"(Transactions.NonSnapshotVersionTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "NonSnapshotVersionTransactions"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsSnapshotActiveTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_snapshot_active_total"),
-			"(Transactions.SnapshotTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "SnapshotTransactions"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsActive: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_active"),
-			"(Transactions.Transactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "Transactions"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsUpdateConflictsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_update_conflicts_total"),
-			"(Transactions.UpdateConflictRatio)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "Updateconflictratio"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsUpdateSnapshotActiveTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_update_snapshot_active_total"),
-			"(Transactions.UpdateSnapshotTransactions)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "UpdateSnapshotTransactions"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1772,19 +1874,19 @@ func NewMSSQLCollector() (Collector, error) {
 		),
 		TransactionsVersionStoreUnits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_version_store_units"),
-			"(Transactions.VersionStoreUnitCount)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "VersionStoreunitcount"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsVersionStoreCreationUnits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_version_store_creation_units"),
-			"(Transactions.VersionStoreUnitCreation)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "VersionStoreunitcreation"),
 			[]string{"mssql_instance"},
 			nil,
 		),
 		TransactionsVersionStoreTruncationUnits: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "transactions_version_store_truncation_units"),
-			"(Transactions.VersionStoreUnitTruncation)",
+			counterHelp(mssqlGetPerfObjectName(helpInstance, "transactions"), mssqlTransactions{}, "VersionStoreunittruncation"),
 			[]string{"mssql_instance"},
 			nil,
 		),
@@ -1807,56 +1909,140 @@ func NewMSSQLCollector() (Collector, error) {
 
 type mssqlCollectorFunc func(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error)
 
-func (c *MSSQLCollector) execute(ctx *ScrapeContext, name string, fn mssqlCollectorFunc, ch chan<- prometheus.Metric, sqlInstance string, wg *sync.WaitGroup) {
-	// Reset failure counter on each scrape
-	c.mssqlChildCollectorFailure = 0
-	defer wg.Done()
+// mssqlChildResult holds the outcome of a class collector. Metrics are
+// buffered, so that those of class collectors that exceeded the instance
+// timeout can be dropped.
+type mssqlChildResult struct {
+	name     string
+	metrics  []prometheus.Metric
+	duration time.Duration
+	err      error
+}
+
+func (c *MSSQLCollector) execute(ctx *ScrapeContext, name string, fn mssqlCollectorFunc, sqlInstance string) mssqlChildResult {
+	metrics := make(chan prometheus.Metric)
+	result := mssqlChildResult{name: name}
+	done := make(chan struct{})
+	go func() {
+		for m := range metrics {
+			result.metrics = append(result.metrics, m)
+		}
+		close(done)
+	}()
 
 	begin := time.Now()
-	_, err := fn(ctx, ch, sqlInstance)
-	duration := time.Since(begin)
-	var success float64
+	_, result.err = fn(ctx, metrics, sqlInstance)
+	result.duration = time.Since(begin)
+	close(metrics)
+	<-done
+	return result
+}
 
-	if err != nil {
-		log.Errorf("mssql class collector %s failed after %fs: %s", name, duration.Seconds(), err)
-		success = 0
-		c.mssqlChildCollectorFailure++
-	} else {
-		log.Debugf("mssql class collector %s succeeded after %fs.", name, duration.Seconds())
-		success = 1
+// collectInstance runs the enabled class collectors of sqlInstance, and
+// reports whether all of them succeeded in time. sem bounds the number of
+// class collectors running at once, if not nil.
+func (c *MSSQLCollector) collectInstance(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string, enabled []string, sem chan struct{}) bool {
+	begin := time.Now()
+	results := make(chan mssqlChildResult, len(enabled))
+	pending := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		pending[name] = true
+		go func(name string, fn mssqlCollectorFunc) {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Context().Done():
+					results <- mssqlChildResult{name: name, err: ctx.Context().Err()}
+					return
+				}
+			}
+			results <- c.execute(ctx, name, fn, sqlInstance)
+		}(name, c.mssqlCollectors[name])
+	}
+
+	var deadline <-chan time.Time
+	if *mssqlInstanceTimeout > 0 {
+		timer := time.NewTimer(*mssqlInstanceTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ok := true
+	report := func(name string, duration time.Duration, success float64) {
+		ch <- prometheus.MustNewConstMetric(
+			c.mssqlScrapeDurationDesc,
+			prometheus.GaugeValue,
+			duration.Seconds(),
+			name, sqlInstance,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.mssqlScrapeSuccessDesc,
+			prometheus.GaugeValue,
+			success,
+			name, sqlInstance,
+		)
+	}
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.name)
+			if r.err != nil {
+				mssqlLogger.Errorf("mssql class collector %s failed after %fs: %s", r.name, r.duration.Seconds(), r.err)
+				ok = false
+				report(r.name, r.duration, 0)
+				continue
+			}
+			mssqlLogger.Debugf("mssql class collector %s succeeded after %fs.", r.name, r.duration.Seconds())
+			for _, m := range r.metrics {
+				ch <- m
+			}
+			report(r.name, r.duration, 1)
+		case <-deadline:
+			for name := range pending {
+				mssqlLogger.Errorf("mssql class collector %s timed out after %s for instance %s", name, *mssqlInstanceTimeout, sqlInstance)
+				report(name, *mssqlInstanceTimeout, 0)
+			}
+			return false
+		case <-ctx.Context().Done():
+			for name := range pending {
+				mssqlLogger.Errorf("mssql class collector %s was cancelled for instance %s: %v", name, sqlInstance, ctx.Context().Err())
+				report(name, time.Since(begin), 0)
+			}
+			return false
+		}
 	}
-	ch <- prometheus.MustNewConstMetric(
-		c.mssqlScrapeDurationDesc,
-		prometheus.GaugeValue,
-		duration.Seconds(),
-		name, sqlInstance,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		c.mssqlScrapeSuccessDesc,
-		prometheus.GaugeValue,
-		success,
-		name, sqlInstance,
-	)
+	return ok
 }
 
 // Collect sends the metric values for each metric
 // to the provided prometheus Metric channel.
 func (c *MSSQLCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
-	wg := sync.WaitGroup{}
+	var sem chan struct{}
+	if *mssqlMaxConcurrency > 0 {
+		sem = make(chan struct{}, *mssqlMaxConcurrency)
+	}
 
 	enabled := expandEnabledChildCollectors(*mssqlEnabledCollectors)
+	wg := sync.WaitGroup{}
+	var failures int32
 	for sqlInstance := range c.mssqlInstances {
-		for _, name := range enabled {
-			function := c.mssqlCollectors[name]
-
-			wg.Add(1)
-			go c.execute(ctx, name, function, ch, sqlInstance, &wg)
+		instanceCh, done, ok := clusterOwnerFilter(ch, "SQL Server", mssqlClusterResourceName(sqlInstance))
+		if !ok {
+			continue
 		}
+		wg.Add(1)
+		go func(sqlInstance string) {
+			defer wg.Done()
+			defer done()
+			if !c.collectInstance(ctx, instanceCh, sqlInstance, enabled, sem) {
+				atomic.AddInt32(&failures, 1)
+			}
+		}(sqlInstance)
 	}
 	wg.Wait()
 
-	// this shoud return an error if any? some? children errord.
-	if c.mssqlChildCollectorFailure > 0 {
+	if failures > 0 {
 		return errors.New("at least one child collector failed")
 	}
 	return nil
@@ -1913,9 +2099,9 @@ type mssqlAccessMethods struct {
 
 func (c *MSSQLCollector) collectAccessMethods(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlAccessMethods
-	log.Debugf("mssql_accessmethods collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_accessmethods collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "accessmethods")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "accessmethods")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -2248,9 +2434,9 @@ type mssqlAvailabilityReplica struct {
 
 func (c *MSSQLCollector) collectAvailabilityReplica(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlAvailabilityReplica
-	log.Debugf("mssql_availreplica collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_availreplica collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "availreplica")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "availreplica")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -2326,6 +2512,139 @@ func (c *MSSQLCollector) collectAvailabilityReplica(ctx *ScrapeContext, ch chan<
 	return nil, nil
 }
 
+// mssqlBackupQuery returns the last backup of each type of every database
+// but tempdb, with one row without backup_type for databases never backed up.
+// Backups are matched to the instance by server name, as msdb may hold the
+// history of backups restored from other servers. The age of the backups is
+// computed by the server, as backup_finish_date is in its local time.
+const mssqlBackupQuery = `WITH last AS (
+	SELECT database_name, type, backup_finish_date, backup_size,
+		ROW_NUMBER() OVER (PARTITION BY database_name, type ORDER BY backup_finish_date DESC) AS n
+	FROM msdb.dbo.backupset
+	WHERE server_name = @@SERVERNAME AND backup_finish_date IS NOT NULL
+)
+SELECT d.name AS database_name, d.recovery_model_desc AS recovery_model, l.type AS backup_type,
+	DATEDIFF(second, l.backup_finish_date, GETDATE()) AS age_seconds, l.backup_size
+FROM sys.databases d
+LEFT JOIN last l ON l.database_name = d.name AND l.n = 1
+WHERE d.name <> 'tempdb'`
+
+// https://docs.microsoft.com/en-us/sql/relational-databases/system-tables/backupset-transact-sql
+var mssqlBackupTypes = map[string]string{
+	"D": "full",
+	"I": "differential",
+	"L": "log",
+}
+
+type mssqlBackup struct {
+	database   string
+	backupType string
+	finished   time.Time
+	sizeBytes  float64
+}
+
+// mssqlBackups converts the rows of mssqlBackupQuery. Every database has the
+// last full, differential and log backup, those it never had finished at the
+// Unix epoch, so that alerts on the age of backups cover them. Databases in
+// the simple recovery model, which can't have log backups, have none. Other
+// backup types, e.g. file backups, are skipped.
+func mssqlBackups(rows []odbc32.Row, now time.Time) []mssqlBackup {
+	var databases []string
+	last := make(map[string]map[string]mssqlBackup)
+	simple := make(map[string]bool)
+	for _, row := range rows {
+		database := row["database_name"]
+		if _, ok := last[database]; !ok {
+			databases = append(databases, database)
+			last[database] = make(map[string]mssqlBackup)
+		}
+		if row["recovery_model"] == "SIMPLE" {
+			simple[database] = true
+		}
+		code, ok := row["backup_type"]
+		if !ok {
+			continue
+		}
+		backupType, ok := mssqlBackupTypes[code]
+		if !ok {
+			continue
+		}
+		age, err := strconv.ParseInt(row["age_seconds"], 10, 64)
+		if err != nil {
+			mssqlLogger.Debugf("Ignoring %s backup of database %s with age %q", backupType, database, row["age_seconds"])
+			continue
+		}
+		size, _ := strconv.ParseFloat(row["backup_size"], 64)
+		last[database][backupType] = mssqlBackup{
+			database:   database,
+			backupType: backupType,
+			finished:   now.Add(-time.Duration(age) * time.Second),
+			sizeBytes:  size,
+		}
+	}
+
+	var backups []mssqlBackup
+	for _, database := range databases {
+		for _, backupType := range []string{"full", "differential", "log"} {
+			b, ok := last[database][backupType]
+			if !ok {
+				if backupType == "log" && simple[database] {
+					continue
+				}
+				b = mssqlBackup{database: database, backupType: backupType, finished: time.Unix(0, 0)}
+			}
+			backups = append(backups, b)
+		}
+	}
+	return backups
+}
+
+// mssqlConnectionString returns the connection string to the database of the
+// local instance, authenticating with the Windows account of the exporter.
+func mssqlConnectionString(sqlInstance string, database string) string {
+	server := "."
+	if sqlInstance != "MSSQLSERVER" {
+		server = `.\` + sqlInstance
+	}
+	return fmt.Sprintf("Driver={%s};Server=%s;Database=%s;Trusted_Connection=yes", *mssqlODBCDriver, server, database)
+}
+
+// mssqlQuery runs the query against the database of the local instance,
+// giving up at the deadline of the scrape.
+func mssqlQuery(ctx *ScrapeContext, sqlInstance string, database string, query string) ([]odbc32.Row, error) {
+	timeout := odbcDefaultTimeout
+	if deadline, ok := ctx.Context().Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return odbc32.Query(mssqlConnectionString(sqlInstance, database), query, timeout)
+}
+
+func (c *MSSQLCollector) collectBackups(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
+	rows, err := mssqlQuery(ctx, sqlInstance, "msdb", mssqlBackupQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range mssqlBackups(rows, time.Now()) {
+		ch <- prometheus.MustNewConstMetric(
+			c.BackupLastFinishTimestamp,
+			prometheus.GaugeValue,
+			float64(b.finished.Unix()),
+			sqlInstance, b.database, b.backupType,
+		)
+		if b.finished.Unix() == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.BackupLastSizeBytes,
+			prometheus.GaugeValue,
+			b.sizeBytes,
+			sqlInstance, b.database, b.backupType,
+		)
+	}
+	return nil, nil
+}
+
 // Win32_PerfRawData_MSSQLSERVER_SQLServerBufferManager docs:
 // - https://docs.microsoft.com/en-us/sql/relational-databases/performance-monitor/sql-server-buffer-manager-object
 type mssqlBufferManager struct {
@@ -2356,9 +2675,9 @@ type mssqlBufferManager struct {
 
 func (c *MSSQLCollector) collectBufferManager(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlBufferManager
-	log.Debugf("mssql_bufman collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_bufman collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "bufman")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "bufman")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -2560,9 +2879,9 @@ type mssqlDatabaseReplica struct {
 
 func (c *MSSQLCollector) collectDatabaseReplica(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlDatabaseReplica
-	log.Debugf("mssql_dbreplica collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_dbreplica collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "dbreplica")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "dbreplica")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -2799,9 +3118,9 @@ type mssqlDatabases struct {
 
 func (c *MSSQLCollector) collectDatabases(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlDatabases
-	log.Debugf("mssql_databases collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_databases collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "databases")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "databases")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3181,9 +3500,9 @@ type mssqlGeneralStatistics struct {
 
 func (c *MSSQLCollector) collectGeneralStatistics(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlGeneralStatistics
-	log.Debugf("mssql_genstats collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_genstats collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "genstats")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "genstats")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3376,9 +3695,9 @@ type mssqlLocks struct {
 
 func (c *MSSQLCollector) collectLocks(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlLocks
-	log.Debugf("mssql_locks collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_locks collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "locks")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "locks")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3474,9 +3793,9 @@ type mssqlMemoryManager struct {
 
 func (c *MSSQLCollector) collectMemoryManager(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlMemoryManager
-	log.Debugf("mssql_memmgr collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_memmgr collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "memmgr")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "memmgr")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3643,9 +3962,9 @@ type mssqlSQLStatistics struct {
 
 func (c *MSSQLCollector) collectSQLStats(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlSQLStatistics
-	log.Debugf("mssql_sqlstats collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_sqlstats collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "sqlstats")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "sqlstats")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3740,9 +4059,9 @@ type mssqlSQLErrors struct {
 // - https://docs.microsoft.com/en-us/sql/relational-databases/performance-monitor/sql-server-sql-errors-object
 func (c *MSSQLCollector) collectSQLErrors(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlSQLErrors
-	log.Debugf("mssql_sqlerrors collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_sqlerrors collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "sqlerrors")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "sqlerrors")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -3763,6 +4082,111 @@ func (c *MSSQLCollector) collectSQLErrors(ctx *ScrapeContext, ch chan<- promethe
 	return nil, nil
 }
 
+// mssqlTempDBLatchQuery returns the tasks waiting for a latch on a page of
+// tempdb, whose database ID is always 2. resource_description identifies the
+// page as <database ID>:<file ID>:<page ID>.
+const mssqlTempDBLatchQuery = `SELECT resource_description
+FROM sys.dm_os_waiting_tasks
+WHERE wait_type LIKE 'PAGELATCH[_]%' AND resource_description LIKE '2:%'`
+
+// mssqlTempDBFilesQuery returns the space usage of the data files of tempdb,
+// in pages.
+const mssqlTempDBFilesQuery = `SELECT f.name AS file_name, f.size,
+	u.unallocated_extent_page_count, u.version_store_reserved_page_count,
+	u.user_object_reserved_page_count, u.internal_object_reserved_page_count,
+	u.mixed_extent_page_count
+FROM sys.dm_db_file_space_usage u
+JOIN sys.database_files f ON f.file_id = u.file_id`
+
+const mssqlPageSize = 8192
+
+var mssqlTempDBUsages = map[string]string{
+	"version_store_reserved_page_count":   "version_store",
+	"user_object_reserved_page_count":     "user_objects",
+	"internal_object_reserved_page_count": "internal_objects",
+	"mixed_extent_page_count":             "mixed_extents",
+}
+
+// mssqlTempDBPageType returns the type of the allocation page identified by
+// the resource description of a latch wait: pfs, gam, sgam, or other for the
+// pages that aren't allocation pages. A PFS page covers 8088 pages, and a GAM
+// and SGAM page 511232 pages, the first of which are pages 1, 2 and 3 of each
+// file.
+// https://docs.microsoft.com/en-us/sql/relational-databases/pages-and-extents-architecture-guide
+func mssqlTempDBPageType(resource string) (string, bool) {
+	fields := strings.Split(strings.SplitN(resource, " ", 2)[0], ":")
+	if len(fields) < 3 || fields[0] != "2" {
+		return "", false
+	}
+	page, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case page == 1 || page%8088 == 0:
+		return "pfs", true
+	case page == 2 || page%511232 == 0:
+		return "gam", true
+	case page == 3 || (page-1)%511232 == 0:
+		return "sgam", true
+	}
+	return "other", true
+}
+
+func (c *MSSQLCollector) collectTempDB(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
+	rows, err := mssqlQuery(ctx, sqlInstance, "tempdb", mssqlTempDBLatchQuery)
+	if err != nil {
+		return nil, err
+	}
+	waiting := map[string]float64{"pfs": 0, "gam": 0, "sgam": 0, "other": 0}
+	for _, row := range rows {
+		if pageType, ok := mssqlTempDBPageType(row["resource_description"]); ok {
+			waiting[pageType]++
+		}
+	}
+	for pageType, n := range waiting {
+		ch <- prometheus.MustNewConstMetric(
+			c.TempDBPageLatchWaitingTasks,
+			prometheus.GaugeValue,
+			n,
+			sqlInstance, pageType,
+		)
+	}
+
+	rows, err = mssqlQuery(ctx, sqlInstance, "tempdb", mssqlTempDBFilesQuery)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		file := row["file_name"]
+		pages := func(column string) float64 {
+			v, _ := strconv.ParseFloat(row[column], 64)
+			return v
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.TempDBFileSizeBytes,
+			prometheus.GaugeValue,
+			pages("size")*mssqlPageSize,
+			sqlInstance, file,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.TempDBFileFreeBytes,
+			prometheus.GaugeValue,
+			pages("unallocated_extent_page_count")*mssqlPageSize,
+			sqlInstance, file,
+		)
+		for column, usage := range mssqlTempDBUsages {
+			ch <- prometheus.MustNewConstMetric(
+				c.TempDBFileUsedBytes,
+				prometheus.GaugeValue,
+				pages(column)*mssqlPageSize,
+				sqlInstance, file, usage,
+			)
+		}
+	}
+	return nil, nil
+}
+
 type mssqlTransactions struct {
 	FreeSpaceintempdbKB            float64 `perflib:"Free Space in tempdb (KB)"`
 	LongestTransactionRunningTime  float64 `perflib:"Longest Transaction Running Time"`
@@ -3783,9 +4207,9 @@ type mssqlTransactions struct {
 // - https://docs.microsoft.com/en-us/sql/relational-databases/performance-monitor/sql-server-transactions-object
 func (c *MSSQLCollector) collectTransactions(ctx *ScrapeContext, ch chan<- prometheus.Metric, sqlInstance string) (*prometheus.Desc, error) {
 	var dst []mssqlTransactions
-	log.Debugf("mssql_transactions collector iterating sql instance %s.", sqlInstance)
+	mssqlLogger.Debugf("mssql_transactions collector iterating sql instance %s.", sqlInstance)
 
-	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "transactions")], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects[mssqlGetPerfObjectName(sqlInstance, "transactions")], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -1,9 +1,71 @@
 package collector
 
 import (
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/odbc32"
 )
 
 func BenchmarkMSSQLCollector(b *testing.B) {
 	benchmarkCollector(b, "mssql", NewMSSQLCollector)
 }
+
+func TestMSSQLBackups(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	rows := []odbc32.Row{
+		{"database_name": "Shop", "recovery_model": "FULL", "backup_type": "D", "age_seconds": "86400", "backup_size": "1048576"},
+		{"database_name": "Shop", "recovery_model": "FULL", "backup_type": "I", "age_seconds": "3600", "backup_size": "65536"},
+		{"database_name": "Shop", "recovery_model": "FULL", "backup_type": "L", "age_seconds": "600", "backup_size": "4096"},
+		// File backups are skipped.
+		{"database_name": "Shop", "recovery_model": "FULL", "backup_type": "F", "age_seconds": "60", "backup_size": "512"},
+		// Databases never backed up have no backup_type.
+		{"database_name": "Staging", "recovery_model": "FULL"},
+		// Databases with only log backups still have a full backup.
+		{"database_name": "Orders", "recovery_model": "FULL", "backup_type": "L", "age_seconds": "300", "backup_size": "2048"},
+		// Databases in the simple recovery model have no log backup.
+		{"database_name": "Reports", "recovery_model": "SIMPLE", "backup_type": "I", "age_seconds": "7200", "backup_size": "8192"},
+	}
+	epoch := time.Unix(0, 0)
+	expected := []mssqlBackup{
+		{database: "Shop", backupType: "full", finished: time.Unix(1599913600, 0), sizeBytes: 1048576},
+		{database: "Shop", backupType: "differential", finished: time.Unix(1599996400, 0), sizeBytes: 65536},
+		{database: "Shop", backupType: "log", finished: time.Unix(1599999400, 0), sizeBytes: 4096},
+		{database: "Staging", backupType: "full", finished: epoch},
+		{database: "Staging", backupType: "differential", finished: epoch},
+		{database: "Staging", backupType: "log", finished: epoch},
+		{database: "Orders", backupType: "full", finished: epoch},
+		{database: "Orders", backupType: "differential", finished: epoch},
+		{database: "Orders", backupType: "log", finished: time.Unix(1599999700, 0), sizeBytes: 2048},
+		{database: "Reports", backupType: "full", finished: epoch},
+		{database: "Reports", backupType: "differential", finished: time.Unix(1599992800, 0), sizeBytes: 8192},
+	}
+	if got := mssqlBackups(rows, now); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Backups do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
+
+func TestMSSQLTempDBPageType(t *testing.T) {
+	cases := map[string]string{
+		"2:1:1":      "pfs",
+		"2:3:8088":   "pfs",
+		"2:1:2":      "gam",
+		"2:1:511232": "gam",
+		"2:1:3":      "sgam",
+		"2:4:511233": "sgam",
+		"2:1:120":    "other",
+		// Anything following the page ID is ignored.
+		"2:1:1 (PFS)": "pfs",
+	}
+	for resource, expected := range cases {
+		if got, ok := mssqlTempDBPageType(resource); !ok || got != expected {
+			t.Errorf("mssqlTempDBPageType(%q) = %q, %v, expected %q", resource, got, ok, expected)
+		}
+	}
+	for _, resource := range []string{"5:1:1", "2:1", "", "2:1:x"} {
+		if _, ok := mssqlTempDBPageType(resource); ok {
+			t.Errorf("mssqlTempDBPageType(%q) succeeded", resource)
+		}
+	}
+}
@@ -15,6 +15,8 @@ func init() {
 	registerCollector("net", NewNetworkCollector, "Network Interface")
 }
 
+var netLogger = log.With("collector", "net")
+
 var (
 	nicWhitelist = kingpin.Flag(
 		"collector.net.nic-whitelist",
@@ -53,67 +55,67 @@ func NewNetworkCollector() (Collector, error) {
 	return &NetworkCollector{
 		BytesReceivedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bytes_received_total"),
-			"(Network.BytesReceivedPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "BytesReceivedPerSec"),
 			[]string{"nic"},
 			nil,
 		),
 		BytesSentTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bytes_sent_total"),
-			"(Network.BytesSentPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "BytesSentPerSec"),
 			[]string{"nic"},
 			nil,
 		),
 		BytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "bytes_total"),
-			"(Network.BytesTotalPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "BytesTotalPerSec"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsOutboundDiscarded: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_outbound_discarded_total"),
-			"(Network.PacketsOutboundDiscarded)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsOutboundDiscarded"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsOutboundErrors: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_outbound_errors_total"),
-			"(Network.PacketsOutboundErrors)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsOutboundErrors"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsReceivedDiscarded: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_received_discarded_total"),
-			"(Network.PacketsReceivedDiscarded)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsReceivedDiscarded"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsReceivedErrors: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_received_errors_total"),
-			"(Network.PacketsReceivedErrors)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsReceivedErrors"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsReceivedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_received_total"),
-			"(Network.PacketsReceivedPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsReceivedPerSec"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsReceivedUnknown: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_received_unknown_total"),
-			"(Network.PacketsReceivedUnknown)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsReceivedUnknown"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_total"),
-			"(Network.PacketsPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsPerSec"),
 			[]string{"nic"},
 			nil,
 		),
 		PacketsSentTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "packets_sent_total"),
-			"(Network.PacketsSentPerSec)",
+			counterHelp("Network Interface", networkInterface{}, "PacketsSentPerSec"),
 			[]string{"nic"},
 			nil,
 		),
@@ -133,7 +135,7 @@ func NewNetworkCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *NetworkCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting net metrics:", desc, err)
+		netLogger.Error("failed collecting net metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -166,7 +168,7 @@ type networkInterface struct {
 func (c *NetworkCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []networkInterface
 
-	if err := unmarshalObject(ctx.perfObjects["Network Interface"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["Network Interface"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
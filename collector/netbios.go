@@ -0,0 +1,193 @@
+// +build windows
+
+package collector
+
+import (
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("netbios", NewNetBIOSCollector, "NBT Connection", "WINS Server")
+}
+
+var netbiosLogger = log.With("collector", "netbios")
+
+// A NetBIOSCollector is a Prometheus collector for Perflib NetBIOS over TCP/IP
+// and WINS Server metrics
+type NetBIOSCollector struct {
+	Sessions      *prometheus.Desc
+	ReceivedBytes *prometheus.Desc
+	SentBytes     *prometheus.Desc
+
+	WINSRegistrations *prometheus.Desc
+	WINSRenewals      *prometheus.Desc
+	WINSConflicts     *prometheus.Desc
+	WINSReleases      *prometheus.Desc
+	WINSQueries       *prometheus.Desc
+}
+
+// NewNetBIOSCollector ...
+func NewNetBIOSCollector() (Collector, error) {
+	const subsystem = "netbios"
+
+	return &NetBIOSCollector{
+		Sessions: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "sessions"),
+			"Number of open NetBIOS over TCP/IP sessions",
+			nil,
+			nil,
+		),
+		ReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "received_bytes_total"),
+			"Total bytes received over NetBIOS over TCP/IP sessions",
+			nil,
+			nil,
+		),
+		SentBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "sent_bytes_total"),
+			"Total bytes sent over NetBIOS over TCP/IP sessions",
+			nil,
+			nil,
+		),
+		WINSRegistrations: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "wins_registrations_total"),
+			"Total name registrations received by the WINS server",
+			[]string{"type"},
+			nil,
+		),
+		WINSRenewals: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "wins_renewals_total"),
+			"Total name renewals received by the WINS server",
+			[]string{"type"},
+			nil,
+		),
+		WINSConflicts: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "wins_conflicts_total"),
+			"Total name conflicts seen by the WINS server",
+			[]string{"type"},
+			nil,
+		),
+		WINSReleases: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "wins_releases_total"),
+			"Total name releases processed by the WINS server",
+			[]string{"result"},
+			nil,
+		),
+		WINSQueries: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "wins_queries_total"),
+			"Total name queries processed by the WINS server",
+			[]string{"result"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *NetBIOSCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectSessions(ctx, ch); err != nil {
+		netbiosLogger.Error("failed collecting netbios metrics:", desc, err)
+		return err
+	}
+	// The WINS Server object only exists if the WINS Server feature is installed.
+	if ctx.perfObjects["WINS Server"] == nil {
+		return nil
+	}
+	if desc, err := c.collectWINS(ctx, ch); err != nil {
+		netbiosLogger.Error("failed collecting netbios wins metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// Perflib: "NBT Connection"
+type perflibNBTConnection struct {
+	Name string
+
+	BytesReceivedPerSec float64 `perflib:"Bytes Received/sec"`
+	BytesSentPerSec     float64 `perflib:"Bytes Sent/sec"`
+}
+
+func (c *NetBIOSCollector) collectSessions(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []perflibNBTConnection
+	if err := unmarshalObject(ctx.perfObjects["NBT Connection"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	// Every session is an instance named after the remote computer, in
+	// addition to the Total instance.
+	sessions := 0
+	for _, conn := range dst {
+		if conn.Name == "Total" {
+			ch <- prometheus.MustNewConstMetric(
+				c.ReceivedBytes,
+				prometheus.CounterValue,
+				conn.BytesReceivedPerSec,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.SentBytes,
+				prometheus.CounterValue,
+				conn.BytesSentPerSec,
+			)
+			continue
+		}
+		sessions++
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.Sessions,
+		prometheus.GaugeValue,
+		float64(sessions),
+	)
+	return nil, nil
+}
+
+// Perflib: "WINS Server"
+type perflibWINSServer struct {
+	UniqueRegistrationsPerSec float64 `perflib:"Unique Registrations/sec"`
+	GroupRegistrationsPerSec  float64 `perflib:"Group Registrations/sec"`
+	UniqueRenewalsPerSec      float64 `perflib:"Unique Renewals/sec"`
+	GroupRenewalsPerSec       float64 `perflib:"Group Renewals/sec"`
+	UniqueConflictsPerSec     float64 `perflib:"Unique Conflicts/sec"`
+	GroupConflictsPerSec      float64 `perflib:"Group Conflicts/sec"`
+	SuccessfulReleasesPerSec  float64 `perflib:"Successful Releases/sec"`
+	FailedReleasesPerSec      float64 `perflib:"Failed Releases/sec"`
+	SuccessfulQueriesPerSec   float64 `perflib:"Successful Queries/sec"`
+	FailedQueriesPerSec       float64 `perflib:"Failed Queries/sec"`
+}
+
+func (c *NetBIOSCollector) collectWINS(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []perflibWINSServer
+	if err := unmarshalObject(ctx.perfObjects["WINS Server"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+	if len(dst) == 0 {
+		return nil, nil
+	}
+	wins := dst[0]
+
+	for _, m := range []struct {
+		desc  *prometheus.Desc
+		value float64
+		label string
+	}{
+		{c.WINSRegistrations, wins.UniqueRegistrationsPerSec, "unique"},
+		{c.WINSRegistrations, wins.GroupRegistrationsPerSec, "group"},
+		{c.WINSRenewals, wins.UniqueRenewalsPerSec, "unique"},
+		{c.WINSRenewals, wins.GroupRenewalsPerSec, "group"},
+		{c.WINSConflicts, wins.UniqueConflictsPerSec, "unique"},
+		{c.WINSConflicts, wins.GroupConflictsPerSec, "group"},
+		{c.WINSReleases, wins.SuccessfulReleasesPerSec, "success"},
+		{c.WINSReleases, wins.FailedReleasesPerSec, "failure"},
+		{c.WINSQueries, wins.SuccessfulQueriesPerSec, "success"},
+		{c.WINSQueries, wins.FailedQueriesPerSec, "failure"},
+	} {
+		ch <- prometheus.MustNewConstMetric(
+			m.desc,
+			prometheus.CounterValue,
+			m.value,
+			m.label,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,9 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkNetBIOSCollector(b *testing.B) {
+	benchmarkCollector(b, "netbios", NewNetBIOSCollector)
+}
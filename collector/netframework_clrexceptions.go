@@ -3,7 +3,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -12,6 +11,8 @@ func init() {
 	registerCollector("netframework_clrexceptions", NewNETFramework_NETCLRExceptionsCollector)
 }
 
+var netframeworkClrexceptionsLogger = log.With("collector", "netframework_clrexceptions")
+
 // A NETFramework_NETCLRExceptionsCollector is a Prometheus collector for WMI Win32_PerfRawData_NETFramework_NETCLRExceptions metrics
 type NETFramework_NETCLRExceptionsCollector struct {
 	NumberofExcepsThrown *prometheus.Desc
@@ -55,7 +56,7 @@ func NewNETFramework_NETCLRExceptionsCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *NETFramework_NETCLRExceptionsCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting win32_perfrawdata_netframework_netclrexceptions metrics:", desc, err)
+		netframeworkClrexceptionsLogger.Error("failed collecting win32_perfrawdata_netframework_netclrexceptions metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -74,7 +75,7 @@ type Win32_PerfRawData_NETFramework_NETCLRExceptions struct {
 func (c *NETFramework_NETCLRExceptionsCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NETFramework_NETCLRExceptions
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
@@ -3,7 +3,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -12,6 +11,8 @@ func init() {
 	registerCollector("netframework_clrinterop", NewNETFramework_NETCLRInteropCollector)
 }
 
+var netframeworkClrinteropLogger = log.With("collector", "netframework_clrinterop")
+
 // A NETFramework_NETCLRInteropCollector is a Prometheus collector for WMI Win32_PerfRawData_NETFramework_NETCLRInterop metrics
 type NETFramework_NETCLRInteropCollector struct {
 	NumberofCCWs        *prometheus.Desc
@@ -48,7 +49,7 @@ func NewNETFramework_NETCLRInteropCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *NETFramework_NETCLRInteropCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting win32_perfrawdata_netframework_netclrinterop metrics:", desc, err)
+		netframeworkClrinteropLogger.Error("failed collecting win32_perfrawdata_netframework_netclrinterop metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -67,7 +68,7 @@ type Win32_PerfRawData_NETFramework_NETCLRInterop struct {
 func (c *NETFramework_NETCLRInteropCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NETFramework_NETCLRInterop
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
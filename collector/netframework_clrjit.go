@@ -3,7 +3,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -12,6 +11,8 @@ func init() {
 	registerCollector("netframework_clrjit", NewNETFramework_NETCLRJitCollector)
 }
 
+var netframeworkClrjitLogger = log.With("collector", "netframework_clrjit")
+
 // A NETFramework_NETCLRJitCollector is a Prometheus collector for WMI Win32_PerfRawData_NETFramework_NETCLRJit metrics
 type NETFramework_NETCLRJitCollector struct {
 	NumberofMethodsJitted      *prometheus.Desc
@@ -55,7 +56,7 @@ func NewNETFramework_NETCLRJitCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *NETFramework_NETCLRJitCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting win32_perfrawdata_netframework_netclrjit metrics:", desc, err)
+		netframeworkClrjitLogger.Error("failed collecting win32_perfrawdata_netframework_netclrjit metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -76,7 +77,7 @@ type Win32_PerfRawData_NETFramework_NETCLRJit struct {
 func (c *NETFramework_NETCLRJitCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NETFramework_NETCLRJit
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
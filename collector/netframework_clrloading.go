@@ -3,7 +3,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -12,6 +11,8 @@ func init() {
 	registerCollector("netframework_clrloading", NewNETFramework_NETCLRLoadingCollector)
 }
 
+var netframeworkClrloadingLogger = log.With("collector", "netframework_clrloading")
+
 // A NETFramework_NETCLRLoadingCollector is a Prometheus collector for WMI Win32_PerfRawData_NETFramework_NETCLRLoading metrics
 type NETFramework_NETCLRLoadingCollector struct {
 	BytesinLoaderHeap         *prometheus.Desc
@@ -90,7 +91,7 @@ func NewNETFramework_NETCLRLoadingCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *NETFramework_NETCLRLoadingCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting win32_perfrawdata_netframework_netclrloading metrics:", desc, err)
+		netframeworkClrloadingLogger.Error("failed collecting win32_perfrawdata_netframework_netclrloading metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -120,7 +121,7 @@ type Win32_PerfRawData_NETFramework_NETCLRLoading struct {
 func (c *NETFramework_NETCLRLoadingCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_NETFramework_NETCLRLoading
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
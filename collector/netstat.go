@@ -0,0 +1,196 @@
+// +build windows
+
+package collector
+
+import (
+	"errors"
+
+	"github.com/prometheus-community/windows_exporter/headers/iphlpapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("netstat", NewNetstatCollector)
+}
+
+var netstatLogger = log.With("collector", "netstat")
+
+var netstatFamilies = []struct {
+	family uint16
+	label  string
+}{
+	{iphlpapi.AF_INET, "ipv4"},
+	{iphlpapi.AF_INET6, "ipv6"},
+}
+
+// A NetstatCollector is a Prometheus collector for the routing table,
+// neighbor cache and dynamic port usage of the IP stack
+type NetstatCollector struct {
+	Routes            *prometheus.Desc
+	Neighbors         *prometheus.Desc
+	DynamicPorts      *prometheus.Desc
+	DynamicPortsInUse *prometheus.Desc
+}
+
+// NewNetstatCollector ...
+func NewNetstatCollector() (Collector, error) {
+	const subsystem = "netstat"
+
+	return &NetstatCollector{
+		Routes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "routes"),
+			"Number of entries of the routing table",
+			[]string{"family"},
+			nil,
+		),
+		Neighbors: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "neighbors"),
+			"Number of entries of the neighbor cache, the ARP cache for IPv4, by state",
+			[]string{"family", "state"},
+			nil,
+		),
+		DynamicPorts: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "dynamic_ports"),
+			"Number of ports of the dynamic port range, from which ephemeral ports are allocated",
+			[]string{"protocol"},
+			nil,
+		),
+		DynamicPortsInUse: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "dynamic_ports_in_use"),
+			"Number of distinct local ports of the dynamic port range in use by endpoints",
+			[]string{"protocol", "family"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *NetstatCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectRoutes(ch); err != nil {
+		netstatLogger.Error("failed collecting netstat routes:", desc, err)
+		return err
+	}
+	if desc, err := c.collectNeighbors(ch); err != nil {
+		netstatLogger.Error("failed collecting netstat neighbors:", desc, err)
+		return err
+	}
+	if desc, err := c.collectDynamicPorts(ch); err != nil {
+		netstatLogger.Error("failed collecting netstat dynamic ports:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *NetstatCollector) collectRoutes(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	for _, f := range netstatFamilies {
+		count, err := iphlpapi.RouteCount(f.family)
+		if err != nil {
+			return c.Routes, err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.Routes,
+			prometheus.GaugeValue,
+			float64(count),
+			f.label,
+		)
+	}
+	return nil, nil
+}
+
+func (c *NetstatCollector) collectNeighbors(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	for _, f := range netstatFamilies {
+		neighbors, err := iphlpapi.Neighbors(f.family)
+		if err != nil {
+			return c.Neighbors, err
+		}
+		counts := make(map[iphlpapi.NeighborState]int)
+		for _, n := range neighbors {
+			counts[n.State]++
+		}
+		for state := iphlpapi.NeighborStateUnreachable; state <= iphlpapi.NeighborStatePermanent; state++ {
+			ch <- prometheus.MustNewConstMetric(
+				c.Neighbors,
+				prometheus.GaugeValue,
+				float64(counts[state]),
+				f.label, state.String(),
+			)
+		}
+	}
+	return nil, nil
+}
+
+// MSFT_NetTCPSetting and MSFT_NetUDPSetting docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/tcpip/msft-nettcpsetting
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/tcpip/msft-netudpsetting
+type netstatPortSetting struct {
+	DynamicPortRangeStartPort     uint16
+	DynamicPortRangeNumberOfPorts uint16
+}
+
+// dynamicPortRange returns the dynamic port range of a protocol. The range is
+// global, but also exposed by every TCP setting template, some of which
+// report it as empty.
+func dynamicPortRange(class string) (uint16, uint16, error) {
+	var dst []netstatPortSetting
+	q := queryAllForClass(&dst, class)
+	if err := queryWMINamespace(q, &dst, "root/StandardCimv2"); err != nil {
+		return 0, 0, err
+	}
+	for _, s := range dst {
+		if s.DynamicPortRangeNumberOfPorts > 0 {
+			return s.DynamicPortRangeStartPort, s.DynamicPortRangeNumberOfPorts, nil
+		}
+	}
+	return 0, 0, errors.New("no dynamic port range found in " + class)
+}
+
+// countPortsInRange returns the number of distinct ports within the range of
+// count ports starting at start.
+func countPortsInRange(ports []uint16, start, count uint16) int {
+	end := uint32(start) + uint32(count)
+	seen := make(map[uint16]bool)
+	for _, p := range ports {
+		if uint32(p) >= uint32(start) && uint32(p) < end {
+			seen[p] = true
+		}
+	}
+	return len(seen)
+}
+
+func (c *NetstatCollector) collectDynamicPorts(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	for _, proto := range []struct {
+		name  string
+		class string
+		ports func(uint16) ([]uint16, error)
+	}{
+		{"tcp", "MSFT_NetTCPSetting", iphlpapi.TCPLocalPorts},
+		{"udp", "MSFT_NetUDPSetting", iphlpapi.UDPLocalPorts},
+	} {
+		start, count, err := dynamicPortRange(proto.class)
+		if err != nil {
+			return c.DynamicPorts, err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.DynamicPorts,
+			prometheus.GaugeValue,
+			float64(count),
+			proto.name,
+		)
+
+		for _, f := range netstatFamilies {
+			ports, err := proto.ports(f.family)
+			if err != nil {
+				return c.DynamicPortsInUse, err
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.DynamicPortsInUse,
+				prometheus.GaugeValue,
+				float64(countPortsInRange(ports, start, count)),
+				proto.name, f.label,
+			)
+		}
+	}
+	return nil, nil
+}
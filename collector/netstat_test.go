@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestCountPortsInRange(t *testing.T) {
+	// Ports shared by several endpoints are only counted once.
+	ports := []uint16{80, 49151, 49152, 49152, 50000, 65535}
+	if got := countPortsInRange(ports, 49152, 16384); got != 3 {
+		t.Errorf("expected 3 ports, got %d", got)
+	}
+	if got := countPortsInRange(ports, 49152, 100); got != 1 {
+		t.Errorf("expected 1 port, got %d", got)
+	}
+}
+
+func BenchmarkNetstatCollector(b *testing.B) {
+	benchmarkCollector(b, "netstat", NewNetstatCollector)
+}
@@ -0,0 +1,186 @@
+// +build windows
+
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/headers/winioctl"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+func init() {
+	registerCollector("ntfs", newNTFSCollector)
+}
+
+var ntfsLogger = log.With("collector", "ntfs")
+
+// ntfsShortNamesPerVolume is the default NtfsDisable8dot3NameCreation since
+// Windows 8 and Windows Server 2012, deferring to the setting of each volume.
+const ntfsShortNamesPerVolume = 2
+
+// A NTFSCollector is a Prometheus collector for the master file table, change
+// journal and short name creation of the local NTFS volumes
+type NTFSCollector struct {
+	MFTSizeBytes               *prometheus.Desc
+	USNJournalSizeBytes        *prometheus.Desc
+	USNJournalMaximumSizeBytes *prometheus.Desc
+	ShortNamesEnabled          *prometheus.Desc
+}
+
+func newNTFSCollector() (Collector, error) {
+	const subsystem = "ntfs"
+
+	return &NTFSCollector{
+		MFTSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "mft_size_bytes"),
+			"Size of the valid data of the master file table of the volume",
+			[]string{"volume"},
+			nil,
+		),
+		USNJournalSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "usn_journal_size_bytes"),
+			"Size of the records currently held by the change journal of the volume",
+			[]string{"volume"},
+			nil,
+		),
+		USNJournalMaximumSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "usn_journal_maximum_size_bytes"),
+			"Size the change journal of the volume is truncated to when it grows beyond it",
+			[]string{"volume"},
+			nil,
+		),
+		ShortNamesEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "short_names_enabled"),
+			"Whether 8.3 short names are created for new files on the volume (1) or not (0)",
+			[]string{"volume"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *NTFSCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectVolumes(ctx, ch); err != nil {
+		ntfsLogger.Error("failed collecting ntfs metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// ntfsVolume is the Win32_Volume of a local NTFS volume.
+type ntfsVolume struct {
+	DeviceID    string
+	DriveLetter string
+	Name        string
+	BootVolume  bool
+}
+
+// label returns the drive letter of the volume, e.g. C:, or its mount point
+// or volume GUID path if it has none.
+func (v ntfsVolume) label() string {
+	if v.DriveLetter != "" {
+		return v.DriveLetter
+	}
+	return v.Name
+}
+
+// ntfsShortNamesEnabled returns whether short names are created on a volume
+// for the NtfsDisable8dot3NameCreation setting of the system, and whether
+// the setting is known.
+// https://docs.microsoft.com/en-us/windows-server/administration/windows-commands/fsutil-8dot3name
+func ntfsShortNamesEnabled(setting uint64, volumeDisabled, bootVolume bool) (bool, bool) {
+	switch setting {
+	case 0:
+		return true, true
+	case 1:
+		return false, true
+	case ntfsShortNamesPerVolume:
+		return !volumeDisabled, true
+	case 3:
+		// Disabled on all volumes except the one Windows is installed on.
+		return bootVolume, true
+	default:
+		return false, false
+	}
+}
+
+func (c *NTFSCollector) collectVolumes(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var volumes []ntfsVolume
+	q := "SELECT DeviceID, DriveLetter, Name, BootVolume FROM Win32_Volume WHERE DriveType = 3 AND FileSystem = 'NTFS'"
+	if err := queryWMIContext(ctx.Context(), q, &volumes); err != nil {
+		return nil, err
+	}
+
+	setting := uint64(ntfsShortNamesPerVolume)
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\FileSystem`, registry.QUERY_VALUE)
+	if err == nil {
+		if v, _, err := k.GetIntegerValue("NtfsDisable8dot3NameCreation"); err == nil {
+			setting = v
+		}
+		k.Close()
+	}
+
+	for _, v := range volumes {
+		c.collectVolume(v, setting, ch)
+	}
+	return nil, nil
+}
+
+// collectVolume sends the metrics of a volume. Volumes that can't be opened,
+// e.g. without administrative rights, are skipped.
+func (c *NTFSCollector) collectVolume(v ntfsVolume, shortNameSetting uint64, ch chan<- prometheus.Metric) {
+	h, err := winioctl.OpenVolume(strings.TrimSuffix(v.DeviceID, `\`))
+	if err != nil {
+		ntfsLogger.Debugf("Failed to open volume %s: %v", v.label(), err)
+		return
+	}
+	defer windows.CloseHandle(h)
+
+	if data, err := winioctl.GetNTFSVolumeData(h); err == nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.MFTSizeBytes,
+			prometheus.GaugeValue,
+			float64(data.MftValidDataLength),
+			v.label(),
+		)
+	} else {
+		ntfsLogger.Debugf("Failed to get the NTFS data of volume %s: %v", v.label(), err)
+	}
+
+	// Volumes without a change journal only leave out its metrics.
+	if journal, err := winioctl.QueryUSNJournal(h); err == nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.USNJournalSizeBytes,
+			prometheus.GaugeValue,
+			float64(journal.NextUsn-journal.FirstUsn),
+			v.label(),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.USNJournalMaximumSizeBytes,
+			prometheus.GaugeValue,
+			float64(journal.MaximumSize),
+			v.label(),
+		)
+	} else if err != windows.ERROR_JOURNAL_NOT_ACTIVE {
+		ntfsLogger.Debugf("Failed to query the change journal of volume %s: %v", v.label(), err)
+	}
+
+	volumeDisabled, err := winioctl.ShortNameCreationDisabled(h)
+	if err != nil {
+		ntfsLogger.Debugf("Failed to query the short name creation of volume %s: %v", v.label(), err)
+		return
+	}
+	if enabled, ok := ntfsShortNamesEnabled(shortNameSetting, volumeDisabled, v.BootVolume); ok {
+		ch <- prometheus.MustNewConstMetric(
+			c.ShortNamesEnabled,
+			prometheus.GaugeValue,
+			boolToFloat(enabled),
+			v.label(),
+		)
+	}
+}
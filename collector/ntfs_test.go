@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkNTFSCollector(b *testing.B) {
+	benchmarkCollector(b, "ntfs", newNTFSCollector)
+}
+
+func TestNTFSShortNamesEnabled(t *testing.T) {
+	cases := []struct {
+		setting        uint64
+		volumeDisabled bool
+		bootVolume     bool
+		enabled        bool
+		ok             bool
+	}{
+		{setting: 0, volumeDisabled: true, enabled: true, ok: true},
+		{setting: 1, bootVolume: true, enabled: false, ok: true},
+		{setting: 2, volumeDisabled: false, enabled: true, ok: true},
+		{setting: 2, volumeDisabled: true, enabled: false, ok: true},
+		{setting: 3, bootVolume: true, enabled: true, ok: true},
+		{setting: 3, volumeDisabled: false, enabled: false, ok: true},
+		{setting: 4, enabled: false, ok: false},
+	}
+	for _, c := range cases {
+		enabled, ok := ntfsShortNamesEnabled(c.setting, c.volumeDisabled, c.bootVolume)
+		if enabled != c.enabled || ok != c.ok {
+			t.Errorf("Short names for setting %d, volume disabled %t, boot volume %t do not match!\nExpected result: %t, %t\nActual result: %t, %t",
+				c.setting, c.volumeDisabled, c.bootVolume, c.enabled, c.ok, enabled, ok)
+		}
+	}
+}
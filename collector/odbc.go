@@ -0,0 +1,347 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus-community/windows_exporter/headers/odbc32"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	registerCollector("odbc", newODBCCollector)
+}
+
+var odbcLogger = log.With("collector", "odbc")
+
+var odbcConfigFile = kingpin.Flag(
+	"collector.odbc.config-file",
+	"YAML file listing the ODBC queries to run.",
+).Default("").String()
+
+const (
+	odbcDefaultInterval = time.Minute
+	odbcDefaultTimeout  = 30 * time.Second
+)
+
+// odbcQuery is an entry of the queries section of the ODBC configuration file.
+type odbcQuery struct {
+	Name string `yaml:"name"`
+	// ConnectionString is passed to SQLDriverConnect, e.g. DSN=orders or
+	// Driver={ODBC Driver 17 for SQL Server};Server=.\SQLEXPRESS;Trusted_Connection=yes.
+	ConnectionString string `yaml:"connection_string"`
+	Query            string `yaml:"query"`
+	// Values are the columns exposed as metrics, each row being a sample.
+	Values []string `yaml:"values"`
+	// Labels are the columns whose values label the samples.
+	Labels   []string      `yaml:"labels"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type odbcConfig struct {
+	Queries []odbcQuery `yaml:"queries"`
+}
+
+// odbcSample is a value of a row of a query result.
+type odbcSample struct {
+	column string
+	value  float64
+	labels []string
+}
+
+// odbcResult is the outcome of the last run of a query.
+type odbcResult struct {
+	success  bool
+	duration time.Duration
+	time     time.Time
+	samples  []odbcSample
+}
+
+// odbcRunner runs a query at its interval, off the scrape path, keeping the
+// result of the last run.
+type odbcRunner struct {
+	query  odbcQuery
+	values map[string]*prometheus.Desc
+
+	mu     sync.Mutex
+	result *odbcResult
+}
+
+// An ODBCCollector is a Prometheus collector for the results of SQL queries
+// against ODBC data sources
+type ODBCCollector struct {
+	QuerySuccess  *prometheus.Desc
+	QueryDuration *prometheus.Desc
+	QueryLastRun  *prometheus.Desc
+
+	runners []*odbcRunner
+	done    chan struct{}
+}
+
+func newODBCCollector() (Collector, error) {
+	const subsystem = "odbc"
+
+	var cfg odbcConfig
+	if *odbcConfigFile != "" {
+		b, err := ioutil.ReadFile(*odbcConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", *odbcConfigFile, err)
+		}
+	}
+	if err := validateODBCQueries(cfg.Queries); err != nil {
+		return nil, err
+	}
+
+	c := &ODBCCollector{
+		QuerySuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "query_success"),
+			"Whether the last run of the query succeeded (1) or not (0)",
+			[]string{"query"},
+			nil,
+		),
+		QueryDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "query_duration_seconds"),
+			"Duration of the last run of the query",
+			[]string{"query"},
+			nil,
+		),
+		QueryLastRun: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "query_last_run_timestamp_seconds"),
+			"Time the query was last run",
+			[]string{"query"},
+			nil,
+		),
+		done: make(chan struct{}),
+	}
+
+	for _, q := range cfg.Queries {
+		// The connection string may hold a password, so it can be a
+		// reference to a secret like the values of the configuration file.
+		connectionString, err := config.ResolveSecret(q.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("ODBC query %s: connection_string: %v", q.Name, err)
+		}
+		q.ConnectionString = connectionString
+		if q.Interval <= 0 {
+			q.Interval = odbcDefaultInterval
+		}
+		if q.Timeout <= 0 {
+			q.Timeout = odbcDefaultTimeout
+		}
+
+		r := &odbcRunner{query: q, values: make(map[string]*prometheus.Desc)}
+		for _, column := range q.Values {
+			r.values[column] = prometheus.NewDesc(
+				prometheus.BuildFQName(Namespace, subsystem, q.Name+"_"+odbcMetricName(column)),
+				fmt.Sprintf("Column %s of ODBC query %s", column, q.Name),
+				odbcLabelNames(q.Labels),
+				nil,
+			)
+		}
+		c.runners = append(c.runners, r)
+	}
+	for _, r := range c.runners {
+		go r.run(c.done)
+	}
+	return c, nil
+}
+
+// Close stops running the queries. Queries in progress complete first.
+func (c *ODBCCollector) Close() error {
+	close(c.done)
+	return nil
+}
+
+// odbcQueryMetrics are the names of the metrics exposed for every query,
+// which the metrics of the columns must not collide with.
+var odbcQueryMetrics = []string{"query_success", "query_duration_seconds", "query_last_run_timestamp_seconds"}
+
+// validateODBCQueries validates the queries, and checks that their names are
+// unique and the metrics and labels built from their columns don't collide,
+// as the collisions would only surface when scraping.
+func validateODBCQueries(queries []odbcQuery) error {
+	names := make(map[string]bool)
+	// metrics maps the metric names to the query and column they come from.
+	metrics := make(map[string]string)
+	for _, name := range odbcQueryMetrics {
+		metrics[name] = "the collector"
+	}
+	for _, q := range queries {
+		if err := validateODBCQuery(q); err != nil {
+			return err
+		}
+		if names[q.Name] {
+			return fmt.Errorf("duplicate ODBC query %s", q.Name)
+		}
+		names[q.Name] = true
+
+		for _, column := range q.Values {
+			name := q.Name + "_" + odbcMetricName(column)
+			if other, ok := metrics[name]; ok {
+				return fmt.Errorf("ODBC query %s: metric %s of column %s is also exposed by %s", q.Name, name, column, other)
+			}
+			metrics[name] = fmt.Sprintf("column %s of query %s", column, q.Name)
+		}
+		labels := make(map[string]string)
+		for _, column := range q.Labels {
+			name := odbcMetricName(column)
+			if other, ok := labels[name]; ok {
+				return fmt.Errorf("ODBC query %s: columns %s and %s are both exposed as label %s", q.Name, other, column, name)
+			}
+			labels[name] = column
+		}
+	}
+	return nil
+}
+
+func validateODBCQuery(q odbcQuery) error {
+	switch {
+	case q.Name == "":
+		return fmt.Errorf("ODBC query without name")
+	case odbcMetricName(q.Name) != q.Name:
+		return fmt.Errorf("ODBC query %s: name must only contain lower case letters, digits and underscores", q.Name)
+	case q.ConnectionString == "":
+		return fmt.Errorf("ODBC query %s: connection_string is required", q.Name)
+	case q.Query == "":
+		return fmt.Errorf("ODBC query %s: query is required", q.Name)
+	case len(q.Values) == 0:
+		return fmt.Errorf("ODBC query %s: values must list at least one column", q.Name)
+	}
+	return nil
+}
+
+// run runs the query until done is closed.
+func (r *odbcRunner) run(done <-chan struct{}) {
+	for {
+		start := time.Now()
+		rows, err := odbc32.Query(r.query.ConnectionString, r.query.Query, r.query.Timeout)
+		result := &odbcResult{
+			success:  err == nil,
+			duration: time.Since(start),
+			time:     start,
+		}
+		if err != nil {
+			odbcLogger.Warnf("ODBC query %s failed: %v", r.query.Name, err)
+		} else {
+			result.samples = odbcSamples(r.query, rows)
+		}
+		r.mu.Lock()
+		r.result = result
+		r.mu.Unlock()
+		select {
+		case <-done:
+			return
+		case <-time.After(r.query.Interval):
+		}
+	}
+}
+
+// odbcSamples returns the samples of the value columns of the rows. Values
+// that are NULL or not numeric, and rows whose labels repeat those of an
+// earlier row, are skipped.
+func odbcSamples(q odbcQuery, rows []odbc32.Row) []odbcSample {
+	var samples []odbcSample
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		labels := make([]string, len(q.Labels))
+		for i, column := range q.Labels {
+			labels[i] = row[column]
+		}
+		key := strings.Join(labels, "\xff")
+		if seen[key] {
+			odbcLogger.Debugf("ODBC query %s: ignoring row with duplicate labels %v", q.Name, labels)
+			continue
+		}
+		seen[key] = true
+
+		for _, column := range q.Values {
+			s, ok := row[column]
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				odbcLogger.Debugf("ODBC query %s: ignoring non-numeric value %q of column %s", q.Name, s, column)
+				continue
+			}
+			samples = append(samples, odbcSample{column: column, value: v, labels: labels})
+		}
+	}
+	return samples
+}
+
+// odbcMetricName converts a column name to a metric or label name, replacing
+// invalid characters with underscores.
+func odbcMetricName(column string) string {
+	b := []byte(strings.ToLower(column))
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c == '_' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func odbcLabelNames(columns []string) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = odbcMetricName(column)
+	}
+	return names
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ODBCCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	for _, r := range c.runners {
+		r.mu.Lock()
+		result := r.result
+		r.mu.Unlock()
+		// The query hasn't completed its first run yet.
+		if result == nil {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.QuerySuccess,
+			prometheus.GaugeValue,
+			boolToFloat(result.success),
+			r.query.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.QueryDuration,
+			prometheus.GaugeValue,
+			result.duration.Seconds(),
+			r.query.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.QueryLastRun,
+			prometheus.GaugeValue,
+			float64(result.time.UnixNano())/1e9,
+			r.query.Name,
+		)
+		for _, s := range result.samples {
+			ch <- prometheus.MustNewConstMetric(
+				r.values[s.column],
+				prometheus.GaugeValue,
+				s.value,
+				s.labels...,
+			)
+		}
+	}
+	return nil
+}
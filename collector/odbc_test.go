@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/headers/odbc32"
+)
+
+func BenchmarkODBCCollector(b *testing.B) {
+	benchmarkCollector(b, "odbc", newODBCCollector)
+}
+
+func TestODBCMetricName(t *testing.T) {
+	cases := map[string]string{
+		"pending":        "pending",
+		"Pending Orders": "pending_orders",
+		"2nd_level":      "_nd_level",
+		"queue-depth":    "queue_depth",
+	}
+	for column, expected := range cases {
+		if got := odbcMetricName(column); got != expected {
+			t.Errorf("odbcMetricName(%q) = %q, expected %q", column, got, expected)
+		}
+	}
+}
+
+func TestODBCSamples(t *testing.T) {
+	q := odbcQuery{
+		Name:   "orders",
+		Values: []string{"count", "oldest_age"},
+		Labels: []string{"status"},
+	}
+	rows := []odbc32.Row{
+		{"status": "pending", "count": "12", "oldest_age": " 360.5 "},
+		// NULL ages are missing.
+		{"status": "failed", "count": "0"},
+		{"status": "pending", "count": "13"},
+		{"status": "shipped", "count": "n/a", "oldest_age": "0"},
+	}
+
+	expected := []odbcSample{
+		{column: "count", value: 12, labels: []string{"pending"}},
+		{column: "oldest_age", value: 360.5, labels: []string{"pending"}},
+		{column: "count", value: 0, labels: []string{"failed"}},
+		{column: "oldest_age", value: 0, labels: []string{"shipped"}},
+	}
+	if got := odbcSamples(q, rows); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Samples do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
+
+func TestValidateODBCQueries(t *testing.T) {
+	query := func(name string, values []string, labels []string) odbcQuery {
+		return odbcQuery{Name: name, ConnectionString: "DSN=test", Query: "SELECT 1", Values: values, Labels: labels}
+	}
+	cases := []struct {
+		name    string
+		queries []odbcQuery
+		valid   bool
+	}{
+		{"valid", []odbcQuery{query("orders", []string{"count", "oldest_age"}, []string{"status", "region"}), query("backlog", []string{"count"}, []string{"status"})}, true},
+		{"duplicate query", []odbcQuery{query("orders", []string{"count"}, nil), query("orders", []string{"age"}, nil)}, false},
+		{"duplicate normalized label", []odbcQuery{query("orders", []string{"count"}, []string{"Order Status", "order_status"})}, false},
+		{"duplicate normalized value", []odbcQuery{query("orders", []string{"Count", "count"}, nil)}, false},
+		{"metric colliding across queries", []odbcQuery{query("orders_open", []string{"count"}, nil), query("orders", []string{"open_count"}, nil)}, false},
+		{"metric colliding with query metrics", []odbcQuery{query("query", []string{"success"}, nil)}, false},
+	}
+	for _, c := range cases {
+		if err := validateODBCQueries(c.queries); (err == nil) != c.valid {
+			t.Errorf("%s: expected valid %t, got error %v", c.name, c.valid, err)
+		}
+	}
+}
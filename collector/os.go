@@ -20,6 +20,8 @@ func init() {
 	registerCollector("os", NewOSCollector, "Paging File")
 }
 
+var osLogger = log.With("collector", "os")
+
 // A OSCollector is a Prometheus collector for WMI metrics
 type OSCollector struct {
 	OSInformation           *prometheus.Desc
@@ -48,11 +50,11 @@ func NewOSCollector() (Collector, error) {
 	const subsystem = "os"
 
 	return &OSCollector{
-		OSInformation: prometheus.NewDesc(
-			prometheus.BuildFQName(Namespace, subsystem, "info"),
+		OSInformation: newInfoDesc(
+			subsystem,
 			"OperatingSystem.Caption, OperatingSystem.Version",
-			[]string{"product", "version"},
 			nil,
+			"product", "version",
 		),
 		PagingLimitBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "paging_limit_bytes"),
@@ -133,7 +135,7 @@ func NewOSCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *OSCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting os metrics:", desc, err)
+		osLogger.Error("failed collecting os metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -217,7 +219,7 @@ func (c *OSCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (
 	}
 
 	var pfc = make([]pagingFileCounter, 0)
-	if err := unmarshalObject(ctx.perfObjects["Paging File"], &pfc); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["Paging File"], &pfc, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -233,10 +235,8 @@ func (c *OSCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (
 	// Subtract from total page file allocation on disk.
 	pfb := fsipf - (pfbRaw * float64(gpi.PageSize))
 
-	ch <- prometheus.MustNewConstMetric(
+	ch <- newInfoMetric(
 		c.OSInformation,
-		prometheus.GaugeValue,
-		1.0,
 		fmt.Sprintf("Microsoft %s", pn), // Caption
 		fmt.Sprintf("%d.%d.%s", nwgi.VersionMajor, nwgi.VersionMinor, bn), // Version
 	)
@@ -4,14 +4,40 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	perflibCollector "github.com/leoluk/perflib_exporter/collector"
 	"github.com/leoluk/perflib_exporter/perflib"
+	perflibv2 "github.com/prometheus-community/windows_exporter/headers/perflib"
 	"github.com/prometheus-community/windows_exporter/log"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var nametable = perflib.QueryNameTable("Counter 009") // Reads the names in English TODO: validate that the English names are always present
 
+var (
+	perflibBackend = kingpin.Flag(
+		"collectors.perflib.backend",
+		"Performance counter backend used by perflib based collectors. One of [v1, v2]. v2 uses the PerfLib V2 consumer API (PerfOpenQueryHandle).",
+	).Default("v1").Enum("v1", "v2")
+	perflibV2Collectors = kingpin.Flag(
+		"collectors.perflib.v2-collectors",
+		"Comma-separated list of collectors that use the PerfLib V2 backend, regardless of --collectors.perflib.backend.",
+	).Default("").String()
+)
+
+// usePerflibV2 reports whether the counters of the given collector should be
+// read with the PerfLib V2 consumer API.
+func usePerflibV2(collector string) bool {
+	if *perflibBackend == "v2" {
+		return true
+	}
+	return find(expandEnabledChildCollectors(*perflibV2Collectors), collector)
+}
+
 func MapCounterToIndex(name string) string {
 	return strconv.Itoa(int(nametable.LookupIndex(name)))
 }
@@ -29,7 +55,346 @@ func getPerflibSnapshot(objNames string) (map[string]*perflib.PerfObject, error)
 	return indexed, nil
 }
 
-func unmarshalObject(obj *perflib.PerfObject, vs interface{}) error {
+// counterSetCache holds the PerfLib V2 counter set registrations per machine,
+// indexed by their English name. The local machine is keyed by "".
+var counterSetCache struct {
+	sync.Mutex
+	machines map[string]map[string]perflibv2.CounterSet
+}
+
+func lookupCounterSet(machine string, name string) (perflibv2.CounterSet, error) {
+	counterSetCache.Lock()
+	defer counterSetCache.Unlock()
+
+	if counterSetCache.machines == nil {
+		counterSetCache.machines = make(map[string]map[string]perflibv2.CounterSet)
+	}
+	sets, ok := counterSetCache.machines[machine]
+	if !ok {
+		guids, err := perflibv2.EnumerateCounterSets(machine)
+		if err != nil {
+			return perflibv2.CounterSet{}, err
+		}
+		sets = make(map[string]perflibv2.CounterSet, len(guids))
+		for _, guid := range guids {
+			set, err := perflibv2.QueryCounterSet(machine, guid)
+			if err != nil {
+				log.Debugf("Skipping counter set %v: %v", guid, err)
+				continue
+			}
+			sets[set.Name] = set
+		}
+		counterSetCache.machines[machine] = sets
+	}
+
+	set, ok := sets[name]
+	if !ok {
+		return perflibv2.CounterSet{}, fmt.Errorf("counter set %q not found", name)
+	}
+	return set, nil
+}
+
+// getPerflibV2Snapshot queries the named counter sets with the PerfLib V2
+// consumer API and converts them to the objects returned by the V1 backend,
+// so collectors can unmarshal them unchanged.
+func getPerflibV2Snapshot(machine string, names []string) (map[string]*perflib.PerfObject, time.Time, error) {
+	sets := make([]perflibv2.CounterSet, 0, len(names))
+	guids := make([]windows.GUID, 0, len(names))
+	for _, name := range names {
+		set, err := lookupCounterSet(machine, name)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		sets = append(sets, set)
+		guids = append(guids, set.GUID)
+	}
+
+	snapshot, err := perflibv2.Query(machine, guids)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	indexed := make(map[string]*perflib.PerfObject, len(sets))
+	for _, data := range snapshot.Data {
+		for _, set := range sets {
+			if set.GUID == data.GUID {
+				indexed[set.Name] = counterSetToObject(set, data, snapshot.Frequency)
+				break
+			}
+		}
+	}
+	return indexed, snapshot.Time, nil
+}
+
+// remoteV2RetryInterval is how often the PerfLib V2 API is tried again for
+// remote hosts whose counters are read from the remote registry.
+const remoteV2RetryInterval = 10 * time.Minute
+
+// remoteRegistryHosts holds the remote hosts whose counters are read from
+// HKEY_PERFORMANCE_DATA, after the PerfLib V2 API failed for them, with the
+// time to try the PerfLib V2 API again.
+var remoteRegistryHosts sync.Map
+
+// getRemoteSnapshot queries the named objects of a remote host with the
+// PerfLib V2 API. If that fails, e.g. because a firewall blocks its RPC
+// interface, the objects are read through the Remote Registry service instead,
+// and the PerfLib V2 API is only tried again every remoteV2RetryInterval.
+func getRemoteSnapshot(machine string, names []string) (map[string]*perflib.PerfObject, time.Time, error) {
+	retry, fallback := remoteRegistryHosts.Load(machine)
+	if fallback && time.Now().Before(retry.(time.Time)) {
+		return getRegistrySnapshot(machine, names)
+	}
+	objs, sampleTime, err := getPerflibV2Snapshot(machine, names)
+	if err == nil {
+		if fallback {
+			log.Infof("Querying the counters of %s with the PerfLib V2 API succeeded again, no longer reading them from the remote registry", machine)
+			remoteRegistryHosts.Delete(machine)
+		}
+		return objs, sampleTime, nil
+	}
+	objs, sampleTime, regErr := getRegistrySnapshot(machine, names)
+	if regErr != nil {
+		return nil, time.Time{}, fmt.Errorf("%v, remote registry fallback failed: %v", err, regErr)
+	}
+	if fallback {
+		log.Debugf("Querying the counters of %s with the PerfLib V2 API still fails: %v", machine, err)
+	} else {
+		log.Warnf("Querying the counters of %s with the PerfLib V2 API failed, reading them from the remote registry, retrying every %s: %v", machine, remoteV2RetryInterval, err)
+	}
+	remoteRegistryHosts.Store(machine, time.Now().Add(remoteV2RetryInterval))
+	return objs, sampleTime, nil
+}
+
+// registryNameCache holds the English object and counter names of remote
+// hosts read from HKEY_PERFORMANCE_DATA, indexed by name index.
+var registryNameCache struct {
+	sync.Mutex
+	machines map[string]map[uint32]string
+}
+
+func lookupRegistryNames(machine string) (map[uint32]string, error) {
+	registryNameCache.Lock()
+	defer registryNameCache.Unlock()
+
+	if registryNameCache.machines == nil {
+		registryNameCache.machines = make(map[string]map[uint32]string)
+	}
+	names, ok := registryNameCache.machines[machine]
+	if !ok {
+		var err error
+		names, err = perflibv2.QueryRegistryNames(machine)
+		if err != nil {
+			return nil, err
+		}
+		registryNameCache.machines[machine] = names
+	}
+	return names, nil
+}
+
+// getRegistrySnapshot queries the named objects from HKEY_PERFORMANCE_DATA and
+// converts them to the objects returned by the V1 backend. Objects that don't
+// exist on the machine are left out.
+func getRegistrySnapshot(machine string, names []string) (map[string]*perflib.PerfObject, time.Time, error) {
+	table, err := lookupRegistryNames(machine)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	// Names aren't unique, e.g. counters sharing the name of an object, so
+	// every index of a name is queried.
+	var indices []string
+	for index, name := range table {
+		if wanted[name] {
+			indices = append(indices, strconv.Itoa(int(index)))
+		}
+	}
+	if len(indices) == 0 {
+		return map[string]*perflib.PerfObject{}, time.Now(), nil
+	}
+
+	snapshot, err := perflibv2.QueryRegistry(machine, strings.Join(indices, " "))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	indexed := make(map[string]*perflib.PerfObject, len(snapshot.Objects))
+	for _, obj := range snapshot.Objects {
+		if name := table[obj.NameIndex]; wanted[name] {
+			indexed[name] = registryObjectToObject(name, obj, table)
+		}
+	}
+	return indexed, snapshot.Time, nil
+}
+
+// registryObjectToObject converts an object read from HKEY_PERFORMANCE_DATA,
+// whose base counters already follow their primaries.
+func registryObjectToObject(name string, obj perflibv2.RegistryObject, table map[uint32]string) *perflib.PerfObject {
+	defs := make([]*perflib.PerfCounterDef, len(obj.Counters))
+	for i, c := range obj.Counters {
+		defs[i] = &perflib.PerfCounterDef{
+			Name:                table[c.NameIndex],
+			NameIndex:           uint(c.NameIndex),
+			CounterType:         c.Type,
+			IsCounter:           c.Type&0x400 == 0x400,
+			IsBaseValue:         isBaseCounterType(c.Type),
+			IsNanosecondCounter: c.Type&0x00100000 == 0x00100000,
+		}
+	}
+
+	result := &perflib.PerfObject{
+		Name:        name,
+		NameIndex:   uint(obj.NameIndex),
+		CounterDefs: defs,
+		Frequency:   obj.Frequency,
+		Instances:   make([]*perflib.PerfInstance, 0, len(obj.Instances)),
+	}
+	for _, inst := range obj.Instances {
+		counters := make([]*perflib.PerfCounter, len(defs))
+		for i, def := range defs {
+			counters[i] = &perflib.PerfCounter{
+				Value: inst.Values[i],
+				Def:   def,
+			}
+		}
+		result.Instances = append(result.Instances, &perflib.PerfInstance{
+			Name:     inst.Name,
+			Counters: counters,
+		})
+	}
+	return result
+}
+
+func counterSetToObject(set perflibv2.CounterSet, data perflibv2.CounterSetData, frequency int64) *perflib.PerfObject {
+	// unmarshalObject expects every base counter to directly follow its primary.
+	byID := make(map[uint32]perflibv2.Counter, len(set.Counters))
+	for _, c := range set.Counters {
+		byID[c.ID] = c
+	}
+	ordered := make([]perflibv2.Counter, 0, len(set.Counters))
+	added := make(map[uint32]bool, len(set.Counters))
+	for _, c := range set.Counters {
+		if added[c.ID] || isBaseCounterType(c.Type) {
+			continue
+		}
+		ordered = append(ordered, c)
+		added[c.ID] = true
+		if base, ok := byID[c.BaseID]; ok && c.BaseID != c.ID && !added[base.ID] && isBaseCounterType(base.Type) {
+			ordered = append(ordered, base)
+			added[base.ID] = true
+		}
+	}
+	for _, c := range set.Counters {
+		if !added[c.ID] {
+			ordered = append(ordered, c)
+		}
+	}
+
+	defs := make([]*perflib.PerfCounterDef, len(ordered))
+	for i, c := range ordered {
+		defs[i] = &perflib.PerfCounterDef{
+			Name:                c.Name,
+			CounterType:         c.Type,
+			IsCounter:           c.Type&0x400 == 0x400,
+			IsBaseValue:         isBaseCounterType(c.Type),
+			IsNanosecondCounter: c.Type&0x00100000 == 0x00100000,
+		}
+	}
+
+	obj := &perflib.PerfObject{
+		Name:        set.Name,
+		CounterDefs: defs,
+		Frequency:   frequency,
+		Instances:   make([]*perflib.PerfInstance, 0, len(data.Instances)),
+	}
+	for _, inst := range data.Instances {
+		counters := make([]*perflib.PerfCounter, len(defs))
+		for i, def := range defs {
+			counters[i] = &perflib.PerfCounter{
+				Value: inst.Values[ordered[i].ID],
+				Def:   def,
+			}
+		}
+		obj.Instances = append(obj.Instances, &perflib.PerfInstance{
+			Name:     inst.Name,
+			Counters: counters,
+		})
+	}
+	return obj
+}
+
+// isBaseCounterType mirrors the PERF_COUNTER_BASE check of the V1 parser.
+func isBaseCounterType(counterType uint32) bool {
+	return counterType&0x00030000 == 0x00030000
+}
+
+//go:generate go run ../tools/counter-help -o counter_help_generated.go Cache Memory "Network Interface" TCPv4 "RemoteFX Network" "SQLServer:Access Methods" "SQLServer:Availability Replica" "SQLServer:Buffer Manager" "SQLServer:Database Replica" "SQLServer:Databases" "SQLServer:General Statistics" "SQLServer:Locks" "SQLServer:Memory Manager" "SQLServer:SQL Errors" "SQLServer:SQL Statistics" "SQLServer:Transactions"
+
+// helpTexts holds the explain texts of the counters of all performance
+// objects registered on this host, indexed by object and counter name. They
+// are read once, by the first collector asking for one, rather than by every
+// collector constructor.
+var helpTexts struct {
+	once    sync.Once
+	objects map[string]map[string]string
+}
+
+func loadHelpTexts() {
+	helpTexts.objects = make(map[string]map[string]string)
+	objs, err := perflib.QueryPerformanceData("Global")
+	if err != nil {
+		log.Debugf("Failed to query explain texts of the performance objects: %v", err)
+		return
+	}
+	for _, obj := range objs {
+		texts := make(map[string]string, len(obj.CounterDefs))
+		for _, def := range obj.CounterDefs {
+			if _, exists := texts[def.Name]; !exists && !def.IsBaseValue {
+				texts[def.Name] = strings.TrimSpace(def.HelpText)
+			}
+		}
+		helpTexts.objects[obj.Name] = texts
+	}
+}
+
+func lookupHelpText(object string, counter string) string {
+	helpTexts.once.Do(loadHelpTexts)
+	if text := helpTexts.objects[object][counter]; text != "" {
+		return text
+	}
+	// Named SQL Server instances prefix their objects with MSSQL$<instance>
+	// rather than SQLServer.
+	if strings.HasPrefix(object, "MSSQL$") {
+		if i := strings.Index(object, ":"); i > 0 {
+			object = "SQLServer" + object[i:]
+		}
+	}
+	return generatedHelpTexts[object][counter]
+}
+
+// counterHelp returns a HELP text for a metric read from the given field of a
+// perflib tagged struct. It consists of the explain text the provider registered
+// for the counter, followed by the counter's path. If the explain text isn't
+// available on this host, the one embedded by go generate is used, and failing
+// that the field name in parentheses is returned, like the collectors' static
+// placeholders.
+func counterHelp(object string, v interface{}, field string) string {
+	f, ok := reflect.TypeOf(v).FieldByName(field)
+	if !ok {
+		return "(" + field + ")"
+	}
+	counter := f.Tag.Get("perflib")
+	if text := lookupHelpText(object, counter); text != "" {
+		return fmt.Sprintf("%s (\\%s\\%s)", text, object, counter)
+	}
+	return "(" + field + ")"
+}
+
+// unmarshalObject sets the fields of the structs in the slice vs pointed to
+// from the counters of each instance of obj named by their perflib tags.
+// Missing counters are logged to logger.
+func unmarshalObject(obj *perflib.PerfObject, vs interface{}, logger log.Logger) error {
 	if obj == nil {
 		return fmt.Errorf("counter not found")
 	}
@@ -53,9 +418,19 @@ func unmarshalObject(obj *perflib.PerfObject, vs interface{}) error {
 		rt := target.Type()
 
 		counters := make(map[string]*perflib.PerfCounter, len(instance.Counters))
-		for _, ctr := range instance.Counters {
+		for i, ctr := range instance.Counters {
 			if ctr.Def.IsBaseValue && !ctr.Def.IsNanosecondCounter {
 				counters[ctr.Def.Name+"_Base"] = ctr
+				// A base counter always immediately follows the counter it is the
+				// denominator of. Its own name does not necessarily match the primary,
+				// so additionally make it available under the primary's name.
+				if i > 0 {
+					if primary := instance.Counters[i-1]; !primary.Def.IsBaseValue {
+						if _, exists := counters[primary.Def.Name+"_Base"]; !exists {
+							counters[primary.Def.Name+"_Base"] = ctr
+						}
+					}
+				}
 			} else {
 				counters[ctr.Def.Name] = ctr
 			}
@@ -70,7 +445,7 @@ func unmarshalObject(obj *perflib.PerfObject, vs interface{}) error {
 
 			ctr, found := counters[tag]
 			if !found {
-				log.Debugf("missing counter %q, have %v", tag, counterMapKeys(counters))
+				logger.Debugf("missing counter %q, have %v", tag, counterMapKeys(counters))
 				continue
 			}
 			if !target.Field(i).CanSet() {
@@ -80,14 +455,7 @@ func unmarshalObject(obj *perflib.PerfObject, vs interface{}) error {
 				return fmt.Errorf("tagged field %v has wrong type %v, must be float64", f.Name, fieldType)
 			}
 
-			switch ctr.Def.CounterType {
-			case perflibCollector.PERF_ELAPSED_TIME:
-				target.Field(i).SetFloat(float64(ctr.Value-windowsEpoch) / float64(obj.Frequency))
-			case perflibCollector.PERF_100NSEC_TIMER, perflibCollector.PERF_PRECISION_100NS_TIMER:
-				target.Field(i).SetFloat(float64(ctr.Value) * ticksToSecondsScaleFactor)
-			default:
-				target.Field(i).SetFloat(float64(ctr.Value))
-			}
+			target.Field(i).SetFloat(convertCounterValue(ctr, obj.Frequency))
 		}
 
 		if instance.Name != "" && target.FieldByName("Name").CanSet() {
@@ -98,6 +466,31 @@ func unmarshalObject(obj *perflib.PerfObject, vs interface{}) error {
 	return nil
 }
 
+// convertCounterValue returns the raw value of a counter, scaled to seconds for
+// the timer counter types. 100ns timers are always measured in 100ns ticks,
+// while the remaining timers are measured in ticks of the object's frequency.
+func convertCounterValue(ctr *perflib.PerfCounter, frequency int64) float64 {
+	switch ctr.Def.CounterType {
+	case perflibCollector.PERF_ELAPSED_TIME:
+		return float64(ctr.Value-windowsEpoch) / float64(frequency)
+	case perflibCollector.PERF_100NSEC_TIMER,
+		perflibCollector.PERF_100NSEC_TIMER_INV,
+		perflibCollector.PERF_100NSEC_MULTI_TIMER,
+		perflibCollector.PERF_100NSEC_MULTI_TIMER_INV,
+		perflibCollector.PERF_PRECISION_100NS_TIMER:
+		return float64(ctr.Value) * ticksToSecondsScaleFactor
+	case perflibCollector.PERF_AVERAGE_TIMER,
+		perflibCollector.PERF_PRECISION_SYSTEM_TIMER,
+		perflibCollector.PERF_PRECISION_OBJECT_TIMER:
+		if frequency <= 0 {
+			return float64(ctr.Value)
+		}
+		return float64(ctr.Value) / float64(frequency)
+	default:
+		return float64(ctr.Value)
+	}
+}
+
 func counterMapKeys(m map[string]*perflib.PerfCounter) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -6,6 +6,8 @@ import (
 
 	perflibCollector "github.com/leoluk/perflib_exporter/collector"
 	"github.com/leoluk/perflib_exporter/perflib"
+	perflibv2 "github.com/prometheus-community/windows_exporter/headers/perflib"
+	"github.com/prometheus-community/windows_exporter/log"
 )
 
 type simple struct {
@@ -13,6 +15,11 @@ type simple struct {
 	ValB float64 `perflib:"Something Else"`
 }
 
+type fraction struct {
+	Latency      float64 `perflib:"Avg. Latency"`
+	Latency_Base float64 `perflib:"Avg. Latency_Base"`
+}
+
 func TestUnmarshalPerflib(t *testing.T) {
 	cases := []struct {
 		name string
@@ -109,7 +116,7 @@ func TestUnmarshalPerflib(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			output := make([]simple, 0)
-			err := unmarshalObject(c.obj, &output)
+			err := unmarshalObject(c.obj, &output, log.Base())
 			if err != nil && !c.expectError {
 				t.Errorf("Did not expect error, got %q", err)
 			}
@@ -123,3 +130,148 @@ func TestUnmarshalPerflib(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalPerflibBaseCounters(t *testing.T) {
+	cases := []struct {
+		name string
+		obj  *perflib.PerfObject
+
+		expectedOutput []fraction
+	}{
+		{
+			name: "base named after primary",
+			obj: &perflib.PerfObject{
+				Frequency: 1000,
+				Instances: []*perflib.PerfInstance{
+					{
+						Counters: []*perflib.PerfCounter{
+							{
+								Def: &perflib.PerfCounterDef{
+									Name:        "Avg. Latency",
+									CounterType: perflibCollector.PERF_AVERAGE_TIMER,
+								},
+								Value: 4000,
+							},
+							{
+								Def: &perflib.PerfCounterDef{
+									Name:        "Avg. Latency",
+									CounterType: perflibCollector.PERF_AVERAGE_BASE,
+									IsBaseValue: true,
+								},
+								Value: 8,
+							},
+						},
+					},
+				},
+			},
+			expectedOutput: []fraction{{Latency: 4, Latency_Base: 8}},
+		},
+		{
+			name: "base with distinct name",
+			obj: &perflib.PerfObject{
+				Frequency: 1000,
+				Instances: []*perflib.PerfInstance{
+					{
+						Counters: []*perflib.PerfCounter{
+							{
+								Def: &perflib.PerfCounterDef{
+									Name:        "Avg. Latency",
+									CounterType: perflibCollector.PERF_AVERAGE_TIMER,
+								},
+								Value: 500,
+							},
+							{
+								Def: &perflib.PerfCounterDef{
+									Name:        "Avg. Latency Base",
+									CounterType: perflibCollector.PERF_AVERAGE_BASE,
+									IsBaseValue: true,
+								},
+								Value: 2,
+							},
+						},
+					},
+				},
+			},
+			expectedOutput: []fraction{{Latency: 0.5, Latency_Base: 2}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			output := make([]fraction, 0)
+			err := unmarshalObject(c.obj, &output, log.Base())
+			if err != nil {
+				t.Errorf("Did not expect error, got %q", err)
+			}
+
+			if err == nil && !reflect.DeepEqual(output, c.expectedOutput) {
+				t.Errorf("Output mismatch, expected %+v, got %+v", c.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestCounterSetToObject(t *testing.T) {
+	set := perflibv2.CounterSet{
+		Name: "Test",
+		Counters: []perflibv2.Counter{
+			{ID: 1, Name: "Avg. Latency Base", Type: perflibCollector.PERF_AVERAGE_BASE},
+			{ID: 2, Name: "Something", Type: perflibCollector.PERF_COUNTER_COUNTER},
+			{ID: 3, Name: "Avg. Latency", Type: perflibCollector.PERF_AVERAGE_TIMER, BaseID: 1},
+		},
+	}
+	data := perflibv2.CounterSetData{
+		Instances: []perflibv2.Instance{
+			{Name: "a", Values: map[uint32]int64{1: 2, 2: 5, 3: 3000}},
+		},
+	}
+
+	obj := counterSetToObject(set, data, 1000)
+
+	var names []string
+	for _, def := range obj.CounterDefs {
+		names = append(names, def.Name)
+	}
+	expectedNames := []string{"Something", "Avg. Latency", "Avg. Latency Base"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Counter order mismatch, expected %v, got %v", expectedNames, names)
+	}
+
+	output := make([]fraction, 0)
+	if err := unmarshalObject(obj, &output, log.Base()); err != nil {
+		t.Fatalf("Did not expect error, got %q", err)
+	}
+	expectedOutput := []fraction{{Latency: 3, Latency_Base: 2}}
+	if !reflect.DeepEqual(output, expectedOutput) {
+		t.Errorf("Output mismatch, expected %+v, got %+v", expectedOutput, output)
+	}
+}
+
+func TestRegistryObjectToObject(t *testing.T) {
+	table := map[uint32]string{
+		10: "Test",
+		12: "Something",
+		14: "Avg. Latency",
+		16: "Avg. Latency Base",
+	}
+	obj := perflibv2.RegistryObject{
+		NameIndex: 10,
+		Frequency: 1000,
+		Counters: []perflibv2.RegistryCounter{
+			{NameIndex: 12, Type: perflibCollector.PERF_COUNTER_COUNTER},
+			{NameIndex: 14, Type: perflibCollector.PERF_AVERAGE_TIMER},
+			{NameIndex: 16, Type: perflibCollector.PERF_AVERAGE_BASE},
+		},
+		Instances: []perflibv2.RegistryInstance{
+			{Name: "a", Values: []int64{5, 3000, 2}},
+		},
+	}
+
+	output := make([]fraction, 0)
+	if err := unmarshalObject(registryObjectToObject("Test", obj, table), &output, log.Base()); err != nil {
+		t.Fatalf("Did not expect error, got %q", err)
+	}
+	expectedOutput := []fraction{{Latency: 3, Latency_Base: 2}}
+	if !reflect.DeepEqual(output, expectedOutput) {
+		t.Errorf("Output mismatch, expected %+v, got %+v", expectedOutput, output)
+	}
+}
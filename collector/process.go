@@ -5,10 +5,12 @@ package collector
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/StackExchange/wmi"
+	"github.com/leoluk/perflib_exporter/perflib"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -18,6 +20,8 @@ func init() {
 	registerCollector("process", newProcessCollector, "Process")
 }
 
+var processLogger = log.With("collector", "process")
+
 var (
 	processWhitelist = kingpin.Flag(
 		"collector.process.whitelist",
@@ -27,6 +31,22 @@ var (
 		"collector.process.blacklist",
 		"Regexp of processes to exclude. Process name must both match whitelist and not match blacklist to be included.",
 	).Default("").String()
+	processAggregate = kingpin.Flag(
+		"collector.process.aggregate",
+		"Regexp of processes whose instances are summed into a single series per process name, or per application pool for IIS worker processes.",
+	).Default("").String()
+	processTopN = kingpin.Flag(
+		"collector.process.top-n",
+		"If greater than 0, only the given number of processes ranking highest by --collector.process.top-n-by are reported, and the others are summed into a series of process \"other\".",
+	).Default("0").Int()
+	processTopNBy = kingpin.Flag(
+		"collector.process.top-n-by",
+		"What processes are ranked by for --collector.process.top-n. cpu ranks by processor time used since the previous scrape.",
+	).Default("cpu").Enum("cpu", "working_set", "private_bytes", "io_bytes", "handles")
+	processCountEvents = kingpin.Flag(
+		"collector.process.count-events",
+		"Count the processes started and exited, from the events of the Microsoft-Windows-Kernel-Process ETW provider, so processes exiting between scrapes are seen.",
+	).Default("false").Bool()
 )
 
 type processCollector struct {
@@ -43,9 +63,29 @@ type processCollector struct {
 	ThreadCount       *prometheus.Desc
 	VirtualBytes      *prometheus.Desc
 	WorkingSet        *prometheus.Desc
+	Instances         *prometheus.Desc
+	StartsTotal       *prometheus.Desc
+	ExitsTotal        *prometheus.Desc
+	FailedExitsTotal  *prometheus.Desc
 
 	processWhitelistPattern *regexp.Regexp
 	processBlacklistPattern *regexp.Regexp
+	// processAggregatePattern is nil unless --collector.process.aggregate
+	// is set.
+	processAggregatePattern *regexp.Regexp
+
+	// lastCPUTime holds the processor time of each series at the previous
+	// scrape, to rank processes by processor time used since.
+	lastCPUTimeMu sync.Mutex
+	lastCPUTime   map[string]float64
+
+	// exited keeps the counters of the instances that left each series of
+	// an aggregated process or of process "other".
+	exited exitedProcesses
+
+	// events counts the processes started and exited, nil unless
+	// --collector.process.count-events is set.
+	events *processEvents
 }
 
 // NewProcessCollector ...
@@ -53,10 +93,10 @@ func newProcessCollector() (Collector, error) {
 	const subsystem = "process"
 
 	if *processWhitelist == ".*" && *processBlacklist == "" {
-		log.Warn("No filters specified for process collector. This will generate a very large number of metrics!")
+		processLogger.Warn("No filters specified for process collector. This will generate a very large number of metrics!")
 	}
 
-	return &processCollector{
+	c := &processCollector{
 		StartTime: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "start_time"),
 			"Time of process start.",
@@ -135,9 +175,44 @@ func newProcessCollector() (Collector, error) {
 			[]string{"process", "process_id", "creating_process_id"},
 			nil,
 		),
+		Instances: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "instances"),
+			"Number of process instances summed into the series of the process, for processes matching --collector.process.aggregate.",
+			[]string{"process"},
+			nil,
+		),
+		StartsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "starts_total"),
+			"Processes of the name started since the exporter started, for --collector.process.count-events.",
+			[]string{"process"},
+			nil,
+		),
+		ExitsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "exits_total"),
+			"Processes of the name exited since the exporter started, for --collector.process.count-events.",
+			[]string{"process"},
+			nil,
+		),
+		FailedExitsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "failed_exits_total"),
+			"Processes of the name exited with a non-zero exit code since the exporter started, for --collector.process.count-events.",
+			[]string{"process"},
+			nil,
+		),
 		processWhitelistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *processWhitelist)),
 		processBlacklistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *processBlacklist)),
-	}, nil
+	}
+	if *processAggregate != "" {
+		c.processAggregatePattern = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *processAggregate))
+	}
+
+	if *processCountEvents {
+		c.events = newProcessEvents(c.processWhitelistPattern, c.processBlacklistPattern)
+		if err := c.events.start(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
 type perflibProcess struct {
@@ -177,19 +252,29 @@ type WorkerProcess struct {
 	ProcessId   uint64
 }
 
+// Close stops counting the processes started and exited.
+func (c *processCollector) Close() error {
+	if c.events == nil {
+		return nil
+	}
+	return c.events.stop()
+}
+
 func (c *processCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	data := make([]perflibProcess, 0)
-	err := unmarshalObject(ctx.perfObjects["Process"], &data)
+	err := unmarshalObject(restrictProcessInstances(ctx.perfObjects["Process"]), &data, ctx.logger())
 	if err != nil {
 		return err
 	}
 
 	var dst_wp []WorkerProcess
 	q_wp := queryAll(&dst_wp)
-	if err := wmi.QueryNamespace(q_wp, &dst_wp, "root\\WebAdministration"); err != nil {
-		log.Debugf("Could not query WebAdministration namespace for IIS worker processes: %v. Skipping", err)
+	if err := queryWMINamespace(q_wp, &dst_wp, "root\\WebAdministration"); err != nil {
+		processLogger.Debugf("Could not query WebAdministration namespace for IIS worker processes: %v. Skipping", err)
 	}
 
+	var processes []processSeries
+	aggregated := make(map[string]int)
 	for _, process := range data {
 		if process.Name == "_Total" ||
 			c.processBlacklistPattern.MatchString(process.Name) ||
@@ -198,8 +283,7 @@ func (c *processCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metr
 		}
 		// Duplicate processes are suffixed # and an index number. Remove those.
 		processName := strings.Split(process.Name, "#")[0]
-		pid := strconv.FormatUint(uint64(process.IDProcess), 10)
-		cpid := strconv.FormatUint(uint64(process.CreatingProcessID), 10)
+		aggregate := c.processAggregatePattern != nil && c.processAggregatePattern.MatchString(processName)
 
 		for _, wp := range dst_wp {
 			if wp.ProcessId == uint64(process.IDProcess) {
@@ -208,6 +292,57 @@ func (c *processCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metr
 			}
 		}
 
+		if !aggregate {
+			processes = append(processes, processSeries{
+				name:    processName,
+				pid:     strconv.FormatUint(uint64(process.IDProcess), 10),
+				cpid:    strconv.FormatUint(uint64(process.CreatingProcessID), 10),
+				process: process,
+			})
+			continue
+		}
+		if i, ok := aggregated[processName]; ok {
+			processes[i].add(process)
+			processes[i].instances++
+			processes[i].members = append(processes[i].members, process)
+			continue
+		}
+		aggregated[processName] = len(processes)
+		processes = append(processes, processSeries{name: processName, instances: 1, process: process, members: []perflibProcess{process}})
+	}
+
+	if topN := flagInt(processTopN); topN > 0 {
+		processes = selectTopProcesses(processes, topN, c.processScores(processes))
+	}
+
+	// The counters of the instances that left a series summing several
+	// are carried forward, so that they don't decrease.
+	groups := make(map[string][]perflibProcess)
+	for _, series := range processes {
+		if series.instances > 0 {
+			groups[series.key()] = series.members
+		}
+	}
+	exited := c.exited.update(groups, data)
+	for i, series := range processes {
+		if series.instances > 0 {
+			addProcessCounters(&processes[i].process, exited[series.key()])
+		}
+	}
+
+	for _, series := range processes {
+		process := series.process
+		processName, pid, cpid := series.name, series.pid, series.cpid
+
+		if series.instances > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.Instances,
+				prometheus.GaugeValue,
+				float64(series.instances),
+				processName,
+			)
+		}
+
 		ch <- prometheus.MustNewConstMetric(
 			c.StartTime,
 			prometheus.GaugeValue,
@@ -390,5 +525,246 @@ func (c *processCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metr
 		)
 	}
 
+	if c.events != nil {
+		c.events.collect(ch, c.StartsTotal, c.ExitsTotal, c.FailedExitsTotal)
+	}
 	return nil
 }
+
+// processSeries holds the counters of a process, or the sum of those of the
+// instances of an aggregated process, which has no process IDs.
+type processSeries struct {
+	name, pid, cpid string
+	// instances is the number of instances summed, 0 if not aggregated.
+	instances int
+	process   perflibProcess
+	// members holds the instances summed, if aggregated.
+	members []perflibProcess
+}
+
+// instanceCount returns the number of instances of the series, 1 if it isn't
+// aggregated.
+func (s processSeries) instanceCount() int {
+	if s.instances == 0 {
+		return 1
+	}
+	return s.instances
+}
+
+// instanceProcesses returns the instances of the series.
+func (s processSeries) instanceProcesses() []perflibProcess {
+	if s.instances == 0 {
+		return []perflibProcess{s.process}
+	}
+	return s.members
+}
+
+// key identifies the series across scrapes.
+func (s processSeries) key() string {
+	return s.name + "/" + s.pid
+}
+
+// add adds the counters of another instance to those of the aggregated
+// process, without counting the instance. Its start time is that of the
+// oldest instance, and its base priority the highest one.
+func (s *processSeries) add(p perflibProcess) {
+	a := &s.process
+	if p.ElapsedTime < a.ElapsedTime {
+		a.ElapsedTime = p.ElapsedTime
+	}
+	if p.PriorityBase > a.PriorityBase {
+		a.PriorityBase = p.PriorityBase
+	}
+	a.HandleCount += p.HandleCount
+	a.PageFileBytes += p.PageFileBytes
+	a.PoolNonpagedBytes += p.PoolNonpagedBytes
+	a.PoolPagedBytes += p.PoolPagedBytes
+	a.PrivateBytes += p.PrivateBytes
+	a.ThreadCount += p.ThreadCount
+	a.VirtualBytes += p.VirtualBytes
+	a.WorkingSet += p.WorkingSet
+	addProcessCounters(a, p)
+}
+
+// addProcessCounters adds the counters of p that only increase while a
+// process runs, e.g. its processor time, to those of a.
+func addProcessCounters(a *perflibProcess, p perflibProcess) {
+	a.PercentPrivilegedTime += p.PercentPrivilegedTime
+	a.PercentUserTime += p.PercentUserTime
+	a.IOOtherBytesPerSec += p.IOOtherBytesPerSec
+	a.IOOtherOperationsPerSec += p.IOOtherOperationsPerSec
+	a.IOReadBytesPerSec += p.IOReadBytesPerSec
+	a.IOReadOperationsPerSec += p.IOReadOperationsPerSec
+	a.IOWriteBytesPerSec += p.IOWriteBytesPerSec
+	a.IOWriteOperationsPerSec += p.IOWriteOperationsPerSec
+	a.PageFaultsPerSec += p.PageFaultsPerSec
+}
+
+// processID identifies a process across scrapes, as process IDs are reused.
+type processID struct {
+	pid   uint32
+	start float64
+}
+
+// exitedProcesses carries the counters of exited processes forward, so that
+// counters summed over the running processes of a group, e.g. those of a
+// session, keep increasing when a process of the group exits rather than
+// dropping, which rate() would take for a counter reset.
+type exitedProcesses struct {
+	mu sync.Mutex
+	// running holds the processes of each group seen by the last update.
+	running map[string]map[processID]perflibProcess
+	// departed holds the processes that left each group while still
+	// running, e.g. to enter the top processes, as last seen in the group.
+	departed map[string]map[processID]perflibProcess
+	// exited holds the summed counters of the exited processes of each
+	// group.
+	exited map[string]perflibProcess
+}
+
+// update records the running processes of each group, and returns the
+// summed counters of the processes of each group that left it since the
+// group was first seen, as last seen in the group. A process that returns
+// to a group is counted as running again, and the counters of those that
+// left a group are kept until they are no longer among all. Groups not
+// passed are forgotten.
+func (e *exitedProcesses) update(groups map[string][]perflibProcess, all []perflibProcess) map[string]perflibProcess {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alive := make(map[processID]bool, len(all))
+	for _, p := range all {
+		alive[processID{pid: uint32(p.IDProcess), start: p.ElapsedTime}] = true
+	}
+
+	running := make(map[string]map[processID]perflibProcess, len(groups))
+	departed := make(map[string]map[processID]perflibProcess, len(groups))
+	exited := make(map[string]perflibProcess, len(groups))
+	result := make(map[string]perflibProcess, len(groups))
+	for group, processes := range groups {
+		current := make(map[processID]perflibProcess, len(processes))
+		for _, p := range processes {
+			current[processID{pid: uint32(p.IDProcess), start: p.ElapsedTime}] = p
+		}
+		left := make(map[processID]perflibProcess)
+		for id, p := range e.departed[group] {
+			left[id] = p
+		}
+		for id, p := range e.running[group] {
+			if _, ok := current[id]; !ok {
+				left[id] = p
+			}
+		}
+		sum := e.exited[group]
+		for id, p := range left {
+			if _, ok := current[id]; ok {
+				delete(left, id)
+			} else if !alive[id] {
+				addProcessCounters(&sum, p)
+				delete(left, id)
+			}
+		}
+		total := sum
+		for _, p := range left {
+			addProcessCounters(&total, p)
+		}
+		running[group], departed[group], exited[group] = current, left, sum
+		result[group] = total
+	}
+	e.running, e.departed, e.exited = running, departed, exited
+	return result
+}
+
+// processScores returns the scores of the series to rank them by for
+// --collector.process.top-n.
+func (c *processCollector) processScores(processes []processSeries) []float64 {
+	scores := make([]float64, len(processes))
+	switch flagString(processTopNBy) {
+	case "cpu":
+		c.lastCPUTimeMu.Lock()
+		defer c.lastCPUTimeMu.Unlock()
+		cpuTimes := make(map[string]float64, len(processes))
+		for i, s := range processes {
+			cpuTime := s.process.PercentPrivilegedTime + s.process.PercentUserTime
+			cpuTimes[s.key()] = cpuTime
+			scores[i] = cpuTime
+			// Processes first seen are ranked by their total processor time.
+			if last, ok := c.lastCPUTime[s.key()]; ok && last <= cpuTime {
+				scores[i] = cpuTime - last
+			}
+		}
+		c.lastCPUTime = cpuTimes
+	case "working_set":
+		for i, s := range processes {
+			scores[i] = s.process.WorkingSet
+		}
+	case "private_bytes":
+		for i, s := range processes {
+			scores[i] = s.process.PrivateBytes
+		}
+	case "io_bytes":
+		for i, s := range processes {
+			scores[i] = s.process.IOReadBytesPerSec + s.process.IOWriteBytesPerSec + s.process.IOOtherBytesPerSec
+		}
+	case "handles":
+		for i, s := range processes {
+			scores[i] = s.process.HandleCount
+		}
+	}
+	return scores
+}
+
+// selectTopProcesses returns the n series with the highest scores, followed by
+// a series of process "other" summing the rest, if there are more than n.
+// Series with the same score are ranked by name.
+func selectTopProcesses(processes []processSeries, n int, scores []float64) []processSeries {
+	if len(processes) <= n {
+		return processes
+	}
+	ranked := make([]int, len(processes))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return processes[a].name < processes[b].name
+	})
+
+	top := make([]processSeries, 0, n+1)
+	for _, i := range ranked[:n] {
+		top = append(top, processes[i])
+	}
+	other := processSeries{name: "other"}
+	for j, i := range ranked[n:] {
+		if j == 0 {
+			other.process = processes[i].process
+		} else {
+			other.add(processes[i].process)
+		}
+		other.instances += processes[i].instanceCount()
+		other.members = append(other.members, processes[i].instanceProcesses()...)
+	}
+	return append(top, other)
+}
+
+// restrictProcessInstances returns the Process object with only the instances
+// of the processes allowed by the label_values section, if it restricts them,
+// so the others aren't unmarshalled at all.
+func restrictProcessInstances(obj *perflib.PerfObject) *perflib.PerfObject {
+	allowed := labelValueSet("process")
+	if allowed == nil || obj == nil {
+		return obj
+	}
+	restricted := *obj
+	restricted.Instances = make([]*perflib.PerfInstance, 0, len(allowed))
+	for _, inst := range obj.Instances {
+		// Duplicate processes are suffixed # and an index number.
+		if allowed[strings.ToLower(strings.Split(inst.Name, "#")[0])] {
+			restricted.Instances = append(restricted.Instances, inst)
+		}
+	}
+	return &restricted
+}
@@ -0,0 +1,198 @@
+// +build windows
+
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	processEventsSessionName = "windows_exporter_process"
+
+	// WINEVENT_KEYWORD_PROCESS of Microsoft-Windows-Kernel-Process
+	processEventsKeyword = 0x10
+
+	processStartEventID = 1
+	processStopEventID  = 2
+
+	// processEventsMaxSeries bounds the process names counted, further ones
+	// being counted as "other".
+	processEventsMaxSeries = 1000
+	// processEventsMaxRunning bounds the names of running processes kept to
+	// name their exit, as the exit event only has the first 14 characters of
+	// the image name.
+	processEventsMaxRunning = 100000
+)
+
+// Microsoft-Windows-Kernel-Process
+var kernelProcessProvider = windows.GUID{
+	Data1: 0x22fb2cd6,
+	Data2: 0x0e7b,
+	Data3: 0x422b,
+	Data4: [8]byte{0xa0, 0xc7, 0x2f, 0xad, 0x1f, 0xd0, 0xe7, 0x16},
+}
+
+type processEventCounts struct {
+	starts      float64
+	exits       float64
+	failedExits float64
+}
+
+// processEvents counts the processes started and exited since the exporter
+// started, by process name.
+type processEvents struct {
+	whitelist *regexp.Regexp
+	blacklist *regexp.Regexp
+	session   *etw.Session
+
+	mu      sync.Mutex
+	running map[uint32]string
+	counts  map[string]*processEventCounts
+}
+
+func newProcessEvents(whitelist, blacklist *regexp.Regexp) *processEvents {
+	return &processEvents{
+		whitelist: whitelist,
+		blacklist: blacklist,
+		running:   make(map[uint32]string),
+		counts:    make(map[string]*processEventCounts),
+	}
+}
+
+// start starts counting the processes started and exited from now on.
+func (p *processEvents) start() error {
+	providers := []etw.Provider{{GUID: kernelProcessProvider, Level: 4, MatchAnyKeyword: processEventsKeyword}}
+	session, err := etw.StartSession(processEventsSessionName, providers, p.handleEvent)
+	if err != nil {
+		return err
+	}
+	p.session = session
+	return nil
+}
+
+// stop stops counting the processes started and exited.
+func (p *processEvents) stop() error {
+	return p.session.Close()
+}
+
+// processImageName returns the name of a process as in the Process object,
+// the base name of its image without extension, e.g. cmd for
+// \Device\HarddiskVolume2\Windows\System32\cmd.exe.
+func processImageName(image string) string {
+	if i := strings.LastIndexAny(image, `\/`); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.LastIndex(image, "."); i > 0 {
+		image = image[:i]
+	}
+	return image
+}
+
+func (p *processEvents) handleEvent(e *etw.Event) {
+	if e.ID != processStartEventID && e.ID != processStopEventID {
+		return
+	}
+	props, err := e.Properties()
+	if err != nil {
+		processLogger.Debugf("Failed to decode process event %d: %v", e.ID, err)
+		return
+	}
+	pid, err := strconv.ParseUint(props["ProcessID"], 0, 32)
+	if err != nil {
+		processLogger.Debugf("Ignoring process event %d with invalid ProcessID %q", e.ID, props["ProcessID"])
+		return
+	}
+
+	if e.ID == processStartEventID {
+		p.started(uint32(pid), processImageName(props["ImageName"]))
+		return
+	}
+	exitCode, ok := parseETWValue(props["ExitCode"])
+	p.exited(uint32(pid), processImageName(props["ImageName"]), ok && exitCode == 0)
+}
+
+// included returns whether the process name is selected by the whitelist,
+// blacklist and allowed label values of the process collector.
+func (p *processEvents) included(name string) bool {
+	if name == "" || p.blacklist.MatchString(name) || !p.whitelist.MatchString(name) {
+		return false
+	}
+	if allowed := labelValueSet("process"); allowed != nil {
+		return allowed[strings.ToLower(name)]
+	}
+	return true
+}
+
+// lookup returns the counts of the process name, counting further names as
+// "other" once there are processEventsMaxSeries.
+func (p *processEvents) lookup(name string) *processEventCounts {
+	if c, ok := p.counts[name]; ok {
+		return c
+	}
+	if len(p.counts) >= processEventsMaxSeries {
+		name = etwOtherLabel
+		if c, ok := p.counts[name]; ok {
+			return c
+		}
+	}
+	c := &processEventCounts{}
+	p.counts[name] = c
+	return c
+}
+
+func (p *processEvents) started(pid uint32, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.running) < processEventsMaxRunning {
+		p.running[pid] = name
+	}
+	if p.included(name) {
+		p.lookup(name).starts++
+	}
+}
+
+// exited counts the exit of the process, named as when it started if it
+// started since the exporter did, or else after the image name of its exit
+// event.
+func (p *processEvents) exited(pid uint32, image string, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name, ok := p.running[pid]
+	if ok {
+		delete(p.running, pid)
+	} else {
+		name = image
+	}
+	if !p.included(name) {
+		return
+	}
+	c := p.lookup(name)
+	c.exits++
+	if !success {
+		c.failedExits++
+	}
+}
+
+func (p *processEvents) collect(ch chan<- prometheus.Metric, starts, exits, failedExits *prometheus.Desc) {
+	p.mu.Lock()
+	metrics := make([]prometheus.Metric, 0, 3*len(p.counts))
+	for name, c := range p.counts {
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(starts, prometheus.CounterValue, c.starts, name),
+			prometheus.MustNewConstMetric(exits, prometheus.CounterValue, c.exits, name),
+			prometheus.MustNewConstMetric(failedExits, prometheus.CounterValue, c.failedExits, name),
+		)
+	}
+	p.mu.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+}
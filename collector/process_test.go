@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -12,3 +14,142 @@ func BenchmarkProcessCollector(b *testing.B) {
 	// No context name required as collector source is WMI
 	benchmarkCollector(b, "", newProcessCollector)
 }
+
+func TestProcessSeriesAdd(t *testing.T) {
+	s := processSeries{name: "w3wp_DefaultAppPool", instances: 1, process: perflibProcess{
+		ElapsedTime:       200,
+		PriorityBase:      8,
+		HandleCount:       100,
+		IOReadBytesPerSec: 1000,
+		ThreadCount:       10,
+		WorkingSet:        1 << 20,
+	}}
+	s.add(perflibProcess{
+		ElapsedTime:       100,
+		PriorityBase:      4,
+		HandleCount:       50,
+		IOReadBytesPerSec: 500,
+		ThreadCount:       5,
+		WorkingSet:        1 << 20,
+	})
+
+	expected := perflibProcess{
+		ElapsedTime:       100,
+		PriorityBase:      8,
+		HandleCount:       150,
+		IOReadBytesPerSec: 1500,
+		ThreadCount:       15,
+		WorkingSet:        2 << 20,
+	}
+	if s.process != expected {
+		t.Errorf("Aggregated process does not match!\nExpected result: %+v\nActual result: %+v", expected, s.process)
+	}
+}
+
+func TestSelectTopProcesses(t *testing.T) {
+	processes := []processSeries{
+		{name: "svchost", pid: "100", process: perflibProcess{ThreadCount: 10}},
+		{name: "sqlservr", pid: "200", process: perflibProcess{ThreadCount: 20}},
+		{name: "w3wp_DefaultAppPool", instances: 3, process: perflibProcess{ThreadCount: 30}},
+		{name: "lsass", pid: "300", process: perflibProcess{ThreadCount: 40}},
+	}
+	scores := []float64{1, 50, 20, 1}
+
+	top := selectTopProcesses(processes, 2, scores)
+	expected := []processSeries{
+		processes[1],
+		processes[2],
+		{name: "other", instances: 2, process: perflibProcess{ThreadCount: 50}, members: []perflibProcess{{ThreadCount: 40}, {ThreadCount: 10}}},
+	}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("Top processes do not match!\nExpected result: %+v\nActual result: %+v", expected, top)
+	}
+
+	if top := selectTopProcesses(processes, 4, scores); len(top) != 4 {
+		t.Errorf("Expected all 4 processes without an other series, got %d", len(top))
+	}
+}
+
+func TestAggregatedProcessMonotonic(t *testing.T) {
+	var exited exitedProcesses
+	// cpuTime returns the processor time of the series of group after
+	// carrying the counters of the processes that left it forward.
+	cpuTime := func(groups map[string][]perflibProcess, group string) float64 {
+		var all []perflibProcess
+		for _, processes := range groups {
+			all = append(all, processes...)
+		}
+		sum := exited.update(groups, all)[group]
+		for _, p := range groups[group] {
+			addProcessCounters(&sum, p)
+		}
+		return sum.PercentUserTime
+	}
+
+	a := perflibProcess{IDProcess: 100, ElapsedTime: 10, PercentUserTime: 2}
+	b := perflibProcess{IDProcess: 200, ElapsedTime: 20, PercentUserTime: 3}
+	if v := cpuTime(map[string][]perflibProcess{"other/": {a, b}}, "other/"); v != 5 {
+		t.Errorf("Processor time does not match!\nExpected result: %v\nActual result: %v", 5, v)
+	}
+
+	// b enters the top processes, its counters stay in other.
+	b.PercentUserTime = 6
+	if v := cpuTime(map[string][]perflibProcess{"other/": {a}, "b/200": {b}}, "other/"); v != 5 {
+		t.Errorf("Processor time does not match!\nExpected result: %v\nActual result: %v", 5, v)
+	}
+
+	// b returns to other, and is counted once.
+	b.PercentUserTime = 7
+	if v := cpuTime(map[string][]perflibProcess{"other/": {a, b}}, "other/"); v != 9 {
+		t.Errorf("Processor time does not match!\nExpected result: %v\nActual result: %v", 9, v)
+	}
+
+	// b exits.
+	if v := cpuTime(map[string][]perflibProcess{"other/": {a}}, "other/"); v != 9 {
+		t.Errorf("Processor time does not match!\nExpected result: %v\nActual result: %v", 9, v)
+	}
+}
+
+func TestProcessImageName(t *testing.T) {
+	for image, expected := range map[string]string{
+		`\Device\HarddiskVolume2\Windows\System32\cmd.exe`: "cmd",
+		"w3wp.exe":          "w3wp",
+		"Microsoft.Photos.": "Microsoft.Photos",
+		"System":            "System",
+	} {
+		if got := processImageName(image); got != expected {
+			t.Errorf("Expected process name %s for image %s, got %s", expected, image, got)
+		}
+	}
+}
+
+func TestProcessEvents(t *testing.T) {
+	events := newProcessEvents(regexp.MustCompile("^(?:.*)$"), regexp.MustCompile("^(?:svchost)$"))
+	events.started(100, "cmd")
+	events.started(101, "cmd")
+	events.started(102, "svchost")
+	events.started(103, "LongProcessNameHere")
+	events.exited(100, "cmd", true)
+	events.exited(101, "cmd", false)
+	events.exited(102, "svchost", true)
+	// The exit event only has the first 14 characters of the image name.
+	events.exited(103, "LongProcessNam", false)
+	// Processes started before the exporter are named after the exit event.
+	events.exited(200, "notepad", true)
+
+	expected := map[string]processEventCounts{
+		"cmd":                 {starts: 2, exits: 2, failedExits: 1},
+		"LongProcessNameHere": {starts: 1, exits: 1, failedExits: 1},
+		"notepad":             {exits: 1},
+	}
+	actual := make(map[string]processEventCounts)
+	for name, c := range events.counts {
+		actual[name] = *c
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("process event counts do not match!\nExpected result: %+v\nActual result: %+v", expected, actual)
+	}
+	if len(events.running) != 0 {
+		t.Errorf("Expected no running process left, got %v", events.running)
+	}
+}
@@ -13,6 +13,8 @@ func init() {
 	registerCollector("remote_fx", NewRemoteFx, "RemoteFX Network", "RemoteFX Graphics")
 }
 
+var remoteFxLogger = log.With("collector", "remote_fx")
+
 // A RemoteFxNetworkCollector is a Prometheus collector for
 // WMI Win32_PerfRawData_Counters_RemoteFXNetwork & Win32_PerfRawData_Counters_RemoteFXGraphics metrics
 // https://wutils.com/wmi/root/cimv2/win32_perfrawdata_counters_remotefxnetwork/
@@ -84,13 +86,13 @@ func NewRemoteFx() (Collector, error) {
 		),
 		TotalReceivedBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "net_received_bytes_total"),
-			"(TotalReceivedBytes)",
+			counterHelp("RemoteFX Network", perflibRemoteFxNetwork{}, "TotalReceivedBytes"),
 			[]string{"session_name"},
 			nil,
 		),
 		TotalSentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "net_sent_bytes_total"),
-			"(TotalSentBytes)",
+			counterHelp("RemoteFX Network", perflibRemoteFxNetwork{}, "TotalSentBytes"),
 			[]string{"session_name"},
 			nil,
 		),
@@ -157,11 +159,11 @@ func NewRemoteFx() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *RemoteFxCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collectRemoteFXNetworkCount(ctx, ch); err != nil {
-		log.Error("failed collecting terminal services session count metrics:", desc, err)
+		remoteFxLogger.Error("failed collecting terminal services session count metrics:", desc, err)
 		return err
 	}
 	if desc, err := c.collectRemoteFXGraphicsCounters(ctx, ch); err != nil {
-		log.Error("failed collecting terminal services session count metrics:", desc, err)
+		remoteFxLogger.Error("failed collecting terminal services session count metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -183,7 +185,7 @@ type perflibRemoteFxNetwork struct {
 
 func (c *RemoteFxCollector) collectRemoteFXNetworkCount(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	dst := make([]perflibRemoteFxNetwork, 0)
-	err := unmarshalObject(ctx.perfObjects["RemoteFX Network"], &dst)
+	err := unmarshalObject(ctx.perfObjects["RemoteFX Network"], &dst, ctx.logger())
 	if err != nil {
 		return nil, err
 	}
@@ -273,7 +275,7 @@ type perflibRemoteFxGraphics struct {
 
 func (c *RemoteFxCollector) collectRemoteFXGraphicsCounters(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	dst := make([]perflibRemoteFxGraphics, 0)
-	err := unmarshalObject(ctx.perfObjects["RemoteFX Graphics"], &dst)
+	err := unmarshalObject(ctx.perfObjects["RemoteFX Graphics"], &dst, ctx.logger())
 	if err != nil {
 		return nil, err
 	}
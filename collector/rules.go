@@ -0,0 +1,280 @@
+package collector
+
+import (
+	"sort"
+)
+
+// Rule is a Prometheus recording rule or alert built on the metrics of a
+// collector.
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// requires lists the other collectors the rule needs metrics of.
+	requires []string
+}
+
+// RuleGroup is a group of a Prometheus rule file.
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// collectorRules holds the curated rules of the collectors. Alerts build on
+// the recording rules of their group where there is one, so thresholds are
+// expressed as ratios.
+var collectorRules = map[string][]Rule{
+	"ad": {
+		{
+			Alert:       "ADDatabaseLogVolumeLow",
+			Expr:        "windows_ad_database_log_volume_free_bytes / windows_ad_database_log_volume_size_bytes < 0.1",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "The volume holding the AD database logs on {{ $labels.instance }} has less than 10% free space"},
+		},
+	},
+	"adcs": {
+		{
+			Alert:       "CRLExpiringSoon",
+			Expr:        "windows_adcs_crl_next_update_timestamp_seconds - time() < 24 * 3600",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "CRL {{ $labels.crl }} on {{ $labels.instance }} expires in {{ $value | humanizeDuration }} and hasn't been republished"},
+		},
+	},
+	"ad_forest": {
+		{
+			Alert:       "ADReplicationStale",
+			Expr:        "time() - windows_ad_forest_replication_last_success_timestamp_seconds > 3 * 3600",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "{{ $labels.dc }} hasn't replicated {{ $labels.naming_context }} from {{ $labels.source_dc }} for 3 hours"},
+		},
+		{
+			Alert:       "ADDomainControllerDown",
+			Expr:        "windows_ad_forest_dc_up == 0 or windows_ad_forest_dc_share_present == 0",
+			For:         "10m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Domain controller {{ $labels.dc }} is unhealthy"},
+		},
+	},
+	"cloud": {
+		{
+			Alert:       "AzureMaintenanceScheduled",
+			Expr:        `windows_cloud_scheduled_event_not_before_timestamp_seconds{source="platform"} - time() < 15 * 60`,
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Azure {{ $labels.type }} of {{ $labels.instance }} may start in {{ $value | humanizeDuration }}"},
+		},
+	},
+	"cpu": {
+		{
+			Record: "instance:windows_cpu_utilisation:ratio",
+			Expr:   `1 - avg by (instance) (rate(windows_cpu_time_total{mode="idle"}[5m]))`,
+		},
+		{
+			Alert:       "CPUUsageHigh",
+			Expr:        "instance:windows_cpu_utilisation:ratio > 0.9",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "CPU usage on {{ $labels.instance }} is {{ $value | humanizePercentage }}"},
+		},
+	},
+	"defender": {
+		{
+			Alert:       "DefenderSignaturesOutdated",
+			Expr:        `time() - windows_defender_signature_last_updated_timestamp_seconds{signature="antivirus"} > 3 * 24 * 3600`,
+			For:         "1h",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "The antivirus signatures of {{ $labels.instance }} were last updated {{ $value | humanizeDuration }} ago"},
+		},
+		{
+			Alert:       "DefenderRealTimeProtectionDisabled",
+			Expr:        `windows_defender_protection_enabled{protection="real_time"} == 0`,
+			For:         "15m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Real-time protection of Defender Antivirus is disabled on {{ $labels.instance }}"},
+		},
+	},
+	"hyperv": {
+		{
+			Alert:       "HyperVCheckpointForgotten",
+			Expr:        "time() - windows_hyperv_vm_oldest_checkpoint_timestamp_seconds > 3 * 24 * 3600",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "VM {{ $labels.vm }} on {{ $labels.instance }} has had a checkpoint for {{ $value | humanizeDuration }}"},
+		},
+	},
+	"logical_disk": {
+		{
+			Record: "instance_volume:windows_logical_disk_used:ratio",
+			Expr:   "1 - windows_logical_disk_free_bytes / windows_logical_disk_size_bytes",
+		},
+		{
+			Alert:       "DiskSpaceLow",
+			Expr:        "instance_volume:windows_logical_disk_used:ratio > 0.95",
+			For:         "10m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Volume {{ $labels.volume }} on {{ $labels.instance }} is {{ $value | humanizePercentage }} full"},
+		},
+		{
+			Alert:       "DiskFilling",
+			Expr:        "instance_volume:windows_logical_disk_used:ratio > 0.85 and predict_linear(windows_logical_disk_free_bytes[6h], 4 * 24 * 3600) < 0",
+			For:         "1h",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Volume {{ $labels.volume }} on {{ $labels.instance }} is expected to fill up within four days"},
+		},
+	},
+	"memory": {
+		{
+			Record:   "instance:windows_memory_utilisation:ratio",
+			Expr:     "1 - windows_memory_available_bytes / on (instance) windows_cs_physical_memory_bytes",
+			requires: []string{"cs"},
+		},
+		{
+			Alert:       "MemoryUsageHigh",
+			Expr:        "instance:windows_memory_utilisation:ratio > 0.9",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Memory usage on {{ $labels.instance }} is {{ $value | humanizePercentage }}"},
+			requires:    []string{"cs"},
+		},
+	},
+	"mscluster": {
+		{
+			Alert:       "ClusterNodeDown",
+			Expr:        `windows_mscluster_node_state{state="down"} == 1`,
+			For:         "5m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Cluster node {{ $labels.node }} seen from {{ $labels.instance }} is down"},
+		},
+		{
+			Alert:       "ClusterGroupFailed",
+			Expr:        `windows_mscluster_group_state{state="failed"} == 1`,
+			For:         "1m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Cluster role {{ $labels.group }} seen from {{ $labels.instance }} has failed"},
+		},
+		{
+			Alert:       "ClusterNetworkNotUp",
+			Expr:        `windows_mscluster_network_state{state="up"} == 0`,
+			For:         "1m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Cluster network {{ $labels.network }} seen from {{ $labels.instance }} is not up"},
+		},
+		{
+			Alert:       "ClusterWitnessOffline",
+			Expr:        `windows_mscluster_witness_state{state="online"} == 0`,
+			For:         "5m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Quorum witness {{ $labels.name }} is not online, the cluster tolerates one fewer node failure"},
+		},
+	},
+	"scheduled_task": {
+		{
+			Alert:       "ScheduledTaskFailed",
+			Expr:        "windows_scheduled_task_last_result != 0 and windows_scheduled_task_last_result != 267009 and windows_scheduled_task_last_result != 267011",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Scheduled task {{ $labels.task }} on {{ $labels.instance }} failed with result {{ $value }}"},
+		},
+	},
+	"service": {
+		{
+			Alert:       "ServiceDown",
+			Expr:        `windows_service_state{state="running"} == 0 and on (instance, name) windows_service_start_mode{start_mode="auto"} == 1`,
+			For:         "5m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Automatically started service {{ $labels.name }} on {{ $labels.instance }} isn't running"},
+		},
+	},
+	"storage_spaces": {
+		{
+			Alert:       "StorageSpacesVirtualDiskUnhealthy",
+			Expr:        `windows_storage_spaces_virtual_disk_health{health="healthy"} == 0`,
+			For:         "10m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Virtual disk {{ $labels.virtual_disk }} on {{ $labels.instance }} isn't healthy, it may be degraded"},
+		},
+	},
+	"time": {
+		{
+			Alert:       "NTPClientDelay",
+			Expr:        "windows_time_ntp_round_trip_delay_seconds > 1",
+			For:         "5m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "The NTP round trip delay of {{ $labels.instance }} is {{ $value | humanizeDuration }}"},
+		},
+	},
+	"update": {
+		{
+			Alert:       "CriticalUpdatesPending",
+			Expr:        `windows_update_pending{severity="critical"} > 0`,
+			For:         "7d",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "{{ $value }} critical updates are pending on {{ $labels.instance }} for a week"},
+		},
+		{
+			Alert:       "RebootRequired",
+			Expr:        "windows_update_reboot_required == 1",
+			For:         "3d",
+			Labels:      map[string]string{"severity": "info"},
+			Annotations: map[string]string{"summary": "{{ $labels.instance }} needs a reboot to complete the installation of updates"},
+		},
+	},
+	"vmware": {
+		{
+			Alert:       "VMwareCPUReadyHigh",
+			Expr:        "rate(windows_vmware_cpu_stolen_seconds_total[5m]) / on (instance) windows_cs_logical_processors > 0.1",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "The processors of {{ $labels.instance }} wait for the host {{ $value | humanizePercentage }} of the time"},
+			requires:    []string{"cs"},
+		},
+		{
+			Alert:       "VMwareMemoryReclaimed",
+			Expr:        "windows_vmware_mem_ballooned_bytes + windows_vmware_mem_swapped_bytes > 0",
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "The host reclaimed {{ $value | humanize1024 }}B of memory from {{ $labels.instance }}"},
+		},
+	},
+	"wef": {
+		{
+			Alert:       "WEFSourcesInactive",
+			Expr:        `windows_wef_sources{status="inactive"} / ignoring (status) sum without (status) (windows_wef_sources) > 0.1`,
+			For:         "1h",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "{{ $value | humanizePercentage }} of the sources of subscription {{ $labels.subscription }} on {{ $labels.instance }} are inactive"},
+		},
+	},
+}
+
+// RuleGroups returns a group with the rules of each of the given collectors
+// that has any, leaving out rules that need metrics of collectors not given.
+// The groups are sorted by name.
+func RuleGroups(collectors []string) []RuleGroup {
+	enabled := make(map[string]bool, len(collectors))
+	for _, name := range collectors {
+		enabled[name] = true
+	}
+
+	var groups []RuleGroup
+	for _, name := range collectors {
+		var rules []Rule
+	rules:
+		for _, rule := range collectorRules[name] {
+			for _, required := range rule.requires {
+				if !enabled[required] {
+					continue rules
+				}
+			}
+			rules = append(rules, rule)
+		}
+		if len(rules) > 0 {
+			groups = append(groups, RuleGroup{Name: Namespace + "_" + name, Rules: rules})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var ruleMetricPattern = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*`)
+
+// TestRuleMetrics checks that the rules only use metrics documented for their
+// collector or the collectors they require. Some docs leave out the prefix of
+// the metric names.
+func TestRuleMetrics(t *testing.T) {
+	for name, rules := range collectorRules {
+		for _, rule := range rules {
+			documented := func(metric string) bool {
+				for _, c := range append([]string{name}, rule.requires...) {
+					b, err := ioutil.ReadFile("../docs/collector." + c + ".md")
+					if err != nil {
+						t.Fatal(err)
+					}
+					short := strings.TrimPrefix(metric, Namespace+"_"+c+"_")
+					if strings.Contains(string(b), "`"+metric+"`") || strings.Contains(string(b), "`"+short+"`") {
+						return true
+					}
+				}
+				return false
+			}
+			for _, token := range ruleMetricPattern.FindAllString(rule.Expr, -1) {
+				if !strings.HasPrefix(token, Namespace+"_") || strings.Contains(token, ":") {
+					continue
+				}
+				if !documented(token) {
+					t.Errorf("Metric %s of rule %s%s of collector %s is not documented", token, rule.Alert, rule.Record, name)
+				}
+			}
+		}
+	}
+}
+
+func TestRuleGroups(t *testing.T) {
+	groups := RuleGroups([]string{"memory", "cpu", "textfile"})
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	if strings.Join(names, ",") != "windows_cpu" {
+		t.Errorf("Rule groups do not match!\nExpected result: windows_cpu\nActual result: %v", names)
+	}
+
+	groups = RuleGroups([]string{"memory", "cs"})
+	if len(groups) != 1 || len(groups[0].Rules) != 2 {
+		t.Errorf("Rules of memory with cs do not match!\nExpected result: 2 rules\nActual result: %+v", groups)
+	}
+}
@@ -0,0 +1,211 @@
+// +build windows
+
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("s2d", newS2DCollector, "Cluster Storage Cache Stores")
+}
+
+var s2dLog = log.With("collector", "s2d")
+
+const (
+	s2dStorageNamespace = "root/Microsoft/Windows/Storage"
+
+	// s2dUsageJournal is the Usage of the physical disks Storage Spaces Direct
+	// uses as cache devices.
+	s2dUsageJournal = 5
+)
+
+// A S2DCollector is a Prometheus collector for the cache devices of Storage
+// Spaces Direct
+type S2DCollector struct {
+	CacheDeviceInfo      *prometheus.Desc
+	CacheDeviceWearRatio *prometheus.Desc
+	CacheStoreBindings   *prometheus.Desc
+	CacheStoreSizeBytes  *prometheus.Desc
+	CacheStoreDirtyBytes *prometheus.Desc
+}
+
+func newS2DCollector() (Collector, error) {
+	const subsystem = "s2d"
+
+	return &S2DCollector{
+		CacheDeviceInfo: newInfoDesc(
+			subsystem+"_cache_device",
+			"A metric with a constant '1' value labeled with the friendly name of the cache device",
+			[]string{"serial_number"},
+			"device",
+		),
+		CacheDeviceWearRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "cache_device_wear_ratio"),
+			"Share of the rated write endurance of the cache device consumed",
+			[]string{"serial_number"},
+			nil,
+		),
+		CacheStoreBindings: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "cache_store_bindings_active"),
+			"Number of capacity devices currently bound to the cache store",
+			[]string{"store"},
+			nil,
+		),
+		CacheStoreSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "cache_store_size_bytes"),
+			"Size of the cache store",
+			[]string{"store"},
+			nil,
+		),
+		CacheStoreDirtyBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "cache_store_dirty_bytes"),
+			"Data written to the cache store that has not yet been destaged to the capacity devices",
+			[]string{"store"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *S2DCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectCacheDevices(ctx, ch); err != nil {
+		s2dLog.Error("failed collecting s2d cache device metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectCacheStores(ctx, ch); err != nil {
+		s2dLog.Error("failed collecting s2d cache store metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// MSFT_PhysicalDisk docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/stormgmt/msft-physicaldisk
+type MSFT_PhysicalDisk struct {
+	DeviceId     string
+	FriendlyName string
+	SerialNumber string
+	Usage        uint16
+}
+
+// MSFT_StorageReliabilityCounter docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/stormgmt/msft-storagereliabilitycounter
+type MSFT_StorageReliabilityCounter struct {
+	DeviceId string
+	Wear     uint8
+}
+
+type s2dCacheDevice struct {
+	name         string
+	serialNumber string
+	wear         float64
+}
+
+// s2dCacheDevices returns the physical disks used as cache devices with the
+// wear reported by their reliability counters. Devices not reporting their
+// wear are left out.
+func s2dCacheDevices(disks []MSFT_PhysicalDisk, counters []MSFT_StorageReliabilityCounter) []s2dCacheDevice {
+	wear := make(map[string]uint8, len(counters))
+	for _, counter := range counters {
+		wear[counter.DeviceId] = counter.Wear
+	}
+
+	var devices []s2dCacheDevice
+	for _, disk := range disks {
+		if disk.Usage != s2dUsageJournal {
+			continue
+		}
+		w, ok := wear[disk.DeviceId]
+		if !ok {
+			continue
+		}
+		// As in the storage_spaces collector, the serial number is the key
+		// of the device series.
+		serial := strings.TrimSpace(disk.SerialNumber)
+		if serial == "" {
+			serial = disk.DeviceId
+		}
+		devices = append(devices, s2dCacheDevice{
+			name:         disk.FriendlyName,
+			serialNumber: serial,
+			wear:         float64(w) / 100,
+		})
+	}
+	return devices
+}
+
+func (c *S2DCollector) collectCacheDevices(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var disks []MSFT_PhysicalDisk
+	q := "SELECT DeviceId, FriendlyName, SerialNumber, Usage FROM MSFT_PhysicalDisk"
+	if err := queryWMIContext(ctx.Context(), q, &disks, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+	var counters []MSFT_StorageReliabilityCounter
+	q = "SELECT DeviceId, Wear FROM MSFT_StorageReliabilityCounter"
+	if err := queryWMIContext(ctx.Context(), q, &counters, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, device := range s2dCacheDevices(disks, counters) {
+		ch <- newInfoMetric(c.CacheDeviceInfo, device.serialNumber, device.name)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheDeviceWearRatio,
+			prometheus.GaugeValue,
+			device.wear,
+			device.serialNumber,
+		)
+	}
+	return nil, nil
+}
+
+// clusterStorageCacheStore holds the counters of the cache store on a cache
+// device.
+type clusterStorageCacheStore struct {
+	Name string
+
+	BindingsActive float64 `perflib:"Bindings Active"`
+	CacheSizeTotal float64 `perflib:"Cache Size Total"`
+	CacheSizeDirty float64 `perflib:"Cache Size Dirty"`
+}
+
+func (c *S2DCollector) collectCacheStores(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The object only exists on nodes of a cluster with Storage Spaces
+	// Direct enabled.
+	if ctx.perfObjects["Cluster Storage Cache Stores"] == nil {
+		return nil, nil
+	}
+	var dst []clusterStorageCacheStore
+	if err := unmarshalObject(ctx.perfObjects["Cluster Storage Cache Stores"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, store := range dst {
+		if store.Name == "_Total" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheStoreBindings,
+			prometheus.GaugeValue,
+			store.BindingsActive,
+			store.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheStoreSizeBytes,
+			prometheus.GaugeValue,
+			store.CacheSizeTotal,
+			store.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CacheStoreDirtyBytes,
+			prometheus.GaugeValue,
+			store.CacheSizeDirty,
+			store.Name,
+		)
+	}
+	return nil, nil
+}
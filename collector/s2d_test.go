@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkS2DCollector(b *testing.B) {
+	benchmarkCollector(b, "s2d", newS2DCollector)
+}
+
+func TestS2DCacheDevices(t *testing.T) {
+	disks := []MSFT_PhysicalDisk{
+		{DeviceId: "1001", FriendlyName: "NVMe INTEL SSDPE2KX020T8", SerialNumber: "PHLJ0001", Usage: s2dUsageJournal},
+		// Capacity devices are auto-selected.
+		{DeviceId: "1002", FriendlyName: "ATA ST8000NM0055", SerialNumber: "ZA10002", Usage: 1},
+		// Cache devices without reliability counters are left out.
+		{DeviceId: "1003", FriendlyName: "NVMe INTEL SSDPE2KX020T8", SerialNumber: "PHLJ0003", Usage: s2dUsageJournal},
+		// Devices without a serial number fall back to their device ID.
+		{DeviceId: "1004", FriendlyName: "Msft Virtual Disk", SerialNumber: " ", Usage: s2dUsageJournal},
+	}
+	counters := []MSFT_StorageReliabilityCounter{
+		{DeviceId: "1001", Wear: 12},
+		{DeviceId: "1002", Wear: 0},
+		{DeviceId: "1004", Wear: 3},
+	}
+	expected := []s2dCacheDevice{
+		{name: "NVMe INTEL SSDPE2KX020T8", serialNumber: "PHLJ0001", wear: 0.12},
+		{name: "Msft Virtual Disk", serialNumber: "1004", wear: 0.03},
+	}
+	if got := s2dCacheDevices(disks, counters); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Cache devices do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
@@ -0,0 +1,282 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("scheduled_task", newScheduledTaskCollector)
+}
+
+var scheduledTaskLogger = log.With("collector", "scheduled_task")
+
+var (
+	taskWhitelist = kingpin.Flag(
+		"collector.scheduled_task.whitelist",
+		"Regexp of task paths to include. Task path must both match whitelist and not match blacklist to be included.",
+	).Default(".+").String()
+	taskBlacklist = kingpin.Flag(
+		"collector.scheduled_task.blacklist",
+		"Regexp of task paths to exclude. Task path must both match whitelist and not match blacklist to be included.",
+	).Default("").String()
+)
+
+const (
+	// taskEnumHidden includes hidden tasks in IRegisteredTaskCollection.
+	taskEnumHidden = 1
+	// schedSTaskHasNotRun is the LastTaskResult of tasks that never ran.
+	schedSTaskHasNotRun = 0x41303
+)
+
+// scheduledTaskStates maps the TASK_STATE of a registered task to the state
+// label, in the order of their value.
+var scheduledTaskStates = []string{"unknown", "disabled", "queued", "ready", "running"}
+
+// A ScheduledTaskCollector is a Prometheus collector for the last runs and
+// states of the tasks of the Task Scheduler
+type ScheduledTaskCollector struct {
+	LastRunTime *prometheus.Desc
+	LastResult  *prometheus.Desc
+	MissedRuns  *prometheus.Desc
+	State       *prometheus.Desc
+
+	taskWhitelistPattern *regexp.Regexp
+	taskBlacklistPattern *regexp.Regexp
+}
+
+func newScheduledTaskCollector() (Collector, error) {
+	const subsystem = "scheduled_task"
+
+	return &ScheduledTaskCollector{
+		LastRunTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_run_timestamp_seconds"),
+			"The time the task last started, as a Unix timestamp. Not reported for tasks that never ran",
+			[]string{"task"},
+			nil,
+		),
+		LastResult: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_result"),
+			"The result code of the last run of the task, 0 on success",
+			[]string{"task"},
+			nil,
+		),
+		MissedRuns: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "missed_runs"),
+			"The number of times the task was not run when it was scheduled to",
+			[]string{"task"},
+			nil,
+		),
+		State: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "state"),
+			"The state of the task (1 for the current state, 0 for the others)",
+			[]string{"task", "state"},
+			nil,
+		),
+		taskWhitelistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *taskWhitelist)),
+		taskBlacklistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *taskBlacklist)),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *ScheduledTaskCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		scheduledTaskLogger.Error("failed collecting scheduled task metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+type scheduledTask struct {
+	path  string
+	state int32
+	// lastRunTime is zero if the task never ran.
+	lastRunTime time.Time
+	lastResult  int32
+	missedRuns  int32
+}
+
+func (c *ScheduledTaskCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	tasks, err := queryScheduledTasks(func(path string) bool {
+		return c.taskWhitelistPattern.MatchString(path) && !c.taskBlacklistPattern.MatchString(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		if !task.lastRunTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				c.LastRunTime,
+				prometheus.GaugeValue,
+				float64(task.lastRunTime.Unix()),
+				task.path,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.LastResult,
+			prometheus.GaugeValue,
+			float64(task.lastResult),
+			task.path,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.MissedRuns,
+			prometheus.GaugeValue,
+			float64(task.missedRuns),
+			task.path,
+		)
+		for i, state := range scheduledTaskStates {
+			isCurrentState := 0.0
+			if int(task.state) == i {
+				isCurrentState = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.State,
+				prometheus.GaugeValue,
+				isCurrentState,
+				task.path,
+				state,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// queryScheduledTasks returns the registered tasks, including hidden ones,
+// whose path is included, through the Task Scheduler scripting API. Folders
+// the exporter's account can't read are skipped.
+// https://docs.microsoft.com/en-us/windows/win32/taskschd/taskschedulerschema-scripting-objects
+func queryScheduledTasks(include func(path string) bool) ([]scheduledTask, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if code := err.(*ole.OleError).Code(); code != ole.S_OK && code != wmi.S_FALSE {
+			return nil, err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	unknown, err := oleutil.CreateObject("Schedule.Service")
+	if err != nil {
+		return nil, err
+	}
+	defer unknown.Release()
+	service, err := unknown.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, err
+	}
+	defer service.Release()
+	if _, err := oleutil.CallMethod(service, "Connect"); err != nil {
+		return nil, err
+	}
+	root, err := oleutil.CallMethod(service, "GetFolder", `\`)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Clear()
+
+	var tasks []scheduledTask
+	if err := queryScheduledTaskFolder(root.ToIDispatch(), include, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func queryScheduledTaskFolder(folder *ole.IDispatch, include func(path string) bool, tasks *[]scheduledTask) error {
+	registered, err := oleutil.CallMethod(folder, "GetTasks", taskEnumHidden)
+	if err != nil {
+		return err
+	}
+	defer registered.Clear()
+	err = oleutil.ForEach(registered.ToIDispatch(), func(v *ole.VARIANT) error {
+		defer v.Clear()
+		task, err := queryScheduledTask(v.ToIDispatch(), include)
+		if err != nil {
+			return err
+		}
+		if task != nil {
+			*tasks = append(*tasks, *task)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	subfolders, err := oleutil.CallMethod(folder, "GetFolders", 0)
+	if err != nil {
+		return err
+	}
+	defer subfolders.Clear()
+	return oleutil.ForEach(subfolders.ToIDispatch(), func(v *ole.VARIANT) error {
+		defer v.Clear()
+		subfolder := v.ToIDispatch()
+		if err := queryScheduledTaskFolder(subfolder, include, tasks); err != nil {
+			path, _ := oleutil.GetProperty(subfolder, "Path")
+			scheduledTaskLogger.Debugf("Skipping scheduled task folder %s: %v", path.ToString(), err)
+			path.Clear()
+		}
+		return nil
+	})
+}
+
+// queryScheduledTask reads an IRegisteredTask, returning nil if its path
+// isn't included.
+func queryScheduledTask(task *ole.IDispatch, include func(path string) bool) (*scheduledTask, error) {
+	path, err := oleutil.GetProperty(task, "Path")
+	if err != nil {
+		return nil, err
+	}
+	result := scheduledTask{path: path.ToString()}
+	path.Clear()
+	if !include(result.path) {
+		return nil, nil
+	}
+
+	for name, dst := range map[string]*int32{
+		"State":              &result.state,
+		"LastTaskResult":     &result.lastResult,
+		"NumberOfMissedRuns": &result.missedRuns,
+	} {
+		v, err := oleutil.GetProperty(task, name)
+		if err != nil {
+			return nil, err
+		}
+		*dst, _ = v.Value().(int32)
+		v.Clear()
+	}
+
+	lastRunTime, err := oleutil.GetProperty(task, "LastRunTime")
+	if err != nil {
+		return nil, err
+	}
+	defer lastRunTime.Clear()
+	// The DATE is returned in the raw bits of the VARIANT, which go-ole
+	// doesn't decode correctly.
+	if date := math.Float64frombits(uint64(lastRunTime.Val)); result.lastResult != schedSTaskHasNotRun && date > 0 {
+		result.lastRunTime = oleDateToTime(date, time.Local)
+	}
+	return &result, nil
+}
+
+// oleDateToTime converts an OLE automation date, the days since midnight of
+// 30 December 1899 with the time of day as the fraction, in the given
+// location. The date is rounded to the second.
+func oleDateToTime(date float64, loc *time.Location) time.Time {
+	days := math.Floor(date)
+	seconds := math.Round((date - days) * 24 * 60 * 60)
+	return time.Date(1899, 12, 30, 0, 0, int(seconds), 0, loc).AddDate(0, 0, int(days))
+}
@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkScheduledTaskCollector(b *testing.B) {
+	benchmarkCollector(b, "scheduled_task", newScheduledTaskCollector)
+}
+
+func TestOLEDateToTime(t *testing.T) {
+	cases := []struct {
+		date     float64
+		expected time.Time
+	}{
+		{date: 36526, expected: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{date: 43831.5, expected: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)},
+		// 3:25:45, which isn't exactly representable.
+		{date: 44013 + 12345.0/86400, expected: time.Date(2020, 7, 1, 3, 25, 45, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		actual := oleDateToTime(c.date, time.UTC)
+		if !actual.Equal(c.expected) {
+			t.Errorf("Time of OLE date %v does not match!\nExpected result: %v\nActual result: %v", c.date, c.expected, actual)
+		}
+	}
+}
@@ -3,12 +3,16 @@
 package collector
 
 import (
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unsafe"
 
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -16,11 +20,17 @@ func init() {
 	registerCollector("service", NewserviceCollector)
 }
 
+var serviceLogger = log.With("collector", "service")
+
 var (
 	serviceWhereClause = kingpin.Flag(
 		"collector.service.services-where",
 		"WQL 'where' clause to use in WMI metrics query. Limits the response to the services you specify and reduces the size of the response.",
 	).Default("").String()
+	serviceWatchStateChanges = kingpin.Flag(
+		"collector.service.watch-state-changes",
+		"Subscribe to the state change notifications of the service control manager for the services collected, to count the state changes happening between scrapes.",
+	).Default("false").Bool()
 )
 
 // A serviceCollector is a Prometheus collector for WMI Win32_Service metrics
@@ -30,7 +40,14 @@ type serviceCollector struct {
 	StartMode   *prometheus.Desc
 	Status      *prometheus.Desc
 
+	StateChanges    *prometheus.Desc
+	LastStateChange *prometheus.Desc
+
 	queryWhereClause string
+
+	// notifier watches the state changes of the services collected, nil if
+	// they aren't watched.
+	notifier *serviceNotifier
 }
 
 // NewserviceCollector ...
@@ -38,15 +55,15 @@ func NewserviceCollector() (Collector, error) {
 	const subsystem = "service"
 
 	if *serviceWhereClause == "" {
-		log.Warn("No where-clause specified for service collector. This will generate a very large number of metrics!")
+		serviceLogger.Warn("No where-clause specified for service collector. This will generate a very large number of metrics!")
 	}
 
-	return &serviceCollector{
-		Information: prometheus.NewDesc(
-			prometheus.BuildFQName(Namespace, subsystem, "info"),
+	c := &serviceCollector{
+		Information: newInfoDesc(
+			subsystem,
 			"A metric with a constant '1' value labeled with service information",
-			[]string{"name", "display_name", "process_id", "run_as"},
-			nil,
+			[]string{"name"},
+			"display_name", "process_id", "run_as",
 		),
 		State: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "state"),
@@ -66,15 +83,39 @@ func NewserviceCollector() (Collector, error) {
 			[]string{"name", "status"},
 			nil,
 		),
+		StateChanges: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "state_changes_total"),
+			"Changes of the service to the state, as notified by the service control manager since the service was first collected",
+			[]string{"name", "state"},
+			nil,
+		),
+		LastStateChange: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_state_change_timestamp_seconds"),
+			"Time of the last state change of the service notified by the service control manager, in seconds since epoch",
+			[]string{"name"},
+			nil,
+		),
 		queryWhereClause: *serviceWhereClause,
-	}, nil
+	}
+	if *serviceWatchStateChanges {
+		c.notifier = newServiceNotifier()
+	}
+	return c, nil
+}
+
+// Close stops watching the state changes of the services.
+func (c *serviceCollector) Close() error {
+	if c.notifier != nil {
+		c.notifier.stop()
+	}
+	return nil
 }
 
 // Collect sends the metric values for each metric
 // to the provided prometheus Metric channel.
 func (c *serviceCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
-	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting service metrics:", desc, err)
+	if desc, err := c.collect(ctx, ch); err != nil {
+		serviceLogger.Error("failed collecting service metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -126,10 +167,10 @@ var (
 	}
 )
 
-func (c *serviceCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+func (c *serviceCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_Service
-	q := queryAllWhere(&dst, c.queryWhereClause)
-	if err := wmi.Query(q, &dst); err != nil {
+	q := queryAllWhere(&dst, wqlLabelValuesWhere(c.queryWhereClause, "Name", allowedLabelValues("service")))
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
 		return nil, err
 	}
 	for _, service := range dst {
@@ -139,10 +180,8 @@ func (c *serviceCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Des
 		if service.StartName != nil {
 			runAs = *service.StartName
 		}
-		ch <- prometheus.MustNewConstMetric(
+		ch <- newInfoMetric(
 			c.Information,
-			prometheus.GaugeValue,
-			1.0,
 			strings.ToLower(service.Name),
 			service.DisplayName,
 			pid,
@@ -190,6 +229,386 @@ func (c *serviceCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Des
 				status,
 			)
 		}
+
+		if c.notifier != nil {
+			c.collectStateChanges(ch, service.Name)
+		}
+	}
+	if c.notifier != nil {
+		names := make([]string, 0, len(dst))
+		for _, service := range dst {
+			names = append(names, service.Name)
+		}
+		c.notifier.watch(names)
 	}
 	return nil, nil
 }
+
+// collectStateChanges sends the state changes of the service, if they are
+// watched yet.
+func (c *serviceCollector) collectStateChanges(ch chan<- prometheus.Metric, name string) {
+	key := strings.ToLower(name)
+	changes, last, ok := c.notifier.snapshot(key)
+	if !ok {
+		return
+	}
+	for _, state := range allStates {
+		ch <- prometheus.MustNewConstMetric(
+			c.StateChanges,
+			prometheus.CounterValue,
+			changes[state],
+			key,
+			state,
+		)
+	}
+	if !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastStateChange,
+			prometheus.GaugeValue,
+			float64(last.UnixNano())/1e9,
+			key,
+		)
+	}
+}
+
+// serviceStateName returns the state label of the current state of a
+// SERVICE_STATUS.
+func serviceStateName(state uint32) string {
+	if state < windows.SERVICE_STOPPED || state > windows.SERVICE_PAUSED {
+		return "unknown"
+	}
+	return allStates[state-windows.SERVICE_STOPPED]
+}
+
+// serviceTransitions counts the changes of the state of a service.
+type serviceTransitions struct {
+	mu      sync.Mutex
+	state   uint32
+	changes map[string]float64
+	last    time.Time
+}
+
+// record sets the state of the service at the time. The first state recorded
+// is the initial one and isn't counted as a change.
+func (t *serviceTransitions) record(state uint32, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.changes == nil {
+		t.state = state
+		t.changes = make(map[string]float64)
+		return
+	}
+	if state == t.state {
+		return
+	}
+	t.state = state
+	t.changes[serviceStateName(state)]++
+	t.last = at
+}
+
+// snapshot returns a copy of the changes by state and the time of the last
+// one, which is zero if there was none. It returns false if no state was
+// recorded yet.
+func (t *serviceTransitions) snapshot() (map[string]float64, time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.changes == nil {
+		return nil, time.Time{}, false
+	}
+	changes := make(map[string]float64, len(t.changes))
+	for state, n := range t.changes {
+		changes[state] = n
+	}
+	return changes, t.last, true
+}
+
+// serviceNotifyMask returns the SERVICE_NOTIFY_* mask of the states of a
+// service other than state, the notification being queued right away if the
+// service is in a state of the mask.
+func serviceNotifyMask(state uint32) uint32 {
+	const all = windows.SERVICE_NOTIFY_STOPPED |
+		windows.SERVICE_NOTIFY_START_PENDING |
+		windows.SERVICE_NOTIFY_STOP_PENDING |
+		windows.SERVICE_NOTIFY_RUNNING |
+		windows.SERVICE_NOTIFY_CONTINUE_PENDING |
+		windows.SERVICE_NOTIFY_PAUSE_PENDING |
+		windows.SERVICE_NOTIFY_PAUSED
+	if state < windows.SERVICE_STOPPED || state > windows.SERVICE_PAUSED {
+		return all
+	}
+	return all &^ (1 << (state - windows.SERVICE_STOPPED))
+}
+
+const (
+	// serviceNotifyPollInterval is how often the notifier checks for services
+	// to start or stop watching, between waiting for notifications.
+	serviceNotifyPollInterval = time.Second
+	// serviceWatchMinRetry and serviceWatchMaxRetry bound the delay before
+	// watching a service again after failing to, which doubles with each
+	// failure in a row.
+	serviceWatchMinRetry = time.Minute
+	serviceWatchMaxRetry = time.Hour
+)
+
+// serviceNotifications holds the watchers with a notification pending by the
+// address of their SERVICE_NOTIFY, for serviceNotifyCallback.
+var serviceNotifications sync.Map
+
+// serviceNotifyCallback is the callback of the notifications. It's called on
+// the thread of the notifier that asked for the notification, while it waits
+// for them, and flags the watcher for the notifier to read the notification.
+var serviceNotifyCallback = windows.NewCallback(func(notify uintptr) uintptr {
+	if w, ok := serviceNotifications.Load(notify); ok {
+		w.(*serviceWatcher).notified = true
+	}
+	return 0
+})
+
+// serviceNotifier watches the state changes of services with
+// NotifyServiceStatusChange. The notifications are delivered as APCs to the
+// thread that asked for them, so all services are watched from a single OS
+// thread.
+// https://docs.microsoft.com/en-us/windows/win32/api/winsvc/nf-winsvc-notifyservicestatuschangew
+type serviceNotifier struct {
+	mu sync.Mutex
+	// wanted holds the names of the services to watch by lower-cased name, as
+	// last collected.
+	wanted map[string]string
+	// transitions holds the state changes of the services wanted by
+	// lower-cased name, kept while a service is watched again after a
+	// failure.
+	transitions map[string]*serviceTransitions
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// serviceWatcher holds the notification of a service watched by the
+// notifier. It's only used on the thread of the notifier.
+type serviceWatcher struct {
+	name    string
+	handle  windows.Handle
+	state   uint32
+	history *serviceTransitions
+
+	// notify is written by the service control manager while a notification
+	// is pending, so it's kept here rather than on the stack.
+	notify   windows.SERVICE_NOTIFY
+	notified bool
+}
+
+// serviceWatchFailure delays watching a service again after failing to.
+type serviceWatchFailure struct {
+	retry time.Time
+	delay time.Duration
+}
+
+func newServiceNotifier() *serviceNotifier {
+	n := &serviceNotifier{
+		wanted:      make(map[string]string),
+		transitions: make(map[string]*serviceTransitions),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// watch sets the services to watch, replacing those of the previous call.
+// The watchers of services no longer collected are stopped.
+func (n *serviceNotifier) watch(names []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	wanted := make(map[string]string, len(names))
+	for _, name := range names {
+		key := strings.ToLower(name)
+		wanted[key] = name
+		if _, ok := n.transitions[key]; !ok {
+			n.transitions[key] = &serviceTransitions{}
+		}
+	}
+	for key := range n.transitions {
+		if _, ok := wanted[key]; !ok {
+			delete(n.transitions, key)
+		}
+	}
+	n.wanted = wanted
+}
+
+// snapshot returns the state changes of the service as
+// serviceTransitions.snapshot does, or false if it isn't watched yet.
+func (n *serviceNotifier) snapshot(key string) (map[string]float64, time.Time, bool) {
+	n.mu.Lock()
+	t, ok := n.transitions[key]
+	n.mu.Unlock()
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return t.snapshot()
+}
+
+// stop stops watching the services and waits for the notifier thread to
+// exit.
+func (n *serviceNotifier) stop() {
+	close(n.done)
+	<-n.stopped
+}
+
+// run watches the services until the notifier is stopped.
+func (n *serviceNotifier) run() {
+	defer close(n.stopped)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		serviceLogger.Warnf("Failed to connect to the service control manager to watch service state changes: %v", err)
+		return
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	watchers := make(map[string]*serviceWatcher)
+	failures := make(map[string]*serviceWatchFailure)
+	defer func() {
+		for key, w := range watchers {
+			n.unwatch(watchers, key, w)
+		}
+	}()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		default:
+		}
+
+		n.mu.Lock()
+		wanted := make(map[string]string, len(n.wanted))
+		history := make(map[string]*serviceTransitions, len(n.transitions))
+		for key, name := range n.wanted {
+			wanted[key] = name
+			history[key] = n.transitions[key]
+		}
+		n.mu.Unlock()
+
+		for key, w := range watchers {
+			if _, ok := wanted[key]; !ok {
+				n.unwatch(watchers, key, w)
+			}
+		}
+		for key := range failures {
+			if _, ok := wanted[key]; !ok {
+				delete(failures, key)
+			}
+		}
+		now := time.Now()
+		for key, name := range wanted {
+			if _, ok := watchers[key]; ok {
+				continue
+			}
+			if f, ok := failures[key]; ok && now.Before(f.retry) {
+				continue
+			}
+			w := &serviceWatcher{name: name, history: history[key]}
+			if err := w.open(scm); err != nil {
+				n.failed(failures, key, name, err)
+				continue
+			}
+			watchers[key] = w
+			if err := n.arm(w); err != nil {
+				n.unwatch(watchers, key, w)
+				n.failed(failures, key, name, err)
+				continue
+			}
+			delete(failures, key)
+		}
+
+		windows.SleepEx(uint32(serviceNotifyPollInterval/time.Millisecond), true)
+
+		for key, w := range watchers {
+			if !w.notified {
+				continue
+			}
+			serviceNotifications.Delete(uintptr(unsafe.Pointer(&w.notify)))
+			w.notified = false
+			if status := w.notify.NotificationStatus; status != 0 {
+				// ERROR_SERVICE_MARKED_FOR_DELETE, the handle must be closed.
+				// The service is watched again if it's created anew.
+				serviceLogger.Debugf("Stopped watching service %s: %v", w.name, windows.Errno(status))
+				n.unwatch(watchers, key, w)
+				continue
+			}
+			w.state = w.notify.ServiceStatus.CurrentState
+			w.history.record(w.state, time.Now())
+			if err := n.arm(w); err != nil {
+				n.unwatch(watchers, key, w)
+				n.failed(failures, key, w.name, err)
+			}
+		}
+	}
+}
+
+// open opens the service and records its current state.
+func (w *serviceWatcher) open(scm windows.Handle) error {
+	name, err := windows.UTF16PtrFromString(w.name)
+	if err != nil {
+		return err
+	}
+	w.handle, err = windows.OpenService(scm, name, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return err
+	}
+	var status windows.SERVICE_STATUS
+	if err := windows.QueryServiceStatus(w.handle, &status); err != nil {
+		windows.CloseServiceHandle(w.handle)
+		return err
+	}
+	w.state = status.CurrentState
+	w.history.record(w.state, time.Now())
+	return nil
+}
+
+// arm asks for a notification of the next state change of the service.
+func (n *serviceNotifier) arm(w *serviceWatcher) error {
+	w.notify = windows.SERVICE_NOTIFY{
+		Version:        windows.SERVICE_NOTIFY_STATUS_CHANGE,
+		NotifyCallback: serviceNotifyCallback,
+	}
+	key := uintptr(unsafe.Pointer(&w.notify))
+	serviceNotifications.Store(key, w)
+	if err := windows.NotifyServiceStatusChange(w.handle, serviceNotifyMask(w.state), &w.notify); err != nil {
+		serviceNotifications.Delete(key)
+		return err
+	}
+	return nil
+}
+
+// unwatch stops watching the service. Closing its handle cancels the pending
+// notification, though one already queued is still delivered by the next
+// alertable wait, so it's waited for before the watcher is dropped.
+func (n *serviceNotifier) unwatch(watchers map[string]*serviceWatcher, key string, w *serviceWatcher) {
+	windows.CloseServiceHandle(w.handle)
+	windows.SleepEx(0, true)
+	serviceNotifications.Delete(uintptr(unsafe.Pointer(&w.notify)))
+	delete(watchers, key)
+}
+
+// failed records a failure to watch the service, to retry it after a delay
+// doubling with each failure in a row. Only the first failure is logged as a
+// warning, as the cause, e.g. missing access rights, usually persists.
+func (n *serviceNotifier) failed(failures map[string]*serviceWatchFailure, key string, name string, err error) {
+	f, ok := failures[key]
+	if !ok {
+		f = &serviceWatchFailure{delay: serviceWatchMinRetry}
+		failures[key] = f
+		serviceLogger.Warnf("Failed to watch the state of service %s, retrying in %s: %v", name, f.delay, err)
+	} else {
+		f.delay *= 2
+		if f.delay > serviceWatchMaxRetry {
+			f.delay = serviceWatchMaxRetry
+		}
+		serviceLogger.Debugf("Failed to watch the state of service %s, retrying in %s: %v", name, f.delay, err)
+	}
+	f.retry = time.Now().Add(f.delay)
+}
@@ -1,9 +1,105 @@
 package collector
 
 import (
+	"reflect"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
 )
 
 func BenchmarkServiceCollector(b *testing.B) {
 	benchmarkCollector(b, "service", NewserviceCollector)
 }
+
+func TestServiceTransitionsRecord(t *testing.T) {
+	var transitions serviceTransitions
+	if _, _, ok := transitions.snapshot(); ok {
+		t.Error("Expected no snapshot before the initial state is recorded")
+	}
+
+	start := time.Unix(1600000000, 0)
+	transitions.record(windows.SERVICE_RUNNING, start)
+	transitions.record(windows.SERVICE_RUNNING, start.Add(time.Second))
+	transitions.record(windows.SERVICE_STOP_PENDING, start.Add(2*time.Second))
+	transitions.record(windows.SERVICE_STOPPED, start.Add(3*time.Second))
+	transitions.record(windows.SERVICE_START_PENDING, start.Add(4*time.Second))
+	transitions.record(windows.SERVICE_RUNNING, start.Add(5*time.Second))
+
+	changes, last, ok := transitions.snapshot()
+	if !ok {
+		t.Fatal("Expected a snapshot after the initial state is recorded")
+	}
+	expected := map[string]float64{
+		"stop pending":  1,
+		"stopped":       1,
+		"start pending": 1,
+		"running":       1,
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("state changes do not match!\nExpected result: %v\nActual result: %v", expected, changes)
+	}
+	if !last.Equal(start.Add(5 * time.Second)) {
+		t.Errorf("last state change does not match!\nExpected result: %v\nActual result: %v", start.Add(5*time.Second), last)
+	}
+}
+
+func TestServiceNotifyMask(t *testing.T) {
+	cases := []struct {
+		state    uint32
+		expected uint32
+	}{
+		{windows.SERVICE_STOPPED, 0x7e},
+		{windows.SERVICE_RUNNING, 0x77},
+		{windows.SERVICE_PAUSED, 0x3f},
+		{0, 0x7f},
+	}
+	for _, c := range cases {
+		if mask := serviceNotifyMask(c.state); mask != c.expected {
+			t.Errorf("notify mask of state %d does not match!\nExpected result: %#x\nActual result: %#x", c.state, c.expected, mask)
+		}
+	}
+}
+
+func TestServiceNotifierWatch(t *testing.T) {
+	n := &serviceNotifier{
+		wanted:      make(map[string]string),
+		transitions: make(map[string]*serviceTransitions),
+	}
+	n.watch([]string{"Spooler", "W32Time"})
+	n.transitions["spooler"].record(windows.SERVICE_RUNNING, time.Now())
+	if _, _, ok := n.snapshot("spooler"); !ok {
+		t.Error("Expected a snapshot of a watched service")
+	}
+	if _, _, ok := n.snapshot("w32time"); ok {
+		t.Error("Expected no snapshot before the initial state is recorded")
+	}
+
+	// Services no longer collected are forgotten, and watched anew.
+	n.watch([]string{"W32Time"})
+	if _, ok := n.wanted["spooler"]; ok {
+		t.Error("Expected a service no longer collected not to be watched")
+	}
+	n.watch([]string{"Spooler"})
+	if _, _, ok := n.snapshot("spooler"); ok {
+		t.Error("Expected the state changes of a service watched anew to be forgotten")
+	}
+}
+
+func TestServiceNotifierFailed(t *testing.T) {
+	var n serviceNotifier
+	failures := make(map[string]*serviceWatchFailure)
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for _, delay := range expected {
+		n.failed(failures, "spooler", "Spooler", windows.ERROR_ACCESS_DENIED)
+		if f := failures["spooler"]; f.delay != delay {
+			t.Errorf("retry delay does not match!\nExpected result: %s\nActual result: %s", delay, f.delay)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		n.failed(failures, "spooler", "Spooler", windows.ERROR_ACCESS_DENIED)
+	}
+	if f := failures["spooler"]; f.delay != serviceWatchMaxRetry {
+		t.Errorf("retry delay does not match!\nExpected result: %s\nActual result: %s", serviceWatchMaxRetry, f.delay)
+	}
+}
@@ -0,0 +1,328 @@
+// +build windows
+
+package collector
+
+import (
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("smb", newSMBCollector, "SMB Server Shares", "SMB Client Shares")
+}
+
+var smbLogger = log.With("collector", "smb")
+
+// A SMBCollector is a Prometheus collector for the shares served by the SMB
+// server and the remote shares used by the SMB client
+type SMBCollector struct {
+	ServerTreeConnects    *prometheus.Desc
+	ServerOpenFiles       *prometheus.Desc
+	ServerPendingRequests *prometheus.Desc
+	ServerFilesOpened     *prometheus.Desc
+	ServerReadBytes       *prometheus.Desc
+	ServerWrittenBytes    *prometheus.Desc
+	ServerReads           *prometheus.Desc
+	ServerWrites          *prometheus.Desc
+	ServerReadLatency     *prometheus.Desc
+	ServerWriteLatency    *prometheus.Desc
+	ClientReadBytes       *prometheus.Desc
+	ClientWrittenBytes    *prometheus.Desc
+	ClientReads           *prometheus.Desc
+	ClientWrites          *prometheus.Desc
+	ClientReadLatency     *prometheus.Desc
+	ClientWriteLatency    *prometheus.Desc
+	ClientDataQueueLength *prometheus.Desc
+}
+
+func newSMBCollector() (Collector, error) {
+	const subsystem = "smb"
+
+	return &SMBCollector{
+		ServerTreeConnects: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_tree_connects"),
+			"Number of tree connects to the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerOpenFiles: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_open_files"),
+			"Number of files currently open on the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerPendingRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_pending_requests"),
+			"Number of requests to the share waiting to be processed",
+			[]string{"share"},
+			nil,
+		),
+		ServerFilesOpened: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_files_opened_total"),
+			"Total files opened on the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_read_bytes_total"),
+			"Total bytes read from the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerWrittenBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_written_bytes_total"),
+			"Total bytes written to the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerReads: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_reads_total"),
+			"Total read requests to the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerWrites: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_writes_total"),
+			"Total write requests to the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerReadLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_read_latency_seconds_total"),
+			"Total time spent serving read requests to the share",
+			[]string{"share"},
+			nil,
+		),
+		ServerWriteLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "server_write_latency_seconds_total"),
+			"Total time spent serving write requests to the share",
+			[]string{"share"},
+			nil,
+		),
+		ClientReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_read_bytes_total"),
+			"Total bytes read from the remote share",
+			[]string{"share"},
+			nil,
+		),
+		ClientWrittenBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_written_bytes_total"),
+			"Total bytes written to the remote share",
+			[]string{"share"},
+			nil,
+		),
+		ClientReads: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_reads_total"),
+			"Total read requests to the remote share",
+			[]string{"share"},
+			nil,
+		),
+		ClientWrites: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_writes_total"),
+			"Total write requests to the remote share",
+			[]string{"share"},
+			nil,
+		),
+		ClientReadLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_read_latency_seconds_total"),
+			"Total time read requests to the remote share took",
+			[]string{"share"},
+			nil,
+		),
+		ClientWriteLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_write_latency_seconds_total"),
+			"Total time write requests to the remote share took",
+			[]string{"share"},
+			nil,
+		),
+		ClientDataQueueLength: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "client_data_queue_length"),
+			"Number of read and write requests to the remote share waiting to be processed",
+			[]string{"share"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *SMBCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectServerShares(ctx, ch); err != nil {
+		smbLogger.Error("failed collecting smb server share metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectClientShares(ctx, ch); err != nil {
+		smbLogger.Error("failed collecting smb client share metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// smbServerShare holds the counters of a share of the SMB server. The
+// latencies are the sums of the latencies of the requests.
+type smbServerShare struct {
+	Name string
+
+	TreeConnectCount       float64 `perflib:"Tree Connect Count"`
+	CurrentOpenFileCount   float64 `perflib:"Current Open File Count"`
+	CurrentPendingRequests float64 `perflib:"Current Pending Requests"`
+	FilesOpenedPerSec      float64 `perflib:"Files Opened/sec"`
+	ReadBytesPerSec        float64 `perflib:"Read Bytes/sec"`
+	WriteBytesPerSec       float64 `perflib:"Write Bytes/sec"`
+	ReadRequestsPerSec     float64 `perflib:"Read Requests/sec"`
+	WriteRequestsPerSec    float64 `perflib:"Write Requests/sec"`
+	AvgSecPerRead          float64 `perflib:"Avg. sec/Read"`
+	AvgSecPerWrite         float64 `perflib:"Avg. sec/Write"`
+}
+
+func (c *SMBCollector) collectServerShares(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The object only exists while the Server service is running.
+	if ctx.perfObjects["SMB Server Shares"] == nil {
+		return nil, nil
+	}
+	var dst []smbServerShare
+	if err := unmarshalObject(ctx.perfObjects["SMB Server Shares"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, share := range dst {
+		if share.Name == "_Total" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerTreeConnects,
+			prometheus.GaugeValue,
+			share.TreeConnectCount,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerOpenFiles,
+			prometheus.GaugeValue,
+			share.CurrentOpenFileCount,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerPendingRequests,
+			prometheus.GaugeValue,
+			share.CurrentPendingRequests,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerFilesOpened,
+			prometheus.CounterValue,
+			share.FilesOpenedPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerReadBytes,
+			prometheus.CounterValue,
+			share.ReadBytesPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerWrittenBytes,
+			prometheus.CounterValue,
+			share.WriteBytesPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerReads,
+			prometheus.CounterValue,
+			share.ReadRequestsPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerWrites,
+			prometheus.CounterValue,
+			share.WriteRequestsPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerReadLatency,
+			prometheus.CounterValue,
+			share.AvgSecPerRead,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ServerWriteLatency,
+			prometheus.CounterValue,
+			share.AvgSecPerWrite,
+			share.Name,
+		)
+	}
+	return nil, nil
+}
+
+// smbClientShare holds the counters of a remote share the SMB client
+// connected to, named \\server\share.
+type smbClientShare struct {
+	Name string
+
+	ReadBytesPerSec        float64 `perflib:"Read Bytes/sec"`
+	WriteBytesPerSec       float64 `perflib:"Write Bytes/sec"`
+	ReadRequestsPerSec     float64 `perflib:"Read Requests/sec"`
+	WriteRequestsPerSec    float64 `perflib:"Write Requests/sec"`
+	AvgSecPerRead          float64 `perflib:"Avg. sec/Read"`
+	AvgSecPerWrite         float64 `perflib:"Avg. sec/Write"`
+	CurrentDataQueueLength float64 `perflib:"Current Data Queue Length"`
+}
+
+func (c *SMBCollector) collectClientShares(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	// The object only exists while the Workstation service is running.
+	if ctx.perfObjects["SMB Client Shares"] == nil {
+		return nil, nil
+	}
+	var dst []smbClientShare
+	if err := unmarshalObject(ctx.perfObjects["SMB Client Shares"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+
+	for _, share := range dst {
+		if share.Name == "_Total" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientReadBytes,
+			prometheus.CounterValue,
+			share.ReadBytesPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientWrittenBytes,
+			prometheus.CounterValue,
+			share.WriteBytesPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientReads,
+			prometheus.CounterValue,
+			share.ReadRequestsPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientWrites,
+			prometheus.CounterValue,
+			share.WriteRequestsPerSec,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientReadLatency,
+			prometheus.CounterValue,
+			share.AvgSecPerRead,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientWriteLatency,
+			prometheus.CounterValue,
+			share.AvgSecPerWrite,
+			share.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ClientDataQueueLength,
+			prometheus.GaugeValue,
+			share.CurrentDataQueueLength,
+			share.Name,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,205 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("smb_latency", newSMBLatencyCollector)
+}
+
+var smbLatencyLogger = log.With("collector", "smb_latency")
+
+var smbLatencyBuckets = kingpin.Flag(
+	"collector.smb_latency.buckets",
+	"Comma-separated upper bounds in seconds of the request latency histogram buckets.",
+).Default("0.0001,0.0005,0.001,0.0025,0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5").String()
+
+const (
+	smbLatencySessionName = "windows_exporter_smb_latency"
+
+	// Opcodes of the events starting and completing an activity
+	etwOpcodeStart = 1
+	etwOpcodeStop  = 2
+
+	// smbLatencyMaxPending bounds the requests awaiting completion, whose stop
+	// event may be lost.
+	smbLatencyMaxPending = 100000
+	smbLatencyMaxAge     = 5 * time.Minute
+)
+
+// Microsoft-Windows-SMBServer
+var smbServerProvider = windows.GUID{
+	Data1: 0xd48ce617,
+	Data2: 0x33a2,
+	Data3: 0x4bc3,
+	Data4: [8]byte{0xa5, 0xc7, 0x11, 0xaa, 0x4f, 0x29, 0x61, 0x9e},
+}
+
+type smbLatencyKey struct {
+	share    string
+	category string
+}
+
+type smbPendingRequest struct {
+	start    time.Time
+	share    string
+	category string
+}
+
+// A SMBLatencyCollector is a Prometheus collector for the latency of the
+// requests served by the SMB server, from the start and stop events of its
+// requests
+type SMBLatencyCollector struct {
+	RequestDuration *prometheus.Desc
+
+	bounds  []float64
+	session *etw.Session
+
+	mu         sync.Mutex
+	pending    map[windows.GUID]smbPendingRequest
+	histograms map[smbLatencyKey]*etwHistogram
+}
+
+func newSMBLatencyCollector() (Collector, error) {
+	const subsystem = "smb_latency"
+
+	bounds, err := parseSMBLatencyBuckets(*smbLatencyBuckets)
+	if err != nil {
+		return nil, err
+	}
+	c := &SMBLatencyCollector{
+		RequestDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "request_duration_seconds"),
+			"Histogram of the latency of the requests to the share completed since the exporter started",
+			[]string{"share", "category"},
+			nil,
+		),
+		bounds:     bounds,
+		pending:    make(map[windows.GUID]smbPendingRequest),
+		histograms: make(map[smbLatencyKey]*etwHistogram),
+	}
+
+	c.session, err = etw.StartSession(smbLatencySessionName, []etw.Provider{{GUID: smbServerProvider, Level: 4}}, c.handleEvent)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseSMBLatencyBuckets parses the comma-separated bucket upper bounds,
+// returning them in increasing order.
+func parseSMBLatencyBuckets(s string) ([]float64, error) {
+	var bounds []float64
+	for _, f := range strings.Split(s, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("invalid SMB latency bucket %q", f)
+		}
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+	return bounds, nil
+}
+
+// smbRequestCategory classifies requests by the name of their task, e.g.
+// Smb2Read. Requests other than reads and writes operate on metadata.
+func smbRequestCategory(task string) string {
+	task = strings.ToLower(task)
+	switch {
+	case strings.Contains(task, "read"):
+		return "read"
+	case strings.Contains(task, "write"):
+		return "write"
+	}
+	return "metadata"
+}
+
+func (c *SMBLatencyCollector) handleEvent(e *etw.Event) {
+	switch e.Opcode {
+	case etwOpcodeStart:
+		task, err := e.TaskName()
+		if err != nil {
+			smbLatencyLogger.Debugf("Failed to decode SMB server event %d: %v", e.ID, err)
+			return
+		}
+		props, err := e.Properties()
+		if err != nil {
+			smbLatencyLogger.Debugf("Failed to decode SMB server event %d: %v", e.ID, err)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.pending) >= smbLatencyMaxPending {
+			c.expirePending(e.Time)
+		}
+		if len(c.pending) < smbLatencyMaxPending {
+			c.pending[e.ActivityID] = smbPendingRequest{
+				start:    e.Time,
+				share:    props["ShareName"],
+				category: smbRequestCategory(task),
+			}
+		}
+	case etwOpcodeStop:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		r, ok := c.pending[e.ActivityID]
+		if !ok {
+			return
+		}
+		delete(c.pending, e.ActivityID)
+		c.observe(smbLatencyKey{share: r.share, category: r.category}, e.Time.Sub(r.start).Seconds())
+	}
+}
+
+// expirePending removes the requests whose stop event was lost.
+func (c *SMBLatencyCollector) expirePending(now time.Time) {
+	for id, r := range c.pending {
+		if now.Sub(r.start) > smbLatencyMaxAge {
+			delete(c.pending, id)
+		}
+	}
+}
+
+func (c *SMBLatencyCollector) observe(key smbLatencyKey, seconds float64) {
+	h, ok := c.histograms[key]
+	if !ok {
+		h = newETWHistogram(c.bounds)
+		c.histograms[key] = h
+	}
+	h.observe(seconds)
+}
+
+// Close stops the ETW session of the collector.
+func (c *SMBLatencyCollector) Close() error {
+	return c.session.Close()
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *SMBLatencyCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	metrics := make([]prometheus.Metric, 0, len(c.histograms))
+	for k, h := range c.histograms {
+		metrics = append(metrics, h.metric(c.RequestDuration, k.share, k.category))
+	}
+	c.mu.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	return nil
+}
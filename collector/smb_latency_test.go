@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkSMBLatencyCollector(b *testing.B) {
+	benchmarkCollector(b, "smb_latency", newSMBLatencyCollector)
+}
+
+func TestParseSMBLatencyBuckets(t *testing.T) {
+	bounds, err := parseSMBLatencyBuckets("0.1, 0.01,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0.01, 0.1, 1}; !reflect.DeepEqual(bounds, want) {
+		t.Errorf("got %v, want %v", bounds, want)
+	}
+	for _, s := range []string{"", "0.1,,1", "-1", "fast"} {
+		if _, err := parseSMBLatencyBuckets(s); err == nil {
+			t.Errorf("parseSMBLatencyBuckets(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSMBRequestCategory(t *testing.T) {
+	for task, want := range map[string]string{"Smb2Read": "read", "Smb2Write": "write", "Smb2Create": "metadata", "Smb2QueryDirectory": "metadata"} {
+		if got := smbRequestCategory(task); got != want {
+			t.Errorf("smbRequestCategory(%q) = %q, want %q", task, got, want)
+		}
+	}
+}
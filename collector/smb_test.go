@@ -0,0 +1,9 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkSMBCollector(b *testing.B) {
+	benchmarkCollector(b, "smb", newSMBCollector)
+}
@@ -0,0 +1,443 @@
+// +build windows
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("snmp", NewSNMPCollector)
+}
+
+var snmpLogger = log.With("collector", "snmp")
+
+var (
+	snmpAddress = kingpin.Flag(
+		"collector.snmp.address",
+		"Address of the SNMP service to query.",
+	).Default("127.0.0.1:161").String()
+	snmpCommunity = kingpin.Flag(
+		"collector.snmp.community",
+		"SNMP community with read access to the SNMP service.",
+	).Default("public").String()
+	snmpTimeout = kingpin.Flag(
+		"collector.snmp.timeout",
+		"Timeout of the request to the SNMP service.",
+	).Default("1s").Duration()
+)
+
+// snmpGroupOID is the OID of the snmp group of SNMPv2-MIB, whose objects
+// count the messages handled by the SNMP agent.
+// https://tools.ietf.org/html/rfc3418
+const snmpGroupOID = "1.3.6.1.2.1.11"
+
+// A SNMPCollector is a Prometheus collector for the statistics of the SNMP
+// service, read from the snmp group of its own MIB-II
+type SNMPCollector struct {
+	Up                  *prometheus.Desc
+	InPackets           *prometheus.Desc
+	OutPackets          *prometheus.Desc
+	BadVersions         *prometheus.Desc
+	BadCommunityNames   *prometheus.Desc
+	BadCommunityUses    *prometheus.Desc
+	ASNParseErrors      *prometheus.Desc
+	InRequests          *prometheus.Desc
+	OutGetResponses     *prometheus.Desc
+	OutTraps            *prometheus.Desc
+	AuthenticationTraps *prometheus.Desc
+
+	objects []snmpObject
+}
+
+type snmpObject struct {
+	oid   string
+	desc  *prometheus.Desc
+	typ   prometheus.ValueType
+	label string
+}
+
+// NewSNMPCollector ...
+func NewSNMPCollector() (Collector, error) {
+	const subsystem = "snmp"
+
+	c := &SNMPCollector{
+		Up: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "up"),
+			"Whether the SNMP service answered the request",
+			nil,
+			nil,
+		),
+		InPackets: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "in_packets_total"),
+			"Total messages delivered to the SNMP service (snmpInPkts)",
+			nil,
+			nil,
+		),
+		OutPackets: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "out_packets_total"),
+			"Total messages sent by the SNMP service (snmpOutPkts)",
+			nil,
+			nil,
+		),
+		BadVersions: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "bad_versions_total"),
+			"Total messages for an unsupported SNMP version (snmpInBadVersions)",
+			nil,
+			nil,
+		),
+		BadCommunityNames: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "bad_community_names_total"),
+			"Total messages using an unknown community name (snmpInBadCommunityNames)",
+			nil,
+			nil,
+		),
+		BadCommunityUses: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "bad_community_uses_total"),
+			"Total messages requesting an operation not allowed for their community (snmpInBadCommunityUses)",
+			nil,
+			nil,
+		),
+		ASNParseErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "asn_parse_errors_total"),
+			"Total messages that failed to decode (snmpInASNParseErrs)",
+			nil,
+			nil,
+		),
+		InRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "in_requests_total"),
+			"Total requests accepted and processed by the SNMP service, by type",
+			[]string{"type"},
+			nil,
+		),
+		OutGetResponses: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "out_get_responses_total"),
+			"Total responses sent by the SNMP service (snmpOutGetResponses)",
+			nil,
+			nil,
+		),
+		OutTraps: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "out_traps_total"),
+			"Total traps sent by the SNMP service (snmpOutTraps)",
+			nil,
+			nil,
+		),
+		AuthenticationTraps: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "authentication_traps_enabled"),
+			"Whether the SNMP service sends authentication failure traps (snmpEnableAuthenTraps)",
+			nil,
+			nil,
+		),
+	}
+	c.objects = []snmpObject{
+		{snmpGroupOID + ".1.0", c.InPackets, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".2.0", c.OutPackets, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".3.0", c.BadVersions, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".4.0", c.BadCommunityNames, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".5.0", c.BadCommunityUses, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".6.0", c.ASNParseErrors, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".15.0", c.InRequests, prometheus.CounterValue, "get"},
+		{snmpGroupOID + ".16.0", c.InRequests, prometheus.CounterValue, "getnext"},
+		{snmpGroupOID + ".17.0", c.InRequests, prometheus.CounterValue, "set"},
+		{snmpGroupOID + ".28.0", c.OutGetResponses, prometheus.CounterValue, ""},
+		{snmpGroupOID + ".29.0", c.OutTraps, prometheus.CounterValue, ""},
+		// snmpEnableAuthenTraps is enabled(1) or disabled(2).
+		{snmpGroupOID + ".30.0", c.AuthenticationTraps, prometheus.GaugeValue, ""},
+	}
+	return c, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *SNMPCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		snmpLogger.Error("failed collecting snmp metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *SNMPCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	oids := make([]string, 0, len(c.objects))
+	for _, o := range c.objects {
+		oids = append(oids, o.oid)
+	}
+
+	address := flagString(snmpAddress)
+	values, err := snmpGet(address, flagString(snmpCommunity), oids, flagDuration(snmpTimeout))
+	ch <- prometheus.MustNewConstMetric(
+		c.Up,
+		prometheus.GaugeValue,
+		boolToFloat(err == nil),
+	)
+	if err != nil {
+		// The SNMP service silently drops requests with an unknown
+		// community, which is only noticed as a timeout.
+		snmpLogger.Warnf("Failed to query SNMP service at %s: %v", address, err)
+		return nil, nil
+	}
+
+	for _, o := range c.objects {
+		value, ok := values[o.oid]
+		if !ok {
+			continue
+		}
+		if o.desc == c.AuthenticationTraps {
+			value = boolToFloat(value == 1)
+		}
+		var labels []string
+		if o.label != "" {
+			labels = []string{o.label}
+		}
+		ch <- prometheus.MustNewConstMetric(o.desc, o.typ, value, labels...)
+	}
+	return nil, nil
+}
+
+// snmpGet sends an SNMPv2c GetRequest for oids and returns the numeric values
+// of the objects that exist, keyed by OID.
+func snmpGet(address, community string, oids []string, timeout time.Duration) (map[string]float64, error) {
+	requestID := rand.Int31()
+	request, err := snmpEncodeGetRequest(community, requestID, oids)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		id, values, err := snmpDecodeResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		// Skip late responses to earlier requests.
+		if id == requestID {
+			return values, nil
+		}
+	}
+}
+
+// BER tags used by SNMP.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berCounter64   = 0x46
+
+	snmpGetRequest = 0xa0
+	snmpResponse   = 0xa2
+	snmpVersion2c  = 1
+)
+
+func berEncode(tag byte, content []byte) []byte {
+	n := len(content)
+	var length []byte
+	switch {
+	case n < 0x80:
+		length = []byte{byte(n)}
+	case n <= 0xff:
+		length = []byte{0x81, byte(n)}
+	default:
+		length = []byte{0x82, byte(n >> 8), byte(n)}
+	}
+	return append(append([]byte{tag}, length...), content...)
+}
+
+func berEncodeInt(v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 0x7f || v < -0x80 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return berEncode(berInteger, b)
+}
+
+func berEncodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	ids := make([]uint32, len(parts))
+	for i, p := range parts {
+		id, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		ids[i] = uint32(id)
+	}
+	b := []byte{byte(ids[0]*40 + ids[1])}
+	for _, id := range ids[2:] {
+		// Base 128, most significant group first, continuation bit set on all
+		// but the last group.
+		enc := []byte{byte(id & 0x7f)}
+		for id >>= 7; id > 0; id >>= 7 {
+			enc = append([]byte{byte(id&0x7f) | 0x80}, enc...)
+		}
+		b = append(b, enc...)
+	}
+	return berEncode(berOID, b), nil
+}
+
+func snmpEncodeGetRequest(community string, requestID int32, oids []string) ([]byte, error) {
+	var varbinds []byte
+	for _, oid := range oids {
+		name, err := berEncodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		varbinds = append(varbinds, berEncode(berSequence, append(name, berNull, 0))...)
+	}
+	var pdu []byte
+	pdu = append(pdu, berEncodeInt(int64(requestID))...)
+	pdu = append(pdu, berEncodeInt(0)...) // error-status
+	pdu = append(pdu, berEncodeInt(0)...) // error-index
+	pdu = append(pdu, berEncode(berSequence, varbinds)...)
+
+	var msg []byte
+	msg = append(msg, berEncodeInt(snmpVersion2c)...)
+	msg = append(msg, berEncode(berOctetString, []byte(community))...)
+	msg = append(msg, berEncode(snmpGetRequest, pdu)...)
+	return berEncode(berSequence, msg), nil
+}
+
+// berDecode splits the first TLV off b.
+func berDecode(b []byte) (tag byte, content []byte, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errors.New("truncated message")
+	}
+	tag = b[0]
+	n := int(b[1])
+	b = b[2:]
+	if n&0x80 != 0 {
+		lengthBytes := n & 0x7f
+		if lengthBytes == 0 || lengthBytes > 2 || len(b) < lengthBytes {
+			return 0, nil, nil, errors.New("invalid length")
+		}
+		n = 0
+		for _, c := range b[:lengthBytes] {
+			n = n<<8 | int(c)
+		}
+		b = b[lengthBytes:]
+	}
+	if len(b) < n {
+		return 0, nil, nil, errors.New("truncated message")
+	}
+	return tag, b[:n], b[n:], nil
+}
+
+func berDecodeOID(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	parts := []string{strconv.Itoa(int(b[0]) / 40), strconv.Itoa(int(b[0]) % 40)}
+	var id uint64
+	for _, c := range b[1:] {
+		id = id<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			parts = append(parts, strconv.FormatUint(id, 10))
+			id = 0
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func berDecodeInt(b []byte, signed bool) uint64 {
+	var v uint64
+	if signed && len(b) > 0 && b[0]&0x80 != 0 {
+		v = ^uint64(0)
+	}
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// snmpDecodeResponse decodes a Response-PDU, returning its request ID and
+// the numeric values of its variable bindings. Bindings of objects that
+// don't exist are left out.
+func snmpDecodeResponse(b []byte) (int32, map[string]float64, error) {
+	tag, msg, _, err := berDecode(b)
+	if err != nil || tag != berSequence {
+		return 0, nil, fmt.Errorf("invalid message: %v", err)
+	}
+	// Skip version and community.
+	for i := 0; i < 2; i++ {
+		if _, _, msg, err = berDecode(msg); err != nil {
+			return 0, nil, err
+		}
+	}
+	tag, pdu, _, err := berDecode(msg)
+	if err != nil {
+		return 0, nil, err
+	}
+	if tag != snmpResponse {
+		return 0, nil, fmt.Errorf("unexpected PDU type 0x%x", tag)
+	}
+
+	var fields [3][]byte
+	for i := range fields {
+		if _, fields[i], pdu, err = berDecode(pdu); err != nil {
+			return 0, nil, err
+		}
+	}
+	requestID := int32(berDecodeInt(fields[0], true))
+	if status := berDecodeInt(fields[1], true); status != 0 {
+		return requestID, nil, fmt.Errorf("agent returned error-status %d", status)
+	}
+
+	_, varbinds, _, err := berDecode(pdu)
+	if err != nil {
+		return 0, nil, err
+	}
+	values := make(map[string]float64)
+	for len(varbinds) > 0 {
+		var varbind, name, value []byte
+		var valueTag byte
+		if _, varbind, varbinds, err = berDecode(varbinds); err != nil {
+			return 0, nil, err
+		}
+		if _, name, varbind, err = berDecode(varbind); err != nil {
+			return 0, nil, err
+		}
+		if valueTag, value, _, err = berDecode(varbind); err != nil {
+			return 0, nil, err
+		}
+		switch valueTag {
+		case berInteger:
+			values[berDecodeOID(name)] = float64(int64(berDecodeInt(value, true)))
+		case berCounter32, berGauge32, berTimeTicks, berCounter64:
+			values[berDecodeOID(name)] = float64(berDecodeInt(value, false))
+		}
+	}
+	return requestID, values, nil
+}
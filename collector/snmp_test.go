@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// snmpTestAgent answers GetRequests for the scalars of the snmp group with a
+// Counter32 of 1000 + the object's sub-identifier, snmpEnableAuthenTraps with
+// disabled(2), and all others with noSuchObject.
+func snmpTestAgent(t *testing.T, conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		_, msg, _, _ := berDecode(buf[:n])
+		_, _, msg, _ = berDecode(msg) // version
+		_, _, msg, _ = berDecode(msg) // community
+		_, pdu, _, _ := berDecode(msg)
+		_, requestID, pdu, _ := berDecode(pdu)
+		_, _, pdu, _ = berDecode(pdu)
+		_, _, pdu, _ = berDecode(pdu)
+		_, varbinds, _, _ := berDecode(pdu)
+
+		var response []byte
+		for len(varbinds) > 0 {
+			var varbind, name []byte
+			_, varbind, varbinds, _ = berDecode(varbinds)
+			_, name, _, _ = berDecode(varbind)
+			oid := berDecodeOID(name)
+			encodedName, _ := berEncodeOID(oid)
+			value := []byte{0x80, 0} // noSuchObject
+			if oid == snmpGroupOID+".30.0" {
+				value = berEncodeInt(2)
+			} else if strings.HasPrefix(oid, snmpGroupOID+".") {
+				value = berEncode(berCounter32, []byte{0x03, 0xe8 + name[len(name)-2]})
+			}
+			response = append(response, berEncode(berSequence, append(encodedName, value...))...)
+		}
+		var body []byte
+		body = append(body, berEncode(berInteger, requestID)...)
+		body = append(body, berEncodeInt(0)...)
+		body = append(body, berEncodeInt(0)...)
+		body = append(body, berEncode(berSequence, response)...)
+		var reply []byte
+		reply = append(reply, berEncodeInt(snmpVersion2c)...)
+		reply = append(reply, berEncode(berOctetString, []byte("public"))...)
+		reply = append(reply, berEncode(snmpResponse, body)...)
+		if _, err := conn.WriteTo(berEncode(berSequence, reply), addr); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSNMPGet(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go snmpTestAgent(t, conn)
+
+	values, err := snmpGet(conn.LocalAddr().String(), "public", []string{
+		snmpGroupOID + ".4.0",
+		snmpGroupOID + ".30.0",
+		"1.3.6.1.2.1.1.3.0",
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("expected 2 values, got %v", values)
+	}
+	if v := values[snmpGroupOID+".4.0"]; v != 1004 {
+		t.Errorf("expected snmpInBadCommunityNames 1004, got %v", v)
+	}
+	if v := values[snmpGroupOID+".30.0"]; v != 2 {
+		t.Errorf("expected snmpEnableAuthenTraps 2, got %v", v)
+	}
+}
+
+func TestBEREncodeOID(t *testing.T) {
+	for _, oid := range []string{"1.3.6.1.2.1.11.4.0", "1.3.6.1.4.1.311.1", "2.5.4.3", "1.3.6.1.2.1.2.2.1.10.4294967295"} {
+		b, err := berEncodeOID(oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, content, _, err := berDecode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := berDecodeOID(content); got != oid {
+			t.Errorf("expected %s, got %s", oid, got)
+		}
+	}
+}
+
+func BenchmarkSNMPCollector(b *testing.B) {
+	benchmarkCollector(b, "snmp", NewSNMPCollector)
+}
@@ -0,0 +1,402 @@
+// +build windows
+
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("storage_spaces", newStorageSpacesCollector)
+}
+
+var storageSpacesLogger = log.With("collector", "storage_spaces")
+
+// storageHealthStatuses are the HealthStatus values of the Storage Management
+// API objects, in the order of their values.
+var storageHealthStatuses = []string{"healthy", "warning", "unhealthy", "unknown"}
+
+// storageJobCompleted is the JobState of finished storage jobs; later states
+// are those of jobs that were stopped or failed.
+const storageJobCompleted = 7
+
+// A StorageSpacesCollector is a Prometheus collector for the health and
+// capacity of Storage Spaces pools, their virtual disks and physical disks,
+// and the jobs repairing them
+type StorageSpacesCollector struct {
+	PoolSize                *prometheus.Desc
+	PoolAllocated           *prometheus.Desc
+	PoolHealth              *prometheus.Desc
+	PoolReadOnly            *prometheus.Desc
+	VirtualDiskInfo         *prometheus.Desc
+	VirtualDiskSize         *prometheus.Desc
+	VirtualDiskFootprint    *prometheus.Desc
+	VirtualDiskHealth       *prometheus.Desc
+	PhysicalDiskInfo        *prometheus.Desc
+	PhysicalDiskHealth      *prometheus.Desc
+	PhysicalDiskUncorrected *prometheus.Desc
+	Jobs                    *prometheus.Desc
+	JobBytesProcessed       *prometheus.Desc
+	JobBytesTotal           *prometheus.Desc
+}
+
+func newStorageSpacesCollector() (Collector, error) {
+	const subsystem = "storage_spaces"
+
+	return &StorageSpacesCollector{
+		PoolSize: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pool_size_bytes"),
+			"Capacity of the storage pool",
+			[]string{"pool"},
+			nil,
+		),
+		PoolAllocated: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pool_allocated_bytes"),
+			"Capacity of the storage pool allocated to virtual disks",
+			[]string{"pool"},
+			nil,
+		),
+		PoolHealth: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pool_health"),
+			"Health of the storage pool (1 for the current health, 0 for the others)",
+			[]string{"pool", "health"},
+			nil,
+		),
+		PoolReadOnly: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pool_read_only"),
+			"Whether the storage pool is read-only (1) or not (0)",
+			[]string{"pool"},
+			nil,
+		),
+		VirtualDiskInfo: newInfoDesc(
+			"storage_spaces_virtual_disk",
+			"Resiliency of the virtual disk",
+			[]string{"virtual_disk"},
+			"resiliency", "data_copies",
+		),
+		VirtualDiskSize: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "virtual_disk_size_bytes"),
+			"Capacity of the virtual disk",
+			[]string{"virtual_disk"},
+			nil,
+		),
+		VirtualDiskFootprint: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "virtual_disk_footprint_bytes"),
+			"Capacity of the storage pool used by the virtual disk, including its copies and parity",
+			[]string{"virtual_disk"},
+			nil,
+		),
+		VirtualDiskHealth: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "virtual_disk_health"),
+			"Health of the virtual disk (1 for the current health, 0 for the others)",
+			[]string{"virtual_disk", "health"},
+			nil,
+		),
+		PhysicalDiskInfo: newInfoDesc(
+			"storage_spaces_physical_disk",
+			"A metric with a constant '1' value labeled with the friendly name of the physical disk",
+			[]string{"serial_number"},
+			"disk",
+		),
+		PhysicalDiskHealth: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "physical_disk_health"),
+			"Health of the physical disk (1 for the current health, 0 for the others)",
+			[]string{"serial_number", "health"},
+			nil,
+		),
+		PhysicalDiskUncorrected: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "physical_disk_uncorrected_errors_total"),
+			"Read or write errors of the physical disk that couldn't be corrected, as reported by its reliability counters",
+			[]string{"serial_number", "operation"},
+			nil,
+		),
+		Jobs: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "jobs"),
+			"Number of storage jobs of the name not yet completed, e.g. repairing or rebalancing virtual disks",
+			[]string{"job"},
+			nil,
+		),
+		JobBytesProcessed: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "job_processed_bytes"),
+			"Data processed by the storage jobs of the name not yet completed",
+			[]string{"job"},
+			nil,
+		),
+		JobBytesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "job_total_bytes"),
+			"Data to process by the storage jobs of the name not yet completed",
+			[]string{"job"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *StorageSpacesCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectPools(ctx, ch); err != nil {
+		storageSpacesLogger.Error("failed collecting storage_spaces pool metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectVirtualDisks(ctx, ch); err != nil {
+		storageSpacesLogger.Error("failed collecting storage_spaces virtual disk metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectPhysicalDisks(ctx, ch); err != nil {
+		storageSpacesLogger.Error("failed collecting storage_spaces physical disk metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectJobs(ctx, ch); err != nil {
+		storageSpacesLogger.Error("failed collecting storage_spaces job metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// storageHealth returns the name of a HealthStatus value.
+func storageHealth(status uint16) string {
+	if int(status) < len(storageHealthStatuses)-1 {
+		return storageHealthStatuses[status]
+	}
+	return "unknown"
+}
+
+func (c *StorageSpacesCollector) sendHealth(ch chan<- prometheus.Metric, desc *prometheus.Desc, status uint16, labels ...string) {
+	current := storageHealth(status)
+	for _, health := range storageHealthStatuses {
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.GaugeValue,
+			boolToFloat(health == current),
+			append(append([]string{}, labels...), health)...,
+		)
+	}
+}
+
+// MSFT_StoragePool docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/stormgmt/msft-storagepool
+type MSFT_StoragePool struct {
+	FriendlyName  string
+	HealthStatus  uint16
+	IsReadOnly    bool
+	Size          uint64
+	AllocatedSize uint64
+}
+
+func (c *StorageSpacesCollector) collectPools(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var pools []MSFT_StoragePool
+	// The primordial pools hold the disks not yet added to a pool.
+	q := queryAllWhere(&pools, "IsPrimordial = FALSE")
+	if err := queryWMIContext(ctx.Context(), q, &pools, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, pool := range pools {
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolSize,
+			prometheus.GaugeValue,
+			float64(pool.Size),
+			pool.FriendlyName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolAllocated,
+			prometheus.GaugeValue,
+			float64(pool.AllocatedSize),
+			pool.FriendlyName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PoolReadOnly,
+			prometheus.GaugeValue,
+			boolToFloat(pool.IsReadOnly),
+			pool.FriendlyName,
+		)
+		c.sendHealth(ch, c.PoolHealth, pool.HealthStatus, pool.FriendlyName)
+	}
+	return nil, nil
+}
+
+// MSFT_VirtualDisk docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/stormgmt/msft-virtualdisk
+type MSFT_VirtualDisk struct {
+	FriendlyName          string
+	HealthStatus          uint16
+	ResiliencySettingName string
+	NumberOfDataCopies    uint16
+	Size                  uint64
+	FootprintOnPool       uint64
+}
+
+func (c *StorageSpacesCollector) collectVirtualDisks(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var disks []MSFT_VirtualDisk
+	q := queryAll(&disks)
+	if err := queryWMIContext(ctx.Context(), q, &disks, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, disk := range disks {
+		ch <- newInfoMetric(
+			c.VirtualDiskInfo,
+			disk.FriendlyName,
+			disk.ResiliencySettingName,
+			strconv.Itoa(int(disk.NumberOfDataCopies)),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VirtualDiskSize,
+			prometheus.GaugeValue,
+			float64(disk.Size),
+			disk.FriendlyName,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.VirtualDiskFootprint,
+			prometheus.GaugeValue,
+			float64(disk.FootprintOnPool),
+			disk.FriendlyName,
+		)
+		c.sendHealth(ch, c.VirtualDiskHealth, disk.HealthStatus, disk.FriendlyName)
+	}
+	return nil, nil
+}
+
+// storageSpacesPhysicalDisk holds the properties of MSFT_PhysicalDisk read by
+// the collector.
+type storageSpacesPhysicalDisk struct {
+	DeviceId     string
+	FriendlyName string
+	SerialNumber string
+	HealthStatus uint16
+}
+
+// storageSpacesReliabilityCounter holds the properties of
+// MSFT_StorageReliabilityCounter read by the collector.
+type storageSpacesReliabilityCounter struct {
+	DeviceId               string
+	ReadErrorsUncorrected  uint64
+	WriteErrorsUncorrected uint64
+}
+
+func (c *StorageSpacesCollector) collectPhysicalDisks(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var disks []storageSpacesPhysicalDisk
+	q := "SELECT DeviceId, FriendlyName, SerialNumber, HealthStatus FROM MSFT_PhysicalDisk"
+	if err := queryWMIContext(ctx.Context(), q, &disks, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+	var counters []storageSpacesReliabilityCounter
+	q = "SELECT DeviceId, ReadErrorsUncorrected, WriteErrorsUncorrected FROM MSFT_StorageReliabilityCounter"
+	if err := queryWMIContext(ctx.Context(), q, &counters, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+	reliability := make(map[string]storageSpacesReliabilityCounter, len(counters))
+	for _, counter := range counters {
+		reliability[counter.DeviceId] = counter
+	}
+
+	for _, disk := range disks {
+		// Disks are identified by their serial number, which unlike their
+		// friendly name can't be changed. Disks without one, e.g. some
+		// virtual disks of VMs, fall back to their device ID.
+		serial := strings.TrimSpace(disk.SerialNumber)
+		if serial == "" {
+			serial = disk.DeviceId
+		}
+		ch <- newInfoMetric(c.PhysicalDiskInfo, serial, disk.FriendlyName)
+		c.sendHealth(ch, c.PhysicalDiskHealth, disk.HealthStatus, serial)
+
+		// Disks whose driver doesn't report reliability counters have none.
+		counter, ok := reliability[disk.DeviceId]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.PhysicalDiskUncorrected,
+			prometheus.CounterValue,
+			float64(counter.ReadErrorsUncorrected),
+			serial,
+			"read",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PhysicalDiskUncorrected,
+			prometheus.CounterValue,
+			float64(counter.WriteErrorsUncorrected),
+			serial,
+			"write",
+		)
+	}
+	return nil, nil
+}
+
+// MSFT_StorageJob docs:
+// - https://docs.microsoft.com/en-us/previous-versions/windows/desktop/stormgmt/msft-storagejob
+type MSFT_StorageJob struct {
+	Name           string
+	JobState       uint16
+	BytesProcessed uint64
+	BytesTotal     uint64
+}
+
+type storageJobSummary struct {
+	name           string
+	jobs           int
+	bytesProcessed uint64
+	bytesTotal     uint64
+}
+
+// storageJobSummaries sums the storage jobs not yet completed by name, e.g.
+// the Regeneration jobs of several virtual disks, sorted by name.
+func storageJobSummaries(jobs []MSFT_StorageJob) []storageJobSummary {
+	byName := make(map[string]*storageJobSummary)
+	for _, job := range jobs {
+		if job.JobState >= storageJobCompleted {
+			continue
+		}
+		s, ok := byName[job.Name]
+		if !ok {
+			s = &storageJobSummary{name: job.Name}
+			byName[job.Name] = s
+		}
+		s.jobs++
+		s.bytesProcessed += job.BytesProcessed
+		s.bytesTotal += job.BytesTotal
+	}
+
+	summaries := make([]storageJobSummary, 0, len(byName))
+	for _, s := range byName {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].name < summaries[j].name })
+	return summaries
+}
+
+func (c *StorageSpacesCollector) collectJobs(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var jobs []MSFT_StorageJob
+	q := queryAll(&jobs)
+	if err := queryWMIContext(ctx.Context(), q, &jobs, nil, s2dStorageNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, s := range storageJobSummaries(jobs) {
+		ch <- prometheus.MustNewConstMetric(
+			c.Jobs,
+			prometheus.GaugeValue,
+			float64(s.jobs),
+			s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.JobBytesProcessed,
+			prometheus.GaugeValue,
+			float64(s.bytesProcessed),
+			s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.JobBytesTotal,
+			prometheus.GaugeValue,
+			float64(s.bytesTotal),
+			s.name,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkStorageSpacesCollector(b *testing.B) {
+	benchmarkCollector(b, "storage_spaces", newStorageSpacesCollector)
+}
+
+func TestStorageHealth(t *testing.T) {
+	for status, expected := range map[uint16]string{0: "healthy", 1: "warning", 2: "unhealthy", 5: "unknown"} {
+		if got := storageHealth(status); got != expected {
+			t.Errorf("Expected health %s for status %d, got %s", expected, status, got)
+		}
+	}
+}
+
+func TestStorageJobSummaries(t *testing.T) {
+	jobs := []MSFT_StorageJob{
+		{Name: "Regeneration", JobState: 4, BytesProcessed: 100, BytesTotal: 400},
+		{Name: "Rebalance", JobState: 4, BytesProcessed: 10, BytesTotal: 20},
+		{Name: "Regeneration", JobState: 2, BytesProcessed: 0, BytesTotal: 600},
+		{Name: "Regeneration", JobState: 7, BytesProcessed: 500, BytesTotal: 500},
+	}
+	expected := []storageJobSummary{
+		{name: "Rebalance", jobs: 1, bytesProcessed: 10, bytesTotal: 20},
+		{name: "Regeneration", jobs: 2, bytesProcessed: 100, bytesTotal: 1000},
+	}
+	if got := storageJobSummaries(jobs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Job summaries do not match!\nExpected result: %+v\nActual result: %+v", expected, got)
+	}
+}
@@ -0,0 +1,186 @@
+// +build windows
+
+package collector
+
+import (
+	"unsafe"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+)
+
+func init() {
+	registerCollector("sysmain", NewSysMainCollector, "Process")
+}
+
+var sysmainLogger = log.With("collector", "sysmain")
+
+// A SysMainCollector is a Prometheus collector for the resource usage of the
+// SysMain (Superfetch) service, which prefetches files into the standby list
+type SysMainCollector struct {
+	Running           *prometheus.Desc
+	CPUTimeTotal      *prometheus.Desc
+	WorkingSet        *prometheus.Desc
+	PrivateBytes      *prometheus.Desc
+	IOBytesTotal      *prometheus.Desc
+	IOOperationsTotal *prometheus.Desc
+}
+
+// NewSysMainCollector ...
+func NewSysMainCollector() (Collector, error) {
+	const subsystem = "sysmain"
+
+	return &SysMainCollector{
+		Running: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "running"),
+			"Whether the SysMain service is running (1) or not (0)",
+			nil,
+			nil,
+		),
+		CPUTimeTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "cpu_time_total"),
+			"Total CPU time used by the process hosting the SysMain service",
+			[]string{"mode"},
+			nil,
+		),
+		WorkingSet: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "working_set_bytes"),
+			"Working set of the process hosting the SysMain service",
+			nil,
+			nil,
+		),
+		PrivateBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "private_bytes"),
+			"Private bytes of the process hosting the SysMain service",
+			nil,
+			nil,
+		),
+		IOBytesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_bytes_total"),
+			"Total bytes transferred in I/O operations by the process hosting the SysMain service, including prefetch reads",
+			[]string{"mode"},
+			nil,
+		),
+		IOOperationsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "io_operations_total"),
+			"Total I/O operations issued by the process hosting the SysMain service, including prefetch reads",
+			[]string{"mode"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *SysMainCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ctx, ch); err != nil {
+		sysmainLogger.Error("failed collecting sysmain metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *SysMainCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	pid, err := serviceProcessID("SysMain")
+	// SysMain isn't installed on Server Core.
+	if err != nil && err != windows.ERROR_SERVICE_DOES_NOT_EXIST {
+		return c.Running, err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.Running,
+		prometheus.GaugeValue,
+		boolToFloat(pid != 0),
+	)
+	if pid == 0 {
+		return nil, nil
+	}
+
+	dst := make([]perflibProcess, 0)
+	if err := unmarshalObject(ctx.perfObjects["Process"], &dst, ctx.logger()); err != nil {
+		return nil, err
+	}
+	for _, process := range dst {
+		if uint32(process.IDProcess) != pid {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.CPUTimeTotal,
+			prometheus.CounterValue,
+			process.PercentPrivilegedTime,
+			"privileged",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.CPUTimeTotal,
+			prometheus.CounterValue,
+			process.PercentUserTime,
+			"user",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.WorkingSet,
+			prometheus.GaugeValue,
+			process.WorkingSet,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.PrivateBytes,
+			prometheus.GaugeValue,
+			process.PrivateBytes,
+		)
+		for _, m := range []struct {
+			mode       string
+			bytes, ops float64
+		}{
+			{"read", process.IOReadBytesPerSec, process.IOReadOperationsPerSec},
+			{"write", process.IOWriteBytesPerSec, process.IOWriteOperationsPerSec},
+			{"other", process.IOOtherBytesPerSec, process.IOOtherOperationsPerSec},
+		} {
+			ch <- prometheus.MustNewConstMetric(
+				c.IOBytesTotal,
+				prometheus.CounterValue,
+				m.bytes,
+				m.mode,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.IOOperationsTotal,
+				prometheus.CounterValue,
+				m.ops,
+				m.mode,
+			)
+		}
+		return nil, nil
+	}
+	sysmainLogger.Debugf("SysMain process %d not found in Process counters", pid)
+	return nil, nil
+}
+
+// serviceProcessID returns the ID of the process hosting the service, or 0 if
+// the service isn't running.
+func serviceProcessID(name string) (uint32, error) {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	serviceName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	service, err := windows.OpenService(scm, serviceName, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseServiceHandle(service)
+
+	var status windows.SERVICE_STATUS_PROCESS
+	var needed uint32
+	err = windows.QueryServiceStatusEx(service, windows.SC_STATUS_PROCESS_INFO, (*byte)(unsafe.Pointer(&status)), uint32(unsafe.Sizeof(status)), &needed)
+	if err != nil {
+		return 0, err
+	}
+	if status.CurrentState != windows.SERVICE_RUNNING {
+		return 0, nil
+	}
+	return status.ProcessId, nil
+}
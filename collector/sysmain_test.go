@@ -0,0 +1,9 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkSysMainCollector(b *testing.B) {
+	benchmarkCollector(b, "sysmain", NewSysMainCollector)
+}
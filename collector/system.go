@@ -11,6 +11,8 @@ func init() {
 	registerCollector("system", NewSystemCollector, "System")
 }
 
+var systemLogger = log.With("collector", "system")
+
 // A SystemCollector is a Prometheus collector for WMI metrics
 type SystemCollector struct {
 	ContextSwitchesTotal     *prometheus.Desc
@@ -69,7 +71,7 @@ func NewSystemCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *SystemCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting system metrics:", desc, err)
+		systemLogger.Error("failed collecting system metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -88,7 +90,7 @@ type system struct {
 
 func (c *SystemCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []system
-	if err := unmarshalObject(ctx.perfObjects["System"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["System"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
@@ -11,6 +11,8 @@ func init() {
 	registerCollector("tcp", NewTCPCollector, "TCPv4", "TCPv6")
 }
 
+var tcpLogger = log.With("collector", "tcp")
+
 // A TCPCollector is a Prometheus collector for WMI Win32_PerfRawData_Tcpip_TCPv{4,6} metrics
 type TCPCollector struct {
 	ConnectionFailures         *prometheus.Desc
@@ -31,55 +33,55 @@ func NewTCPCollector() (Collector, error) {
 	return &TCPCollector{
 		ConnectionFailures: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "connection_failures"),
-			"(TCP.ConnectionFailures)",
+			counterHelp("TCPv4", tcp{}, "ConnectionFailures"),
 			[]string{"af"},
 			nil,
 		),
 		ConnectionsActive: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "connections_active"),
-			"(TCP.ConnectionsActive)",
+			counterHelp("TCPv4", tcp{}, "ConnectionsActive"),
 			[]string{"af"},
 			nil,
 		),
 		ConnectionsEstablished: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "connections_established"),
-			"(TCP.ConnectionsEstablished)",
+			counterHelp("TCPv4", tcp{}, "ConnectionsEstablished"),
 			[]string{"af"},
 			nil,
 		),
 		ConnectionsPassive: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "connections_passive"),
-			"(TCP.ConnectionsPassive)",
+			counterHelp("TCPv4", tcp{}, "ConnectionsPassive"),
 			[]string{"af"},
 			nil,
 		),
 		ConnectionsReset: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "connections_reset"),
-			"(TCP.ConnectionsReset)",
+			counterHelp("TCPv4", tcp{}, "ConnectionsReset"),
 			[]string{"af"},
 			nil,
 		),
 		SegmentsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "segments_total"),
-			"(TCP.SegmentsTotal)",
+			counterHelp("TCPv4", tcp{}, "SegmentsPersec"),
 			[]string{"af"},
 			nil,
 		),
 		SegmentsReceivedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "segments_received_total"),
-			"(TCP.SegmentsReceivedTotal)",
+			counterHelp("TCPv4", tcp{}, "SegmentsReceivedPersec"),
 			[]string{"af"},
 			nil,
 		),
 		SegmentsRetransmittedTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "segments_retransmitted_total"),
-			"(TCP.SegmentsRetransmittedTotal)",
+			counterHelp("TCPv4", tcp{}, "SegmentsRetransmittedPersec"),
 			[]string{"af"},
 			nil,
 		),
 		SegmentsSentTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "segments_sent_total"),
-			"(TCP.SegmentsSentTotal)",
+			counterHelp("TCPv4", tcp{}, "SegmentsSentPersec"),
 			[]string{"af"},
 			nil,
 		),
@@ -90,7 +92,7 @@ func NewTCPCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *TCPCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting tcp metrics:", desc, err)
+		tcpLogger.Error("failed collecting tcp metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -172,7 +174,7 @@ func (c *TCPCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric)
 	var dst []tcp
 
 	// TCPv4 counters
-	if err := unmarshalObject(ctx.perfObjects["TCPv4"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["TCPv4"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 	if len(dst) != 0 {
@@ -180,7 +182,7 @@ func (c *TCPCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric)
 	}
 
 	// TCPv6 counters
-	if err := unmarshalObject(ctx.perfObjects["TCPv6"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["TCPv6"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 	if len(dst) != 0 {
@@ -4,19 +4,23 @@ package collector
 
 import (
 	"errors"
+	"strconv"
 	"strings"
 
-	"github.com/StackExchange/wmi"
+	"github.com/prometheus-community/windows_exporter/headers/wtsapi32"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
 )
 
 const ConnectionBrokerFeatureID uint32 = 133
 
 func init() {
-	registerCollector("terminal_services", NewTerminalServicesCollector, "Terminal Services", "Terminal Services Session", "Remote Desktop Connection Broker Counterset")
+	registerCollector("terminal_services", NewTerminalServicesCollector, "Terminal Services", "Terminal Services Session", "Remote Desktop Connection Broker Counterset", "Process")
 }
 
+var terminalServicesLogger = log.With("collector", "terminal_services")
+
 var (
 	connectionBrokerEnabled = isConnectionBrokerServer()
 )
@@ -28,7 +32,7 @@ type Win32_ServerFeature struct {
 func isConnectionBrokerServer() bool {
 	var dst []Win32_ServerFeature
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return false
 	}
 	for _, d := range dst {
@@ -36,7 +40,7 @@ func isConnectionBrokerServer() bool {
 			return true
 		}
 	}
-	log.Debug("host is not a connection broker skipping Connection Broker performance metrics.")
+	terminalServicesLogger.Debug("host is not a connection broker skipping Connection Broker performance metrics.")
 	return false
 }
 
@@ -47,6 +51,8 @@ func isConnectionBrokerServer() bool {
 type TerminalServicesCollector struct {
 	LocalSessionCount           *prometheus.Desc
 	ConnectionBrokerPerformance *prometheus.Desc
+	ConnectionBrokerSessions    *prometheus.Desc
+	ConnectionBrokerDatabaseUp  *prometheus.Desc
 	HandleCount                 *prometheus.Desc
 	PageFaultsPersec            *prometheus.Desc
 	PageFileBytes               *prometheus.Desc
@@ -62,6 +68,17 @@ type TerminalServicesCollector struct {
 	VirtualBytesPeak            *prometheus.Desc
 	WorkingSet                  *prometheus.Desc
 	WorkingSetPeak              *prometheus.Desc
+
+	SessionInfo         *prometheus.Desc
+	SessionProcesses    *prometheus.Desc
+	SessionCPUTime      *prometheus.Desc
+	SessionWorkingSet   *prometheus.Desc
+	SessionPrivateBytes *prometheus.Desc
+	SessionIOBytes      *prometheus.Desc
+	SessionIOOperations *prometheus.Desc
+
+	// exited keeps the counters of the exited processes of each session.
+	exited exitedProcesses
 }
 
 // NewTerminalServicesCollector ...
@@ -80,6 +97,18 @@ func NewTerminalServicesCollector() (Collector, error) {
 			[]string{"connection"},
 			nil,
 		),
+		ConnectionBrokerSessions: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "connection_broker_sessions"),
+			"Number of sessions the Connection Broker tracks on the session host of the collection",
+			[]string{"collection", "server"},
+			nil,
+		),
+		ConnectionBrokerDatabaseUp: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "connection_broker_database_up"),
+			"Whether the session directory kept in the Connection Broker database could be read (1) or not (0)",
+			nil,
+			nil,
+		),
 		HandleCount: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "handle_count"),
 			"Total number of handles currently opened by this process. This number is the sum of the handles currently opened by each thread in this process.",
@@ -170,6 +199,48 @@ func NewTerminalServicesCollector() (Collector, error) {
 			[]string{"session_name"},
 			nil,
 		),
+		SessionInfo: newInfoDesc(
+			subsystem+"_session",
+			"A metric with a constant '1' value labeled with the WinStation name of the session",
+			[]string{"session_id", "user"},
+			"session_name",
+		),
+		SessionProcesses: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_processes"),
+			"Number of processes running in the session",
+			[]string{"session_id", "user"},
+			nil,
+		),
+		SessionCPUTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_cpu_time_seconds_total"),
+			"Total CPU time used by the processes running in the session",
+			[]string{"session_id", "user", "mode"},
+			nil,
+		),
+		SessionWorkingSet: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_working_set_bytes"),
+			"Sum of the working sets of the processes running in the session",
+			[]string{"session_id", "user"},
+			nil,
+		),
+		SessionPrivateBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_private_bytes"),
+			"Sum of the private bytes of the processes running in the session",
+			[]string{"session_id", "user"},
+			nil,
+		),
+		SessionIOBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_io_bytes_total"),
+			"Total bytes transferred in I/O operations by the processes running in the session",
+			[]string{"session_id", "user", "mode"},
+			nil,
+		),
+		SessionIOOperations: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "session_io_operations_total"),
+			"Total I/O operations issued by the processes running in the session",
+			[]string{"session_id", "user", "mode"},
+			nil,
+		),
 	}, nil
 }
 
@@ -177,18 +248,26 @@ func NewTerminalServicesCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *TerminalServicesCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collectTSSessionCount(ctx, ch); err != nil {
-		log.Error("failed collecting terminal services session count metrics:", desc, err)
+		terminalServicesLogger.Error("failed collecting terminal services session count metrics:", desc, err)
 		return err
 	}
 	if desc, err := c.collectTSSessionCounters(ctx, ch); err != nil {
-		log.Error("failed collecting terminal services session count metrics:", desc, err)
+		terminalServicesLogger.Error("failed collecting terminal services session count metrics:", desc, err)
+		return err
+	}
+	if desc, err := c.collectSessionProcesses(ctx, ch); err != nil {
+		terminalServicesLogger.Error("failed collecting terminal services session process metrics:", desc, err)
 		return err
 	}
 
 	// only collect CollectionBrokerPerformance if host is a Connection Broker
 	if connectionBrokerEnabled {
 		if desc, err := c.collectCollectionBrokerPerformanceCounter(ctx, ch); err != nil {
-			log.Error("failed collecting Connection Broker performance metrics:", desc, err)
+			terminalServicesLogger.Error("failed collecting Connection Broker performance metrics:", desc, err)
+			return err
+		}
+		if desc, err := c.collectConnectionBrokerSessions(ctx, ch); err != nil {
+			terminalServicesLogger.Error("failed collecting Connection Broker session metrics:", desc, err)
 			return err
 		}
 	}
@@ -203,7 +282,7 @@ type perflibTerminalServices struct {
 
 func (c *TerminalServicesCollector) collectTSSessionCount(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	dst := make([]perflibTerminalServices, 0)
-	err := unmarshalObject(ctx.perfObjects["Terminal Services"], &dst)
+	err := unmarshalObject(ctx.perfObjects["Terminal Services"], &dst, ctx.logger())
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +335,7 @@ type perflibTerminalServicesSession struct {
 
 func (c *TerminalServicesCollector) collectTSSessionCounters(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	dst := make([]perflibTerminalServicesSession, 0)
-	err := unmarshalObject(ctx.perfObjects["Terminal Services Session"], &dst)
+	err := unmarshalObject(ctx.perfObjects["Terminal Services Session"], &dst, ctx.logger())
 	if err != nil {
 		return nil, err
 	}
@@ -361,6 +440,166 @@ func (c *TerminalServicesCollector) collectTSSessionCounters(ctx *ScrapeContext,
 	return nil, nil
 }
 
+// sessionUsage is the resource usage of the processes running in a session.
+type sessionUsage struct {
+	Processes      float64
+	PrivilegedTime float64
+	UserTime       float64
+	WorkingSet     float64
+	PrivateBytes   float64
+	IOReadBytes    float64
+	IOWriteBytes   float64
+	IOOtherBytes   float64
+	IOReadOps      float64
+	IOWriteOps     float64
+	IOOtherOps     float64
+}
+
+// sessionProcesses groups the processes by session ID. Processes whose
+// session can't be determined, usually because they exited, are skipped.
+func sessionProcesses(processes []perflibProcess, sessionOf func(pid uint32) (uint32, bool)) map[uint32][]perflibProcess {
+	sessions := make(map[uint32][]perflibProcess)
+	for _, p := range processes {
+		if p.Name == "_Total" || p.Name == "Idle" {
+			continue
+		}
+		id, ok := sessionOf(uint32(p.IDProcess))
+		if !ok {
+			continue
+		}
+		sessions[id] = append(sessions[id], p)
+	}
+	return sessions
+}
+
+// aggregateSessionUsage sums the resource usage of the processes of a
+// session. The counters of its exited processes are added, so that they keep
+// increasing when a process exits.
+func aggregateSessionUsage(processes []perflibProcess, exited perflibProcess) sessionUsage {
+	counters := exited
+	u := sessionUsage{Processes: float64(len(processes))}
+	for _, p := range processes {
+		addProcessCounters(&counters, p)
+		u.WorkingSet += p.WorkingSet
+		u.PrivateBytes += p.PrivateBytes
+	}
+	u.PrivilegedTime = counters.PercentPrivilegedTime
+	u.UserTime = counters.PercentUserTime
+	u.IOReadBytes = counters.IOReadBytesPerSec
+	u.IOWriteBytes = counters.IOWriteBytesPerSec
+	u.IOOtherBytes = counters.IOOtherBytesPerSec
+	u.IOReadOps = counters.IOReadOperationsPerSec
+	u.IOWriteOps = counters.IOWriteOperationsPerSec
+	u.IOOtherOps = counters.IOOtherOperationsPerSec
+	return u
+}
+
+// sessionGroup identifies the processes of a session across scrapes. The
+// WinStation name changes when the session disconnects or reconnects, so it
+// isn't part of it.
+func sessionGroup(s wtsapi32.Session) string {
+	return strconv.FormatUint(uint64(s.ID), 10) + "\x00" + s.User
+}
+
+// collectSessionProcesses aggregates the Process counters per session, so the
+// sessions using the most resources on a multi-user host can be told apart
+// without exposing every process.
+func (c *TerminalServicesCollector) collectSessionProcesses(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	dst := make([]perflibProcess, 0)
+	err := unmarshalObject(ctx.perfObjects["Process"], &dst, ctx.logger())
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := wtsapi32.ListSessions()
+	if err != nil {
+		return c.SessionProcesses, err
+	}
+
+	bySession := sessionProcesses(dst, func(pid uint32) (uint32, bool) {
+		var id uint32
+		if err := windows.ProcessIdToSessionId(pid, &id); err != nil {
+			return 0, false
+		}
+		return id, true
+	})
+	// Session IDs are reused, so the exited processes are kept by the
+	// labels of the session's series.
+	groups := make(map[string][]perflibProcess, len(sessions))
+	for _, s := range sessions {
+		if processes, ok := bySession[s.ID]; ok {
+			groups[sessionGroup(s)] = processes
+		}
+	}
+	exited := c.exited.update(groups, dst)
+
+	for _, s := range sessions {
+		group := sessionGroup(s)
+		processes, ok := groups[group]
+		if !ok {
+			continue
+		}
+		u := aggregateSessionUsage(processes, exited[group])
+		labels := []string{strconv.FormatUint(uint64(s.ID), 10), s.User}
+		withMode := func(mode string) []string {
+			return append(labels[:len(labels):len(labels)], mode)
+		}
+
+		ch <- newInfoMetric(c.SessionInfo, append(labels[:len(labels):len(labels)], s.StationName)...)
+		ch <- prometheus.MustNewConstMetric(
+			c.SessionProcesses,
+			prometheus.GaugeValue,
+			u.Processes,
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.SessionCPUTime,
+			prometheus.CounterValue,
+			u.PrivilegedTime,
+			withMode("privileged")...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.SessionCPUTime,
+			prometheus.CounterValue,
+			u.UserTime,
+			withMode("user")...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.SessionWorkingSet,
+			prometheus.GaugeValue,
+			u.WorkingSet,
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.SessionPrivateBytes,
+			prometheus.GaugeValue,
+			u.PrivateBytes,
+			labels...,
+		)
+		for _, m := range []struct {
+			mode       string
+			bytes, ops float64
+		}{
+			{"read", u.IOReadBytes, u.IOReadOps},
+			{"write", u.IOWriteBytes, u.IOWriteOps},
+			{"other", u.IOOtherBytes, u.IOOtherOps},
+		} {
+			ch <- prometheus.MustNewConstMetric(
+				c.SessionIOBytes,
+				prometheus.CounterValue,
+				m.bytes,
+				withMode(m.mode)...,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.SessionIOOperations,
+				prometheus.CounterValue,
+				m.ops,
+				withMode(m.mode)...,
+			)
+		}
+	}
+	return nil, nil
+}
+
 type perflibRemoteDesktopConnectionBrokerCounterset struct {
 	SuccessfulConnections float64 `perflib:"Successful Connections"`
 	PendingConnections    float64 `perflib:"Pending Connections"`
@@ -370,7 +609,7 @@ type perflibRemoteDesktopConnectionBrokerCounterset struct {
 func (c *TerminalServicesCollector) collectCollectionBrokerPerformanceCounter(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 
 	dst := make([]perflibRemoteDesktopConnectionBrokerCounterset, 0)
-	err := unmarshalObject(ctx.perfObjects["Remote Desktop Connection Broker Counterset"], &dst)
+	err := unmarshalObject(ctx.perfObjects["Remote Desktop Connection Broker Counterset"], &dst, ctx.logger())
 	if err != nil {
 		return nil, err
 	}
@@ -401,3 +640,43 @@ func (c *TerminalServicesCollector) collectCollectionBrokerPerformanceCounter(ct
 
 	return nil, nil
 }
+
+// Win32_SessionDirectoryServer is a session host known to the Connection
+// Broker. ClusterName is the name of its collection.
+type Win32_SessionDirectoryServer struct {
+	ServerName       string
+	ClusterName      string
+	NumberOfSessions uint32
+}
+
+func (c *TerminalServicesCollector) collectConnectionBrokerSessions(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []Win32_SessionDirectoryServer
+	q := queryAll(&dst)
+	// The session directory is read from the Connection Broker database, which
+	// is on a remote SQL Server in highly available deployments, so failing to
+	// read it is reported rather than failing the collector.
+	if err := queryWMIContext(ctx.Context(), q, &dst); err != nil {
+		terminalServicesLogger.Warnf("Failed to read the Connection Broker session directory: %v", err)
+		ch <- prometheus.MustNewConstMetric(
+			c.ConnectionBrokerDatabaseUp,
+			prometheus.GaugeValue,
+			0,
+		)
+		return nil, nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.ConnectionBrokerDatabaseUp,
+		prometheus.GaugeValue,
+		1,
+	)
+	for _, server := range dst {
+		ch <- prometheus.MustNewConstMetric(
+			c.ConnectionBrokerSessions,
+			prometheus.GaugeValue,
+			float64(server.NumberOfSessions),
+			server.ClusterName, strings.ToLower(server.ServerName),
+		)
+	}
+	return nil, nil
+}
@@ -4,6 +4,67 @@ import (
 	"testing"
 )
 
+func TestAggregateSessionUsage(t *testing.T) {
+	processes := []perflibProcess{
+		{Name: "_Total", IDProcess: 0, WorkingSet: 1000},
+		{Name: "Idle", IDProcess: 0, PercentPrivilegedTime: 500},
+		{Name: "svchost", IDProcess: 4, WorkingSet: 10, PercentUserTime: 1},
+		{Name: "explorer", IDProcess: 100, WorkingSet: 20, PercentUserTime: 2, IOReadBytesPerSec: 5},
+		{Name: "explorer#1", IDProcess: 200, WorkingSet: 30, PercentUserTime: 3, IOReadBytesPerSec: 7},
+		{Name: "exited", IDProcess: 300, WorkingSet: 40},
+	}
+	sessions := map[uint32]uint32{4: 0, 100: 2, 200: 2}
+	bySession := sessionProcesses(processes, func(pid uint32) (uint32, bool) {
+		id, ok := sessions[pid]
+		return id, ok
+	})
+
+	if len(bySession) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(bySession))
+	}
+	if u := aggregateSessionUsage(bySession[0], perflibProcess{}); u.Processes != 1 || u.WorkingSet != 10 || u.PrivilegedTime != 0 {
+		t.Errorf("unexpected usage of session 0: %+v", u)
+	}
+	if u := aggregateSessionUsage(bySession[2], perflibProcess{}); u.Processes != 2 || u.WorkingSet != 50 || u.UserTime != 5 || u.IOReadBytes != 12 {
+		t.Errorf("unexpected usage of session 2: %+v", u)
+	}
+}
+
+func TestSessionUsageMonotonic(t *testing.T) {
+	var exited exitedProcesses
+	usage := func(processes ...perflibProcess) sessionUsage {
+		e := exited.update(map[string][]perflibProcess{"2": processes}, processes)
+		return aggregateSessionUsage(processes, e["2"])
+	}
+
+	explorer := perflibProcess{Name: "explorer", IDProcess: 100, ElapsedTime: 10, PercentUserTime: 2, IOReadBytesPerSec: 5}
+	notepad := perflibProcess{Name: "notepad", IDProcess: 200, ElapsedTime: 20, PercentUserTime: 3, IOReadBytesPerSec: 7}
+	if u := usage(explorer, notepad); u.UserTime != 5 || u.IOReadBytes != 12 {
+		t.Errorf("unexpected usage with both processes: %+v", u)
+	}
+
+	// The counters of notepad are carried forward after it exited.
+	explorer.PercentUserTime = 4
+	if u := usage(explorer); u.Processes != 1 || u.UserTime != 7 || u.IOReadBytes != 12 {
+		t.Errorf("unexpected usage after notepad exited: %+v", u)
+	}
+
+	// A new process reusing the ID of notepad is another process.
+	reused := perflibProcess{Name: "cmd", IDProcess: 200, ElapsedTime: 30, PercentUserTime: 1}
+	if u := usage(explorer, reused); u.UserTime != 8 || u.IOReadBytes != 12 {
+		t.Errorf("unexpected usage with a reused process ID: %+v", u)
+	}
+	if u := usage(explorer); u.UserTime != 8 {
+		t.Errorf("unexpected usage after the reused process ID exited: %+v", u)
+	}
+
+	// Groups that disappear are forgotten.
+	exited.update(map[string][]perflibProcess{}, nil)
+	if u := usage(explorer); u.UserTime != 4 {
+		t.Errorf("expected the exited processes of a new session to be forgotten, got %+v", u)
+	}
+}
+
 func BenchmarkTerminalServicesCollector(b *testing.B) {
 	benchmarkCollector(b, "terminal_services", NewTerminalServicesCollector)
 }
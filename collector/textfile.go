@@ -57,6 +57,8 @@ func init() {
 	registerCollector("textfile", NewTextFileCollector)
 }
 
+var textfileLogger = log.With("collector", "textfile")
+
 // NewTextFileCollector returns a new Collector exposing metrics read from files
 // in the given textfile directory.
 func NewTextFileCollector() (Collector, error) {
@@ -81,7 +83,7 @@ func convertMetricFamily(metricFamily *dto.MetricFamily, ch chan<- prometheus.Me
 
 	for _, metric := range metricFamily.Metric {
 		if metric.TimestampMs != nil {
-			log.Warnf("Ignoring unsupported custom timestamp on textfile collector metric %v", metric)
+			textfileLogger.Warnf("Ignoring unsupported custom timestamp on textfile collector metric %v", metric)
 		}
 
 		labels := metric.GetLabel()
@@ -151,7 +153,7 @@ func convertMetricFamily(metricFamily *dto.MetricFamily, ch chan<- prometheus.Me
 				buckets, values...,
 			)
 		default:
-			log.Errorf("unknown metric type for file")
+			textfileLogger.Errorf("unknown metric type for file")
 			continue
 		}
 		if metricType == dto.MetricType_GAUGE || metricType == dto.MetricType_COUNTER || metricType == dto.MetricType_UNTYPED {
@@ -219,7 +221,7 @@ func (c *textFileCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Met
 	// Iterate over files and accumulate their metrics.
 	files, err := ioutil.ReadDir(c.path)
 	if err != nil && c.path != "" {
-		log.Errorf("Error reading textfile collector directory %q: %s", c.path, err)
+		textfileLogger.Errorf("Error reading textfile collector directory %q: %s", c.path, err)
 		error = 1.0
 	}
 
@@ -229,34 +231,34 @@ fileLoop:
 			continue
 		}
 		path := filepath.Join(c.path, f.Name())
-		log.Debugf("Processing file %q", path)
+		textfileLogger.Debugf("Processing file %q", path)
 		file, err := os.Open(path)
 		if err != nil {
-			log.Errorf("Error opening %q: %v", path, err)
+			textfileLogger.Errorf("Error opening %q: %v", path, err)
 			error = 1.0
 			continue
 		}
 		var parser expfmt.TextParser
 		r, encoding := utfbom.Skip(carriageReturnFilteringReader{r: file})
 		if err = checkBOM(encoding); err != nil {
-			log.Errorf("Invalid file encoding detected in %s: %s - file must be UTF8", path, err.Error())
+			textfileLogger.Errorf("Invalid file encoding detected in %s: %s - file must be UTF8", path, err.Error())
 			error = 1.0
 			continue
 		}
 		parsedFamilies, err := parser.TextToMetricFamilies(r)
 		closeErr := file.Close()
 		if closeErr != nil {
-			log.Warnf("Error closing file: %v", err)
+			textfileLogger.Warnf("Error closing file: %v", err)
 		}
 		if err != nil {
-			log.Errorf("Error parsing %q: %v", path, err)
+			textfileLogger.Errorf("Error parsing %q: %v", path, err)
 			error = 1.0
 			continue
 		}
 		for _, mf := range parsedFamilies {
 			for _, m := range mf.Metric {
 				if m.TimestampMs != nil {
-					log.Errorf("Textfile %q contains unsupported client-side timestamps, skipping entire file", path)
+					textfileLogger.Errorf("Textfile %q contains unsupported client-side timestamps, skipping entire file", path)
 					error = 1.0
 					continue fileLoop
 				}
@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -10,6 +9,8 @@ func init() {
 	registerCollector("thermalzone", NewThermalZoneCollector)
 }
 
+var thermalzoneLogger = log.With("collector", "thermalzone")
+
 // A thermalZoneCollector is a Prometheus collector for WMI Win32_PerfRawData_Counters_ThermalZoneInformation metrics
 type thermalZoneCollector struct {
 	PercentPassiveLimit *prometheus.Desc
@@ -52,7 +53,7 @@ func NewThermalZoneCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *thermalZoneCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Error("failed collecting thermalzone metrics:", desc, err)
+		thermalzoneLogger.Error("failed collecting thermalzone metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -71,7 +72,7 @@ type Win32_PerfRawData_Counters_ThermalZoneInformation struct {
 func (c *thermalZoneCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_Counters_ThermalZoneInformation
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 
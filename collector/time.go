@@ -13,6 +13,8 @@ func init() {
 	registerCollector("time", newTimeCollector, "Windows Time Service")
 }
 
+var timeLogger = log.With("collector", "time")
+
 // TimeCollector is a Prometheus collector for Perflib counter metrics
 type TimeCollector struct {
 	ClockFrequencyAdjustmentPPBTotal *prometheus.Desc
@@ -74,7 +76,7 @@ func newTimeCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *TimeCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ctx, ch); err != nil {
-		log.Error("failed collecting time metrics:", desc, err)
+		timeLogger.Error("failed collecting time metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -92,7 +94,7 @@ type windowsTime struct {
 
 func (c *TimeCollector) collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []windowsTime // Single-instance class, array is required but will have single entry.
-	if err := unmarshalObject(ctx.perfObjects["Windows Time Service"], &dst); err != nil {
+	if err := unmarshalObject(ctx.perfObjects["Windows Time Service"], &dst, ctx.logger()); err != nil {
 		return nil, err
 	}
 
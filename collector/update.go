@@ -0,0 +1,343 @@
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("update", newUpdateCollector)
+}
+
+var updateLogger = log.With("collector", "update")
+
+var (
+	updateSearchInterval = kingpin.Flag(
+		"collector.update.search-interval",
+		"How often to search for pending updates in the background. A search takes from seconds to minutes.",
+	).Default("1h").Duration()
+	updateSearchOnline = kingpin.Flag(
+		"collector.update.online",
+		"If true, search for pending updates on Windows Update or the configured WSUS server, instead of in the results of the last automatic detection.",
+	).Bool()
+)
+
+const (
+	updatePendingCriteria = "IsInstalled=0 and IsHidden=0"
+	// updateSearchSucceeded and updateSearchSucceededWithErrors are the
+	// OperationResultCode of searches with usable results.
+	updateSearchSucceeded           = 2
+	updateSearchSucceededWithErrors = 3
+)
+
+// updateSeverities are the severity labels, the lower-cased MSRC severities
+// of the updates and unspecified for updates without one, e.g. drivers.
+var updateSeverities = []string{"critical", "important", "moderate", "low", "unspecified"}
+
+// An UpdateCollector is a Prometheus collector for the updates pending on the
+// local computer and the state of the Windows Update Agent
+type UpdateCollector struct {
+	Pending                *prometheus.Desc
+	SearchTime             *prometheus.Desc
+	RebootRequired         *prometheus.Desc
+	LastSearchSuccessTime  *prometheus.Desc
+	LastInstallSuccessTime *prometheus.Desc
+
+	mu     sync.Mutex
+	search *updateSearchResult
+	// done is closed to stop searching for updates.
+	done chan struct{}
+}
+
+func newUpdateCollector() (Collector, error) {
+	const subsystem = "update"
+
+	c := &UpdateCollector{
+		Pending: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "pending"),
+			"Number of applicable updates not installed, as of the last search",
+			[]string{"severity"},
+			nil,
+		),
+		SearchTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "search_timestamp_seconds"),
+			"Time the exporter last searched for pending updates successfully",
+			nil,
+			nil,
+		),
+		RebootRequired: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "reboot_required"),
+			"Whether a reboot is required to complete the installation of updates (1) or not (0)",
+			nil,
+			nil,
+		),
+		LastSearchSuccessTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_automatic_search_success_timestamp_seconds"),
+			"Time Automatic Updates last searched for updates successfully",
+			nil,
+			nil,
+		),
+		LastInstallSuccessTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_install_success_timestamp_seconds"),
+			"Time updates were last installed successfully",
+			nil,
+			nil,
+		),
+	}
+	c.done = make(chan struct{})
+	go c.searchUpdates()
+	return c, nil
+}
+
+// Close stops searching for updates. A search in progress is completed first.
+func (c *UpdateCollector) Close() error {
+	close(c.done)
+	return nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *UpdateCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collectStatus(ch); err != nil {
+		updateLogger.Error("failed collecting update status metrics:", desc, err)
+		return err
+	}
+	c.collectPending(ch)
+	return nil
+}
+
+type updateSearchResult struct {
+	time    time.Time
+	pending map[string]int
+}
+
+// searchUpdates searches for pending updates in the background, as a search
+// takes too long to run on every scrape.
+func (c *UpdateCollector) searchUpdates() {
+	for {
+		pending, err := searchPendingUpdates(flagBool(updateSearchOnline))
+		if err != nil {
+			updateLogger.Warnf("Failed to search for pending updates: %v", err)
+		} else {
+			c.mu.Lock()
+			c.search = &updateSearchResult{time: time.Now(), pending: pending}
+			c.mu.Unlock()
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(flagDuration(updateSearchInterval)):
+		}
+	}
+}
+
+func (c *UpdateCollector) collectPending(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	search := c.search
+	c.mu.Unlock()
+	// Nothing is reported until the first search completed.
+	if search == nil {
+		return
+	}
+
+	for _, severity := range updateSeverities {
+		ch <- prometheus.MustNewConstMetric(
+			c.Pending,
+			prometheus.GaugeValue,
+			float64(search.pending[severity]),
+			severity,
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.SearchTime,
+		prometheus.GaugeValue,
+		float64(search.time.Unix()),
+	)
+}
+
+func (c *UpdateCollector) collectStatus(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	status, err := queryUpdateStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.RebootRequired,
+		prometheus.GaugeValue,
+		boolToFloat(status.rebootRequired),
+	)
+	if !status.lastSearchSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastSearchSuccessTime,
+			prometheus.GaugeValue,
+			float64(status.lastSearchSuccess.Unix()),
+		)
+	}
+	if !status.lastInstallSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.LastInstallSuccessTime,
+			prometheus.GaugeValue,
+			float64(status.lastInstallSuccess.Unix()),
+		)
+	}
+	return nil, nil
+}
+
+// updateSeverity returns the severity label of an MSRC severity.
+func updateSeverity(msrcSeverity string) string {
+	severity := strings.ToLower(msrcSeverity)
+	for _, s := range updateSeverities {
+		if s == severity {
+			return s
+		}
+	}
+	return "unspecified"
+}
+
+// createUpdateObject creates an object of the Windows Update Agent API. COM
+// must be initialized on the locked thread of the caller.
+// https://docs.microsoft.com/en-us/windows/win32/wua_sdk/windows-update-agent-object-model
+func createUpdateObject(progID string) (*ole.IDispatch, error) {
+	unknown, err := oleutil.CreateObject(progID)
+	if err != nil {
+		return nil, err
+	}
+	defer unknown.Release()
+	return unknown.QueryInterface(ole.IID_IDispatch)
+}
+
+// searchPendingUpdates returns the number of pending updates by severity.
+func searchPendingUpdates(online bool) (map[string]int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if code := err.(*ole.OleError).Code(); code != ole.S_OK && code != wmi.S_FALSE {
+			return nil, err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	session, err := createUpdateObject("Microsoft.Update.Session")
+	if err != nil {
+		return nil, err
+	}
+	defer session.Release()
+	searcherRaw, err := oleutil.CallMethod(session, "CreateUpdateSearcher")
+	if err != nil {
+		return nil, err
+	}
+	defer searcherRaw.Clear()
+	searcher := searcherRaw.ToIDispatch()
+	if _, err := oleutil.PutProperty(searcher, "Online", online); err != nil {
+		return nil, err
+	}
+
+	resultRaw, err := oleutil.CallMethod(searcher, "Search", updatePendingCriteria)
+	if err != nil {
+		return nil, err
+	}
+	defer resultRaw.Clear()
+	result := resultRaw.ToIDispatch()
+	resultCode, err := oleutil.GetProperty(result, "ResultCode")
+	if err != nil {
+		return nil, err
+	}
+	defer resultCode.Clear()
+	if code, _ := resultCode.Value().(int32); code != updateSearchSucceeded && code != updateSearchSucceededWithErrors {
+		return nil, fmt.Errorf("search returned result code %d", code)
+	}
+
+	updatesRaw, err := oleutil.GetProperty(result, "Updates")
+	if err != nil {
+		return nil, err
+	}
+	defer updatesRaw.Clear()
+	pending := make(map[string]int)
+	err = oleutil.ForEach(updatesRaw.ToIDispatch(), func(v *ole.VARIANT) error {
+		defer v.Clear()
+		severity, err := oleutil.GetProperty(v.ToIDispatch(), "MsrcSeverity")
+		if err != nil {
+			return err
+		}
+		pending[updateSeverity(severity.ToString())]++
+		severity.Clear()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+type updateStatus struct {
+	rebootRequired bool
+	// The times are zero if there was no such success.
+	lastSearchSuccess  time.Time
+	lastInstallSuccess time.Time
+}
+
+// queryUpdateStatus returns whether a reboot is required and the results of
+// Automatic Updates, which are cheap to query.
+func queryUpdateStatus() (updateStatus, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED); err != nil {
+		if code := err.(*ole.OleError).Code(); code != ole.S_OK && code != wmi.S_FALSE {
+			return updateStatus{}, err
+		}
+	}
+	defer ole.CoUninitialize()
+
+	var status updateStatus
+	systemInfo, err := createUpdateObject("Microsoft.Update.SystemInfo")
+	if err != nil {
+		return updateStatus{}, err
+	}
+	defer systemInfo.Release()
+	rebootRequired, err := oleutil.GetProperty(systemInfo, "RebootRequired")
+	if err != nil {
+		return updateStatus{}, err
+	}
+	status.rebootRequired, _ = rebootRequired.Value().(bool)
+	rebootRequired.Clear()
+
+	autoUpdate, err := createUpdateObject("Microsoft.Update.AutoUpdate")
+	if err != nil {
+		return updateStatus{}, err
+	}
+	defer autoUpdate.Release()
+	resultsRaw, err := oleutil.GetProperty(autoUpdate, "Results")
+	if err != nil {
+		return updateStatus{}, err
+	}
+	defer resultsRaw.Clear()
+	for name, dst := range map[string]*time.Time{
+		"LastSearchSuccessDate":       &status.lastSearchSuccess,
+		"LastInstallationSuccessDate": &status.lastInstallSuccess,
+	} {
+		v, err := oleutil.GetProperty(resultsRaw.ToIDispatch(), name)
+		if err != nil {
+			return updateStatus{}, err
+		}
+		// The dates are in UTC, and empty if there was no success. As for
+		// scheduled tasks, the DATE is decoded from the raw bits.
+		if v.VT == ole.VT_DATE {
+			*dst = oleDateToTime(math.Float64frombits(uint64(v.Val)), time.UTC)
+		}
+		v.Clear()
+	}
+	return status, nil
+}
@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkUpdateCollector(b *testing.B) {
+	benchmarkCollector(b, "update", newUpdateCollector)
+}
+
+func TestUpdateSeverity(t *testing.T) {
+	cases := map[string]string{
+		"Critical":  "critical",
+		"Important": "important",
+		"Low":       "low",
+		"":          "unspecified",
+		"Unknown":   "unspecified",
+	}
+	for msrcSeverity, expected := range cases {
+		if actual := updateSeverity(msrcSeverity); actual != expected {
+			t.Errorf("Severity of %q does not match!\nExpected result: %s\nActual result: %s", msrcSeverity, expected, actual)
+		}
+	}
+}
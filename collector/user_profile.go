@@ -0,0 +1,367 @@
+// +build windows
+
+package collector
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("user_profile", newUserProfileCollector)
+}
+
+var userProfileLogger = log.With("collector", "user_profile")
+
+var (
+	userProfileSizeRefreshInterval = kingpin.Flag(
+		"collector.user_profile.size-refresh-interval",
+		"How often to measure the size of the profiles on disk again.",
+	).Default("1h").Duration()
+	userProfileEventLookback = kingpin.Flag(
+		"collector.user_profile.event-lookback",
+		"How far back to look for logons and logoffs in the User Profile Service event log.",
+	).Default("24h").Duration()
+)
+
+const (
+	profileListKey         = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList`
+	userProfileServiceLog  = "Microsoft-Windows-User Profile Service/Operational"
+	userProfileEventsQuery = "*[System[EventID>=1 and EventID<=4 and TimeCreated[timediff(@SystemTime) <= %d]]]"
+)
+
+// userProfile is a local profile, as listed in the registry.
+type userProfile struct {
+	sid      string
+	user     string
+	path     string
+	loadTime time.Time
+	loaded   bool
+}
+
+// A UserProfileCollector is a Prometheus collector for the local user profiles
+// and the time the User Profile Service took to process logons and logoffs
+type UserProfileCollector struct {
+	SizeBytes       *prometheus.Desc
+	Loaded          *prometheus.Desc
+	LoadTime        *prometheus.Desc
+	ProcessDuration *prometheus.Desc
+
+	mu    sync.Mutex
+	sizes map[string]float64
+	// done is closed to stop measuring the profiles.
+	done chan struct{}
+}
+
+func newUserProfileCollector() (Collector, error) {
+	const subsystem = "user_profile"
+
+	c := &UserProfileCollector{
+		SizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "size_bytes"),
+			"Size of the files in the profile directory, measured every collector.user_profile.size-refresh-interval",
+			[]string{"sid", "user"},
+			nil,
+		),
+		Loaded: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "loaded"),
+			"Whether the profile is currently loaded (1) or not (0)",
+			[]string{"sid", "user"},
+			nil,
+		),
+		LoadTime: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "load_timestamp_seconds"),
+			"Time the profile was last loaded",
+			[]string{"sid", "user"},
+			nil,
+		),
+		ProcessDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_notification_duration_seconds"),
+			"Time the User Profile Service took to process the last logon or logoff of the user",
+			[]string{"sid", "user", "notification"},
+			nil,
+		),
+		sizes: make(map[string]float64),
+		done:  make(chan struct{}),
+	}
+	go c.measureSizes()
+	return c, nil
+}
+
+// measureSizes measures the size of the profiles in the background, as
+// walking large profiles can take longer than a scrape.
+func (c *UserProfileCollector) measureSizes() {
+	for {
+		profiles, err := listUserProfiles()
+		if err != nil {
+			userProfileLogger.Warnf("Failed to list user profiles: %v", err)
+		}
+		sizes := make(map[string]float64, len(profiles))
+		for _, p := range profiles {
+			sizes[p.sid] = directorySize(p.path)
+		}
+		c.mu.Lock()
+		c.sizes = sizes
+		c.mu.Unlock()
+		select {
+		case <-c.done:
+			return
+		case <-time.After(flagDuration(userProfileSizeRefreshInterval)):
+		}
+	}
+}
+
+// directorySize sums the size of the files below dir. Files that can't be
+// accessed are skipped, and junctions such as "Application Data" aren't
+// followed.
+func directorySize(dir string) float64 {
+	var size int64
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return float64(size)
+}
+
+// listUserProfiles returns the profiles of the users who logged on locally,
+// excluding the profiles of the service accounts.
+func listUserProfiles() ([]userProfile, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, profileListKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+	sids, err := k.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []userProfile
+	for _, sid := range sids {
+		switch sid {
+		case "S-1-5-18", "S-1-5-19", "S-1-5-20":
+			continue
+		}
+		pk, err := registry.OpenKey(k, sid, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		p := userProfile{sid: sid, user: lookupSIDAccount(sid)}
+		p.path, _, _ = pk.GetStringValue("ProfileImagePath")
+		// LocalProfileLoadTime is a FILETIME split into two DWORDs, only set
+		// since Windows 8.
+		high, _, errHigh := pk.GetIntegerValue("LocalProfileLoadTimeHigh")
+		low, _, errLow := pk.GetIntegerValue("LocalProfileLoadTimeLow")
+		if errHigh == nil && errLow == nil && (high != 0 || low != 0) {
+			ft := windows.Filetime{HighDateTime: uint32(high), LowDateTime: uint32(low)}
+			p.loadTime = time.Unix(0, ft.Nanoseconds())
+		}
+		pk.Close()
+
+		// A loaded profile's registry hive is mounted below HKEY_USERS.
+		if uk, err := registry.OpenKey(registry.USERS, sid, registry.QUERY_VALUE); err == nil {
+			p.loaded = true
+			uk.Close()
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// sidAccounts caches the accounts resolved by lookupSIDAccount, as resolving
+// domain accounts queries a domain controller.
+var sidAccounts sync.Map
+
+// lookupSIDAccount returns the account of the SID as DOMAIN\user, or an empty
+// string if the SID can't be resolved, e.g. for deleted accounts.
+func lookupSIDAccount(s string) string {
+	if account, ok := sidAccounts.Load(s); ok {
+		return account.(string)
+	}
+	sid, err := windows.StringToSid(s)
+	if err != nil {
+		return ""
+	}
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return ""
+	}
+	if domain != "" {
+		account = domain + `\` + account
+	}
+	sidAccounts.Store(s, account)
+	return account
+}
+
+// Close stops measuring the size of the profiles.
+func (c *UserProfileCollector) Close() error {
+	close(c.done)
+	return nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *UserProfileCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		userProfileLogger.Error("failed collecting user_profile metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *UserProfileCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	profiles, err := listUserProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	sizes := c.sizes
+	c.mu.Unlock()
+
+	users := make(map[string]string, len(profiles))
+	for _, p := range profiles {
+		users[p.sid] = p.user
+		ch <- prometheus.MustNewConstMetric(
+			c.Loaded,
+			prometheus.GaugeValue,
+			boolToFloat(p.loaded),
+			p.sid, p.user,
+		)
+		if !p.loadTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				c.LoadTime,
+				prometheus.GaugeValue,
+				float64(p.loadTime.UnixNano())/1e9,
+				p.sid, p.user,
+			)
+		}
+		// The size is missing until the profile was first measured.
+		if size, ok := sizes[p.sid]; ok {
+			ch <- prometheus.MustNewConstMetric(
+				c.SizeBytes,
+				prometheus.GaugeValue,
+				size,
+				p.sid, p.user,
+			)
+		}
+	}
+
+	// The operational log may be disabled or cleared, which only leaves the
+	// durations out.
+	events, err := wevtapi.Query(userProfileServiceLog, fmt.Sprintf(userProfileEventsQuery, userProfileEventLookback.Milliseconds()))
+	if err != nil {
+		userProfileLogger.Debugf("Failed to query %s events: %v", userProfileServiceLog, err)
+		return nil, nil
+	}
+	var parsed []userProfileEvent
+	for _, e := range events {
+		pe, err := parseUserProfileEvent(e)
+		if err != nil {
+			userProfileLogger.Debugf("Ignoring invalid %s event: %v", userProfileServiceLog, err)
+			continue
+		}
+		parsed = append(parsed, pe)
+	}
+	for key, d := range userProfileNotificationDurations(parsed) {
+		user, ok := users[key.sid]
+		if !ok {
+			user = lookupSIDAccount(key.sid)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ProcessDuration,
+			prometheus.GaugeValue,
+			d.Seconds(),
+			key.sid, user, key.notification,
+		)
+	}
+	return nil, nil
+}
+
+// userProfileEvent is an event of the User Profile Service operational log:
+//
+//	1: Received user logon notification on session N.
+//	2: Finished processing user logon notification on session N.
+//	3: Received user logoff notification on session N.
+//	4: Finished processing user logoff notification on session N.
+type userProfileEvent struct {
+	EventID int `xml:"System>EventID"`
+	Time    struct {
+		SystemTime time.Time `xml:"SystemTime,attr"`
+	} `xml:"System>TimeCreated"`
+	Security struct {
+		UserID string `xml:"UserID,attr"`
+	} `xml:"System>Security"`
+	Data []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+}
+
+func parseUserProfileEvent(s string) (userProfileEvent, error) {
+	var e userProfileEvent
+	err := xml.Unmarshal([]byte(s), &e)
+	return e, err
+}
+
+func (e userProfileEvent) session() string {
+	for _, d := range e.Data {
+		if d.Name == "Session" {
+			return d.Value
+		}
+	}
+	return ""
+}
+
+type userProfileNotificationKey struct {
+	sid          string
+	notification string
+}
+
+// userProfileNotificationDurations pairs the received and finished events of
+// each session, returning the duration of the last logon and logoff processed
+// for each user. events must be ordered oldest first.
+func userProfileNotificationDurations(events []userProfileEvent) map[userProfileNotificationKey]time.Duration {
+	type pending struct {
+		sid, session string
+		notification string
+	}
+	started := make(map[pending]time.Time)
+	durations := make(map[userProfileNotificationKey]time.Duration)
+
+	for _, e := range events {
+		notification := "logon"
+		if e.EventID == 3 || e.EventID == 4 {
+			notification = "logoff"
+		}
+		p := pending{sid: e.Security.UserID, session: e.session(), notification: notification}
+		switch e.EventID {
+		case 1, 3:
+			started[p] = e.Time.SystemTime
+		case 2, 4:
+			start, ok := started[p]
+			if !ok {
+				continue
+			}
+			delete(started, p)
+			durations[userProfileNotificationKey{sid: p.sid, notification: notification}] = e.Time.SystemTime.Sub(start)
+		}
+	}
+	return durations
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func userProfileTestEvent(t *testing.T, id int, sid string, session string, ts string) userProfileEvent {
+	e, err := parseUserProfileEvent(fmt.Sprintf(`<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Microsoft-Windows-User Profiles Service" />
+    <EventID>%d</EventID>
+    <TimeCreated SystemTime="%s" />
+    <Security UserID="%s" />
+  </System>
+  <EventData>
+    <Data Name="Session">%s</Data>
+  </EventData>
+</Event>`, id, ts, sid, session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestUserProfileNotificationDurations(t *testing.T) {
+	const alice, bob = "S-1-5-21-1-2-3-1001", "S-1-5-21-1-2-3-1002"
+	events := []userProfileEvent{
+		userProfileTestEvent(t, 1, alice, "2", "2020-10-01T08:00:00.0000000Z"),
+		userProfileTestEvent(t, 1, bob, "3", "2020-10-01T08:00:01.0000000Z"),
+		userProfileTestEvent(t, 2, alice, "2", "2020-10-01T08:00:04.5000000Z"),
+		userProfileTestEvent(t, 3, alice, "2", "2020-10-01T17:00:00.0000000Z"),
+		userProfileTestEvent(t, 4, alice, "2", "2020-10-01T17:00:02.0000000Z"),
+		userProfileTestEvent(t, 1, alice, "4", "2020-10-02T08:00:00.0000000Z"),
+		userProfileTestEvent(t, 2, alice, "4", "2020-10-02T08:00:30.0000000Z"),
+		// Finished without being received within the lookback.
+		userProfileTestEvent(t, 4, bob, "1", "2020-10-02T09:00:00.0000000Z"),
+	}
+
+	got := userProfileNotificationDurations(events)
+	want := map[userProfileNotificationKey]time.Duration{
+		{sid: alice, notification: "logon"}:  30 * time.Second,
+		{sid: alice, notification: "logoff"}: 2 * time.Second,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, d := range want {
+		if got[k] != d {
+			t.Errorf("%v: got %v, want %v", k, got[k], d)
+		}
+	}
+}
+
+func BenchmarkUserProfileCollector(b *testing.B) {
+	benchmarkCollector(b, "user_profile", newUserProfileCollector)
+}
@@ -0,0 +1,132 @@
+// +build windows
+
+package collector
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/headers/sysinfoapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows/registry"
+)
+
+func init() {
+	registerCollector("virtualization", NewVirtualizationCollector)
+}
+
+var virtualizationLogger = log.With("collector", "virtualization")
+
+// A VirtualizationCollector is a Prometheus collector for the virtualization
+// platform the system runs on
+type VirtualizationCollector struct {
+	Info *prometheus.Desc
+}
+
+// NewVirtualizationCollector ...
+func NewVirtualizationCollector() (Collector, error) {
+	const subsystem = "virtualization"
+
+	return &VirtualizationCollector{
+		Info: newInfoDesc(
+			subsystem,
+			"A metric with a constant '1' value labeled with the hypervisor the system runs on, or physical, and whether hypervisor enlightenments are in use",
+			nil,
+			"hypervisor", "model", "generation", "vm_name", "enlightened",
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *VirtualizationCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		virtualizationLogger.Error("failed collecting virtualization metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+type virtualizationComputerSystem struct {
+	Manufacturer      string
+	Model             string
+	HypervisorPresent bool
+}
+
+// detectHypervisor derives the hypervisor from the SMBIOS system manufacturer
+// and model, which all major hypervisors set to a well-known value.
+func detectHypervisor(manufacturer, model string) string {
+	manufacturer = strings.ToLower(manufacturer)
+	model = strings.ToLower(model)
+	switch {
+	case manufacturer == "microsoft corporation" && strings.Contains(model, "virtual machine"):
+		return "hyperv"
+	case strings.Contains(manufacturer, "vmware") || strings.HasPrefix(model, "vmware"):
+		return "vmware"
+	case strings.Contains(manufacturer, "xen") || strings.Contains(model, "hvm domu"):
+		return "xen"
+	case strings.Contains(manufacturer, "innotek") || strings.Contains(model, "virtualbox"):
+		return "virtualbox"
+	case strings.Contains(manufacturer, "qemu"), strings.Contains(model, "kvm"),
+		strings.HasPrefix(model, "standard pc"), manufacturer == "red hat",
+		manufacturer == "nutanix", manufacturer == "amazon ec2", manufacturer == "google":
+		return "kvm"
+	}
+	return "physical"
+}
+
+func (c *VirtualizationCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	var dst []virtualizationComputerSystem
+	q := queryAllForClass(&dst, "Win32_ComputerSystem")
+	if err := queryWMI(q, &dst); err != nil {
+		return nil, err
+	}
+	if len(dst) == 0 {
+		return nil, errors.New("WMI query returned empty result set")
+	}
+
+	cs := dst[0]
+	hypervisor := detectHypervisor(cs.Manufacturer, cs.Model)
+
+	var generation, vmName string
+	if hypervisor == "hyperv" {
+		// Generation 2 virtual machines boot from UEFI, generation 1 from BIOS.
+		if firmware, err := sysinfoapi.GetFirmwareType(); err != nil {
+			virtualizationLogger.Debugf("Failed to determine firmware type: %v", err)
+		} else if firmware == sysinfoapi.FirmwareTypeUefi {
+			generation = "2"
+		} else {
+			generation = "1"
+		}
+		vmName = hyperVGuestName()
+	}
+
+	ch <- newInfoMetric(
+		c.Info,
+		hypervisor,
+		cs.Model,
+		generation,
+		vmName,
+		strconv.FormatBool(cs.HypervisorPresent),
+	)
+	return nil, nil
+}
+
+// hyperVGuestName returns the name of the virtual machine on its Hyper-V host,
+// as published by the Data Exchange integration service.
+func hyperVGuestName() string {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Virtual Machine\Guest\Parameters`, registry.QUERY_VALUE)
+	if err != nil {
+		virtualizationLogger.Debugf("Failed to open Hyper-V guest parameters: %v", err)
+		return ""
+	}
+	defer k.Close()
+	name, _, err := k.GetStringValue("VirtualMachineName")
+	if err != nil {
+		virtualizationLogger.Debugf("Failed to read Hyper-V virtual machine name: %v", err)
+		return ""
+	}
+	return name
+}
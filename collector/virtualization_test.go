@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestDetectHypervisor(t *testing.T) {
+	cases := []struct {
+		manufacturer, model, expected string
+	}{
+		{"Microsoft Corporation", "Virtual Machine", "hyperv"},
+		{"Microsoft Corporation", "Surface Pro 7", "physical"},
+		{"VMware, Inc.", "VMware7,1", "vmware"},
+		{"QEMU", "Standard PC (Q35 + ICH9, 2009)", "kvm"},
+		{"Amazon EC2", "t3.medium", "kvm"},
+		{"Xen", "HVM domU", "xen"},
+		{"innotek GmbH", "VirtualBox", "virtualbox"},
+		{"Dell Inc.", "PowerEdge R740", "physical"},
+	}
+	for _, c := range cases {
+		if got := detectHypervisor(c.manufacturer, c.model); got != c.expected {
+			t.Errorf("%s %s: expected %s, got %s", c.manufacturer, c.model, c.expected, got)
+		}
+	}
+}
+
+func BenchmarkVirtualizationCollector(b *testing.B) {
+	benchmarkCollector(b, "virtualization", NewVirtualizationCollector)
+}
@@ -5,7 +5,6 @@ package collector
 import (
 	"errors"
 
-	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -14,6 +13,8 @@ func init() {
 	registerCollector("vmware", NewVmwareCollector)
 }
 
+var vmwareLogger = log.With("collector", "vmware")
+
 // A VmwareCollector is a Prometheus collector for WMI Win32_PerfRawData_vmGuestLib_VMem/Win32_PerfRawData_vmGuestLib_VCPU metrics
 type VmwareCollector struct {
 	MemActive      *prometheus.Desc
@@ -44,116 +45,116 @@ func NewVmwareCollector() (Collector, error) {
 	return &VmwareCollector{
 		MemActive: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_active_bytes"),
-			"(MemActiveMB)",
+			"Memory the VM is estimated to be actively using, as seen from the host",
 			nil,
 			nil,
 		),
 		MemBallooned: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_ballooned_bytes"),
-			"(MemBalloonedMB)",
+			"Memory reclaimed from the VM by the balloon driver of VMware Tools, because the host is short of memory",
 			nil,
 			nil,
 		),
 		MemLimit: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_limit_bytes"),
-			"(MemLimitMB)",
+			"Maximum memory the host allows the VM to use",
 			nil,
 			nil,
 		),
 		MemMapped: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_mapped_bytes"),
-			"(MemMappedMB)",
+			"Memory of the VM backed by memory of the host",
 			nil,
 			nil,
 		),
 		MemOverhead: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_overhead_bytes"),
-			"(MemOverheadMB)",
+			"Memory the host uses to run the VM, on top of the memory of the VM",
 			nil,
 			nil,
 		),
 		MemReservation: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_reservation_bytes"),
-			"(MemReservationMB)",
+			"Memory the host guarantees to the VM",
 			nil,
 			nil,
 		),
 		MemShared: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_shared_bytes"),
-			"(MemSharedMB)",
+			"Memory of the VM shared with other VMs through transparent page sharing",
 			nil,
 			nil,
 		),
 		MemSharedSaved: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_shared_saved_bytes"),
-			"(MemSharedSavedMB)",
+			"Memory of the host saved by transparent page sharing",
 			nil,
 			nil,
 		),
 		MemShares: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_shares"),
-			"(MemShares)",
+			"Memory shares of the VM, its priority when VMs contend for the memory of the host",
 			nil,
 			nil,
 		),
 		MemSwapped: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_swapped_bytes"),
-			"(MemSwappedMB)",
+			"Memory of the VM swapped out to disk by the host",
 			nil,
 			nil,
 		),
 		MemTargetSize: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_target_size_bytes"),
-			"(MemTargetSizeMB)",
+			"Memory the host intends to allocate to the VM",
 			nil,
 			nil,
 		),
 		MemUsed: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "mem_used_bytes"),
-			"(MemUsedMB)",
+			"Memory of the host used by the VM",
 			nil,
 			nil,
 		),
 
 		CpuLimitMHz: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cpu_limit_mhz"),
-			"(CpuLimitMHz)",
+			"Maximum processing capacity the host allows the VM to use",
 			nil,
 			nil,
 		),
 		CpuReservationMHz: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cpu_reservation_mhz"),
-			"(CpuReservationMHz)",
+			"Processing capacity the host guarantees to the VM",
 			nil,
 			nil,
 		),
 		CpuShares: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cpu_shares"),
-			"(CpuShares)",
+			"Processor shares of the VM, its priority when VMs contend for the processors of the host",
 			nil,
 			nil,
 		),
 		CpuStolenTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cpu_stolen_seconds_total"),
-			"(CpuStolenMs)",
+			"Time the VM was ready to run but not scheduled by the host, e.g. because of contention or a processor limit",
 			nil,
 			nil,
 		),
 		CpuTimeTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "cpu_time_seconds_total"),
-			"(CpuTimePercents)",
+			"Time the VM ran on the processors of the host",
 			nil,
 			nil,
 		),
 		EffectiveVMSpeedMHz: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "effective_vm_speed_mhz"),
-			"(EffectiveVMSpeedMHz)",
+			"Processing capacity of the host the VM used",
 			nil,
 			nil,
 		),
 		HostProcessorSpeedMHz: prometheus.NewDesc(
 			prometheus.BuildFQName(Namespace, subsystem, "host_processor_speed_mhz"),
-			"(HostProcessorSpeedMHz)",
+			"Speed of the processors of the host",
 			nil,
 			nil,
 		),
@@ -164,11 +165,11 @@ func NewVmwareCollector() (Collector, error) {
 // to the provided prometheus Metric channel.
 func (c *VmwareCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
 	if desc, err := c.collectMem(ch); err != nil {
-		log.Error("failed collecting vmware memory metrics:", desc, err)
+		vmwareLogger.Error("failed collecting vmware memory metrics:", desc, err)
 		return err
 	}
 	if desc, err := c.collectCpu(ch); err != nil {
-		log.Error("failed collecting vmware cpu metrics:", desc, err)
+		vmwareLogger.Error("failed collecting vmware cpu metrics:", desc, err)
 		return err
 	}
 	return nil
@@ -202,7 +203,7 @@ type Win32_PerfRawData_vmGuestLib_VCPU struct {
 func (c *VmwareCollector) collectMem(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_vmGuestLib_VMem
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 	if len(dst) == 0 {
@@ -291,7 +292,7 @@ func mbToBytes(mb uint64) float64 {
 func (c *VmwareCollector) collectCpu(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
 	var dst []Win32_PerfRawData_vmGuestLib_VCPU
 	q := queryAll(&dst)
-	if err := wmi.Query(q, &dst); err != nil {
+	if err := queryWMI(q, &dst); err != nil {
 		return nil, err
 	}
 	if len(dst) == 0 {
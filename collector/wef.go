@@ -0,0 +1,200 @@
+// +build windows
+
+package collector
+
+import (
+	"github.com/prometheus-community/windows_exporter/headers/wecapi"
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("wef", newWEFCollector)
+}
+
+var wefLogger = log.With("collector", "wef")
+
+// wefStatuses are the runtime statuses of subscriptions and event sources.
+var wefStatuses = []string{
+	"disabled",
+	"active",
+	"inactive",
+	"trying",
+	"unknown",
+}
+
+// A WEFCollector is a Prometheus collector for the subscriptions of a Windows
+// Event Collector server and the computers forwarding events to them
+type WEFCollector struct {
+	SubscriptionInfo    *prometheus.Desc
+	SubscriptionEnabled *prometheus.Desc
+	SubscriptionStatus  *prometheus.Desc
+	Sources             *prometheus.Desc
+	SourceLastHeartbeat *prometheus.Desc
+	LogEvents           *prometheus.Desc
+}
+
+func newWEFCollector() (Collector, error) {
+	const subsystem = "wef"
+
+	return &WEFCollector{
+		SubscriptionInfo: newInfoDesc(
+			subsystem+"_subscription",
+			"Type of the subscription and event log its events are forwarded to",
+			[]string{"subscription"},
+			"type", "log",
+		),
+		SubscriptionEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "subscription_enabled"),
+			"Whether the subscription is enabled (1) or not (0)",
+			[]string{"subscription"},
+			nil,
+		),
+		SubscriptionStatus: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "subscription_status"),
+			"Runtime status of the subscription",
+			[]string{"subscription", "status"},
+			nil,
+		),
+		Sources: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "sources"),
+			"Computers forwarding events to the subscription, by runtime status",
+			[]string{"subscription", "status"},
+			nil,
+		),
+		SourceLastHeartbeat: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "source_last_heartbeat_timestamp_seconds"),
+			"Time of the last heartbeat of the computer to the subscription, in seconds since epoch",
+			[]string{"subscription", "source"},
+			nil,
+		),
+		LogEvents: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "log_events_total"),
+			"Events written to the event log subscriptions forward events to, from its last record number",
+			[]string{"log"},
+			nil,
+		),
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *WEFCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		wefLogger.Error("failed collecting wef metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+// wefStatusName returns the name of a runtime status of a subscription or
+// event source.
+func wefStatusName(status uint32) string {
+	if status >= wecapi.StatusDisabled && status <= wecapi.StatusTrying {
+		return wefStatuses[status-1]
+	}
+	return "unknown"
+}
+
+// wefSubscriptionType returns the name of the type of a subscription.
+func wefSubscriptionType(t uint32) string {
+	if t == wecapi.TypeCollectorInitiated {
+		return "collector_initiated"
+	}
+	return "source_initiated"
+}
+
+// wefSourceCounts returns the number of event sources of each status.
+func wefSourceCounts(sources []wecapi.EventSource) map[string]float64 {
+	counts := make(map[string]float64, len(wefStatuses))
+	for _, status := range wefStatuses {
+		counts[status] = 0
+	}
+	for _, source := range sources {
+		counts[wefStatusName(source.Status)]++
+	}
+	return counts
+}
+
+func (c *WEFCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	names, err := wecapi.SubscriptionNames()
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make(map[string]bool)
+	for _, name := range names {
+		s, err := wecapi.QuerySubscription(name)
+		if err != nil {
+			wefLogger.Warnf("Failed to query WEF subscription %s: %v", name, err)
+			continue
+		}
+
+		ch <- newInfoMetric(c.SubscriptionInfo, s.Name, wefSubscriptionType(s.Type), s.LogFile)
+		ch <- prometheus.MustNewConstMetric(
+			c.SubscriptionEnabled,
+			prometheus.GaugeValue,
+			boolToFloat(s.Enabled),
+			s.Name,
+		)
+		status := wefStatusName(s.Status)
+		for _, st := range wefStatuses {
+			isCurrentStatus := 0.0
+			if st == status {
+				isCurrentStatus = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.SubscriptionStatus,
+				prometheus.GaugeValue,
+				isCurrentStatus,
+				s.Name,
+				st,
+			)
+		}
+
+		for st, count := range wefSourceCounts(s.Sources) {
+			ch <- prometheus.MustNewConstMetric(
+				c.Sources,
+				prometheus.GaugeValue,
+				count,
+				s.Name,
+				st,
+			)
+		}
+		for _, source := range s.Sources {
+			// Sources that never sent a heartbeat have no metric.
+			if source.LastHeartbeat.IsZero() {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.SourceLastHeartbeat,
+				prometheus.GaugeValue,
+				float64(source.LastHeartbeat.Unix()),
+				s.Name,
+				source.Name,
+			)
+		}
+
+		if s.LogFile != "" {
+			logs[s.LogFile] = true
+		}
+	}
+
+	// Forwarded events don't record the subscription they came from, so they
+	// are counted by the log they are written to.
+	for name := range logs {
+		id, err := wevtapi.LastRecordID(name)
+		if err != nil {
+			wefLogger.Warnf("Failed to read the last record of event log %s: %v", name, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.LogEvents,
+			prometheus.CounterValue,
+			float64(id),
+			name,
+		)
+	}
+	return nil, nil
+}
@@ -0,0 +1,25 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/headers/wecapi"
+)
+
+func BenchmarkWEFCollector(b *testing.B) {
+	benchmarkCollector(b, "wef", newWEFCollector)
+}
+
+func TestWEFSourceCounts(t *testing.T) {
+	sources := []wecapi.EventSource{
+		{Name: "host1", Status: wecapi.StatusActive},
+		{Name: "host2", Status: wecapi.StatusActive},
+		{Name: "host3", Status: wecapi.StatusInactive},
+		{Name: "host4", Status: 0},
+	}
+	expected := map[string]float64{"disabled": 0, "active": 2, "inactive": 1, "trying": 0, "unknown": 1}
+	if got := wefSourceCounts(sources); !reflect.DeepEqual(got, expected) {
+		t.Errorf("source counts do not match!\nExpected result: %v\nActual result: %v", expected, got)
+	}
+}
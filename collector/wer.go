@@ -0,0 +1,219 @@
+// +build windows
+
+package collector
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/wevtapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("wer", newWERCollector)
+}
+
+var werLogger = log.With("collector", "wer")
+
+const (
+	werApplicationQuery = "*[System[((Provider[@Name='Application Error'] and EventID=1000) or (Provider[@Name='Application Hang'] and EventID=1002)) and EventRecordID>%d]]"
+	werBugcheckQuery    = "*[System[Provider[@Name='Microsoft-Windows-WER-SystemErrorReporting'] and EventID=1001 and EventRecordID>%d]]"
+)
+
+// A WERCollector is a Prometheus collector for the application crashes and
+// hangs and the kernel bugchecks reported to Windows Error Reporting
+type WERCollector struct {
+	ApplicationCrashes    *prometheus.Desc
+	ApplicationHangs      *prometheus.Desc
+	Bugchecks             *prometheus.Desc
+	LastBugcheckInfo      *prometheus.Desc
+	LastBugcheckTimestamp *prometheus.Desc
+
+	mu sync.Mutex
+	// The events are counted incrementally, starting with those still in the
+	// event logs when the collector starts.
+	applicationRecordID uint64
+	bugcheckRecordID    uint64
+	werCounts
+}
+
+type werCounts struct {
+	crashes      map[string]float64
+	hangs        map[string]float64
+	bugchecks    float64
+	lastBugcheck *werEvent
+}
+
+func newWERCollector() (Collector, error) {
+	const subsystem = "wer"
+
+	return &WERCollector{
+		ApplicationCrashes: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "application_crashes_total"),
+			"Total application crashes reported by Windows Error Reporting",
+			[]string{"process"},
+			nil,
+		),
+		ApplicationHangs: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "application_hangs_total"),
+			"Total application hangs reported by Windows Error Reporting",
+			[]string{"process"},
+			nil,
+		),
+		Bugchecks: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "bugchecks_total"),
+			"Total reboots from a kernel bugcheck",
+			nil,
+			nil,
+		),
+		LastBugcheckInfo: newInfoDesc(
+			subsystem+"_last_bugcheck",
+			"A metric with a constant '1' value labeled with the code of the last kernel bugcheck",
+			nil,
+			"code",
+		),
+		LastBugcheckTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "last_bugcheck_timestamp_seconds"),
+			"Time the system rebooted from the last kernel bugcheck",
+			nil,
+			nil,
+		),
+		werCounts: werCounts{
+			crashes: make(map[string]float64),
+			hangs:   make(map[string]float64),
+		},
+	}, nil
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *WERCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		werLogger.Error("failed collecting wer metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *WERCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.update("Application", werApplicationQuery, &c.applicationRecordID); err != nil {
+		return c.ApplicationCrashes, err
+	}
+	if err := c.update("System", werBugcheckQuery, &c.bugcheckRecordID); err != nil {
+		return c.Bugchecks, err
+	}
+
+	for process, n := range c.crashes {
+		ch <- prometheus.MustNewConstMetric(
+			c.ApplicationCrashes,
+			prometheus.CounterValue,
+			n,
+			process,
+		)
+	}
+	for process, n := range c.hangs {
+		ch <- prometheus.MustNewConstMetric(
+			c.ApplicationHangs,
+			prometheus.CounterValue,
+			n,
+			process,
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.Bugchecks,
+		prometheus.CounterValue,
+		c.bugchecks,
+	)
+	if c.lastBugcheck != nil {
+		ch <- newInfoMetric(c.LastBugcheckInfo, c.lastBugcheck.bugcheckCode())
+		ch <- prometheus.MustNewConstMetric(
+			c.LastBugcheckTimestamp,
+			prometheus.GaugeValue,
+			float64(c.lastBugcheck.Time.SystemTime.UnixNano())/1e9,
+		)
+	}
+	return nil, nil
+}
+
+// update counts the events of the log newer than the record ID, and advances
+// it to the newest event.
+func (c *WERCollector) update(channel string, query string, recordID *uint64) error {
+	events, err := wevtapi.Query(channel, fmt.Sprintf(query, *recordID))
+	if err != nil {
+		return err
+	}
+	for _, s := range events {
+		e, err := parseWEREvent(s)
+		if err != nil {
+			werLogger.Debugf("Ignoring invalid %s event: %v", channel, err)
+			continue
+		}
+		c.count(e)
+		if e.RecordID > *recordID {
+			*recordID = e.RecordID
+		}
+	}
+	return nil
+}
+
+// werEvent is an event reported by Windows Error Reporting:
+//
+//	Application Error 1000: an application crashed
+//	Application Hang 1002: an application stopped responding
+//	WER-SystemErrorReporting 1001: the system rebooted from a bugcheck
+type werEvent struct {
+	Provider struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"System>Provider"`
+	EventID  int    `xml:"System>EventID"`
+	RecordID uint64 `xml:"System>EventRecordID"`
+	Time     struct {
+		SystemTime time.Time `xml:"SystemTime,attr"`
+	} `xml:"System>TimeCreated"`
+	Data []string `xml:"EventData>Data"`
+}
+
+func parseWEREvent(s string) (werEvent, error) {
+	var e werEvent
+	err := xml.Unmarshal([]byte(s), &e)
+	return e, err
+}
+
+// bugcheckCode returns the code of a bugcheck event, whose first parameter
+// is formatted as "0x0000009f (0x..., 0x..., 0x..., 0x...)".
+func (e werEvent) bugcheckCode() string {
+	if len(e.Data) == 0 {
+		return ""
+	}
+	if fields := strings.Fields(e.Data[0]); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func (c *werCounts) count(e werEvent) {
+	switch {
+	case e.Provider.Name == "Application Error" && e.EventID == 1000:
+		if len(e.Data) > 0 {
+			c.crashes[e.Data[0]]++
+		}
+	case e.Provider.Name == "Application Hang" && e.EventID == 1002:
+		if len(e.Data) > 0 {
+			c.hangs[e.Data[0]]++
+		}
+	case e.EventID == 1001:
+		c.bugchecks++
+		if c.lastBugcheck == nil || e.Time.SystemTime.After(c.lastBugcheck.Time.SystemTime) {
+			last := e
+			c.lastBugcheck = &last
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+)
+
+func werTestEvent(t *testing.T, provider string, id int, recordID int, data ...string) werEvent {
+	var eventData string
+	for _, d := range data {
+		eventData += "<Data>" + d + "</Data>"
+	}
+	e, err := parseWEREvent(fmt.Sprintf(`<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="%s" />
+    <EventID Qualifiers="0">%d</EventID>
+    <TimeCreated SystemTime="2020-10-01T08:00:%02d.0000000Z" />
+    <EventRecordID>%d</EventRecordID>
+  </System>
+  <EventData>%s</EventData>
+</Event>`, provider, id, recordID, recordID, eventData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestWERCount(t *testing.T) {
+	c := werCounts{
+		crashes: make(map[string]float64),
+		hangs:   make(map[string]float64),
+	}
+	for _, e := range []werEvent{
+		werTestEvent(t, "Application Error", 1000, 1, "explorer.exe", "10.0.19041.546"),
+		werTestEvent(t, "Application Error", 1000, 2, "explorer.exe", "10.0.19041.546"),
+		werTestEvent(t, "Application Hang", 1002, 3, "outlook.exe", "16.0.13231.20262"),
+		werTestEvent(t, "Microsoft-Windows-WER-SystemErrorReporting", 1001, 5, "0x0000009f (0x0000000000000003, 0xffffe001fd3d8060)", `C:\Windows\MEMORY.DMP`),
+		werTestEvent(t, "Microsoft-Windows-WER-SystemErrorReporting", 1001, 4, "0x000000d1 (0x0000000000000000, 0x0000000000000002)", `C:\Windows\MEMORY.DMP`),
+	} {
+		c.count(e)
+	}
+
+	if c.crashes["explorer.exe"] != 2 {
+		t.Errorf("expected 2 explorer.exe crashes, got %v", c.crashes["explorer.exe"])
+	}
+	if c.hangs["outlook.exe"] != 1 {
+		t.Errorf("expected 1 outlook.exe hang, got %v", c.hangs["outlook.exe"])
+	}
+	if c.bugchecks != 2 {
+		t.Errorf("expected 2 bugchecks, got %v", c.bugchecks)
+	}
+	if code := c.lastBugcheck.bugcheckCode(); code != "0x0000009f" {
+		t.Errorf("expected last bugcheck 0x0000009f, got %q", code)
+	}
+}
+
+func BenchmarkWERCollector(b *testing.B) {
+	benchmarkCollector(b, "wer", newWERCollector)
+}
@@ -0,0 +1,211 @@
+// +build windows
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/fwpuclnt"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	registerCollector("wfp", newWFPCollector)
+}
+
+var wfpLogger = log.With("collector", "wfp")
+
+var (
+	wfpFilterWhitelist = kingpin.Flag(
+		"collector.wfp.filter-whitelist",
+		"Regexp of filters to include. Filter name must both match whitelist and not match blacklist to be included.",
+	).Default(".+").String()
+	wfpFilterBlacklist = kingpin.Flag(
+		"collector.wfp.filter-blacklist",
+		"Regexp of filters to exclude. Filter name must both match whitelist and not match blacklist to be included.",
+	).Default("").String()
+	wfpCountPermits = kingpin.Flag(
+		"collector.wfp.count-permits",
+		"If true, count the packets and connections permitted by filters, in addition to those dropped.",
+	).Bool()
+	wfpConfigureEngine = kingpin.Flag(
+		"collector.wfp.configure-engine",
+		"If true, enable the collection of the net events the collector counts, if disabled. The setting is system-wide and persists after the exporter stops.",
+	).Bool()
+)
+
+type wfpFilterCounts struct {
+	permits float64
+	drops   float64
+}
+
+// A WFPCollector is a Prometheus collector for the packets and connections
+// permitted and dropped by the filters of the Windows Filtering Platform,
+// including those of Windows Defender Firewall rules
+type WFPCollector struct {
+	FilterInfo    *prometheus.Desc
+	FilterMatches *prometheus.Desc
+
+	engine           *fwpuclnt.Engine
+	whitelistPattern *regexp.Regexp
+	blacklistPattern *regexp.Regexp
+
+	mu     sync.Mutex
+	counts map[uint64]*wfpFilterCounts
+	// names caches the names of the filters, which don't change.
+	names map[uint64]string
+}
+
+func newWFPCollector() (Collector, error) {
+	const subsystem = "wfp"
+
+	engine, err := fwpuclnt.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err := configureWFPNetEvents(engine); err != nil {
+		engine.Close()
+		return nil, err
+	}
+
+	c := &WFPCollector{
+		FilterInfo: newInfoDesc(
+			subsystem+"_filter",
+			"A metric with a constant '1' value labeled with the name of the filter",
+			[]string{"id"},
+			"filter",
+		),
+		FilterMatches: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "filter_matches_total"),
+			"Total packets or connections permitted or dropped by the filter since the exporter started",
+			[]string{"id", "action"},
+			nil,
+		),
+		engine:           engine,
+		whitelistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *wfpFilterWhitelist)),
+		blacklistPattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *wfpFilterBlacklist)),
+		counts:           make(map[uint64]*wfpFilterCounts),
+		names:            make(map[uint64]string),
+	}
+
+	// WFP doesn't count the matches of filters, so they're counted from the
+	// classify net events, which are only held in memory.
+	if err := engine.SubscribeClassifyEvents(c.count); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close unsubscribes from the net events and closes the session with the
+// filtering engine.
+func (c *WFPCollector) Close() error {
+	return c.engine.Close()
+}
+
+// configureWFPNetEvents checks that the engine collects the net events the
+// collector counts, enabling them if --collector.wfp.configure-engine is set.
+func configureWFPNetEvents(engine *fwpuclnt.Engine) error {
+	collect, err := engine.Option(fwpuclnt.EngineCollectNetEvents)
+	if err != nil {
+		return err
+	}
+	if collect == 0 {
+		if !flagBool(wfpConfigureEngine) {
+			return errors.New("WFP net event collection is disabled, enable it with 'netsh wfp set options netevents=on' or --collector.wfp.configure-engine")
+		}
+		if err := engine.SetOption(fwpuclnt.EngineCollectNetEvents, 1); err != nil {
+			return err
+		}
+		wfpLogger.Info("Enabled the collection of WFP net events")
+	}
+	if !flagBool(wfpCountPermits) {
+		return nil
+	}
+
+	keywords, err := engine.Option(fwpuclnt.EngineNetEventMatchAnyKeywords)
+	if err != nil {
+		return err
+	}
+	if keywords&fwpuclnt.NetEventKeywordClassifyAllow == 0 {
+		if !flagBool(wfpConfigureEngine) {
+			return errors.New("WFP permit net events are disabled, enable them with --collector.wfp.configure-engine")
+		}
+		if err := engine.SetOption(fwpuclnt.EngineNetEventMatchAnyKeywords, keywords|fwpuclnt.NetEventKeywordClassifyAllow); err != nil {
+			return err
+		}
+		wfpLogger.Info("Enabled the collection of WFP permit net events")
+	}
+	return nil
+}
+
+func (c *WFPCollector) count(e fwpuclnt.ClassifyEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts, ok := c.counts[e.FilterID]
+	if !ok {
+		counts = &wfpFilterCounts{}
+		c.counts[e.FilterID] = counts
+	}
+	if e.Allowed {
+		counts.permits++
+	} else {
+		counts.drops++
+	}
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *WFPCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	counts := make(map[uint64]wfpFilterCounts, len(c.counts))
+	for id, n := range c.counts {
+		counts[id] = *n
+	}
+	c.mu.Unlock()
+
+	for id, n := range counts {
+		c.mu.Lock()
+		name, ok := c.names[id]
+		c.mu.Unlock()
+		if !ok {
+			var err error
+			name, err = c.engine.FilterName(id)
+			if err != nil {
+				// The filter was deleted since it matched.
+				wfpLogger.Debugf("Failed to look up WFP filter %d: %v", id, err)
+				continue
+			}
+			c.mu.Lock()
+			c.names[id] = name
+			c.mu.Unlock()
+		}
+		if c.blacklistPattern.MatchString(name) || !c.whitelistPattern.MatchString(name) {
+			continue
+		}
+
+		filterID := strconv.FormatUint(id, 10)
+		ch <- newInfoMetric(c.FilterInfo, filterID, name)
+		ch <- prometheus.MustNewConstMetric(
+			c.FilterMatches,
+			prometheus.CounterValue,
+			n.drops,
+			filterID, "drop",
+		)
+		if flagBool(wfpCountPermits) {
+			ch <- prometheus.MustNewConstMetric(
+				c.FilterMatches,
+				prometheus.CounterValue,
+				n.permits,
+				filterID, "permit",
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package collector
+
+import (
+	"testing"
+)
+
+func BenchmarkWFPCollector(b *testing.B) {
+	benchmarkCollector(b, "wfp", newWFPCollector)
+}
@@ -0,0 +1,270 @@
+// +build windows
+
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/headers/wlanapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+)
+
+func init() {
+	registerCollector("wifi", NewWiFiCollector)
+}
+
+var wifiLogger = log.With("collector", "wifi")
+
+// A WiFiCollector is a Prometheus collector for the connections of wireless
+// network interfaces, queried with the WLAN API
+type WiFiCollector struct {
+	InterfaceInfo  *prometheus.Desc
+	ConnectionInfo *prometheus.Desc
+	Connected      *prometheus.Desc
+	SignalQuality  *prometheus.Desc
+	RSSI           *prometheus.Desc
+	ReceiveRate    *prometheus.Desc
+	TransmitRate   *prometheus.Desc
+	Channel        *prometheus.Desc
+	Roams          *prometheus.Desc
+	Disconnects    *prometheus.Desc
+
+	client *wlanapi.Client
+
+	mu          sync.Mutex
+	roams       map[windows.GUID]float64
+	disconnects map[windows.GUID]float64
+}
+
+// NewWiFiCollector ...
+func NewWiFiCollector() (Collector, error) {
+	const subsystem = "wifi"
+
+	client, err := wlanapi.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &WiFiCollector{
+		InterfaceInfo: newInfoDesc(
+			subsystem+"_interface",
+			"A metric with a constant '1' value labeled with the description of the network adapter of the interface",
+			[]string{"interface"},
+			"description",
+		),
+		ConnectionInfo: newInfoDesc(
+			subsystem+"_connection",
+			"A metric with a constant '1' value labeled with the network the interface is connected to",
+			[]string{"interface"},
+			"ssid", "bssid", "profile", "phy_type",
+		),
+		Connected: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "connected"),
+			"Whether the interface is connected to a wireless network (1) or not (0)",
+			[]string{"interface"},
+			nil,
+		),
+		SignalQuality: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "signal_quality_percent"),
+			"Signal quality of the connection, 0 corresponding to -100 dBm and 100 to -50 dBm",
+			[]string{"interface"},
+			nil,
+		),
+		RSSI: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "rssi_dbm"),
+			"Received signal strength of the connection in dBm",
+			[]string{"interface"},
+			nil,
+		),
+		ReceiveRate: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "receive_rate_bits_per_second"),
+			"PHY rate the interface receives at",
+			[]string{"interface"},
+			nil,
+		),
+		TransmitRate: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "transmit_rate_bits_per_second"),
+			"PHY rate the interface transmits at",
+			[]string{"interface"},
+			nil,
+		),
+		Channel: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "channel"),
+			"Channel the interface is connected on",
+			[]string{"interface"},
+			nil,
+		),
+		Roams: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "roams_total"),
+			"Total roams to another access point since the exporter started",
+			[]string{"interface"},
+			nil,
+		),
+		Disconnects: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, subsystem, "disconnects_total"),
+			"Total disconnections from a wireless network since the exporter started",
+			[]string{"interface"},
+			nil,
+		),
+		client:      client,
+		roams:       make(map[windows.GUID]float64),
+		disconnects: make(map[windows.GUID]float64),
+	}
+
+	// Windows doesn't count roams and disconnections, so they're counted
+	// from the notifications of the media specific module.
+	if err := client.RegisterNotification(wlanapi.NotificationSourceMSM, c.notify); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close unregisters the notifications and closes the session with the WLAN
+// service.
+func (c *WiFiCollector) Close() error {
+	return c.client.Close()
+}
+
+func (c *WiFiCollector) notify(n wlanapi.Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch n.Code {
+	case wlanapi.NotificationMSMRoamingEnd:
+		c.roams[n.Interface]++
+	case wlanapi.NotificationMSMDisconnected:
+		c.disconnects[n.Interface]++
+	}
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *WiFiCollector) Collect(ctx *ScrapeContext, ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		wifiLogger.Error("failed collecting wifi metrics:", desc, err)
+		return err
+	}
+	return nil
+}
+
+func (c *WiFiCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	interfaces, err := c.client.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range interfaces {
+		// The description of the adapter changes with its driver, so the
+		// series are keyed by the GUID of the interface.
+		guid := iface.GUID.String()
+		ch <- newInfoMetric(c.InterfaceInfo, guid, iface.Description)
+
+		c.mu.Lock()
+		roams, disconnects := c.roams[iface.GUID], c.disconnects[iface.GUID]
+		c.mu.Unlock()
+		ch <- prometheus.MustNewConstMetric(
+			c.Roams,
+			prometheus.CounterValue,
+			roams,
+			guid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.Disconnects,
+			prometheus.CounterValue,
+			disconnects,
+			guid,
+		)
+
+		connected := iface.State == wlanapi.InterfaceStateConnected
+		ch <- prometheus.MustNewConstMetric(
+			c.Connected,
+			prometheus.GaugeValue,
+			boolToFloat(connected),
+			guid,
+		)
+		if !connected {
+			continue
+		}
+
+		conn, err := c.client.CurrentConnection(iface.GUID)
+		if err != nil {
+			// The interface may have disconnected since it was enumerated.
+			wifiLogger.Debugf("Failed to query connection of %s: %v", iface.Description, err)
+			continue
+		}
+		ch <- newInfoMetric(
+			c.ConnectionInfo,
+			guid,
+			conn.SSID, conn.BSSID.String(), conn.ProfileName, wifiPhyTypeName(conn.PhyType),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.SignalQuality,
+			prometheus.GaugeValue,
+			float64(conn.SignalQuality),
+			guid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.ReceiveRate,
+			prometheus.GaugeValue,
+			float64(conn.RxRateKbps)*1000,
+			guid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.TransmitRate,
+			prometheus.GaugeValue,
+			float64(conn.TxRateKbps)*1000,
+			guid,
+		)
+
+		if channel, err := c.client.Channel(iface.GUID); err == nil {
+			ch <- prometheus.MustNewConstMetric(
+				c.Channel,
+				prometheus.GaugeValue,
+				float64(channel),
+				guid,
+			)
+		}
+		// Not all drivers report the RSSI.
+		if rssi, err := c.client.RSSI(iface.GUID); err == nil {
+			ch <- prometheus.MustNewConstMetric(
+				c.RSSI,
+				prometheus.GaugeValue,
+				float64(rssi),
+				guid,
+			)
+		}
+	}
+	return nil, nil
+}
+
+// wifiPhyTypeName returns the IEEE 802.11 amendment of a DOT11_PHY_TYPE.
+// https://docs.microsoft.com/en-us/windows/win32/nativewifi/dot11-phy-type
+func wifiPhyTypeName(phyType uint32) string {
+	switch phyType {
+	case 1:
+		return "802.11 FHSS"
+	case 2:
+		return "802.11 DSSS"
+	case 3:
+		return "802.11 IR"
+	case 4:
+		return "802.11a"
+	case 5:
+		return "802.11b"
+	case 6:
+		return "802.11g"
+	case 7:
+		return "802.11n"
+	case 8:
+		return "802.11ac"
+	case 9:
+		return "802.11ad"
+	case 10:
+		return "802.11ax"
+	case 11:
+		return "802.11be"
+	}
+	return "unknown"
+}
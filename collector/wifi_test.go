@@ -0,0 +1,22 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestWiFiPhyTypeName(t *testing.T) {
+	for phyType, want := range map[uint32]string{
+		0:  "unknown",
+		7:  "802.11n",
+		10: "802.11ax",
+		99: "unknown",
+	} {
+		if got := wifiPhyTypeName(phyType); got != want {
+			t.Errorf("wifiPhyTypeName(%d) = %q, want %q", phyType, got, want)
+		}
+	}
+}
+
+func BenchmarkWiFiCollector(b *testing.B) {
+	benchmarkCollector(b, "wifi", NewWiFiCollector)
+}
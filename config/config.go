@@ -14,8 +14,14 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/prometheus-community/windows_exporter/log"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -28,7 +34,75 @@ type getFlagger interface {
 
 // Resolver represents a configuration file resolver for kingpin.
 type Resolver struct {
-	flags map[string]string
+	flags       map[string]string
+	remoteHosts []RemoteHost
+	endpoints   []Endpoint
+	labelValues LabelValues
+	access      []CollectorAccess
+	// secrets holds the names of the flags whose value was resolved from a
+	// secret reference.
+	secrets map[string]bool
+}
+
+// RemoteHost is an entry of the remote_hosts section, describing a computer whose
+// performance counters are collected in addition to those of the local machine.
+type RemoteHost struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Collectors is a comma-separated list of collectors to run against the host.
+	// If empty, all enabled collectors supporting remote collection are used.
+	Collectors string `yaml:"collectors"`
+	// Authentication is either "negotiate", the default, or "kerberos" to
+	// check that a Kerberos ticket for SPN can be obtained before every
+	// collection. The connection itself always negotiates.
+	Authentication string `yaml:"authentication"`
+	// SPN defaults to HOST/<host>.
+	SPN string `yaml:"spn"`
+}
+
+// Endpoint is an entry of the endpoints section, describing an additional
+// listener of the exporter serving a subset of the enabled collectors.
+type Endpoint struct {
+	ListenAddress string `yaml:"listen_address"`
+	// MetricsPath defaults to /metrics.
+	MetricsPath string `yaml:"metrics_path"`
+	// Collectors is a comma-separated list of the enabled collectors served
+	// by the endpoint. If empty, all enabled collectors are served.
+	Collectors string `yaml:"collectors"`
+	// WebConfigFile is a web config for TLS and authentication. If empty, the
+	// endpoint is served over plain HTTP without authentication.
+	WebConfigFile string `yaml:"web_config_file"`
+	// AllowedCIDRs is a comma-separated list of CIDRs or IP addresses
+	// allowed to connect. If empty, all addresses are allowed.
+	AllowedCIDRs string `yaml:"allowed_cidrs"`
+	// ClientAllowedNames is a comma-separated list of the subject common
+	// names or SANs of the verified client certificates allowed to connect.
+	// If empty, those of --web.client-cert.allowed-names are allowed.
+	ClientAllowedNames string `yaml:"client_allowed_names"`
+}
+
+// CollectorAccess is an entry of the collector_access section, restricting
+// the collectors served to authenticated clients.
+type CollectorAccess struct {
+	// Identities are basic auth user names, or subject common names or SANs
+	// of verified client certificates.
+	Identities []string `yaml:"identities"`
+	// Collectors is a comma-separated list of the collectors served to the
+	// identities. If empty, all enabled collectors are served.
+	Collectors string `yaml:"collectors"`
+}
+
+// LabelValues is the label_values section, restricting the values of a label
+// of a collector, indexed by collector and label name, to those listed.
+type LabelValues map[string]map[string][]string
+
+// sections holds the parts of the configuration file that can't be expressed as flags.
+type sections struct {
+	RemoteHosts     []RemoteHost      `yaml:"remote_hosts"`
+	Endpoints       []Endpoint        `yaml:"endpoints"`
+	LabelValues     LabelValues       `yaml:"label_values"`
+	CollectorAccess []CollectorAccess `yaml:"collector_access"`
 }
 
 // NewResolver returns a Resolver structure.
@@ -55,7 +129,206 @@ func NewResolver(file string) (*Resolver, error) {
 			flags[k] = v
 		}
 	}
-	return &Resolver{flags: flags}, nil
+
+	var s sections
+	err = yaml.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range s.RemoteHosts {
+		if h.Host == "" {
+			return nil, fmt.Errorf("remote_hosts: entry without host")
+		}
+		if h.Authentication != "" && h.Authentication != "negotiate" && h.Authentication != "kerberos" {
+			return nil, fmt.Errorf("remote_hosts: %s: unknown authentication %q", h.Host, h.Authentication)
+		}
+	}
+	listenAddresses := make(map[string]bool)
+	for i := range s.Endpoints {
+		e := &s.Endpoints[i]
+		if e.ListenAddress == "" {
+			return nil, fmt.Errorf("endpoints: entry without listen_address")
+		}
+		if listenAddresses[e.ListenAddress] {
+			return nil, fmt.Errorf("endpoints: %s: duplicate listen_address", e.ListenAddress)
+		}
+		listenAddresses[e.ListenAddress] = true
+		if e.MetricsPath == "" {
+			e.MetricsPath = "/metrics"
+		}
+	}
+	for collector, labels := range s.LabelValues {
+		for label, values := range labels {
+			if len(values) == 0 {
+				return nil, fmt.Errorf("label_values: %s: %s: no values listed", collector, label)
+			}
+		}
+	}
+	identities := make(map[string]bool)
+	for i, a := range s.CollectorAccess {
+		if len(a.Identities) == 0 {
+			return nil, fmt.Errorf("collector_access: entry %d without identities", i)
+		}
+		for _, id := range a.Identities {
+			if id == "" {
+				return nil, fmt.Errorf("collector_access: entry %d with an empty identity", i)
+			}
+			if identities[strings.ToLower(id)] {
+				return nil, fmt.Errorf("collector_access: %s: identity listed more than once", id)
+			}
+			identities[strings.ToLower(id)] = true
+		}
+	}
+	secrets, err := resolveSecrets(flags, s.RemoteHosts)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{flags: flags, remoteHosts: s.RemoteHosts, endpoints: s.Endpoints, labelValues: s.LabelValues, access: s.CollectorAccess, secrets: secrets}, nil
+}
+
+// RemoteHosts returns the remote hosts listed in the configuration file.
+func (c *Resolver) RemoteHosts() []RemoteHost {
+	return c.remoteHosts
+}
+
+// Endpoints returns the additional endpoints listed in the configuration file.
+func (c *Resolver) Endpoints() []Endpoint {
+	return c.endpoints
+}
+
+// CollectorAccess returns the collector_access section of the configuration file.
+func (c *Resolver) CollectorAccess() []CollectorAccess {
+	return c.access
+}
+
+// LabelValues returns the label values allowed by the configuration file.
+func (c *Resolver) LabelValues() LabelValues {
+	return c.labelValues
+}
+
+// Value returns the value the configuration file sets for the flag name.
+func (c *Resolver) Value(name string) (string, bool) {
+	v, ok := c.flags[name]
+	return v, ok
+}
+
+// Secret reports whether the value of the flag name is a secret, which must
+// not be logged: either it was resolved from a secret reference, or the name
+// of the flag suggests a password, token or key.
+func (c *Resolver) Secret(name string) bool {
+	if c.secrets[name] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownFlags returns the sorted names of the settings of the configuration
+// file that are neither in a section nor a flag of app or of the command
+// selected by args, which are otherwise ignored.
+func (c *Resolver) UnknownFlags(app *kingpin.Application, args []string) ([]string, error) {
+	pc, err := app.ParseContext(args)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(sections{})
+	var unknown []string
+names:
+	for name := range c.flags {
+		for i := 0; i < t.NumField(); i++ {
+			section := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == section || strings.HasPrefix(name, section+".") {
+				continue names
+			}
+		}
+		if app.GetFlag(name) != nil {
+			continue
+		}
+		if pc.SelectedCommand != nil && pc.SelectedCommand.GetFlag(name) != nil {
+			continue
+		}
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// ChangedFlags returns the sorted names of the flags whose value differs
+// between c and other, including those only set by one of them.
+func (c *Resolver) ChangedFlags(other *Resolver) []string {
+	var changed []string
+	for name, v := range c.flags {
+		if ov, ok := other.flags[name]; !ok || ov != v {
+			changed = append(changed, name)
+		}
+	}
+	for name := range other.flags {
+		if _, ok := c.flags[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// RemoteHostsChanged reports whether the remote_hosts section differs between c and other.
+func (c *Resolver) RemoteHostsChanged(other *Resolver) bool {
+	return !reflect.DeepEqual(c.remoteHosts, other.remoteHosts)
+}
+
+// EndpointsChanged reports whether the endpoints section differs between c and other.
+func (c *Resolver) EndpointsChanged(other *Resolver) bool {
+	return !reflect.DeepEqual(c.endpoints, other.endpoints)
+}
+
+// CollectorAccessChanged reports whether the collector_access section differs between c and other.
+func (c *Resolver) CollectorAccessChanged(other *Resolver) bool {
+	return !reflect.DeepEqual(c.access, other.access)
+}
+
+// LabelValuesChanged reports whether the label_values section differs between c and other.
+func (c *Resolver) LabelValuesChanged(other *Resolver) bool {
+	return !reflect.DeepEqual(c.labelValues, other.labelValues)
+}
+
+// Watch checks file for changes every interval until stop is closed, and
+// calls onChange with a Resolver for its new content. Content that fails to
+// load is logged and otherwise ignored.
+func Watch(file string, interval time.Duration, stop <-chan struct{}, onChange func(*Resolver)) {
+	last, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Warnf("Couldn't read configuration file %s: %v", file, err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Warnf("Couldn't read configuration file %s: %v", file, err)
+			continue
+		}
+		if bytes.Equal(b, last) {
+			continue
+		}
+		last = b
+		resolver, err := NewResolver(file)
+		if err != nil {
+			log.Errorf("Ignoring invalid configuration file %s: %v", file, err)
+			continue
+		}
+		onChange(resolver)
+	}
 }
 
 func (c *Resolver) setDefault(v getFlagger) {
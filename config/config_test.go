@@ -0,0 +1,395 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestRemoteHosts(t *testing.T) {
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`---
+collectors:
+  enabled: cpu,net
+remote_hosts:
+  - host: edge01
+  - host: edge02
+    username: MONITOR\svc
+    password: secret
+    collectors: cpu
+    authentication: kerberos
+    spn: HOST/edge02.example.com
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	resolver, err := NewResolver(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RemoteHost{
+		{Host: "edge01"},
+		{Host: "edge02", Username: `MONITOR\svc`, Password: "secret", Collectors: "cpu", Authentication: "kerberos", SPN: "HOST/edge02.example.com"},
+	}
+	if !reflect.DeepEqual(resolver.RemoteHosts(), expected) {
+		t.Errorf("Remote hosts do not match!\nExpected result: %+v\nActual result: %+v", expected, resolver.RemoteHosts())
+	}
+	if resolver.flags["collectors.enabled"] != "cpu,net" {
+		t.Errorf("Expected collectors.enabled to be %q, got %q", "cpu,net", resolver.flags["collectors.enabled"])
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`---
+endpoints:
+  - listen_address: 127.0.0.1:9183
+  - listen_address: :9184
+    metrics_path: /minimal
+    collectors: cpu,memory
+    web_config_file: C:\exporter\web.yml
+    allowed_cidrs: 10.0.0.0/8
+    client_allowed_names: prometheus-a,prometheus-b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	resolver, err := NewResolver(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Endpoint{
+		{ListenAddress: "127.0.0.1:9183", MetricsPath: "/metrics"},
+		{ListenAddress: ":9184", MetricsPath: "/minimal", Collectors: "cpu,memory", WebConfigFile: `C:\exporter\web.yml`, AllowedCIDRs: "10.0.0.0/8", ClientAllowedNames: "prometheus-a,prometheus-b"},
+	}
+	if !reflect.DeepEqual(resolver.Endpoints(), expected) {
+		t.Errorf("Endpoints do not match!\nExpected result: %+v\nActual result: %+v", expected, resolver.Endpoints())
+	}
+}
+
+func TestDuplicateEndpoint(t *testing.T) {
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`---
+endpoints:
+  - listen_address: :9183
+  - listen_address: :9183
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := NewResolver(f.Name()); err == nil {
+		t.Error("Expected an error for a duplicate listen_address")
+	}
+}
+
+func TestLabelValues(t *testing.T) {
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`---
+label_values:
+  process:
+    process: [sqlservr, w3wp]
+  msmq:
+    name:
+      - private$\orders
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	resolver, err := NewResolver(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := LabelValues{
+		"process": {"process": {"sqlservr", "w3wp"}},
+		"msmq":    {"name": {`private$\orders`}},
+	}
+	if !reflect.DeepEqual(resolver.LabelValues(), expected) {
+		t.Errorf("Label values do not match!\nExpected result: %+v\nActual result: %+v", expected, resolver.LabelValues())
+	}
+
+	writeConfig(t, f.Name(), `---
+label_values:
+  process:
+    process: []
+`)
+	if _, err := NewResolver(f.Name()); err == nil {
+		t.Error("Expected an error for a label without values")
+	}
+}
+
+func TestCollectorAccess(t *testing.T) {
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	writeConfig(t, f.Name(), `---
+collector_access:
+  - identities: [tenant-prometheus]
+    collectors: cpu,memory
+  - identities: [platform-prometheus, prometheus.example.com]
+`)
+	resolver, err := NewResolver(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CollectorAccess{
+		{Identities: []string{"tenant-prometheus"}, Collectors: "cpu,memory"},
+		{Identities: []string{"platform-prometheus", "prometheus.example.com"}},
+	}
+	if !reflect.DeepEqual(resolver.CollectorAccess(), expected) {
+		t.Errorf("Collector access does not match!\nExpected result: %+v\nActual result: %+v", expected, resolver.CollectorAccess())
+	}
+
+	writeConfig(t, f.Name(), `---
+collector_access:
+  - identities: [tenant-prometheus]
+    collectors: cpu
+  - identities: [Tenant-Prometheus]
+`)
+	if _, err := NewResolver(f.Name()); err == nil {
+		t.Error("Expected an error for an identity listed twice")
+	}
+
+	writeConfig(t, f.Name(), `---
+collector_access:
+  - collectors: cpu
+`)
+	if _, err := NewResolver(f.Name()); err == nil {
+		t.Error("Expected an error for an entry without identities")
+	}
+}
+
+func writeConfig(t *testing.T, path string, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangedFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/config.yml"
+
+	writeConfig(t, path, `---
+collectors:
+  enabled: cpu,service
+collector:
+  service:
+    services-where: Name='windows_exporter'
+log:
+  level: info
+`)
+	old, err := NewResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, path, `---
+collectors:
+  enabled: cpu,service
+collector:
+  service:
+    services-where: Name='w32time'
+  process:
+    whitelist: sql.*
+`)
+	updated, err := NewResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"collector.process.whitelist", "collector.service.services-where", "log.level"}
+	if changed := old.ChangedFlags(updated); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Changed flags do not match!\nExpected result: %v\nActual result: %v", expected, changed)
+	}
+	if v, ok := updated.Value("collector.service.services-where"); !ok || v != "Name='w32time'" {
+		t.Errorf("Unexpected value %q for collector.service.services-where", v)
+	}
+	if _, ok := updated.Value("log.level"); ok {
+		t.Errorf("Expected log.level to be unset")
+	}
+}
+
+func TestUnknownFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/config.yml"
+
+	writeConfig(t, path, `---
+collectors:
+  enabled: cpu,service
+collector:
+  service:
+    services-where: Name='windows_exporter'
+    servces-where: Name='w32time'
+iterations: 5
+timeout: 1m
+endpoints:
+  - listen_address: ":9183"
+label_values:
+  service:
+    name: [w32time]
+`)
+	resolver, err := NewResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := kingpin.New("windows_exporter", "")
+	app.Flag("collectors.enabled", "").String()
+	app.Flag("collector.service.services-where", "").String()
+	app.Command("serve", "").Default()
+	bench := app.Command("bench", "")
+	bench.Flag("iterations", "").Int()
+
+	expected := []string{"collector.service.servces-where", "timeout"}
+	unknown, err := resolver.UnknownFlags(app, []string{"bench"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("Unknown flags do not match!\nExpected result: %v\nActual result: %v", expected, unknown)
+	}
+
+	expected = []string{"collector.service.servces-where", "iterations", "timeout"}
+	unknown, err = resolver.UnknownFlags(app, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("Unknown flags of the default command do not match!\nExpected result: %v\nActual result: %v", expected, unknown)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/config.yml"
+	writeConfig(t, path, "collectors:\n  enabled: cpu\n")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := make(chan *Resolver, 1)
+	go Watch(path, 10*time.Millisecond, stop, func(r *Resolver) {
+		changes <- r
+	})
+
+	// Let the watcher read the initial content before changing it.
+	time.Sleep(50 * time.Millisecond)
+	writeConfig(t, path, "collectors:\n  enabled: cpu,net\n")
+
+	select {
+	case r := <-changes:
+		if v, _ := r.Value("collectors.enabled"); v != "cpu,net" {
+			t.Errorf("Expected collectors.enabled to be %q, got %q", "cpu,net", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Change to configuration file was not detected")
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	secretSchemes["test"] = func(ref string) (secret, error) {
+		return secret{username: "svc_" + ref, value: "secret-" + ref}, nil
+	}
+	defer delete(secretSchemes, "test")
+
+	f, err := ioutil.TempFile("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`---
+collector:
+  mssql:
+    password: test://sql
+  textfile:
+    directory: file://C:/textfile
+remote_hosts:
+  - host: edge01
+    password: test://edge01
+  - host: edge02
+    username: EXAMPLE\monitor
+    password: test://edge02
+  - host: edge03
+    username: test://edge03-user
+    password: secret
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	resolver, err := NewResolver(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := resolver.Value("collector.mssql.password"); v != "secret-sql" {
+		t.Errorf("Expected secret reference to be resolved, got %q", v)
+	}
+	if v, _ := resolver.Value("collector.textfile.directory"); v != "file://C:/textfile" {
+		t.Errorf("Expected value with unknown scheme to be unchanged, got %q", v)
+	}
+	for name, expected := range map[string]bool{
+		"collector.mssql.password":     true,
+		"collector.textfile.directory": false,
+		"collector.vmware.api-token":   true,
+	} {
+		if secret := resolver.Secret(name); secret != expected {
+			t.Errorf("Secret(%q) do not match!\nExpected result: %v\nActual result: %v", name, expected, secret)
+		}
+	}
+	expected := []RemoteHost{
+		{Host: "edge01", Username: "svc_edge01", Password: "secret-edge01"},
+		{Host: "edge02", Username: `EXAMPLE\monitor`, Password: "secret-edge02"},
+		{Host: "edge03", Username: "svc_edge03-user", Password: "secret"},
+	}
+	if !reflect.DeepEqual(resolver.RemoteHosts(), expected) {
+		t.Errorf("Remote hosts do not match!\nExpected result: %+v\nActual result: %+v", expected, resolver.RemoteHosts())
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// secret is the result of resolving a secret reference. Some stores, like the
+// Credential Manager, also provide the user the secret belongs to.
+type secret struct {
+	username string
+	value    string
+}
+
+// secretSchemes maps the scheme of a secret reference, e.g. "cred" for
+// cred://target, to the function resolving the rest of the reference.
+var secretSchemes = map[string]func(ref string) (secret, error){}
+
+// resolveSecret resolves value if it is a reference to a secret, and returns
+// it unchanged otherwise.
+func resolveSecret(value string) (secret, bool, error) {
+	i := strings.Index(value, "://")
+	if i < 0 {
+		return secret{value: value}, false, nil
+	}
+	resolve, ok := secretSchemes[value[:i]]
+	if !ok {
+		return secret{value: value}, false, nil
+	}
+	s, err := resolve(value[i+3:])
+	if err != nil {
+		return secret{}, true, fmt.Errorf("couldn't resolve secret %s: %v", value, err)
+	}
+	return s, true, nil
+}
+
+// ResolveSecret resolves value if it is a reference to a secret, and returns
+// it unchanged otherwise. It serves settings read from other files than the
+// configuration file, e.g. the queries of the odbc collector.
+func ResolveSecret(value string) (string, error) {
+	s, _, err := resolveSecret(value)
+	return s.value, err
+}
+
+// sensitiveNameParts are parts of flag names whose values are secrets even if
+// they are not given as a reference, e.g. collector.mssql.password.
+var sensitiveNameParts = []string{"password", "secret", "token", "key"}
+
+// resolveSecrets replaces the secret references in flags and remote hosts
+// with the secrets they refer to, and returns the names of the flags that
+// were references.
+func resolveSecrets(flags map[string]string, hosts []RemoteHost) (map[string]bool, error) {
+	resolved := make(map[string]bool)
+	for name, v := range flags {
+		s, isRef, err := resolveSecret(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		flags[name] = s.value
+		if isRef {
+			resolved[name] = true
+		}
+	}
+	for i := range hosts {
+		h := &hosts[i]
+		// A reference given as the username resolves to the user name of
+		// the secret if the store provides one, and to its value otherwise.
+		u, _, err := resolveSecret(h.Username)
+		if err != nil {
+			return nil, fmt.Errorf("remote_hosts: %s: username: %v", h.Host, err)
+		}
+		h.Username = u.value
+		if u.username != "" {
+			h.Username = u.username
+		}
+		s, isRef, err := resolveSecret(h.Password)
+		if err != nil {
+			return nil, fmt.Errorf("remote_hosts: %s: %v", h.Host, err)
+		}
+		h.Password = s.value
+		if isRef && h.Username == "" {
+			h.Username = s.username
+		}
+	}
+	return resolved, nil
+}
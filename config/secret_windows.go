@@ -0,0 +1,53 @@
+package config
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/prometheus-community/windows_exporter/headers/dpapi"
+	"github.com/prometheus-community/windows_exporter/headers/wincred"
+)
+
+func init() {
+	secretSchemes["cred"] = resolveCredential
+	secretSchemes["dpapi"] = resolveProtectedFile
+}
+
+// resolveCredential reads cred://<target> from the Credential Manager of the
+// account the exporter runs as.
+func resolveCredential(target string) (secret, error) {
+	c, err := wincred.Read(target)
+	if err != nil {
+		return secret{}, err
+	}
+	return secret{username: c.UserName, value: c.Password}, nil
+}
+
+// resolveProtectedFile decrypts dpapi://<path>. The file either holds the
+// output of PowerShell's ConvertFrom-SecureString, or the raw output of
+// ProtectedData.Protect over UTF-8 text.
+func resolveProtectedFile(path string) (secret, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return secret{}, err
+	}
+	if decoded, err := hex.DecodeString(strings.TrimSpace(string(b))); err == nil {
+		plain, err := dpapi.Unprotect(decoded)
+		if err != nil {
+			return secret{}, err
+		}
+		// SecureStrings are UTF-16.
+		u := make([]uint16, len(plain)/2)
+		for i := range u {
+			u[i] = uint16(plain[2*i]) | uint16(plain[2*i+1])<<8
+		}
+		return secret{value: string(utf16.Decode(u))}, nil
+	}
+	plain, err := dpapi.Unprotect(b)
+	if err != nil {
+		return secret{}, err
+	}
+	return secret{value: string(plain)}, nil
+}
@@ -0,0 +1,135 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// configReport holds the problems found by --config.check. Errors would stop
+// the exporter or leave settings ignored, while warnings are only logged.
+type configReport struct {
+	errors   []string
+	warnings []string
+}
+
+func (r *configReport) errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *configReport) warnf(format string, args ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// write writes the problems of the configuration file, returning whether it
+// has no errors.
+func (r *configReport) write(w io.Writer, file string) bool {
+	for _, e := range r.errors {
+		fmt.Fprintf(w, "error: %s\n", e)
+	}
+	for _, warning := range r.warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	if len(r.errors) == 0 && len(r.warnings) == 0 {
+		fmt.Fprintf(w, "%s is valid\n", file)
+		return true
+	}
+	fmt.Fprintf(w, "%s: %d errors, %d warnings\n", file, len(r.errors), len(r.warnings))
+	return len(r.errors) == 0
+}
+
+// checkSettings is what --config.check validates besides the configuration
+// file itself: the flags it sets, as the exporter would start with them.
+type checkSettings struct {
+	enabled            string
+	collectorTimeouts  string
+	onDemandCollectors string
+	webConfigFile      string
+}
+
+// runConfigCheck checks the configuration file and the collectors it enables
+// as the exporter would at startup, rather than at the first scrape, and
+// writes a report. It returns whether the exporter would start.
+func runConfigCheck(file string, resolver *config.Resolver, app *kingpin.Application, args []string, s checkSettings, w io.Writer) bool {
+	var report configReport
+
+	unknown, err := resolver.UnknownFlags(app, args)
+	if err != nil {
+		report.errorf("%v", err)
+	}
+	for _, name := range unknown {
+		report.errorf("unknown setting %s", name)
+	}
+
+	names := expandEnabledCollectors(s.enabled)
+	sort.Strings(names)
+	collectors := make(map[string]collector.Collector, len(names))
+	for _, name := range names {
+		c, err := collector.Build(name)
+		if err != nil {
+			report.errorf("collector %s: %v", name, err)
+			continue
+		}
+		collectors[name] = c
+	}
+	// Collectors reading ETW events started their sessions.
+	defer etw.CloseAll()
+
+	built := keys(collectors)
+	sort.Strings(built)
+	missing, err := collector.MissingPerfObjects(built)
+	if err != nil {
+		report.errorf("failed to read the performance counters of the enabled collectors: %v", err)
+	}
+	for _, name := range built {
+		for _, object := range missing[name] {
+			report.warnf("collector %s: performance object %q not found on this host", name, object)
+		}
+	}
+
+	timeouts, err := parseCollectorTimeouts(s.collectorTimeouts)
+	if err != nil {
+		report.errorf("invalid scrape.collector-timeouts: %v", err)
+	}
+	for name := range timeouts {
+		if _, ok := collectors[name]; !ok {
+			report.warnf("scrape.collector-timeouts: collector %s isn't enabled", name)
+		}
+	}
+
+	if _, err := parseOnDemandCollectors(s.onDemandCollectors, collectors); err != nil {
+		report.errorf("invalid collectors.on-demand: %v", err)
+	}
+
+	if s.webConfigFile != "" {
+		if _, err := os.Stat(s.webConfigFile); err != nil {
+			report.errorf("web.config.file: %v", err)
+		}
+	}
+	for _, e := range resolver.Endpoints() {
+		if _, err := newEndpointHandler(e, metricsHandler{}, collectors); err != nil {
+			report.errorf("endpoints: %s: %v", e.ListenAddress, err)
+		}
+		if e.WebConfigFile != "" {
+			if _, err := os.Stat(e.WebConfigFile); err != nil {
+				report.errorf("endpoints: %s: web_config_file: %v", e.ListenAddress, err)
+			}
+		}
+	}
+	if _, err := newCollectorAccess(resolver.CollectorAccess(), collectors); err != nil {
+		report.errorf("collector_access: %v", err)
+	}
+	if _, err := loadRemoteHosts(resolver.RemoteHosts(), collectors); err != nil {
+		report.errorf("remote_hosts: %v", err)
+	}
+
+	return report.write(w, file)
+}
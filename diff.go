@@ -0,0 +1,206 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricSchema is a metric name with the names of its labels, e.g.
+// windows_cpu_time_total{core,mode}. Metrics are compared by their schema, as
+// label values, e.g. of processes, change between scrapes.
+type metricSchema struct {
+	name   string
+	labels []string
+	// series holds the label values of the metric's series, to match
+	// renamed metrics by.
+	series map[string]bool
+}
+
+func (s *metricSchema) String() string {
+	return s.name + "{" + strings.Join(s.labels, ",") + "}"
+}
+
+// metricSchemaDiff is the result of comparing the metrics of two versions.
+type metricSchemaDiff struct {
+	removed []*metricSchema
+	added   []*metricSchema
+	// renamed holds pairs of the baseline and the current schema.
+	renamed [][2]*metricSchema
+}
+
+func (d metricSchemaDiff) empty() bool {
+	return len(d.removed) == 0 && len(d.added) == 0 && len(d.renamed) == 0
+}
+
+// metricSchemasOf returns the schemas of the metrics of the families whose
+// name starts with prefix, indexed by their string.
+func metricSchemasOf(families []*dto.MetricFamily, prefix string) map[string]*metricSchema {
+	schemas := make(map[string]*metricSchema)
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), prefix) {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			pairs := append([]*dto.LabelPair{}, m.GetLabel()...)
+			sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+			schema := &metricSchema{name: mf.GetName()}
+			values := make([]string, 0, len(pairs))
+			for _, p := range pairs {
+				schema.labels = append(schema.labels, p.GetName())
+				values = append(values, fmt.Sprintf("%q", p.GetValue()))
+			}
+			if existing, ok := schemas[schema.String()]; ok {
+				schema = existing
+			} else {
+				schema.series = make(map[string]bool)
+				schemas[schema.String()] = schema
+			}
+			schema.series[strings.Join(values, ",")] = true
+		}
+	}
+	return schemas
+}
+
+// diffMetricSchemas compares the metrics of a baseline to the current ones. A
+// removed and an added metric are reported as renamed if they're the only
+// ones with the same name, i.e. their labels changed, or the only ones with
+// the same labels and series.
+func diffMetricSchemas(baseline, current map[string]*metricSchema) metricSchemaDiff {
+	var removed, added []*metricSchema
+	for key, s := range baseline {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, s)
+		}
+	}
+	for key, s := range current {
+		if _, ok := baseline[key]; !ok {
+			added = append(added, s)
+		}
+	}
+
+	var diff metricSchemaDiff
+	sameName := func(a, b *metricSchema) bool { return a.name == b.name }
+	sameSeries := func(a, b *metricSchema) bool {
+		if strings.Join(a.labels, ",") != strings.Join(b.labels, ",") || len(a.series) != len(b.series) {
+			return false
+		}
+		for s := range a.series {
+			if !b.series[s] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, match := range []func(a, b *metricSchema) bool{sameName, sameSeries} {
+		removed, added = pairUnique(removed, added, match, &diff.renamed)
+	}
+	diff.removed, diff.added = removed, added
+
+	sort.Slice(diff.removed, func(i, j int) bool { return diff.removed[i].String() < diff.removed[j].String() })
+	sort.Slice(diff.added, func(i, j int) bool { return diff.added[i].String() < diff.added[j].String() })
+	sort.Slice(diff.renamed, func(i, j int) bool { return diff.renamed[i][0].String() < diff.renamed[j][0].String() })
+	return diff
+}
+
+// pairUnique appends the removed and added schemas that only match each other
+// to pairs, and returns the remaining ones.
+func pairUnique(removed, added []*metricSchema, match func(a, b *metricSchema) bool, pairs *[][2]*metricSchema) ([]*metricSchema, []*metricSchema) {
+	matches := func(s *metricSchema, candidates []*metricSchema, swap bool) []*metricSchema {
+		var result []*metricSchema
+		for _, c := range candidates {
+			if (!swap && match(s, c)) || (swap && match(c, s)) {
+				result = append(result, c)
+			}
+		}
+		return result
+	}
+
+	paired := make(map[*metricSchema]bool)
+	for _, r := range removed {
+		m := matches(r, added, false)
+		if len(m) != 1 || len(matches(m[0], removed, true)) != 1 {
+			continue
+		}
+		*pairs = append(*pairs, [2]*metricSchema{r, m[0]})
+		paired[r], paired[m[0]] = true, true
+	}
+
+	var remainingRemoved, remainingAdded []*metricSchema
+	for _, r := range removed {
+		if !paired[r] {
+			remainingRemoved = append(remainingRemoved, r)
+		}
+	}
+	for _, a := range added {
+		if !paired[a] {
+			remainingAdded = append(remainingAdded, a)
+		}
+	}
+	return remainingRemoved, remainingAdded
+}
+
+func writeMetricSchemaDiff(w io.Writer, diff metricSchemaDiff) {
+	for _, s := range diff.removed {
+		fmt.Fprintf(w, "- %s\n", s)
+	}
+	for _, s := range diff.added {
+		fmt.Fprintf(w, "+ %s\n", s)
+	}
+	for _, p := range diff.renamed {
+		fmt.Fprintf(w, "~ %s -> %s\n", p[0], p[1])
+	}
+	fmt.Fprintf(w, "%d removed, %d added, %d renamed\n", len(diff.removed), len(diff.added), len(diff.renamed))
+}
+
+// runDiff collects the enabled collectors once and writes how their metrics
+// differ from those in the baseline file, a scrape in the text format, e.g.
+// of the previous version of the exporter. It returns whether they differ.
+func runDiff(enabled string, baselineFile string, timeout time.Duration, w io.Writer) (bool, error) {
+	f, err := os.Open(baselineFile)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var parser expfmt.TextParser
+	baselineFamilies, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		return false, fmt.Errorf("couldn't parse baseline %s: %v", baselineFile, err)
+	}
+	baseline := make([]*dto.MetricFamily, 0, len(baselineFamilies))
+	for _, mf := range baselineFamilies {
+		baseline = append(baseline, mf)
+	}
+
+	collectors, err := loadCollectors(enabled)
+	if err != nil {
+		return false, err
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&windowsCollector{
+		collectors:        collectors,
+		maxScrapeDuration: timeout,
+	})
+	current, err := reg.Gather()
+	if err != nil {
+		log.Warnf("Error gathering metrics: %v", err)
+	}
+
+	// Metrics of the Go runtime and the process change with the Go version
+	// and aren't compared.
+	prefix := collector.Namespace + "_"
+	diff := diffMetricSchemas(metricSchemasOf(baseline, prefix), metricSchemasOf(current, prefix))
+	writeMetricSchemaDiff(w, diff)
+	return !diff.empty(), nil
+}
@@ -3,11 +3,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/exec"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,10 +19,14 @@ import (
 	"time"
 
 	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
 
 	"github.com/StackExchange/wmi"
 	"github.com/prometheus-community/windows_exporter/collector"
 	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus-community/windows_exporter/headers/etw"
+	"github.com/prometheus-community/windows_exporter/headers/mpr"
+	"github.com/prometheus-community/windows_exporter/headers/sspi"
 	"github.com/prometheus-community/windows_exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -31,6 +39,35 @@ import (
 type windowsCollector struct {
 	maxScrapeDuration time.Duration
 	collectors        map[string]collector.Collector
+	// remote is set if the collectors should run against a remote host.
+	remote *remoteHost
+	// sampleTimestamps is set if the metrics of collectors reading
+	// performance counters should carry the time the counters were sampled.
+	sampleTimestamps bool
+	// perfStats, if set, receives the outcome of every scrape.
+	perfStats *perfCounterStats
+	// collectorTimeouts holds the collectors' own timeouts, shorter than
+	// maxScrapeDuration.
+	collectorTimeouts map[string]time.Duration
+	// requestContext, if set, is the context of the scrape request, whose
+	// cancellation cancels the collectors.
+	requestContext context.Context
+	// workers, if set, bounds the collectors running at once across all
+	// scrapes. A collector runs while it holds a slot of the channel.
+	workers chan struct{}
+}
+
+// remoteHost is a computer whose performance counters are collected alongside
+// those of the local machine. Its series are labeled with source=<host>.
+type remoteHost struct {
+	config     config.RemoteHost
+	collectors map[string]collector.Collector
+
+	mu sync.Mutex
+	// connected is set once a session to the host's IPC$ share is
+	// established with the configured credentials, and reset when a scrape
+	// of the host fails, so that the session is only established again then.
+	connected bool
 }
 
 // Same struct prometheus uses for their /version endpoint.
@@ -69,6 +106,12 @@ var (
 		[]string{"collector"},
 		nil,
 	)
+	scrapeSampleTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(collector.Namespace, "exporter", "collector_sample_timestamp_seconds"),
+		"windows_exporter: Time the performance counters read by the collector were sampled.",
+		[]string{"collector"},
+		nil,
+	)
 	snapshotDuration = prometheus.NewDesc(
 		prometheus.BuildFQName(collector.Namespace, "exporter", "perflib_snapshot_duration_seconds"),
 		"Duration of perflib snapshot capture",
@@ -100,13 +143,38 @@ func (coll windowsCollector) Collect(ch chan<- prometheus.Metric) {
 	for name := range coll.collectors {
 		cs = append(cs, name)
 	}
-	scrapeContext, err := collector.PrepareScrapeContext(cs)
+	ctx := coll.requestContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, coll.maxScrapeDuration)
+	defer cancel()
+
+	var scrapeContext *collector.ScrapeContext
+	var err error
+	if coll.remote != nil {
+		scrapeContext, err = coll.remote.prepareScrapeContext(ctx, cs)
+	} else {
+		scrapeContext, err = collector.PrepareScrapeContext(cs)
+	}
 	ch <- prometheus.MustNewConstMetric(
 		snapshotDuration,
 		prometheus.GaugeValue,
 		time.Since(t).Seconds(),
 	)
 	if err != nil {
+		if coll.perfStats != nil {
+			coll.perfStats.observe(perfTotalInstance, failed, time.Since(t))
+		}
+		if coll.remote != nil {
+			// An unreachable remote host mustn't fail the scrape of the
+			// local machine and the other hosts.
+			log.Warnf("Failed to prepare scrape of %s: %v", coll.remote.config.Host, err)
+			for name := range coll.collectors {
+				ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 0, name)
+			}
+			return
+		}
 		ch <- prometheus.NewInvalidMetric(scrapeSuccessDesc, fmt.Errorf("failed to prepare scrape: %v", err))
 		return
 	}
@@ -118,53 +186,105 @@ func (coll windowsCollector) Collect(ch chan<- prometheus.Metric) {
 		collectorOutcomes[name] = pending
 	}
 
-	metricsBuffer := make(chan prometheus.Metric)
 	l := sync.Mutex{}
 	finished := false
-	go func() {
-		for m := range metricsBuffer {
-			l.Lock()
-			if !finished {
-				ch <- m
-			}
-			l.Unlock()
+	for name, c := range coll.collectors {
+		collectorCtx, cancelCollector := ctx, context.CancelFunc(func() {})
+		if timeout, ok := coll.collectorTimeouts[name]; ok {
+			collectorCtx, cancelCollector = context.WithTimeout(ctx, timeout)
 		}
-	}()
 
-	for name, c := range coll.collectors {
+		// A collector is settled once it finished or its deadline expired,
+		// whichever comes first. The metrics it sends afterwards are
+		// discarded.
+		var settle sync.Once
+		expired := false
+		// A collector holds its worker slot until it's settled, so that
+		// collectors overrunning their deadline, e.g. stuck in a WMI query,
+		// don't starve the collectors of later scrapes. held is closed once
+		// the slot is taken.
+		var release sync.Once
+		held := make(chan struct{})
+		releaseWorker := func() { <-coll.workers }
+		out := make(chan prometheus.Metric)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			for m := range out {
+				l.Lock()
+				if !finished && !expired {
+					ch <- m
+				}
+				l.Unlock()
+			}
+		}()
 		go func(name string, c collector.Collector) {
-			defer wg.Done()
-			outcome := execute(name, c, scrapeContext, metricsBuffer)
-			l.Lock()
-			if !finished {
-				collectorOutcomes[name] = outcome
+			defer cancelCollector()
+			if coll.workers != nil {
+				select {
+				case coll.workers <- struct{}{}:
+					close(held)
+					defer release.Do(releaseWorker)
+				case <-collectorCtx.Done():
+				}
+				if collectorCtx.Err() != nil {
+					// The collector reached its deadline waiting
+					// for a worker, and is reported as timed out.
+					close(out)
+					return
+				}
 			}
-			l.Unlock()
+			start := time.Now()
+			outcome := execute(name, c, scrapeContext.WithContext(collectorCtx).WithLogger(log.With("collector", name)), out, coll.sampleTimestamps)
+			close(out)
+			<-forwarded
+			settle.Do(func() {
+				l.Lock()
+				if !finished {
+					collectorOutcomes[name] = outcome
+					if coll.perfStats != nil {
+						coll.perfStats.observe(name, outcome, time.Since(start))
+					}
+				}
+				l.Unlock()
+				wg.Done()
+			})
 		}(name, c)
+		go func() {
+			<-collectorCtx.Done()
+			settle.Do(func() {
+				l.Lock()
+				expired = true
+				l.Unlock()
+				wg.Done()
+			})
+			select {
+			case <-held:
+				release.Do(releaseWorker)
+			default:
+			}
+		}()
 	}
 
-	allDone := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(allDone)
-		close(metricsBuffer)
-	}()
-
-	// Wait until either all collectors finish, or timeout expires
-	select {
-	case <-allDone:
-	case <-time.After(coll.maxScrapeDuration):
-	}
+	// Wait until all collectors finished or reached their deadline
+	wg.Wait()
 
 	l.Lock()
 	finished = true
 
 	remainingCollectorNames := make([]string, 0)
+	scrapeOutcome := success
 	for name, outcome := range collectorOutcomes {
 		var successValue, timeoutValue float64
 		if outcome == pending {
 			timeoutValue = 1.0
 			remainingCollectorNames = append(remainingCollectorNames, name)
+			if coll.perfStats != nil {
+				coll.perfStats.observe(name, pending, time.Since(t))
+			}
+		}
+		if outcome != success && scrapeOutcome != pending {
+			scrapeOutcome = outcome
 		}
 		if outcome == success {
 			successValue = 1.0
@@ -187,13 +307,30 @@ func (coll windowsCollector) Collect(ch chan<- prometheus.Metric) {
 	if len(remainingCollectorNames) > 0 {
 		log.Warn("Collection timed out, still waiting for ", remainingCollectorNames)
 	}
+	if coll.perfStats != nil {
+		coll.perfStats.observe(perfTotalInstance, scrapeOutcome, time.Since(t))
+	}
 
 	l.Unlock()
 }
 
-func execute(name string, c collector.Collector, ctx *collector.ScrapeContext, ch chan<- prometheus.Metric) collectorOutcome {
+func execute(name string, c collector.Collector, ctx *collector.ScrapeContext, ch chan<- prometheus.Metric, sampleTimestamps bool) collectorOutcome {
 	t := time.Now()
-	err := c.Collect(ctx, ch)
+	out := ch
+	flush := func() {}
+	if sampleTime, ok := ctx.SampleTime(name); ok {
+		ch <- prometheus.MustNewConstMetric(
+			scrapeSampleTimeDesc,
+			prometheus.GaugeValue,
+			float64(sampleTime.UnixNano())/1e9,
+			name,
+		)
+		if sampleTimestamps {
+			out, flush = withTimestamp(ch, sampleTime)
+		}
+	}
+	err := c.Collect(ctx, out)
+	flush()
 	duration := time.Since(t).Seconds()
 	ch <- prometheus.MustNewConstMetric(
 		scrapeDurationDesc,
@@ -203,13 +340,81 @@ func execute(name string, c collector.Collector, ctx *collector.ScrapeContext, c
 	)
 
 	if err != nil {
-		log.Errorf("collector %s failed after %fs: %s", name, duration, err)
+		log.With("collector", name).Errorf("collector %s failed after %fs: %s", name, duration, err)
 		return failed
 	}
-	log.Debugf("collector %s succeeded after %fs.", name, duration)
+	log.With("collector", name).Debugf("collector %s succeeded after %fs.", name, duration)
 	return success
 }
 
+// withTimestamp returns a channel forwarding metrics to ch with the timestamp
+// t, and a function to call once all metrics were sent.
+func withTimestamp(ch chan<- prometheus.Metric, t time.Time) (chan<- prometheus.Metric, func()) {
+	timestamped := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range timestamped {
+			ch <- prometheus.NewMetricWithTimestamp(t, m)
+		}
+		close(done)
+	}()
+	return timestamped, func() {
+		close(timestamped)
+		<-done
+	}
+}
+
+// parseCollectorTimeouts parses a comma-separated list of collector=timeout
+// pairs, e.g. ad=5s,mssql=10s.
+func parseCollectorTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid collector timeout %q, must be collector=timeout", pair)
+		}
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q of collector %s", parts[1], parts[0])
+		}
+		timeouts[parts[0]] = timeout
+	}
+	return timeouts, nil
+}
+
+// parseOnDemandCollectors parses a comma-separated list of collectors only
+// scraped when requested, which must be enabled.
+func parseOnDemandCollectors(s string, enabled map[string]collector.Collector) (map[string]bool, error) {
+	onDemand := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := enabled[name]; !ok {
+			return nil, fmt.Errorf("collector %s isn't enabled", name)
+		}
+		onDemand[name] = true
+	}
+	return onDemand, nil
+}
+
+// withoutOnDemand returns the collectors scraped when none is requested with
+// collect[].
+func withoutOnDemand(names []string, onDemand map[string]bool) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if !onDemand[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
 func expandEnabledCollectors(enabled string) []string {
 	expanded := strings.Replace(enabled, defaultCollectorsPlaceholder, defaultCollectors, -1)
 	separated := strings.Split(expanded, ",")
@@ -241,6 +446,102 @@ func loadCollectors(list string) (map[string]collector.Collector, error) {
 	return collectors, nil
 }
 
+func loadRemoteHosts(hosts []config.RemoteHost, local map[string]collector.Collector) ([]*remoteHost, error) {
+	result := make([]*remoteHost, 0, len(hosts))
+	for _, h := range hosts {
+		names := keys(local)
+		if h.Collectors != "" {
+			names = expandEnabledCollectors(h.Collectors)
+		}
+
+		collectors := map[string]collector.Collector{}
+		for _, name := range names {
+			if !collector.SupportsRemote(name) {
+				if h.Collectors != "" {
+					log.Warnf("Collector %s doesn't support remote hosts, not collecting it from %s", name, h.Host)
+				}
+				continue
+			}
+			c, exists := local[name]
+			if !exists {
+				var err error
+				c, err = collector.Build(name)
+				if err != nil {
+					return nil, err
+				}
+			}
+			collectors[name] = c
+		}
+		result = append(result, &remoteHost{config: h, collectors: collectors})
+	}
+	return result, nil
+}
+
+func (h *remoteHost) prepareScrapeContext(c context.Context, collectors []string) (*collector.ScrapeContext, error) {
+	// Neither the ticket request nor the IPC$ session can be cancelled, so
+	// the scrape stops waiting for them once its deadline expires.
+	done := make(chan error, 1)
+	go func() {
+		done <- h.authenticate()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-c.Done():
+		return nil, fmt.Errorf("failed to authenticate to %s: %v", h.config.Host, c.Err())
+	}
+	ctx, err := collector.PrepareRemoteScrapeContext(c, h.config.Host, collectors)
+	if err != nil {
+		h.mu.Lock()
+		h.connected = false
+		h.mu.Unlock()
+	}
+	return ctx, err
+}
+
+// authenticate checks that Kerberos is available for the host, if configured,
+// and connects to it.
+func (h *remoteHost) authenticate() error {
+	// The IPC$ session and the RPC calls of the collection negotiate their
+	// authentication themselves, so this only checks that Kerberos is
+	// available for the host, e.g. that its SPN is registered.
+	if h.config.Authentication == "kerberos" {
+		spn := h.config.SPN
+		if spn == "" {
+			spn = "HOST/" + h.config.Host
+		}
+		ticket, err := sspi.RequestTicket("Kerberos", spn, h.config.Username, h.config.Password)
+		if err != nil {
+			return fmt.Errorf("failed to get a Kerberos ticket for %s: %v", spn, err)
+		}
+		log.Debugf("Got Kerberos ticket for %s (delegable: %t)", spn, ticket.Delegable)
+	}
+	if err := h.connect(); err != nil {
+		return fmt.Errorf("failed to authenticate to %s: %v", h.config.Host, err)
+	}
+	return nil
+}
+
+// connect establishes a session to the IPC$ share of the host with the
+// configured credentials, unless there are none or it is established already.
+func (h *remoteHost) connect() error {
+	if h.config.Username == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.connected {
+		return nil
+	}
+	if err := mpr.ConnectIPC(h.config.Host, h.config.Username, h.config.Password); err != nil {
+		return err
+	}
+	h.connected = true
+	return nil
+}
+
 func initWbem() {
 	// This initialization prevents a memory leak on WMF 5+. See
 	// https://github.com/prometheus-community/windows_exporter/issues/77 and
@@ -260,6 +561,22 @@ func main() {
 			"config.file",
 			"YAML configuration file to use. Values set in this file will be overriden by CLI flags.",
 		).String()
+		configCheck = kingpin.Flag(
+			"config.check",
+			"Check --config.file and the collectors it enables, report the problems found, then exit, non-zero if the exporter wouldn't start or would ignore settings.",
+		).Default("false").Bool()
+		configWatchInterval = kingpin.Flag(
+			"config.watch-interval",
+			"How often to check --config.file for changes, rebuilding the collectors whose settings changed. 0 to disable.",
+		).Default("0s").Duration()
+		enableLifecycle = kingpin.Flag(
+			"web.enable-lifecycle",
+			"If true, reload --config.file on POST requests to /-/reload.",
+		).Bool()
+		enablePerfCountersAPI = kingpin.Flag(
+			"web.enable-perfcounters-api",
+			"If true, serve the performance objects of the local machine with their counters, instances and explain texts on /api/v1/perfcounters.",
+		).Bool()
 		webConfig     = webflag.AddFlags(kingpin.CommandLine)
 		listenAddress = kingpin.Flag(
 			"telemetry.addr",
@@ -273,6 +590,10 @@ func main() {
 			"telemetry.max-requests",
 			"Maximum number of concurrent requests. 0 to disable.",
 		).Default("5").Int()
+		scrapeQueueTimeout = kingpin.Flag(
+			"web.scrape-queue-timeout",
+			"How long a scrape waits for a running one to finish when --telemetry.max-requests is reached. 0 rejects it immediately.",
+		).Default("0s").Duration()
 		enabledCollectors = kingpin.Flag(
 			"collectors.enabled",
 			"Comma-separated list of collectors to use. Use '[defaults]' as a placeholder for all the collectors enabled by default.").
@@ -285,6 +606,122 @@ func main() {
 			"scrape.timeout-margin",
 			"Seconds to subtract from the timeout allowed by the client. Tune to allow for overhead or high loads.",
 		).Default("0.5").Float64()
+		collectorTimeouts = kingpin.Flag(
+			"scrape.collector-timeouts",
+			"Comma-separated list of collector=timeout pairs, e.g. ad=5s, giving up on a slow collector before the scrape timeout so the other collectors' metrics are still returned.",
+		).Default("").String()
+		maxConcurrency = kingpin.Flag(
+			"collectors.max-concurrency",
+			"Maximum number of collectors running at once across all scrapes, 0 for no limit. Collectors waiting for their turn count against their timeout.",
+		).Default("0").Int()
+		onDemandCollectors = kingpin.Flag(
+			"collectors.on-demand",
+			"Comma-separated list of enabled collectors only scraped when requested with collect[], e.g. slow ones scraped by a separate job on a longer interval.",
+		).Default("").String()
+		sampleTimestamps = kingpin.Flag(
+			"scrape.sample-timestamps",
+			"If true, the metrics of collectors reading performance counters carry the time the counters were sampled, rather than the scrape time.",
+		).Bool()
+		allowedCIDRs = kingpin.Flag(
+			"web.allowed-cidrs",
+			"Comma-separated list of CIDRs or IP addresses allowed to connect. Empty to allow all.",
+		).Default("").String()
+		manageFirewallRule = kingpin.Flag(
+			"web.manage-firewall-rule",
+			"If true, create or update an inbound Windows Firewall rule for the listen port, scoped to --web.allowed-cidrs, at startup.",
+		).Bool()
+		auditLogTarget = kingpin.Flag(
+			"web.audit-log",
+			"Record every request in an audit log. Either \"eventlog\" to write to the Application event log, or the path of a file to append JSON lines to. Empty to disable.",
+		).Default("").String()
+		allowedClientNames = kingpin.Flag(
+			"web.client-cert.allowed-names",
+			"Comma-separated list of client certificate subject common names or SANs allowed to connect. Requires client certificate verification to be enabled in --web.config.file. Empty to allow all verified clients.",
+		).Default("").String()
+		publishPerfCounters = kingpin.Flag(
+			"telemetry.perf-counters",
+			"If true, publish scrape durations, last success times and error counts as the windows_exporter performance counter set. Requires installer/windows_exporter.man to be registered with lodctr.",
+		).Bool()
+		enableOpenMetrics = kingpin.Flag(
+			"telemetry.openmetrics",
+			"If true, serve the OpenMetrics format, including _created samples for counters, to clients that accept it.",
+		).Bool()
+		enableSD = kingpin.Flag(
+			"sd.enabled",
+			"If true, serve the computer objects of the local computer's domain as Prometheus HTTP service discovery targets on /sd.",
+		).Bool()
+		sdSearchBases = kingpin.Flag(
+			"sd.search-base",
+			"Semicolon-separated list of DNs of OUs or containers whose computers are served on /sd. Empty for all computers of the domain.",
+		).Default("").String()
+		sdOSFilter = kingpin.Flag(
+			"sd.os-filter",
+			"Shell pattern the operating system of computers served on /sd must match, e.g. 'Windows Server*'. Empty for all operating systems.",
+		).Default("").String()
+		sdTargetPort = kingpin.Flag(
+			"sd.target-port",
+			"Port of the targets served on /sd.",
+		).Default("9182").Int()
+		sdRefreshInterval = kingpin.Flag(
+			"sd.refresh-interval",
+			"How often to read the computer objects served on /sd from the directory.",
+		).Default("5m").Duration()
+		pushURL = kingpin.Flag(
+			"push.remote-write.url",
+			"If set, also push the metrics of the enabled collectors to this Prometheus remote write endpoint every --push.interval, for hosts Prometheus can't scrape.",
+		).Default("").String()
+		pushInterval = kingpin.Flag(
+			"push.interval",
+			"How often to collect and push metrics to --push.remote-write.url.",
+		).Default("1m").Duration()
+		pushLabels = kingpin.Flag(
+			"push.remote-write.labels",
+			"Comma-separated list of name=value labels added to the pushed series. The instance label defaults to the hostname, and the job label to windows_exporter.",
+		).Default("").String()
+		pushUsername = kingpin.Flag(
+			"push.remote-write.username",
+			"Username for basic authentication to --push.remote-write.url.",
+		).Default("").String()
+		pushPasswordFile = kingpin.Flag(
+			"push.remote-write.password-file",
+			"File holding the password for basic authentication to --push.remote-write.url.",
+		).Default("").String()
+		pushBearerTokenFile = kingpin.Flag(
+			"push.remote-write.bearer-token-file",
+			"File holding a bearer token to authenticate to --push.remote-write.url with, if no username is set.",
+		).Default("").String()
+		updateCheckFeedURL = kingpin.Flag(
+			"update-check.feed-url",
+			"If set, check this release feed in the format of the GitHub releases API for newer releases, reported by windows_exporter_update_available.",
+		).Default("").String()
+		updateCheckInterval = kingpin.Flag(
+			"update-check.interval",
+			"How often to check --update-check.feed-url for newer releases.",
+		).Default("24h").Duration()
+		keepPrivileges = kingpin.Flag(
+			"security.keep-privileges",
+			"Comma-separated list of the privileges the exporter keeps, removing all others from its process at startup. \"all\" to keep all privileges of the account.",
+		).Default(defaultPrivileges).String()
+		shutdownTimeout = kingpin.Flag(
+			"web.shutdown-timeout",
+			"Maximum duration to wait for in-flight requests to complete when stopping, before the listeners are closed.",
+		).Default("5s").Duration()
+		_               = kingpin.Command("serve", "Serve the metrics of the enabled collectors.").Default()
+		benchCmd        = kingpin.Command("bench", "Measure the cost of scraping each enabled collector on this host, then exit.")
+		benchIterations = benchCmd.Flag(
+			"iterations",
+			"Number of scrapes to average the cost of each collector over.",
+		).Default("10").Int()
+		diffCmd      = kingpin.Command("diff", "Collect the enabled collectors once and report the metrics added, removed or renamed compared to a baseline, then exit.")
+		diffBaseline = diffCmd.Flag(
+			"baseline",
+			"File holding a scrape of the exporter in the text format, e.g. of the previous version.",
+		).Required().ExistingFile()
+		diffTimeout = diffCmd.Flag(
+			"timeout",
+			"Maximum duration of the collection.",
+		).Default("1m").Duration()
+		generateRulesCmd = kingpin.Command("generate-rules", "Write Prometheus recording rules and alerts for the metrics of the enabled collectors to stdout, then exit.")
 	)
 
 	log.AddFlags(kingpin.CommandLine)
@@ -293,9 +730,16 @@ func main() {
 
 	// Load values from configuration file(s). Executable flags must first be parsed, in order
 	// to load the specified file(s).
-	kingpin.Parse()
+	command := kingpin.Parse()
 
+	var remoteHostConfigs []config.RemoteHost
+	var endpoints []config.Endpoint
+	var accessConfig []config.CollectorAccess
+	live := &liveCollectors{}
+	var reloader *configReloader
 	if *configFile != "" {
+		// Capture the defaults of all flags before the configuration file overrides them.
+		reloader = newConfigReloader(*configFile, kingpin.CommandLine, os.Args[1:], enabledCollectors, live)
 		resolver, err := config.NewResolver(*configFile)
 		if err != nil {
 			log.Fatalf("could not load config file: %v\n", err)
@@ -305,7 +749,14 @@ func main() {
 			log.Fatalf("%v\n", err)
 		}
 		// Parse flags once more to include those discovered in configuration file(s).
-		kingpin.Parse()
+		command = kingpin.Parse()
+		remoteHostConfigs = resolver.RemoteHosts()
+		endpoints = resolver.Endpoints()
+		accessConfig = resolver.CollectorAccess()
+		if err := collector.SetLabelValues(resolver.LabelValues()); err != nil {
+			log.Fatalf("Invalid label_values: %v", err)
+		}
+		reloader.current = resolver
 	}
 
 	if *printCollectors {
@@ -322,21 +773,80 @@ func main() {
 		return
 	}
 
+	if command == generateRulesCmd.FullCommand() {
+		if err := runGenerateRules(*enabledCollectors, os.Stdout); err != nil {
+			log.Fatalf("Couldn't generate rules: %s", err)
+		}
+		return
+	}
+
 	initWbem()
 
+	if *configCheck {
+		if reloader == nil {
+			log.Fatalf("--config.check requires --config.file")
+		}
+		settings := checkSettings{
+			enabled:            *enabledCollectors,
+			collectorTimeouts:  *collectorTimeouts,
+			onDemandCollectors: *onDemandCollectors,
+			webConfigFile:      *webConfig,
+		}
+		if !runConfigCheck(*configFile, reloader.current, kingpin.CommandLine, os.Args[1:], settings, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == benchCmd.FullCommand() {
+		if err := runBench(*enabledCollectors, *benchIterations, os.Stdout); err != nil {
+			log.Fatalf("Couldn't benchmark collectors: %s", err)
+		}
+		return
+	}
+	if command == diffCmd.FullCommand() {
+		differ, err := runDiff(*enabledCollectors, *diffBaseline, *diffTimeout, os.Stdout)
+		if err != nil {
+			log.Fatalf("Couldn't compare metrics: %s", err)
+		}
+		if differ {
+			os.Exit(1)
+		}
+		return
+	}
+
+	logProcessAccount()
+	if err := dropPrivileges(*keepPrivileges); err != nil {
+		log.Fatalf("Couldn't drop privileges: %v", err)
+	}
+
 	isInteractive, err := svc.IsAnInteractiveSession()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	stopCh := make(chan bool)
+	// doneCh is closed once the exporter stopped, and serviceDoneCh once the
+	// service reported it, so that the process exits after both.
+	doneCh := make(chan struct{})
+	serviceDoneCh := make(chan struct{})
 	if !isInteractive {
 		go func() {
-			err = svc.Run(serviceName, &windowsExporterService{stopCh: stopCh})
+			defer close(serviceDoneCh)
+			err = svc.Run(serviceName, &windowsExporterService{stopCh: stopCh, doneCh: doneCh, stopTimeout: *shutdownTimeout})
 			if err != nil {
 				log.Errorf("Failed to start service: %v", err)
 			}
 		}()
+	} else {
+		// Stop on Ctrl+C as well, so that ETW sessions are closed.
+		close(serviceDoneCh)
+		go func() {
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			<-interrupt
+			stopCh <- true
+		}()
 	}
 
 	collectors, err := loadCollectors(*enabledCollectors)
@@ -345,14 +855,70 @@ func main() {
 	}
 
 	log.Infof("Enabled collectors: %v", strings.Join(keys(collectors), ", "))
+	live.set(collectors)
+
+	var perfStats *perfCounterStats
+	if *publishPerfCounters {
+		perfStats, err = newPerfCounterStats(keys(collectors))
+		if err != nil {
+			log.Errorf("Couldn't publish performance counters: %v", err)
+		} else {
+			defer perfStats.close()
+		}
+	}
+	if reloader != nil {
+		reloader.perfStats = perfStats
+	}
+	if reloader != nil && *configWatchInterval > 0 {
+		stopWatch := make(chan struct{})
+		defer close(stopWatch)
+		go config.Watch(*configFile, *configWatchInterval, stopWatch, func(updated *config.Resolver) {
+			// The failures are logged by the reload.
+			_ = reloader.reload(updated)
+		})
+	}
+
+	remoteHosts, err := loadRemoteHosts(remoteHostConfigs, collectors)
+	if err != nil {
+		log.Fatalf("Couldn't load collectors for remote hosts: %s", err)
+	}
+	for _, h := range remoteHosts {
+		log.Infof("Collecting from remote host %s: %v", h.config.Host, strings.Join(keys(h.collectors), ", "))
+	}
+
+	timeouts, err := parseCollectorTimeouts(*collectorTimeouts)
+	if err != nil {
+		log.Fatalf("Invalid --scrape.collector-timeouts: %v", err)
+	}
+	for name := range timeouts {
+		if _, ok := collectors[name]; !ok {
+			log.Warnf("Ignoring the timeout of collector %s, which isn't enabled", name)
+		}
+	}
+	onDemand, err := parseOnDemandCollectors(*onDemandCollectors, collectors)
+	if err != nil {
+		log.Fatalf("Invalid --collectors.on-demand: %v", err)
+	}
+	var workers chan struct{}
+	if *maxConcurrency > 0 {
+		workers = make(chan struct{}, *maxConcurrency)
+	}
 
 	h := &metricsHandler{
 		timeoutMargin: *timeoutMargin,
+		remoteHosts:   remoteHosts,
+		onDemand:      onDemand,
 		collectorFactory: func(timeout time.Duration, requestedCollectors []string) (error, *windowsCollector) {
+			collectors := live.get()
 			filteredCollectors := make(map[string]collector.Collector)
-			// scrape all enabled collectors if no collector is requested
+			// scrape all enabled collectors but those scraped on demand if
+			// no collector is requested
 			if len(requestedCollectors) == 0 {
-				filteredCollectors = collectors
+				for name, col := range collectors {
+					if !onDemand[name] {
+						filteredCollectors[name] = col
+					}
+				}
 			}
 			for _, name := range requestedCollectors {
 				col, exists := collectors[name]
@@ -364,12 +930,49 @@ func main() {
 			return nil, &windowsCollector{
 				collectors:        filteredCollectors,
 				maxScrapeDuration: timeout,
+				perfStats:         perfStats,
+				sampleTimestamps:  *sampleTimestamps,
+				collectorTimeouts: timeouts,
+				workers:           workers,
 			}
 		},
 	}
 
-	http.HandleFunc(*metricsPath, withConcurrencyLimit(*maxRequests, h.ServeHTTP))
+	if *enableOpenMetrics {
+		h.createdTracker = newCreatedTracker()
+	}
+	h.access, err = newCollectorAccess(accessConfig, collectors)
+	if err != nil {
+		log.Fatalf("Invalid collector_access: %v", err)
+	}
+
+	http.HandleFunc(*metricsPath, withConcurrencyLimit(*maxRequests, *scrapeQueueTimeout, h.ServeHTTP))
 	http.HandleFunc("/health", healthCheck)
+	if len(remoteHosts) > 0 {
+		http.Handle("/probe", withConcurrencyLimit(*maxRequests, *scrapeQueueTimeout, newProbeHandler(h, remoteHosts).ServeHTTP))
+	}
+	if *enableLifecycle {
+		if reloader == nil {
+			log.Fatalf("--web.enable-lifecycle requires --config.file")
+		}
+		http.Handle("/-/reload", withFullAccess(h.access, reloader))
+	}
+	if *enablePerfCountersAPI {
+		http.Handle("/api/v1/perfcounters", withFullAccess(h.access, http.HandlerFunc(servePerfCounters)))
+	}
+	if *enableSD {
+		sd := &sdHandler{
+			port:            *sdTargetPort,
+			refreshInterval: *sdRefreshInterval,
+		}
+		if *sdOSFilter != "" {
+			sd.filter.osPatterns = []string{*sdOSFilter}
+		}
+		if *sdSearchBases != "" {
+			sd.filter.bases = strings.Split(*sdSearchBases, ";")
+		}
+		http.Handle("/sd", withFullAccess(h.access, sd))
+	}
 	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
 		// we can't use "version" directly as it is a package, and not an object that
 		// can be serialized.
@@ -396,13 +999,87 @@ func main() {
 </html>`))
 	})
 
+	allowedNetworks, err := parseCIDRs(*allowedCIDRs)
+	if err != nil {
+		log.Fatalf("Invalid --web.allowed-cidrs: %v", err)
+	}
+	if *manageFirewallRule {
+		if err := ensureFirewallRule(*listenAddress, allowedNetworks); err != nil {
+			log.Errorf("Failed to configure firewall rule: %v", err)
+		}
+	}
+
+	var audit auditLogger
+	if *auditLogTarget != "" {
+		audit, err = newAuditLogger(*auditLogTarget)
+		if err != nil {
+			log.Fatalf("Couldn't open audit log: %v", err)
+		}
+	}
+
+	if *pushURL != "" {
+		hostname, _ := os.Hostname()
+		labels, err := parseRemoteWriteLabels(*pushLabels, hostname)
+		if err != nil {
+			log.Fatalf("Invalid --push.remote-write.labels: %v", err)
+		}
+		// Collect with the default scrape timeout, unless pushing more often.
+		timeout := 10 * time.Second
+		if *pushInterval < timeout {
+			timeout = *pushInterval
+		}
+		w := &remoteWriter{
+			url:          *pushURL,
+			interval:     *pushInterval,
+			timeout:      timeout - time.Duration(*timeoutMargin*float64(time.Second)),
+			labels:       labels,
+			username:     *pushUsername,
+			passwordFile: *pushPasswordFile,
+			tokenFile:    *pushBearerTokenFile,
+			client:       &http.Client{Timeout: *pushInterval},
+			handler:      h,
+		}
+		go w.run()
+	}
+
+	if *updateCheckFeedURL != "" {
+		h.updateChecker = newUpdateChecker(*updateCheckFeedURL, *updateCheckInterval)
+		go h.updateChecker.run()
+	}
+
 	log.Infoln("Starting windows_exporter", version.Info())
 	log.Infoln("Build context", version.BuildContext())
 
+	// servers are shut down on stop, so that their listeners are closed
+	// before e.g. an upgrade starts the next version on the same addresses.
+	var servers []*http.Server
+	for _, e := range endpoints {
+		handler, err := newEndpointHandler(e, *h, collectors)
+		if err != nil {
+			log.Fatalf("Invalid endpoint %s: %v", e.ListenAddress, err)
+		}
+		handler = withConcurrencyLimit(*maxRequests, *scrapeQueueTimeout, handler)
+		server := &http.Server{
+			Addr:    e.ListenAddress,
+			Handler: newEndpointServerHandler(e, handler, audit, *allowedClientNames),
+		}
+		servers = append(servers, server)
+		go func(e config.Endpoint, server *http.Server) {
+			log.Infof("Starting endpoint on %s serving %s", e.ListenAddress, e.MetricsPath)
+			if err := web.ListenAndServe(server, e.WebConfigFile, log.NewToolkitAdapter()); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("cannot start endpoint %s: %s", e.ListenAddress, err)
+			}
+		}(e, server)
+	}
+
+	server := &http.Server{
+		Addr:    *listenAddress,
+		Handler: withBasicAuthVerified(*webConfig, withAuditLog(audit, withAllowedNetworks(allowedNetworks, withClientCertAllowlist(strings.Split(*allowedClientNames, ","), http.DefaultServeMux)))),
+	}
+	servers = append(servers, server)
 	go func() {
 		log.Infoln("Starting server on", *listenAddress)
-		server := &http.Server{Addr: *listenAddress}
-		if err := web.ListenAndServe(server, *webConfig, log.NewToolkitAdapter()); err != nil {
+		if err := web.ListenAndServe(server, *webConfig, log.NewToolkitAdapter()); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("cannot start windows_exporter: %s", err)
 		}
 	}()
@@ -410,9 +1087,66 @@ func main() {
 	for {
 		if <-stopCh {
 			log.Info("Shutting down windows_exporter")
+			shutdownServers(servers, *shutdownTimeout)
+			if err := etw.CloseAll(); err != nil {
+				log.Warnf("Failed to stop ETW sessions: %v", err)
+			}
 			break
 		}
 	}
+	close(doneCh)
+	<-serviceDoneCh
+}
+
+// shutdownServers stops the servers from accepting connections and closes
+// their listeners, waiting up to timeout for in-flight requests to complete.
+func shutdownServers(servers []*http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *http.Server) {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				log.Warnf("Failed to shut down server on %s gracefully: %v", s.Addr, err)
+				s.Close()
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+// newEndpointHandler returns the metrics handler of an endpoint, serving the
+// collectors of base restricted to those of the endpoint.
+func newEndpointHandler(e config.Endpoint, base metricsHandler, enabled map[string]collector.Collector) (http.HandlerFunc, error) {
+	if _, err := parseCIDRs(e.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("invalid allowed_cidrs: %v", err)
+	}
+	if e.Collectors != "" {
+		base.collectors = expandEnabledCollectors(e.Collectors)
+		for _, name := range base.collectors {
+			if _, ok := enabled[name]; !ok {
+				return nil, fmt.Errorf("collector %s isn't enabled", name)
+			}
+		}
+	}
+	return base.ServeHTTP, nil
+}
+
+// newEndpointServerHandler returns the handler of the server of an endpoint,
+// serving metrics with the checks of the main listener. The client
+// certificates allowed are those of the endpoint's client_allowed_names, or
+// allowedClientNames, from --web.client-cert.allowed-names, if it has none.
+func newEndpointServerHandler(e config.Endpoint, metrics http.HandlerFunc, audit auditLogger, allowedClientNames string) http.Handler {
+	networks, _ := parseCIDRs(e.AllowedCIDRs)
+	if e.ClientAllowedNames != "" {
+		allowedClientNames = e.ClientAllowedNames
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(e.MetricsPath, metrics)
+	mux.HandleFunc("/health", healthCheck)
+	return withBasicAuthVerified(e.WebConfigFile, withAuditLog(audit, withAllowedNetworks(networks, withClientCertAllowlist(strings.Split(allowedClientNames, ","), mux))))
 }
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
@@ -431,7 +1165,11 @@ func keys(m map[string]collector.Collector) []string {
 	return ret
 }
 
-func withConcurrencyLimit(n int, next http.HandlerFunc) http.HandlerFunc {
+// withConcurrencyLimit allows at most n concurrent requests. Requests exceeding
+// the limit wait up to queueTimeout for a free slot. They are rejected with
+// 503 if they weren't allowed to wait, or with 429 if they timed out waiting.
+// Both include a Retry-After header.
+func withConcurrencyLimit(n int, queueTimeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
 	if n <= 0 {
 		return next
 	}
@@ -441,17 +1179,270 @@ func withConcurrencyLimit(n int, next http.HandlerFunc) http.HandlerFunc {
 		select {
 		case sem <- struct{}{}:
 			defer func() { <-sem }()
+			next(w, r)
+			return
 		default:
+		}
+
+		if queueTimeout <= 0 {
+			w.Header().Set("Retry-After", "1")
 			w.WriteHeader(http.StatusServiceUnavailable)
 			_, _ = w.Write([]byte("Too many concurrent requests"))
 			return
 		}
-		next(w, r)
+
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next(w, r)
+		case <-timer.C:
+			w.Header().Set("Retry-After", strconv.Itoa(int(queueTimeout.Seconds())+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("Too many concurrent requests"))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// auditEntry is a single record of the audit log.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	// Identity is the subject common name of the verified client certificate,
+	// or the basic auth user name checked by the web configuration. It is
+	// empty if neither was verified.
+	Identity   string   `json:"identity,omitempty"`
+	Collectors []string `json:"collectors,omitempty"`
+	Status     int      `json:"status"`
+	Bytes      int64    `json:"bytes"`
+	Duration   float64  `json:"duration_seconds"`
+}
+
+type auditLogger interface {
+	Log(entry auditEntry) error
+}
+
+func newAuditLogger(target string) (auditLogger, error) {
+	if target == "eventlog" {
+		l, err := eventlog.Open(serviceName)
+		if err != nil {
+			return nil, err
+		}
+		return &eventlogAuditLogger{log: l}, nil
+	}
+
+	f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditLogger{enc: json.NewEncoder(f)}, nil
+}
+
+type fileAuditLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (l *fileAuditLogger) Log(entry auditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(entry)
+}
+
+type eventlogAuditLogger struct {
+	log *eventlog.Log
+}
+
+func (l *eventlogAuditLogger) Log(entry auditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return l.log.Info(200, string(b))
+}
+
+// auditResponseWriter records the status code and size of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// withAuditLog records every request in audit.
+func withAuditLog(audit auditLogger, next http.Handler) http.Handler {
+	if audit == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		aw := &auditResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+
+		entry := auditEntry{
+			Time:       t,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Collectors: r.URL.Query()["collect[]"],
+			Status:     aw.status,
+			Bytes:      aw.bytes,
+			Duration:   time.Since(t).Seconds(),
+		}
+		for _, id := range requestIdentities(r) {
+			if id != "" {
+				entry.Identity = id
+				break
+			}
+		}
+		if err := audit.Log(entry); err != nil {
+			log.Warnf("Failed to write audit log: %v", err)
+		}
+	})
+}
+
+// parseCIDRs parses a comma-separated list of CIDRs. Plain IP addresses are
+// treated as single-host networks.
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
 	}
+	return networks, nil
+}
+
+// withAllowedNetworks rejects requests from remote addresses outside of networks.
+func withAllowedNetworks(networks []*net.IPNet, next http.Handler) http.Handler {
+	if len(networks) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, n := range networks {
+				if n.Contains(ip) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		log.Warnf("Rejecting request from %s: not in allowed networks", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+}
+
+// ensureFirewallRule replaces the inbound firewall rule managed by the exporter
+// with one allowing TCP connections to the port of listenAddress from networks.
+func ensureFirewallRule(listenAddress string, networks []*net.IPNet) error {
+	_, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("name=%s (managed, TCP %s)", serviceName, port)
+
+	remoteIP := "any"
+	if len(networks) > 0 {
+		ips := make([]string, 0, len(networks))
+		for _, n := range networks {
+			ips = append(ips, n.String())
+		}
+		remoteIP = strings.Join(ips, ",")
+	}
+
+	// Deleting fails if the rule doesn't exist yet, which is fine.
+	_ = exec.Command("netsh", "advfirewall", "firewall", "delete", "rule", name).Run()
+
+	out, err := exec.Command("netsh", "advfirewall", "firewall", "add", "rule", name,
+		"dir=in", "action=allow", "protocol=TCP", "localport="+port, "remoteip="+remoteIP).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("netsh failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	log.Infof("Configured firewall rule for port %s, allowing %s", port, remoteIP)
+	return nil
+}
+
+// withClientCertAllowlist rejects requests unless they present a verified client
+// certificate whose subject common name or one of its SANs is in allowed.
+func withClientCertAllowlist(allowed []string, next http.Handler) http.Handler {
+	names := make(map[string]bool, len(allowed))
+	for _, n := range allowed {
+		if n = strings.TrimSpace(n); n != "" {
+			names[strings.ToLower(n)] = true
+		}
+	}
+	if len(names) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			log.Warnf("Rejecting request from %s without verified client certificate", r.RemoteAddr)
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		for _, c := range certificateNames(cert) {
+			if names[strings.ToLower(c)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		log.Warnf("Rejecting request from %s with client certificate %q: not in allowed names", r.RemoteAddr, cert.Subject.CommonName)
+		http.Error(w, "client certificate not allowed", http.StatusForbidden)
+	})
 }
 
 type windowsExporterService struct {
 	stopCh chan<- bool
+	// doneCh is closed once the exporter stopped, at most stopTimeout after
+	// being signaled, not counting ETW sessions.
+	doneCh      <-chan struct{}
+	stopTimeout time.Duration
 }
 
 func (s *windowsExporterService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
@@ -466,23 +1457,43 @@ loop:
 			case svc.Interrogate:
 				changes <- c.CurrentStatus
 			case svc.Stop, svc.Shutdown:
+				// Report the service as stopped only once the listeners are
+				// closed, as installers replace the executable and restart
+				// the service as soon as it is.
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32((s.stopTimeout + 5*time.Second) / time.Millisecond)}
 				s.stopCh <- true
+				<-s.doneCh
 				break loop
 			default:
 				log.Error(fmt.Sprintf("unexpected control request #%d", c))
 			}
 		}
 	}
-	changes <- svc.Status{State: svc.StopPending}
 	return
 }
 
 type metricsHandler struct {
-	timeoutMargin    float64
+	timeoutMargin float64
+	remoteHosts   []*remoteHost
+	// collectors, if set, restricts the served collectors to those of an
+	// endpoint.
+	collectors []string
+	// onDemand holds the collectors only scraped when requested with
+	// collect[].
+	onDemand         map[string]bool
 	collectorFactory func(timeout time.Duration, requestedCollectors []string) (error, *windowsCollector)
+	// createdTracker is set if OpenMetrics may be negotiated.
+	createdTracker *createdTracker
+	// updateChecker is set if a release feed is checked for updates.
+	updateChecker *updateChecker
+	// access, if set, restricts the collectors served to authenticated
+	// clients.
+	access *collectorAccess
 }
 
-func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// scrapeTimeout returns the time left to collect, the scrape timeout of
+// Prometheus less the timeout margin.
+func (mh *metricsHandler) scrapeTimeout(r *http.Request) time.Duration {
 	const defaultTimeout = 10.0
 
 	var timeoutSeconds float64
@@ -498,21 +1509,125 @@ func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	timeoutSeconds = timeoutSeconds - mh.timeoutMargin
 
-	reg := prometheus.NewRegistry()
-	err, wc := mh.collectorFactory(time.Duration(timeoutSeconds*float64(time.Second)), r.URL.Query()["collect[]"])
+	return time.Duration(timeoutSeconds * float64(time.Second))
+}
+
+func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := mh.scrapeTimeout(r)
+	requestedCollectors := r.URL.Query()["collect[]"]
+	if mh.collectors != nil {
+		served := make(map[string]bool, len(mh.collectors))
+		for _, name := range mh.collectors {
+			served[name] = true
+		}
+		for _, name := range requestedCollectors {
+			if !served[name] {
+				log.Warnln("Collector not served by this endpoint: ", name)
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("Collector not served by this endpoint: %s", name)))
+				return
+			}
+		}
+	}
+	if mh.access != nil {
+		var err error
+		requestedCollectors, err = mh.access.restrict(r, requestedCollectors, mh.collectors)
+		if err != nil {
+			log.Warnf("Rejecting request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if len(requestedCollectors) == 0 {
+		requestedCollectors = mh.collectors
+	}
+	if len(r.URL.Query()["collect[]"]) == 0 && len(requestedCollectors) > 0 {
+		requestedCollectors = withoutOnDemand(requestedCollectors, mh.onDemand)
+		if len(requestedCollectors) == 0 {
+			log.Warnln("No collector to scrape, all are scraped on demand")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("No collector to scrape, request the collectors scraped on demand with collect[]"))
+			return
+		}
+	}
+	var shard *exposureShard
+	if v := r.URL.Query().Get("shard"); v != "" {
+		s, err := parseExposureShard(v)
+		if err != nil {
+			log.Warnln("Invalid shard requested: ", err)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		shard = &s
+	}
+	reg, err := mh.newRegistry(r.Context(), timeout, requestedCollectors)
 	if err != nil {
 		log.Warnln("Couldn't create filtered metrics handler: ", err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(fmt.Sprintf("Couldn't create filtered metrics handler: %s", err)))
 		return
 	}
+	var g prometheus.Gatherer = reg
+	if shard != nil {
+		g = shard.gatherer(reg)
+	}
+
+	if mh.createdTracker != nil {
+		openMetricsHandler(g, mh.createdTracker).ServeHTTP(w, r)
+		return
+	}
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+}
+
+// newRegistry returns a registry collecting the requested collectors, or all
+// enabled ones but those scraped on demand if none is requested, of the local
+// machine and the remote hosts. Cancelling ctx cancels the collectors.
+func (mh *metricsHandler) newRegistry(ctx context.Context, timeout time.Duration, requestedCollectors []string) (*prometheus.Registry, error) {
+	err, wc := mh.collectorFactory(timeout, requestedCollectors)
+	if err != nil {
+		return nil, err
+	}
+	wc.requestContext = ctx
+	reg := prometheus.NewRegistry()
 	reg.MustRegister(wc)
+	for _, h := range mh.remoteHosts {
+		remoteCollectors := make(map[string]collector.Collector)
+		for name, c := range h.collectors {
+			if !mh.onDemand[name] {
+				remoteCollectors[name] = c
+			}
+		}
+		if len(requestedCollectors) > 0 {
+			remoteCollectors = make(map[string]collector.Collector)
+			for _, name := range requestedCollectors {
+				if c, exists := h.collectors[name]; exists {
+					remoteCollectors[name] = c
+				}
+			}
+		}
+		if len(remoteCollectors) == 0 {
+			continue
+		}
+		prometheus.WrapRegistererWith(prometheus.Labels{"source": h.config.Host}, reg).MustRegister(&windowsCollector{
+			collectors:        remoteCollectors,
+			maxScrapeDuration: timeout,
+			remote:            h,
+			sampleTimestamps:  wc.sampleTimestamps,
+			collectorTimeouts: wc.collectorTimeouts,
+			requestContext:    ctx,
+			workers:           wc.workers,
+		})
+	}
 	reg.MustRegister(
 		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 		prometheus.NewGoCollector(),
-		version.NewCollector("windows_exporter"),
+		newBuildInfoCollector(),
+		newPrivilegesCollector(),
 	)
-
-	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
+	if mh.updateChecker != nil {
+		reg.MustRegister(mh.updateChecker)
+	}
+	return reg, nil
 }
@@ -3,9 +3,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/leoluk/perflib_exporter/perflib"
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/headers/advapi32"
+	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/sys/windows"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 type expansionTestCase struct {
@@ -49,3 +74,1039 @@ func TestExpandEnabled(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCollectorTimeouts(t *testing.T) {
+	timeouts, err := parseCollectorTimeouts("ad=5s, mssql=1m,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeouts) != 2 || timeouts["ad"] != 5*time.Second || timeouts["mssql"] != time.Minute {
+		t.Errorf("unexpected timeouts %v", timeouts)
+	}
+	for _, s := range []string{"ad", "ad=", "ad=fast", "ad=-1s"} {
+		if _, err := parseCollectorTimeouts(s); err == nil {
+			t.Errorf("parseCollectorTimeouts(%q) succeeded", s)
+		}
+	}
+}
+
+func TestOnDemandCollectors(t *testing.T) {
+	enabled := map[string]collector.Collector{"cpu": nil, "mssql": nil, "os": nil}
+	onDemand, err := parseOnDemandCollectors("mssql, ", enabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := withoutOnDemand([]string{"cpu", "mssql", "os"}, onDemand); strings.Join(got, ",") != "cpu,os" {
+		t.Errorf("Expected collectors cpu,os, got %v", got)
+	}
+	if _, err := parseOnDemandCollectors("exchange", enabled); err == nil {
+		t.Error("Expected an error for a collector that isn't enabled")
+	}
+}
+
+func TestEndpointClientCertAllowlist(t *testing.T) {
+	metrics := func(w http.ResponseWriter, r *http.Request) {}
+	cases := []struct {
+		name         string
+		endpoint     config.Endpoint
+		flag         string
+		expectedCode int
+	}{
+		{"no allowlist", config.Endpoint{MetricsPath: "/metrics"}, "", http.StatusOK},
+		{"allowlist of the flag", config.Endpoint{MetricsPath: "/metrics"}, "prometheus-a", http.StatusForbidden},
+		{"allowlist of the endpoint", config.Endpoint{MetricsPath: "/metrics", ClientAllowedNames: "prometheus-b"}, "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := newEndpointServerHandler(c.endpoint, metrics, nil, c.flag)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+			if w.Code != c.expectedCode {
+				t.Errorf("Expected status %d, got %d", c.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestClientCertAllowlist(t *testing.T) {
+	handler := withClientCertAllowlist([]string{"prometheus-a", "prom.example.com"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	cases := []struct {
+		name         string
+		cert         *x509.Certificate
+		expectedCode int
+	}{
+		{"no certificate", nil, http.StatusForbidden},
+		{"allowed common name", &x509.Certificate{Subject: pkix.Name{CommonName: "Prometheus-A"}}, http.StatusOK},
+		{"allowed SAN", &x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"prom.example.com"}}, http.StatusOK},
+		{"unknown certificate", &x509.Certificate{Subject: pkix.Name{CommonName: "other"}}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if c.cert != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c.cert}}}
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != c.expectedCode {
+				t.Errorf("Expected status %d, got %d", c.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestAllowedNetworks(t *testing.T) {
+	networks, err := parseCIDRs("10.0.0.0/8, 192.168.1.5,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := withAllowedNetworks(networks, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	cases := []struct {
+		remoteAddr   string
+		expectedCode int
+	}{
+		{"10.1.2.3:5000", http.StatusOK},
+		{"192.168.1.5:5000", http.StatusOK},
+		{"192.168.1.6:5000", http.StatusForbidden},
+		{"[::1]:5000", http.StatusOK},
+		{"[fe80::1]:5000", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.RemoteAddr = c.remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.expectedCode {
+			t.Errorf("For %s expected status %d, got %d", c.remoteAddr, c.expectedCode, w.Code)
+		}
+	}
+
+	if _, err := parseCIDRs("10.0.0.0/33"); err == nil {
+		t.Error("Expected an error for an invalid CIDR")
+	}
+}
+
+type recordingAuditLogger struct {
+	entries []auditEntry
+}
+
+func (l *recordingAuditLogger) Log(entry auditEntry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func TestAuditLog(t *testing.T) {
+	audit := &recordingAuditLogger{}
+	handler := withAuditLog(audit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest("GET", "/metrics?collect[]=cpu&collect[]=os", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.SetBasicAuth("prometheus", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true)))
+
+	// Neither the basic auth user nor the client certificate of this
+	// request were verified, so no identity must be recorded.
+	r = httptest.NewRequest("GET", "/metrics", nil)
+	r.SetBasicAuth("admin", "guess")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "admin"}}}}
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(audit.entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(audit.entries))
+	}
+	e := audit.entries[0]
+	if e.RemoteAddr != "10.0.0.1:5000" || e.Identity != "prometheus" || e.Status != http.StatusOK || e.Bytes != 5 {
+		t.Errorf("Unexpected audit entry %+v", e)
+	}
+	if strings.Join(e.Collectors, ",") != "cpu,os" {
+		t.Errorf("Expected collectors cpu,os, got %v", e.Collectors)
+	}
+	if e := audit.entries[1]; e.Identity != "" {
+		t.Errorf("Expected no identity for unverified credentials, got %q", e.Identity)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	cases := []struct {
+		name         string
+		queueTimeout time.Duration
+		expectedCode int
+	}{
+		{"reject immediately", 0, http.StatusServiceUnavailable},
+		{"queue timeout", 10 * time.Millisecond, http.StatusTooManyRequests},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := withConcurrencyLimit(1, c.queueTimeout, slow)
+			go handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+			<-started
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/metrics", nil))
+			release <- struct{}{}
+
+			if w.Code != c.expectedCode {
+				t.Errorf("Expected status %d, got %d", c.expectedCode, w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected a Retry-After header")
+			}
+		})
+	}
+}
+
+// blockingCollector blocks until released, ignoring its deadline like a
+// collector stuck in a WMI query.
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c blockingCollector) Collect(ctx *collector.ScrapeContext, ch chan<- prometheus.Metric) error {
+	close(c.started)
+	<-c.release
+	return nil
+}
+
+type idleCollector struct{}
+
+func (idleCollector) Collect(ctx *collector.ScrapeContext, ch chan<- prometheus.Metric) error {
+	return nil
+}
+
+// collectorSuccess scrapes coll and returns windows_exporter_collector_success
+// by collector.
+func collectorSuccess(t *testing.T, coll windowsCollector) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		coll.Collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for m := range ch {
+		if m.Desc() != scrapeSuccessDesc {
+			continue
+		}
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		values[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestWorkerReleasedOnTimeout(t *testing.T) {
+	workers := make(chan struct{}, 1)
+	blocking := blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(blocking.release)
+
+	stuck := windowsCollector{
+		collectors:        map[string]collector.Collector{"blocking": blocking},
+		maxScrapeDuration: time.Minute,
+		collectorTimeouts: map[string]time.Duration{"blocking": 50 * time.Millisecond},
+		workers:           workers,
+	}
+	if success := collectorSuccess(t, stuck); success["blocking"] != 0 {
+		t.Errorf("Expected the blocking collector to time out, got %v", success)
+	}
+	<-blocking.started
+
+	// The blocking collector still runs, but released its worker after its
+	// deadline, so the collectors of later scrapes run.
+	next := windowsCollector{
+		collectors:        map[string]collector.Collector{"idle": idleCollector{}},
+		maxScrapeDuration: time.Second,
+		workers:           workers,
+	}
+	if success := collectorSuccess(t, next); success["idle"] != 1 {
+		t.Errorf("Expected the idle collector to succeed, got %v", success)
+	}
+}
+
+func TestParsePrivileges(t *testing.T) {
+	names, err := parsePrivileges(" SeChangeNotifyPrivilege,,seCreateGlobalPrivilege ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"SeChangeNotifyPrivilege", "seCreateGlobalPrivilege"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("privileges do not match!\nExpected result: %v\nActual result: %v", expected, names)
+	}
+	if _, err := parsePrivileges("SeChangeNotifyPrivilege,SeChangeNotifyPrivileg"); err == nil {
+		t.Error("Expected an error for an unknown privilege")
+	}
+	if _, err := parsePrivileges(defaultPrivileges); err != nil {
+		t.Errorf("Expected the default privileges to be known, got %v", err)
+	}
+}
+
+func TestRemovePrivileges(t *testing.T) {
+	// The privileges are removed from a copy of the token of the process,
+	// as they can't be added back.
+	process, err := openProcessToken(windows.TOKEN_DUPLICATE | windows.TOKEN_QUERY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Close()
+	var token windows.Token
+	if err := windows.DuplicateTokenEx(process, windows.TOKEN_QUERY|windows.TOKEN_ADJUST_PRIVILEGES, nil, windows.SecurityImpersonation, windows.TokenPrimary, &token); err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+
+	before, err := advapi32.TokenPrivileges(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := advapi32.RemovePrivileges(token, []string{"sechangenotifyprivilege"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := advapi32.TokenPrivileges(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expectedRemoved []string
+	for _, p := range before {
+		if p.Name != "SeChangeNotifyPrivilege" {
+			expectedRemoved = append(expectedRemoved, p.Name)
+		}
+	}
+	if !reflect.DeepEqual(removed, expectedRemoved) {
+		t.Errorf("removed privileges do not match!\nExpected result: %v\nActual result: %v", expectedRemoved, removed)
+	}
+	if len(after) != 1 || after[0].Name != "SeChangeNotifyPrivilege" {
+		t.Errorf("Expected only SeChangeNotifyPrivilege to be kept, got %v", after)
+	}
+}
+
+func TestOpenMetricsCreated(t *testing.T) {
+	boot := time.Unix(1000, 0)
+	tracker := &createdTracker{boot: boot, series: make(map[string]*createdSeries)}
+	value := 10.0
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		name, help, label, labelValue := "requests_total", "Requests.", "path", "/"
+		return []*dto.MetricFamily{{
+			Name: &name,
+			Help: &help,
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: &label, Value: &labelValue}},
+				Counter: &dto.Counter{Value: &value},
+			}},
+		}}, nil
+	})
+	handler := openMetricsHandler(g, tracker)
+
+	scrape := func(accept string) string {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if body := scrape("text/plain"); strings.Contains(body, "_created") {
+		t.Errorf("expected no _created sample in text format, got:\n%s", body)
+	}
+
+	body := scrape(string(expfmt.FmtOpenMetrics))
+	if !strings.Contains(body, "requests_total{path=\"/\"} 10.0\nrequests_created{path=\"/\"} 1000\n") {
+		t.Errorf("expected _created sample at boot time, got:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("expected OpenMetrics output to be terminated, got:\n%s", body)
+	}
+
+	// A counter going down was reset, so its created timestamp moves after boot.
+	value = 1
+	body = scrape(string(expfmt.FmtOpenMetrics))
+	if strings.Contains(body, "requests_created{path=\"/\"} 1000\n") {
+		t.Errorf("expected _created sample to change after reset, got:\n%s", body)
+	}
+}
+
+func TestWithTimestamp(t *testing.T) {
+	sampleTime := time.Unix(1600000000, 500000000)
+	desc := prometheus.NewDesc("test_total", "Test.", nil, nil)
+
+	ch := make(chan prometheus.Metric, 2)
+	out, flush := withTimestamp(ch, sampleTime)
+	out <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, 1)
+	out <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, 2)
+	flush()
+	close(ch)
+
+	n := 0
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		if pb.GetTimestampMs() != 1600000000500 {
+			t.Errorf("expected timestamp 1600000000500, got %d", pb.GetTimestampMs())
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 metrics, got %d", n)
+	}
+}
+
+func TestSDTargetGroups(t *testing.T) {
+	computers := []ds_computer{
+		{DS_dNSHostName: "SRV01.example.com", DS_distinguishedName: "CN=SRV01,OU=Servers,DC=example,DC=com", DS_operatingSystem: "Windows Server 2019 Standard"},
+		{DS_dNSHostName: "WS01.example.com", DS_distinguishedName: "CN=WS01,OU=Workstations,DC=example,DC=com", DS_operatingSystem: "Windows 10 Enterprise"},
+		{DS_dNSHostName: "SRV02.example.com", DS_distinguishedName: "CN=SRV02,OU=Web,OU=Servers,DC=example,DC=com", DS_operatingSystem: "Windows Server 2016 Standard"},
+		// Computers without a DNS host name can't be scraped.
+		{DS_distinguishedName: "CN=STALE,OU=Servers,DC=example,DC=com", DS_operatingSystem: "Windows Server 2012 R2 Standard"},
+	}
+	cases := []struct {
+		filter   sdFilter
+		expected []string
+	}{
+		{sdFilter{}, []string{"srv01.example.com:9182", "srv02.example.com:9182", "ws01.example.com:9182"}},
+		{sdFilter{bases: []string{"ou=servers,DC=example,DC=com"}}, []string{"srv01.example.com:9182", "srv02.example.com:9182"}},
+		{sdFilter{bases: []string{"OU=Web,OU=Servers,DC=example,DC=com", "OU=Workstations,DC=example,DC=com"}}, []string{"srv02.example.com:9182", "ws01.example.com:9182"}},
+		{sdFilter{osPatterns: []string{"windows server 2019*"}}, []string{"srv01.example.com:9182"}},
+		{sdFilter{osPatterns: []string{"windows server*", "*2016*"}}, []string{"srv02.example.com:9182"}},
+	}
+	for _, c := range cases {
+		groups, err := sdTargetGroups(computers, c.filter, 9182)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var targets []string
+		for _, g := range groups {
+			targets = append(targets, g.Targets...)
+		}
+		if strings.Join(targets, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("filter %+v: expected %v, got %v", c.filter, c.expected, targets)
+		}
+	}
+
+	if _, err := sdTargetGroups(computers, sdFilter{osPatterns: []string{"["}}, 9182); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+
+	// Query parameters only narrow the configured filter.
+	configured := sdFilter{bases: []string{"OU=Servers,DC=example,DC=com"}, osPatterns: []string{"Windows Server*"}}
+	narrowCases := []struct {
+		ous      []string
+		os       string
+		expected sdFilter
+		allowed  bool
+	}{
+		{nil, "", configured, true},
+		{[]string{"ou=servers,dc=example,dc=com"}, "", sdFilter{bases: []string{"ou=servers,dc=example,dc=com"}, osPatterns: []string{"Windows Server*"}}, true},
+		{[]string{"OU=Web,OU=Servers,DC=example,DC=com"}, "*2016*", sdFilter{bases: []string{"OU=Web,OU=Servers,DC=example,DC=com"}, osPatterns: []string{"Windows Server*", "*2016*"}}, true},
+		{[]string{"OU=Workstations,DC=example,DC=com"}, "", sdFilter{}, false},
+		{[]string{"DC=example,DC=com"}, "", sdFilter{}, false},
+		{[]string{"OU=Web,OU=Servers,DC=example,DC=com", "OU=Workstations,DC=example,DC=com"}, "", sdFilter{}, false},
+	}
+	for _, c := range narrowCases {
+		filter, err := configured.narrow(c.ous, c.os)
+		if (err == nil) != c.allowed {
+			t.Errorf("ou %v: expected allowed %t, got error %v", c.ous, c.allowed, err)
+			continue
+		}
+		if c.allowed && !reflect.DeepEqual(filter, c.expected) {
+			t.Errorf("Filter do not match!\nExpected result: %+v\nActual result: %+v", c.expected, filter)
+		}
+	}
+	if _, err := (sdFilter{}).narrow([]string{"DC=example,DC=com"}, ""); err != nil {
+		t.Errorf("Expected any ou to be allowed without search bases, got %v", err)
+	}
+}
+
+func TestPerfObjectInfos(t *testing.T) {
+	objects := []*perflib.PerfObject{
+		{
+			Name:     "Processor",
+			HelpText: "The Processor performance object consists of counters that measure aspects of processor activity.",
+			CounterDefs: []*perflib.PerfCounterDef{
+				{Name: "% Processor Time", IsCounter: true},
+				{Name: "Interrupts/sec", IsCounter: true},
+			},
+			Instances: []*perflib.PerfInstance{{Name: "0"}, {Name: "_Total"}},
+		},
+		// Objects without instances have a single unnamed one.
+		{
+			Name:        "Memory",
+			CounterDefs: []*perflib.PerfCounterDef{{Name: "Available Bytes"}, {Name: "Cache Faults/sec", IsCounter: true}},
+			Instances:   []*perflib.PerfInstance{{}},
+		},
+	}
+	expected := []perfObjectInfo{
+		{
+			Name:      "Memory",
+			Counters:  []perfCounterInfo{{Name: "Available Bytes", Type: "gauge"}, {Name: "Cache Faults/sec", Type: "counter"}},
+			Instances: []string{},
+		},
+		{
+			Name:      "Processor",
+			Help:      "The Processor performance object consists of counters that measure aspects of processor activity.",
+			Counters:  []perfCounterInfo{{Name: "% Processor Time", Type: "counter"}, {Name: "Interrupts/sec", Type: "counter"}},
+			Instances: []string{"0", "_Total"},
+		},
+	}
+	if got := perfObjectInfos(objects); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestPerfCounterGUIDs(t *testing.T) {
+	f, err := os.Open("installer/windows_exporter.man")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var manifest struct {
+		Providers []struct {
+			GUID        string `xml:"providerGuid,attr"`
+			CounterSets []struct {
+				GUID string `xml:"guid,attr"`
+			} `xml:"counterSet"`
+		} `xml:"instrumentation>counters>provider"`
+	}
+	if err := xml.NewDecoder(f).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Providers) != 1 || len(manifest.Providers[0].CounterSets) != 1 {
+		t.Fatalf("expected a single provider with a single counter set, got %+v", manifest.Providers)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		manifest string
+		guid     windows.GUID
+	}{
+		{"provider", manifest.Providers[0].GUID, perfProviderGUID},
+		{"counter set", manifest.Providers[0].CounterSets[0].GUID, perfCounterSetGUID},
+	} {
+		expected, err := windows.GUIDFromString(tc.manifest)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if tc.guid != expected {
+			t.Errorf("GUID of the %s does not match the manifest!\nExpected result: %s\nActual result: %s", tc.name, expected, tc.guid)
+		}
+	}
+}
+
+func TestRemoteWriteSeries(t *testing.T) {
+	desc := prometheus.NewDesc("latency_seconds", "Latency.", []string{"instance"}, nil)
+	h := prometheus.MustNewConstHistogram(desc, 3, 2.5, map[float64]uint64{0.1: 1, 1: 2}, "db01")
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	name := "latency_seconds"
+	families := []*dto.MetricFamily{{Name: &name, Type: dto.MetricType_HISTOGRAM.Enum(), Metric: []*dto.Metric{&m}}}
+	extra := []remoteWriteLabel{{name: "instance", value: "HOST01"}, {name: "job", value: "windows_exporter"}}
+
+	var got []string
+	for _, s := range remoteWriteSeriesOf(families, extra, time.Unix(1600000000, 0)) {
+		var labels []string
+		for _, l := range s.labels {
+			labels = append(labels, l.name+"="+l.value)
+		}
+		got = append(got, fmt.Sprintf("%s %v %d", strings.Join(labels, ","), s.value, s.timestamp))
+	}
+	// The instance label of the metric takes precedence.
+	expected := []string{
+		"__name__=latency_seconds_bucket,instance=db01,job=windows_exporter,le=0.1 1 1600000000000",
+		"__name__=latency_seconds_bucket,instance=db01,job=windows_exporter,le=1 2 1600000000000",
+		"__name__=latency_seconds_bucket,instance=db01,job=windows_exporter,le=+Inf 3 1600000000000",
+		"__name__=latency_seconds_sum,instance=db01,job=windows_exporter 2.5 1600000000000",
+		"__name__=latency_seconds_count,instance=db01,job=windows_exporter 3 1600000000000",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected series\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestRemoteWriteSend(t *testing.T) {
+	payload := encodeWriteRequest([]remoteWriteSeries{{labels: []remoteWriteLabel{{name: "__name__", value: "up"}}, value: 1, timestamp: 1600000000000}})
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if user, password, _ := r.BasicAuth(); user != "pusher" || password != "" {
+			t.Errorf("unexpected credentials %s:%s", user, password)
+		}
+		compressed, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if body, err := snappy.Decode(nil, compressed); err != nil || !bytes.Equal(body, payload) {
+			t.Errorf("expected the snappy encoded payload, got %v (%v)", body, err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	w := &remoteWriter{url: server.URL, username: "pusher", client: server.Client()}
+
+	cases := []struct {
+		status    int
+		retry     bool
+		succeeded bool
+	}{
+		{http.StatusNoContent, false, true},
+		{http.StatusBadRequest, false, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusServiceUnavailable, true, false},
+	}
+	for _, c := range cases {
+		status = c.status
+		retry, err := w.send(snappy.Encode(nil, payload))
+		if retry != c.retry || (err == nil) != c.succeeded {
+			t.Errorf("status %d: got retry %v and error %v", c.status, retry, err)
+		}
+	}
+}
+
+func TestDiffMetricSchemas(t *testing.T) {
+	parse := func(text string) map[string]*metricSchema {
+		var parser expfmt.TextParser
+		families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var list []*dto.MetricFamily
+		for _, mf := range families {
+			list = append(list, mf)
+		}
+		return metricSchemasOf(list, "windows_")
+	}
+	baseline := parse(`windows_cpu_time_total{core="0,0",mode="idle"} 1
+windows_cpu_time_total{core="0,0",mode="user"} 1
+windows_disk_free_bytes{volume="C:"} 1
+windows_net_bytes_total{nic="eth0"} 1
+windows_os_processes 1
+windows_os_users 1
+go_goroutines 10
+`)
+	current := parse(`windows_cpu_time_total{core="0,0",mode="idle"} 2
+windows_cpu_time_total{core="0,0",mode="user"} 2
+windows_logical_disk_free_bytes{volume="C:"} 1
+windows_net_bytes_total{nic="eth0",direction="sent"} 1
+windows_os_processes 1
+windows_os_threads 1
+windows_os_logons 1
+`)
+
+	var buf bytes.Buffer
+	writeMetricSchemaDiff(&buf, diffMetricSchemas(baseline, current))
+	// The label-less metrics are ambiguous, so they aren't paired.
+	expected := `- windows_os_users{}
++ windows_os_logons{}
++ windows_os_threads{}
+~ windows_disk_free_bytes{volume} -> windows_logical_disk_free_bytes{volume}
+~ windows_net_bytes_total{nic} -> windows_net_bytes_total{direction,nic}
+1 removed, 2 added, 2 renamed
+`
+	if buf.String() != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.16.0", "0.16.0", 0},
+		{"v0.16.0", "0.16.0", 0},
+		{"0.16.1", "0.16.0", 1},
+		{"0.9.0", "0.16.0", -1},
+		{"1.0", "1.0.0", 0},
+		{"0.17.0-rc.1", "0.17.0", -1},
+		{"0.17.0-rc.2", "0.17.0-rc.1", 1},
+		{"0.17.0-rc.1", "0.16.0", 1},
+		{"0.16.0+build.1", "0.16.0", 0},
+		{"0.16.0", "", 1},
+	}
+	for _, c := range cases {
+		if actual := compareVersions(c.a, c.b); actual != c.expected {
+			t.Errorf("compareVersions(%q, %q): expected %d, got %d", c.a, c.b, c.expected, actual)
+		}
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	entries := []releaseFeedEntry{
+		{TagName: "v0.16.0"},
+		{TagName: "v0.18.0", Draft: true},
+		{TagName: "v0.17.0-rc.1", Prerelease: true},
+		{TagName: "v0.15.0"},
+	}
+	for channel, expected := range map[string]string{
+		"stable":     "0.16.0",
+		"prerelease": "0.17.0-rc.1",
+	} {
+		if actual := latestRelease(entries, channel); actual != expected {
+			t.Errorf("channel %s: expected latest release %q, got %q", channel, expected, actual)
+		}
+	}
+}
+
+func TestExposureShard(t *testing.T) {
+	for _, s := range []string{"", "1", "2/2", "a/2", "0/0", "-1/2", "0/2/3"} {
+		if _, err := parseExposureShard(s); err == nil {
+			t.Errorf("expected shard %q to be invalid", s)
+		}
+	}
+
+	// Every metric belongs to exactly one of the shards.
+	const total = 3
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		var families []*dto.MetricFamily
+		for i := 0; i < 30; i++ {
+			name := fmt.Sprintf("windows_test_%d", i)
+			families = append(families, &dto.MetricFamily{Name: &name})
+		}
+		return families, nil
+	})
+	seen := make(map[string]int)
+	for i := 0; i < total; i++ {
+		shard, err := parseExposureShard(fmt.Sprintf("%d/%d", i, total))
+		if err != nil {
+			t.Fatal(err)
+		}
+		families, err := shard.gatherer(g).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(families) == 0 {
+			t.Errorf("expected shard %d to hold metrics", i)
+		}
+		for _, mf := range families {
+			seen[mf.GetName()]++
+		}
+	}
+	if len(seen) != 30 {
+		t.Errorf("expected all 30 metrics to be sharded, got %d", len(seen))
+	}
+	for name, n := range seen {
+		if n != 1 {
+			t.Errorf("expected %s in one shard, got %d", name, n)
+		}
+	}
+}
+
+func TestCollectorAccess(t *testing.T) {
+	enabled := map[string]collector.Collector{"cpu": nil, "memory": nil, "os": nil, "service": nil}
+	access, err := newCollectorAccess([]config.CollectorAccess{
+		{Identities: []string{"tenant"}, Collectors: "cpu,memory"},
+		{Identities: []string{"platform", "prom.example.com"}},
+	}, enabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		user      string
+		cert      *x509.Certificate
+		requested []string
+		served    []string
+		expected  []string
+		allowed   bool
+		// unverified requests come from a listener not checking basic
+		// auth passwords.
+		unverified bool
+	}{
+		{"unknown client", "", nil, nil, nil, nil, false, false},
+		{"unknown user", "other", nil, nil, nil, nil, false, false},
+		{"restricted user", "Tenant", nil, nil, nil, []string{"cpu", "memory"}, true, false},
+		{"restricted user requesting allowed", "tenant", nil, []string{"cpu"}, nil, []string{"cpu"}, true, false},
+		{"restricted user requesting others", "tenant", nil, []string{"cpu", "service"}, nil, nil, false, false},
+		{"restricted user on endpoint", "tenant", nil, nil, []string{"memory", "os"}, []string{"memory"}, true, false},
+		{"restricted user on other endpoint", "tenant", nil, nil, []string{"os"}, nil, false, false},
+		{"unrestricted user", "platform", nil, nil, nil, nil, true, false},
+		{"unrestricted user with unverified password", "platform", nil, nil, nil, nil, false, true},
+		{"unrestricted certificate SAN", "", &x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"prom.example.com"}}, []string{"service"}, nil, []string{"service"}, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if c.user != "" {
+				r.SetBasicAuth(c.user, "secret")
+				if !c.unverified {
+					r = r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true))
+				}
+			}
+			if c.cert != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c.cert}}}
+			}
+			collectors, err := access.restrict(r, c.requested, c.served)
+			if (err == nil) != c.allowed {
+				t.Fatalf("Expected allowed %t, got error %v", c.allowed, err)
+			}
+			if !reflect.DeepEqual(collectors, c.expected) {
+				t.Errorf("Collectors do not match!\nExpected result: %v\nActual result: %v", c.expected, collectors)
+			}
+		})
+	}
+
+	// Endpoints beyond the collectors are restricted to clients served all
+	// of them.
+	for user, expected := range map[string]int{"platform": http.StatusOK, "tenant": http.StatusForbidden, "": http.StatusForbidden} {
+		r := httptest.NewRequest("GET", "/sd", nil)
+		if user != "" {
+			r.SetBasicAuth(user, "secret")
+			r = r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true))
+		}
+		w := httptest.NewRecorder()
+		withFullAccess(access, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+		if w.Code != expected {
+			t.Errorf("Expected status %d for user %q, got %d", expected, user, w.Code)
+		}
+	}
+
+	if _, err := newCollectorAccess([]config.CollectorAccess{{Identities: []string{"tenant"}, Collectors: "iis"}}, enabled); err == nil {
+		t.Error("Expected an error for a collector that isn't enabled")
+	}
+}
+
+func TestConfigReloadAccess(t *testing.T) {
+	access, err := newCollectorAccess([]config.CollectorAccess{
+		{Identities: []string{"tenant"}, Collectors: "cpu"},
+		{Identities: []string{"platform"}},
+	}, map[string]collector.Collector{"cpu": nil, "os": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := kingpin.New("windows_exporter", "")
+	enabled := app.Flag("collectors.enabled", "").Default("").String()
+	value := app.Flag("collector.test.value", "").Default("0").Int()
+	reloader := newConfigReloader(path, app, nil, enabled, &liveCollectors{collectors: map[string]collector.Collector{}})
+	if reloader.current, err = config.NewResolver(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("collector:\n  test:\n    value: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	handler := withFullAccess(access, reloader)
+
+	for _, c := range []struct {
+		user     string
+		status   int
+		expected int
+	}{
+		{"tenant", http.StatusForbidden, 0},
+		{"platform", http.StatusOK, 2},
+	} {
+		r := httptest.NewRequest("POST", "/-/reload", nil)
+		r.SetBasicAuth(c.user, "secret")
+		r = r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for user %q, got %d", c.status, c.user, w.Code)
+		}
+		if *value != c.expected {
+			t.Errorf("Expected value %d after reload by %q, got %d", c.expected, c.user, *value)
+		}
+	}
+}
+
+func TestProbeTarget(t *testing.T) {
+	host := &remoteHost{
+		config:     config.RemoteHost{Host: "edge01.example.com"},
+		collectors: map[string]collector.Collector{"cpu": nil, "memory": nil, "logical_disk": nil},
+	}
+	access, err := newCollectorAccess([]config.CollectorAccess{
+		{Identities: []string{"tenant"}, Collectors: "cpu"},
+		{Identities: []string{"platform"}},
+	}, map[string]collector.Collector{"cpu": nil, "memory": nil, "logical_disk": nil, "os": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProbeHandler(&metricsHandler{onDemand: map[string]bool{"logical_disk": true}, access: access}, []*remoteHost{host})
+
+	cases := []struct {
+		name     string
+		query    string
+		user     string
+		status   int
+		expected []string
+	}{
+		{"all collectors", "target=EDGE01.example.com", "platform", http.StatusOK, []string{"cpu", "memory"}},
+		{"requested collectors", "target=edge01.example.com&collectors=cpu,logical_disk", "platform", http.StatusOK, []string{"cpu", "logical_disk"}},
+		{"restricted client", "target=edge01.example.com", "tenant", http.StatusOK, []string{"cpu"}},
+		{"restricted client requesting others", "target=edge01.example.com&collectors=memory", "tenant", http.StatusForbidden, nil},
+		{"missing target", "collectors=cpu", "platform", http.StatusBadRequest, nil},
+		{"target not in remote_hosts", "target=edge02.example.com", "platform", http.StatusForbidden, nil},
+		{"local only collector", "target=edge01.example.com&collectors=os", "platform", http.StatusBadRequest, nil},
+		{"collector not configured for the host", "target=edge01.example.com&collectors=net", "platform", http.StatusBadRequest, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/probe?"+c.query, nil)
+			r.SetBasicAuth(c.user, "secret")
+			r = r.WithContext(context.WithValue(r.Context(), basicAuthVerifiedKey{}, true))
+			h, collectors, err := p.target(r)
+			status := http.StatusOK
+			if err != nil {
+				status = err.(*probeError).status
+			}
+			if status != c.status {
+				t.Fatalf("Expected status %d, got %d: %v", c.status, status, err)
+			}
+			if err != nil {
+				return
+			}
+			if h != host {
+				t.Errorf("Expected host %s, got %s", host.config.Host, h.config.Host)
+			}
+			names := keys(collectors)
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, c.expected) {
+				t.Errorf("Collectors do not match!\nExpected result: %v\nActual result: %v", c.expected, names)
+			}
+		})
+	}
+}
+
+func TestBasicAuthVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "windows_exporter_web_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	withUsers := filepath.Join(dir, "users.yml")
+	withoutUsers := filepath.Join(dir, "tls.yml")
+	if err := ioutil.WriteFile(withUsers, []byte("basic_auth_users:\n  platform: $2y$10$X0h1gDsPszWURQaxFh.zoubFi6DXncSjhoQNJgRrnGs7EsimhC7zG\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(withoutUsers, []byte("tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, expected := range map[string][]string{withUsers: {"platform"}, withoutUsers: nil, "": nil} {
+		var ids []string
+		handler := withBasicAuthVerified(file, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ids = requestIdentities(r)
+		}))
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.SetBasicAuth("platform", "secret")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Identities with web config %q do not match!\nExpected result: %v\nActual result: %v", file, expected, ids)
+		}
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "windows_exporter_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := kingpin.New("windows_exporter", "")
+	enabled := app.Flag("collectors.enabled", "").Default("").String()
+	value := app.Flag("collector.test.value", "").Default("0").Int()
+	write("{}\n")
+	r := newConfigReloader(path, app, nil, enabled, &liveCollectors{collectors: map[string]collector.Collector{}})
+	if r.current, err = config.NewResolver(path); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/-/reload", nil))
+		return w.Code
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/-/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be rejected with %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	cases := []struct {
+		name     string
+		content  string
+		status   int
+		expected int
+	}{
+		{"changed value", "collector:\n  test:\n    value: 2\n", http.StatusOK, 2},
+		{"invalid value", "collector:\n  test:\n    value: two\n", http.StatusInternalServerError, 2},
+		{"invalid value retried", "collector:\n  test:\n    value: two\n", http.StatusInternalServerError, 2},
+		{"unknown collector", "collectors:\n  enabled: nonexistent\ncollector:\n  test:\n    value: 3\n", http.StatusInternalServerError, 3},
+		{"invalid file", "collector: [\n", http.StatusInternalServerError, 3},
+		{"default value", "{}\n", http.StatusOK, 0},
+	}
+	for _, c := range cases {
+		write(c.content)
+		if status := post(); status != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, status)
+		}
+		if *value != c.expected {
+			t.Errorf("%s: expected value %d, got %d", c.name, c.expected, *value)
+		}
+	}
+}
+
+func TestConfigReport(t *testing.T) {
+	var report configReport
+	var b bytes.Buffer
+	if !report.write(&b, "config.yml") {
+		t.Error("Expected an empty report to be valid")
+	}
+	if expected := "config.yml is valid\n"; b.String() != expected {
+		t.Errorf("Report does not match!\nExpected result: %q\nActual result: %q", expected, b.String())
+	}
+
+	report.warnf("collector %s: performance object %q not found on this host", "gpu", "GPU Engine")
+	b.Reset()
+	if !report.write(&b, "config.yml") {
+		t.Error("Expected a report with only warnings to be valid")
+	}
+
+	report.errorf("unknown setting %s", "collector.service.servces-where")
+	b.Reset()
+	if report.write(&b, "config.yml") {
+		t.Error("Expected a report with errors to be invalid")
+	}
+	expected := `error: unknown setting collector.service.servces-where
+warning: collector gpu: performance object "GPU Engine" not found on this host
+config.yml: 1 errors, 1 warnings
+`
+	if b.String() != expected {
+		t.Errorf("Report does not match!\nExpected result: %q\nActual result: %q", expected, b.String())
+	}
+}
@@ -8,7 +8,8 @@ require (
 	github.com/StackExchange/wmi v0.0.0-20180725035823-b12b22c5341f
 	github.com/dimchansky/utfbom v1.1.0
 	github.com/go-kit/kit v0.10.0
-	github.com/go-ole/go-ole v1.2.1 // indirect
+	github.com/go-ole/go-ole v1.2.1
+	github.com/golang/snappy v0.0.2
 	github.com/google/go-cmp v0.5.1 // indirect
 	github.com/leoluk/perflib_exporter v0.1.0
 	github.com/prometheus/client_golang v1.8.0
@@ -18,6 +19,7 @@ require (
 	github.com/sirupsen/logrus v1.6.0
 	golang.org/x/sys v0.0.0-20201015000850-e3ed0017c211
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	google.golang.org/protobuf v1.23.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	gopkg.in/yaml.v2 v2.4.0
 )
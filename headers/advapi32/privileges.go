@@ -0,0 +1,119 @@
+package advapi32
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32                 = windows.NewLazySystemDLL("advapi32.dll")
+	procLookupPrivilegeNameW = advapi32.NewProc("LookupPrivilegeNameW")
+)
+
+// Privilege is a privilege held by an access token.
+type Privilege struct {
+	Name    string
+	Enabled bool
+}
+
+// TokenPrivileges returns the privileges held by the token, which must have
+// been opened with TOKEN_QUERY.
+func TokenPrivileges(token windows.Token) ([]Privilege, error) {
+	privileges, err := tokenPrivileges(token)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Privilege, 0, privileges.PrivilegeCount)
+	for _, p := range privileges.AllPrivileges() {
+		name, err := lookupPrivilegeName(p.Luid)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Privilege{
+			Name:    name,
+			Enabled: p.Attributes&windows.SE_PRIVILEGE_ENABLED != 0,
+		})
+	}
+	return result, nil
+}
+
+// RemovePrivileges removes the privileges of the token not named in keep,
+// ignoring case, and returns the names of those removed. Removed privileges
+// can't be added back to the token. The token must have been opened with
+// TOKEN_QUERY and TOKEN_ADJUST_PRIVILEGES.
+// https://docs.microsoft.com/en-us/windows/win32/api/securitybaseapi/nf-securitybaseapi-adjusttokenprivileges
+func RemovePrivileges(token windows.Token, keep []string) ([]string, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, name := range keep {
+		kept[strings.ToLower(name)] = true
+	}
+	privileges, err := tokenPrivileges(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, p := range privileges.AllPrivileges() {
+		name, err := lookupPrivilegeName(p.Luid)
+		if err != nil {
+			return nil, err
+		}
+		if kept[strings.ToLower(name)] {
+			continue
+		}
+		state := windows.Tokenprivileges{PrivilegeCount: 1}
+		state.Privileges[0] = windows.LUIDAndAttributes{Luid: p.Luid, Attributes: windows.SE_PRIVILEGE_REMOVED}
+		if err := windows.AdjustTokenPrivileges(token, false, &state, 0, nil, nil); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// LookupPrivilegeValue returns the LUID of the privilege of the given name on
+// the local computer, failing for unknown privileges.
+func LookupPrivilegeValue(name string) (windows.LUID, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return windows.LUID{}, err
+	}
+	var luid windows.LUID
+	err = windows.LookupPrivilegeValue(nil, p, &luid)
+	return luid, err
+}
+
+func tokenPrivileges(token windows.Token) (*windows.Tokenprivileges, error) {
+	var size uint32
+	err := windows.GetTokenInformation(token, windows.TokenPrivileges, nil, 0, &size)
+	if err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if err := windows.GetTokenInformation(token, windows.TokenPrivileges, &buf[0], size, &size); err != nil {
+		return nil, err
+	}
+	return (*windows.Tokenprivileges)(unsafe.Pointer(&buf[0])), nil
+}
+
+// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-lookupprivilegenamew
+func lookupPrivilegeName(luid windows.LUID) (string, error) {
+	size := uint32(64)
+	for {
+		buf := make([]uint16, size)
+		r1, _, err := procLookupPrivilegeNameW.Call(
+			0,
+			uintptr(unsafe.Pointer(&luid)),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&size)),
+		)
+		if r1 != 0 {
+			return windows.UTF16ToString(buf[:size]), nil
+		}
+		if err != windows.ERROR_INSUFFICIENT_BUFFER {
+			return "", err
+		}
+	}
+}
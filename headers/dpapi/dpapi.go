@@ -0,0 +1,51 @@
+package dpapi
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cryptProtectUIForbidden is CRYPTPROTECT_UI_FORBIDDEN. Services can't
+// display prompts.
+const cryptProtectUIForbidden = 0x1
+
+// dataBlob is a wrapper of DATA_BLOB
+// https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/aa381414(v=vs.85)
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+var (
+	crypt32                = windows.NewLazySystemDLL("crypt32.dll")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+)
+
+// Unprotect decrypts data that was protected with CryptProtectData, either by
+// the current user or with the machine scope.
+// https://docs.microsoft.com/en-us/windows/win32/api/dpapi/nf-dpapi-cryptunprotectdata
+func Unprotect(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	in := dataBlob{cbData: uint32(len(data)), pbData: &data[0]}
+	var out dataBlob
+	r1, _, err := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(&in)),
+		0,
+		0,
+		0,
+		0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if r1 == 0 {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.pbData)))
+
+	plain := make([]byte, out.cbData)
+	copy(plain, (*[1 << 20]byte)(unsafe.Pointer(out.pbData))[:out.cbData:out.cbData])
+	return plain, nil
+}
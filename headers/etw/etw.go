@@ -0,0 +1,515 @@
+package etw
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	wnodeFlagTracedGUID         = 0x00020000
+	eventTraceRealTimeMode      = 0x00000100
+	eventTraceControlStop       = 1
+	eventControlCodeEnable      = 1
+	processTraceModeRealTime    = 0x00000100
+	processTraceModeEventRecord = 0x10000000
+	eventHeaderFlag32BitHeader  = 0x0020
+
+	// PROPERTY_FLAGS
+	propertyStruct      = 0x1
+	propertyParamLength = 0x2
+	propertyParamCount  = 0x4
+
+	// TDH_IN_TYPE of the fixed size integers, which can be referenced as
+	// the length of other properties.
+	tdhInTypeInt8   = 3
+	tdhInTypeUInt64 = 10
+
+	errorInsufficientBuffer = 122
+	maxLoggerNameLength     = 1024
+	sessionBufferSizeKB     = 64
+)
+
+var (
+	advapi32            = windows.NewLazySystemDLL("advapi32.dll")
+	procStartTraceW     = advapi32.NewProc("StartTraceW")
+	procControlTraceW   = advapi32.NewProc("ControlTraceW")
+	procEnableTraceEx2  = advapi32.NewProc("EnableTraceEx2")
+	procOpenTraceW      = advapi32.NewProc("OpenTraceW")
+	procProcessTrace    = advapi32.NewProc("ProcessTrace")
+	procCloseTrace      = advapi32.NewProc("CloseTrace")
+	tdh                 = windows.NewLazySystemDLL("tdh.dll")
+	procTdhGetEventInfo = tdh.NewProc("TdhGetEventInformation")
+	procTdhFormatProp   = tdh.NewProc("TdhFormatProperty")
+)
+
+// wnodeHeader is a wrapper of WNODE_HEADER
+// https://docs.microsoft.com/en-us/windows/win32/etw/wnode-header
+type wnodeHeader struct {
+	BufferSize        uint32
+	ProviderID        uint32
+	HistoricalContext uint64
+	TimeStamp         int64
+	GUID              windows.GUID
+	ClientContext     uint32
+	Flags             uint32
+}
+
+// eventTraceProperties is a wrapper of EVENT_TRACE_PROPERTIES, followed by
+// the buffer holding the session name.
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_properties
+type eventTraceProperties struct {
+	Wnode               wnodeHeader
+	BufferSize          uint32
+	MinimumBuffers      uint32
+	MaximumBuffers      uint32
+	MaximumFileSize     uint32
+	LogFileMode         uint32
+	FlushTimer          uint32
+	EnableFlags         uint32
+	AgeLimit            int32
+	NumberOfBuffers     uint32
+	FreeBuffers         uint32
+	EventsLost          uint32
+	BuffersWritten      uint32
+	LogBuffersLost      uint32
+	RealTimeBuffersLost uint32
+	LoggerThreadID      windows.Handle
+	LogFileNameOffset   uint32
+	LoggerNameOffset    uint32
+
+	loggerName [maxLoggerNameLength]uint16
+}
+
+func newEventTraceProperties() *eventTraceProperties {
+	p := &eventTraceProperties{}
+	p.Wnode.BufferSize = uint32(unsafe.Sizeof(*p))
+	p.LoggerNameOffset = uint32(unsafe.Offsetof(p.loggerName))
+	return p
+}
+
+// eventTraceLogfile is a wrapper of EVENT_TRACE_LOGFILEW. The current event
+// and the log file header aren't used in real time mode, and are left opaque
+// as their alignment differs between 386 and amd64.
+// https://docs.microsoft.com/en-us/windows/win32/api/evntrace/ns-evntrace-event_trace_logfilew
+type eventTraceLogfile struct {
+	LogFileName         *uint16
+	LoggerName          *uint16
+	CurrentTime         int64
+	BuffersRead         uint32
+	ProcessTraceMode    uint32
+	_                   [352 + 2*unsafe.Sizeof(uintptr(0))]byte
+	BufferCallback      uintptr
+	BufferSize          uint32
+	Filled              uint32
+	EventsLost          uint32
+	EventRecordCallback uintptr
+	IsKernelTrace       uint32
+	Context             uintptr
+}
+
+// eventDescriptor is a wrapper of EVENT_DESCRIPTOR
+// https://docs.microsoft.com/en-us/windows/win32/api/evntprov/ns-evntprov-event_descriptor
+type eventDescriptor struct {
+	ID      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+// eventRecord is a wrapper of EVENT_RECORD, including its EVENT_HEADER
+// https://docs.microsoft.com/en-us/windows/win32/api/evntcons/ns-evntcons-event_record
+type eventRecord struct {
+	Size              uint16
+	HeaderType        uint16
+	Flags             uint16
+	EventProperty     uint16
+	ThreadID          uint32
+	ProcessID         uint32
+	TimeStamp         int64
+	ProviderID        windows.GUID
+	EventDescriptor   eventDescriptor
+	ProcessorTime     uint64
+	ActivityID        windows.GUID
+	BufferContext     uint32
+	ExtendedDataCount uint16
+	UserDataLength    uint16
+	ExtendedData      uintptr
+	UserData          unsafe.Pointer
+	UserContext       uintptr
+}
+
+// traceEventInfoHeader is the start of TRACE_EVENT_INFO, followed by
+// PropertyCount EVENT_PROPERTY_INFO.
+// https://docs.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-trace_event_info
+type traceEventInfoHeader struct {
+	ProviderGUID          windows.GUID
+	EventGUID             windows.GUID
+	EventDescriptor       eventDescriptor
+	DecodingSource        uint32
+	ProviderNameOffset    uint32
+	LevelNameOffset       uint32
+	ChannelNameOffset     uint32
+	KeywordsNameOffset    uint32
+	TaskNameOffset        uint32
+	OpcodeNameOffset      uint32
+	EventMessageOffset    uint32
+	ProviderMessageOffset uint32
+	BinaryXMLOffset       uint32
+	BinaryXMLSize         uint32
+	EventNameOffset       uint32
+	EventAttributesOffset uint32
+	PropertyCount         uint32
+	TopLevelPropertyCount uint32
+	Flags                 uint32
+}
+
+// eventPropertyInfo is a wrapper of EVENT_PROPERTY_INFO for non-struct
+// properties.
+// https://docs.microsoft.com/en-us/windows/win32/api/tdh/ns-tdh-event_property_info
+type eventPropertyInfo struct {
+	Flags         uint32
+	NameOffset    uint32
+	InType        uint16
+	OutType       uint16
+	MapNameOffset uint32
+	Count         uint16
+	Length        uint16
+	Reserved      uint32
+}
+
+// Provider is an ETW provider enabled in a session.
+type Provider struct {
+	GUID windows.GUID
+	// Level is the most verbose level of the events delivered, e.g. 4 for
+	// informational events.
+	Level uint8
+	// MatchAnyKeyword restricts the events to those with any of the
+	// keywords. 0 for all events.
+	MatchAnyKeyword uint64
+}
+
+// Event is an event delivered to a session. It's only valid during the call
+// of the session's handler.
+type Event struct {
+	Provider  windows.GUID
+	ID        uint16
+	Version   uint8
+	Opcode    uint8
+	Task      uint16
+	ProcessID uint32
+	Time      time.Time
+	// ActivityID correlates the events of an activity, e.g. the start and
+	// stop events of a request.
+	ActivityID windows.GUID
+
+	record *eventRecord
+}
+
+// Session is a real time ETW session.
+type Session struct {
+	name    string
+	handle  uint64
+	trace   uint64
+	handler func(*Event)
+	done    chan struct{}
+}
+
+var (
+	// Callbacks can't be released, so a single one dispatches the events
+	// of all sessions.
+	eventCallback     uintptr
+	eventCallbackOnce sync.Once
+	sessions          sync.Map // uintptr -> *Session
+	nextSessionID     uintptr
+	nextSessionIDMu   sync.Mutex
+)
+
+// uint64Args returns the arguments passing v by value, which takes two
+// arguments on 386.
+func uint64Args(v uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		return []uintptr{uintptr(v), uintptr(v >> 32)}
+	}
+	return []uintptr{uintptr(v)}
+}
+
+func dispatchEvent(record *eventRecord) uintptr {
+	if s, ok := sessions.Load(record.UserContext); ok {
+		ft := windows.Filetime{
+			LowDateTime:  uint32(record.TimeStamp),
+			HighDateTime: uint32(record.TimeStamp >> 32),
+		}
+		s.(*Session).handler(&Event{
+			Provider:   record.ProviderID,
+			ID:         record.EventDescriptor.ID,
+			Version:    record.EventDescriptor.Version,
+			Opcode:     record.EventDescriptor.Opcode,
+			Task:       record.EventDescriptor.Task,
+			ProcessID:  record.ProcessID,
+			ActivityID: record.ActivityID,
+			Time:       time.Unix(0, ft.Nanoseconds()),
+			record:     record,
+		})
+	}
+	return 0
+}
+
+// StartSession starts a real time session enabling the providers, and calls
+// handler for every event they write until the session is closed. handler is
+// called from a single goroutine. A session left over with the same name,
+// e.g. by a crashed process, is stopped first. Starting sessions requires
+// administrative privileges or membership in Performance Log Users.
+// https://docs.microsoft.com/en-us/windows/win32/etw/configuring-and-starting-an-event-tracing-session
+func StartSession(name string, providers []Provider, handler func(*Event)) (*Session, error) {
+	s := &Session{name: name, handler: handler, done: make(chan struct{})}
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for retry := true; ; retry = false {
+		props := newEventTraceProperties()
+		props.Wnode.Flags = wnodeFlagTracedGUID
+		// Timestamps are read from the QueryPerformanceCounter.
+		props.Wnode.ClientContext = 1
+		props.BufferSize = sessionBufferSizeKB
+		props.LogFileMode = eventTraceRealTimeMode
+		r1, _, _ := procStartTraceW.Call(
+			uintptr(unsafe.Pointer(&s.handle)),
+			uintptr(unsafe.Pointer(namePtr)),
+			uintptr(unsafe.Pointer(props)),
+		)
+		if r1 == 0 {
+			break
+		}
+		if windows.Errno(r1) != windows.ERROR_ALREADY_EXISTS || !retry {
+			return nil, windows.Errno(r1)
+		}
+		if err := stopSession(0, namePtr); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range providers {
+		args := uint64Args(s.handle)
+		args = append(args, uintptr(unsafe.Pointer(&p.GUID)), eventControlCodeEnable, uintptr(p.Level))
+		args = append(args, uint64Args(p.MatchAnyKeyword)...)
+		args = append(args, uint64Args(0)...)
+		args = append(args, 0, 0)
+		r1, _, _ := procEnableTraceEx2.Call(args...)
+		if r1 != 0 {
+			_ = stopSession(s.handle, namePtr)
+			return nil, windows.Errno(r1)
+		}
+	}
+
+	eventCallbackOnce.Do(func() {
+		eventCallback = windows.NewCallback(dispatchEvent)
+	})
+	nextSessionIDMu.Lock()
+	nextSessionID++
+	id := nextSessionID
+	nextSessionIDMu.Unlock()
+	sessions.Store(id, s)
+
+	logfile := eventTraceLogfile{
+		LoggerName:          namePtr,
+		ProcessTraceMode:    processTraceModeRealTime | processTraceModeEventRecord,
+		EventRecordCallback: eventCallback,
+		Context:             id,
+	}
+	r1, r2, err := procOpenTraceW.Call(uintptr(unsafe.Pointer(&logfile)))
+	s.trace = uint64(r1)
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		s.trace |= uint64(r2) << 32
+	}
+	// INVALID_PROCESSTRACE_HANDLE
+	if s.trace == ^uint64(0) || s.trace == 0xFFFFFFFF {
+		sessions.Delete(id)
+		_ = stopSession(s.handle, namePtr)
+		return nil, err
+	}
+
+	go func() {
+		defer close(s.done)
+		defer sessions.Delete(id)
+		// ProcessTrace blocks until the session is stopped.
+		_, _, _ = procProcessTrace.Call(uintptr(unsafe.Pointer(&s.trace)), 1, 0, 0)
+	}()
+	return s, nil
+}
+
+func stopSession(handle uint64, name *uint16) error {
+	props := newEventTraceProperties()
+	args := uint64Args(handle)
+	args = append(args, uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(props)), eventTraceControlStop)
+	r1, _, _ := procControlTraceW.Call(args...)
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// Close stops the session, waiting for the delivery of the pending events.
+func (s *Session) Close() error {
+	name, err := windows.UTF16PtrFromString(s.name)
+	if err != nil {
+		return err
+	}
+	err = stopSession(s.handle, name)
+	_, _, _ = procCloseTrace.Call(uint64Args(s.trace)...)
+	<-s.done
+	return err
+}
+
+// CloseAll closes the running sessions. Real time sessions outlive the process
+// that started them, so they should be closed before it exits.
+func CloseAll() error {
+	var lastErr error
+	sessions.Range(func(_, v interface{}) bool {
+		if err := v.(*Session).Close(); err != nil {
+			lastErr = err
+		}
+		return true
+	})
+	return lastErr
+}
+
+var (
+	// eventInfos caches the TRACE_EVENT_INFO of the events by provider, ID
+	// and version.
+	eventInfos   = make(map[eventInfoKey][]byte)
+	eventInfosMu sync.Mutex
+)
+
+type eventInfoKey struct {
+	provider windows.GUID
+	id       uint16
+	version  uint8
+}
+
+// eventInfo returns the TRACE_EVENT_INFO describing the event's properties.
+// https://docs.microsoft.com/en-us/windows/win32/api/tdh/nf-tdh-tdhgeteventinformation
+func (e *Event) eventInfo() ([]byte, error) {
+	key := eventInfoKey{provider: e.Provider, id: e.ID, version: e.Version}
+	eventInfosMu.Lock()
+	info, ok := eventInfos[key]
+	eventInfosMu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	var size uint32
+	r1, _, _ := procTdhGetEventInfo.Call(uintptr(unsafe.Pointer(e.record)), 0, 0, 0, uintptr(unsafe.Pointer(&size)))
+	if r1 != errorInsufficientBuffer {
+		return nil, windows.Errno(r1)
+	}
+	// The buffer must be aligned for TRACE_EVENT_INFO.
+	buf := make([]uint64, (size+7)/8)
+	r1, _, _ = procTdhGetEventInfo.Call(uintptr(unsafe.Pointer(e.record)), 0, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	info = (*[1 << 20]byte)(unsafe.Pointer(&buf[0]))[:size:size]
+
+	eventInfosMu.Lock()
+	eventInfos[key] = info
+	eventInfosMu.Unlock()
+	return info, nil
+}
+
+// TaskName returns the name of the event's task in the provider's manifest,
+// or an empty string if it has none.
+func (e *Event) TaskName() (string, error) {
+	info, err := e.eventInfo()
+	if err != nil {
+		return "", err
+	}
+	header := (*traceEventInfoHeader)(unsafe.Pointer(&info[0]))
+	if header.TaskNameOffset == 0 {
+		return "", nil
+	}
+	return windows.UTF16PtrToString((*uint16)(unsafe.Pointer(&info[header.TaskNameOffset]))), nil
+}
+
+// Properties returns the top level properties of the event formatted as
+// text. Properties following an array or a structure aren't returned.
+// https://docs.microsoft.com/en-us/windows/win32/api/tdh/nf-tdh-tdhformatproperty
+func (e *Event) Properties() (map[string]string, error) {
+	info, err := e.eventInfo()
+	if err != nil {
+		return nil, err
+	}
+	header := (*traceEventInfoHeader)(unsafe.Pointer(&info[0]))
+	count := int(header.TopLevelPropertyCount)
+	propertyInfos := (*[1 << 16]eventPropertyInfo)(unsafe.Pointer(&info[unsafe.Sizeof(*header)]))[:count:count]
+
+	pointerSize := uintptr(8)
+	if e.record.Flags&eventHeaderFlag32BitHeader != 0 {
+		pointerSize = 4
+	}
+	userData := e.record.UserData
+	remaining := e.record.UserDataLength
+	// raw holds the data of each property, to resolve lengths given by
+	// earlier properties.
+	raw := make([][]byte, count)
+	properties := make(map[string]string, count)
+	buf := make([]uint16, 256)
+	for i, p := range propertyInfos {
+		if p.Flags&(propertyStruct|propertyParamCount) != 0 || p.Count > 1 {
+			break
+		}
+		length := p.Length
+		if p.Flags&propertyParamLength != 0 {
+			length = uint16(littleEndianUint(raw[p.Length]))
+		}
+
+		var consumed uint16
+		for {
+			size := uint32(len(buf) * 2)
+			r1, _, _ := procTdhFormatProp.Call(
+				uintptr(unsafe.Pointer(&info[0])),
+				0,
+				pointerSize,
+				uintptr(p.InType),
+				uintptr(p.OutType),
+				uintptr(length),
+				uintptr(remaining),
+				uintptr(userData),
+				uintptr(unsafe.Pointer(&size)),
+				uintptr(unsafe.Pointer(&buf[0])),
+				uintptr(unsafe.Pointer(&consumed)),
+			)
+			if r1 == errorInsufficientBuffer {
+				buf = make([]uint16, size/2+1)
+				continue
+			}
+			if r1 != 0 {
+				return properties, windows.Errno(r1)
+			}
+			break
+		}
+
+		name := windows.UTF16PtrToString((*uint16)(unsafe.Pointer(&info[p.NameOffset])))
+		properties[name] = windows.UTF16ToString(buf)
+		if p.InType >= tdhInTypeInt8 && p.InType <= tdhInTypeUInt64 {
+			raw[i] = append([]byte(nil), (*[8]byte)(userData)[:consumed:consumed]...)
+		}
+		userData = unsafe.Pointer(uintptr(userData) + uintptr(consumed))
+		remaining -= consumed
+	}
+	return properties, nil
+}
+
+func littleEndianUint(b []byte) uint64 {
+	var padded [8]byte
+	copy(padded[:], b)
+	return binary.LittleEndian.Uint64(padded[:])
+}
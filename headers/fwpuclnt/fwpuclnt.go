@@ -0,0 +1,238 @@
+package fwpuclnt
+
+import (
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Options of the base filtering engine.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmtypes/ne-fwpmtypes-fwpm_engine_option
+const (
+	EngineCollectNetEvents         = 0
+	EngineNetEventMatchAnyKeywords = 1
+)
+
+// Keywords of the net events collected in addition to the classify drops.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmenginesetoption0
+const (
+	NetEventKeywordClassifyAllow = 0x10
+)
+
+const (
+	rpcCAuthnWinNT = 10
+	fwpUint32      = 3
+
+	// FWPM_NET_EVENT_TYPE
+	netEventTypeClassifyDrop  = 3
+	netEventTypeClassifyAllow = 6
+)
+
+var (
+	fwpuclnt                     = windows.NewLazySystemDLL("fwpuclnt.dll")
+	procFwpmEngineOpen0          = fwpuclnt.NewProc("FwpmEngineOpen0")
+	procFwpmEngineClose0         = fwpuclnt.NewProc("FwpmEngineClose0")
+	procFwpmEngineGetOption0     = fwpuclnt.NewProc("FwpmEngineGetOption0")
+	procFwpmEngineSetOption0     = fwpuclnt.NewProc("FwpmEngineSetOption0")
+	procFwpmFilterGetById0       = fwpuclnt.NewProc("FwpmFilterGetById0")
+	procFwpmFreeMemory0          = fwpuclnt.NewProc("FwpmFreeMemory0")
+	procFwpmNetEventSubscribe1   = fwpuclnt.NewProc("FwpmNetEventSubscribe1")
+	procFwpmNetEventUnsubscribe0 = fwpuclnt.NewProc("FwpmNetEventUnsubscribe0")
+)
+
+// fwpValue0 is a wrapper of FWP_VALUE0 holding a UINT32. The union is 8-byte
+// aligned on both 386 and amd64.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwptypes/ns-fwptypes-fwp_value0
+type fwpValue0 struct {
+	dataType uint32
+	_        uint32
+	uint32   uint32
+	_        uint32
+}
+
+// fwpByteBlob is a wrapper of FWP_BYTE_BLOB
+// https://docs.microsoft.com/en-us/windows/win32/api/fwptypes/ns-fwptypes-fwp_byte_blob
+type fwpByteBlob struct {
+	size uint32
+	data *byte
+}
+
+// fwpmNetEventHeader2 is a wrapper of FWPM_NET_EVENT_HEADER2
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmtypes/ns-fwpmtypes-fwpm_net_event_header2
+type fwpmNetEventHeader2 struct {
+	timeStamp     windows.Filetime
+	flags         uint32
+	ipVersion     uint32
+	ipProtocol    uint8
+	localAddr     [4]uint32
+	remoteAddr    [4]uint32
+	localPort     uint16
+	remotePort    uint16
+	scopeID       uint32
+	appID         fwpByteBlob
+	userID        *windows.SID
+	addressFamily uint32
+	packageSid    *windows.SID
+}
+
+// fwpmNetEvent2 is a wrapper of FWPM_NET_EVENT2
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmtypes/ns-fwpmtypes-fwpm_net_event2
+type fwpmNetEvent2 struct {
+	header    fwpmNetEventHeader2
+	eventType uint32
+	// data points to the event of the type. FWPM_NET_EVENT_CLASSIFY_DROP2
+	// and FWPM_NET_EVENT_CLASSIFY_ALLOW0 both start with the filter ID and
+	// the layer ID.
+	data unsafe.Pointer
+}
+
+type classifyEventData struct {
+	filterID uint64
+	layerID  uint16
+}
+
+// fwpmNetEventSubscription0 is a wrapper of FWPM_NET_EVENT_SUBSCRIPTION0
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmtypes/ns-fwpmtypes-fwpm_net_event_subscription0
+type fwpmNetEventSubscription0 struct {
+	enumTemplate uintptr
+	flags        uint32
+	sessionKey   windows.GUID
+}
+
+// fwpmFilter0Header is the start of FWPM_FILTER0, up to its display data.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmtypes/ns-fwpmtypes-fwpm_filter0
+type fwpmFilter0Header struct {
+	filterKey   windows.GUID
+	name        *uint16
+	description *uint16
+}
+
+// ClassifyEvent is a packet or connection permitted or dropped by a filter.
+type ClassifyEvent struct {
+	FilterID uint64
+	LayerID  uint16
+	Allowed  bool
+}
+
+// Engine is a session with the base filtering engine.
+type Engine struct {
+	handle       windows.Handle
+	subscription windows.Handle
+}
+
+// Open opens a session with the local base filtering engine. It requires
+// administrative privileges.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmengineopen0
+func Open() (*Engine, error) {
+	e := &Engine{}
+	r1, _, _ := procFwpmEngineOpen0.Call(0, rpcCAuthnWinNT, 0, 0, uintptr(unsafe.Pointer(&e.handle)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	return e, nil
+}
+
+// Close unsubscribes from the net events and closes the session.
+func (e *Engine) Close() error {
+	if e.subscription != 0 {
+		_, _, _ = procFwpmNetEventUnsubscribe0.Call(uintptr(e.handle), uintptr(e.subscription))
+		classifyHandlers.Delete(e.handle)
+	}
+	r1, _, _ := procFwpmEngineClose0.Call(uintptr(e.handle))
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// Option returns the value of an option of the engine.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmenginegetoption0
+func (e *Engine) Option(option uint32) (uint32, error) {
+	var value *fwpValue0
+	r1, _, _ := procFwpmEngineGetOption0.Call(uintptr(e.handle), uintptr(option), uintptr(unsafe.Pointer(&value)))
+	if r1 != 0 {
+		return 0, windows.Errno(r1)
+	}
+	defer procFwpmFreeMemory0.Call(uintptr(unsafe.Pointer(&value)))
+	return value.uint32, nil
+}
+
+// SetOption sets an option of the engine. Options are persistent and apply to
+// all sessions.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmenginesetoption0
+func (e *Engine) SetOption(option uint32, value uint32) error {
+	v := fwpValue0{dataType: fwpUint32, uint32: value}
+	r1, _, _ := procFwpmEngineSetOption0.Call(uintptr(e.handle), uintptr(option), uintptr(unsafe.Pointer(&v)))
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// FilterName returns the display name of a filter. The filters of Windows
+// Defender Firewall rules are named after the rule.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmfiltergetbyid0
+func (e *Engine) FilterName(id uint64) (string, error) {
+	var filter *fwpmFilter0Header
+	var r1 uintptr
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		r1, _, _ = procFwpmFilterGetById0.Call(uintptr(e.handle), uintptr(id), uintptr(id>>32), uintptr(unsafe.Pointer(&filter)))
+	} else {
+		r1, _, _ = procFwpmFilterGetById0.Call(uintptr(e.handle), uintptr(id), uintptr(unsafe.Pointer(&filter)))
+	}
+	if r1 != 0 {
+		return "", windows.Errno(r1)
+	}
+	defer procFwpmFreeMemory0.Call(uintptr(unsafe.Pointer(&filter)))
+	return windows.UTF16PtrToString(filter.name), nil
+}
+
+var (
+	// Callbacks can't be released, so a single one dispatches the events
+	// of all engines.
+	classifyCallback     uintptr
+	classifyCallbackOnce sync.Once
+	classifyHandlers     sync.Map // windows.Handle -> func(ClassifyEvent)
+)
+
+func dispatchClassifyEvent(context uintptr, event *fwpmNetEvent2) uintptr {
+	if event.eventType != netEventTypeClassifyDrop && event.eventType != netEventTypeClassifyAllow {
+		return 0
+	}
+	if handler, ok := classifyHandlers.Load(windows.Handle(context)); ok {
+		data := (*classifyEventData)(event.data)
+		handler.(func(ClassifyEvent))(ClassifyEvent{
+			FilterID: data.filterID,
+			LayerID:  data.layerID,
+			Allowed:  event.eventType == netEventTypeClassifyAllow,
+		})
+	}
+	return 0
+}
+
+// SubscribeClassifyEvents calls fn for every packet or connection a filter
+// permitted or dropped, until the engine is closed. Only the events enabled
+// with the EngineCollectNetEvents and EngineNetEventMatchAnyKeywords options
+// are delivered. fn is called from a thread of the engine and must not block.
+// https://docs.microsoft.com/en-us/windows/win32/api/fwpmu/nf-fwpmu-fwpmneteventsubscribe1
+func (e *Engine) SubscribeClassifyEvents(fn func(ClassifyEvent)) error {
+	classifyCallbackOnce.Do(func() {
+		classifyCallback = windows.NewCallback(dispatchClassifyEvent)
+	})
+	classifyHandlers.Store(e.handle, fn)
+	// A nil enumeration template subscribes to all events.
+	var subscription fwpmNetEventSubscription0
+	r1, _, _ := procFwpmNetEventSubscribe1.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(&subscription)),
+		classifyCallback,
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(&e.subscription)),
+	)
+	if r1 != 0 {
+		classifyHandlers.Delete(e.handle)
+		return windows.Errno(r1)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package iphlpapi
+
+import (
+	"encoding/binary"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Address families.
+const (
+	AF_UNSPEC = 0
+	AF_INET   = 2
+	AF_INET6  = 23
+)
+
+// NeighborState is a wrapper of NL_NEIGHBOR_STATE
+// https://docs.microsoft.com/en-us/windows/win32/api/nldef/ne-nldef-nl_neighbor_state
+type NeighborState uint32
+
+const (
+	NeighborStateUnreachable NeighborState = iota
+	NeighborStateIncomplete
+	NeighborStateProbe
+	NeighborStateDelay
+	NeighborStateStale
+	NeighborStateReachable
+	NeighborStatePermanent
+)
+
+// String returns the name of the state, as shown by Get-NetNeighbor.
+func (s NeighborState) String() string {
+	switch s {
+	case NeighborStateUnreachable:
+		return "unreachable"
+	case NeighborStateIncomplete:
+		return "incomplete"
+	case NeighborStateProbe:
+		return "probe"
+	case NeighborStateDelay:
+		return "delay"
+	case NeighborStateStale:
+		return "stale"
+	case NeighborStateReachable:
+		return "reachable"
+	case NeighborStatePermanent:
+		return "permanent"
+	}
+	return "unknown"
+}
+
+const (
+	tcpTableOwnerPIDAll = 5
+	udpTableOwnerPID    = 1
+
+	errorInsufficientBuffer = 122
+	errorNotFound           = 1168
+)
+
+var (
+	iphlpapi                = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetIpForwardTable2  = iphlpapi.NewProc("GetIpForwardTable2")
+	procGetIpNetTable2      = iphlpapi.NewProc("GetIpNetTable2")
+	procFreeMibTable        = iphlpapi.NewProc("FreeMibTable")
+	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
+	procGetExtendedUdpTable = iphlpapi.NewProc("GetExtendedUdpTable")
+)
+
+// mibIpnetRow2 is a wrapper of MIB_IPNET_ROW2
+// https://docs.microsoft.com/en-us/windows/win32/api/netioapi/ns-netioapi-mib_ipnet_row2
+type mibIpnetRow2 struct {
+	Address               [28]byte // SOCKADDR_INET
+	InterfaceIndex        uint32
+	InterfaceLuid         uint64
+	PhysicalAddress       [32]byte
+	PhysicalAddressLength uint32
+	State                 NeighborState
+	Flags                 uint8
+	_                     [3]byte
+	ReachabilityTime      uint32
+}
+
+// mibIpnetTable2 is a wrapper of MIB_IPNET_TABLE2. The rows are aligned
+// to 8 bytes on all architectures.
+// https://docs.microsoft.com/en-us/windows/win32/api/netioapi/ns-netioapi-mib_ipnet_table2
+type mibIpnetTable2 struct {
+	NumEntries uint32
+	_          uint32
+	Table      [1 << 20]mibIpnetRow2
+}
+
+// Neighbor is an entry of the neighbor cache, i.e. the ARP cache for IPv4.
+type Neighbor struct {
+	InterfaceIndex uint32
+	State          NeighborState
+}
+
+// RouteCount returns the number of entries of the routing table of the given
+// address family, or of both if family is AF_UNSPEC.
+// https://docs.microsoft.com/en-us/windows/win32/api/netioapi/nf-netioapi-getipforwardtable2
+func RouteCount(family uint16) (int, error) {
+	// The first member of MIB_IPFORWARD_TABLE2 is the number of entries.
+	var table *uint32
+	r1, _, _ := procGetIpForwardTable2.Call(uintptr(family), uintptr(unsafe.Pointer(&table)))
+	if r1 == errorNotFound {
+		return 0, nil
+	}
+	if r1 != 0 {
+		return 0, windows.Errno(r1)
+	}
+	defer procFreeMibTable.Call(uintptr(unsafe.Pointer(table)))
+	return int(*table), nil
+}
+
+// Neighbors returns the neighbor cache of the given address family, or of
+// both if family is AF_UNSPEC.
+// https://docs.microsoft.com/en-us/windows/win32/api/netioapi/nf-netioapi-getipnettable2
+func Neighbors(family uint16) ([]Neighbor, error) {
+	var table *mibIpnetTable2
+	r1, _, _ := procGetIpNetTable2.Call(uintptr(family), uintptr(unsafe.Pointer(&table)))
+	if r1 == errorNotFound {
+		return nil, nil
+	}
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procFreeMibTable.Call(uintptr(unsafe.Pointer(table)))
+
+	neighbors := make([]Neighbor, 0, table.NumEntries)
+	for _, row := range table.Table[:table.NumEntries:table.NumEntries] {
+		neighbors = append(neighbors, Neighbor{
+			InterfaceIndex: row.InterfaceIndex,
+			State:          row.State,
+		})
+	}
+	return neighbors, nil
+}
+
+// extendedTable calls GetExtendedTcpTable or GetExtendedUdpTable, growing the
+// buffer until the table fits.
+func extendedTable(proc *windows.LazyProc, family uint16, class uint32) ([]byte, error) {
+	size := uint32(16 * 1024)
+	for {
+		buf := make([]byte, size)
+		r1, _, _ := proc.Call(
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&size)),
+			0,
+			uintptr(family),
+			uintptr(class),
+			0,
+		)
+		switch r1 {
+		case 0:
+			return buf[:size], nil
+		case errorInsufficientBuffer:
+			// Connections may have been opened since size was returned.
+			size += size / 4
+		default:
+			return nil, windows.Errno(r1)
+		}
+	}
+}
+
+// localPorts returns the local ports of a MIB_TCPTABLE_OWNER_PID,
+// MIB_TCP6TABLE_OWNER_PID, MIB_UDPTABLE_OWNER_PID or MIB_UDP6TABLE_OWNER_PID,
+// given the size of its rows and the offset of dwLocalPort within them.
+func localPorts(buf []byte, rowSize, portOffset int) []uint16 {
+	if len(buf) < 4 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint32(buf))
+	ports := make([]uint16, 0, n)
+	for i := 0; i < n; i++ {
+		off := 4 + i*rowSize + portOffset
+		if off+2 > len(buf) {
+			break
+		}
+		// Ports are stored in network byte order in the low word.
+		ports = append(ports, binary.BigEndian.Uint16(buf[off:]))
+	}
+	return ports
+}
+
+// TCPLocalPorts returns the local ports of all TCP endpoints of the given
+// address family, including listeners, one entry per endpoint.
+// https://docs.microsoft.com/en-us/windows/win32/api/iphlpapi/nf-iphlpapi-getextendedtcptable
+func TCPLocalPorts(family uint16) ([]uint16, error) {
+	buf, err := extendedTable(procGetExtendedTcpTable, family, tcpTableOwnerPIDAll)
+	if err != nil {
+		return nil, err
+	}
+	if family == AF_INET6 {
+		// MIB_TCP6ROW_OWNER_PID: ucLocalAddr[16], dwLocalScopeId, dwLocalPort, ...
+		return localPorts(buf, 56, 20), nil
+	}
+	// MIB_TCPROW_OWNER_PID: dwState, dwLocalAddr, dwLocalPort, ...
+	return localPorts(buf, 24, 8), nil
+}
+
+// UDPLocalPorts returns the local ports of all UDP endpoints of the given
+// address family, one entry per endpoint.
+// https://docs.microsoft.com/en-us/windows/win32/api/iphlpapi/nf-iphlpapi-getextendedudptable
+func UDPLocalPorts(family uint16) ([]uint16, error) {
+	buf, err := extendedTable(procGetExtendedUdpTable, family, udpTableOwnerPID)
+	if err != nil {
+		return nil, err
+	}
+	if family == AF_INET6 {
+		// MIB_UDP6ROW_OWNER_PID: ucLocalAddr[16], dwLocalScopeId, dwLocalPort, dwOwningPid
+		return localPorts(buf, 28, 20), nil
+	}
+	// MIB_UDPROW_OWNER_PID: dwLocalAddr, dwLocalPort, dwOwningPid
+	return localPorts(buf, 12, 4), nil
+}
@@ -0,0 +1,118 @@
+package ktmw32
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ktmObjectTransaction        = 0
+	ktmObjectTransactionManager = 1
+
+	transactionQueryInformation = 0x0001
+	transactionBasicInformation = 0
+
+	statusNoMoreEntries = 0x8000001a
+)
+
+// States of a transaction, as in TRANSACTION_STATE.
+const (
+	TransactionStateNormal          = 1
+	TransactionStateIndoubt         = 2
+	TransactionStateCommittedNotify = 3
+)
+
+// TransactionBasicInformation is a wrapper for TRANSACTION_BASIC_INFORMATION
+// https://docs.microsoft.com/en-us/windows-hardware/drivers/ddi/wdm/ns-wdm-_transaction_basic_information
+type TransactionBasicInformation struct {
+	TransactionID windows.GUID
+	State         uint32
+	Outcome       uint32
+}
+
+// ktmObjectCursor is a wrapper for KTMOBJECT_CURSOR, with room for a batch of
+// object IDs.
+// https://docs.microsoft.com/en-us/windows-hardware/drivers/ddi/wdm/ns-wdm-_ktmobject_cursor
+type ktmObjectCursor struct {
+	LastQuery     windows.GUID
+	ObjectIDCount uint32
+	ObjectIDs     [64]windows.GUID
+}
+
+var (
+	ntdll                             = windows.NewLazySystemDLL("ntdll.dll")
+	procNtEnumerateTransactionObject  = ntdll.NewProc("NtEnumerateTransactionObject")
+	procNtQueryInformationTransaction = ntdll.NewProc("NtQueryInformationTransaction")
+	procRtlNtStatusToDosError         = ntdll.NewProc("RtlNtStatusToDosError")
+
+	ktmw32              = windows.NewLazySystemDLL("ktmw32.dll")
+	procOpenTransaction = ktmw32.NewProc("OpenTransaction")
+)
+
+func ntStatusError(status uintptr) error {
+	r1, _, _ := procRtlNtStatusToDosError.Call(status)
+	return windows.Errno(r1)
+}
+
+// enumerate returns the IDs of all objects of the type on the computer.
+// https://docs.microsoft.com/en-us/windows-hardware/drivers/ddi/wdm/nf-wdm-zwenumeratetransactionobject
+func enumerate(objectType uintptr) ([]windows.GUID, error) {
+	var cursor ktmObjectCursor
+	var ids []windows.GUID
+	for {
+		var returned uint32
+		r1, _, _ := procNtEnumerateTransactionObject.Call(
+			0,
+			objectType,
+			uintptr(unsafe.Pointer(&cursor)),
+			unsafe.Sizeof(cursor),
+			uintptr(unsafe.Pointer(&returned)),
+		)
+		if uint32(r1) == statusNoMoreEntries {
+			return ids, nil
+		}
+		if uint32(r1) != 0 {
+			return nil, ntStatusError(r1)
+		}
+		n := cursor.ObjectIDCount
+		if n > uint32(len(cursor.ObjectIDs)) {
+			n = uint32(len(cursor.ObjectIDs))
+		}
+		ids = append(ids, cursor.ObjectIDs[:n]...)
+	}
+}
+
+// EnumerateTransactions returns the IDs of the transactions on the computer.
+func EnumerateTransactions() ([]windows.GUID, error) {
+	return enumerate(ktmObjectTransaction)
+}
+
+// EnumerateTransactionManagers returns the IDs of the transaction managers on
+// the computer.
+func EnumerateTransactionManagers() ([]windows.GUID, error) {
+	return enumerate(ktmObjectTransactionManager)
+}
+
+// QueryTransaction returns the state and outcome of a transaction.
+// https://docs.microsoft.com/en-us/windows/win32/api/ktmw32/nf-ktmw32-opentransaction
+func QueryTransaction(id windows.GUID) (TransactionBasicInformation, error) {
+	h, _, err := procOpenTransaction.Call(transactionQueryInformation, uintptr(unsafe.Pointer(&id)))
+	if windows.Handle(h) == windows.InvalidHandle {
+		return TransactionBasicInformation{}, err
+	}
+	defer windows.CloseHandle(windows.Handle(h))
+
+	var info TransactionBasicInformation
+	r1, _, _ := procNtQueryInformationTransaction.Call(
+		h,
+		transactionBasicInformation,
+		uintptr(unsafe.Pointer(&info)),
+		unsafe.Sizeof(info),
+		0,
+	)
+	if uint32(r1) != 0 {
+		return TransactionBasicInformation{}, ntStatusError(r1)
+	}
+	return info, nil
+}
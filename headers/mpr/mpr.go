@@ -0,0 +1,76 @@
+package mpr
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// netResource is a wrapper of NETRESOURCEW
+// https://docs.microsoft.com/en-us/windows/win32/api/winnetwk/ns-winnetwk-netresourcew
+type netResource struct {
+	dwScope       uint32
+	dwType        uint32
+	dwDisplayType uint32
+	dwUsage       uint32
+	lpLocalName   *uint16
+	lpRemoteName  *uint16
+	lpComment     *uint16
+	lpProvider    *uint16
+}
+
+const (
+	resourceTypeAny = 0
+	// ERROR_SESSION_CREDENTIAL_CONFLICT is returned if a connection with other
+	// credentials already exists.
+	errorSessionCredentialConflict = windows.Errno(1219)
+)
+
+// ErrCredentialConflict is returned by ConnectIPC if the process already has a
+// session to the host with other credentials, which would be used instead.
+var ErrCredentialConflict = errors.New("a session to the host with other credentials already exists, e.g. from a mapped drive of the exporter's account")
+
+var (
+	mpr                     = windows.NewLazySystemDLL("mpr.dll")
+	procWNetAddConnection2W = mpr.NewProc("WNetAddConnection2W")
+)
+
+// ConnectIPC establishes an authenticated session to the IPC$ share of host,
+// which subsequent RPC based calls (remote registry, performance counters)
+// reuse. If a session to the host with other credentials exists already,
+// ErrCredentialConflict is returned, as the credentials wouldn't be used.
+// https://docs.microsoft.com/en-us/windows/win32/api/winnetwk/nf-winnetwk-wnetaddconnection2w
+func ConnectIPC(host, username, password string) error {
+	remoteName, err := windows.UTF16PtrFromString(`\\` + host + `\IPC$`)
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(username)
+	if err != nil {
+		return err
+	}
+	pass, err := windows.UTF16PtrFromString(password)
+	if err != nil {
+		return err
+	}
+
+	resource := netResource{
+		dwType:       resourceTypeAny,
+		lpRemoteName: remoteName,
+	}
+	r1, _, _ := procWNetAddConnection2W.Call(
+		uintptr(unsafe.Pointer(&resource)),
+		uintptr(unsafe.Pointer(pass)),
+		uintptr(unsafe.Pointer(user)),
+		0,
+	)
+	switch ret := windows.Errno(r1); ret {
+	case 0:
+		return nil
+	case errorSessionCredentialConflict:
+		return ErrCredentialConflict
+	default:
+		return ret
+	}
+}
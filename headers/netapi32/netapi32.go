@@ -106,3 +106,42 @@ func GetWorkstationInfo() (WorkstationInfo, error) {
 	}
 	return workstationInfo, nil
 }
+
+// nerrNetNameNotFound is returned by NetShareGetInfo for unknown shares.
+const nerrNetNameNotFound = 2310
+
+var procNetShareGetInfo = netapi32.NewProc("NetShareGetInfo")
+
+// ShareExists reports whether server shares a folder under name.
+// https://docs.microsoft.com/en-us/windows/win32/api/lmshare/nf-lmshare-netsharegetinfo
+func ShareExists(server, name string) (bool, error) {
+	serverPtr, err := windows.UTF16PtrFromString(server)
+	if err != nil {
+		return false, err
+	}
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return false, err
+	}
+	var buf *byte
+	r1, _, _ := procNetShareGetInfo.Call(
+		uintptr(unsafe.Pointer(serverPtr)),
+		uintptr(unsafe.Pointer(namePtr)),
+		0,
+		uintptr(unsafe.Pointer(&buf)),
+	)
+	if buf != nil {
+		procNetApiBufferFree.Call(uintptr(unsafe.Pointer(buf)))
+	}
+	switch ret := uint32(r1); ret {
+	case 0:
+		return true, nil
+	case nerrNetNameNotFound:
+		return false, nil
+	default:
+		if s, ok := NetApiStatus[ret]; ok {
+			return false, errors.New(s)
+		}
+		return false, windows.Errno(ret)
+	}
+}
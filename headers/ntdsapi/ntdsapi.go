@@ -0,0 +1,245 @@
+package ntdsapi
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Role is an index into the result of DsListRoles.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dslistrolesw
+type Role int
+
+// Operations master (FSMO) roles.
+const (
+	RoleSchemaOwner Role = iota
+	RoleDomainNamingOwner
+	RolePDCOwner
+	RoleRIDOwner
+	RoleInfrastructureOwner
+)
+
+const (
+	dsReplInfoNeighbors = 0
+	dsNameNoError       = 0
+)
+
+// dsNameResultItem is a wrapper of DS_NAME_RESULT_ITEMW
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/ns-ntdsapi-ds_name_result_itemw
+type dsNameResultItem struct {
+	status  uint32
+	pDomain *uint16
+	pName   *uint16
+}
+
+// dsNameResult is a wrapper of DS_NAME_RESULTW
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/ns-ntdsapi-ds_name_resultw
+type dsNameResult struct {
+	cItems uint32
+	rItems *dsNameResultItem
+}
+
+// dsDomainControllerInfo1 is a wrapper of DS_DOMAIN_CONTROLLER_INFO_1W
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/ns-ntdsapi-ds_domain_controller_info_1w
+type dsDomainControllerInfo1 struct {
+	NetbiosName        *uint16
+	DnsHostName        *uint16
+	SiteName           *uint16
+	ComputerObjectName *uint16
+	ServerObjectName   *uint16
+	fIsPdc             int32
+	fDsEnabled         int32
+}
+
+// dsReplNeighbor is a wrapper of DS_REPL_NEIGHBORW
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/ns-ntdsapi-ds_repl_neighborw
+type dsReplNeighbor struct {
+	pszNamingContext                   *uint16
+	pszSourceDsaDN                     *uint16
+	pszSourceDsaAddress                *uint16
+	pszAsyncIntersiteTransportDN       *uint16
+	dwReplicaFlags                     uint32
+	dwReserved                         uint32
+	uuidNamingContextObjGuid           windows.GUID
+	uuidSourceDsaObjGuid               windows.GUID
+	uuidSourceDsaInvocationID          windows.GUID
+	uuidAsyncIntersiteTransportObjGuid windows.GUID
+	usnLastObjChangeSynced             int64
+	usnAttributeFilter                 int64
+	ftimeLastSyncSuccess               windows.Filetime
+	ftimeLastSyncAttempt               windows.Filetime
+	dwLastSyncResult                   uint32
+	cNumConsecutiveSyncFailures        uint32
+}
+
+// dsReplNeighbors is a wrapper of DS_REPL_NEIGHBORSW
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/ns-ntdsapi-ds_repl_neighborsw
+type dsReplNeighbors struct {
+	cNumNeighbors uint32
+	dwReserved    uint32
+	rgNeighbor    [1 << 16]dsReplNeighbor
+}
+
+var (
+	ntdsapi                        = windows.NewLazySystemDLL("ntdsapi.dll")
+	procDsBindW                    = ntdsapi.NewProc("DsBindW")
+	procDsUnBindW                  = ntdsapi.NewProc("DsUnBindW")
+	procDsListDomainsInSiteW       = ntdsapi.NewProc("DsListDomainsInSiteW")
+	procDsListRolesW               = ntdsapi.NewProc("DsListRolesW")
+	procDsFreeNameResultW          = ntdsapi.NewProc("DsFreeNameResultW")
+	procDsGetDomainControllerInfoW = ntdsapi.NewProc("DsGetDomainControllerInfoW")
+	procDsFreeDomainControllerInfo = ntdsapi.NewProc("DsFreeDomainControllerInfoW")
+	procDsReplicaGetInfoW          = ntdsapi.NewProc("DsReplicaGetInfoW")
+	procDsReplicaFreeInfo          = ntdsapi.NewProc("DsReplicaFreeInfo")
+)
+
+// Handle is a binding to a domain controller.
+type Handle windows.Handle
+
+// DomainController describes a domain controller of a domain.
+type DomainController struct {
+	DNSHostName string
+	SiteName    string
+	// ServerObjectName is the DN of the server object, the parent of its
+	// NTDS Settings object.
+	ServerObjectName string
+	IsPDC            bool
+}
+
+// ReplicationNeighbor is an inbound replication partner of a domain controller.
+type ReplicationNeighbor struct {
+	NamingContext string
+	// SourceDsaDN is the DN of the NTDS Settings object of the partner.
+	SourceDsaDN             string
+	LastSyncSuccess         windows.Filetime
+	LastSyncResult          uint32
+	ConsecutiveSyncFailures uint32
+}
+
+// Bind binds to domainController, which can also be a DNS domain name, in
+// which case a domain controller of the domain is located.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dsbindw
+func Bind(domainController string) (Handle, error) {
+	dc, err := windows.UTF16PtrFromString(domainController)
+	if err != nil {
+		return 0, err
+	}
+	var h Handle
+	r1, _, _ := procDsBindW.Call(uintptr(unsafe.Pointer(dc)), 0, uintptr(unsafe.Pointer(&h)))
+	if r1 != 0 {
+		return 0, windows.Errno(r1)
+	}
+	return h, nil
+}
+
+// Close releases the binding.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dsunbindw
+func (h Handle) Close() error {
+	r1, _, _ := procDsUnBindW.Call(uintptr(unsafe.Pointer(&h)))
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// ListDomains returns the DNs of all domains of the forest.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dslistdomainsinsitew
+func (h Handle) ListDomains() ([]string, error) {
+	var result *dsNameResult
+	r1, _, _ := procDsListDomainsInSiteW.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&result)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procDsFreeNameResultW.Call(uintptr(unsafe.Pointer(result)))
+	return nameResultItems(result), nil
+}
+
+// ListRoles returns the DNs of the NTDS Settings objects of the owners of the
+// FSMO roles, indexed by Role. The domain-wide roles are those of the domain
+// of the bound domain controller.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dslistrolesw
+func (h Handle) ListRoles() ([]string, error) {
+	var result *dsNameResult
+	r1, _, _ := procDsListRolesW.Call(uintptr(h), uintptr(unsafe.Pointer(&result)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procDsFreeNameResultW.Call(uintptr(unsafe.Pointer(result)))
+	return nameResultItems(result), nil
+}
+
+// DomainControllers returns the domain controllers of domain.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dsgetdomaincontrollerinfow
+func (h Handle) DomainControllers(domain string) ([]DomainController, error) {
+	domainPtr, err := windows.UTF16PtrFromString(domain)
+	if err != nil {
+		return nil, err
+	}
+	var count uint32
+	var info *dsDomainControllerInfo1
+	r1, _, _ := procDsGetDomainControllerInfoW.Call(
+		uintptr(h),
+		uintptr(unsafe.Pointer(domainPtr)),
+		1,
+		uintptr(unsafe.Pointer(&count)),
+		uintptr(unsafe.Pointer(&info)),
+	)
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procDsFreeDomainControllerInfo.Call(1, uintptr(count), uintptr(unsafe.Pointer(info)))
+
+	dcs := make([]DomainController, 0, count)
+	for _, dc := range (*[1 << 16]dsDomainControllerInfo1)(unsafe.Pointer(info))[:count:count] {
+		dcs = append(dcs, DomainController{
+			DNSHostName:      utf16PtrToString(dc.DnsHostName),
+			SiteName:         utf16PtrToString(dc.SiteName),
+			ServerObjectName: utf16PtrToString(dc.ServerObjectName),
+			IsPDC:            dc.fIsPdc != 0,
+		})
+	}
+	return dcs, nil
+}
+
+// ReplicationNeighbors returns the inbound replication partners of the bound
+// domain controller, one per partner and naming context.
+// https://docs.microsoft.com/en-us/windows/win32/api/ntdsapi/nf-ntdsapi-dsreplicagetinfow
+func (h Handle) ReplicationNeighbors() ([]ReplicationNeighbor, error) {
+	var info *dsReplNeighbors
+	r1, _, _ := procDsReplicaGetInfoW.Call(uintptr(h), dsReplInfoNeighbors, 0, 0, uintptr(unsafe.Pointer(&info)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procDsReplicaFreeInfo.Call(dsReplInfoNeighbors, uintptr(unsafe.Pointer(info)))
+
+	neighbors := make([]ReplicationNeighbor, 0, info.cNumNeighbors)
+	for _, n := range info.rgNeighbor[:info.cNumNeighbors:info.cNumNeighbors] {
+		neighbors = append(neighbors, ReplicationNeighbor{
+			NamingContext:           utf16PtrToString(n.pszNamingContext),
+			SourceDsaDN:             utf16PtrToString(n.pszSourceDsaDN),
+			LastSyncSuccess:         n.ftimeLastSyncSuccess,
+			LastSyncResult:          n.dwLastSyncResult,
+			ConsecutiveSyncFailures: n.cNumConsecutiveSyncFailures,
+		})
+	}
+	return neighbors, nil
+}
+
+func nameResultItems(result *dsNameResult) []string {
+	items := make([]string, 0, result.cItems)
+	for _, item := range (*[1 << 16]dsNameResultItem)(unsafe.Pointer(result.rItems))[:result.cItems:result.cItems] {
+		if item.status != dsNameNoError {
+			items = append(items, "")
+			continue
+		}
+		items = append(items, utf16PtrToString(item.pName))
+	}
+	return items
+}
+
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	return windows.UTF16PtrToString(p)
+}
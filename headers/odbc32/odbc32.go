@@ -0,0 +1,284 @@
+package odbc32
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	sqlHandleEnv  = 1
+	sqlHandleDbc  = 2
+	sqlHandleStmt = 3
+
+	sqlSuccess            = 0
+	sqlSuccessWithInfo    = 1
+	sqlNoData             = 100
+	sqlAttrODBCVersion    = 200
+	sqlOVODBC3            = 3
+	sqlAttrLoginTimeout   = 103
+	sqlAttrQueryTimeout   = 0
+	sqlDriverNoPrompt     = 0
+	sqlNullData           = -1
+	sqlNoTotal            = -4
+	maxColumnNameLength   = 256
+	dataChunkLength       = 1024
+	diagnosticMessageSize = 1024
+)
+
+// Negative arguments, which are sign-extended when passed.
+var (
+	sqlNTS        = -3
+	sqlCWChar     = -8
+	sqlIsUInteger = -5
+)
+
+var (
+	odbc32                 = windows.NewLazySystemDLL("odbc32.dll")
+	procSQLAllocHandle     = odbc32.NewProc("SQLAllocHandle")
+	procSQLFreeHandle      = odbc32.NewProc("SQLFreeHandle")
+	procSQLSetEnvAttr      = odbc32.NewProc("SQLSetEnvAttr")
+	procSQLSetConnectAttrW = odbc32.NewProc("SQLSetConnectAttrW")
+	procSQLSetStmtAttrW    = odbc32.NewProc("SQLSetStmtAttrW")
+	procSQLDriverConnectW  = odbc32.NewProc("SQLDriverConnectW")
+	procSQLDisconnect      = odbc32.NewProc("SQLDisconnect")
+	procSQLExecDirectW     = odbc32.NewProc("SQLExecDirectW")
+	procSQLNumResultCols   = odbc32.NewProc("SQLNumResultCols")
+	procSQLDescribeColW    = odbc32.NewProc("SQLDescribeColW")
+	procSQLFetch           = odbc32.NewProc("SQLFetch")
+	procSQLGetData         = odbc32.NewProc("SQLGetData")
+	procSQLGetDiagRecW     = odbc32.NewProc("SQLGetDiagRecW")
+)
+
+// Row is a row of a result set, holding the value of each column as text.
+// NULL values are missing.
+type Row map[string]string
+
+// Error is the first diagnostic record of a failed call.
+type Error struct {
+	Function    string
+	SQLState    string
+	NativeError int32
+	Message     string
+}
+
+func (e *Error) Error() string {
+	if e.SQLState == "" {
+		return e.Function + " failed"
+	}
+	return fmt.Sprintf("%s failed: [%s] %s", e.Function, e.SQLState, e.Message)
+}
+
+func succeeded(r1 uintptr) bool {
+	ret := int16(r1)
+	return ret == sqlSuccess || ret == sqlSuccessWithInfo
+}
+
+// diagnose returns the error of a failed call on the handle.
+// https://docs.microsoft.com/en-us/sql/odbc/reference/syntax/sqlgetdiagrec-function
+func diagnose(function string, handleType int16, handle uintptr) error {
+	var state [6]uint16
+	var native int32
+	var msg [diagnosticMessageSize]uint16
+	var msgLen int16
+	r1, _, _ := procSQLGetDiagRecW.Call(
+		uintptr(handleType),
+		handle,
+		1,
+		uintptr(unsafe.Pointer(&state[0])),
+		uintptr(unsafe.Pointer(&native)),
+		uintptr(unsafe.Pointer(&msg[0])),
+		uintptr(len(msg)),
+		uintptr(unsafe.Pointer(&msgLen)),
+	)
+	if !succeeded(r1) {
+		return &Error{Function: function}
+	}
+	return &Error{
+		Function:    function,
+		SQLState:    windows.UTF16ToString(state[:]),
+		NativeError: native,
+		Message:     windows.UTF16ToString(msg[:]),
+	}
+}
+
+func allocHandle(handleType int16, parent uintptr) (uintptr, error) {
+	var handle uintptr
+	r1, _, _ := procSQLAllocHandle.Call(uintptr(handleType), parent, uintptr(unsafe.Pointer(&handle)))
+	if !succeeded(r1) {
+		if parent == 0 {
+			return 0, &Error{Function: "SQLAllocHandle"}
+		}
+		return 0, diagnose("SQLAllocHandle", handleType-1, parent)
+	}
+	return handle, nil
+}
+
+func freeHandle(handleType int16, handle uintptr) {
+	_, _, _ = procSQLFreeHandle.Call(uintptr(handleType), handle)
+}
+
+// Query connects to the data source described by the connection string, runs
+// the query and returns the rows of its first result set. timeout applies to
+// both the login and the query, and is ignored by drivers not supporting it.
+// https://docs.microsoft.com/en-us/sql/odbc/reference/syntax/sqldriverconnect-function
+func Query(connectionString string, query string, timeout time.Duration) ([]Row, error) {
+	env, err := allocHandle(sqlHandleEnv, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer freeHandle(sqlHandleEnv, env)
+	r1, _, _ := procSQLSetEnvAttr.Call(env, sqlAttrODBCVersion, sqlOVODBC3, 0)
+	if !succeeded(r1) {
+		return nil, diagnose("SQLSetEnvAttr", sqlHandleEnv, env)
+	}
+
+	dbc, err := allocHandle(sqlHandleDbc, env)
+	if err != nil {
+		return nil, err
+	}
+	defer freeHandle(sqlHandleDbc, dbc)
+	seconds := uintptr(timeout / time.Second)
+	_, _, _ = procSQLSetConnectAttrW.Call(dbc, sqlAttrLoginTimeout, seconds, uintptr(sqlIsUInteger))
+
+	connStr, err := windows.UTF16PtrFromString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	r1, _, _ = procSQLDriverConnectW.Call(
+		dbc,
+		0,
+		uintptr(unsafe.Pointer(connStr)),
+		uintptr(sqlNTS),
+		0,
+		0,
+		0,
+		sqlDriverNoPrompt,
+	)
+	if !succeeded(r1) {
+		return nil, diagnose("SQLDriverConnect", sqlHandleDbc, dbc)
+	}
+	defer procSQLDisconnect.Call(dbc)
+
+	stmt, err := allocHandle(sqlHandleStmt, dbc)
+	if err != nil {
+		return nil, err
+	}
+	defer freeHandle(sqlHandleStmt, stmt)
+	_, _, _ = procSQLSetStmtAttrW.Call(stmt, sqlAttrQueryTimeout, seconds, uintptr(sqlIsUInteger))
+
+	text, err := windows.UTF16PtrFromString(query)
+	if err != nil {
+		return nil, err
+	}
+	r1, _, _ = procSQLExecDirectW.Call(stmt, uintptr(unsafe.Pointer(text)), uintptr(sqlNTS))
+	// A statement without a result set, e.g. an UPDATE affecting no rows,
+	// returns SQL_NO_DATA.
+	if int16(r1) == sqlNoData {
+		return nil, nil
+	}
+	if !succeeded(r1) {
+		return nil, diagnose("SQLExecDirect", sqlHandleStmt, stmt)
+	}
+
+	columns, err := describeColumns(stmt)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for {
+		r1, _, _ = procSQLFetch.Call(stmt)
+		if int16(r1) == sqlNoData {
+			return rows, nil
+		}
+		if !succeeded(r1) {
+			return nil, diagnose("SQLFetch", sqlHandleStmt, stmt)
+		}
+		row := make(Row, len(columns))
+		for i, name := range columns {
+			value, null, err := getData(stmt, uint16(i+1))
+			if err != nil {
+				return nil, err
+			}
+			if !null {
+				row[name] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// describeColumns returns the names of the columns of the result set.
+// https://docs.microsoft.com/en-us/sql/odbc/reference/syntax/sqldescribecol-function
+func describeColumns(stmt uintptr) ([]string, error) {
+	var count int16
+	r1, _, _ := procSQLNumResultCols.Call(stmt, uintptr(unsafe.Pointer(&count)))
+	if !succeeded(r1) {
+		return nil, diagnose("SQLNumResultCols", sqlHandleStmt, stmt)
+	}
+	columns := make([]string, count)
+	for i := range columns {
+		var name [maxColumnNameLength]uint16
+		var nameLen, dataType, decimalDigits, nullable int16
+		var size uintptr
+		r1, _, _ := procSQLDescribeColW.Call(
+			stmt,
+			uintptr(i+1),
+			uintptr(unsafe.Pointer(&name[0])),
+			uintptr(len(name)),
+			uintptr(unsafe.Pointer(&nameLen)),
+			uintptr(unsafe.Pointer(&dataType)),
+			uintptr(unsafe.Pointer(&size)),
+			uintptr(unsafe.Pointer(&decimalDigits)),
+			uintptr(unsafe.Pointer(&nullable)),
+		)
+		if !succeeded(r1) {
+			return nil, diagnose("SQLDescribeCol", sqlHandleStmt, stmt)
+		}
+		columns[i] = windows.UTF16ToString(name[:])
+	}
+	return columns, nil
+}
+
+// getData returns the value of a column of the current row as text, which
+// drivers convert any type to. Values longer than the buffer are read in
+// chunks.
+// https://docs.microsoft.com/en-us/sql/odbc/reference/syntax/sqlgetdata-function
+func getData(stmt uintptr, column uint16) (value string, null bool, err error) {
+	var buf [dataChunkLength]uint16
+	var data []uint16
+	for {
+		var indicator int
+		r1, _, _ := procSQLGetData.Call(
+			stmt,
+			uintptr(column),
+			uintptr(sqlCWChar),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)*2),
+			uintptr(unsafe.Pointer(&indicator)),
+		)
+		ret := int16(r1)
+		if ret == sqlNoData {
+			break
+		}
+		if !succeeded(r1) {
+			return "", false, diagnose("SQLGetData", sqlHandleStmt, stmt)
+		}
+		if indicator == sqlNullData {
+			return "", true, nil
+		}
+		// The data is truncated if it doesn't fit the buffer, including its
+		// null terminator, and the rest is returned by the next call.
+		n := len(buf) - 1
+		if indicator != sqlNoTotal && indicator/2 < n {
+			n = indicator / 2
+		}
+		data = append(data, buf[:n]...)
+		if ret == sqlSuccess {
+			break
+		}
+	}
+	return windows.UTF16ToString(data), false, nil
+}
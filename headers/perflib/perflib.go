@@ -0,0 +1,511 @@
+package perflib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Request codes for PerfQueryCounterSetRegistrationInfo.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfquerycountersetregistrationinfo
+const (
+	perfRegCounterSetStruct      = 1
+	perfRegCounterSetNameString  = 3
+	perfRegCounterNameStrings    = 5
+	perfRegCounterSetEnglishName = 9
+	perfRegCounterEnglishNames   = 10
+)
+
+// Block types returned in PERF_COUNTER_HEADER.dwType.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counter_header
+const (
+	perfErrorReturn       = 0
+	perfSingleCounter     = 1
+	perfMultipleCounters  = 2
+	perfMultipleInstances = 4
+	perfCounterSet        = 6
+)
+
+const (
+	// PERF_WILDCARD_COUNTER selects all counters or all instances of a counter set.
+	perfWildcardCounter = 0xFFFFFFFF
+	// langEnglish is the LCID used to request English names.
+	langEnglish = 0x0409
+
+	errorNotEnoughMemory = windows.Errno(8)
+	errorMoreData        = windows.Errno(234)
+)
+
+var (
+	advapi32                                = windows.NewLazySystemDLL("advapi32.dll")
+	procPerfEnumerateCounterSet             = advapi32.NewProc("PerfEnumerateCounterSet")
+	procPerfQueryCounterSetRegistrationInfo = advapi32.NewProc("PerfQueryCounterSetRegistrationInfo")
+	procPerfOpenQueryHandle                 = advapi32.NewProc("PerfOpenQueryHandle")
+	procPerfCloseQueryHandle                = advapi32.NewProc("PerfCloseQueryHandle")
+	procPerfAddCounters                     = advapi32.NewProc("PerfAddCounters")
+	procPerfQueryCounterData                = advapi32.NewProc("PerfQueryCounterData")
+)
+
+// perfCounterSetRegInfo is a wrapper of PERF_COUNTERSET_REG_INFO
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counterset_reg_info
+type perfCounterSetRegInfo struct {
+	CounterSetGuid windows.GUID
+	CounterSetType uint32
+	DetailLevel    uint32
+	NumCounters    uint32
+	InstanceType   uint32
+}
+
+// perfCounterRegInfo is a wrapper of PERF_COUNTER_REG_INFO
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counter_reg_info
+type perfCounterRegInfo struct {
+	CounterId     uint32
+	Type          uint32
+	Attrib        uint64
+	DetailLevel   uint32
+	DefaultScale  int32
+	BaseCounterId uint32
+	PerfTimeId    uint32
+	PerfFreqId    uint32
+	MultiId       uint32
+	AggregateFunc uint32
+	Reserved      uint32
+}
+
+// perfCounterIdentifier is a wrapper of PERF_COUNTER_IDENTIFIER
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counter_identifier
+type perfCounterIdentifier struct {
+	CounterSetGuid windows.GUID
+	Status         uint32
+	Size           uint32
+	CounterId      uint32
+	InstanceId     uint32
+	Index          uint32
+	Reserved       uint32
+}
+
+// CounterSet describes a counter set registered on a machine.
+type CounterSet struct {
+	GUID     windows.GUID
+	Name     string
+	Counters []Counter
+	// MultiInstance is true if the counter set can contain more than one instance.
+	MultiInstance bool
+}
+
+// Counter describes a single counter of a counter set.
+type Counter struct {
+	ID   uint32
+	Name string
+	// Type is one of the PERF_* counter types, identical to the V1 counter types.
+	Type uint32
+	// BaseID is the ID of the base counter, if this counter requires one.
+	BaseID uint32
+}
+
+// Instance holds the raw counter values of one instance, indexed by counter ID.
+type Instance struct {
+	Name   string
+	Values map[uint32]int64
+}
+
+// CounterSetData is the result of querying a single counter set.
+type CounterSetData struct {
+	GUID      windows.GUID
+	Instances []Instance
+}
+
+// Snapshot is the result of a single PerfQueryCounterData call.
+type Snapshot struct {
+	Frequency int64
+	// Time is the time the counters were sampled, on the sampled machine's
+	// clock.
+	Time time.Time
+	Data []CounterSetData
+}
+
+// EnumerateCounterSets returns the GUIDs of all counter sets registered on machine.
+// An empty machine name refers to the local computer.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfenumeratecounterset
+func EnumerateCounterSets(machine string) ([]windows.GUID, error) {
+	machinePtr, err := machinePointer(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	var needed uint32
+	r1, _, _ := procPerfEnumerateCounterSet.Call(uintptr(unsafe.Pointer(machinePtr)), 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if ret := windows.Errno(r1); ret != 0 && ret != errorMoreData && ret != errorNotEnoughMemory {
+		return nil, fmt.Errorf("PerfEnumerateCounterSet failed: %v", ret)
+	}
+	if needed == 0 {
+		return nil, nil
+	}
+
+	guids := make([]windows.GUID, needed)
+	r1, _, _ = procPerfEnumerateCounterSet.Call(
+		uintptr(unsafe.Pointer(machinePtr)),
+		uintptr(unsafe.Pointer(&guids[0])),
+		uintptr(needed),
+		uintptr(unsafe.Pointer(&needed)),
+	)
+	if ret := windows.Errno(r1); ret != 0 {
+		return nil, fmt.Errorf("PerfEnumerateCounterSet failed: %v", ret)
+	}
+	return guids[:needed], nil
+}
+
+// QueryCounterSet returns the registration information of a counter set, with
+// English names where available.
+func QueryCounterSet(machine string, guid windows.GUID) (CounterSet, error) {
+	set := CounterSet{GUID: guid}
+
+	buf, err := queryRegistrationInfo(machine, guid, perfRegCounterSetStruct, 0)
+	if err != nil {
+		return set, err
+	}
+	info := (*perfCounterSetRegInfo)(unsafe.Pointer(&buf[0]))
+	// PERF_COUNTERSET_SINGLE_INSTANCE is 0, PERF_COUNTERSET_SINGLE_AGGREGATE is 4.
+	set.MultiInstance = info.CounterSetType != 0 && info.CounterSetType != 4
+
+	offset := unsafe.Sizeof(perfCounterSetRegInfo{})
+	size := unsafe.Sizeof(perfCounterRegInfo{})
+	for i := uint32(0); i < info.NumCounters; i++ {
+		if int(offset+size) > len(buf) {
+			break
+		}
+		c := (*perfCounterRegInfo)(unsafe.Pointer(&buf[offset]))
+		set.Counters = append(set.Counters, Counter{
+			ID:     c.CounterId,
+			Type:   c.Type,
+			BaseID: c.BaseCounterId,
+		})
+		offset += size
+	}
+
+	set.Name, err = queryName(machine, guid, perfRegCounterSetEnglishName, perfRegCounterSetNameString)
+	if err != nil {
+		return set, err
+	}
+
+	names, err := queryCounterNames(machine, guid)
+	if err != nil {
+		return set, err
+	}
+	for i := range set.Counters {
+		set.Counters[i].Name = names[set.Counters[i].ID]
+	}
+
+	return set, nil
+}
+
+// Query collects all counters of all instances of the given counter sets from
+// machine in a single call.
+func Query(machine string, guids []windows.GUID) (*Snapshot, error) {
+	machinePtr, err := machinePointer(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	var handle windows.Handle
+	r1, _, _ := procPerfOpenQueryHandle.Call(uintptr(unsafe.Pointer(machinePtr)), uintptr(unsafe.Pointer(&handle)))
+	if ret := windows.Errno(r1); ret != 0 {
+		return nil, fmt.Errorf("PerfOpenQueryHandle failed: %v", ret)
+	}
+	defer procPerfCloseQueryHandle.Call(uintptr(handle))
+
+	for _, guid := range guids {
+		if err := addWildcardCounters(handle, guid); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		var needed uint32
+		r1, _, _ := procPerfQueryCounterData.Call(
+			uintptr(handle),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&needed)),
+		)
+		ret := windows.Errno(r1)
+		if ret == errorNotEnoughMemory || ret == errorMoreData {
+			buf = make([]byte, needed)
+			continue
+		}
+		if ret != 0 {
+			return nil, fmt.Errorf("PerfQueryCounterData failed: %v", ret)
+		}
+		return parseCounterData(buf[:needed], guids)
+	}
+}
+
+// addWildcardCounters adds all counters and instances of a counter set to the query.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfaddcounters
+func addWildcardCounters(handle windows.Handle, guid windows.GUID) error {
+	// The identifier is followed by the instance name "*", and its size must be
+	// a multiple of 8 bytes.
+	headerSize := int(unsafe.Sizeof(perfCounterIdentifier{}))
+	size := align8(headerSize + 4)
+	buf := make([]byte, size)
+	id := (*perfCounterIdentifier)(unsafe.Pointer(&buf[0]))
+	id.CounterSetGuid = guid
+	id.Size = uint32(size)
+	id.CounterId = perfWildcardCounter
+	id.InstanceId = perfWildcardCounter
+	binary.LittleEndian.PutUint16(buf[headerSize:], '*')
+
+	r1, _, _ := procPerfAddCounters.Call(uintptr(handle), uintptr(unsafe.Pointer(&buf[0])), uintptr(size))
+	if ret := windows.Errno(r1); ret != 0 {
+		return fmt.Errorf("PerfAddCounters failed: %v", ret)
+	}
+	if id.Status != 0 {
+		return fmt.Errorf("PerfAddCounters failed for counter set %v: %v", guid, windows.Errno(id.Status))
+	}
+	return nil
+}
+
+// parseCounterData parses the PERF_DATA_HEADER block returned by PerfQueryCounterData.
+// The blocks following the header are in the order the counter sets were added.
+func parseCounterData(buf []byte, guids []windows.GUID) (*Snapshot, error) {
+	const dataHeaderSize = 48
+	if len(buf) < dataHeaderSize {
+		return nil, fmt.Errorf("short PERF_DATA_HEADER: %d bytes", len(buf))
+	}
+	le := binary.LittleEndian
+	numBlocks := int(le.Uint32(buf[4:]))
+	snapshot := &Snapshot{
+		Frequency: int64(le.Uint64(buf[24:])),
+		Time:      systemTimeToTime(buf[32:48]),
+	}
+
+	offset := dataHeaderSize
+	for i := 0; i < numBlocks && offset+16 <= len(buf); i++ {
+		status := le.Uint32(buf[offset:])
+		blockType := le.Uint32(buf[offset+4:])
+		blockSize := int(le.Uint32(buf[offset+8:]))
+		if blockSize < 16 || offset+blockSize > len(buf) {
+			return nil, fmt.Errorf("invalid PERF_COUNTER_HEADER at offset %d", offset)
+		}
+		block := buf[offset+16 : offset+blockSize]
+		offset += blockSize
+
+		if i >= len(guids) || status != 0 || blockType == perfErrorReturn {
+			continue
+		}
+		data := CounterSetData{GUID: guids[i]}
+		switch blockType {
+		case perfMultipleCounters:
+			ids, rest := parseMultiCounters(block)
+			data.Instances = []Instance{{Values: parseCounterValues(rest, ids)}}
+		case perfCounterSet:
+			ids, rest := parseMultiCounters(block)
+			data.Instances = parseMultiInstances(rest, ids)
+		default:
+			continue
+		}
+		snapshot.Data = append(snapshot.Data, data)
+	}
+	return snapshot, nil
+}
+
+// systemTimeToTime converts a SYSTEMTIME in UTC.
+// https://docs.microsoft.com/en-us/windows/win32/api/minwinbase/ns-minwinbase-systemtime
+func systemTimeToTime(b []byte) time.Time {
+	le := binary.LittleEndian
+	return time.Date(
+		int(le.Uint16(b[0:])),
+		time.Month(le.Uint16(b[2:])),
+		int(le.Uint16(b[6:])),
+		int(le.Uint16(b[8:])),
+		int(le.Uint16(b[10:])),
+		int(le.Uint16(b[12:])),
+		int(le.Uint16(b[14:]))*int(time.Millisecond),
+		time.UTC,
+	)
+}
+
+// parseMultiCounters parses a PERF_MULTI_COUNTERS block, returning the counter
+// IDs and the remainder of the buffer.
+func parseMultiCounters(buf []byte) ([]uint32, []byte) {
+	if len(buf) < 8 {
+		return nil, nil
+	}
+	size := int(binary.LittleEndian.Uint32(buf))
+	count := int(binary.LittleEndian.Uint32(buf[4:]))
+	if size > len(buf) || 8+count*4 > size {
+		return nil, nil
+	}
+	ids := make([]uint32, count)
+	for i := range ids {
+		ids[i] = binary.LittleEndian.Uint32(buf[8+i*4:])
+	}
+	return ids, buf[size:]
+}
+
+// parseMultiInstances parses a PERF_MULTI_INSTANCES block. Every
+// PERF_INSTANCE_HEADER is followed by one PERF_COUNTER_DATA block per counter.
+func parseMultiInstances(buf []byte, ids []uint32) []Instance {
+	if len(buf) < 8 {
+		return nil
+	}
+	le := binary.LittleEndian
+	count := int(le.Uint32(buf[4:]))
+	instances := make([]Instance, 0, count)
+
+	offset := 8
+	for i := 0; i < count && offset+8 <= len(buf); i++ {
+		headerSize := int(le.Uint32(buf[offset:]))
+		if headerSize < 8 || offset+headerSize > len(buf) {
+			break
+		}
+		name := utf16BytesToString(buf[offset+8 : offset+headerSize])
+		offset += headerSize
+
+		values, consumed := parseCounterDataBlocks(buf[offset:], ids)
+		offset += consumed
+		instances = append(instances, Instance{Name: name, Values: values})
+	}
+	return instances
+}
+
+func parseCounterValues(buf []byte, ids []uint32) map[uint32]int64 {
+	values, _ := parseCounterDataBlocks(buf, ids)
+	return values
+}
+
+// parseCounterDataBlocks parses consecutive PERF_COUNTER_DATA blocks, returning the
+// values indexed by counter ID and the number of bytes consumed.
+func parseCounterDataBlocks(buf []byte, ids []uint32) (map[uint32]int64, int) {
+	le := binary.LittleEndian
+	values := make(map[uint32]int64, len(ids))
+	offset := 0
+	for _, id := range ids {
+		if offset+8 > len(buf) {
+			break
+		}
+		dataSize := int(le.Uint32(buf[offset:]))
+		size := int(le.Uint32(buf[offset+4:]))
+		if size < 8 || offset+size > len(buf) {
+			break
+		}
+		switch {
+		case dataSize >= 8:
+			values[id] = int64(le.Uint64(buf[offset+8:]))
+		case dataSize >= 4:
+			values[id] = int64(le.Uint32(buf[offset+8:]))
+		}
+		offset += size
+	}
+	return values, offset
+}
+
+func queryRegistrationInfo(machine string, guid windows.GUID, requestCode uint32, lang uint32) ([]byte, error) {
+	machinePtr, err := machinePointer(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	var needed uint32
+	r1, _, _ := procPerfQueryCounterSetRegistrationInfo.Call(
+		uintptr(unsafe.Pointer(machinePtr)),
+		uintptr(unsafe.Pointer(&guid)),
+		uintptr(requestCode),
+		uintptr(lang),
+		0,
+		0,
+		uintptr(unsafe.Pointer(&needed)),
+	)
+	if ret := windows.Errno(r1); ret != 0 && ret != errorMoreData && ret != errorNotEnoughMemory {
+		return nil, fmt.Errorf("PerfQueryCounterSetRegistrationInfo failed: %v", ret)
+	}
+	if needed == 0 {
+		return nil, fmt.Errorf("PerfQueryCounterSetRegistrationInfo returned no data for %v", guid)
+	}
+
+	buf := make([]byte, needed)
+	r1, _, _ = procPerfQueryCounterSetRegistrationInfo.Call(
+		uintptr(unsafe.Pointer(machinePtr)),
+		uintptr(unsafe.Pointer(&guid)),
+		uintptr(requestCode),
+		uintptr(lang),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(needed),
+		uintptr(unsafe.Pointer(&needed)),
+	)
+	if ret := windows.Errno(r1); ret != 0 {
+		return nil, fmt.Errorf("PerfQueryCounterSetRegistrationInfo failed: %v", ret)
+	}
+	return buf[:needed], nil
+}
+
+// queryName requests a string from the registration info, preferring the
+// English variant and falling back to the English-locale localized string on
+// systems that don't support the former.
+func queryName(machine string, guid windows.GUID, englishCode uint32, localizedCode uint32) (string, error) {
+	buf, err := queryRegistrationInfo(machine, guid, englishCode, 0)
+	if err != nil {
+		buf, err = queryRegistrationInfo(machine, guid, localizedCode, langEnglish)
+		if err != nil {
+			return "", err
+		}
+	}
+	return utf16BytesToString(buf), nil
+}
+
+// queryCounterNames returns the counter names of a counter set indexed by counter ID.
+// The data is a PERF_STRING_BUFFER_HEADER followed by PERF_STRING_COUNTER_HEADER entries.
+func queryCounterNames(machine string, guid windows.GUID) (map[uint32]string, error) {
+	buf, err := queryRegistrationInfo(machine, guid, perfRegCounterEnglishNames, 0)
+	if err != nil {
+		buf, err = queryRegistrationInfo(machine, guid, perfRegCounterNameStrings, langEnglish)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	le := binary.LittleEndian
+	names := make(map[uint32]string)
+	if len(buf) < 8 {
+		return names, nil
+	}
+	count := int(le.Uint32(buf[4:]))
+	for i := 0; i < count && 8+i*8+8 <= len(buf); i++ {
+		id := le.Uint32(buf[8+i*8:])
+		offset := le.Uint32(buf[8+i*8+4:])
+		if offset == 0xFFFFFFFF || int(offset) >= len(buf) {
+			continue
+		}
+		names[id] = utf16BytesToString(buf[offset:])
+	}
+	return names, nil
+}
+
+func machinePointer(machine string) (*uint16, error) {
+	if machine == "" {
+		return nil, nil
+	}
+	return windows.UTF16PtrFromString(machine)
+}
+
+// utf16BytesToString decodes a NUL-terminated little-endian UTF-16 string.
+func utf16BytesToString(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,169 @@
+package perflib
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Counter set instance types and counter attributes used by providers.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counterset_info
+const (
+	CounterSetSingleInstance = 0
+	CounterSetMultiInstances = 2
+
+	perfAttribByValue  = 0
+	perfDetailNovice   = 100
+	counterValueSize   = 8
+	counterSetInfoSize = int(unsafe.Sizeof(perfCounterSetInfo{}))
+)
+
+var (
+	procPerfStartProvider            = advapi32.NewProc("PerfStartProvider")
+	procPerfStopProvider             = advapi32.NewProc("PerfStopProvider")
+	procPerfSetCounterSetInfo        = advapi32.NewProc("PerfSetCounterSetInfo")
+	procPerfCreateInstance           = advapi32.NewProc("PerfCreateInstance")
+	procPerfDeleteInstance           = advapi32.NewProc("PerfDeleteInstance")
+	procPerfSetULongLongCounterValue = advapi32.NewProc("PerfSetULongLongCounterValue")
+)
+
+// perfCounterSetInfo is a wrapper of PERF_COUNTERSET_INFO
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counterset_info
+type perfCounterSetInfo struct {
+	CounterSetGuid windows.GUID
+	ProviderGuid   windows.GUID
+	NumCounters    uint32
+	InstanceType   uint32
+}
+
+// perfCounterInfo is a wrapper of PERF_COUNTER_INFO
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/ns-perflib-perf_counter_info
+type perfCounterInfo struct {
+	CounterId   uint32
+	Type        uint32
+	Attrib      uint64
+	Size        uint32
+	DetailLevel uint32
+	Scale       int32
+	Offset      uint32
+}
+
+// Provider publishes counter sets that are declared in an instrumentation
+// manifest registered with `lodctr /m:`. All counter values are 64 bit wide.
+type Provider struct {
+	handle windows.Handle
+	guid   windows.GUID
+}
+
+// ProviderInstance is an instance of a counter set created by a Provider.
+type ProviderInstance struct {
+	provider   *Provider
+	counterSet windows.GUID
+	ptr        uintptr
+}
+
+// StartProvider registers the provider identified by guid.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfstartprovider
+func StartProvider(guid windows.GUID) (*Provider, error) {
+	p := &Provider{guid: guid}
+	r1, _, _ := procPerfStartProvider.Call(
+		uintptr(unsafe.Pointer(&guid)),
+		0,
+		uintptr(unsafe.Pointer(&p.handle)),
+	)
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	return p, nil
+}
+
+// Stop unregisters the provider, deleting all of its instances.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfstopprovider
+func (p *Provider) Stop() error {
+	r1, _, _ := procPerfStopProvider.Call(uintptr(p.handle))
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// SetCounterSetInfo describes the layout of a counter set to the system. The
+// counters must match those declared in the manifest, and are laid out in
+// the given order.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfsetcountersetinfo
+func (p *Provider) SetCounterSetInfo(counterSet windows.GUID, instanceType uint32, counters []Counter) error {
+	infoSize := int(unsafe.Sizeof(perfCounterInfo{}))
+	buf := make([]byte, counterSetInfoSize+len(counters)*infoSize)
+	*(*perfCounterSetInfo)(unsafe.Pointer(&buf[0])) = perfCounterSetInfo{
+		CounterSetGuid: counterSet,
+		ProviderGuid:   p.guid,
+		NumCounters:    uint32(len(counters)),
+		InstanceType:   instanceType,
+	}
+	for i, c := range counters {
+		*(*perfCounterInfo)(unsafe.Pointer(&buf[counterSetInfoSize+i*infoSize])) = perfCounterInfo{
+			CounterId:   c.ID,
+			Type:        c.Type,
+			Attrib:      perfAttribByValue,
+			Size:        counterValueSize,
+			DetailLevel: perfDetailNovice,
+			Offset:      uint32(i * counterValueSize),
+		}
+	}
+
+	r1, _, _ := procPerfSetCounterSetInfo.Call(
+		uintptr(p.handle),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+	)
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// CreateInstance creates a named instance of counterSet. id must be unique
+// among the instances of the counter set.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfcreateinstance
+func (p *Provider) CreateInstance(counterSet windows.GUID, name string, id uint32) (*ProviderInstance, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	r1, _, err := procPerfCreateInstance.Call(
+		uintptr(p.handle),
+		uintptr(unsafe.Pointer(&counterSet)),
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(id),
+	)
+	if r1 == 0 {
+		return nil, err
+	}
+	return &ProviderInstance{provider: p, counterSet: counterSet, ptr: r1}, nil
+}
+
+// Delete removes the instance.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfdeleteinstance
+func (i *ProviderInstance) Delete() error {
+	r1, _, _ := procPerfDeleteInstance.Call(uintptr(i.provider.handle), i.ptr)
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// SetValue sets the value of counter id.
+// https://docs.microsoft.com/en-us/windows/win32/api/perflib/nf-perflib-perfsetulonglongcountervalue
+func (i *ProviderInstance) SetValue(id uint32, value uint64) error {
+	var r1 uintptr
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		r1, _, _ = procPerfSetULongLongCounterValue.Call(uintptr(i.provider.handle), i.ptr, uintptr(id), uintptr(value))
+	} else {
+		// On 32 bit, ULONGLONG arguments are passed as two stack slots, low word first.
+		r1, _, _ = procPerfSetULongLongCounterValue.Call(uintptr(i.provider.handle), i.ptr, uintptr(id), uintptr(value), uintptr(value>>32))
+	}
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package perflib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// perfNoInstances is the NumInstances of objects that have no instances, but
+// a single counter block.
+const perfNoInstances = -1
+
+// RegistryCounter describes a counter of an object read from
+// HKEY_PERFORMANCE_DATA.
+type RegistryCounter struct {
+	NameIndex uint32
+	// Type is one of the PERF_* counter types.
+	Type uint32
+}
+
+// RegistryInstance holds the raw counter values of one instance, in the order
+// of the counters of its object.
+type RegistryInstance struct {
+	Name   string
+	Values []int64
+}
+
+// RegistryObject is a performance object read from HKEY_PERFORMANCE_DATA.
+// Objects without instances have a single unnamed instance.
+type RegistryObject struct {
+	NameIndex uint32
+	Frequency int64
+	Counters  []RegistryCounter
+	Instances []RegistryInstance
+}
+
+// RegistrySnapshot is the result of a single HKEY_PERFORMANCE_DATA query.
+type RegistrySnapshot struct {
+	// Time is the time the counters were sampled, on the sampled machine's
+	// clock.
+	Time    time.Time
+	Objects []RegistryObject
+}
+
+// QueryRegistry reads the objects with the given space separated name indices
+// from the HKEY_PERFORMANCE_DATA key of a machine, through the Remote Registry
+// service for remote machines. Unlike Query, it doesn't need the RPC interface
+// of the PerfLib V2 consumer API.
+// https://docs.microsoft.com/en-us/windows/win32/perfctrs/using-the-registry-functions-to-consume-counter-data
+func QueryRegistry(machine string, query string) (*RegistrySnapshot, error) {
+	buf, err := queryPerformanceData(machine, query)
+	if err != nil {
+		return nil, err
+	}
+	return parsePerfDataBlock(buf)
+}
+
+// QueryRegistryNames returns the English names of the objects and counters of
+// a machine, indexed by their name index.
+func QueryRegistryNames(machine string) (map[uint32]string, error) {
+	buf, err := queryPerformanceData(machine, "Counter 009")
+	if err != nil {
+		return nil, err
+	}
+	return parseNameTable(buf), nil
+}
+
+func queryPerformanceData(machine string, value string) ([]byte, error) {
+	key := registry.PERFORMANCE_DATA
+	if machine != "" {
+		var err error
+		key, err = registry.OpenRemoteKey(machine, registry.PERFORMANCE_DATA)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to the remote registry of %s failed: %v", machine, err)
+		}
+	}
+	defer key.Close()
+
+	// The size of the data isn't known in advance, and isn't returned with
+	// ERROR_MORE_DATA for HKEY_PERFORMANCE_DATA.
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := key.GetValue(value, buf)
+		switch err {
+		case nil:
+			return buf[:n], nil
+		case windows.ERROR_MORE_DATA:
+			buf = make([]byte, 2*len(buf))
+		default:
+			return nil, fmt.Errorf("querying %q from HKEY_PERFORMANCE_DATA failed: %v", value, err)
+		}
+	}
+}
+
+// parsePerfDataBlock parses the PERF_DATA_BLOCK returned by HKEY_PERFORMANCE_DATA.
+// https://docs.microsoft.com/en-us/windows/win32/api/winperf/ns-winperf-perf_data_block
+func parsePerfDataBlock(buf []byte) (*RegistrySnapshot, error) {
+	const (
+		dataBlockSize  = 88
+		objectTypeSize = 64
+		counterDefSize = 40
+		instanceSize   = 24
+	)
+	le := binary.LittleEndian
+	if len(buf) < dataBlockSize || utf16BytesToString(buf[:8]) != "PERF" {
+		return nil, fmt.Errorf("invalid PERF_DATA_BLOCK")
+	}
+	numObjects := int(le.Uint32(buf[28:]))
+	snapshot := &RegistrySnapshot{Time: systemTimeToTime(buf[36:52])}
+
+	offset := int(le.Uint32(buf[24:]))
+	for i := 0; i < numObjects; i++ {
+		if offset+objectTypeSize > len(buf) {
+			return nil, fmt.Errorf("invalid PERF_OBJECT_TYPE at offset %d", offset)
+		}
+		objBuf := buf[offset:]
+		totalLength := int(le.Uint32(objBuf))
+		definitionLength := int(le.Uint32(objBuf[4:]))
+		headerLength := int(le.Uint32(objBuf[8:]))
+		numCounters := int(le.Uint32(objBuf[32:]))
+		numInstances := int(int32(le.Uint32(objBuf[40:])))
+		if totalLength < objectTypeSize || offset+totalLength > len(buf) || definitionLength > totalLength {
+			return nil, fmt.Errorf("invalid PERF_OBJECT_TYPE at offset %d", offset)
+		}
+		objBuf = objBuf[:totalLength]
+		offset += totalLength
+
+		obj := RegistryObject{
+			NameIndex: le.Uint32(objBuf[12:]),
+			Frequency: int64(le.Uint64(objBuf[56:])),
+			Counters:  make([]RegistryCounter, 0, numCounters),
+		}
+		// PERF_COUNTER_DEFINITION entries, the value offsets are relative
+		// to the PERF_COUNTER_BLOCK of an instance.
+		var sizes, offsets []int
+		defOffset := headerLength
+		for j := 0; j < numCounters; j++ {
+			if defOffset+counterDefSize > definitionLength {
+				return nil, fmt.Errorf("invalid PERF_COUNTER_DEFINITION in object %d", obj.NameIndex)
+			}
+			def := objBuf[defOffset:]
+			obj.Counters = append(obj.Counters, RegistryCounter{
+				NameIndex: le.Uint32(def[4:]),
+				Type:      le.Uint32(def[28:]),
+			})
+			sizes = append(sizes, int(le.Uint32(def[32:])))
+			offsets = append(offsets, int(le.Uint32(def[36:])))
+			defOffset += int(le.Uint32(def))
+		}
+
+		if numInstances == perfNoInstances {
+			values, blockLength := parseCounterBlock(objBuf[definitionLength:], sizes, offsets)
+			if blockLength == 0 {
+				return nil, fmt.Errorf("invalid PERF_COUNTER_BLOCK in object %d", obj.NameIndex)
+			}
+			obj.Instances = []RegistryInstance{{Values: values}}
+		}
+		instOffset := definitionLength
+		for j := 0; j < numInstances; j++ {
+			if instOffset+instanceSize > len(objBuf) {
+				return nil, fmt.Errorf("invalid PERF_INSTANCE_DEFINITION in object %d", obj.NameIndex)
+			}
+			inst := objBuf[instOffset:]
+			byteLength := int(le.Uint32(inst))
+			nameOffset := int(le.Uint32(inst[16:]))
+			nameLength := int(le.Uint32(inst[20:]))
+			if byteLength < instanceSize || nameOffset+nameLength > byteLength || instOffset+byteLength > len(objBuf) {
+				return nil, fmt.Errorf("invalid PERF_INSTANCE_DEFINITION in object %d", obj.NameIndex)
+			}
+			name := utf16BytesToString(inst[nameOffset : nameOffset+nameLength])
+			values, blockLength := parseCounterBlock(objBuf[instOffset+byteLength:], sizes, offsets)
+			if blockLength == 0 {
+				return nil, fmt.Errorf("invalid PERF_COUNTER_BLOCK in object %d", obj.NameIndex)
+			}
+			obj.Instances = append(obj.Instances, RegistryInstance{Name: name, Values: values})
+			instOffset += byteLength + blockLength
+		}
+		snapshot.Objects = append(snapshot.Objects, obj)
+	}
+	return snapshot, nil
+}
+
+// parseCounterBlock parses a PERF_COUNTER_BLOCK, returning the values of the
+// counters and the length of the block, or 0 if the block is invalid.
+func parseCounterBlock(buf []byte, sizes []int, offsets []int) ([]int64, int) {
+	le := binary.LittleEndian
+	if len(buf) < 4 {
+		return nil, 0
+	}
+	length := int(le.Uint32(buf))
+	if length < 4 || length > len(buf) {
+		return nil, 0
+	}
+	values := make([]int64, len(sizes))
+	for i, size := range sizes {
+		switch o := offsets[i]; {
+		case size == 8 && o+8 <= length:
+			values[i] = int64(le.Uint64(buf[o:]))
+		case o+4 <= length:
+			values[i] = int64(le.Uint32(buf[o:]))
+		}
+	}
+	return values, length
+}
+
+// parseNameTable parses the REG_MULTI_SZ name table of HKEY_PERFORMANCE_DATA,
+// which alternates between name indices and names.
+func parseNameTable(buf []byte) map[uint32]string {
+	u := make([]uint16, len(buf)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(buf[2*i:])
+	}
+	strs := strings.Split(string(utf16.Decode(u)), "\x00")
+	names := make(map[uint32]string, len(strs)/2)
+	for i := 0; i+1 < len(strs); i += 2 {
+		index, err := strconv.ParseUint(strs[i], 10, 32)
+		if err != nil || strs[i+1] == "" {
+			continue
+		}
+		names[uint32(index)] = strs[i+1]
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+package sspi
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	secpkgCredOutbound          = 2
+	secWinntAuthIdentityUnicode = 2
+	securityNativeDrep          = 0x10
+	secbufferVersion            = 0
+	secbufferToken              = 2
+
+	iscReqDelegate       = 0x1
+	iscReqMutualAuth     = 0x2
+	iscReqAllocateMemory = 0x100
+	iscRetDelegate       = 0x1
+
+	secIContinueNeeded = 0x00090312
+)
+
+// secHandle is a wrapper of SecHandle/CredHandle/CtxtHandle
+// https://docs.microsoft.com/en-us/windows/win32/secauthn/sechandle
+type secHandle struct {
+	dwLower uintptr
+	dwUpper uintptr
+}
+
+// secWinntAuthIdentity is a wrapper of SEC_WINNT_AUTH_IDENTITY_W
+// https://docs.microsoft.com/en-us/windows/win32/api/sspi/ns-sspi-sec_winnt_auth_identity_w
+type secWinntAuthIdentity struct {
+	User           *uint16
+	UserLength     uint32
+	Domain         *uint16
+	DomainLength   uint32
+	Password       *uint16
+	PasswordLength uint32
+	Flags          uint32
+}
+
+// secBuffer is a wrapper of SecBuffer
+// https://docs.microsoft.com/en-us/windows/win32/api/sspi/ns-sspi-secbuffer
+type secBuffer struct {
+	cbBuffer   uint32
+	BufferType uint32
+	pvBuffer   uintptr
+}
+
+// secBufferDesc is a wrapper of SecBufferDesc
+// https://docs.microsoft.com/en-us/windows/win32/api/sspi/ns-sspi-secbufferdesc
+type secBufferDesc struct {
+	ulVersion uint32
+	cBuffers  uint32
+	pBuffers  *secBuffer
+}
+
+var (
+	secur32                        = windows.NewLazySystemDLL("secur32.dll")
+	procAcquireCredentialsHandleW  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContextW = secur32.NewProc("InitializeSecurityContextW")
+	procFreeCredentialsHandle      = secur32.NewProc("FreeCredentialsHandle")
+	procDeleteSecurityContext      = secur32.NewProc("DeleteSecurityContext")
+	procFreeContextBuffer          = secur32.NewProc("FreeContextBuffer")
+)
+
+// Ticket describes the outcome of requesting a service ticket.
+type Ticket struct {
+	// Delegable is true if the ticket allows the target to delegate the
+	// client's identity, i.e. the account is trusted for delegation to the SPN.
+	Delegable bool
+}
+
+// RequestTicket requests a service ticket for spn from the security package
+// pkg, e.g. "Kerberos" or "Negotiate". If username is empty, the credentials
+// of the calling process are used, which is how group managed service accounts
+// authenticate. Usernames may be given as DOMAIN\user or user@domain.
+// https://docs.microsoft.com/en-us/windows/win32/secauthn/initializesecuritycontext--kerberos
+func RequestTicket(pkg, spn, username, password string) (Ticket, error) {
+	pkgPtr, err := windows.UTF16PtrFromString(pkg)
+	if err != nil {
+		return Ticket{}, err
+	}
+	spnPtr, err := windows.UTF16PtrFromString(spn)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	var identity *secWinntAuthIdentity
+	if username != "" {
+		identity, err = newAuthIdentity(username, password)
+		if err != nil {
+			return Ticket{}, err
+		}
+	}
+
+	var cred secHandle
+	var expiry int64
+	r1, _, _ := procAcquireCredentialsHandleW.Call(
+		0,
+		uintptr(unsafe.Pointer(pkgPtr)),
+		secpkgCredOutbound,
+		0,
+		uintptr(unsafe.Pointer(identity)),
+		0,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if r1 != 0 {
+		return Ticket{}, windows.Errno(r1)
+	}
+	defer procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&cred)))
+
+	out := secBuffer{BufferType: secbufferToken}
+	outDesc := secBufferDesc{ulVersion: secbufferVersion, cBuffers: 1, pBuffers: &out}
+	var ctx secHandle
+	var attrs uint32
+	r1, _, _ = procInitializeSecurityContextW.Call(
+		uintptr(unsafe.Pointer(&cred)),
+		0,
+		uintptr(unsafe.Pointer(spnPtr)),
+		iscReqMutualAuth|iscReqDelegate|iscReqAllocateMemory,
+		0,
+		securityNativeDrep,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&ctx)),
+		uintptr(unsafe.Pointer(&outDesc)),
+		uintptr(unsafe.Pointer(&attrs)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if out.pvBuffer != 0 {
+		defer procFreeContextBuffer.Call(out.pvBuffer)
+	}
+	if r1 != 0 && r1 != secIContinueNeeded {
+		return Ticket{}, windows.Errno(r1)
+	}
+	defer procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&ctx)))
+
+	return Ticket{Delegable: attrs&iscRetDelegate != 0}, nil
+}
+
+func newAuthIdentity(username, password string) (*secWinntAuthIdentity, error) {
+	var user, domain string
+	if i := strings.Index(username, `\`); i >= 0 {
+		domain, user = username[:i], username[i+1:]
+	} else {
+		// user@domain is passed as is, with an empty domain.
+		user = username
+	}
+
+	userPtr, err := windows.UTF16FromString(user)
+	if err != nil {
+		return nil, err
+	}
+	domainPtr, err := windows.UTF16FromString(domain)
+	if err != nil {
+		return nil, err
+	}
+	passwordPtr, err := windows.UTF16FromString(password)
+	if err != nil {
+		return nil, err
+	}
+	return &secWinntAuthIdentity{
+		User:           &userPtr[0],
+		UserLength:     uint32(len(userPtr) - 1),
+		Domain:         &domainPtr[0],
+		DomainLength:   uint32(len(domainPtr) - 1),
+		Password:       &passwordPtr[0],
+		PasswordLength: uint32(len(passwordPtr) - 1),
+		Flags:          secWinntAuthIdentityUnicode,
+	}, nil
+}
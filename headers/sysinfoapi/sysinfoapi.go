@@ -98,11 +98,23 @@ const (
 	ComputerNameMax
 )
 
+// FirmwareType is the type of firmware the system booted from.
+// https://docs.microsoft.com/en-us/windows/win32/api/winnt/ne-winnt-firmware_type
+type FirmwareType uint32
+
+const (
+	FirmwareTypeUnknown FirmwareType = iota
+	FirmwareTypeBios
+	FirmwareTypeUefi
+)
+
 var (
 	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
 	procGetSystemInfo        = kernel32.NewProc("GetSystemInfo")
 	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
 	procGetComputerNameExW   = kernel32.NewProc("GetComputerNameExW")
+	procGetTickCount64       = kernel32.NewProc("GetTickCount64")
+	procGetFirmwareType      = kernel32.NewProc("GetFirmwareType")
 )
 
 // GlobalMemoryStatusEx retrieves information about the system's current usage of both physical and virtual memory.
@@ -162,3 +174,25 @@ func GetComputerName(f WinComputerNameFormat) (string, error) {
 	out := utf16.Decode(bytes)
 	return string(out), nil
 }
+
+// GetTickCount64 returns the number of milliseconds that have elapsed since the system was started.
+// https://docs.microsoft.com/en-us/windows/win32/api/sysinfoapi/nf-sysinfoapi-gettickcount64
+func GetTickCount64() uint64 {
+	r1, r2, _ := procGetTickCount64.Call()
+	if unsafe.Sizeof(r1) == 4 {
+		// 64 bit return values are split across EAX and EDX on 32 bit.
+		return uint64(r1) | uint64(r2)<<32
+	}
+	return uint64(r1)
+}
+
+// GetFirmwareType returns the type of firmware the system booted from.
+// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-getfirmwaretype
+func GetFirmwareType() (FirmwareType, error) {
+	var t FirmwareType
+	r1, _, err := procGetFirmwareType.Call(uintptr(unsafe.Pointer(&t)))
+	if r1 == 0 {
+		return FirmwareTypeUnknown, err
+	}
+	return t, nil
+}
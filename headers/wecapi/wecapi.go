@@ -0,0 +1,279 @@
+package wecapi
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ecReadAccess   = 1
+	ecOpenExisting = 1
+
+	// EC_SUBSCRIPTION_PROPERTY_ID
+	ecSubscriptionEnabled = 0
+	ecSubscriptionLogFile = 19
+	ecSubscriptionType    = 27
+
+	// EC_SUBSCRIPTION_RUNTIME_STATUS_INFO_ID
+	ecSubscriptionRunTimeStatusActive            = 0
+	ecSubscriptionRunTimeStatusLastError         = 1
+	ecSubscriptionRunTimeStatusEventSources      = 5
+	ecSubscriptionRunTimeStatusLastHeartbeatTime = 6
+
+	// EC_VARIANT_TYPE
+	ecVarTypeBoolean   = 1
+	ecVarTypeUInt32    = 2
+	ecVarTypeDateTime  = 3
+	ecVarTypeString    = 4
+	ecVariantTypeArray = 128
+)
+
+// Runtime statuses of subscriptions and their event sources, as in
+// EC_SUBSCRIPTION_RUNTIME_STATUS_ACTIVE_STATUS.
+const (
+	StatusDisabled = 1
+	StatusActive   = 2
+	StatusInactive = 3
+	StatusTrying   = 4
+)
+
+// Types of subscriptions, as in EC_SUBSCRIPTION_TYPE.
+const (
+	TypeSourceInitiated    = 0
+	TypeCollectorInitiated = 1
+)
+
+var (
+	wecapi                             = windows.NewLazySystemDLL("wecapi.dll")
+	procEcOpenSubscriptionEnum         = wecapi.NewProc("EcOpenSubscriptionEnum")
+	procEcEnumNextSubscription         = wecapi.NewProc("EcEnumNextSubscription")
+	procEcOpenSubscription             = wecapi.NewProc("EcOpenSubscription")
+	procEcGetSubscriptionProperty      = wecapi.NewProc("EcGetSubscriptionProperty")
+	procEcGetSubscriptionRunTimeStatus = wecapi.NewProc("EcGetSubscriptionRunTimeStatus")
+	procEcClose                        = wecapi.NewProc("EcClose")
+)
+
+// Subscription is a subscription of the Windows Event Collector service.
+type Subscription struct {
+	Name    string
+	Type    uint32
+	Enabled bool
+	// LogFile is the event log the events are forwarded to, e.g.
+	// ForwardedEvents.
+	LogFile   string
+	Status    uint32
+	LastError uint32
+	Sources   []EventSource
+}
+
+// EventSource is a computer forwarding events to a subscription.
+type EventSource struct {
+	Name      string
+	Status    uint32
+	LastError uint32
+	// LastHeartbeat is zero if the source never sent a heartbeat.
+	LastHeartbeat time.Time
+}
+
+// ecVariant is a wrapper for EC_VARIANT, whose value is a union of 8 bytes.
+// https://docs.microsoft.com/en-us/windows/win32/api/evcoll/ns-evcoll-ec_variant
+type ecVariant struct {
+	Value uint64
+	Count uint32
+	Type  uint32
+}
+
+// pointer returns the value of a variant holding a pointer.
+func (v *ecVariant) pointer() unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&v.Value))
+}
+
+// getVariant calls a function returning an EC_VARIANT in a buffer of the
+// given size, growing the buffer until the variant fits. The variant is only
+// valid as long as the buffer returned.
+func getVariant(call func(size uintptr, buf uintptr, used uintptr) (uintptr, error)) (*ecVariant, []uint64, error) {
+	buf := make([]uint64, 8)
+	for {
+		var used uint32
+		r1, err := call(uintptr(len(buf)*8), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+		if r1 == 0 {
+			if err == windows.ERROR_INSUFFICIENT_BUFFER {
+				buf = make([]uint64, (used+7)/8)
+				continue
+			}
+			return nil, nil, err
+		}
+		return (*ecVariant)(unsafe.Pointer(&buf[0])), buf, nil
+	}
+}
+
+// SubscriptionNames returns the names of the subscriptions of the local
+// Windows Event Collector service.
+// https://docs.microsoft.com/en-us/windows/win32/api/evcoll/nf-evcoll-ecenumnextsubscription
+func SubscriptionNames() ([]string, error) {
+	h, _, err := procEcOpenSubscriptionEnum.Call(0)
+	if h == 0 {
+		return nil, err
+	}
+	defer procEcClose.Call(h)
+
+	var names []string
+	buf := make([]uint16, 256)
+	for {
+		var used uint32
+		r1, _, err := procEcEnumNextSubscription.Call(
+			h,
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&used)),
+		)
+		if r1 == 0 {
+			switch err {
+			case windows.ERROR_NO_MORE_ITEMS:
+				return names, nil
+			case windows.ERROR_INSUFFICIENT_BUFFER:
+				buf = make([]uint16, used)
+				continue
+			}
+			return nil, err
+		}
+		names = append(names, windows.UTF16ToString(buf))
+	}
+}
+
+// QuerySubscription returns the configuration and the runtime status of the
+// subscription and of its event sources.
+func QuerySubscription(name string) (Subscription, error) {
+	s := Subscription{Name: name}
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return s, err
+	}
+
+	h, _, err := procEcOpenSubscription.Call(uintptr(unsafe.Pointer(namePtr)), ecReadAccess, ecOpenExisting)
+	if h == 0 {
+		return s, err
+	}
+	defer procEcClose.Call(h)
+
+	property := func(id uintptr) (*ecVariant, []uint64, error) {
+		return getVariant(func(size, buf, used uintptr) (uintptr, error) {
+			r1, _, err := procEcGetSubscriptionProperty.Call(h, id, 0, size, buf, used)
+			return r1, err
+		})
+	}
+	v, _, err := property(ecSubscriptionEnabled)
+	if err != nil {
+		return s, err
+	}
+	s.Enabled = v.Type == ecVarTypeBoolean && uint32(v.Value) != 0
+	v, _, err = property(ecSubscriptionType)
+	if err != nil {
+		return s, err
+	}
+	if v.Type == ecVarTypeUInt32 {
+		s.Type = uint32(v.Value)
+	}
+	v, buf, err := property(ecSubscriptionLogFile)
+	if err != nil {
+		return s, err
+	}
+	if v.Type == ecVarTypeString {
+		s.LogFile = windows.UTF16PtrToString((*uint16)(v.pointer()))
+	}
+	runtime.KeepAlive(buf)
+
+	s.Status, s.LastError, _, err = runTimeStatus(namePtr, nil)
+	if err != nil {
+		return s, err
+	}
+	sources, err := eventSources(namePtr)
+	if err != nil {
+		return s, err
+	}
+	for _, source := range sources {
+		sourcePtr, err := windows.UTF16PtrFromString(source)
+		if err != nil {
+			return s, err
+		}
+		status, lastError, heartbeat, err := runTimeStatus(namePtr, sourcePtr)
+		if err != nil {
+			return s, err
+		}
+		s.Sources = append(s.Sources, EventSource{Name: source, Status: status, LastError: lastError, LastHeartbeat: heartbeat})
+	}
+	return s, nil
+}
+
+// runTimeStatusVariant returns a runtime status of the subscription, or of an
+// event source of it if source isn't nil.
+// https://docs.microsoft.com/en-us/windows/win32/api/evcoll/nf-evcoll-ecgetsubscriptionruntimestatus
+func runTimeStatusVariant(subscription *uint16, id uintptr, source *uint16) (*ecVariant, []uint64, error) {
+	return getVariant(func(size, buf, used uintptr) (uintptr, error) {
+		r1, _, err := procEcGetSubscriptionRunTimeStatus.Call(
+			uintptr(unsafe.Pointer(subscription)),
+			id,
+			uintptr(unsafe.Pointer(source)),
+			0,
+			size,
+			buf,
+			used,
+		)
+		return r1, err
+	})
+}
+
+// runTimeStatus returns the status and last error of the subscription, or of
+// an event source of it with its last heartbeat if source isn't nil.
+func runTimeStatus(subscription *uint16, source *uint16) (uint32, uint32, time.Time, error) {
+	var status, lastError uint32
+	var heartbeat time.Time
+
+	v, _, err := runTimeStatusVariant(subscription, ecSubscriptionRunTimeStatusActive, source)
+	if err != nil {
+		return 0, 0, heartbeat, err
+	}
+	if v.Type == ecVarTypeUInt32 {
+		status = uint32(v.Value)
+	}
+	v, _, err = runTimeStatusVariant(subscription, ecSubscriptionRunTimeStatusLastError, source)
+	if err != nil {
+		return 0, 0, heartbeat, err
+	}
+	if v.Type == ecVarTypeUInt32 {
+		lastError = uint32(v.Value)
+	}
+	if source == nil {
+		return status, lastError, heartbeat, nil
+	}
+	v, _, err = runTimeStatusVariant(subscription, ecSubscriptionRunTimeStatusLastHeartbeatTime, source)
+	if err != nil {
+		return 0, 0, heartbeat, err
+	}
+	if v.Type == ecVarTypeDateTime && v.Value != 0 {
+		ft := windows.Filetime{LowDateTime: uint32(v.Value), HighDateTime: uint32(v.Value >> 32)}
+		heartbeat = time.Unix(0, ft.Nanoseconds())
+	}
+	return status, lastError, heartbeat, nil
+}
+
+// eventSources returns the names of the event sources of the subscription.
+func eventSources(subscription *uint16) ([]string, error) {
+	v, buf, err := runTimeStatusVariant(subscription, ecSubscriptionRunTimeStatusEventSources, nil)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type != ecVarTypeString|ecVariantTypeArray || v.Count == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, v.Count)
+	for _, p := range (*[1 << 20]*uint16)(v.pointer())[:v.Count:v.Count] {
+		names = append(names, windows.UTF16PtrToString(p))
+	}
+	// The strings are held by the buffer.
+	runtime.KeepAlive(buf)
+	return names, nil
+}
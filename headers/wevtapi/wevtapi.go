@@ -0,0 +1,243 @@
+package wevtapi
+
+import (
+	"encoding/xml"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	evtQueryChannelPath      = 0x1
+	evtRenderEventXml        = 1
+	evtFormatMessageEvent    = 1
+	evtOpenChannelPath       = 0x1
+	evtLogNumberOfLogRecords = 5
+	evtLogOldestRecordNumber = 6
+
+	batchSize = 64
+)
+
+var (
+	wevtapi       = windows.NewLazySystemDLL("wevtapi.dll")
+	procEvtQuery  = wevtapi.NewProc("EvtQuery")
+	procEvtNext   = wevtapi.NewProc("EvtNext")
+	procEvtRender = wevtapi.NewProc("EvtRender")
+	procEvtClose  = wevtapi.NewProc("EvtClose")
+
+	procEvtOpenPublisherMetadata = wevtapi.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = wevtapi.NewProc("EvtFormatMessage")
+	procEvtOpenLog               = wevtapi.NewProc("EvtOpenLog")
+	procEvtGetLogInfo            = wevtapi.NewProc("EvtGetLogInfo")
+)
+
+// Event is an event rendered as XML, with its message if requested.
+type Event struct {
+	XML     string
+	Message string
+}
+
+// evtVariant is a wrapper for EVT_VARIANT
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/ns-winevt-evt_variant
+type evtVariant struct {
+	Value uint64
+	Count uint32
+	Type  uint32
+}
+
+// Query returns the events of the channel matching the XPath query, oldest
+// first, rendered as XML.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/nf-winevt-evtquery
+func Query(channel string, query string) ([]string, error) {
+	events, err := queryEvents(channel, query, false)
+	if err != nil {
+		return nil, err
+	}
+	xmls := make([]string, len(events))
+	for i, e := range events {
+		xmls[i] = e.XML
+	}
+	return xmls, nil
+}
+
+// QueryMessages returns the events matching the query like Query, along with
+// their message as formatted by their provider. The channel is empty for
+// structured XML queries, which name their channels. Messages of providers
+// not installed on the computer are empty.
+func QueryMessages(channel string, query string) ([]Event, error) {
+	return queryEvents(channel, query, true)
+}
+
+func queryEvents(channel string, query string, messages bool) ([]Event, error) {
+	var path *uint16
+	if channel != "" {
+		var err error
+		if path, err = windows.UTF16PtrFromString(channel); err != nil {
+			return nil, err
+		}
+	}
+	q, err := windows.UTF16PtrFromString(query)
+	if err != nil {
+		return nil, err
+	}
+	results, _, err := procEvtQuery.Call(0, uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(q)), evtQueryChannelPath)
+	if results == 0 {
+		return nil, err
+	}
+	defer procEvtClose.Call(results)
+
+	var publishers map[string]uintptr
+	if messages {
+		publishers = make(map[string]uintptr)
+		defer func() {
+			for _, p := range publishers {
+				if p != 0 {
+					procEvtClose.Call(p)
+				}
+			}
+		}()
+	}
+
+	var events []Event
+	handles := make([]windows.Handle, batchSize)
+	buf := make([]uint16, 4096)
+	for {
+		var returned uint32
+		r1, _, err := procEvtNext.Call(
+			results,
+			batchSize,
+			uintptr(unsafe.Pointer(&handles[0])),
+			windows.INFINITE,
+			0,
+			uintptr(unsafe.Pointer(&returned)),
+		)
+		if r1 == 0 {
+			if err == windows.ERROR_NO_MORE_ITEMS {
+				return events, nil
+			}
+			return nil, err
+		}
+		var renderErr error
+		for _, h := range handles[:returned] {
+			if renderErr == nil {
+				var e Event
+				e.XML, buf, renderErr = render(h, buf)
+				if renderErr == nil && messages {
+					e.Message, buf = formatMessage(h, providerMetadata(e.XML, publishers), buf)
+				}
+				events = append(events, e)
+			}
+			procEvtClose.Call(uintptr(h))
+		}
+		if renderErr != nil {
+			return nil, renderErr
+		}
+	}
+}
+
+// render renders the event as XML, growing buf if it's too small.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/nf-winevt-evtrender
+func render(event windows.Handle, buf []uint16) (string, []uint16, error) {
+	for {
+		var used, propertyCount uint32
+		r1, _, err := procEvtRender.Call(
+			0,
+			uintptr(event),
+			evtRenderEventXml,
+			uintptr(len(buf)*2),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&used)),
+			uintptr(unsafe.Pointer(&propertyCount)),
+		)
+		if r1 != 0 {
+			return windows.UTF16ToString(buf[:used/2]), buf, nil
+		}
+		if err != windows.ERROR_INSUFFICIENT_BUFFER {
+			return "", buf, err
+		}
+		buf = make([]uint16, (used+1)/2)
+	}
+}
+
+// providerMetadata returns the metadata of the provider of the event, opened
+// once for each provider, or 0 if the provider isn't installed.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/nf-winevt-evtopenpublishermetadata
+func providerMetadata(eventXML string, publishers map[string]uintptr) uintptr {
+	var e struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"System>Provider"`
+	}
+	if err := xml.Unmarshal([]byte(eventXML), &e); err != nil || e.Provider.Name == "" {
+		return 0
+	}
+	if p, ok := publishers[e.Provider.Name]; ok {
+		return p
+	}
+	var p uintptr
+	if name, err := windows.UTF16PtrFromString(e.Provider.Name); err == nil {
+		p, _, _ = procEvtOpenPublisherMetadata.Call(0, uintptr(unsafe.Pointer(name)), 0, 0, 0)
+	}
+	publishers[e.Provider.Name] = p
+	return p
+}
+
+// formatMessage returns the message of the event, or an empty string if it
+// can't be formatted, growing buf if it's too small.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/nf-winevt-evtformatmessage
+func formatMessage(event windows.Handle, publisher uintptr, buf []uint16) (string, []uint16) {
+	if publisher == 0 {
+		return "", buf
+	}
+	for {
+		var used uint32
+		r1, _, err := procEvtFormatMessage.Call(
+			publisher,
+			uintptr(event),
+			0,
+			0,
+			0,
+			evtFormatMessageEvent,
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&used)),
+		)
+		if r1 != 0 {
+			return windows.UTF16ToString(buf[:used]), buf
+		}
+		if err != windows.ERROR_INSUFFICIENT_BUFFER {
+			return "", buf
+		}
+		buf = make([]uint16, used)
+	}
+}
+
+// LastRecordID returns the record ID of the newest event of the channel, or 0
+// if it has none.
+// https://docs.microsoft.com/en-us/windows/win32/api/winevt/nf-winevt-evtgetloginfo
+func LastRecordID(channel string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(channel)
+	if err != nil {
+		return 0, err
+	}
+	h, _, err := procEvtOpenLog.Call(0, uintptr(unsafe.Pointer(path)), evtOpenChannelPath)
+	if h == 0 {
+		return 0, err
+	}
+	defer procEvtClose.Call(h)
+
+	var values [2]uint64
+	for i, property := range []uintptr{evtLogNumberOfLogRecords, evtLogOldestRecordNumber} {
+		var v evtVariant
+		var used uint32
+		r1, _, err := procEvtGetLogInfo.Call(h, property, unsafe.Sizeof(v), uintptr(unsafe.Pointer(&v)), uintptr(unsafe.Pointer(&used)))
+		if r1 == 0 {
+			return 0, err
+		}
+		values[i] = v.Value
+	}
+	if values[0] == 0 {
+		return 0, nil
+	}
+	return values[1] + values[0] - 1, nil
+}
@@ -0,0 +1,77 @@
+package wincred
+
+import (
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// credTypeGeneric is CRED_TYPE_GENERIC, the type of credentials added with
+// `cmdkey /generic:` or the Credential Manager control panel.
+const credTypeGeneric = 1
+
+// credential is a wrapper of CREDENTIALW
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/ns-wincred-credentialw
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+var (
+	advapi32      = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW = advapi32.NewProc("CredReadW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+// Credential is a generic credential stored in the Credential Manager.
+type Credential struct {
+	UserName string
+	Password string
+}
+
+// Read returns the generic credential stored under target in the Credential
+// Manager of the current user.
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/nf-wincred-credreadw
+func Read(target string) (Credential, error) {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return Credential{}, err
+	}
+	var cred *credential
+	r1, _, err := procCredReadW.Call(
+		uintptr(unsafe.Pointer(targetPtr)),
+		credTypeGeneric,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+	)
+	if r1 == 0 {
+		return Credential{}, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	// Passwords are stored as UTF-16 without terminating NUL.
+	var password []uint16
+	if cred.CredentialBlobSize > 0 {
+		blob := (*[1 << 20]uint16)(unsafe.Pointer(cred.CredentialBlob))[: cred.CredentialBlobSize/2 : cred.CredentialBlobSize/2]
+		password = append(password, blob...)
+	}
+	var userName string
+	if cred.UserName != nil {
+		userName = windows.UTF16PtrToString(cred.UserName)
+	}
+	return Credential{
+		UserName: userName,
+		Password: string(utf16.Decode(password)),
+	}, nil
+}
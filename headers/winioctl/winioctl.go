@@ -0,0 +1,202 @@
+package winioctl
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ioctlStorageQueryProperty                 = 0x2d1400
+	ioctlStorageGetLBProvisioningMapResources = 0x2d5408
+	fsctlGetNTFSVolumeData                    = 0x90064
+	fsctlQueryUSNJournal                      = 0x900f4
+	fsctlQueryPersistentVolumeState           = 0x9023c
+
+	storageDeviceTrimProperty           = 8
+	storageDeviceLBProvisioningProperty = 11
+	propertyStandardQuery               = 0
+
+	persistentVolumeStateShortNameCreationDisabled = 0x1
+)
+
+// storagePropertyQuery is a wrapper for STORAGE_PROPERTY_QUERY
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-storage_property_query
+type storagePropertyQuery struct {
+	PropertyID           uint32
+	QueryType            uint32
+	AdditionalParameters [1]byte
+}
+
+// deviceTrimDescriptor is a wrapper for DEVICE_TRIM_DESCRIPTOR
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-device_trim_descriptor
+type deviceTrimDescriptor struct {
+	Version     uint32
+	Size        uint32
+	TrimEnabled byte
+}
+
+// deviceLBProvisioningDescriptor is a wrapper for
+// DEVICE_LB_PROVISIONING_DESCRIPTOR, whose flags are a bit field.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-device_lb_provisioning_descriptor
+type deviceLBProvisioningDescriptor struct {
+	Version                      uint32
+	Size                         uint32
+	Flags                        byte
+	Reserved1                    [7]byte
+	OptimalUnmapGranularity      uint64
+	UnmapGranularityAlignment    uint64
+	MaxUnmapLbaCount             uint32
+	MaxUnmapBlockDescriptorCount uint32
+}
+
+// storageLBProvisioningMapResources is a wrapper for
+// STORAGE_LB_PROVISIONING_MAP_RESOURCES, whose validity flags are a bit field.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-storage_lb_provisioning_map_resources
+type storageLBProvisioningMapResources struct {
+	Size                      uint32
+	Version                   uint32
+	Valid                     byte
+	Reserved1                 [3]byte
+	Scope                     byte
+	Reserved3                 [3]byte
+	AvailableMappingResources uint64
+	UsedMappingResources      uint64
+}
+
+// NTFSVolumeData is a wrapper for NTFS_VOLUME_DATA_BUFFER
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-ntfs_volume_data_buffer
+type NTFSVolumeData struct {
+	VolumeSerialNumber           int64
+	NumberSectors                int64
+	TotalClusters                int64
+	FreeClusters                 int64
+	TotalReserved                int64
+	BytesPerSector               uint32
+	BytesPerCluster              uint32
+	BytesPerFileRecordSegment    uint32
+	ClustersPerFileRecordSegment uint32
+	MftValidDataLength           int64
+	MftStartLcn                  int64
+	Mft2StartLcn                 int64
+	MftZoneStart                 int64
+	MftZoneEnd                   int64
+}
+
+// USNJournalData is a wrapper for USN_JOURNAL_DATA_V0
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-usn_journal_data_v0
+type USNJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// filePersistentVolumeInformation is a wrapper for
+// FILE_FS_PERSISTENT_VOLUME_INFORMATION
+type filePersistentVolumeInformation struct {
+	VolumeFlags uint32
+	FlagMask    uint32
+	Version     uint32
+	Reserved    uint32
+}
+
+// MappingResources are the resources a thinly provisioned device maps its
+// logical blocks to, in bytes. A count is -1 if the device doesn't report it.
+type MappingResources struct {
+	Available int64
+	Used      int64
+}
+
+// OpenVolume opens a volume, e.g. \\.\C:, for querying its storage
+// properties. Opening a volume for reading requires administrative rights.
+func OpenVolume(path string) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	return windows.CreateFile(p, windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+}
+
+func queryProperty(h windows.Handle, propertyID uint32, out unsafe.Pointer, size uint32) error {
+	query := storagePropertyQuery{PropertyID: propertyID, QueryType: propertyStandardQuery}
+	var returned uint32
+	return windows.DeviceIoControl(h, ioctlStorageQueryProperty, (*byte)(unsafe.Pointer(&query)), uint32(unsafe.Sizeof(query)), (*byte)(out), size, &returned, nil)
+}
+
+// TrimEnabled reports whether the device of the volume supports TRIM, or
+// unmap for SCSI devices.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-ioctl_storage_query_property
+func TrimEnabled(h windows.Handle) (bool, error) {
+	var d deviceTrimDescriptor
+	if err := queryProperty(h, storageDeviceTrimProperty, unsafe.Pointer(&d), uint32(unsafe.Sizeof(d))); err != nil {
+		return false, err
+	}
+	return d.TrimEnabled != 0, nil
+}
+
+// ThinProvisioningEnabled reports whether the device of the volume is thinly
+// provisioned.
+func ThinProvisioningEnabled(h windows.Handle) (bool, error) {
+	var d deviceLBProvisioningDescriptor
+	if err := queryProperty(h, storageDeviceLBProvisioningProperty, unsafe.Pointer(&d), uint32(unsafe.Sizeof(d))); err != nil {
+		return false, err
+	}
+	return d.Flags&1 != 0, nil
+}
+
+// GetMappingResources returns the mapping resources of a thinly provisioned
+// device.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-ioctl_storage_get_lb_provisioning_map_resources
+func GetMappingResources(h windows.Handle) (MappingResources, error) {
+	var r storageLBProvisioningMapResources
+	var returned uint32
+	if err := windows.DeviceIoControl(h, ioctlStorageGetLBProvisioningMapResources, nil, 0, (*byte)(unsafe.Pointer(&r)), uint32(unsafe.Sizeof(r)), &returned, nil); err != nil {
+		return MappingResources{}, err
+	}
+	resources := MappingResources{Available: -1, Used: -1}
+	if r.Valid&1 != 0 {
+		resources.Available = int64(r.AvailableMappingResources)
+	}
+	if r.Valid&2 != 0 {
+		resources.Used = int64(r.UsedMappingResources)
+	}
+	return resources, nil
+}
+
+// GetNTFSVolumeData returns the layout of an NTFS volume, including the size
+// of its master file table.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_get_ntfs_volume_data
+func GetNTFSVolumeData(h windows.Handle) (NTFSVolumeData, error) {
+	var d NTFSVolumeData
+	var returned uint32
+	err := windows.DeviceIoControl(h, fsctlGetNTFSVolumeData, nil, 0, (*byte)(unsafe.Pointer(&d)), uint32(unsafe.Sizeof(d)), &returned, nil)
+	return d, err
+}
+
+// QueryUSNJournal returns the state of the change journal of a volume. It
+// fails with windows.ERROR_JOURNAL_NOT_ACTIVE if the volume has none.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_query_usn_journal
+func QueryUSNJournal(h windows.Handle) (USNJournalData, error) {
+	var d USNJournalData
+	var returned uint32
+	err := windows.DeviceIoControl(h, fsctlQueryUSNJournal, nil, 0, (*byte)(unsafe.Pointer(&d)), uint32(unsafe.Sizeof(d)), &returned, nil)
+	return d, err
+}
+
+// ShortNameCreationDisabled reports whether the creation of 8.3 short names
+// is disabled on the volume itself, which only applies if the system wide
+// setting defers to the volumes.
+// https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_query_persistent_volume_state
+func ShortNameCreationDisabled(h windows.Handle) (bool, error) {
+	in := filePersistentVolumeInformation{Version: 1}
+	var out filePersistentVolumeInformation
+	var returned uint32
+	if err := windows.DeviceIoControl(h, fsctlQueryPersistentVolumeState, (*byte)(unsafe.Pointer(&in)), uint32(unsafe.Sizeof(in)), (*byte)(unsafe.Pointer(&out)), uint32(unsafe.Sizeof(out)), &returned, nil); err != nil {
+		return false, err
+	}
+	return out.VolumeFlags&persistentVolumeStateShortNameCreationDisabled != 0, nil
+}
@@ -0,0 +1,288 @@
+package wlanapi
+
+import (
+	"net"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// InterfaceState is a wrapper of WLAN_INTERFACE_STATE
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ne-wlanapi-wlan_interface_state-r1
+type InterfaceState uint32
+
+const (
+	InterfaceStateNotReady InterfaceState = iota
+	InterfaceStateConnected
+	InterfaceStateAdHocNetworkFormed
+	InterfaceStateDisconnecting
+	InterfaceStateDisconnected
+	InterfaceStateAssociating
+	InterfaceStateDiscovering
+	InterfaceStateAuthenticating
+)
+
+// Notification sources and codes of the media specific module (MSM).
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ns-wlanapi-wlan_notification_data
+const (
+	NotificationSourceMSM = 0x00000010
+
+	NotificationMSMRoamingEnd   = 6
+	NotificationMSMDisconnected = 10
+)
+
+const (
+	clientVersion = 2
+
+	opcodeChannelNumber     = 8
+	opcodeCurrentConnection = 7
+	opcodeRSSI              = 0x10000102
+)
+
+var (
+	wlanapi                      = windows.NewLazySystemDLL("wlanapi.dll")
+	procWlanOpenHandle           = wlanapi.NewProc("WlanOpenHandle")
+	procWlanCloseHandle          = wlanapi.NewProc("WlanCloseHandle")
+	procWlanEnumInterfaces       = wlanapi.NewProc("WlanEnumInterfaces")
+	procWlanQueryInterface       = wlanapi.NewProc("WlanQueryInterface")
+	procWlanFreeMemory           = wlanapi.NewProc("WlanFreeMemory")
+	procWlanRegisterNotification = wlanapi.NewProc("WlanRegisterNotification")
+)
+
+// wlanInterfaceInfo is a wrapper of WLAN_INTERFACE_INFO
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ns-wlanapi-wlan_interface_info
+type wlanInterfaceInfo struct {
+	InterfaceGuid           windows.GUID
+	strInterfaceDescription [256]uint16
+	isState                 InterfaceState
+}
+
+// wlanInterfaceInfoList is a wrapper of WLAN_INTERFACE_INFO_LIST
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ns-wlanapi-wlan_interface_info_list
+type wlanInterfaceInfoList struct {
+	dwNumberOfItems uint32
+	dwIndex         uint32
+	InterfaceInfo   [1 << 10]wlanInterfaceInfo
+}
+
+// wlanConnectionAttributes is a wrapper of WLAN_CONNECTION_ATTRIBUTES,
+// including the embedded WLAN_ASSOCIATION_ATTRIBUTES
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ns-wlanapi-wlan_connection_attributes
+type wlanConnectionAttributes struct {
+	isState            InterfaceState
+	wlanConnectionMode uint32
+	strProfileName     [256]uint16
+	uSSIDLength        uint32
+	ucSSID             [32]byte
+	dot11BssType       uint32
+	dot11Bssid         [6]byte
+	_                  [2]byte
+	dot11PhyType       uint32
+	uDot11PhyIndex     uint32
+	wlanSignalQuality  uint32
+	ulRxRate           uint32
+	ulTxRate           uint32
+	// WLAN_SECURITY_ATTRIBUTES
+	bSecurityEnabled     int32
+	bOneXEnabled         int32
+	dot11AuthAlgorithm   uint32
+	dot11CipherAlgorithm uint32
+}
+
+// wlanNotificationData is a wrapper of WLAN_NOTIFICATION_DATA
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/ns-wlanapi-wlan_notification_data
+type wlanNotificationData struct {
+	NotificationSource uint32
+	NotificationCode   uint32
+	InterfaceGuid      windows.GUID
+	dwDataSize         uint32
+	pData              uintptr
+}
+
+// Interface is a wireless network interface.
+type Interface struct {
+	GUID        windows.GUID
+	Description string
+	State       InterfaceState
+}
+
+// Connection describes the current connection of an interface.
+type Connection struct {
+	State       InterfaceState
+	ProfileName string
+	SSID        string
+	BSSID       net.HardwareAddr
+	// PhyType is a DOT11_PHY_TYPE.
+	PhyType uint32
+	// SignalQuality is a percentage, 0 corresponding to -100 dBm and 100 to
+	// -50 dBm.
+	SignalQuality uint32
+	RxRateKbps    uint32
+	TxRateKbps    uint32
+}
+
+// Notification is a notification about an interface.
+type Notification struct {
+	Source    uint32
+	Code      uint32
+	Interface windows.GUID
+}
+
+// Client is a session with the WLAN service.
+type Client struct {
+	handle windows.Handle
+}
+
+// Open opens a session with the WLAN service. It fails if the service isn't
+// running, e.g. on servers without the Wireless LAN Service feature.
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanopenhandle
+func Open() (*Client, error) {
+	var negotiated uint32
+	c := &Client{}
+	r1, _, _ := procWlanOpenHandle.Call(clientVersion, 0, uintptr(unsafe.Pointer(&negotiated)), uintptr(unsafe.Pointer(&c.handle)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	return c, nil
+}
+
+// Close closes the session, unregistering notifications.
+func (c *Client) Close() error {
+	r1, _, _ := procWlanCloseHandle.Call(uintptr(c.handle), 0)
+	notificationHandlers.Delete(c.handle)
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	return nil
+}
+
+// Interfaces returns the wireless network interfaces.
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanenuminterfaces
+func (c *Client) Interfaces() ([]Interface, error) {
+	var list *wlanInterfaceInfoList
+	r1, _, _ := procWlanEnumInterfaces.Call(uintptr(c.handle), 0, uintptr(unsafe.Pointer(&list)))
+	if r1 != 0 {
+		return nil, windows.Errno(r1)
+	}
+	defer procWlanFreeMemory.Call(uintptr(unsafe.Pointer(list)))
+
+	interfaces := make([]Interface, 0, list.dwNumberOfItems)
+	for _, info := range list.InterfaceInfo[:list.dwNumberOfItems:list.dwNumberOfItems] {
+		interfaces = append(interfaces, Interface{
+			GUID:        info.InterfaceGuid,
+			Description: windows.UTF16ToString(info.strInterfaceDescription[:]),
+			State:       info.isState,
+		})
+	}
+	return interfaces, nil
+}
+
+// query calls WlanQueryInterface, passing the returned buffer to fn before
+// freeing it.
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanqueryinterface
+func (c *Client) query(guid windows.GUID, opcode uint32, fn func(data unsafe.Pointer)) error {
+	var size uint32
+	var data unsafe.Pointer
+	r1, _, _ := procWlanQueryInterface.Call(
+		uintptr(c.handle),
+		uintptr(unsafe.Pointer(&guid)),
+		uintptr(opcode),
+		0,
+		uintptr(unsafe.Pointer(&size)),
+		uintptr(unsafe.Pointer(&data)),
+		0,
+	)
+	if r1 != 0 {
+		return windows.Errno(r1)
+	}
+	defer procWlanFreeMemory.Call(uintptr(data))
+	fn(data)
+	return nil
+}
+
+// CurrentConnection returns the current connection of the interface. It
+// fails with ERROR_INVALID_STATE if the interface isn't connected.
+func (c *Client) CurrentConnection(guid windows.GUID) (Connection, error) {
+	var conn Connection
+	err := c.query(guid, opcodeCurrentConnection, func(data unsafe.Pointer) {
+		attrs := (*wlanConnectionAttributes)(data)
+		ssidLength := attrs.uSSIDLength
+		if ssidLength > uint32(len(attrs.ucSSID)) {
+			ssidLength = uint32(len(attrs.ucSSID))
+		}
+		conn = Connection{
+			State:         attrs.isState,
+			ProfileName:   windows.UTF16ToString(attrs.strProfileName[:]),
+			SSID:          string(attrs.ucSSID[:ssidLength]),
+			BSSID:         net.HardwareAddr(append([]byte{}, attrs.dot11Bssid[:]...)),
+			PhyType:       attrs.dot11PhyType,
+			SignalQuality: attrs.wlanSignalQuality,
+			RxRateKbps:    attrs.ulRxRate,
+			TxRateKbps:    attrs.ulTxRate,
+		}
+	})
+	return conn, err
+}
+
+// Channel returns the channel the interface is connected on.
+func (c *Client) Channel(guid windows.GUID) (uint32, error) {
+	var channel uint32
+	err := c.query(guid, opcodeChannelNumber, func(data unsafe.Pointer) {
+		channel = *(*uint32)(data)
+	})
+	return channel, err
+}
+
+// RSSI returns the received signal strength of the connection in dBm.
+func (c *Client) RSSI(guid windows.GUID) (int32, error) {
+	var rssi int32
+	err := c.query(guid, opcodeRSSI, func(data unsafe.Pointer) {
+		rssi = *(*int32)(data)
+	})
+	return rssi, err
+}
+
+var (
+	// Callbacks can't be released, so a single one dispatches the
+	// notifications of all clients.
+	notificationCallback     uintptr
+	notificationCallbackOnce sync.Once
+	notificationHandlers     sync.Map // windows.Handle -> func(Notification)
+)
+
+func dispatchNotification(data *wlanNotificationData, context uintptr) uintptr {
+	if handler, ok := notificationHandlers.Load(windows.Handle(context)); ok {
+		handler.(func(Notification))(Notification{
+			Source:    data.NotificationSource,
+			Code:      data.NotificationCode,
+			Interface: data.InterfaceGuid,
+		})
+	}
+	return 0
+}
+
+// RegisterNotification calls fn for every notification of the given sources
+// until the client is closed. fn is called from a thread of the WLAN service
+// and must not block.
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanregisternotification
+func (c *Client) RegisterNotification(sources uint32, fn func(Notification)) error {
+	notificationCallbackOnce.Do(func() {
+		notificationCallback = windows.NewCallback(dispatchNotification)
+	})
+	notificationHandlers.Store(c.handle, fn)
+	r1, _, _ := procWlanRegisterNotification.Call(
+		uintptr(c.handle),
+		uintptr(sources),
+		1, // ignore duplicates
+		notificationCallback,
+		uintptr(c.handle),
+		0,
+		0,
+	)
+	if r1 != 0 {
+		notificationHandlers.Delete(c.handle)
+		return windows.Errno(r1)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package wpcap wraps the packet capture API of Npcap, which isn't part of
+// Windows and has to be installed separately.
+// https://npcap.com/guide/wpcap/pcap.html
+package wpcap
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	errbufSize = 256
+
+	loadWithAlteredSearchPath = 0x00000008
+)
+
+var (
+	// Npcap installs wpcap.dll into System32\Npcap, which isn't on the DLL
+	// search path unless installed in WinPcap compatible mode.
+	wpcapPath = npcapPath()
+	wpcap     = windows.NewLazyDLL(wpcapPath)
+	loadOnce  sync.Once
+	loadErr   error
+
+	procPcapFindAllDevs = wpcap.NewProc("pcap_findalldevs")
+	procPcapFreeAllDevs = wpcap.NewProc("pcap_freealldevs")
+	procPcapOpenLive    = wpcap.NewProc("pcap_open_live")
+	procPcapCompile     = wpcap.NewProc("pcap_compile")
+	procPcapSetFilter   = wpcap.NewProc("pcap_setfilter")
+	procPcapFreeCode    = wpcap.NewProc("pcap_freecode")
+	procPcapNextEx      = wpcap.NewProc("pcap_next_ex")
+	procPcapGetErr      = wpcap.NewProc("pcap_geterr")
+	procPcapClose       = wpcap.NewProc("pcap_close")
+)
+
+// pcapIf is a wrapper of pcap_if_t
+type pcapIf struct {
+	next        *pcapIf
+	name        *byte
+	description *byte
+	addresses   uintptr
+	flags       uint32
+}
+
+// pcapPkthdr is a wrapper of struct pcap_pkthdr. On Windows, the members
+// of struct timeval are 32 bit wide on all architectures.
+type pcapPkthdr struct {
+	tvSec  int32
+	tvUsec int32
+	caplen uint32
+	len    uint32
+}
+
+// bpfProgram is a wrapper of struct bpf_program
+type bpfProgram struct {
+	bfLen   uint32
+	bfInsns uintptr
+}
+
+// Device is a network interface packets can be captured on.
+type Device struct {
+	Name        string
+	Description string
+}
+
+// Handle is an open capture.
+type Handle struct {
+	p uintptr
+}
+
+// ErrTimeout is returned by Next if no packet arrived within the read timeout.
+var ErrTimeout = errors.New("read timeout")
+
+func npcapPath() string {
+	dir, err := windows.GetSystemDirectory()
+	if err != nil {
+		return "wpcap.dll"
+	}
+	return filepath.Join(dir, "Npcap", "wpcap.dll")
+}
+
+// Load loads wpcap.dll, and must be called before any other function. It
+// returns an error if Npcap isn't installed.
+func Load() error {
+	loadOnce.Do(func() {
+		// wpcap.dll depends on Packet.dll next to it, which is only found if
+		// the DLL's own directory is searched.
+		if _, err := windows.LoadLibraryEx(wpcapPath, 0, loadWithAlteredSearchPath); err != nil {
+			loadErr = fmt.Errorf("failed to load %s, is Npcap installed? %v", wpcapPath, err)
+			return
+		}
+		loadErr = wpcap.Load()
+	})
+	return loadErr
+}
+
+// goString converts a NUL terminated C string.
+func goString(p *byte) string {
+	if p == nil {
+		return ""
+	}
+	buf := (*[1 << 20]byte)(unsafe.Pointer(p))
+	n := 0
+	for buf[n] != 0 {
+		n++
+	}
+	return string(buf[:n:n])
+}
+
+// FindAllDevs returns the devices that can be opened with OpenLive.
+func FindAllDevs() ([]Device, error) {
+	var devs *pcapIf
+	errbuf := make([]byte, errbufSize)
+	r1, _, _ := procPcapFindAllDevs.Call(uintptr(unsafe.Pointer(&devs)), uintptr(unsafe.Pointer(&errbuf[0])))
+	if int32(r1) != 0 {
+		return nil, errors.New(goString(&errbuf[0]))
+	}
+	defer procPcapFreeAllDevs.Call(uintptr(unsafe.Pointer(devs)))
+
+	var result []Device
+	for d := devs; d != nil; d = d.next {
+		dev := Device{Name: goString(d.name)}
+		if d.description != nil {
+			dev.Description = goString(d.description)
+		}
+		result = append(result, dev)
+	}
+	return result, nil
+}
+
+// OpenLive opens a capture on the device. Next returns ErrTimeout after
+// timeoutMs milliseconds without packets.
+func OpenLive(device string, snaplen int, promisc bool, timeoutMs int) (*Handle, error) {
+	name, err := windows.BytePtrFromString(device)
+	if err != nil {
+		return nil, err
+	}
+	var promiscFlag uintptr
+	if promisc {
+		promiscFlag = 1
+	}
+	errbuf := make([]byte, errbufSize)
+	r1, _, _ := procPcapOpenLive.Call(
+		uintptr(unsafe.Pointer(name)),
+		uintptr(snaplen),
+		promiscFlag,
+		uintptr(timeoutMs),
+		uintptr(unsafe.Pointer(&errbuf[0])),
+	)
+	if r1 == 0 {
+		return nil, errors.New(goString(&errbuf[0]))
+	}
+	return &Handle{p: r1}, nil
+}
+
+func (h *Handle) lastError() error {
+	r1, _, _ := procPcapGetErr.Call(h.p)
+	// pcap_geterr returns a pointer into the capture's own buffer.
+	return errors.New(goString(*(**byte)(unsafe.Pointer(&r1))))
+}
+
+// SetFilter only lets packets matching the BPF expression through.
+func (h *Handle) SetFilter(expr string) error {
+	filter, err := windows.BytePtrFromString(expr)
+	if err != nil {
+		return err
+	}
+	var program bpfProgram
+	// PCAP_NETMASK_UNKNOWN
+	r1, _, _ := procPcapCompile.Call(h.p, uintptr(unsafe.Pointer(&program)), uintptr(unsafe.Pointer(filter)), 1, 0xffffffff)
+	if int32(r1) != 0 {
+		return fmt.Errorf("invalid filter %q: %v", expr, h.lastError())
+	}
+	defer procPcapFreeCode.Call(uintptr(unsafe.Pointer(&program)))
+	r1, _, _ = procPcapSetFilter.Call(h.p, uintptr(unsafe.Pointer(&program)))
+	if int32(r1) != 0 {
+		return h.lastError()
+	}
+	return nil
+}
+
+// Next returns a copy of the next packet.
+func (h *Handle) Next() ([]byte, error) {
+	var header *pcapPkthdr
+	var data *byte
+	r1, _, _ := procPcapNextEx.Call(h.p, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data)))
+	switch int32(r1) {
+	case 1:
+		packet := make([]byte, header.caplen)
+		copy(packet, (*[1 << 20]byte)(unsafe.Pointer(data))[:header.caplen:header.caplen])
+		return packet, nil
+	case 0:
+		return nil, ErrTimeout
+	default:
+		return nil, h.lastError()
+	}
+}
+
+// Close closes the capture.
+func (h *Handle) Close() {
+	procPcapClose.Call(h.p)
+}
@@ -0,0 +1,80 @@
+package wtsapi32
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	wtsCurrentServerHandle = 0
+
+	// WTS_INFO_CLASS
+	wtsUserName   = 5
+	wtsDomainName = 7
+)
+
+var (
+	wtsapi32                        = windows.NewLazySystemDLL("wtsapi32.dll")
+	procWTSQuerySessionInformationW = wtsapi32.NewProc("WTSQuerySessionInformationW")
+)
+
+// Session is a Terminal Services session on the local server.
+type Session struct {
+	ID uint32
+	// StationName is the name of the WinStation, e.g. Console or RDP-Tcp#3.
+	// It's empty for disconnected sessions.
+	StationName string
+	// User is the account logged on to the session as DOMAIN\user, or empty
+	// if no one is logged on.
+	User string
+}
+
+// ListSessions returns the sessions on the local server.
+// https://docs.microsoft.com/en-us/windows/win32/api/wtsapi32/nf-wtsapi32-wtsenumeratesessionsw
+func ListSessions() ([]Session, error) {
+	var info *windows.WTS_SESSION_INFO
+	var count uint32
+	if err := windows.WTSEnumerateSessions(wtsCurrentServerHandle, 0, 1, &info, &count); err != nil {
+		return nil, err
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(info)))
+
+	sessions := make([]Session, 0, count)
+	for _, s := range (*[1 << 16]windows.WTS_SESSION_INFO)(unsafe.Pointer(info))[:count:count] {
+		session := Session{
+			ID:          s.SessionID,
+			StationName: windows.UTF16PtrToString(s.WindowStationName),
+		}
+		// The user may log off in the meantime, leaving it empty.
+		user, _ := querySessionString(s.SessionID, wtsUserName)
+		if user != "" {
+			domain, _ := querySessionString(s.SessionID, wtsDomainName)
+			if domain != "" {
+				user = domain + `\` + user
+			}
+		}
+		session.User = user
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// querySessionString returns a string information about the session.
+// https://docs.microsoft.com/en-us/windows/win32/api/wtsapi32/nf-wtsapi32-wtsquerysessioninformationw
+func querySessionString(id uint32, infoClass uint32) (string, error) {
+	var buf *uint16
+	var size uint32
+	r1, _, err := procWTSQuerySessionInformationW.Call(
+		wtsCurrentServerHandle,
+		uintptr(id),
+		uintptr(infoClass),
+		uintptr(unsafe.Pointer(&buf)),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if r1 == 0 {
+		return "", err
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(buf)))
+	return windows.UTF16PtrToString(buf), nil
+}
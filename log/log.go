@@ -51,10 +51,26 @@ func (s *loggerSettings) apply(ctx *kingpin.ParseContext) error {
 	if err != nil {
 		return err
 	}
+	collectors := make(map[string]logrus.Level)
+	for collector, level := range collectorLevelFlags {
+		if *level == "" {
+			continue
+		}
+		lvl, err := logrus.ParseLevel(*level)
+		if err != nil {
+			return err
+		}
+		collectors[collector] = lvl
+	}
+	baseLogger.setCollectorLevels(collectors)
 	err = baseLogger.SetFormat(s.format)
 	return err
 }
 
+// collectorLevelFlags holds the values of the flags added by
+// AddCollectorLevelFlag, by collector.
+var collectorLevelFlags = make(map[string]*string)
+
 // AddFlags adds the flags used by this package to the Kingpin application.
 // To use the default Kingpin application, call AddFlags(kingpin.CommandLine)
 func AddFlags(a *kingpin.Application) {
@@ -63,12 +79,20 @@ func AddFlags(a *kingpin.Application) {
 		Default(origLogger.Level.String()).
 		StringVar(&s.level)
 	defaultFormat := url.URL{Scheme: "logger", Opaque: "stderr"}
-	a.Flag("log.format", `Set the log target and format. Example: "logger:syslog?appname=bob&local=7" or "logger:stdout?json=true"`).
+	a.Flag("log.format", `Set the log target and format. Example: "logger:syslog?appname=bob&local=7", "logger:stdout?json=true" or "json" for JSON to stderr`).
 		Default(defaultFormat.String()).
 		StringVar(&s.format)
 	a.Action(s.apply)
 }
 
+// AddCollectorLevelFlag adds a flag to the Kingpin application overriding the
+// level of the messages logged with a collector field of the given value, see
+// With, so that a single collector can be debugged without debugging
+// everything else. The flag is applied along with those added by AddFlags.
+func AddCollectorLevelFlag(a *kingpin.Application, name, help, collector string) {
+	collectorLevelFlags[collector] = a.Flag(name, help).Default("").String()
+}
+
 // Logger is the interface for loggers used in the Prometheus components.
 type Logger interface {
 	Debug(...interface{})
@@ -99,85 +123,121 @@ type Logger interface {
 
 type logger struct {
 	entry *logrus.Entry
+	// levels is nil if the level can't be overridden by collector.
+	levels *collectorLevels
+	// collector is the value of the collector field, if any.
+	collector string
+}
+
+// collectorLevels holds the level of a logger and the levels overriding it for
+// the messages of some collectors. The level of the logrus logger is the most
+// verbose of them, the messages of the other collectors are filtered by
+// sourced.
+type collectorLevels struct {
+	level      logrus.Level
+	collectors map[string]logrus.Level
+}
+
+func (c *collectorLevels) enabled(collector string, level logrus.Level) bool {
+	if l, ok := c.collectors[collector]; ok && collector != "" {
+		return level <= l
+	}
+	return level <= c.level
 }
 
+func (c *collectorLevels) max() logrus.Level {
+	max := c.level
+	for _, l := range c.collectors {
+		if l > max {
+			max = l
+		}
+	}
+	return max
+}
+
+// With adds a field to the logger. The messages of a logger with a collector
+// field are logged at the level set for the collector, if any.
 func (l logger) With(key string, value interface{}) Logger {
-	return logger{l.entry.WithField(key, value)}
+	collector := l.collector
+	if key == "collector" {
+		collector = fmt.Sprint(value)
+	}
+	return logger{l.entry.WithField(key, value), l.levels, collector}
 }
 
 // Debug logs a message at level Debug on the standard logger.
 func (l logger) Debug(args ...interface{}) {
-	l.sourced().Debug(args...)
+	l.sourced(logrus.DebugLevel).Debug(args...)
 }
 
 // Debug logs a message at level Debug on the standard logger.
 func (l logger) Debugln(args ...interface{}) {
-	l.sourced().Debugln(args...)
+	l.sourced(logrus.DebugLevel).Debugln(args...)
 }
 
 // Debugf logs a message at level Debug on the standard logger.
 func (l logger) Debugf(format string, args ...interface{}) {
-	l.sourced().Debugf(format, args...)
+	l.sourced(logrus.DebugLevel).Debugf(format, args...)
 }
 
 // Info logs a message at level Info on the standard logger.
 func (l logger) Info(args ...interface{}) {
-	l.sourced().Info(args...)
+	l.sourced(logrus.InfoLevel).Info(args...)
 }
 
 // Info logs a message at level Info on the standard logger.
 func (l logger) Infoln(args ...interface{}) {
-	l.sourced().Infoln(args...)
+	l.sourced(logrus.InfoLevel).Infoln(args...)
 }
 
 // Infof logs a message at level Info on the standard logger.
 func (l logger) Infof(format string, args ...interface{}) {
-	l.sourced().Infof(format, args...)
+	l.sourced(logrus.InfoLevel).Infof(format, args...)
 }
 
 // Warn logs a message at level Warn on the standard logger.
 func (l logger) Warn(args ...interface{}) {
-	l.sourced().Warn(args...)
+	l.sourced(logrus.WarnLevel).Warn(args...)
 }
 
 // Warn logs a message at level Warn on the standard logger.
 func (l logger) Warnln(args ...interface{}) {
-	l.sourced().Warnln(args...)
+	l.sourced(logrus.WarnLevel).Warnln(args...)
 }
 
 // Warnf logs a message at level Warn on the standard logger.
 func (l logger) Warnf(format string, args ...interface{}) {
-	l.sourced().Warnf(format, args...)
+	l.sourced(logrus.WarnLevel).Warnf(format, args...)
 }
 
 // Error logs a message at level Error on the standard logger.
 func (l logger) Error(args ...interface{}) {
-	l.sourced().Error(args...)
+	l.sourced(logrus.ErrorLevel).Error(args...)
 }
 
 // Error logs a message at level Error on the standard logger.
 func (l logger) Errorln(args ...interface{}) {
-	l.sourced().Errorln(args...)
+	l.sourced(logrus.ErrorLevel).Errorln(args...)
 }
 
 // Errorf logs a message at level Error on the standard logger.
 func (l logger) Errorf(format string, args ...interface{}) {
-	l.sourced().Errorf(format, args...)
+	l.sourced(logrus.ErrorLevel).Errorf(format, args...)
 }
 
 // Fatal logs a message at level Fatal on the standard logger.
 func (l logger) Fatal(args ...interface{}) {
-	l.sourced().Fatal(args...)
+	l.sourced(logrus.FatalLevel).Fatal(args...)
 }
 
 // Fatal logs a message at level Fatal on the standard logger.
 func (l logger) Fatalln(args ...interface{}) {
-	l.sourced().Fatalln(args...)
+	l.sourced(logrus.FatalLevel).Fatalln(args...)
 }
 
 // Fatalf logs a message at level Fatal on the standard logger.
 func (l logger) Fatalf(format string, args ...interface{}) {
-	l.sourced().Fatalf(format, args...)
+	l.sourced(logrus.FatalLevel).Fatalf(format, args...)
 }
 
 func (l logger) SetLevel(level string) error {
@@ -187,14 +247,26 @@ func (l logger) SetLevel(level string) error {
 	}
 
 	l.entry.Logger.Level = lvl
+	if l.levels != nil {
+		l.levels.level = lvl
+		l.entry.Logger.Level = l.levels.max()
+	}
 	return nil
 }
 
+func (l logger) setCollectorLevels(collectors map[string]logrus.Level) {
+	l.levels.collectors = collectors
+	l.entry.Logger.Level = l.levels.max()
+}
+
 func (l logger) SetFormat(format string) error {
 	u, err := url.Parse(format)
 	if err != nil {
 		return err
 	}
+	if format == "json" {
+		u = &url.URL{Scheme: "logger", Opaque: "stderr", RawQuery: "json=true"}
+	}
 	if u.Scheme != "logger" {
 		return fmt.Errorf("invalid scheme %s", u.Scheme)
 	}
@@ -233,8 +305,9 @@ func (l logger) SetFormat(format string) error {
 }
 
 // sourced adds a source field to the logger that contains
-// the file name and line where the logging happened.
-func (l logger) sourced() *logrus.Entry {
+// the file name and line where the logging happened. Messages at a level
+// not enabled for the collector of the logger are discarded.
+func (l logger) sourced(level logrus.Level) *logrus.Entry {
 	_, file, line, ok := runtime.Caller(2)
 	if !ok {
 		file = "<???>"
@@ -243,11 +316,24 @@ func (l logger) sourced() *logrus.Entry {
 		slash := strings.LastIndex(file, "/")
 		file = file[slash+1:]
 	}
+	if l.levels != nil && !l.levels.enabled(l.collector, level) {
+		return discardEntry
+	}
 	return l.entry.WithField("source", fmt.Sprintf("%s:%d", file, line))
 }
 
 var origLogger = logrus.New()
-var baseLogger = logger{entry: logrus.NewEntry(origLogger)}
+var baseLogger = logger{entry: logrus.NewEntry(origLogger), levels: &collectorLevels{level: origLogger.Level}}
+
+// discardEntry discards the messages filtered by sourced. Fatal messages
+// still exit.
+var discardEntry = logrus.NewEntry(&logrus.Logger{
+	Out:       ioutil.Discard,
+	Formatter: new(logrus.TextFormatter),
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.PanicLevel,
+	ExitFunc:  os.Exit,
+})
 
 // Base returns the default Logger logging to
 func Base() Logger {
@@ -268,84 +354,84 @@ func NewNopLogger() Logger {
 	return logger{entry: logrus.NewEntry(l)}
 }
 
-// With adds a field to the logger.
+// With adds a field to the standard logger, see Logger.With.
 func With(key string, value interface{}) Logger {
 	return baseLogger.With(key, value)
 }
 
 // Debug logs a message at level Debug on the standard logger.
 func Debug(args ...interface{}) {
-	baseLogger.sourced().Debug(args...)
+	baseLogger.sourced(logrus.DebugLevel).Debug(args...)
 }
 
 // Debugln logs a message at level Debug on the standard logger.
 func Debugln(args ...interface{}) {
-	baseLogger.sourced().Debugln(args...)
+	baseLogger.sourced(logrus.DebugLevel).Debugln(args...)
 }
 
 // Debugf logs a message at level Debug on the standard logger.
 func Debugf(format string, args ...interface{}) {
-	baseLogger.sourced().Debugf(format, args...)
+	baseLogger.sourced(logrus.DebugLevel).Debugf(format, args...)
 }
 
 // Info logs a message at level Info on the standard logger.
 func Info(args ...interface{}) {
-	baseLogger.sourced().Info(args...)
+	baseLogger.sourced(logrus.InfoLevel).Info(args...)
 }
 
 // Infoln logs a message at level Info on the standard logger.
 func Infoln(args ...interface{}) {
-	baseLogger.sourced().Infoln(args...)
+	baseLogger.sourced(logrus.InfoLevel).Infoln(args...)
 }
 
 // Infof logs a message at level Info on the standard logger.
 func Infof(format string, args ...interface{}) {
-	baseLogger.sourced().Infof(format, args...)
+	baseLogger.sourced(logrus.InfoLevel).Infof(format, args...)
 }
 
 // Warn logs a message at level Warn on the standard logger.
 func Warn(args ...interface{}) {
-	baseLogger.sourced().Warn(args...)
+	baseLogger.sourced(logrus.WarnLevel).Warn(args...)
 }
 
 // Warnln logs a message at level Warn on the standard logger.
 func Warnln(args ...interface{}) {
-	baseLogger.sourced().Warnln(args...)
+	baseLogger.sourced(logrus.WarnLevel).Warnln(args...)
 }
 
 // Warnf logs a message at level Warn on the standard logger.
 func Warnf(format string, args ...interface{}) {
-	baseLogger.sourced().Warnf(format, args...)
+	baseLogger.sourced(logrus.WarnLevel).Warnf(format, args...)
 }
 
 // Error logs a message at level Error on the standard logger.
 func Error(args ...interface{}) {
-	baseLogger.sourced().Error(args...)
+	baseLogger.sourced(logrus.ErrorLevel).Error(args...)
 }
 
 // Errorln logs a message at level Error on the standard logger.
 func Errorln(args ...interface{}) {
-	baseLogger.sourced().Errorln(args...)
+	baseLogger.sourced(logrus.ErrorLevel).Errorln(args...)
 }
 
 // Errorf logs a message at level Error on the standard logger.
 func Errorf(format string, args ...interface{}) {
-	baseLogger.sourced().Errorf(format, args...)
+	baseLogger.sourced(logrus.ErrorLevel).Errorf(format, args...)
 }
 
 // Fatal logs a message at level Fatal on the standard logger.
 func Fatal(args ...interface{}) {
-	baseLogger.sourced().Fatal(args...)
+	baseLogger.sourced(logrus.FatalLevel).Fatal(args...)
 }
 
 // Fatalln logs a message at level Fatal on the standard logger.
 func Fatalln(args ...interface{}) {
-	baseLogger.sourced().Fatalln(args...)
+	baseLogger.sourced(logrus.FatalLevel).Fatalln(args...)
 }
 
 // Fatalf logs a message at level Fatal on the standard logger.
 func Fatalf(format string, args ...interface{}) {
-	baseLogger.sourced().Fatalf(format, args...)
+	baseLogger.sourced(logrus.FatalLevel).Fatalf(format, args...)
 }
 
 // AddHook adds hook to Prometheus' original logger.
@@ -356,7 +442,7 @@ func AddHook(hook logrus.Hook) {
 type errorLogWriter struct{}
 
 func (errorLogWriter) Write(b []byte) (int, error) {
-	baseLogger.sourced().Error(string(b))
+	baseLogger.sourced(logrus.ErrorLevel).Error(string(b))
 	return len(b), nil
 }
 
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCollectorLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.Out = &buf
+	lg := logger{entry: logrus.NewEntry(l), levels: &collectorLevels{}}
+	if err := lg.SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	lg.setCollectorLevels(map[string]logrus.Level{"defrag": logrus.DebugLevel, "cpu": logrus.ErrorLevel})
+
+	if l.Level != logrus.DebugLevel {
+		t.Errorf("Logrus level does not match!\nExpected result: %v\nActual result: %v", logrus.DebugLevel, l.Level)
+	}
+	lg.With("collector", "defrag").With("volume", "C:").Debug("debug message")
+	if !strings.Contains(buf.String(), "debug message") || !strings.Contains(buf.String(), "collector=defrag") {
+		t.Errorf("Debug message of overridden collector not logged: %q", buf.String())
+	}
+	buf.Reset()
+	lg.Debug("other debug message")
+	lg.With("collector", "cpu").Warn("cpu warning")
+	if buf.Len() != 0 {
+		t.Errorf("Expected the messages below the levels to be discarded, got %q", buf.String())
+	}
+
+	cases := []struct {
+		collector string
+		level     logrus.Level
+		enabled   bool
+	}{
+		{"cpu", logrus.WarnLevel, false},
+		{"cpu", logrus.ErrorLevel, true},
+		{"", logrus.InfoLevel, false},
+		{"", logrus.WarnLevel, true},
+		{"memory", logrus.InfoLevel, false},
+		{"memory", logrus.WarnLevel, true},
+	}
+	for _, c := range cases {
+		if enabled := lg.levels.enabled(c.collector, c.level); enabled != c.enabled {
+			t.Errorf("Level %v of collector %q enabled does not match!\nExpected result: %t\nActual result: %t", c.level, c.collector, c.enabled, enabled)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// +build windows
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/sysinfoapi"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// createdSeriesExpiry is how long a counter that is no longer exposed is
+// remembered, so that its created timestamp survives short gaps.
+const createdSeriesExpiry = time.Hour
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// createdTracker derives the created timestamps of counters. Windows counters
+// don't carry one, so a counter is assumed to have started counting at system
+// boot, and to have been recreated whenever its value is seen going down,
+// e.g. after a service restart.
+type createdTracker struct {
+	mu     sync.Mutex
+	boot   time.Time
+	series map[string]*createdSeries
+}
+
+type createdSeries struct {
+	value    float64
+	created  time.Time
+	lastSeen time.Time
+}
+
+func newCreatedTracker() *createdTracker {
+	uptime := time.Duration(sysinfoapi.GetTickCount64()) * time.Millisecond
+	return &createdTracker{
+		boot:   time.Now().Add(-uptime),
+		series: make(map[string]*createdSeries),
+	}
+}
+
+// observe records the current value of a counter and returns its created timestamp.
+func (t *createdTracker) observe(name string, m *dto.Metric, now time.Time) time.Time {
+	key := name
+	for _, lp := range m.GetLabel() {
+		key += "\xff" + lp.GetName() + "\xff" + lp.GetValue()
+	}
+	value := m.GetCounter().GetValue()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.series[key]
+	switch {
+	case !ok:
+		s = &createdSeries{created: t.boot}
+		t.series[key] = s
+	case value < s.value:
+		// The reset happened some time between the previous observation and
+		// now. Pick a point strictly in between, so that the created timestamp
+		// precedes the current sample.
+		s.created = s.lastSeen.Add(now.Sub(s.lastSeen) / 2)
+	}
+	s.value = value
+	s.lastSeen = now
+	return s.created
+}
+
+// expire forgets counters that have not been observed since before the given time.
+func (t *createdTracker) expire(before time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, s := range t.series {
+		if s.lastSeen.Before(before) {
+			delete(t.series, key)
+		}
+	}
+}
+
+// openMetricsHandler serves the metrics of g. If the client accepts it, the
+// OpenMetrics format is used and every counter is followed by its _created
+// sample. Otherwise the request is handled like promhttp would.
+func openMetricsHandler(g prometheus.Gatherer, tracker *createdTracker) http.Handler {
+	fallback := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format != expfmt.FmtOpenMetrics {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		mfs, err := g.Gather()
+		if err != nil {
+			log.Errorf("error gathering metrics: %v", err)
+			http.Error(w, fmt.Sprintf("An error has occurred while gathering metrics:\n\n%s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		var out io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+
+		now := time.Now()
+		for _, mf := range mfs {
+			if err := writeOpenMetricsFamily(out, mf, tracker, now); err != nil {
+				log.Errorf("error encoding metric family %s: %v", mf.GetName(), err)
+				return
+			}
+		}
+		if _, err := expfmt.FinalizeOpenMetrics(out); err != nil {
+			log.Errorf("error finalizing OpenMetrics output: %v", err)
+		}
+		tracker.expire(now.Add(-createdSeriesExpiry))
+	})
+}
+
+// writeOpenMetricsFamily encodes mf in the OpenMetrics format. The encoder of
+// expfmt doesn't support _created samples, so for counters they are inserted
+// after the single line each metric is encoded to.
+func writeOpenMetricsFamily(w io.Writer, mf *dto.MetricFamily, tracker *createdTracker, now time.Time) error {
+	name := mf.GetName()
+	if mf.GetType() != dto.MetricType_COUNTER || !strings.HasSuffix(name, "_total") {
+		_, err := expfmt.MetricFamilyToOpenMetrics(w, mf)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, mf); err != nil {
+		return err
+	}
+	shortName := strings.TrimSuffix(name, "_total")
+	i := 0
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+		if line == "" || strings.HasPrefix(line, "#") || i >= len(mf.Metric) {
+			continue
+		}
+		m := mf.Metric[i]
+		i++
+		created := tracker.observe(name, m, now)
+		_, err := fmt.Fprintf(w, "%s_created%s %s\n",
+			shortName,
+			labelString(m.GetLabel()),
+			strconv.FormatFloat(float64(created.UnixNano())/1e9, 'f', -1, 64),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labelString(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, lp := range labels {
+		pairs = append(pairs, lp.GetName()+`="`+labelValueEscaper.Replace(lp.GetValue())+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
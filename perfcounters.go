@@ -0,0 +1,143 @@
+// +build windows
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/headers/perflib"
+	"github.com/prometheus-community/windows_exporter/log"
+	"golang.org/x/sys/windows"
+)
+
+// GUIDs of the provider and counter set declared in installer/windows_exporter.man.
+var (
+	perfProviderGUID   = windows.GUID{Data1: 0x378422dc, Data2: 0xa0d4, Data3: 0x42a4, Data4: [8]byte{0xaa, 0x32, 0x30, 0x4a, 0x1b, 0x99, 0x2b, 0x7e}}
+	perfCounterSetGUID = windows.GUID{Data1: 0x1e780706, Data2: 0xcebc, Data3: 0x419a, Data4: [8]byte{0xab, 0xc0, 0xbc, 0xaf, 0x83, 0xf9, 0xff, 0x9c}}
+)
+
+// Counter IDs, as declared in the manifest.
+const (
+	perfCounterDuration    = 1
+	perfCounterLastSuccess = 2
+	perfCounterErrors      = 3
+	perfCounterTimeouts    = 4
+
+	perfCounterLargeRawcount = 0x00010100
+
+	// perfTotalInstance holds the figures of whole scrapes.
+	perfTotalInstance = "_Total"
+)
+
+// perfCounterStats publishes the outcome of scrapes through the
+// windows_exporter performance counter set, one instance per collector.
+type perfCounterStats struct {
+	mu        sync.Mutex
+	provider  *perflib.Provider
+	instances map[string]*perfCounterInstance
+	// nextID is the ID of the next instance created, as the IDs of deleted
+	// instances aren't reused.
+	nextID uint32
+}
+
+type perfCounterInstance struct {
+	instance *perflib.ProviderInstance
+	errors   uint64
+	timeouts uint64
+}
+
+func newPerfCounterStats(collectors []string) (*perfCounterStats, error) {
+	provider, err := perflib.StartProvider(perfProviderGUID)
+	if err != nil {
+		return nil, err
+	}
+	err = provider.SetCounterSetInfo(perfCounterSetGUID, perflib.CounterSetMultiInstances, []perflib.Counter{
+		{ID: perfCounterDuration, Type: perfCounterLargeRawcount},
+		{ID: perfCounterLastSuccess, Type: perfCounterLargeRawcount},
+		{ID: perfCounterErrors, Type: perfCounterLargeRawcount},
+		{ID: perfCounterTimeouts, Type: perfCounterLargeRawcount},
+	})
+	if err != nil {
+		_ = provider.Stop()
+		return nil, err
+	}
+
+	s := &perfCounterStats{
+		provider:  provider,
+		instances: make(map[string]*perfCounterInstance, len(collectors)+1),
+	}
+	if err := s.setCollectors(collectors); err != nil {
+		_ = provider.Stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+// setCollectors creates the instances of the collectors that have none yet,
+// and deletes those of the collectors no longer enabled, e.g. after a reload
+// of the configuration file. The counters of the other instances are kept.
+func (s *perfCounterStats) setCollectors(collectors []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := append([]string{perfTotalInstance}, collectors...)
+	sort.Strings(names[1:])
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+		if _, ok := s.instances[name]; ok {
+			continue
+		}
+		instance, err := s.provider.CreateInstance(perfCounterSetGUID, name, s.nextID)
+		if err != nil {
+			return err
+		}
+		s.nextID++
+		s.instances[name] = &perfCounterInstance{instance: instance}
+	}
+	for name, i := range s.instances {
+		if enabled[name] {
+			continue
+		}
+		if err := i.instance.Delete(); err != nil {
+			log.Debugf("Failed to delete performance counter instance %s: %v", name, err)
+		}
+		delete(s.instances, name)
+	}
+	return nil
+}
+
+// observe records the outcome of one collector, or of the whole scrape if
+// name is perfTotalInstance.
+func (s *perfCounterStats) observe(name string, outcome collectorOutcome, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.instances[name]
+	if !ok {
+		return
+	}
+	switch outcome {
+	case success:
+		s.set(i, perfCounterLastSuccess, uint64(time.Now().Unix()))
+	case failed:
+		i.errors++
+		s.set(i, perfCounterErrors, i.errors)
+	case pending:
+		i.timeouts++
+		s.set(i, perfCounterTimeouts, i.timeouts)
+	}
+	s.set(i, perfCounterDuration, uint64(duration.Milliseconds()))
+}
+
+func (s *perfCounterStats) set(i *perfCounterInstance, id uint32, value uint64) {
+	if err := i.instance.SetValue(id, value); err != nil {
+		log.Debugf("Failed to set performance counter %d: %v", id, err)
+	}
+}
+
+func (s *perfCounterStats) close() error {
+	return s.provider.Stop()
+}
@@ -0,0 +1,95 @@
+// +build windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/leoluk/perflib_exporter/perflib"
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/log"
+)
+
+// perfCounterInfo describes a counter of a performance object, named as in the
+// perflib struct tags of the collectors.
+type perfCounterInfo struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	// Type is "counter" for counters, "base" for the base of a fraction and
+	// "gauge" for the other counters.
+	Type string `json:"type"`
+}
+
+type perfObjectInfo struct {
+	Name      string            `json:"name"`
+	Help      string            `json:"help"`
+	Counters  []perfCounterInfo `json:"counters"`
+	Instances []string          `json:"instances"`
+}
+
+// perfObjectInfos describes the objects, sorted by name. Objects without
+// instances have an empty list of instances.
+func perfObjectInfos(objects []*perflib.PerfObject) []perfObjectInfo {
+	infos := make([]perfObjectInfo, 0, len(objects))
+	for _, obj := range objects {
+		info := perfObjectInfo{
+			Name:      obj.Name,
+			Help:      obj.HelpText,
+			Counters:  make([]perfCounterInfo, 0, len(obj.CounterDefs)),
+			Instances: []string{},
+		}
+		for _, def := range obj.CounterDefs {
+			typ := "gauge"
+			switch {
+			case def.IsBaseValue:
+				typ = "base"
+			case def.IsCounter:
+				typ = "counter"
+			}
+			info.Counters = append(info.Counters, perfCounterInfo{Name: def.Name, Help: def.HelpText, Type: typ})
+		}
+		for _, instance := range obj.Instances {
+			if instance.Name != "" {
+				info.Instances = append(info.Instances, instance.Name)
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// servePerfCounters writes the performance objects of the local machine with
+// their counters, instances and explain texts. The object query parameter,
+// which may be repeated, selects objects by their English name; by default,
+// all objects except the costly ones, e.g. Thread, are written.
+func servePerfCounters(w http.ResponseWriter, r *http.Request) {
+	query := "Global"
+	if names, ok := r.URL.Query()["object"]; ok {
+		indices := make([]string, 0, len(names))
+		for _, name := range names {
+			index := collector.MapCounterToIndex(name)
+			if index == "0" {
+				http.Error(w, fmt.Sprintf("unknown performance object %q", name), http.StatusNotFound)
+				return
+			}
+			indices = append(indices, index)
+		}
+		query = strings.Join(indices, " ")
+	}
+
+	objects, err := perflib.QueryPerformanceData(query)
+	if err != nil {
+		log.Errorf("Failed to read performance objects: %v", err)
+		http.Error(w, fmt.Sprintf("error reading performance objects: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(perfObjectInfos(objects)); err != nil {
+		log.Debugf("Failed to write to stream: %v", err)
+	}
+}
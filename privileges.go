@@ -0,0 +1,138 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/headers/advapi32"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/windows"
+)
+
+// defaultPrivileges are the privileges the exporter keeps by default:
+// bypassing traverse checking, creating global objects for the performance
+// counters of --telemetry.perf-counters, and profiling the system for the ETW
+// sessions of kernel providers. SeSecurityPrivilege, which also grants
+// managing the audit policy of objects, isn't kept; Event Log Readers can read
+// the Security event log without it.
+const defaultPrivileges = "SeChangeNotifyPrivilege,SeCreateGlobalPrivilege,SeSystemProfilePrivilege"
+
+// dropPrivileges removes the privileges of the exporter's process not listed
+// in the comma-separated keep, e.g. the many privileges of LocalSystem, so
+// they can't be abused through the exporter. keep is "all" to keep all.
+func dropPrivileges(keep string) error {
+	if keep == "all" {
+		return nil
+	}
+	names, err := parsePrivileges(keep)
+	if err != nil {
+		return err
+	}
+
+	token, err := openProcessToken(windows.TOKEN_QUERY | windows.TOKEN_ADJUST_PRIVILEGES)
+	if err != nil {
+		return err
+	}
+	defer token.Close()
+	removed, err := advapi32.RemovePrivileges(token, names)
+	if len(removed) > 0 {
+		log.Infof("Removed privileges: %s", strings.Join(removed, ", "))
+	}
+	return err
+}
+
+// parsePrivileges returns the privilege names of the comma-separated list,
+// failing for unknown privileges.
+func parsePrivileges(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		// Reject typos, which would drop the privilege otherwise.
+		if _, err := advapi32.LookupPrivilegeValue(name); err != nil {
+			return nil, fmt.Errorf("unknown privilege %s: %v", name, err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// openProcessToken opens the access token of the exporter's process. The
+// pseudo handle of windows.GetCurrentProcessToken needs Windows 8.
+func openProcessToken(access uint32) (windows.Token, error) {
+	var token windows.Token
+	err := windows.OpenProcessToken(windows.CurrentProcess(), access, &token)
+	return token, err
+}
+
+// logProcessAccount logs the account the exporter runs as, e.g. to confirm
+// it runs as a group Managed Service Account.
+func logProcessAccount() {
+	token, err := openProcessToken(windows.TOKEN_QUERY)
+	if err != nil {
+		log.Debugf("Couldn't open the token of the process: %v", err)
+		return
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		log.Debugf("Couldn't get the account of the process: %v", err)
+		return
+	}
+	account, domain, _, err := user.User.Sid.LookupAccount("")
+	if err != nil {
+		log.Debugf("Couldn't look up the account of the process: %v", err)
+		return
+	}
+	log.Infof("Running as %s\\%s", domain, account)
+}
+
+// privilegesCollector exposes the privileges the exporter's process holds.
+type privilegesCollector struct {
+	desc *prometheus.Desc
+}
+
+func newPrivilegesCollector() *privilegesCollector {
+	return &privilegesCollector{
+		desc: prometheus.NewDesc(
+			"windows_exporter_privileges_info",
+			"The privileges the exporter's process holds, and whether they're enabled",
+			[]string{"privilege", "enabled"},
+			nil,
+		),
+	}
+}
+
+func (c *privilegesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *privilegesCollector) Collect(ch chan<- prometheus.Metric) {
+	privileges, err := processPrivileges()
+	if err != nil {
+		log.Warnf("Couldn't read the privileges of the process: %v", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, p := range privileges {
+		enabled := "false"
+		if p.Enabled {
+			enabled = "true"
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, p.Name, enabled)
+	}
+}
+
+func processPrivileges() ([]advapi32.Privilege, error) {
+	token, err := openProcessToken(windows.TOKEN_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer token.Close()
+	return advapi32.TokenPrivileges(token)
+}
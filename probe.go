@@ -0,0 +1,131 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler serves the metrics of a single remote host on
+// /probe?target=<host>&collectors=<names>, like the multi-target exporters,
+// e.g. snmp_exporter. Only the hosts of remote_hosts can be probed, with the
+// collectors configured for them.
+type probeHandler struct {
+	metrics *metricsHandler
+	// hosts maps the lower case names of the remote hosts to them.
+	hosts map[string]*remoteHost
+}
+
+func newProbeHandler(metrics *metricsHandler, hosts []*remoteHost) *probeHandler {
+	p := &probeHandler{metrics: metrics, hosts: make(map[string]*remoteHost, len(hosts))}
+	for _, h := range hosts {
+		p.hosts[strings.ToLower(h.config.Host)] = h
+	}
+	return p
+}
+
+// probeError is an error of a probe request, answered with status.
+type probeError struct {
+	status int
+	err    error
+}
+
+func (e *probeError) Error() string {
+	return e.err.Error()
+}
+
+// target returns the remote host and the collectors a probe request asks for.
+// Without the collectors parameter, the collectors of the host but those
+// scraped on demand are run.
+func (p *probeHandler) target(r *http.Request) (*remoteHost, map[string]collector.Collector, error) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		return nil, nil, &probeError{http.StatusBadRequest, fmt.Errorf("target parameter is missing")}
+	}
+	h, ok := p.hosts[strings.ToLower(target)]
+	if !ok {
+		return nil, nil, &probeError{http.StatusForbidden, fmt.Errorf("target %s is not listed in remote_hosts", target)}
+	}
+
+	var requested []string
+	if v := r.URL.Query().Get("collectors"); v != "" {
+		requested = expandEnabledCollectors(v)
+		for _, name := range requested {
+			if !collector.SupportsRemote(name) {
+				return nil, nil, &probeError{http.StatusBadRequest, fmt.Errorf("collector %s doesn't support remote hosts", name)}
+			}
+			if _, ok := h.collectors[name]; !ok {
+				return nil, nil, &probeError{http.StatusBadRequest, fmt.Errorf("collector %s is not collected from %s", name, h.config.Host)}
+			}
+		}
+	}
+	if p.metrics.access != nil {
+		var err error
+		requested, err = p.metrics.access.restrict(r, requested, keys(h.collectors))
+		if err != nil {
+			return nil, nil, &probeError{http.StatusForbidden, err}
+		}
+	}
+
+	collectors := make(map[string]collector.Collector)
+	if len(requested) == 0 {
+		for name, c := range h.collectors {
+			if !p.metrics.onDemand[name] {
+				collectors[name] = c
+			}
+		}
+	}
+	for _, name := range requested {
+		collectors[name] = h.collectors[name]
+	}
+	if len(collectors) == 0 {
+		return nil, nil, &probeError{http.StatusBadRequest, fmt.Errorf("no collector to run against %s", h.config.Host)}
+	}
+	return h, collectors, nil
+}
+
+func (p *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, collectors, err := p.target(r)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*probeError); ok {
+			status = e.status
+		}
+		log.Warnf("Rejecting probe from %s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	names := keys(collectors)
+	sort.Strings(names)
+	log.Debugf("Probing %s with collectors %s", h.config.Host, strings.Join(names, ","))
+
+	timeout := p.metrics.scrapeTimeout(r)
+	// The local collectors aren't run, the factory only provides the
+	// settings shared with them.
+	err, wc := p.metrics.collectorFactory(timeout, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&windowsCollector{
+		collectors:        collectors,
+		maxScrapeDuration: timeout,
+		remote:            h,
+		sampleTimestamps:  wc.sampleTimestamps,
+		collectorTimeouts: wc.collectorTimeouts,
+		requestContext:    r.Context(),
+		workers:           wc.workers,
+	})
+	// The created timestamps of counters are derived from the boot time of
+	// the local machine, so they aren't added to the metrics of targets.
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,295 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus-community/windows_exporter/config"
+	"github.com/prometheus-community/windows_exporter/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// liveCollectors holds the enabled collectors. The map is never modified, but
+// replaced as a whole when the configuration file is reloaded.
+type liveCollectors struct {
+	mu         sync.RWMutex
+	collectors map[string]collector.Collector
+}
+
+func (l *liveCollectors) get() map[string]collector.Collector {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.collectors
+}
+
+func (l *liveCollectors) set(collectors map[string]collector.Collector) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.collectors = collectors
+}
+
+// configReloader applies changes of the configuration file to the running
+// exporter. Only the collectors whose flags changed are rebuilt; changes to
+// any other flag are reported as requiring a restart.
+type configReloader struct {
+	// mu serializes reloads, which are triggered both by the watcher and
+	// by /-/reload.
+	mu      sync.Mutex
+	file    string
+	app     *kingpin.Application
+	current *config.Resolver
+	// defaults holds the default values of all flags, before any were
+	// overridden by the configuration file.
+	defaults map[string][]string
+	// commandLine holds the flags given on the command line, which take
+	// precedence over the configuration file.
+	commandLine map[string]bool
+	enabled     *string
+	live        *liveCollectors
+	// perfStats, if set, gets an instance for every collector enabled by a
+	// reload.
+	perfStats *perfCounterStats
+
+	// The changes that failed to apply are retried by the next reload, even
+	// of an unchanged file: retryFlags holds the flags whose value was
+	// rejected or restored, retryCollectors the collectors that failed to
+	// build, and retryLabelValues is set if label_values were rejected.
+	retryFlags       map[string]bool
+	retryCollectors  map[string]bool
+	retryLabelValues bool
+}
+
+func newConfigReloader(file string, app *kingpin.Application, args []string, enabled *string, live *liveCollectors) *configReloader {
+	r := &configReloader{
+		file:        file,
+		app:         app,
+		defaults:    make(map[string][]string),
+		commandLine: make(map[string]bool),
+		enabled:     enabled,
+		live:        live,
+
+		retryFlags:      make(map[string]bool),
+		retryCollectors: make(map[string]bool),
+	}
+	for _, f := range app.Model().Flags {
+		r.defaults[f.Name] = f.Default
+	}
+	if ctx, err := app.ParseContext(args); err == nil {
+		for _, e := range ctx.Elements {
+			if f, ok := e.Clause.(*kingpin.FlagClause); ok {
+				r.commandLine[f.Model().Name] = true
+			}
+		}
+	}
+	return r
+}
+
+// reload applies the changes of updated. If any change fails to apply, the
+// others are still applied and an error listing the failures is returned.
+func (r *configReloader) reload(updated *config.Resolver) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		log.Errorf("Configuration reload: %s", msg)
+		errs = append(errs, msg)
+	}
+
+	if r.current.RemoteHostsChanged(updated) {
+		log.Warn("Configuration reload: changes to remote_hosts require a restart")
+	}
+	if r.current.EndpointsChanged(updated) {
+		log.Warn("Configuration reload: changes to endpoints require a restart")
+	}
+	if r.current.CollectorAccessChanged(updated) {
+		log.Warn("Configuration reload: changes to collector_access require a restart")
+	}
+	retryFlags, retryCollectors := r.retryFlags, r.retryCollectors
+	r.retryFlags, r.retryCollectors = make(map[string]bool), make(map[string]bool)
+	if r.current.LabelValuesChanged(updated) || r.retryLabelValues {
+		r.retryLabelValues = false
+		if err := collector.SetLabelValues(updated.LabelValues()); err != nil {
+			fail("keeping the previous label_values: %v", err)
+			r.retryLabelValues = true
+		} else {
+			log.Info("Configuration reload: applied label_values")
+		}
+	}
+
+	changedCollectors := make(map[string]bool)
+	for name := range retryCollectors {
+		changedCollectors[name] = true
+	}
+	// previous holds the values of the flags of each changed collector
+	// before the reload, restored if the collector fails to build.
+	previous := make(map[string]map[string]string)
+	enabledChanged := false
+	changedFlags := r.current.ChangedFlags(updated)
+	for name := range retryFlags {
+		changedFlags = append(changedFlags, name)
+	}
+	seen := make(map[string]bool)
+	for _, name := range changedFlags {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		f := r.app.GetFlag(name)
+		if f == nil {
+			continue
+		}
+		old, _ := r.current.Value(name)
+		value, ok := updated.Value(name)
+		secret := r.current.Secret(name) || updated.Secret(name)
+		if r.commandLine[name] {
+			log.Infof("Configuration reload: ignoring %s, it is set on the command line", name)
+			continue
+		}
+
+		var collectorName string
+		switch {
+		case name == "collectors.enabled":
+			enabledChanged = true
+		case strings.HasPrefix(name, "collector."):
+			collectorName = strings.SplitN(name, ".", 3)[1]
+		default:
+			log.Warnf("Configuration reload: change of %s from %s to %s requires a restart", name, logValue(old, secret), logValue(value, secret))
+			continue
+		}
+
+		if !ok {
+			value = strings.Join(r.defaults[name], ",")
+		}
+		prev := f.Model().Value.String()
+		if err := collector.SetFlag(f, value); err != nil {
+			fail("invalid value %s for %s: %v", logValue(value, secret), name, err)
+			r.retryFlags[name] = true
+			continue
+		}
+		if collectorName != "" {
+			changedCollectors[collectorName] = true
+			if previous[collectorName] == nil {
+				previous[collectorName] = make(map[string]string)
+			}
+			previous[collectorName][name] = prev
+		}
+		log.Infof("Configuration reload: %s changed from %s to %s", name, logValue(old, secret), logValue(value, secret))
+	}
+	r.current = updated
+
+	if !enabledChanged && len(changedCollectors) == 0 {
+		return reloadError(errs)
+	}
+
+	current := r.live.get()
+	collectors := make(map[string]collector.Collector)
+	closed := make(map[string]bool)
+	for _, name := range expandEnabledCollectors(*r.enabled) {
+		c, exists := current[name]
+		if exists && !changedCollectors[name] {
+			collectors[name] = c
+			continue
+		}
+		if exists {
+			// The collector is closed before its replacement is built,
+			// as both may need the same resources, e.g. the name of an
+			// ETW session.
+			closeCollector(name, c)
+			closed[name] = true
+		}
+		c, err := collector.Build(name)
+		if err != nil {
+			fail("couldn't build collector %s: %v", name, err)
+			r.retryCollectors[name] = true
+			if !exists {
+				continue
+			}
+			for flag, value := range previous[name] {
+				r.retryFlags[flag] = true
+				if err := collector.SetFlag(r.app.GetFlag(flag), value); err != nil {
+					fail("couldn't restore %s: %v", flag, err)
+				}
+			}
+			c, err = collector.Build(name)
+			if err != nil {
+				fail("couldn't rebuild collector %s with its previous settings, disabling it: %v", name, err)
+				continue
+			}
+			log.Warnf("Configuration reload: rebuilt collector %s with its previous settings", name)
+			collectors[name] = c
+			continue
+		}
+		if exists {
+			log.Infof("Configuration reload: rebuilt collector %s", name)
+		} else {
+			log.Infof("Configuration reload: enabled collector %s", name)
+		}
+		collectors[name] = c
+	}
+	r.live.set(collectors)
+	if r.perfStats != nil {
+		if err := r.perfStats.setCollectors(keys(collectors)); err != nil {
+			log.Errorf("Configuration reload: couldn't publish the performance counters of the enabled collectors: %v", err)
+		}
+	}
+	for name, c := range current {
+		if _, ok := collectors[name]; !ok && !closed[name] {
+			log.Infof("Configuration reload: disabled collector %s", name)
+			closeCollector(name, c)
+		}
+	}
+	return reloadError(errs)
+}
+
+// reloadError returns an error listing the failures of a reload, nil if
+// there were none.
+func reloadError(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d changes not applied: %s", len(errs), strings.Join(errs, "; "))
+}
+
+// logValue quotes the value of a flag for the log, or hides it if it is a
+// secret.
+func logValue(value string, secret bool) string {
+	if secret {
+		return "<secret>"
+	}
+	return strconv.Quote(value)
+}
+
+// closeCollector releases the resources of a collector replaced or disabled
+// by a reload.
+func closeCollector(name string, c collector.Collector) {
+	if err := collector.Close(c); err != nil {
+		log.Warnf("Configuration reload: failed to close collector %s: %v", name, err)
+	}
+}
+
+// ServeHTTP reloads the configuration file on POST requests to /-/reload.
+func (r *configReloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	updated, err := config.NewResolver(r.file)
+	if err != nil {
+		log.Errorf("Ignoring invalid configuration file %s: %v", r.file, err)
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Infof("Reloading configuration file %s on request from %s", r.file, req.RemoteAddr)
+	if err := r.reload(updated); err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+	}
+}
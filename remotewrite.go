@@ -0,0 +1,286 @@
+// +build windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus-community/windows_exporter/log"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	remoteWriteMinBackoff = 500 * time.Millisecond
+	remoteWriteMaxBackoff = 30 * time.Second
+)
+
+// remoteWriteLabel and remoteWriteSeries mirror the Label and TimeSeries
+// messages of the remote write protocol, each series holding a single sample.
+type remoteWriteLabel struct {
+	name, value string
+}
+
+type remoteWriteSeries struct {
+	labels    []remoteWriteLabel
+	value     float64
+	timestamp int64
+}
+
+// remoteWriter collects the enabled collectors on a fixed interval and pushes
+// the samples to a Prometheus remote write endpoint, for hosts Prometheus
+// can't scrape.
+type remoteWriter struct {
+	url      string
+	interval time.Duration
+	timeout  time.Duration
+	// labels are added to every series not already having them, typically
+	// job and instance.
+	labels []remoteWriteLabel
+
+	username     string
+	passwordFile string
+	tokenFile    string
+
+	client  *http.Client
+	handler *metricsHandler
+}
+
+// parseRemoteWriteLabels parses a comma-separated list of name=value pairs.
+// The instance label defaults to hostname, and the job label to
+// windows_exporter.
+func parseRemoteWriteLabels(s, hostname string) ([]remoteWriteLabel, error) {
+	labels := map[string]string{
+		"instance": hostname,
+		"job":      "windows_exporter",
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", pair)
+		}
+		labels[parts[0]] = parts[1]
+	}
+
+	result := make([]remoteWriteLabel, 0, len(labels))
+	for name, value := range labels {
+		result = append(result, remoteWriteLabel{name: name, value: value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result, nil
+}
+
+// run pushes the metrics every interval, forever.
+func (w *remoteWriter) run() {
+	log.Infof("Pushing metrics to %s every %s", w.url, w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.push()
+		<-ticker.C
+	}
+}
+
+// push collects the metrics once and sends them, retrying until the next
+// push is due.
+func (w *remoteWriter) push() {
+	deadline := time.Now().Add(w.interval)
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+	reg, err := w.handler.newRegistry(ctx, w.timeout, w.handler.collectors)
+	if err != nil {
+		log.Errorf("Couldn't create registry for remote write: %v", err)
+		return
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		log.Warnf("Error gathering metrics for remote write: %v", err)
+	}
+	series := remoteWriteSeriesOf(families, w.labels, time.Now())
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+
+	backoff := remoteWriteMinBackoff
+	for {
+		retry, err := w.send(body)
+		if err == nil {
+			log.Debugf("Pushed %d series to %s", len(series), w.url)
+			return
+		}
+		if !retry || time.Now().Add(backoff).After(deadline) {
+			log.Errorf("Failed to push %d series to %s: %v", len(series), w.url, err)
+			return
+		}
+		log.Warnf("Failed to push to %s, retrying in %s: %v", w.url, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > remoteWriteMaxBackoff {
+			backoff = remoteWriteMaxBackoff
+		}
+	}
+}
+
+// send sends a compressed WriteRequest. It returns whether a failed request
+// may succeed when retried, as for server errors, but not for rejected data.
+func (w *remoteWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "windows_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	// The secrets are read on every push, so that they can be rotated
+	// without restarting the exporter.
+	switch {
+	case w.username != "":
+		password, err := readSecretFile(w.passwordFile)
+		if err != nil {
+			return false, err
+		}
+		req.SetBasicAuth(w.username, password)
+	case w.tokenFile != "":
+		token, err := readSecretFile(w.tokenFile)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+func readSecretFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// remoteWriteSeriesOf flattens the metric families into series, as Prometheus
+// would store them when scraping: summaries and histograms are split into
+// their quantiles or buckets, _sum and _count. Samples without a timestamp of
+// their own are timestamped now.
+func remoteWriteSeriesOf(families []*dto.MetricFamily, extra []remoteWriteLabel, now time.Time) []remoteWriteSeries {
+	var series []remoteWriteSeries
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := now.UnixNano() / int64(time.Millisecond)
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(suffix string, value float64, labelName, labelValue string) {
+				labels := []remoteWriteLabel{{name: "__name__", value: name + suffix}}
+				seen := make(map[string]bool, len(m.GetLabel())+1)
+				for _, lp := range m.GetLabel() {
+					labels = append(labels, remoteWriteLabel{name: lp.GetName(), value: lp.GetValue()})
+					seen[lp.GetName()] = true
+				}
+				if labelName != "" {
+					labels = append(labels, remoteWriteLabel{name: labelName, value: labelValue})
+					seen[labelName] = true
+				}
+				for _, l := range extra {
+					if !seen[l.name] {
+						labels = append(labels, l)
+					}
+				}
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				series = append(series, remoteWriteSeries{labels: labels, value: value, timestamp: ts})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue(), "", "")
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+				}
+				add("_sum", s.GetSampleSum(), "", "")
+				add("_count", float64(s.GetSampleCount()), "", "")
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						infSeen = true
+					}
+					add("_bucket", float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				if !infSeen {
+					add("_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				}
+				add("_sum", h.GetSampleSum(), "", "")
+				add("_count", float64(h.GetSampleCount()), "", "")
+			}
+		}
+	}
+	return series
+}
+
+// encodeWriteRequest encodes the series as a remote write WriteRequest
+// protobuf message.
+func encodeWriteRequest(series []remoteWriteSeries) []byte {
+	var b []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+	return b
+}
@@ -0,0 +1,28 @@
+// +build windows
+
+package main
+
+import (
+	"io"
+	"sort"
+
+	"github.com/prometheus-community/windows_exporter/collector"
+	"gopkg.in/yaml.v2"
+)
+
+// runGenerateRules writes a Prometheus rule file with the curated recording
+// rules and alerts of the enabled collectors.
+func runGenerateRules(enabled string, w io.Writer) error {
+	names := expandEnabledCollectors(enabled)
+	sort.Strings(names)
+	// Keep expressions on a single line.
+	yaml.FutureLineWrap()
+	out, err := yaml.Marshal(struct {
+		Groups []collector.RuleGroup `yaml:"groups"`
+	}{collector.RuleGroups(names)})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
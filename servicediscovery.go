@@ -0,0 +1,186 @@
+// +build windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StackExchange/wmi"
+	"github.com/prometheus-community/windows_exporter/log"
+)
+
+// sdNamespace is the WMI namespace of the Active Directory provider, which
+// exposes directory objects as ds_<class> instances with DS_<attribute> properties.
+const sdNamespace = "root\\directory\\LDAP"
+
+type ds_computer struct {
+	DS_dNSHostName            string
+	DS_distinguishedName      string
+	DS_operatingSystem        string
+	DS_operatingSystemVersion string
+}
+
+// sdTargetGroup is a target group of the Prometheus HTTP service discovery format.
+// https://prometheus.io/docs/prometheus/latest/http_sd/
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// sdFilter selects the computers exposed as targets.
+type sdFilter struct {
+	// bases are DNs of OUs or containers; computers below any of them match.
+	// Empty matches all computers.
+	bases []string
+	// osPatterns are case-insensitive shell patterns for the operatingSystem
+	// attribute, e.g. "Windows Server*", which must all match. Empty matches
+	// all computers.
+	osPatterns []string
+}
+
+func (f sdFilter) match(c ds_computer) (bool, error) {
+	if c.DS_dNSHostName == "" {
+		return false, nil
+	}
+	for _, pattern := range f.osPatterns {
+		ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(c.DS_operatingSystem))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if len(f.bases) == 0 {
+		return true, nil
+	}
+	dn := strings.ToLower(c.DS_distinguishedName)
+	for _, base := range f.bases {
+		if strings.HasSuffix(dn, ","+strings.ToLower(strings.TrimSpace(base))) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// narrow returns f further restricted to the computers below ous and those
+// whose operatingSystem matches osPattern. Every OU must be one of the bases
+// of f or below one, so that clients can't widen the configured scope.
+func (f sdFilter) narrow(ous []string, osPattern string) (sdFilter, error) {
+	narrowed := sdFilter{bases: f.bases, osPatterns: f.osPatterns}
+	if len(ous) > 0 {
+		for _, ou := range ous {
+			if !f.contains(ou) {
+				return sdFilter{}, fmt.Errorf("%q is not below any of the search bases", ou)
+			}
+		}
+		narrowed.bases = ous
+	}
+	if osPattern != "" {
+		narrowed.osPatterns = append(append([]string{}, f.osPatterns...), osPattern)
+	}
+	return narrowed, nil
+}
+
+// contains reports whether dn is one of the bases of f or below one.
+func (f sdFilter) contains(dn string) bool {
+	if len(f.bases) == 0 {
+		return true
+	}
+	dn = strings.ToLower(strings.TrimSpace(dn))
+	for _, base := range f.bases {
+		base = strings.ToLower(strings.TrimSpace(base))
+		if dn == base || strings.HasSuffix(dn, ","+base) {
+			return true
+		}
+	}
+	return false
+}
+
+// sdTargetGroups returns one target group per computer matching f, so that
+// every target carries its own meta labels.
+func sdTargetGroups(computers []ds_computer, f sdFilter, port int) ([]sdTargetGroup, error) {
+	groups := []sdTargetGroup{}
+	for _, c := range computers {
+		ok, err := f.match(c)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		groups = append(groups, sdTargetGroup{
+			Targets: []string{net.JoinHostPort(strings.ToLower(c.DS_dNSHostName), strconv.Itoa(port))},
+			Labels: map[string]string{
+				"__meta_windows_ad_dn":         c.DS_distinguishedName,
+				"__meta_windows_ad_os":         c.DS_operatingSystem,
+				"__meta_windows_ad_os_version": c.DS_operatingSystemVersion,
+			},
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Targets[0] < groups[j].Targets[0] })
+	return groups, nil
+}
+
+// sdHandler serves the computer objects of the local computer's domain as
+// Prometheus HTTP service discovery targets. The computers are read from the
+// directory at most once every refreshInterval.
+type sdHandler struct {
+	filter          sdFilter
+	port            int
+	refreshInterval time.Duration
+
+	mu        sync.Mutex
+	refreshed time.Time
+	computers []ds_computer
+}
+
+func (h *sdHandler) listComputers() ([]ds_computer, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.computers != nil && time.Since(h.refreshed) < h.refreshInterval {
+		return h.computers, nil
+	}
+
+	var dst []ds_computer
+	q := "SELECT DS_dNSHostName, DS_distinguishedName, DS_operatingSystem, DS_operatingSystemVersion FROM ds_computer"
+	if err := wmi.QueryNamespace(q, &dst, sdNamespace); err != nil {
+		return nil, err
+	}
+	log.Debugf("Read %d computer objects for service discovery", len(dst))
+	h.computers = dst
+	h.refreshed = time.Now()
+	return dst, nil
+}
+
+// ServeHTTP writes the matching targets. The ou and os query parameters
+// narrow the configured filter; ou may be repeated.
+func (h *sdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := h.filter.narrow(r.URL.Query()["ou"], r.URL.Query().Get("os"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %s", err), http.StatusForbidden)
+		return
+	}
+
+	computers, err := h.listComputers()
+	if err != nil {
+		log.Errorf("Failed to read computer objects: %v", err)
+		http.Error(w, fmt.Sprintf("error reading computer objects: %s", err), http.StatusInternalServerError)
+		return
+	}
+	groups, err := sdTargetGroups(computers, filter, h.port)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %s", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		log.Debugf("Failed to write to stream: %v", err)
+	}
+}
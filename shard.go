@@ -0,0 +1,59 @@
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// exposureShard selects the metrics served on /metrics?shard=N/M, so that
+// the metrics of very large hosts can be split across several scrape jobs.
+type exposureShard struct {
+	index, total uint64
+}
+
+// parseExposureShard parses a shard of the form N/M, N counting from 0.
+func parseExposureShard(s string) (exposureShard, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return exposureShard{}, fmt.Errorf("invalid shard %q, expected N/M", s)
+	}
+	index, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return exposureShard{}, fmt.Errorf("invalid shard %q, expected N/M", s)
+	}
+	total, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || total == 0 || index >= total {
+		return exposureShard{}, fmt.Errorf("invalid shard %q, expected N/M with 0 <= N < M", s)
+	}
+	return exposureShard{index: index, total: total}, nil
+}
+
+// contains reports whether the metric of the given name belongs to the shard.
+// All series of a metric belong to the same shard, so that queries on a
+// single metric don't span scrape jobs.
+func (s exposureShard) contains(name string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()%s.total == s.index
+}
+
+// gatherer returns a gatherer of the metric families of g in the shard.
+func (s exposureShard) gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := g.Gather()
+		var result []*dto.MetricFamily
+		for _, mf := range families {
+			if s.contains(mf.GetName()) {
+				result = append(result, mf)
+			}
+		}
+		return result, err
+	})
+}
@@ -17,10 +17,26 @@ else {
     $wmiObject = Get-CimInstance -ComputerName $ComputerName -Class $Class
 }
 
+# Amended qualifiers hold the localized explain text of each counter, which is
+# used as the HELP text of the generated metrics.
+$options = New-Object System.Management.ObjectGetOptions
+$options.UseAmendedQualifiers = $true
+$scope = New-Object System.Management.ManagementScope("\\$ComputerName\root\cimv2")
+$wmiClass = New-Object System.Management.ManagementClass($scope, (New-Object System.Management.ManagementPath($Class)), $options)
+
+function Get-Help($Name) {
+    try {
+        return $wmiClass.Properties[$Name].Qualifiers["Description"].Value
+    }
+    catch {
+        return ""
+    }
+}
+
 $members = $wmiObject `
     | Get-Member -MemberType Properties `
     | Where-Object { $_.Definition -Match '^u?int' -and $_.Name -NotMatch '_' } `
-    | Select-Object Name, @{Name="Type";Expression={$_.Definition.Split(" ")[0]}}
+    | Select-Object Name, @{Name="Type";Expression={$_.Definition.Split(" ")[0]}}, @{Name="Help";Expression={Get-Help $_.Name}}
 $input = @{
     "Class"=$Class;
     "CollectorName"=$CollectorName;
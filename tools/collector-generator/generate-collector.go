@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"text/template"
 	"unicode"
@@ -17,6 +18,8 @@ type TemplateData struct {
 type Member struct {
 	Name string
 	Type string
+	// Help is the explain text of the counter, if available.
+	Help string
 }
 
 func main() {
@@ -32,6 +35,7 @@ func main() {
 	funcs := template.FuncMap{
 		"toLower":     strings.ToLower,
 		"toSnakeCase": toSnakeCase,
+		"helpText":    helpText,
 	}
 	tmpl, err := template.New("template").Funcs(funcs).ParseFiles("collector.template")
 	if err != nil {
@@ -43,6 +47,16 @@ func main() {
 	}
 }
 
+// helpText returns the quoted HELP text of a metric, falling back to the
+// member name in parentheses if no explain text is available.
+func helpText(m Member) string {
+	help := strings.Join(strings.Fields(m.Help), " ")
+	if help == "" {
+		return strconv.Quote("(" + m.Name + ")")
+	}
+	return strconv.Quote(help + " (" + m.Name + ")")
+}
+
 // https://gist.github.com/elwinar/14e1e897fdbe4d3432e1
 func toSnakeCase(in string) string {
 	runes := []rune(in)
@@ -0,0 +1,85 @@
+// +build windows
+
+// Command counter-help writes the explain texts of the counters of the given
+// performance objects, as registered on this host, to a Go source file of the
+// collector package. counterHelp falls back to them when a text can't be
+// looked up at runtime, e.g. on hosts without the counter provider's
+// localized texts.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/leoluk/perflib_exporter/perflib"
+)
+
+func main() {
+	out := flag.String("o", "counter_help_generated.go", "File to write the explain texts to.")
+	flag.Parse()
+
+	objects, err := perflib.QueryPerformanceData("Global")
+	if err != nil {
+		log.Fatalf("Failed to query the performance objects: %v", err)
+	}
+	texts := make(map[string]map[string]string)
+	for _, name := range flag.Args() {
+		texts[name] = nil
+	}
+	for _, obj := range objects {
+		if _, ok := texts[obj.Name]; !ok {
+			continue
+		}
+		counters := make(map[string]string)
+		for _, def := range obj.CounterDefs {
+			if _, exists := counters[def.Name]; exists || def.IsBaseValue {
+				continue
+			}
+			if text := strings.TrimSpace(def.HelpText); text != "" {
+				counters[def.Name] = text
+			}
+		}
+		texts[obj.Name] = counters
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by tools/counter-help; DO NOT EDIT.\n\n")
+	b.WriteString("package collector\n\n")
+	b.WriteString("// generatedHelpTexts holds the explain texts of the counters of the objects\n")
+	b.WriteString("// of static collectors, by object and counter name.\n")
+	b.WriteString("var generatedHelpTexts = map[string]map[string]string{\n")
+	names := flag.Args()
+	sort.Strings(names)
+	for _, name := range names {
+		if texts[name] == nil {
+			log.Printf("Performance object %q not found, skipping it", name)
+			continue
+		}
+		fmt.Fprintf(&b, "%q: {\n", name)
+		counters := make([]string, 0, len(texts[name]))
+		for counter := range texts[name] {
+			counters = append(counters, counter)
+		}
+		sort.Strings(counters)
+		for _, counter := range counters {
+			fmt.Fprintf(&b, "%q: %q,\n", counter, texts[name][counter])
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("Failed to format the generated source: %v", err)
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+}
+
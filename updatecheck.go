@@ -0,0 +1,224 @@
+// +build windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+)
+
+// buildChannel is the release channel of the build, set with
+// -X main.buildChannel=... If empty, it is derived from the version.
+var buildChannel string
+
+// releaseChannel returns the release channel of the running build: dev for
+// builds without a version, prerelease for versions with a pre-release
+// suffix, e.g. 0.17.0-rc.1, and stable otherwise.
+func releaseChannel() string {
+	if buildChannel != "" {
+		return buildChannel
+	}
+	switch {
+	case version.Version == "":
+		return "dev"
+	case strings.Contains(version.Version, "-"):
+		return "prerelease"
+	default:
+		return "stable"
+	}
+}
+
+// newBuildInfoCollector returns the windows_exporter_build_info metric of
+// version.NewCollector, with the release channel as an additional label.
+func newBuildInfoCollector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "windows_exporter",
+			Name:      "build_info",
+			Help:      "A metric with a constant '1' value labeled by version, revision, branch, channel, and goversion from which windows_exporter was built.",
+			ConstLabels: prometheus.Labels{
+				"version":   version.Version,
+				"revision":  version.Revision,
+				"branch":    version.Branch,
+				"channel":   releaseChannel(),
+				"goversion": version.GoVersion,
+			},
+		},
+		func() float64 { return 1 },
+	)
+}
+
+// releaseFeedEntry is a release of a feed in the format of the GitHub releases
+// API, e.g. https://api.github.com/repos/prometheus-community/windows_exporter/releases
+type releaseFeedEntry struct {
+	TagName    string `json:"tag_name"`
+	Prerelease bool   `json:"prerelease"`
+	Draft      bool   `json:"draft"`
+}
+
+// updateChecker periodically reads a release feed and reports whether a newer
+// release than the running one is available on its channel.
+type updateChecker struct {
+	url      string
+	interval time.Duration
+	channel  string
+	client   *http.Client
+
+	desc *prometheus.Desc
+
+	mu sync.Mutex
+	// latest is the newest release of the channel, empty until the feed was
+	// read successfully.
+	latest string
+}
+
+func newUpdateChecker(url string, interval time.Duration) *updateChecker {
+	return &updateChecker{
+		url:      url,
+		interval: interval,
+		channel:  releaseChannel(),
+		client:   &http.Client{Timeout: time.Minute},
+		desc: prometheus.NewDesc(
+			"windows_exporter_update_available",
+			"Whether a newer release of windows_exporter than the running one is available on its channel (1) or not (0)",
+			[]string{"latest_version"},
+			nil,
+		),
+	}
+}
+
+// run checks the feed every interval, forever.
+func (u *updateChecker) run() {
+	log.Infof("Checking %s for %s releases every %s", u.url, u.channel, u.interval)
+	for {
+		if err := u.check(); err != nil {
+			log.Warnf("Failed to check for windows_exporter updates: %v", err)
+		}
+		time.Sleep(u.interval)
+	}
+}
+
+func (u *updateChecker) check() error {
+	resp, err := u.client.Get(u.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("release feed returned %s", resp.Status)
+	}
+	var entries []releaseFeedEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return fmt.Errorf("couldn't parse release feed: %v", err)
+	}
+	latest := latestRelease(entries, u.channel)
+	if latest == "" {
+		return fmt.Errorf("release feed has no %s releases", u.channel)
+	}
+
+	u.mu.Lock()
+	u.latest = latest
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *updateChecker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- u.desc
+}
+
+func (u *updateChecker) Collect(ch chan<- prometheus.Metric) {
+	u.mu.Lock()
+	latest := u.latest
+	u.mu.Unlock()
+	// Nothing is reported until the feed was read.
+	if latest == "" {
+		return
+	}
+
+	available := 0.0
+	if compareVersions(latest, version.Version) > 0 {
+		available = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(u.desc, prometheus.GaugeValue, available, latest)
+}
+
+// latestRelease returns the newest version of the releases of the feed on the
+// channel, without the v prefix of the tag. Drafts are ignored, as are
+// pre-releases on the stable channel.
+func latestRelease(entries []releaseFeedEntry, channel string) string {
+	var latest string
+	for _, e := range entries {
+		if e.Draft || (e.Prerelease && channel == "stable") {
+			continue
+		}
+		v := strings.TrimPrefix(e.TagName, "v")
+		if latest == "" || compareVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// compareVersions compares two semantic versions, returning -1, 0 or 1 if a
+// is older than, the same as or newer than b. Missing or non-numeric parts
+// count as 0, and a version with a pre-release suffix is older than the
+// release itself. Pre-release suffixes are compared as strings.
+func compareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		var x, y int
+		if i < len(aCore) {
+			x = aCore[i]
+		}
+		if i < len(bCore) {
+			y = bCore[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// splitVersion splits a version into its numeric parts and its pre-release
+// suffix, ignoring a v prefix and build metadata.
+func splitVersion(v string) ([]int, string) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	var pre string
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	var core []int
+	for _, part := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(part)
+		core = append(core, n)
+	}
+	return core, pre
+}